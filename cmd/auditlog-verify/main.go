@@ -0,0 +1,83 @@
+// auditlog-verify checks that a Rancher audit log written with hash chaining enabled has not been modified. Rotated
+// log files must be passed in the order they were written, oldest first. If no files are given the log is read from
+// stdin.
+//
+// Usage:
+//
+//	auditlog-verify [-public-key key.pub] [-json] [file ...]
+package main
+
+import (
+	"crypto"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rancher/rancher/pkg/auth/audit"
+)
+
+func main() {
+	publicKeyPath := flag.String("public-key", "", "PEM encoded public key used to verify checkpoint signatures, for example the key.pub entry of the cattle-system/audit-log-signing-key secret")
+	asJSON := flag.Bool("json", false, "Print the verification report as JSON")
+	flag.Parse()
+
+	report, err := verify(*publicKeyPath, flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		printReport(report)
+	}
+
+	if !report.Valid() {
+		os.Exit(1)
+	}
+}
+
+func verify(publicKeyPath string, paths []string) (audit.ChainReport, error) {
+	var publicKey crypto.PublicKey
+	if publicKeyPath != "" {
+		data, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			return audit.ChainReport{}, fmt.Errorf("failed to read public key: %w", err)
+		}
+
+		if publicKey, err = audit.ParseCheckpointPublicKey(data); err != nil {
+			return audit.ChainReport{}, err
+		}
+	}
+
+	if len(paths) == 0 {
+		return audit.VerifyChain(os.Stdin, publicKey)
+	}
+
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return audit.ChainReport{}, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer f.Close()
+
+		readers = append(readers, f)
+	}
+
+	return audit.VerifyChain(io.MultiReader(readers...), publicKey)
+}
+
+func printReport(report audit.ChainReport) {
+	for _, issue := range report.Issues {
+		fmt.Printf("line %d: sequence %d: %s: %s\n", issue.Line, issue.Sequence, issue.Type, issue.Message)
+	}
+
+	fmt.Printf("records: %d, checkpoints: %d, records after last checkpoint: %d, issues: %d\n",
+		report.Records, report.Checkpoints, report.Unanchored, len(report.Issues))
+}
@@ -147,6 +147,33 @@ func main() {
 			EnvVar:      "AUDIT_LOG_ENABLED",
 			Destination: &config.AuditLogEnabled,
 		},
+		cli.StringFlag{
+			Name:        "audit-log-spool-dir",
+			EnvVar:      "AUDIT_LOG_SPOOL_DIR",
+			Value:       "/var/log/auditlog/spool",
+			Usage:       "Directory used to hold audit logs that could not yet be delivered to an audit policy sink",
+			Destination: &config.AuditLogSpoolDir,
+		},
+		cli.IntFlag{
+			Name:        "audit-log-spool-maxsize",
+			Value:       100,
+			EnvVar:      "AUDIT_LOG_SPOOL_MAXSIZE",
+			Usage:       "Defines the maximum size in megabytes of the spool kept for each audit policy sink, default size is 100M",
+			Destination: &config.AuditLogSpoolMaxsize,
+		},
+		cli.BoolFlag{
+			Name:        "audit-log-hash-chain",
+			Usage:       "Link each audit log record to the previous one with a hash and write signed checkpoints so tampering can be detected",
+			EnvVar:      "AUDIT_LOG_HASH_CHAIN",
+			Destination: &config.AuditLogHashChain,
+		},
+		cli.IntFlag{
+			Name:        "audit-log-checkpoint-interval",
+			Value:       1000,
+			EnvVar:      "AUDIT_LOG_CHECKPOINT_INTERVAL",
+			Usage:       "Defines the number of audit log records written between signed checkpoints when hash chaining is enabled",
+			Destination: &config.AuditLogCheckpointInterval,
+		},
 		cli.StringFlag{
 			Name:        "profile-listen-address",
 			Value:       "127.0.0.1:6060",
@@ -167,6 +194,12 @@ func main() {
 			Usage:       "Declare a different timeout duration when waiting to registration requests from the aggregation layer",
 			Destination: &config.AggregationRegistrationTimeout,
 		},
+		cli.StringFlag{
+			Name:        "encryption-provider-config",
+			EnvVar:      "CATTLE_ENCRYPTION_PROVIDER_CONFIG",
+			Usage:       "Path of the key provider configuration used to encrypt node driver configs and cloud credentials, stored unencrypted if not set",
+			Destination: &config.EncryptionProviderConfig,
+		},
 	}
 
 	app.Action = func(c *cli.Context) error {
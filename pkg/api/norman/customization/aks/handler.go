@@ -18,6 +18,7 @@
 	"github.com/rancher/norman/types"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	"github.com/rancher/rancher/pkg/controllers/management/cluster"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	mgmtv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -248,6 +249,9 @@ func (h *handler) getCloudCredential(req *http.Request, cap *Capabilities, credI
 	}
 
 	cc, err := h.secretsLister.Get(ns, name)
+	if err == nil {
+		cc, err = encryptedstore.DecryptSecret(cc)
+	}
 	if err != nil {
 		logrus.Errorf("[AKS] error accessing cloud credential %s", credID)
 		return httperror.InvalidBodyContent.Status, fmt.Errorf("error accessing cloud credential %s", credID)
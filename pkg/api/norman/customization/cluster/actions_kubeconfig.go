@@ -6,7 +6,9 @@
 	"strings"
 
 	"github.com/rancher/norman/api/access"
+	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	mgmtclient "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	mgmtv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kubeconfig"
@@ -37,6 +39,9 @@ func (a ActionHandler) GenerateKubeconfigActionHandler(actionName string, action
 
 	generateToken := strings.EqualFold(settings.KubeconfigGenerateToken.Get(), "true")
 	if generateToken {
+		if err := scope.CheckIssuance(apiContext.Request.Context()); err != nil {
+			return httperror.NewAPIError(httperror.PermissionDenied, err.Error())
+		}
 		// generate token and place it in kubeconfig, token doesn't expire
 		if endpointEnabled {
 			tokenKey, err = a.ensureClusterToken(cluster.ID, apiContext)
@@ -12,17 +12,21 @@
 	"github.com/rancher/norman/types/convert"
 	"github.com/rancher/rancher/pkg/api/norman/customization/namespacedresource"
 	"github.com/rancher/rancher/pkg/controllers/provisioningv2/cluster"
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	provv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kontainer-engine/store"
 	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/ref"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/yaml"
 )
 
-func Wrap(store types.Store, ns v1.NamespaceInterface, secretLister v1.SecretLister, provClusterCache provv1.ClusterCache, tokenLister v3.TokenLister) types.Store {
+func Wrap(store types.Store, ns v1.NamespaceInterface, secretLister v1.SecretLister, provClusterCache provv1.ClusterCache, clusterCache mgmtcontrollers.ClusterCache, tokenLister v3.TokenLister) types.Store {
 	transformStore := &transform.Store{
 		Store: store,
 		Transformer: func(apiContext *types.APIContext, schema *types.Schema, data map[string]interface{}, opt *types.QueryOptions) (map[string]interface{}, error) {
@@ -41,6 +45,7 @@ func Wrap(store types.Store, ns v1.NamespaceInterface, secretLister v1.SecretLis
 		Store:            transformStore,
 		SecretLister:     secretLister,
 		ProvClusterCache: provClusterCache,
+		ClusterCache:     clusterCache,
 		TokenLister:      tokenLister,
 	}
 
@@ -59,6 +64,10 @@ func configExists(data map[string]interface{}) bool {
 }
 
 func decodeNonPasswordFields(data map[string]interface{}) error {
+	annotations := map[string]string{}
+	for k, v := range convert.ToMapInterface(data["annotations"]) {
+		annotations[k] = convert.ToString(v)
+	}
 	for key, val := range data {
 		if strings.HasSuffix(key, "Config") {
 			ans := convert.ToMapInterface(val)
@@ -67,6 +76,10 @@ func decodeNonPasswordFields(data map[string]interface{}) error {
 				if err != nil {
 					return err
 				}
+				decoded, err = encryptedstore.DecryptValue(annotations, key+"-"+field, decoded)
+				if err != nil {
+					return err
+				}
 				ans[field] = string(decoded)
 			}
 		}
@@ -86,6 +99,7 @@ type Store struct {
 	types.Store
 	SecretLister     v1.SecretLister
 	ProvClusterCache provv1.ClusterCache
+	ClusterCache     mgmtcontrollers.ClusterCache
 	TokenLister      v3.TokenLister
 }
 
@@ -141,7 +155,13 @@ func (s *Store) processHarvesterCloudCredential(data map[string]any) error {
 					continue
 				}
 
-				err := TokenNamesFromContent(secret.Data["harvestercredentialConfig-kubeconfigContent"], knownTokens)
+				secret, err := encryptedstore.DecryptSecret(secret)
+				if err != nil {
+					logrus.Errorf("failed to decrypt secret: %v", err)
+					continue
+				}
+
+				err = TokenNamesFromContent(secret.Data["harvestercredentialConfig-kubeconfigContent"], knownTokens)
 				if err != nil {
 					// If a secret is all messed up, let the user do whatever they want: it shouldn't be usable anyway and will be remediated when updated.
 					logrus.Errorf("failed to get tokens from secret: %v", err)
@@ -164,6 +184,10 @@ func (s *Store) Create(apiContext *types.APIContext, schema *types.Schema, data
 		return nil, fmt.Errorf("failed to process harvester cloud credential: %w", err)
 	}
 
+	if err := s.encryptConfig(data, nil); err != nil {
+		return nil, err
+	}
+
 	return s.Store.Create(apiContext, schema, data)
 }
 
@@ -172,9 +196,103 @@ func (s *Store) Update(apiContext *types.APIContext, schema *types.Schema, data
 		return nil, fmt.Errorf("failed to process harvester cloud credential: %w", err)
 	}
 
+	if encryptedstore.CloudCredentialsEnabled() {
+		ns, name := ref.Parse(id)
+		existing, err := s.SecretLister.Get(ns, name)
+		if err != nil {
+			return nil, err
+		}
+		hosted, err := s.usedByHostedCluster(ns + ":" + name)
+		if err != nil {
+			return nil, err
+		}
+		if !hosted {
+			if err := s.encryptConfig(data, existing); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return s.Store.Update(apiContext, schema, data, id)
 }
 
+// encryptConfig replaces the config of the credential with the data of the secret encrypted, if cloud credentials are
+// encrypted, so the credential is never written in plaintext. The values of the existing secret are encrypted along
+// with the new ones, since they share the data encryption key.
+func (s *Store) encryptConfig(data map[string]interface{}, existing *corev1.Secret) error {
+	if !encryptedstore.CloudCredentialsEnabled() {
+		return nil
+	}
+	configKey := ""
+	for key, val := range data {
+		if val != nil && strings.HasSuffix(key, "Config") {
+			configKey = key
+			break
+		}
+	}
+	if configKey == "" {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if existing != nil {
+		decrypted, err := encryptedstore.DecryptSecret(existing)
+		if err != nil {
+			return err
+		}
+		secret = decrypted.DeepCopy()
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for field, val := range convert.ToMapInterface(data[configKey]) {
+		secret.Data[configKey+"-"+field] = []byte(convert.ToString(val))
+	}
+	encrypted, err := encryptedstore.EncryptSecret(secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt cloud credential: %w", err)
+	}
+
+	secretData := map[string]interface{}{}
+	for key, val := range encrypted.Data {
+		secretData[key] = base64.StdEncoding.EncodeToString(val)
+	}
+	annotations := map[string]interface{}{}
+	if existing != nil {
+		for key, val := range existing.Annotations {
+			annotations[key] = val
+		}
+	}
+	for key, val := range convert.ToMapInterface(data["annotations"]) {
+		annotations[key] = val
+	}
+	for _, key := range []string{encryptedstore.ProviderAnnotation, encryptedstore.KeyIDAnnotation, encryptedstore.DEKAnnotation} {
+		annotations[key] = encrypted.Annotations[key]
+	}
+	delete(data, configKey)
+	data["data"] = secretData
+	data["annotations"] = annotations
+	return nil
+}
+
+// usedByHostedCluster returns whether the credential with the given ID is read by the operator of a hosted cluster, in
+// which case it is stored unencrypted.
+func (s *Store) usedByHostedCluster(id string) (bool, error) {
+	clusters, err := s.ClusterCache.List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	for _, cluster := range clusters {
+		credentials, _ := encryptedstore.HostedCloudCredentials(cluster)
+		for _, credential := range credentials {
+			if credential == id {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func (s *Store) Delete(apiContext *types.APIContext, schema *types.Schema, id string) (map[string]interface{}, error) {
 	// make sure the credential isn't being used by an active RKE2/K3s cluster
 	if provClusters, err := s.ProvClusterCache.GetByIndex(cluster.ByCloudCred, id); err != nil {
@@ -1,12 +1,19 @@
 package cred
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	corefakes "github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
 	v3fakes "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -268,3 +275,57 @@ func TestProcessHarvesterCloudCredential(t *testing.T) {
 		})
 	}
 }
+
+func TestEncryptConfig(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys.yaml")
+	secret := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, os.WriteFile(keyFile, []byte(fmt.Sprintf("keys:\n- name: key1\n  secret: %s\n", secret)), 0600))
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf("provider: local\ncloudCredentials: true\nlocal:\n  keyFile: %s\n", keyFile)), 0600))
+	require.NoError(t, encryptedstore.Configure(context.Background(), configFile))
+
+	// toSecret returns the secret the data is written as, after the config was encrypted.
+	toSecret := func(data map[string]interface{}) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+			Data:       map[string][]byte{},
+		}
+		for key, val := range data["annotations"].(map[string]interface{}) {
+			secret.Annotations[key] = val.(string)
+		}
+		for key, val := range data["data"].(map[string]interface{}) {
+			decoded, err := base64.StdEncoding.DecodeString(val.(string))
+			require.NoError(t, err)
+			secret.Data[key] = decoded
+		}
+		return secret
+	}
+
+	s := &Store{}
+	data := map[string]interface{}{
+		"annotations":               map[string]interface{}{"field.cattle.io/name": "aws"},
+		"amazonec2credentialConfig": map[string]interface{}{"accessKey": "AKIA", "secretKey": "secret"},
+	}
+	require.NoError(t, s.encryptConfig(data, nil))
+	assert.NotContains(t, data, "amazonec2credentialConfig")
+	created := toSecret(data)
+	assert.True(t, encryptedstore.IsEncrypted(created))
+	assert.Equal(t, "aws", created.Annotations["field.cattle.io/name"])
+	assert.NotContains(t, string(created.Data["amazonec2credentialConfig-secretKey"]), "secret")
+
+	// an update encrypts the existing values again along with the new ones
+	data = map[string]interface{}{
+		"amazonec2credentialConfig": map[string]interface{}{"secretKey": "rotated"},
+	}
+	require.NoError(t, s.encryptConfig(data, created))
+	updated := toSecret(data)
+	assert.Equal(t, "aws", updated.Annotations["field.cattle.io/name"])
+	assert.NotEqual(t, created.Annotations[encryptedstore.DEKAnnotation], updated.Annotations[encryptedstore.DEKAnnotation])
+	decrypted, err := encryptedstore.DecryptSecret(updated)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"amazonec2credentialConfig-accessKey": []byte("AKIA"),
+		"amazonec2credentialConfig-secretKey": []byte("rotated"),
+	}, decrypted.Data)
+}
@@ -15,6 +15,7 @@
 	"github.com/rancher/rancher/pkg/auth/util"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	provcluster "github.com/rancher/rancher/pkg/controllers/provisioningv2/cluster"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	provv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	"github.com/rancher/rancher/pkg/namespace"
@@ -169,6 +170,9 @@ func (handler *handler) extractCreds(req *http.Request, creds *Credentials) (int
 		}
 
 		cc, err := handler.secretsLister.Get(namespace.GlobalNamespace, name)
+		if err == nil {
+			cc, err = encryptedstore.DecryptSecret(cc)
+		}
 		if err != nil {
 			logrus.Debugf("[oci-handler] error accessing cloud credential %s", credID)
 			return httperror.InvalidBodyContent.Status, fmt.Errorf("error accessing cloud credential %s", credID)
@@ -11,6 +11,7 @@
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	v3client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
 )
 
 var ReadOnlySettings = []string{
@@ -68,6 +69,9 @@ func Validator(request *types.APIContext, schema *types.Schema, data map[string]
 	case "auth-user-info-resync-cron":
 		_, err = providerrefresh.ParseCron(newValueString)
 	}
+	if err == nil {
+		err = settings.Validate(id, newValueString)
+	}
 
 	if err != nil {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, fmt.Sprintf("%v", err))
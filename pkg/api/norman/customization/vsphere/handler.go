@@ -13,6 +13,7 @@
 	"github.com/rancher/rancher/pkg/auth/util"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	provcluster "github.com/rancher/rancher/pkg/controllers/provisioningv2/cluster"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	provv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	"github.com/rancher/rancher/pkg/namespace"
@@ -205,6 +206,9 @@ func (v *handler) getCloudCredential(id string, req *http.Request) (*corev1.Secr
 	}
 
 	cc, err := v.secretsLister.Get(namespace.GlobalNamespace, name)
+	if err == nil {
+		cc, err = encryptedstore.DecryptSecret(cc)
+	}
 	if err != nil || cc == nil {
 		return nil, httperror.InvalidBodyContent, fmt.Errorf("error getting cloud cred %s: %v", id, err)
 	}
@@ -235,6 +239,9 @@ func (v *handler) getSecret(id string, req *http.Request) (*corev1.Secret, httpe
 	}
 
 	cc, err := v.secretsLister.Get(defaultNamespace, id)
+	if err == nil {
+		cc, err = encryptedstore.DecryptSecret(cc)
+	}
 	if err != nil || cc == nil {
 		return nil, httperror.InvalidBodyContent, fmt.Errorf("error getting cloud cred %s: %v", id, err)
 	}
@@ -234,6 +234,7 @@ func SecretTypes(ctx context.Context, schemas *types.Schemas, management *config
 		management.Core.Namespaces(""),
 		management.Core.Secrets("").Controller().Lister(),
 		management.Wrangler.Provisioning.Cluster().Cache(),
+		management.Wrangler.Mgmt.Cluster().Cache(),
 		management.Management.Tokens("").Controller().Lister(),
 	)
 	credSchema.Validator = cred.Validator
@@ -4,6 +4,7 @@
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/store/transform"
@@ -11,6 +12,7 @@
 	"github.com/rancher/norman/types/convert"
 	"github.com/rancher/norman/types/slice"
 	"github.com/rancher/rancher/pkg/api/norman/customization/setting"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
 )
 
@@ -82,9 +84,50 @@ func (s *Store) Update(apiContext *types.APIContext, schema *types.Schema, data
 		}
 		data["labels"] = labels
 	}
+	// The history is only ever recorded by the server, clients must not be able to rewrite it.
+	delete(data, "history")
+	if val, ok := data["value"]; ok {
+		history, err := s.appendRevision(apiContext, schema, id, convert.ToString(val))
+		if err != nil {
+			return nil, err
+		}
+		data["history"] = history
+	}
 	return s.Store.Update(apiContext, schema, data, id)
 }
 
+// appendRevision returns the history of the setting with a revision recording the change to value by the requesting
+// user appended to it.
+func (s *Store) appendRevision(apiContext *types.APIContext, schema *types.Schema, id, value string) ([]interface{}, error) {
+	existing, err := s.Store.ByID(apiContext, schema, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []v3.SettingRevision
+	if err := convert.ToObj(existing["history"], &history); err != nil {
+		return nil, err
+	}
+	// The transformer replaces an empty value with the default.
+	oldValue := convert.ToString(existing["value"])
+	if convert.ToString(existing["source"]) == "default" {
+		oldValue = ""
+	}
+
+	user := apiContext.Request.Header.Get("Impersonate-User")
+	history = settings.AppendRevision(history, oldValue, value, user, time.Now())
+
+	result := make([]interface{}, 0, len(history))
+	for _, revision := range history {
+		m, err := convert.EncodeToMap(revision)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
 func validate(id, value string) error {
 	var k8sVersion string
 	var k8sCurrVersions []string
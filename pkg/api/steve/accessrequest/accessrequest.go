@@ -0,0 +1,288 @@
+// Package accessrequest adds the request collection action and the approve and deny actions to AccessRequests. Users
+// file requests for themselves with the request action and can only read their own requests. Only users allowed the
+// approve verb on a request can review it, users can't review their own requests, and only users who could create the
+// requested binding themselves can approve it.
+package accessrequest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers/management/accessrequest"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/steve/pkg/client"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	requestAction = "request"
+	approveAction = "approve"
+	denyAction    = "deny"
+
+	// approveVerb is the verb a user must be allowed on a request to review it.
+	approveVerb = "approve"
+	resource    = "management.cattle.io/accessrequests"
+)
+
+// ReviewInput is the input of the approve and deny actions.
+type ReviewInput struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// RequestInput is the input of the request action. The access is requested for the calling user.
+type RequestInput struct {
+	RoleTemplateName string `json:"roleTemplateName"`
+	ClusterName      string `json:"clusterName,omitempty"`
+	ProjectName      string `json:"projectName,omitempty"`
+	// Duration is how long access is granted once the request is approved, e.g. 2h.
+	Duration      string `json:"duration"`
+	Justification string `json:"justification"`
+}
+
+type handler struct {
+	accessRequests mgmtcontrollers.AccessRequestClient
+	projects       mgmtcontrollers.ProjectCache
+	events         corecontrollers.EventClient
+	clientFactory  *client.Factory
+	approve        bool
+	now            func() time.Time
+}
+
+type requestHandler struct {
+	accessRequests mgmtcontrollers.AccessRequestClient
+}
+
+func Register(server *steve.Server, clients *wrangler.Context) {
+	approve := &handler{
+		accessRequests: clients.Mgmt.AccessRequest(),
+		projects:       clients.Mgmt.Project().Cache(),
+		events:         clients.Core.Event(),
+		clientFactory:  server.ClientFactory,
+		approve:        true,
+		now:            time.Now,
+	}
+	deny := &handler{
+		accessRequests: clients.Mgmt.AccessRequest(),
+		events:         clients.Core.Event(),
+		now:            time.Now,
+	}
+
+	request := &requestHandler{
+		accessRequests: clients.Mgmt.AccessRequest(),
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(ReviewInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(RequestInput{}, nil)
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: "management.cattle.io",
+		Kind:  "AccessRequest",
+		Customize: func(schema *types.APISchema) {
+			// Users aren't granted any verb on AccessRequests, but read their own requests through the store.
+			if !slices.Contains(schema.CollectionMethods, http.MethodGet) {
+				schema.CollectionMethods = append(schema.CollectionMethods, http.MethodGet)
+			}
+			if !slices.Contains(schema.ResourceMethods, http.MethodGet) {
+				schema.ResourceMethods = append(schema.ResourceMethods, http.MethodGet)
+			}
+			if schema.ActionHandlers == nil {
+				schema.ActionHandlers = map[string]http.Handler{}
+			}
+			schema.ActionHandlers[requestAction] = request
+			schema.ActionHandlers[approveAction] = approve
+			schema.ActionHandlers[denyAction] = deny
+			if schema.CollectionActions == nil {
+				schema.CollectionActions = map[string]schemas.Action{}
+			}
+			schema.CollectionActions[requestAction] = schemas.Action{
+				Input:  "requestInput",
+				Output: "management.cattle.io.accessrequest",
+			}
+			if schema.ResourceActions == nil {
+				schema.ResourceActions = map[string]schemas.Action{}
+			}
+			schema.ResourceActions[approveAction] = schemas.Action{
+				Input: "reviewInput",
+			}
+			schema.ResourceActions[denyAction] = schemas.Action{
+				Input: "reviewInput",
+			}
+		},
+		StoreFactory: func(s types.Store) types.Store {
+			return &store{
+				Store:         s,
+				clientFactory: server.ClientFactory,
+			}
+		},
+	})
+}
+
+// ServeHTTP approves or denies the pending request. The review is recorded in the status of the request and as an
+// event.
+func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, resource, approveVerb, "", apiRequest.Name); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, "not allowed to review access request "+apiRequest.Name))
+		return
+	}
+	reviewer := apiRequest.GetUser()
+	if reviewer == "" {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Unauthorized, "unable to determine the reviewer"))
+		return
+	}
+
+	var input ReviewInput
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	request, err := h.accessRequests.Get(apiRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	if h.approve {
+		if err := h.canGrant(apiRequest, request); err != nil {
+			apiRequest.WriteError(err)
+			return
+		}
+	}
+
+	request, step, err := accessrequest.Review(request, reviewer, h.approve, input.Comment, h.now())
+	if errors.Is(err, accessrequest.ErrNotPending) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidState, err.Error()))
+		return
+	} else if errors.Is(err, accessrequest.ErrSelfReview) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, err.Error()))
+		return
+	} else if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	request, err = h.accessRequests.UpdateStatus(request)
+	if apierrors.IsConflict(err) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Conflict, "access request "+apiRequest.Name+" was modified, try again"))
+		return
+	} else if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	logrus.Infof("[access-request] AccessRequest %s for user %s: %s %s", request.Name, request.Spec.UserName, reviewer, step.Message)
+	if _, err := h.events.Create(accessrequest.NewEvent(request, step)); err != nil {
+		logrus.Errorf("[access-request] Failed to record event for AccessRequest %s: %v", request.Name, err)
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// canGrant checks that the reviewer could create the binding granting the requested access, by creating it as the
+// reviewer in dry run mode. The binding is created by the controller once the request is approved, so this applies
+// the same escalation checks as if the reviewer created it directly.
+func (h *handler) canGrant(apiRequest *types.APIRequest, request *v3.AccessRequest) error {
+	var project *v3.Project
+	if request.Spec.ProjectName != "" {
+		clusterName, projectName, _ := strings.Cut(request.Spec.ProjectName, ":")
+		var err error
+		if project, err = h.projects.Get(clusterName, projectName); apierrors.IsNotFound(err) {
+			return apierror.NewAPIError(validation.InvalidState, "project "+request.Spec.ProjectName+" does not exist")
+		} else if err != nil {
+			return err
+		}
+	}
+	binding := accessrequest.NewBinding(request, project)
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(binding)
+	if err != nil {
+		return err
+	}
+	bindingMeta, err := meta.Accessor(binding)
+	if err != nil {
+		return err
+	}
+
+	schemaID := "management.cattle.io.clusterroletemplatebinding"
+	if project != nil {
+		schemaID = "management.cattle.io.projectroletemplatebinding"
+	}
+	bindingSchema := apiRequest.Schemas.LookupSchema(schemaID)
+	if bindingSchema == nil {
+		return apierror.NewAPIError(validation.PermissionDenied, "not allowed to grant "+request.Spec.RoleTemplateName)
+	}
+	client, err := h.clientFactory.Client(apiRequest, bindingSchema, bindingMeta.GetNamespace(), nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Create(apiRequest.Context(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if apierrors.IsForbidden(err) {
+		return apierror.NewAPIError(validation.PermissionDenied, "not allowed to grant "+request.Spec.RoleTemplateName+": "+err.Error())
+	} else if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP creates an access request for the calling user.
+func (h *requestHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	user := apiRequest.GetUser()
+	if user == "" {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Unauthorized, "unable to determine the user"))
+		return
+	}
+
+	var input RequestInput
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidFormat, "invalid duration: "+err.Error()))
+		return
+	}
+
+	request, err := h.accessRequests.Create(&v3.AccessRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "ar-",
+		},
+		Spec: v3.AccessRequestSpec{
+			UserName:         user,
+			RoleTemplateName: input.RoleTemplateName,
+			ClusterName:      input.ClusterName,
+			ProjectName:      input.ProjectName,
+			Duration:         metav1.Duration{Duration: duration},
+			Justification:    input.Justification,
+		},
+	})
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(request); err != nil {
+		logrus.Errorf("[access-request] Failed to write AccessRequest %s: %v", request.Name, err)
+	}
+}
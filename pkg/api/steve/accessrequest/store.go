@@ -0,0 +1,144 @@
+package accessrequest
+
+import (
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// store lets users read their own requests. Users who are allowed to read all requests, like the approvers, read them
+// through the wrapped store, all others only get the requests filed for them, so that they can't read the requests and
+// justifications of other users.
+type store struct {
+	types.Store
+	clientFactory *client.Factory
+}
+
+func (s *store) canReadAll(apiOp *types.APIRequest, verb string) bool {
+	return apiOp.AccessControl.CanDo(apiOp, resource, verb, "", "") == nil
+}
+
+// adminClient returns a client to read the requests of the calling user with.
+func (s *store) adminClient(apiOp *types.APIRequest, schema *types.APISchema) (dynamic.ResourceInterface, string, error) {
+	user := apiOp.GetUser()
+	if user == "" {
+		return nil, "", validation.Unauthorized
+	}
+	client, err := s.clientFactory.AdminClient(apiOp, schema, "", nil)
+	return client, user, err
+}
+
+func (s *store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if s.canReadAll(apiOp, "get") {
+		return s.Store.ByID(apiOp, schema, id)
+	}
+	client, user, err := s.adminClient(apiOp, schema)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	obj, err := client.Get(apiOp.Context(), id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !filedFor(obj, user)) {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "access request "+id+" not found")
+	} else if err != nil {
+		return types.APIObject{}, err
+	}
+	return toAPI(schema, obj), nil
+}
+
+func (s *store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	if s.canReadAll(apiOp, "list") {
+		return s.Store.List(apiOp, schema)
+	}
+	client, user, err := s.adminClient(apiOp, schema)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+
+	list, err := client.List(apiOp.Context(), metav1.ListOptions{})
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	result := types.APIObjectList{
+		Revision: list.GetResourceVersion(),
+	}
+	for i := range list.Items {
+		if filedFor(&list.Items[i], user) {
+			result.Objects = append(result.Objects, toAPI(schema, &list.Items[i]))
+		}
+	}
+	return result, nil
+}
+
+func (s *store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	if s.canReadAll(apiOp, "watch") {
+		return s.Store.Watch(apiOp, schema, w)
+	}
+	user := apiOp.GetUser()
+	if user == "" {
+		return nil, validation.Unauthorized
+	}
+	client, err := s.clientFactory.AdminClientForWatch(apiOp, schema, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := client.Watch(apiOp.Context(), metav1.ListOptions{Watch: true, ResourceVersion: w.Revision})
+	if err != nil {
+		return nil, err
+	}
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		defer watcher.Stop()
+		for event := range watcher.ResultChan() {
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok || !filedFor(obj, user) {
+				continue
+			}
+			apiEvent := types.APIEvent{
+				Name:         eventName(event.Type),
+				ResourceType: schema.ID,
+				Object:       toAPI(schema, obj),
+				Revision:     obj.GetResourceVersion(),
+			}
+			select {
+			case result <- apiEvent:
+			case <-apiOp.Context().Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// filedFor returns whether the request was filed for the user.
+func filedFor(obj *unstructured.Unstructured, user string) bool {
+	userName, _, _ := unstructured.NestedString(obj.Object, "spec", "userName")
+	return userName == user
+}
+
+func toAPI(schema *types.APISchema, obj *unstructured.Unstructured) types.APIObject {
+	return types.APIObject{
+		Type:   schema.ID,
+		ID:     obj.GetName(),
+		Object: obj,
+	}
+}
+
+func eventName(eventType watch.EventType) string {
+	switch eventType {
+	case watch.Added:
+		return types.CreateAPIEvent
+	case watch.Deleted:
+		return types.RemoveAPIEvent
+	default:
+		return types.ChangeAPIEvent
+	}
+}
@@ -11,6 +11,7 @@
 	"github.com/gorilla/mux"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/remotedialer"
 	"github.com/rancher/steve/pkg/proxy"
 	"github.com/rancher/wrangler/v3/pkg/relatedresource"
@@ -90,7 +91,7 @@ func (h *aggregationHandler) makeHandler(uuid string) http.Handler {
 		Host:      "http://" + key,
 		UserAgent: rest.DefaultKubernetesUserAgent() + " " + key,
 		Transport: &http.Transport{
-			DialContext: h.remote.Dialer(key),
+			DialContext: tunnelserver.Dialer(h.remote, key),
 		},
 	}
 
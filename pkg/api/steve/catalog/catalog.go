@@ -63,6 +63,7 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstallAction{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartInstall{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(types2.ChartActionOutput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(types2.ChartDiffOutput{}, nil)
 
 	operationTemplate := schema2.Template{
 		Group: catalog.GroupName,
@@ -70,11 +71,16 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 		Customize: func(apiSchema *types.APISchema) {
 			apiSchema.LinkHandlers = map[string]http.Handler{
 				"logs": ops,
+				"diff": ops,
 			}
 			apiSchema.Formatter = func(request *types.APIRequest, resource *types.RawResource) {
-				if !resource.APIObject.Data().Bool("status", "podCreated") {
+				// the logs of dry runs hold the releases compared by the diff link
+				if !resource.APIObject.Data().Bool("status", "podCreated") || resource.APIObject.Data().String("status", "action") == "dry-run" {
 					delete(resource.Links, "logs")
 				}
+				if !resource.APIObject.Data().Bool("status", "podCreated") || resource.APIObject.Data().String("status", "action") != "dry-run" {
+					delete(resource.Links, "diff")
+				}
 			}
 		},
 	}
@@ -100,6 +106,7 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 			apiSchema.ActionHandlers = map[string]http.Handler{
 				"install": ops,
 				"upgrade": ops,
+				"dryRun":  ops,
 			}
 			apiSchema.ResourceActions = map[string]schemas3.Action{
 				"install": {
@@ -110,6 +117,10 @@ func addSchemas(server *steve.Server, ops *operation, index http.Handler) {
 					Input:  "chartUpgradeAction",
 					Output: "chartActionOutput",
 				},
+				"dryRun": {
+					Input:  "chartUpgradeAction",
+					Output: "chartActionOutput",
+				},
 			}
 			// Customize the handler for retrieving a Repo resource by its ID.
 			apiSchema.ByIDHandler = func(request *types.APIRequest) (types.APIObject, error) {
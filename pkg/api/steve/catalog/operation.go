@@ -47,7 +47,8 @@ func newOperation(
 // For example, if the api request is for installing a chart, then it will call the
 // install function of the Operation struct.
 //
-// All chart actions (install, upgrade, and uninstall) are served through this method.
+// All chart actions (install, upgrade, dry run, and uninstall) are served through this method,
+// along with the logs and diff links of operations.
 func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	// Get the APIContext from the current request's context. This APIContext
 	// encapsulates the details of the API request, which will be used to
@@ -61,8 +62,9 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	var (
-		op  *catalog.Operation
-		err error
+		op   *catalog.Operation
+		diff *catalogtypes.ChartDiffOutput
+		err  error
 	)
 
 	ns, name := nsAndName(apiRequest)
@@ -73,12 +75,16 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		op, err = o.ops.Upgrade(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
 	case "uninstall":
 		op, err = o.ops.Uninstall(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
+	case "dryRun":
+		op, err = o.ops.DryRun(apiRequest.Context(), user, ns, name, req.Body, o.imageOverride)
 	}
 
 	switch apiRequest.Link {
 	case "logs":
 		err = o.ops.Log(apiRequest.Response, apiRequest.Request,
 			apiRequest.Namespace, apiRequest.Name)
+	case "diff":
+		diff, err = o.ops.Diff(apiRequest.Context(), apiRequest.Namespace, apiRequest.Name)
 	}
 
 	if err != nil {
@@ -86,6 +92,14 @@ func (o *operation) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if diff != nil {
+		apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+			Type:   "chartDiffOutput",
+			Object: diff,
+		})
+		return
+	}
+
 	if op == nil {
 		return
 	}
@@ -13,6 +13,7 @@
   - ChartUpgradeAction: Describes the configuration for an upgrade action.
   - ChartUpgrade: Represents a Helm chart upgrade request.
   - ChartActionOutput: Represents the output after performing a Helm chart action.
+  - ChartDiffOutput: Represents the changes a dry run of an upgrade would make to the deployed releases.
 
 Each type includes fields that map directly to properties of Helm chart operations,
 allowing for a structured approach to managing Helm charts through the API.
@@ -98,3 +99,37 @@ type ChartActionOutput struct {
 	OperationName      string `json:"operationName,omitempty"`
 	OperationNamespace string `json:"operationNamespace,omitempty"`
 }
+
+// ChartDiffOutput represents the changes that the upgrade previewed by a dry run operation
+// would make to the manifests of the deployed releases
+type ChartDiffOutput struct {
+	Releases []ReleaseDiff `json:"releases,omitempty"`
+}
+
+// ReleaseDiff represents the objects of the manifest of a release that would be added, removed or changed
+type ReleaseDiff struct {
+	ReleaseName string       `json:"releaseName,omitempty"`
+	Namespace   string       `json:"namespace,omitempty"`
+	FromVersion string       `json:"fromVersion,omitempty"`
+	ToVersion   string       `json:"toVersion,omitempty"`
+	Added       []ObjectDiff `json:"added,omitempty"`
+	Removed     []ObjectDiff `json:"removed,omitempty"`
+	Changed     []ObjectDiff `json:"changed,omitempty"`
+}
+
+// ObjectDiff identifies an object of a release manifest, along with the fields that changed if it was changed
+type ObjectDiff struct {
+	APIVersion string        `json:"apiVersion,omitempty"`
+	Kind       string        `json:"kind,omitempty"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange represents a field of an object whose value changed. The values are JSON encoded and
+// are empty if the field is absent. The values of the data of secrets are redacted.
+type FieldChange struct {
+	Path string `json:"path,omitempty"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
@@ -6,9 +6,11 @@
 	"net/url"
 	"strings"
 
+	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/rancher/pkg/auth/requests"
 	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/kubeconfig"
 	"github.com/rancher/rancher/pkg/settings"
@@ -43,6 +45,10 @@ func (k kubeconfigDownload) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 	var err error
 	generateToken := strings.EqualFold(settings.KubeconfigGenerateToken.Get(), "true")
 	if generateToken {
+		if err := scope.CheckIssuance(req.Context()); err != nil {
+			apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, err.Error()))
+			return
+		}
 		tokenKey, err = k.ensureToken(userName.GetName(), req)
 		if err != nil {
 			apiRequest.WriteError(err)
@@ -0,0 +1,118 @@
+// Package oidcclient adds the authorizeServicePrincipal action to OIDCClients. A client only acts as its service
+// principal once it was authorized by the service principal itself, or by a user allowed to impersonate it, so users
+// who can manage OIDC clients can't mint tokens for more privileged users.
+package oidcclient
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/wrangler"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	authorizeAction = "authorizeServicePrincipal"
+
+	resource = "management.cattle.io/oidcclients"
+	// usersResource is the resource of the Kubernetes impersonation of users, in the core group.
+	usersResource = "/users"
+)
+
+type handler struct {
+	oidcClients mgmtcontrollers.OIDCClientClient
+}
+
+func Register(server *steve.Server, clients *wrangler.Context) {
+	h := &handler{
+		oidcClients: clients.Mgmt.OIDCClient(),
+	}
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: "management.cattle.io",
+		Kind:  "OIDCClient",
+		Customize: func(schema *types.APISchema) {
+			if schema.ActionHandlers == nil {
+				schema.ActionHandlers = map[string]http.Handler{}
+			}
+			schema.ActionHandlers[authorizeAction] = h
+			if schema.ResourceActions == nil {
+				schema.ResourceActions = map[string]schemas.Action{}
+			}
+			schema.ResourceActions[authorizeAction] = schemas.Action{}
+		},
+	})
+}
+
+// ServeHTTP authorizes the client to act as the service principal of its spec. The caller must be allowed to update
+// the client, and be the service principal or be allowed to impersonate it.
+func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, resource, "update", "", apiRequest.Name); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, "not allowed to update OIDC client "+apiRequest.Name))
+		return
+	}
+	caller := apiRequest.GetUser()
+	if caller == "" {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Unauthorized, "unable to determine the user"))
+		return
+	}
+
+	client, err := h.oidcClients.Get(apiRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	principal := client.Spec.ServicePrincipal
+	if principal == "" {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidState, "OIDC client "+apiRequest.Name+" has no service principal"))
+		return
+	}
+	if err := canActAs(apiRequest, caller, principal); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": client.ResourceVersion,
+		},
+		"status": map[string]any{
+			"servicePrincipal": principal,
+		},
+	})
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	if _, err := h.oidcClients.Patch(client.Name, k8stypes.MergePatchType, patch, "status"); apierrors.IsConflict(err) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Conflict, "OIDC client "+apiRequest.Name+" was modified, try again"))
+		return
+	} else if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	logrus.Infof("[oidc-client] User %s authorized OIDC client %s to act as %s", caller, client.Name, principal)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// canActAs returns an error unless caller is principal or is allowed to impersonate it.
+func canActAs(apiRequest *types.APIRequest, caller, principal string) error {
+	if caller == principal {
+		return nil
+	}
+	if err := apiRequest.AccessControl.CanDo(apiRequest, usersResource, "impersonate", "", principal); err != nil {
+		return apierror.NewAPIError(validation.PermissionDenied, "not allowed to act as "+principal)
+	}
+	return nil
+}
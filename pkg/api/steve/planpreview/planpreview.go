@@ -0,0 +1,102 @@
+// Package planpreview adds the previewPlan action to RKEControlPlanes, which shows how the plans of the machines of a
+// cluster would change for a candidate spec before the spec is saved.
+package planpreview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	caprcontrollers "github.com/rancher/rancher/pkg/controllers/capr"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+)
+
+const (
+	previewPlanAction = "previewPlan"
+
+	resource = "rke.cattle.io/rkecontrolplanes"
+)
+
+// PlanPreviewOutput is the output of the previewPlan action.
+type PlanPreviewOutput struct {
+	Machines []planner.MachinePlanPreview `json:"machines"`
+}
+
+type previewer interface {
+	Preview(cp *rkev1.RKEControlPlane, spec rkev1.RKEControlPlaneSpec) ([]planner.MachinePlanPreview, error)
+}
+
+type handler struct {
+	controlPlanes rkecontrollers.RKEControlPlaneCache
+	planner       previewer
+}
+
+func Register(ctx context.Context, server *steve.Server, clients *wrangler.Context) {
+	h := &handler{
+		controlPlanes: clients.RKE.RKEControlPlane().Cache(),
+		planner:       caprcontrollers.NewPlanner(ctx, clients),
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(PlanPreviewOutput{}, nil)
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: "rke.cattle.io",
+		Kind:  "RKEControlPlane",
+		Customize: func(schema *types.APISchema) {
+			if schema.ActionHandlers == nil {
+				schema.ActionHandlers = map[string]http.Handler{}
+			}
+			schema.ActionHandlers[previewPlanAction] = h
+			if schema.ResourceActions == nil {
+				schema.ResourceActions = map[string]schemas.Action{}
+			}
+			schema.ResourceActions[previewPlanAction] = schemas.Action{
+				Output: "planPreviewOutput",
+			}
+		},
+	})
+}
+
+// ServeHTTP previews the plans for the RKEControlPlane spec in the request body. Fields missing from the body keep
+// their current value, so an empty body previews the current spec. Since the preview shows what saving the spec would
+// do, it is only available to users who can update the control plane.
+func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, resource, "update", apiRequest.Namespace, apiRequest.Name); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, "not allowed to update RKEControlPlane "+apiRequest.Namespace+"/"+apiRequest.Name))
+		return
+	}
+
+	cp, err := h.controlPlanes.Get(apiRequest.Namespace, apiRequest.Name)
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	spec := cp.Spec
+	if err := json.NewDecoder(req.Body).Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	machines, err := h.planner.Preview(cp, spec)
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	apiRequest.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "planPreviewOutput",
+		Object: &PlanPreviewOutput{Machines: machines},
+	})
+}
@@ -0,0 +1,104 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const resource = "management.cattle.io/settings"
+
+var (
+	errFromEnv          = errors.New("value is from environment variable")
+	errRevisionNotFound = errors.New("revision not found")
+)
+
+// RollbackInput is the input of the rollback action.
+type RollbackInput struct {
+	// Revision is the recorded change to undo. The setting gets the value it had before that change.
+	Revision int64 `json:"revision"`
+}
+
+type rollbackHandler struct {
+	settings mgmtcontrollers.SettingClient
+	now      func() time.Time
+}
+
+// ServeHTTP restores the value a setting had before the requested revision. The rollback is itself recorded as a new
+// revision.
+func (h *rollbackHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, resource, "update", "", apiRequest.Name); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.PermissionDenied, "not allowed to update setting "+apiRequest.Name))
+		return
+	}
+
+	var input RollbackInput
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	setting, err := h.settings.Get(apiRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	setting, err = rollback(setting, input.Revision, apiRequest.GetUser(), h.now())
+	if errors.Is(err, errFromEnv) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.MethodNotAllowed, err.Error()))
+		return
+	} else if errors.Is(err, errRevisionNotFound) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.NotFound, err.Error()))
+		return
+	} else if err != nil {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	if _, err := h.settings.Update(setting); apierrors.IsConflict(err) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.Conflict, "setting "+apiRequest.Name+" was modified, try again"))
+		return
+	} else if err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	logrus.Infof("[settings] Setting %s rolled back to before revision %d by %s", setting.Name, input.Revision, apiRequest.GetUser())
+	rw.WriteHeader(http.StatusOK)
+}
+
+// rollback returns a copy of setting with the value it had before the given revision and the change recorded in its
+// history.
+func rollback(setting *v3.Setting, revision int64, user string, now time.Time) (*v3.Setting, error) {
+	if _, ok := os.LookupEnv(settings.GetEnvKey(setting.Name)); ok {
+		return nil, fmt.Errorf("setting %s can not be rolled back: %w", setting.Name, errFromEnv)
+	}
+
+	r, ok := settings.FindRevision(setting.History, revision)
+	if !ok {
+		return nil, fmt.Errorf("setting %s: %w: %d", setting.Name, errRevisionNotFound, revision)
+	}
+	if err := settings.Validate(setting.Name, r.OldValue); err != nil {
+		return nil, err
+	}
+
+	setting = setting.DeepCopy()
+	setting.History = settings.AppendRevision(setting.History, setting.Value, r.OldValue, user, now)
+	setting.Value = r.OldValue
+	return setting, nil
+}
@@ -0,0 +1,83 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRollback(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	settings.NewSetting("test-rollback", "5").WithRange(1, 10)
+
+	setting := &v3.Setting{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-rollback"},
+		Value:      "8",
+		Default:    "5",
+		History: []v3.SettingRevision{
+			{Revision: 1, OldValue: "", NewValue: "3", User: "u-a", Time: earlier},
+			{Revision: 2, OldValue: "3", NewValue: "8", User: "u-b", Time: earlier},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		revision  int64
+		env       string
+		wantValue string
+		wantErr   error
+	}{
+		{
+			name:      "rollback the last change",
+			revision:  2,
+			wantValue: "3",
+		},
+		{
+			name:      "rollback to the default",
+			revision:  1,
+			wantValue: "",
+		},
+		{
+			name:     "unknown revision",
+			revision: 7,
+			wantErr:  errRevisionNotFound,
+		},
+		{
+			name:     "value from environment variable",
+			revision: 2,
+			env:      "4",
+			wantErr:  errFromEnv,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv(settings.GetEnvKey(setting.Name), tt.env)
+			}
+
+			got, err := rollback(setting, tt.revision, "u-c", now)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantValue, got.Value)
+			require.Len(t, got.History, 3)
+			assert.Equal(t, v3.SettingRevision{
+				Revision: 3,
+				OldValue: "8",
+				NewValue: tt.wantValue,
+				User:     "u-c",
+				Time:     metav1.NewTime(now),
+			}, got.History[2])
+			assert.Equal(t, "8", setting.Value, "the original setting must not be modified")
+		})
+	}
+}
@@ -1,20 +1,54 @@
+// Package settings customizes the Setting schema. Updates are validated against the declared type of the setting and
+// recorded in its history, and the rollback action restores the value from before a recorded change.
 package settings
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/wrangler"
 	schema2 "github.com/rancher/steve/pkg/schema"
 	steve "github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
 )
 
-func Register(server *steve.Server) {
+const rollbackAction = "rollback"
+
+func Register(server *steve.Server, clients *wrangler.Context) {
+	rollback := &rollbackHandler{
+		settings: clients.Mgmt.Setting(),
+		now:      time.Now,
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(RollbackInput{}, nil)
 	server.SchemaFactory.AddTemplate(schema2.Template{
 		Group: "management.cattle.io",
 		Kind:  "Setting",
+		Customize: func(schema *types.APISchema) {
+			if schema.ActionHandlers == nil {
+				schema.ActionHandlers = map[string]http.Handler{}
+			}
+			schema.ActionHandlers[rollbackAction] = rollback
+			if schema.ResourceActions == nil {
+				schema.ResourceActions = map[string]schemas.Action{}
+			}
+			schema.ResourceActions[rollbackAction] = schemas.Action{
+				Input: "rollbackInput",
+			}
+		},
 		Formatter: func(request *types.APIRequest, resource *types.RawResource) {
 			data := resource.APIObject.Data()
 			if data.String("value") == "" {
 				data.Set("value", data.String("default"))
 			}
 		},
+		StoreFactory: func(s types.Store) types.Store {
+			return &store{
+				Store:    s,
+				settings: clients.Mgmt.Setting(),
+				now:      time.Now,
+			}
+		},
 	})
 }
@@ -0,0 +1,73 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// store validates the value of updated settings and records the change in their history.
+type store struct {
+	types.Store
+	settings mgmtcontrollers.SettingClient
+	now      func() time.Time
+}
+
+// Update validates the new value of the setting and records the change in its history. The history sent by the client
+// is discarded and rebuilt from the stored setting, so that it can't be rewritten. Unless the client sent a resource
+// version, the setting is updated at the version the history was read from, so that concurrent updates conflict instead
+// of dropping revisions.
+func (s *store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	obj := data.Data()
+
+	existing, err := s.settings.Get(id, metav1.GetOptions{})
+	if err != nil {
+		return types.APIObject{}, err
+	}
+
+	history := existing.History
+	if _, ok := obj["value"]; ok {
+		value := obj.String("value")
+
+		if _, ok := os.LookupEnv(settings.GetEnvKey(id)); ok {
+			return types.APIObject{}, apierror.NewAPIError(validation.MethodNotAllowed, fmt.Sprintf("%s is readOnly because its value is from environment variable", id))
+		}
+		if err := settings.Validate(id, value); err != nil {
+			return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+		}
+
+		history = settings.AppendRevision(history, existing.Value, value, apiOp.GetUser(), s.now())
+	}
+
+	encoded, err := encodeHistory(history)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	obj.Set("history", encoded)
+	if obj.String("metadata", "resourceVersion") == "" {
+		obj.SetNested(existing.ResourceVersion, "metadata", "resourceVersion")
+	}
+
+	return s.Store.Update(apiOp, schema, data, id)
+}
+
+func encodeHistory(history []v3.SettingRevision) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(history))
+	for _, revision := range history {
+		m, err := convert.EncodeToMap(revision)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
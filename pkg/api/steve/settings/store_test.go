@@ -0,0 +1,118 @@
+package settings
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type updateStore struct {
+	types.Store
+	updated data.Object
+}
+
+func (s *updateStore) Update(_ *types.APIRequest, _ *types.APISchema, obj types.APIObject, _ string) (types.APIObject, error) {
+	s.updated = obj.Data()
+	return obj, nil
+}
+
+func TestStoreUpdate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	settings.NewSetting("test-store-update", "5").WithRange(1, 10)
+
+	existing := &v3.Setting{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-store-update", ResourceVersion: "42"},
+		Value:      "3",
+		Default:    "5",
+		History: []v3.SettingRevision{
+			{Revision: 1, OldValue: "", NewValue: "3", User: "u-a", Time: earlier},
+		},
+	}
+	forged := []interface{}{
+		map[string]interface{}{"revision": int64(1), "oldValue": "", "newValue": "1", "user": "u-forged"},
+	}
+
+	tests := []struct {
+		name        string
+		data        data.Object
+		wantErr     bool
+		wantHistory []v3.SettingRevision
+		wantVersion string
+	}{
+		{
+			name: "value change is recorded",
+			data: data.Object{"value": "8", "history": forged},
+			wantHistory: []v3.SettingRevision{
+				existing.History[0],
+				{Revision: 2, OldValue: "3", NewValue: "8", User: "u-b", Time: metav1.NewTime(now)},
+			},
+			wantVersion: "42",
+		},
+		{
+			name:        "history can't be rewritten without a value",
+			data:        data.Object{"history": forged},
+			wantHistory: existing.History,
+			wantVersion: "42",
+		},
+		{
+			name:        "resource version of the client is kept",
+			data:        data.Object{"value": "3", "metadata": map[string]interface{}{"resourceVersion": "41"}},
+			wantHistory: existing.History,
+			wantVersion: "41",
+		},
+		{
+			name:    "invalid value",
+			data:    data.Object{"value": "11"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := fake.NewMockNonNamespacedClientInterface[*v3.Setting, *v3.SettingList](ctrl)
+			client.EXPECT().Get("test-store-update", metav1.GetOptions{}).Return(existing.DeepCopy(), nil)
+
+			next := &updateStore{}
+			s := &store{
+				Store:    next,
+				settings: client,
+				now:      func() time.Time { return now },
+			}
+			req := httptest.NewRequest("PUT", "/v1/management.cattle.io.settings/test-store-update", nil)
+			apiOp := &types.APIRequest{Request: req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "u-b"}))}
+
+			_, err := s.Update(apiOp, nil, types.APIObject{Object: map[string]interface{}(tt.data)}, "test-store-update")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, next.updated)
+				return
+			}
+			require.NoError(t, err)
+
+			var history []v3.SettingRevision
+			require.NoError(t, convert.ToObj(next.updated["history"], &history))
+			require.Len(t, history, len(tt.wantHistory))
+			for i, revision := range history {
+				assert.True(t, tt.wantHistory[i].Time.Equal(&revision.Time))
+				revision.Time = tt.wantHistory[i].Time
+				assert.Equal(t, tt.wantHistory[i], revision)
+			}
+			assert.Equal(t, tt.wantVersion, next.updated.String("metadata", "resourceVersion"))
+		})
+	}
+}
@@ -3,13 +3,17 @@
 import (
 	"context"
 
+	"github.com/rancher/rancher/pkg/api/steve/accessrequest"
 	"github.com/rancher/rancher/pkg/api/steve/catalog"
 	"github.com/rancher/rancher/pkg/api/steve/clusters"
 	"github.com/rancher/rancher/pkg/api/steve/disallow"
 	"github.com/rancher/rancher/pkg/api/steve/machine"
 	"github.com/rancher/rancher/pkg/api/steve/navlinks"
+	"github.com/rancher/rancher/pkg/api/steve/oidcclient"
+	"github.com/rancher/rancher/pkg/api/steve/planpreview"
 	"github.com/rancher/rancher/pkg/api/steve/settings"
 	"github.com/rancher/rancher/pkg/api/steve/userpreferences"
+	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/wrangler"
 	steve "github.com/rancher/steve/pkg/server"
 )
@@ -21,8 +25,15 @@ func Setup(ctx context.Context, server *steve.Server, config *wrangler.Context)
 	}
 	machine.Register(server, config)
 	navlinks.Register(ctx, server)
-	settings.Register(server)
+	settings.Register(server, config)
 	disallow.Register(server)
+	accessrequest.Register(server, config)
+	if features.OIDCProvider.Enabled() {
+		oidcclient.Register(server, config)
+	}
+	if features.ProvisioningV2.Enabled() && features.RKE2.Enabled() {
+		planpreview.Register(ctx, server, config)
+	}
 	return catalog.Register(ctx,
 		server,
 		config.HelmOperations,
@@ -10,14 +10,50 @@
 	AuditPolicyConditionTypeUnknown string = "Unknown"
 	AuditPolicyConditionTypeActive  string = "Active"
 
-	FilterActionUnknown FilterAction = ""
-	FilterActionAllow   FilterAction = "allow"
-	FilterActionDeny    FilterAction = "deny"
+	FilterActionUnknown   FilterAction = ""
+	FilterActionAllow     FilterAction = "allow"
+	FilterActionDeny      FilterAction = "deny"
+	FilterActionSample    FilterAction = "sample"
+	FilterActionRateLimit FilterAction = "ratelimit"
 )
 
+type RateLimitKey string
+
+const (
+	RateLimitKeyNone              RateLimitKey = ""
+	RateLimitKeyUser              RateLimitKey = "user"
+	RateLimitKeyRequestURI        RateLimitKey = "requestURI"
+	RateLimitKeyUserAndRequestURI RateLimitKey = "userAndRequestURI"
+	RateLimitKeyUserAndMethod     RateLimitKey = "userAndMethod"
+)
+
+// Sample keeps a subset of the logs matched by a Filter. Exactly one of OneIn or Percentage must be set.
+type Sample struct {
+	// OneIn keeps the first of every OneIn matching logs.
+	OneIn int `json:"oneIn,omitempty"`
+
+	// Percentage keeps roughly the given percentage (1-100) of matching logs, chosen at random.
+	Percentage int `json:"percentage,omitempty"`
+}
+
+// RateLimit keeps matching logs up to a rate, using a token bucket for each distinct Key.
+type RateLimit struct {
+	// EventsPerSecond is the number of logs kept per second for each key.
+	EventsPerSecond int `json:"eventsPerSecond"`
+
+	// Burst is the number of logs that can be kept at once before the rate applies. Defaults to EventsPerSecond.
+	Burst int `json:"burst,omitempty"`
+
+	// Key determines how logs are grouped into buckets, one of "user", "requestURI", "userAndRequestURI" or
+	// "userAndMethod". Leave empty to share a single bucket between all logs matched by the filter.
+	Key RateLimitKey `json:"key,omitempty"`
+}
+
 // Filter provides values used to filter out audit logs.
 type Filter struct {
-	// Action defines what happens
+	// Action defines what happens to logs matching RequestURI, one of "allow", "deny", "sample" or "ratelimit". The
+	// sample and ratelimit actions keep some matching logs and drop the rest, recording in each kept log how many
+	// logs were suppressed since the last one was kept.
 	Action FilterAction `json:"action,omitempty"`
 
 	// RequestURI is a regular expression used to match against the url of the log request. For example, the Filter:
@@ -29,6 +65,94 @@ type Filter struct {
 	//
 	// would allow logs sent to "/foo/some/endpoint" but not "/foo" or "/foobar".
 	RequestURI string `json:"requestURI,omitempty"`
+
+	// Sample configures the sample action.
+	Sample *Sample `json:"sample,omitempty"`
+
+	// RateLimit configures the ratelimit action.
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// SinkTLS configures how a Sink verifies the remote server it delivers logs to.
+type SinkTLS struct {
+	// Enabled toggles TLS for transports where it is optional (syslog). HTTP based sinks infer TLS from the URL scheme.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// CABundle is a PEM encoded CA bundle used to verify the remote server. If empty the system roots are used.
+	CABundle string `json:"caBundle,omitempty"`
+
+	// InsecureSkipVerify disables verification of the remote server certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// SinkDelivery controls how logs are queued, batched and retried before being sent to a Sink.
+type SinkDelivery struct {
+	// BatchSize is the maximum number of logs sent in a single request. Defaults to 100. Syslog sinks send each log as
+	// a separate message but still use BatchSize to decide how many logs are written per connection attempt.
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// FlushInterval is the maximum amount of time a log will wait in the queue before being sent. Defaults to 5s.
+	FlushInterval *metav1.Duration `json:"flushInterval,omitempty"`
+
+	// MaxRetries is the number of times a failed batch is retried before it is moved to the on-disk spool. Defaults
+	// to 3.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// QueueSize is the number of logs buffered in memory for this sink. Once the queue is full, logs are written to
+	// the on-disk spool, and once the spool is full logs are dropped for this sink and reported as failed. Defaults to 1000.
+	QueueSize int `json:"queueSize,omitempty"`
+}
+
+// SyslogSink sends logs as RFC5424 messages using octet counting framing (RFC6587) over TCP or TLS.
+type SyslogSink struct {
+	// Address is the host:port of the syslog server.
+	Address string `json:"address"`
+
+	// Facility is the syslog facility code used for each message. Defaults to 13 (log audit).
+	Facility *int `json:"facility,omitempty"`
+
+	// AppName is used as the APP-NAME of each message. Defaults to "rancher".
+	AppName string `json:"appName,omitempty"`
+
+	TLS SinkTLS `json:"tls,omitempty"`
+}
+
+// WebhookSink sends batches of logs as a JSON array in the body of a POST request.
+type WebhookSink struct {
+	// URL is the http or https endpoint logs are posted to.
+	URL string `json:"url"`
+
+	// Headers are additional headers added to each request, for example an Authorization header.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	TLS SinkTLS `json:"tls,omitempty"`
+}
+
+// OTLPSink exports logs using the OTLP/HTTP logs protocol with JSON encoding.
+type OTLPSink struct {
+	// Endpoint is the base URL of the OTLP collector, for example "https://collector:4318". The "/v1/logs" path is
+	// appended if the endpoint has no path.
+	Endpoint string `json:"endpoint"`
+
+	// Headers are additional headers added to each export request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// ResourceAttributes are added to the resource of every exported log record.
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+
+	TLS SinkTLS `json:"tls,omitempty"`
+}
+
+// Sink is an additional destination for logs allowed by a policy. Exactly one of Syslog, Webhook or OTLP must be set.
+type Sink struct {
+	// Name uniquely identifies the sink within its policy and is used to name its on-disk spool.
+	Name string `json:"name"`
+
+	Syslog  *SyslogSink  `json:"syslog,omitempty"`
+	Webhook *WebhookSink `json:"webhook,omitempty"`
+	OTLP    *OTLPSink    `json:"otlp,omitempty"`
+
+	Delivery SinkDelivery `json:"delivery,omitempty"`
 }
 
 type Redaction struct {
@@ -124,12 +248,14 @@ type AuditPolicySpec struct {
 
 	// Filters described what logs are explicitly allowed and denied. Leave empty if all logs should be allowed. The
 	// Allow action has higher precedence than Deny. So if there are multiple filters that match a log and at least one
-	// Allow, the log will be allowed.
+	// Allow, the log will be allowed. If no Allow filter matches, the first matching Sample or RateLimit filter decides
+	// whether the log is kept.
 	Filters []Filter `json:"filters,omitempty"`
 
 	// AdditionalRedactions details additional informatino to be redacted. If there are any Filers defined in the same
 	// policy, these Redactions will only be applied to logs that are Allowed by those filters. If there are no
-	// Filters, the redactions will be applied to all logs.
+	// Filters, the redactions will be applied to all logs. The logs allowed by policies that only define redactions,
+	// without a Verbosity, Sinks or filters other than Allow, are still dropped by Sample and RateLimit filters.
 	AdditionalRedactions []Redaction `json:"additionalRedactions,omitempty"`
 
 	// Verbosity defines how much data to collect from each log. The end verbosity for a log is calculated as a merge
@@ -163,6 +289,10 @@ type AuditPolicySpec struct {
 	// A request to the "/foo" endpoint will log both the request and response bodies, but a request to "/bar" will
 	// only log the request body.
 	Verbosity LogVerbosity `json:"verbosity,omitempty"`
+
+	// Sinks are additional destinations for logs allowed by this policy. Logs are always written to the local audit
+	// log, and are additionally sent to each sink of every policy that allows them.
+	Sinks []Sink `json:"sinks,omitempty"`
 }
 
 type AuditPolicyStatus struct {
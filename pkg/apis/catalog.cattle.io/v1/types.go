@@ -110,6 +110,55 @@ type RepoSpec struct {
 	// Defaults to false, which keeps the SameOrigin check enabled. Setting this to true is not recommended
 	// in production environments due to the security implications.
 	DisableSameOriginCheck bool `json:"disableSameOriginCheck,omitempty"`
+
+	// Verification is the policy used to verify the charts of the Helm repository before they are
+	// installed or upgraded. If unspecified, charts are not verified.
+	Verification *VerificationPolicy `json:"verification,omitempty"`
+}
+
+// VerificationMode defines what happens when a chart fails verification.
+type VerificationMode string
+
+const (
+	// VerificationModeEnforce rejects installs and upgrades of charts that fail verification.
+	VerificationModeEnforce VerificationMode = "Enforce"
+	// VerificationModeWarn only reports charts that fail verification.
+	VerificationModeWarn VerificationMode = "Warn"
+)
+
+// VerificationPolicy defines how the charts of a Helm repository are verified.
+// Provenance verification applies to HTTP Helm repositories and cosign verification
+// to OCI Helm repositories. Charts of Git Helm repositories can't be verified.
+type VerificationPolicy struct {
+	// Mode is either "Enforce" or "Warn". Defaults to "Enforce".
+	// +kubebuilder:validation:Enum=Enforce;Warn
+	// +optional
+	Mode VerificationMode `json:"mode,omitempty"`
+
+	// Provenance verifies the Helm provenance (.prov) file published next to each chart archive.
+	Provenance *ProvenanceVerification `json:"provenance,omitempty"`
+
+	// Cosign verifies the cosign signatures of the OCI artifact of each chart.
+	Cosign *CosignVerification `json:"cosign,omitempty"`
+}
+
+// ProvenanceVerification verifies charts against their Helm provenance files.
+type ProvenanceVerification struct {
+	// KeyringSecret references the secret holding the PGP keyring, binary or ASCII armored,
+	// under the "keyring" key. The namespace defaults to cattle-system.
+	KeyringSecret *SecretReference `json:"keyringSecret,omitempty"`
+}
+
+// CosignVerification verifies charts against their cosign signatures.
+type CosignVerification struct {
+	// PublicKeysSecret references the secret holding the PEM encoded public keys trusted
+	// to sign the charts. Every key of the secret may hold one or more public keys.
+	// The namespace defaults to cattle-system.
+	PublicKeysSecret *SecretReference `json:"publicKeysSecret,omitempty"`
+
+	// RequireAttestation requires an in-toto attestation signed by a trusted key in addition
+	// to the signature.
+	RequireAttestation bool `json:"requireAttestation,omitempty"`
 }
 
 type RepoCondition string
@@ -118,6 +167,7 @@ type RepoSpec struct {
 	RepoDownloaded         RepoCondition = "Downloaded"
 	FollowerRepoDownloaded RepoCondition = "FollowerDownloaded"
 	OCIDownloaded          RepoCondition = "OCIDownloaded"
+	ChartsVerified         RepoCondition = "ChartsVerified"
 )
 
 // RepoStatus contains details of the Helm repository that is currently being used in the cluster.
@@ -158,6 +208,22 @@ type RepoStatus struct {
 
 	// If the handler should be skipped or not
 	ShouldNotSkip bool `json:"shouldNotSkip,omitempty"`
+
+	// Verification summarizes the verification of the chart versions in the index.
+	// Only set if the repository has a verification policy.
+	Verification *VerificationStatus `json:"verification,omitempty"`
+}
+
+// VerificationStatus summarizes the verification of the chart versions of a Helm repository.
+type VerificationStatus struct {
+	// Verified is the number of chart versions that passed verification.
+	Verified int `json:"verified"`
+
+	// Failed is the number of chart versions that failed verification.
+	Failed int `json:"failed"`
+
+	// Message describes the first failures, if any.
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
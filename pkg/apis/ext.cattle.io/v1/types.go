@@ -3,6 +3,7 @@
 
 import (
 	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -80,6 +81,57 @@ type TokenSpec struct {
 	// enabled token.
 	// +optional
 	Enabled *bool `json:"enabled,omitempty"`
+	// Scope restricts what the token can be used for. A token without a
+	// scope carries the full permissions of its user. The scope can't be
+	// changed after the token is created.
+	// +optional
+	Scope *TokenScope `json:"scope,omitempty"`
+}
+
+// TokenScope restricts the requests a token can authenticate. The
+// restrictions only ever narrow the permissions of the token's user, they
+// never grant anything the user is not allowed to do.
+type TokenScope struct {
+	// Clusters is the list of IDs of the clusters the token can be used
+	// for. Requests that are not routed to a downstream cluster, including
+	// requests to the Rancher API, are considered requests to the "local"
+	// cluster. An empty list allows all clusters.
+	// +listType=set
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Rules is the list of Kubernetes API requests the token can be used
+	// for. When set, the token can only be used for Kubernetes API requests
+	// matching at least one rule, and for API discovery. An empty list
+	// allows all requests.
+	// +optional
+	Rules []TokenScopeRule `json:"rules,omitempty"`
+	// SourceCIDRs is the list of CIDRs the token can be used from. The
+	// source address of a request is the remote address of the
+	// connection, or for connections from the proxies of the
+	// trusted-proxy-cidrs setting, the last address of the
+	// X-Forwarded-For header that isn't one of these proxies. An empty
+	// list allows all source addresses.
+	// +listType=set
+	// +optional
+	SourceCIDRs []string `json:"sourceCIDRs,omitempty"`
+}
+
+// TokenScopeRule describes a set of Kubernetes API requests a scoped token
+// can be used for.
+type TokenScopeRule struct {
+	// APIGroups is the list of API groups of the resources. The empty
+	// string is the core API group and "*" matches all API groups.
+	// +listType=set
+	APIGroups []string `json:"apiGroups"`
+	// Resources is the list of resources. Subresources are written as
+	// "resource/subresource", e.g. "pods/log". "*" matches all resources
+	// and subresources.
+	// +listType=set
+	Resources []string `json:"resources"`
+	// Verbs is the list of verbs, e.g. "get", "list" or "watch". "*"
+	// matches all verbs.
+	// +listType=set
+	Verbs []string `json:"verbs"`
 }
 
 // TokenPrincipal contains the data about the user principal owning the token.
@@ -128,6 +180,13 @@ type TokenStatus struct {
 	// LastActivitySeen is the timestamp of the last time user activity
 	// (mouse movement, interaction, ...) was reported for the token.
 	LastActivitySeen *metav1.Time `json:"lastActivitySeen,omitempty"`
+	// Conditions report anomalies detected in the usage of the token.
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
 }
 
 // Implement the TokenAccessor interface
@@ -194,6 +253,45 @@ func (t *Token) GetCreationTime() metav1.Time {
 	return t.CreationTimestamp
 }
 
+// IsScoped returns true if the token is restricted by a scope.
+func (t *Token) IsScoped() bool {
+	return t.Spec.Scope != nil
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TokenUsage is the recent usage history of a Token. It is served as the usage subresource of the Token.
+type TokenUsage struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Records are the recent uses of the token, ordered by the time they were first seen, most recent first.
+	// Only a bounded number of records is kept.
+	Records []TokenUsageRecord `json:"records"`
+}
+
+// TokenUsageRecord aggregates uses of a token by the same client.
+// Uses after a period of inactivity start a new record.
+type TokenUsageRecord struct {
+	// FirstSeen is the timestamp of the first use in the record.
+	FirstSeen metav1.Time `json:"firstSeen"`
+	// LastSeen is the timestamp of the last use in the record.
+	LastSeen metav1.Time `json:"lastSeen"`
+	// Count is the number of uses in the record.
+	Count int64 `json:"count"`
+	// SourceIP is the address the token was used from.
+	// +optional
+	SourceIP string `json:"sourceIP,omitempty"`
+	// UserAgent is the user agent of the client that used the token.
+	// +optional
+	UserAgent string `json:"userAgent,omitempty"`
+	// ClusterID is the cluster the requests were routed to, or "local" for requests to Rancher itself.
+	// +optional
+	ClusterID string `json:"clusterID,omitempty"`
+}
+
 // +genclient
 // +genclient:nonNamespaced
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -249,3 +347,116 @@ type KubeconfigStatus struct {
 	// Value contains the generated content of the kubeconfig.
 	Value string `json:"value,omitempty"`
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PermissionReview explains the effective permissions of a user or group principal.
+// Creating a PermissionReview evaluates whether the subject is allowed a verb on a resource in a cluster, project
+// or namespace and returns the bindings, global roles and role templates that grant it, as well as those that would
+// grant it if they were bound. PermissionReviews are not stored.
+type PermissionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Spec is the subject and the request to review.
+	Spec PermissionReviewSpec `json:"spec"`
+	// Status is the result of the review.
+	// +optional
+	Status PermissionReviewStatus `json:"status,omitempty"`
+}
+
+// PermissionReviewSpec is the subject and the request to review.
+type PermissionReviewSpec struct {
+	// UserName is the name of the user to review. At most one of UserName and GroupPrincipalName can be set.
+	// Defaults to the user creating the review. Reviewing other users requires being allowed to list global role bindings.
+	// +optional
+	UserName string `json:"userName,omitempty"`
+	// GroupPrincipalName is the name of the group principal to review, e.g. "activedirectory_group://CN=devs,DC=example,DC=com".
+	// +optional
+	GroupPrincipalName string `json:"groupPrincipalName,omitempty"`
+	// ClusterName is the name of the cluster the request is made in. Defaults to "local".
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+	// ProjectName is the name of the project containing the namespace of the request, in the format
+	// "clusterName:projectName". Defaults to the project of the namespace, if any. Project role template
+	// bindings are only evaluated if both ProjectName and Namespace are set.
+	// +optional
+	ProjectName string `json:"projectName,omitempty"`
+	// Namespace is the namespace of the request. Leave empty for cluster-scoped resources and requests across all namespaces.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// APIGroup is the API group of the resource. Empty for the core API group.
+	// +optional
+	APIGroup string `json:"apiGroup,omitempty"`
+	// Resource is the resource of the request, e.g. "pods".
+	Resource string `json:"resource"`
+	// Subresource is the subresource of the request, e.g. "log".
+	// +optional
+	Subresource string `json:"subresource,omitempty"`
+	// ResourceName is the name of the resource of the request. Leave empty for requests on all resources, e.g. list.
+	// +optional
+	ResourceName string `json:"resourceName,omitempty"`
+	// Verb is the verb of the request, e.g. "get".
+	Verb string `json:"verb"`
+}
+
+// PermissionReviewStatus is the result of a PermissionReview.
+type PermissionReviewStatus struct {
+	// Allowed is true if the subject is allowed the request.
+	Allowed bool `json:"allowed"`
+	// Grants are the bindings allowing the subject the request. Each grant is reported with the first matching rule.
+	// +optional
+	Grants []PermissionGrant `json:"grants,omitempty"`
+	// Candidates are the global roles and role templates not bound to the subject that would allow the request.
+	// Their binding fields are empty.
+	// +optional
+	Candidates []PermissionGrant `json:"candidates,omitempty"`
+}
+
+// Sources of the rules of a PermissionGrant.
+const (
+	// PermissionGrantSourceRules is a rule of the Rules of a GlobalRole or RoleTemplate.
+	PermissionGrantSourceRules = "Rules"
+	// PermissionGrantSourceNamespacedRules is a rule of the NamespacedRules of a GlobalRole for the requested namespace.
+	PermissionGrantSourceNamespacedRules = "NamespacedRules"
+	// PermissionGrantSourceInheritedClusterRoles is a rule of a RoleTemplate in the InheritedClusterRoles of a GlobalRole.
+	PermissionGrantSourceInheritedClusterRoles = "InheritedClusterRoles"
+	// PermissionGrantSourceExternalRules is a rule of the ExternalRules of an external RoleTemplate.
+	PermissionGrantSourceExternalRules = "ExternalRules"
+	// PermissionGrantSourceClusterRole is a rule of the ClusterRole backing an external RoleTemplate.
+	PermissionGrantSourceClusterRole = "ClusterRole"
+	// PermissionGrantSourceClusterAdmin is the cluster-admin ClusterRole bound in every downstream cluster for admin GlobalRoles.
+	PermissionGrantSourceClusterAdmin = "ClusterAdmin"
+)
+
+// PermissionGrant is a chain of a binding, global role and role templates ending in a rule matching the request.
+type PermissionGrant struct {
+	// BindingKind is the kind of the binding, one of GlobalRoleBinding, ClusterRoleTemplateBinding and ProjectRoleTemplateBinding.
+	// For candidates it is the kind of binding that would be needed.
+	BindingKind string `json:"bindingKind"`
+	// BindingNamespace is the namespace of the binding.
+	// +optional
+	BindingNamespace string `json:"bindingNamespace,omitempty"`
+	// BindingName is the name of the binding.
+	// +optional
+	BindingName string `json:"bindingName,omitempty"`
+	// Subject is the user or principal the binding is for, which is either the reviewed subject or one of its principals or groups.
+	// +optional
+	Subject string `json:"subject,omitempty"`
+	// GlobalRoleName is the name of the bound GlobalRole.
+	// +optional
+	GlobalRoleName string `json:"globalRoleName,omitempty"`
+	// RoleTemplateNames is the chain of role templates leading to the rule, starting with the bound or inherited role
+	// template and followed by the role templates it inherits.
+	// +optional
+	RoleTemplateNames []string `json:"roleTemplateNames,omitempty"`
+	// Locked is true if a role template in the chain is locked, which prevents creating new bindings to it.
+	// +optional
+	Locked bool `json:"locked,omitempty"`
+	// Source is the field the rule comes from, one of Rules, NamespacedRules, InheritedClusterRoles, ExternalRules,
+	// ClusterRole and ClusterAdmin.
+	Source string `json:"source"`
+	// Rule is the rule matching the request.
+	Rule rbacv1.PolicyRule `json:"rule"`
+}
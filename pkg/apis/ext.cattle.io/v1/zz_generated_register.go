@@ -57,8 +57,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Kubeconfig{},
 		&KubeconfigList{},
+		&PermissionReview{},
 		&Token{},
 		&TokenList{},
+		&TokenUsage{},
 		&UserActivity{},
 		&UserActivityList{},
 	)
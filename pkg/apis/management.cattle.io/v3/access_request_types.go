@@ -0,0 +1,160 @@
+package v3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessRequestPhase is the phase of an access request.
+type AccessRequestPhase string
+
+const (
+	// AccessRequestPending is the phase of a request waiting for a review.
+	AccessRequestPending AccessRequestPhase = "Pending"
+	// AccessRequestApproved is the phase of an approved request whose binding has not been created yet.
+	AccessRequestApproved AccessRequestPhase = "Approved"
+	// AccessRequestActive is the phase of a request whose binding grants the requested access.
+	AccessRequestActive AccessRequestPhase = "Active"
+	// AccessRequestDenied is the phase of a denied request.
+	AccessRequestDenied AccessRequestPhase = "Denied"
+	// AccessRequestExpired is the phase of a request whose access has expired and whose binding was removed.
+	AccessRequestExpired AccessRequestPhase = "Expired"
+	// AccessRequestInvalid is the phase of a request that can't be granted, e.g. because the role template does not exist.
+	AccessRequestInvalid AccessRequestPhase = "Invalid"
+)
+
+const (
+	// AccessRequestRequestedEvent is recorded when a request is accepted for review.
+	AccessRequestRequestedEvent = "Requested"
+	// AccessRequestApprovedEvent is recorded when a request is approved.
+	AccessRequestApprovedEvent = "Approved"
+	// AccessRequestDeniedEvent is recorded when a request is denied.
+	AccessRequestDeniedEvent = "Denied"
+	// AccessRequestGrantedEvent is recorded when the binding of an approved request is created.
+	AccessRequestGrantedEvent = "Granted"
+	// AccessRequestExpiredEvent is recorded when the binding of a request is removed at expiry.
+	AccessRequestExpiredEvent = "Expired"
+	// AccessRequestInvalidEvent is recorded when a request is rejected as invalid.
+	AccessRequestInvalidEvent = "Invalid"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.userName"
+// +kubebuilder:printcolumn:name="Role Template",type="string",JSONPath=".spec.roleTemplateName"
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="Project",type="string",JSONPath=".spec.projectName"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Expires",type="string",JSONPath=".status.expirationTime"
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AccessRequest is a request of a user for temporary access to a cluster or project. Once it is approved, a
+// ClusterRoleTemplateBinding or ProjectRoleTemplateBinding granting the requested role template is created, which
+// is removed again when the requested duration has passed. Requests are approved or denied with the approve and deny
+// actions by users allowed the approve verb on the request.
+type AccessRequest struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the requested access.
+	Spec AccessRequestSpec `json:"spec"`
+
+	// Status is the most recently observed status of the request.
+	// +optional
+	Status AccessRequestStatus `json:"status,omitempty"`
+}
+
+// AccessRequestSpec is the access requested by a user.
+type AccessRequestSpec struct {
+	// UserName is the name of the user access is requested for. Requests filed with the request action of the
+	// steve API are always for the calling user.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	UserName string `json:"userName"`
+
+	// RoleTemplateName is the name of the requested role template. Cluster role templates can only be requested
+	// for a cluster and project role templates only for a project.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	RoleTemplateName string `json:"roleTemplateName"`
+
+	// ClusterName is the name of the cluster access is requested for. Exactly one of ClusterName and ProjectName must
+	// be set.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// ProjectName is the name of the project access is requested for, in the format "clusterName:projectName".
+	// Exactly one of ClusterName and ProjectName must be set.
+	// +optional
+	ProjectName string `json:"projectName,omitempty"`
+
+	// Duration is how long access is granted once the request is approved. It can't exceed the
+	// access-request-max-duration setting.
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+
+	// Justification is the reason access is requested for, shown to the approvers.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Justification string `json:"justification"`
+}
+
+// AccessRequestStatus is the most recently observed status of the request.
+type AccessRequestStatus struct {
+	// Phase is the phase of the request.
+	// +optional
+	Phase AccessRequestPhase `json:"phase,omitempty"`
+
+	// Reviewer is the name of the user who approved or denied the request.
+	// +optional
+	Reviewer string `json:"reviewer,omitempty"`
+
+	// ReviewTime is the time the request was approved or denied.
+	// +optional
+	ReviewTime *metav1.Time `json:"reviewTime,omitempty"`
+
+	// ReviewComment is the comment left by the reviewer.
+	// +optional
+	ReviewComment string `json:"reviewComment,omitempty"`
+
+	// ExpirationTime is the time the granted access expires and its binding is removed.
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+
+	// BindingNamespace is the namespace of the binding granting the requested access.
+	// +optional
+	BindingNamespace string `json:"bindingNamespace,omitempty"`
+
+	// BindingName is the name of the binding granting the requested access.
+	// +optional
+	BindingName string `json:"bindingName,omitempty"`
+
+	// Message is a human-readable reason the request is invalid.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// History records every step of the request, oldest first.
+	// +optional
+	History []AccessRequestEvent `json:"history,omitempty"`
+}
+
+// AccessRequestEvent is a step in the life of an access request.
+type AccessRequestEvent struct {
+	// Type is the type of the step, one of Requested, Approved, Denied, Granted, Expired and Invalid.
+	Type string `json:"type"`
+
+	// Time is the time of the step.
+	Time metav1.Time `json:"time"`
+
+	// User is the name of the user who took the step, if it was taken by a user.
+	// +optional
+	User string `json:"user,omitempty"`
+
+	// Message describes the step.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
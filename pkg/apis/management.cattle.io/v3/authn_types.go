@@ -106,16 +106,34 @@ type User struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	DisplayName        string     `json:"displayName,omitempty"`
-	Description        string     `json:"description"`
-	Username           string     `json:"username,omitempty"`
-	Password           string     `json:"password,omitempty" norman:"writeOnly,noupdate"`
-	MustChangePassword bool       `json:"mustChangePassword,omitempty"`
-	PrincipalIDs       []string   `json:"principalIds,omitempty" norman:"type=array[reference[principal]]"`
-	Me                 bool       `json:"me,omitempty" norman:"nocreate,noupdate"`
-	Enabled            *bool      `json:"enabled,omitempty" norman:"default=true"`
-	Spec               UserSpec   `json:"spec,omitempty"`
-	Status             UserStatus `json:"status"`
+	DisplayName        string       `json:"displayName,omitempty"`
+	Description        string       `json:"description"`
+	Username           string       `json:"username,omitempty"`
+	Password           string       `json:"password,omitempty" norman:"writeOnly,noupdate"`
+	MustChangePassword bool         `json:"mustChangePassword,omitempty"`
+	PrincipalIDs       []string     `json:"principalIds,omitempty" norman:"type=array[reference[principal]]"`
+	Me                 bool         `json:"me,omitempty" norman:"nocreate,noupdate"`
+	Enabled            *bool        `json:"enabled,omitempty" norman:"default=true"`
+	MFA                *UserMFA     `json:"mfa,omitempty" norman:"nocreate,noupdate"`
+	PasswordChangedAt  *metav1.Time `json:"passwordChangedAt,omitempty" norman:"nocreate,noupdate"`
+	PasswordHistory    []string     `json:"passwordHistory,omitempty" norman:"nocreate,noupdate,writeOnly"`
+	LockedUntil        *metav1.Time `json:"lockedUntil,omitempty" norman:"nocreate,noupdate"`
+	Spec               UserSpec     `json:"spec,omitempty"`
+	Status             UserStatus   `json:"status"`
+}
+
+// UserMFA is the multi-factor authentication enrollment of a local user.
+type UserMFA struct {
+	// Enabled is true once the enrollment was confirmed with a valid code. Logins then require a second factor.
+	Enabled bool `json:"enabled,omitempty"`
+	// SecretName is the namespace:name of the secret holding the TOTP key.
+	SecretName string `json:"secretName,omitempty"`
+	// EnrolledAt is the time the enrollment was confirmed.
+	EnrolledAt *metav1.Time `json:"enrolledAt,omitempty"`
+	// LastUsedStep is the TOTP time step of the last accepted code, codes can't be replayed within their step.
+	LastUsedStep int64 `json:"lastUsedStep,omitempty"`
+	// RecoveryCodes are the bcrypt hashes of the unused one-time recovery codes.
+	RecoveryCodes []string `json:"recoveryCodes,omitempty" norman:"writeOnly"`
 }
 
 // IsSystem returns true if the user is a system user.
@@ -238,6 +256,19 @@ type SetPasswordInput struct {
 	NewPassword string `json:"newPassword" norman:"type=string,required"`
 }
 
+type MFACodeInput struct {
+	MFACode string `json:"mfaCode" norman:"type=string,required"`
+}
+
+type MFAEnrollOutput struct {
+	MFASecret string `json:"mfaSecret"`
+	MFAKeyURI string `json:"mfaKeyUri"`
+}
+
+type MFARecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 // +genclient
 // +kubebuilder:skipversion
 // +genclient:nonNamespaced
@@ -71,6 +71,8 @@ func init() {
 	ClusterConditionHarvesterCloudProviderConfigMigrated condition.Cond = "HarvesterCloudProviderConfigMigrated"
 	ClusterConditionACISecretsMigrated                   condition.Cond = "ACISecretsMigrated"
 	ClusterConditionRKESecretsMigrated                   condition.Cond = "RKESecretsMigrated"
+	// ClusterConditionAgentConnectionStable is false when the tunnel sessions of the cluster's agents reconnect repeatedly
+	ClusterConditionAgentConnectionStable condition.Cond = "AgentConnectionStable"
 
 	ClusterDriverImported = "imported"
 	ClusterDriverLocal    = "local"
@@ -228,6 +230,37 @@ type ClusterStatus struct {
 	AADClientCertSecret        string                    `json:"aadClientCertSecret,omitempty" norman:"nocreate,noupdate"`   // Deprecated: use ClusterSpec.ClusterSecrets.AADClientCertSecret instead
 
 	AppliedClusterAgentDeploymentCustomization *AgentDeploymentCustomization `json:"appliedClusterAgentDeploymentCustomization,omitempty"`
+
+	// AgentSessions are the tunnel sessions opened by the agents of the cluster, including recently closed ones.
+	AgentSessions []ClusterAgentSession `json:"agentSessions,omitempty" norman:"nocreate,noupdate"`
+}
+
+// ClusterAgentSession describes a tunnel session opened by an agent of a cluster to a Rancher replica.
+// The bytes transmitted and received and the requests in flight of the session are exported as the
+// tunnel_server_agent_session_* metrics of the replica holding it.
+type ClusterAgentSession struct {
+	// ClientKey identifies the session within the tunnel server. It is the cluster name for the cluster agent.
+	ClientKey string `json:"clientKey"`
+	// Peer is the ID of the Rancher replica holding the session.
+	Peer string `json:"peer,omitempty"`
+	// RemoteAddress is the address the agent connected from. A reconnect from a different address usually means the
+	// agent was restarted or rescheduled, a reconnect from the same address usually points at the network.
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	// ConnectedAt is when the session was opened.
+	ConnectedAt metav1.Time `json:"connectedAt,omitempty"`
+	// DisconnectedAt is when the session was closed, it is unset while the session is active.
+	DisconnectedAt *metav1.Time `json:"disconnectedAt,omitempty"`
+	// ClosedByReplicaShutdown is set if the session was closed because the replica holding it shut down, e.g. during a
+	// rolling upgrade of Rancher. The reconnect that follows is not counted towards RecentReconnects.
+	ClosedByReplicaShutdown bool `json:"closedByReplicaShutdown,omitempty"`
+	// Reconnects is the number of times the session was opened again after being closed.
+	Reconnects int64 `json:"reconnects,omitempty"`
+	// RecentReconnects is the number of reconnects since RecentReconnectsSince.
+	RecentReconnects int64 `json:"recentReconnects,omitempty"`
+	// RecentReconnectsSince is the start of the window RecentReconnects are counted in.
+	RecentReconnectsSince metav1.Time `json:"recentReconnectsSince,omitempty"`
+	// RecentAddressChanges is the number of recent reconnects that came from a different address than the session before.
+	RecentAddressChanges int64 `json:"recentAddressChanges,omitempty"`
 }
 
 type ClusterComponentStatus struct {
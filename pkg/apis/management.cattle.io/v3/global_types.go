@@ -17,6 +17,26 @@ type Setting struct {
 	Default    string `json:"default" norman:"nocreate,noupdate"`
 	Customized bool   `json:"customized" norman:"nocreate,noupdate"`
 	Source     string `json:"source" norman:"nocreate,noupdate,options=db|default|env"`
+
+	// Type is the declared type of the value: string, int, bool or duration.
+	Type string `json:"type,omitempty" norman:"nocreate,noupdate"`
+	// History holds the most recent changes made to the value, oldest first.
+	// Changes made by editing the resource directly are not recorded.
+	History []SettingRevision `json:"history,omitempty" norman:"nocreate,noupdate"`
+}
+
+// SettingRevision records a single change to the value of a Setting.
+type SettingRevision struct {
+	// Revision is a sequence number incremented for every recorded change.
+	Revision int64 `json:"revision"`
+	// OldValue is the value before the change. An empty value means the default was used.
+	OldValue string `json:"oldValue,omitempty"`
+	// NewValue is the value after the change. An empty value means the default is used.
+	NewValue string `json:"newValue,omitempty"`
+	// User is the name of the user that made the change, empty for changes made by Rancher itself.
+	User string `json:"user,omitempty"`
+	// Time is when the change was made.
+	Time metav1.Time `json:"time"`
 }
 
 // +genclient
@@ -47,6 +47,10 @@ type OIDCClientStatus struct {
 	ClientID string `json:"clientID,omitempty"`
 	// ClientSecrets represents the observed status of the client secrets
 	ClientSecrets map[string]OIDCClientSecretStatus `json:"clientSecrets,omitempty"`
+	// ServicePrincipal is the service principal of the spec once it was
+	// authorized by a user allowed to act as it. It is cleared when the
+	// service principal of the spec changes.
+	ServicePrincipal string `json:"servicePrincipal,omitempty"`
 }
 
 // OIDCClient is a description of the oidc client.
@@ -66,4 +70,11 @@ type OIDCClientSpec struct {
 	// a refresh token remains valid before expiration.
 	// +kubebuilder:validation:Minimum=1
 	RefreshTokenExpirationSeconds int64 `json:"refreshTokenExpirationSeconds"`
+	// ServicePrincipal is the ID of the Rancher user the client acts as when it
+	// requests tokens with the client_credentials grant. The grant is rejected
+	// for clients without a service principal, and until the service principal
+	// is authorized with the authorizeServicePrincipal action by the user
+	// itself or a user allowed to impersonate it.
+	// +optional
+	ServicePrincipal string `json:"servicePrincipal,omitempty"`
 }
@@ -61,6 +61,8 @@ type BasicLogin struct {
 	GenericLogin `json:",inline"`
 	Username     string `json:"username" norman:"type=string,required"`
 	Password     string `json:"password" norman:"type=string,required"`
+	// MFACode is a TOTP or recovery code, required for local users enrolled in multi-factor authentication.
+	MFACode string `json:"mfaCode,omitempty" norman:"type=string"`
 }
 
 // +genclient
@@ -490,6 +490,12 @@ type ClusterStatus struct {
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration"`
 
+	// ETCDSnapshotPolicy is the observed state of the etcd snapshot policy
+	// of the cluster, if it has one.
+	// +nullable
+	// +optional
+	ETCDSnapshotPolicy *ETCDSnapshotPolicyStatus `json:"etcdSnapshotPolicy,omitempty"`
+
 	// Conditions is a representation of the Cluster's current state.
 	// +optional
 	// +listType=map
@@ -497,6 +503,52 @@ type ClusterStatus struct {
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
 }
 
+// ETCDSnapshotPolicyStatus is the observed state of the etcd snapshot policy
+// of a cluster.
+type ETCDSnapshotPolicyStatus struct {
+	// RetainedSnapshots is the number of S3 snapshots retained by the policy.
+	// +optional
+	RetainedSnapshots int `json:"retainedSnapshots,omitempty"`
+
+	// Destinations is the drift between the etcd snapshot objects of the
+	// cluster and the objects in each S3 destination.
+	// +optional
+	Destinations []ETCDSnapshotDestinationStatus `json:"destinations,omitempty"`
+}
+
+// ETCDSnapshotDestinationStatus is the drift between the etcd snapshot
+// objects of a cluster and the objects in an S3 destination.
+type ETCDSnapshotDestinationStatus struct {
+	// Name is the name of the destination, either "primary" or "replica".
+	Name string `json:"name"`
+
+	// Endpoint is the S3 endpoint of the destination.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Bucket is the S3 bucket of the destination.
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
+
+	// Folder is the folder of the S3 bucket the snapshots are stored in.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// MissingSnapshots are the names of the retained etcd snapshot objects
+	// whose snapshot is missing in the destination.
+	// +optional
+	MissingSnapshots []string `json:"missingSnapshots,omitempty"`
+
+	// UntrackedObjects are the objects in the folder of the destination that
+	// do not belong to a retained etcd snapshot object.
+	// +optional
+	UntrackedObjects []string `json:"untrackedObjects,omitempty"`
+
+	// Error is the error encountered while reconciling the destination.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
 // +genclient
 // +kubebuilder:resource:path=clusters,scope=Namespaced,categories=provisioning
 // +kubebuilder:subresource:status
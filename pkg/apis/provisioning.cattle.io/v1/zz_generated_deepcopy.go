@@ -221,6 +221,11 @@ func (in *ClusterSpec) DeepCopy() *ClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 	*out = *in
+	if in.ETCDSnapshotPolicy != nil {
+		in, out := &in.ETCDSnapshotPolicy, &out.ETCDSnapshotPolicy
+		*out = new(ETCDSnapshotPolicyStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]genericcondition.GenericCondition, len(*in))
@@ -239,6 +244,55 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotDestinationStatus) DeepCopyInto(out *ETCDSnapshotDestinationStatus) {
+	*out = *in
+	if in.MissingSnapshots != nil {
+		in, out := &in.MissingSnapshots, &out.MissingSnapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UntrackedObjects != nil {
+		in, out := &in.UntrackedObjects, &out.UntrackedObjects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotDestinationStatus.
+func (in *ETCDSnapshotDestinationStatus) DeepCopy() *ETCDSnapshotDestinationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotDestinationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotPolicyStatus) DeepCopyInto(out *ETCDSnapshotPolicyStatus) {
+	*out = *in
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]ETCDSnapshotDestinationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotPolicyStatus.
+func (in *ETCDSnapshotPolicyStatus) DeepCopy() *ETCDSnapshotPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodDisruptionBudgetSpec) DeepCopyInto(out *PodDisruptionBudgetSpec) {
 	*out = *in
@@ -109,6 +109,116 @@ type ClusterUpgradeStrategy struct {
 	// worker nodes, during both upgrades and machine rollouts.
 	// +optional
 	WorkerDrainOptions DrainOptions `json:"workerDrainOptions,omitempty"`
+
+	// MaintenanceWindow restricts when disruptive plan changes, such as
+	// Kubernetes version upgrades and configuration changes that require
+	// the nodes to be drained or restarted, as well as certificate and
+	// encryption key rotations are started. New nodes only wait for the
+	// window while the changes of the nodes of their tier, or of a tier
+	// rolled out before it, are held, so they don't join with another plan.
+	// Changes that were already started are completed.
+	// If unset, plan changes are rolled out as soon as they are reconciled.
+	// +nullable
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+
+	// Canary upgrades a subset of the worker machines first and holds the
+	// disruptive plan changes of the remaining worker machines until the
+	// canary machines have been healthy for the soak duration. The rollout
+	// is halted if a canary machine fails.
+	// If unset, worker machines are upgraded without a canary phase.
+	// +nullable
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+}
+
+// CanaryStrategy contains the configuration of the canary phase of worker
+// upgrades.
+type CanaryStrategy struct {
+	// MachineSelector selects the worker machines that are upgraded first
+	// by the labels of their CAPI machines, for instance the
+	// rke.cattle.io/rke-machine-pool-name label of a machine pool.
+	MachineSelector metav1.LabelSelector `json:"machineSelector"`
+
+	// SoakDuration is how long the canary machines must be healthy after
+	// they have been upgraded before the remaining worker machines are
+	// upgraded, for instance "30m".
+	// +optional
+	SoakDuration metav1.Duration `json:"soakDuration,omitempty"`
+
+	// Checks are HTTP health checks that are run on the canary machines in
+	// addition to the probes of their plans. A canary machine fails if any
+	// of its checks or probes exceeds its failure threshold, or its plan
+	// fails to apply.
+	// +nullable
+	// +optional
+	Checks []CanaryCheck `json:"checks,omitempty"`
+}
+
+// CanaryCheck is an HTTP GET health check run on the canary machines. The
+// check succeeds if the response status is 2xx.
+type CanaryCheck struct {
+	// Name is the name of the check, which must be unique.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// URL is the URL the check requests from the machine, for instance
+	// "http://127.0.0.1:8080/healthz".
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+
+	// Insecure skips the verification of the certificate of the server.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// CACert is the path on the machine of the CA certificate used to
+	// verify the certificate of the server.
+	// +optional
+	CACert string `json:"caCert,omitempty"`
+
+	// TimeoutSeconds is the timeout of the request.
+	// The default value is 5.
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures after which
+	// the canary machine fails.
+	// The default value is 3.
+	// +optional
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+}
+
+// MaintenanceWindow contains the recurring windows during which disruptive
+// plan changes may be rolled out.
+type MaintenanceWindow struct {
+	// Windows are the recurring windows during which disruptive plan
+	// changes may be rolled out. Changes that were started when a window
+	// closes are completed.
+	// +kubebuilder:validation:MinItems=1
+	Windows []MaintenanceWindowSchedule `json:"windows"`
+
+	// TimeZone is the IANA time zone, such as "Europe/Berlin", the windows
+	// and blackout dates are evaluated in.
+	// The default value is UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// BlackoutDates are dates, in the YYYY-MM-DD format, on which no
+	// window opens, for instance during a change freeze.
+	// +nullable
+	// +optional
+	BlackoutDates []string `json:"blackoutDates,omitempty"`
+}
+
+// MaintenanceWindowSchedule is a recurring maintenance window.
+type MaintenanceWindowSchedule struct {
+	// Schedule is the cron expression for when the window opens, with the
+	// fields minute, hour, day of month, month and day of week. For
+	// instance, "0 22 * * 6" opens the window on Saturdays at 22:00.
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window stays open, for instance "4h".
+	Duration metav1.Duration `json:"duration"`
 }
 
 // DrainOptions contains the drain configuration for a machine pool.
@@ -369,6 +479,81 @@ type ETCD struct {
 	// +nullable
 	// +optional
 	S3 *ETCDSnapshotS3 `json:"s3,omitempty"`
+
+	// SnapshotPolicy defines the retention and replication of the S3
+	// snapshots of the cluster, which are managed by Rancher in addition to
+	// the snapshot retention of the distribution.
+	// +nullable
+	// +optional
+	SnapshotPolicy *ETCDSnapshotPolicy `json:"snapshotPolicy,omitempty"`
+
+	// SnapshotVerification enables the verification of the S3 snapshots of
+	// the cluster. Each snapshot is downloaded, checked for integrity and
+	// restored into a throw-away etcd by a job in the management cluster.
+	// The result is recorded in the status of the etcd snapshot object.
+	// +nullable
+	// +optional
+	SnapshotVerification *ETCDSnapshotVerification `json:"snapshotVerification,omitempty"`
+}
+
+// ETCDSnapshotPolicy defines how Rancher retains and replicates the S3
+// snapshots of a cluster.
+type ETCDSnapshotPolicy struct {
+	// Retention defines which S3 snapshots are retained. Snapshots that are
+	// not retained are pruned from the primary destination and, if Rancher
+	// replicated them, from the replica destination.
+	// If unset, all snapshots are retained.
+	// +nullable
+	// +optional
+	Retention *ETCDSnapshotRetention `json:"retention,omitempty"`
+
+	// Replica is the S3 destination each retained S3 snapshot is copied to.
+	// Objects in the replica destination are only pruned along with the
+	// snapshot Rancher replicated them for, other objects in it are reported as
+	// untracked.
+	// If the CloudCredentialName is not set, the cloud credential of the S3
+	// configuration of the cluster is used.
+	// +nullable
+	// +optional
+	Replica *ETCDSnapshotS3 `json:"replica,omitempty"`
+}
+
+// ETCDSnapshotVerification configures the verification jobs of the S3
+// snapshots of a cluster. The snapshots are restored and served with the
+// image of the etcd-snapshot-verification-image setting.
+type ETCDSnapshotVerification struct {
+	// TimeoutSeconds is the time after which a verification job is
+	// considered failed. Defaults to 600.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// ETCDSnapshotRetention is a grandfather-father-son retention scheme. For
+// each period, the newest snapshot of the given number of most recent periods
+// that contain a snapshot is retained, per node. A snapshot is retained if
+// any period retains it.
+type ETCDSnapshotRetention struct {
+	// Hourly is the number of hourly snapshots to retain.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Hourly int `json:"hourly,omitempty"`
+
+	// Daily is the number of daily snapshots to retain.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Daily int `json:"daily,omitempty"`
+
+	// Weekly is the number of weekly snapshots to retain. Weeks start on
+	// Monday.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Weekly int `json:"weekly,omitempty"`
+
+	// Monthly is the number of monthly snapshots to retain.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Monthly int `json:"monthly,omitempty"`
 }
 
 // Networking contains information regarding the desired and actual networking stack of the cluster.
@@ -141,4 +141,51 @@ type ETCDSnapshotFile struct {
 type ETCDSnapshotStatus struct {
 	// This field is currently unused but retained for backward compatibility or future use.
 	Missing bool `json:"missing"`
+
+	// Verification is the result of the restore verification of the snapshot.
+	// It is only set for S3 snapshots of clusters with snapshot verification enabled.
+	// +nullable
+	// +optional
+	Verification *ETCDSnapshotVerificationStatus `json:"verification,omitempty"`
+}
+
+type ETCDSnapshotVerificationPhase string
+
+const (
+	ETCDSnapshotVerificationRunning  ETCDSnapshotVerificationPhase = "Running"
+	ETCDSnapshotVerificationVerified ETCDSnapshotVerificationPhase = "Verified"
+	ETCDSnapshotVerificationFailed   ETCDSnapshotVerificationPhase = "Failed"
+)
+
+// ETCDSnapshotVerificationStatus is the result of restoring a snapshot into a
+// throw-away etcd.
+type ETCDSnapshotVerificationStatus struct {
+	// Phase is the phase of the verification, one of "Running", "Verified"
+	// or "Failed".
+	// +optional
+	Phase ETCDSnapshotVerificationPhase `json:"phase,omitempty"`
+
+	// Size is the size of the snapshot object in bytes.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// KeyCount is the number of keys in the restored etcd.
+	// +optional
+	KeyCount int64 `json:"keyCount,omitempty"`
+
+	// Revision is the revision of the restored etcd.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+
+	// StartTime is the time the verification started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the verification completed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message details why the verification failed.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
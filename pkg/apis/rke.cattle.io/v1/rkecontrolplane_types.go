@@ -149,6 +149,72 @@ type RKEControlPlaneStatus struct {
 	// established for the cluster.
 	// +optional
 	AgentConnected bool `json:"agentConnected,omitempty"`
+
+	// MaintenanceWindow is populated while disruptive plan changes or
+	// rotations are waiting for the maintenance window of the upgrade
+	// strategy to open.
+	// +nullable
+	// +optional
+	MaintenanceWindow *MaintenanceWindowStatus `json:"maintenanceWindow,omitempty"`
+
+	// Canary is populated while the canary phase of the upgrade strategy
+	// holds the plan changes of worker machines, or once it has halted the
+	// rollout.
+	// +nullable
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+}
+
+// CanaryStatus describes the canary phase of a worker upgrade.
+type CanaryStatus struct {
+	// SoakStartTime is the time the canary machines were upgraded and
+	// healthy.
+	// +nullable
+	// +optional
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
+
+	// Halted denotes that the rollout was halted because a canary machine
+	// failed. The rollout resumes once the spec is changed.
+	// +optional
+	Halted bool `json:"halted,omitempty"`
+
+	// HaltedGeneration is the generation of the RKEControlPlane the
+	// rollout was halted for.
+	// +optional
+	HaltedGeneration int64 `json:"haltedGeneration,omitempty"`
+
+	// FailedMachines are the names of the canary machines that failed.
+	// +nullable
+	// +optional
+	FailedMachines []string `json:"failedMachines,omitempty"`
+
+	// WaitingMachines are the names of the worker machines whose disruptive
+	// plan changes are waiting for the canary phase to complete.
+	// +nullable
+	// +optional
+	WaitingMachines []string `json:"waitingMachines,omitempty"`
+}
+
+// MaintenanceWindowStatus describes the disruptive plan changes and rotations
+// that are waiting for the maintenance window to open.
+type MaintenanceWindowStatus struct {
+	// NextWindow is the time the maintenance window opens next.
+	// +nullable
+	// +optional
+	NextWindow *metav1.Time `json:"nextWindow,omitempty"`
+
+	// WaitingMachines are the names of the machines whose disruptive plan
+	// changes are waiting for the maintenance window to open.
+	// +nullable
+	// +optional
+	WaitingMachines []string `json:"waitingMachines,omitempty"`
+
+	// WaitingRotations are the certificate or encryption key rotations,
+	// "certificates" or "encryptionKeys", that are waiting for the
+	// maintenance window to open.
+	// +nullable
+	// +optional
+	WaitingRotations []string `json:"waitingRotations,omitempty"`
 }
 
 // +genclient
@@ -10,6 +10,7 @@
 	"github.com/rancher/norman/types"
 	"github.com/rancher/rancher/pkg/api/scheme"
 	"github.com/rancher/rancher/pkg/auth/api/user"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/principals"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/providers"
@@ -36,6 +37,8 @@ func User(ctx context.Context, schemas *types.Schemas, management *config.Scaled
 		GlobalRoleBindingsClient: management.Management.GlobalRoleBindings(""),
 		UserAuthRefresher:        providerrefresh.NewUserAuthRefresher(ctx, management),
 		ExtTokenStore:            extTokenStore,
+		MFA:                      mfa.NewManager(management.Wrangler),
+		Users:                    management.Wrangler.Mgmt.User(),
 	}
 
 	schema.Formatter = handler.UserFormatter
@@ -0,0 +1,97 @@
+package user
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"golang.org/x/crypto/bcrypt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validatePasswordPolicy ensures the password satisfies validatePassword and the password-min-character-classes
+// setting.
+func validatePasswordPolicy(user string, currentPass string, pass string) error {
+	if err := validatePassword(user, currentPass, pass, settings.PasswordMinLength.GetInt()); err != nil {
+		return err
+	}
+	return validatePasswordClasses(pass, settings.PasswordMinCharacterClasses.GetInt())
+}
+
+// validatePasswordClasses ensures the password contains characters of at least minClasses of the classes lowercase
+// letters, uppercase letters, digits and other characters.
+func validatePasswordClasses(pass string, minClasses int) error {
+	var lower, upper, digit, other int
+	for _, r := range pass {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	if lower+upper+digit+other < minClasses {
+		return errors.Errorf("Password must contain characters of at least %v of the classes lowercase letters, uppercase letters, digits and other characters", minClasses)
+	}
+	return nil
+}
+
+// validatePasswordHistory ensures the password is neither the current password of the user nor one of its last
+// historySize passwords.
+func validatePasswordHistory(user *v3.User, pass string, historySize int) error {
+	if historySize <= 0 {
+		return nil
+	}
+	hashes := append([]string{user.Password}, lastPasswords(user.PasswordHistory, historySize)...)
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil {
+			return errors.Errorf("Password must not be one of the last %v passwords", historySize)
+		}
+	}
+	return nil
+}
+
+// recordPasswordChange moves the replaced password hash into the history of the user, keeping at most historySize
+// hashes, and records the time of the change.
+func recordPasswordChange(user *v3.User, oldHash string, historySize int, now time.Time) {
+	if historySize > 0 && oldHash != "" {
+		user.PasswordHistory = lastPasswords(append(user.PasswordHistory, oldHash), historySize)
+	} else {
+		user.PasswordHistory = nil
+	}
+	changedAt := metav1.NewTime(now)
+	user.PasswordChangedAt = &changedAt
+}
+
+func lastPasswords(history []string, n int) []string {
+	if len(history) <= n {
+		return history
+	}
+	return history[len(history)-n:]
+}
+
+// recordPasswordChangeOf records the password change in the latest version of the user, retrying on conflicts.
+func (h *Handler) recordPasswordChangeOf(userName, oldHash string, historySize int) error {
+	for i := 0; i < 3; i++ {
+		user, err := h.UserClient.Get(userName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		user = user.DeepCopy()
+		recordPasswordChange(user, oldHash, historySize, time.Now())
+		_, err = h.UserClient.Update(user)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update user %s", userName)
+}
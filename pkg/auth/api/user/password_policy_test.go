@@ -0,0 +1,66 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidatePasswordClasses(t *testing.T) {
+	tests := []struct {
+		password   string
+		minClasses int
+		expectsErr bool
+	}{
+		{password: "onlylowercase", minClasses: 0},
+		{password: "onlylowercase", minClasses: 1},
+		{password: "onlylowercase", minClasses: 2, expectsErr: true},
+		{password: "lowerUPPER", minClasses: 2},
+		{password: "lowerUPPER123", minClasses: 3},
+		{password: "lowerUPPER123", minClasses: 4, expectsErr: true},
+		{password: "lowerUPPER123!", minClasses: 4},
+		{password: "парольПАРОЛЬ", minClasses: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := validatePasswordClasses(tt.password, tt.minClasses)
+			if tt.expectsErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	hash := func(pass string) string {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+		require.NoError(t, err)
+		return string(hashed)
+	}
+	user := &v3.User{Password: hash("first")}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, pass := range []string{"second", "third", "fourth"} {
+		require.NoError(t, validatePasswordHistory(user, pass, 2))
+		recordPasswordChange(user, user.Password, 2, now)
+		user.Password = hash(pass)
+	}
+	require.Len(t, user.PasswordHistory, 2)
+	assert.Equal(t, now, user.PasswordChangedAt.Time)
+
+	assert.Error(t, validatePasswordHistory(user, "fourth", 2), "the current password")
+	assert.Error(t, validatePasswordHistory(user, "third", 2))
+	assert.Error(t, validatePasswordHistory(user, "second", 2))
+	assert.NoError(t, validatePasswordHistory(user, "first", 2), "the password dropped out of the history")
+	assert.NoError(t, validatePasswordHistory(user, "second", 1), "only the last passwords are checked")
+	assert.NoError(t, validatePasswordHistory(user, "third", 0), "a zero size disables the history")
+
+	recordPasswordChange(user, user.Password, 0, now)
+	assert.Empty(t, user.PasswordHistory)
+}
@@ -3,15 +3,19 @@
 import (
 	"net/http"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/parse"
 	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
+	"github.com/rancher/rancher/pkg/auth/providers/local"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	exttokenstore "github.com/rancher/rancher/pkg/ext/stores/tokens"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
 	"golang.org/x/crypto/bcrypt"
@@ -21,6 +25,14 @@
 func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.RawResource) {
 	resource.AddAction(apiContext, "setpassword")
 
+	if resource.Values[client.UserFieldMFA] != nil && h.userCanUpdate(apiContext, resource.ID) {
+		resource.AddAction(apiContext, "resetmfa")
+	}
+
+	if resource.Values[client.UserFieldLockedUntil] != nil && h.userCanUpdate(apiContext, resource.ID) {
+		resource.AddAction(apiContext, "unlock")
+	}
+
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		resource.AddAction(apiContext, "refreshauthprovideraccess")
 	}
@@ -28,6 +40,10 @@ func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.Ra
 
 func (h *Handler) CollectionFormatter(apiContext *types.APIContext, collection *types.GenericCollection) {
 	collection.AddAction(apiContext, "changepassword")
+	collection.AddAction(apiContext, "enrollmfa")
+	collection.AddAction(apiContext, "confirmmfa")
+	collection.AddAction(apiContext, "regeneratemfarecoverycodes")
+	collection.AddAction(apiContext, "disablemfa")
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		collection.AddAction(apiContext, "refreshauthprovideraccess")
 	}
@@ -38,10 +54,24 @@ type Handler struct {
 	GlobalRoleBindingsClient v3.GlobalRoleBindingInterface
 	UserAuthRefresher        providerrefresh.UserAuthRefresher
 	ExtTokenStore            *exttokenstore.SystemStore
+	MFA                      *mfa.Manager
+	Users                    managementcontrollers.UserClient
 }
 
 func (h *Handler) Actions(actionName string, action *types.Action, apiContext *types.APIContext) error {
 	switch actionName {
+	case "enrollmfa":
+		return h.enrollMFA(apiContext)
+	case "confirmmfa":
+		return h.confirmMFA(apiContext)
+	case "regeneratemfarecoverycodes":
+		return h.regenerateMFARecoveryCodes(apiContext)
+	case "disablemfa":
+		return h.disableMFA(apiContext)
+	case "resetmfa":
+		return h.resetMFA(apiContext)
+	case "unlock":
+		return h.unlock(apiContext)
 	case "changepassword":
 		if err := h.changePassword(apiContext); err != nil {
 			return err
@@ -97,7 +127,7 @@ func (h *Handler) changePassword(request *types.APIContext) error {
 		return err
 	}
 
-	if err := validatePassword(user.Username, currentPass, newPass, settings.PasswordMinLength.GetInt()); err != nil {
+	if err := validatePasswordPolicy(user.Username, currentPass, newPass); err != nil {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
 	}
 
@@ -105,11 +135,17 @@ func (h *Handler) changePassword(request *types.APIContext) error {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, "invalid current password")
 	}
 
+	historySize := settings.PasswordHistorySize.GetInt()
+	if err := validatePasswordHistory(user, newPass, historySize); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	}
+
 	newPassHash, err := HashPasswordString(newPass)
 	if err != nil {
 		return err
 	}
 
+	recordPasswordChange(user, user.Password, historySize, time.Now())
 	user.Password = newPassHash
 	user.MustChangePassword = false
 	user, err = h.UserClient.Update(user)
@@ -149,7 +185,16 @@ func (h *Handler) setPassword(request *types.APIContext) error {
 	username, _ := usernameInt.(string)
 
 	// passing empty currentPass to validator since, this api call doesn't assume an existing password
-	if err := validatePassword(username, "", newPass, settings.PasswordMinLength.GetInt()); err != nil {
+	if err := validatePasswordPolicy(username, "", newPass); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	}
+
+	user, err := h.UserClient.Get(request.ID, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	historySize := settings.PasswordHistorySize.GetInt()
+	if err := validatePasswordHistory(user, newPass, historySize); err != nil {
 		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
 	}
 
@@ -160,7 +205,16 @@ func (h *Handler) setPassword(request *types.APIContext) error {
 	userData[client.UserFieldMustChangePassword] = false
 	delete(userData, "me")
 
-	userData, err = store.Update(request, request.Schema, userData, request.ID)
+	if _, err = store.Update(request, request.Schema, userData, request.ID); err != nil {
+		return err
+	}
+
+	// The password history and change time can't be updated through the store.
+	if err := h.recordPasswordChangeOf(request.ID, user.Password, historySize); err != nil {
+		return err
+	}
+
+	userData, err = store.ByID(request, request.Schema, request.ID)
 	if err != nil {
 		return err
 	}
@@ -169,6 +223,24 @@ func (h *Handler) setPassword(request *types.APIContext) error {
 	return nil
 }
 
+// unlock lifts the lockout of a user after too many failed logins.
+func (h *Handler) unlock(request *types.APIContext) error {
+	if !h.userCanUpdate(request, request.ID) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "not allowed to unlock user")
+	}
+
+	if err := local.Unlock(request.Request.Context(), h.Users, request.ID, "unlocked by "+request.Request.Header.Get("Impersonate-User")); err != nil {
+		return err
+	}
+
+	userData, err := request.Schema.Store.ByID(request, request.Schema, request.ID)
+	if err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, userData)
+	return nil
+}
+
 func (h *Handler) refreshAttributes(request *types.APIContext) error {
 	canRefresh := h.userCanRefresh(request)
 
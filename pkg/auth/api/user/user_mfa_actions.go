@@ -0,0 +1,142 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/parse"
+	"github.com/rancher/norman/types"
+	"github.com/rancher/norman/types/convert"
+	"github.com/rancher/rancher/pkg/auth/mfa"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+)
+
+// enrollMFA starts the multi-factor authentication enrollment of the current user.
+func (h *Handler) enrollMFA(request *types.APIContext) error {
+	userID := request.Request.Header.Get("Impersonate-User")
+	if userID == "" {
+		return errors.New("can't find user")
+	}
+
+	secret, uri, err := h.MFA.Enroll(userID)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		client.MFAEnrollOutputFieldMFASecret: secret,
+		client.MFAEnrollOutputFieldMFAKeyURI: uri,
+		"type":                               client.MFAEnrollOutputType,
+	})
+	return nil
+}
+
+// confirmMFA enables the pending enrollment of the current user and returns its recovery codes.
+func (h *Handler) confirmMFA(request *types.APIContext) error {
+	userID, code, err := mfaCodeInput(request)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.MFA.Confirm(userID, code)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	writeRecoveryCodes(request, codes)
+	return nil
+}
+
+// regenerateMFARecoveryCodes replaces the recovery codes of the current user.
+func (h *Handler) regenerateMFARecoveryCodes(request *types.APIContext) error {
+	userID, code, err := mfaCodeInput(request)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.MFA.RegenerateRecoveryCodes(userID, code)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	writeRecoveryCodes(request, codes)
+	return nil
+}
+
+// disableMFA removes the enrollment of the current user, which must prove possession of the second factor.
+func (h *Handler) disableMFA(request *types.APIContext) error {
+	userID, code, err := mfaCodeInput(request)
+	if err != nil {
+		return err
+	}
+
+	if err := h.MFA.Verify(userID, code); err != nil {
+		return mfaError(err)
+	}
+	if err := h.MFA.Disable(userID); err != nil {
+		return err
+	}
+
+	request.WriteResponse(http.StatusOK, nil)
+	return nil
+}
+
+// resetMFA removes the enrollment of another user, e.g. after they lost their authenticator and recovery codes. The
+// enrollment is removed with the clients of Rancher, so the caller must be allowed to update the user.
+func (h *Handler) resetMFA(request *types.APIContext) error {
+	if !h.userCanUpdate(request, request.ID) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "not allowed to reset multi-factor authentication")
+	}
+
+	if err := h.MFA.Disable(request.ID); err != nil {
+		return err
+	}
+
+	userData, err := request.Schema.Store.ByID(request, request.Schema, request.ID)
+	if err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, userData)
+	return nil
+}
+
+// userCanUpdate returns whether the caller is allowed to update the user with the given ID.
+func (h *Handler) userCanUpdate(request *types.APIContext, userID string) bool {
+	return request.AccessControl.CanDo(v3.UserGroupVersionKind.Group, v3.UserResource.Name, "update", request, map[string]interface{}{"id": userID}, request.Schema) == nil
+}
+
+func mfaCodeInput(request *types.APIContext) (string, string, error) {
+	userID := request.Request.Header.Get("Impersonate-User")
+	if userID == "" {
+		return "", "", errors.New("can't find user")
+	}
+
+	actionInput, err := parse.ReadBody(request.Request)
+	if err != nil {
+		return "", "", err
+	}
+	code := convert.ToString(actionInput[client.MFACodeInputFieldMFACode])
+	if code == "" {
+		return "", "", httperror.NewAPIError(httperror.InvalidBodyContent, "must specify a multi-factor authentication code")
+	}
+	return userID, code, nil
+}
+
+func writeRecoveryCodes(request *types.APIContext, codes []string) {
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		client.MFARecoveryCodesOutputFieldRecoveryCodes: codes,
+		"type": client.MFARecoveryCodesOutputType,
+	})
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	case errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrAlreadyEnrolled), errors.Is(err, mfa.ErrNotLocalUser):
+		return httperror.NewAPIError(httperror.InvalidState, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+// Package annotations records annotations in the audit log of a request. It is kept apart from the audit package so
+// the handlers of requests can record annotations without depending on the audit log writer.
+package annotations
+
+import (
+	"context"
+	"sync"
+)
+
+type annotationsKey struct{}
+
+// Annotations are the annotations recorded for the audit log of a request.
+type Annotations struct {
+	sync.Mutex
+	values map[string]string
+}
+
+// WithAnnotations returns a context recording the annotations added by the handlers of its request.
+func WithAnnotations(ctx context.Context) (context.Context, *Annotations) {
+	a := &Annotations{}
+	return context.WithValue(ctx, annotationsKey{}, a), a
+}
+
+// Get returns the annotations recorded so far.
+func (a *Annotations) Get() map[string]string {
+	a.Lock()
+	defer a.Unlock()
+	return a.values
+}
+
+// Add records an annotation in the audit log of the request of the given context, replacing any previous value of
+// the key. It does nothing if the request is not audited.
+func Add(ctx context.Context, key, value string) {
+	a, ok := ctx.Value(annotationsKey{}).(*Annotations)
+	if !ok {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	if a.values == nil {
+		a.values = map[string]string{}
+	}
+	a.values[key] = value
+}
@@ -0,0 +1,387 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// chainHashWindow is the number of record hashes kept while verifying a chain, which bounds how far apart two
+	// reordered records can be and still have their links checked.
+	chainHashWindow = 1024
+
+	checkpointSignaturePrefix = "rancher-audit-checkpoint"
+)
+
+// ChainOptions configures hash chaining of the logs written by a Writer. When enabled each record carries a sequence
+// number and the hash of the record before it, so removing, reordering or editing a record breaks the chain.
+type ChainOptions struct {
+	Enabled bool
+
+	// CheckpointInterval is the number of records written between checkpoints. A checkpoint is always written when a
+	// chain is started and when the writer is stopped. A value of 0 disables periodic checkpoints.
+	CheckpointInterval uint64
+
+	// Signer is used to sign checkpoints. If nil checkpoints are written without a signature.
+	Signer crypto.Signer
+
+	// KeyID identifies the key used to sign checkpoints.
+	KeyID string
+}
+
+type checkpointData struct {
+	Timestamp  string `json:"timestamp"`
+	ChainStart bool   `json:"chainStart,omitempty"`
+	KeyID      string `json:"keyID,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+// checkpoint is a record written into the chain that signs the hash of the record before it, anchoring every record
+// up to that point to the signing key.
+type checkpoint struct {
+	Sequence     uint64         `json:"sequence"`
+	PreviousHash string         `json:"previousHash,omitempty"`
+	Checkpoint   checkpointData `json:"checkpoint"`
+}
+
+func (c checkpoint) payload() []byte {
+	return []byte(fmt.Sprintf("%s:%d:%s:%s:%t", checkpointSignaturePrefix, c.Sequence, c.PreviousHash, c.Checkpoint.Timestamp, c.Checkpoint.ChainStart))
+}
+
+func hashRecord(record []byte) string {
+	sum := sha256.Sum256(record)
+	return hex.EncodeToString(sum[:])
+}
+
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(payload)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+func verifyPayload(publicKey crypto.PublicKey, payload []byte, signature []byte) error {
+	digest := sha256.Sum256(payload)
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("signature does not match: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	return nil
+}
+
+// chain links each record written through it to the record before it.
+type chain struct {
+	ChainOptions
+
+	mu              sync.Mutex
+	sequence        uint64
+	previousHash    string
+	sinceCheckpoint uint64
+
+	now func() time.Time
+}
+
+func newChain(opts ChainOptions) *chain {
+	return &chain{
+		ChainOptions: opts,
+		now:          time.Now,
+	}
+}
+
+// write assigns the next sequence number to log, links it to the previous record and writes it to out. The encoded
+// record is returned without a trailing newline.
+func (c *chain) write(log *log, out io.Writer) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sequence == 0 {
+		if err := c.writeCheckpoint(out, true); err != nil {
+			return nil, fmt.Errorf("failed to start audit log chain: %w", err)
+		}
+	}
+
+	log.Sequence = c.sequence + 1
+	log.PreviousHash = c.previousHash
+
+	entry, err := encodeRecord(log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.append(out, entry); err != nil {
+		return nil, fmt.Errorf("failed to write log: %w", err)
+	}
+
+	c.sinceCheckpoint++
+	if c.CheckpointInterval > 0 && c.sinceCheckpoint >= c.CheckpointInterval {
+		if err := c.writeCheckpoint(out, false); err != nil {
+			return entry, fmt.Errorf("failed to write audit log checkpoint: %w", err)
+		}
+	}
+
+	return entry, nil
+}
+
+// checkpoint writes a checkpoint covering every record written so far.
+func (c *chain) checkpoint(out io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sequence == 0 || c.sinceCheckpoint == 0 {
+		return nil
+	}
+
+	return c.writeCheckpoint(out, false)
+}
+
+func (c *chain) writeCheckpoint(out io.Writer, start bool) error {
+	cp := checkpoint{
+		Sequence:     c.sequence + 1,
+		PreviousHash: c.previousHash,
+		Checkpoint: checkpointData{
+			Timestamp:  c.now().UTC().Format(time.RFC3339Nano),
+			ChainStart: start,
+			KeyID:      c.KeyID,
+		},
+	}
+
+	if c.Signer != nil {
+		signature, err := signPayload(c.Signer, cp.payload())
+		if err != nil {
+			return fmt.Errorf("failed to sign checkpoint: %w", err)
+		}
+		cp.Checkpoint.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+
+	entry, err := encodeRecord(cp)
+	if err != nil {
+		return err
+	}
+
+	if err := c.append(out, entry); err != nil {
+		return err
+	}
+
+	c.sinceCheckpoint = 0
+
+	return nil
+}
+
+// append writes a single record and only advances the chain if the write succeeds, so a failed write is not reported
+// as a gap by the verifier.
+func (c *chain) append(out io.Writer, entry []byte) error {
+	line := make([]byte, 0, len(entry)+1)
+	line = append(line, entry...)
+	line = append(line, '\n')
+
+	if _, err := out.Write(line); err != nil {
+		return err
+	}
+
+	c.sequence++
+	c.previousHash = hashRecord(entry)
+
+	return nil
+}
+
+// ChainIssueType describes how a chain of audit log records was found to be broken.
+type ChainIssueType string
+
+const (
+	// ChainIssueGap indicates one or more records are missing.
+	ChainIssueGap ChainIssueType = "Gap"
+
+	// ChainIssueReordered indicates a record appears after a record with a higher sequence number.
+	ChainIssueReordered ChainIssueType = "Reordered"
+
+	// ChainIssueModified indicates a record, or the record before it, was changed after it was written.
+	ChainIssueModified ChainIssueType = "Modified"
+
+	// ChainIssueInvalidRecord indicates a line could not be parsed as a chained record.
+	ChainIssueInvalidRecord ChainIssueType = "InvalidRecord"
+
+	// ChainIssueInvalidSignature indicates a checkpoint is unsigned or its signature could not be verified.
+	ChainIssueInvalidSignature ChainIssueType = "InvalidSignature"
+)
+
+// ChainIssue is a single problem found while verifying a chain.
+type ChainIssue struct {
+	Line     int            `json:"line"`
+	Sequence uint64         `json:"sequence,omitempty"`
+	Type     ChainIssueType `json:"type"`
+	Message  string         `json:"message"`
+}
+
+// ChainReport is the result of verifying a stream of chained audit log records.
+type ChainReport struct {
+	Records     int `json:"records"`
+	Checkpoints int `json:"checkpoints"`
+
+	// Unanchored is the number of records after the last valid checkpoint. These records are linked to the chain but
+	// truncating them can not be detected.
+	Unanchored int `json:"unanchored"`
+
+	Issues []ChainIssue `json:"issues,omitempty"`
+}
+
+// Valid returns true if no issues were found.
+func (r ChainReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+type chainRecord struct {
+	Sequence     uint64          `json:"sequence"`
+	PreviousHash string          `json:"previousHash"`
+	Checkpoint   *checkpointData `json:"checkpoint"`
+}
+
+// VerifyChain walks a stream of audit log records written with hash chaining enabled and reports any gaps,
+// reorderings or modified records. Rotated log files should be concatenated in the order they were written. If
+// publicKey is not nil every checkpoint must carry a valid signature from the matching private key.
+func VerifyChain(r io.Reader, publicKey crypto.PublicKey) (ChainReport, error) {
+	var report ChainReport
+
+	hashes := make(map[uint64]string, chainHashWindow)
+	var expected uint64
+	lineNumber := 0
+
+	issue := func(seq uint64, t ChainIssueType, format string, args ...any) {
+		report.Issues = append(report.Issues, ChainIssue{
+			Line:     lineNumber,
+			Sequence: seq,
+			Type:     t,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return report, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		atEOF := err == io.EOF
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			lineNumber++
+
+			var record chainRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				issue(0, ChainIssueInvalidRecord, "failed to parse record: %s", err)
+			} else if record.Sequence == 0 {
+				issue(0, ChainIssueInvalidRecord, "record has no sequence number")
+			} else {
+				hash := hashRecord(line)
+				isStart := record.Checkpoint != nil && record.Checkpoint.ChainStart
+
+				switch {
+				case isStart:
+					// A new chain is started each time the writer starts.
+					if record.PreviousHash != "" {
+						issue(record.Sequence, ChainIssueModified, "chain start has a previous hash")
+					}
+					clear(hashes)
+					expected = record.Sequence
+				case expected == 0:
+					// The stream starts part way through a chain, for example with a rotated log file, so the first
+					// record is trusted as the anchor.
+					expected = record.Sequence
+					hashes[record.Sequence-1] = record.PreviousHash
+				}
+
+				switch {
+				case record.Sequence > expected:
+					issue(record.Sequence, ChainIssueGap, "missing records %d to %d", expected, record.Sequence-1)
+				case record.Sequence < expected:
+					issue(record.Sequence, ChainIssueReordered, "record appears after record %d", expected-1)
+				}
+
+				if previous, ok := hashes[record.Sequence-1]; ok && !isStart && previous != record.PreviousHash {
+					issue(record.Sequence, ChainIssueModified, "record %d was modified or this record's link was altered", record.Sequence-1)
+				}
+
+				hashes[record.Sequence] = hash
+				delete(hashes, record.Sequence-chainHashWindow)
+				if record.Sequence >= expected {
+					expected = record.Sequence + 1
+				}
+
+				if record.Checkpoint != nil {
+					report.Checkpoints++
+					if verifyCheckpoint(record, publicKey, issue) {
+						report.Unanchored = 0
+					}
+				} else {
+					report.Records++
+					report.Unanchored++
+				}
+			}
+		}
+
+		if atEOF {
+			break
+		}
+	}
+
+	return report, nil
+}
+
+func verifyCheckpoint(record chainRecord, publicKey crypto.PublicKey, issue func(uint64, ChainIssueType, string, ...any)) bool {
+	if publicKey == nil {
+		return true
+	}
+
+	if record.Checkpoint.Signature == "" {
+		issue(record.Sequence, ChainIssueInvalidSignature, "checkpoint is not signed")
+		return false
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(record.Checkpoint.Signature)
+	if err != nil {
+		issue(record.Sequence, ChainIssueInvalidSignature, "failed to decode checkpoint signature: %s", err)
+		return false
+	}
+
+	cp := checkpoint{
+		Sequence:     record.Sequence,
+		PreviousHash: record.PreviousHash,
+		Checkpoint:   *record.Checkpoint,
+	}
+
+	if err := verifyPayload(publicKey, cp.payload(), signature); err != nil {
+		issue(record.Sequence, ChainIssueInvalidSignature, "invalid checkpoint signature: %s", err)
+		return false
+	}
+
+	return true
+}
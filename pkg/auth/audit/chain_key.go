@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	CheckpointKeySecretNamespace = "cattle-system"
+	CheckpointKeySecretName      = "audit-log-signing-key"
+
+	defaultCheckpointKeyID = "key"
+)
+
+// CheckpointSignerFromSecret returns the key used to sign audit log checkpoints and its key id. Keys are stored in
+// the audit-log-signing-key secret in the cattle-system namespace as a PKCS8 encoded private key (<kid>.pem) and its
+// public key (<kid>.pub). Ed25519, ECDSA and RSA keys are supported. If the secret holds several keys the one with the
+// greatest kid is used, so every replica signs with the same key and keys can be rotated by adding one with a greater
+// kid. If the secret does not exist it is created with a new Ed25519 key, unless another replica created it first.
+func CheckpointSignerFromSecret(secretClient corecontrollers.SecretClient) (crypto.Signer, string, error) {
+	secret, err := secretClient.Get(CheckpointKeySecretNamespace, CheckpointKeySecretName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, "", fmt.Errorf("failed to get audit log signing key: %w", err)
+	}
+
+	if errors.IsNotFound(err) {
+		logrus.Infof("Creating a new audit log signing key")
+
+		secret, err = newCheckpointKeySecret()
+		if err != nil {
+			return nil, "", err
+		}
+
+		secret, err = secretClient.Create(secret)
+		if errors.IsAlreadyExists(err) {
+			secret, err = secretClient.Get(CheckpointKeySecretNamespace, CheckpointKeySecretName, metav1.GetOptions{})
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create audit log signing key: %w", err)
+		}
+	}
+
+	var keyIDs []string
+	for name := range secret.Data {
+		if keyID, ok := strings.CutSuffix(name, ".pem"); ok {
+			keyIDs = append(keyIDs, keyID)
+		}
+	}
+	if len(keyIDs) == 0 {
+		return nil, "", fmt.Errorf("audit log signing key not found in secret %s/%s", CheckpointKeySecretNamespace, CheckpointKeySecretName)
+	}
+
+	keyID := slices.Max(keyIDs)
+	signer, err := ParseCheckpointSigningKey(secret.Data[keyID+".pem"])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return signer, keyID, nil
+}
+
+func newCheckpointKeySecret() (*corev1.Secret, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate audit log signing key: %w", err)
+	}
+
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CheckpointKeySecretName,
+			Namespace: CheckpointKeySecretNamespace,
+		},
+		Data: map[string][]byte{
+			defaultCheckpointKeyID + ".pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER}),
+			defaultCheckpointKeyID + ".pub": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}),
+		},
+	}, nil
+}
+
+// ParseCheckpointSigningKey parses a PEM encoded PKCS8 private key used to sign checkpoints.
+func ParseCheckpointSigningKey(privateKeyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("failed to decode PEM block containing private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// ParseCheckpointPublicKey parses a PEM encoded PKIX public key used to verify checkpoints.
+func ParseCheckpointPublicKey(publicKeyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("failed to decode PEM block containing public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return key, nil
+}
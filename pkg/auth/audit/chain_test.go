@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func writeChain(t *testing.T, opts ChainOptions, n int) []string {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	opts.Enabled = true
+
+	w, err := NewWriter(&buffer, WriterOptions{
+		DefaultPolicyLevel:     auditlogv1.LevelNull,
+		DisableDefaultPolicies: true,
+		Chain:                  opts,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, w.Write(&log{RequestURI: fmt.Sprintf("/api/%d", i)}))
+	}
+	require.NoError(t, w.chain.checkpoint(&buffer))
+
+	return strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+}
+
+func verifyLines(t *testing.T, lines []string, opts ChainOptions) ChainReport {
+	t.Helper()
+
+	var publicKey any
+	if opts.Signer != nil {
+		publicKey = opts.Signer.Public()
+	}
+
+	report, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")+"\n"), publicKey)
+	require.NoError(t, err)
+
+	return report
+}
+
+func issueTypes(report ChainReport) []ChainIssueType {
+	var types []ChainIssueType
+	for _, i := range report.Issues {
+		types = append(types, i.Type)
+	}
+	return types
+}
+
+func TestChainRecords(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	opts := ChainOptions{CheckpointInterval: 2, Signer: key, KeyID: "key"}
+	lines := writeChain(t, opts, 3)
+
+	// chain start, 2 records, checkpoint, 1 record, final checkpoint
+	require.Len(t, lines, 6)
+
+	var first, second log
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &second))
+
+	assert.Equal(t, uint64(2), first.Sequence)
+	assert.Equal(t, hashRecord([]byte(lines[0])), first.PreviousHash)
+	assert.Equal(t, uint64(3), second.Sequence)
+	assert.Equal(t, hashRecord([]byte(lines[1])), second.PreviousHash)
+
+	report := verifyLines(t, lines, opts)
+	assert.True(t, report.Valid(), report.Issues)
+	assert.Equal(t, 3, report.Records)
+	assert.Equal(t, 3, report.Checkpoints)
+	assert.Equal(t, 0, report.Unanchored)
+}
+
+func TestVerifyChain(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	opts := ChainOptions{CheckpointInterval: 10, Signer: key}
+
+	cases := []struct {
+		name     string
+		tamper   func(lines []string) []string
+		expected []ChainIssueType
+	}{
+		{
+			name: "removed record",
+			tamper: func(lines []string) []string {
+				return append(append([]string{}, lines[:2]...), lines[3:]...)
+			},
+			expected: []ChainIssueType{ChainIssueGap},
+		},
+		{
+			name: "reordered records",
+			tamper: func(lines []string) []string {
+				lines[2], lines[3] = lines[3], lines[2]
+				return lines
+			},
+			expected: []ChainIssueType{ChainIssueGap, ChainIssueReordered},
+		},
+		{
+			name: "modified record",
+			tamper: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], "/api/1", "/api/x", 1)
+				return lines
+			},
+			expected: []ChainIssueType{ChainIssueModified},
+		},
+		{
+			name: "modified checkpoint",
+			tamper: func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], `"timestamp":"`, `"timestamp":"1`, 1)
+				return lines
+			},
+			expected: []ChainIssueType{ChainIssueInvalidSignature, ChainIssueModified},
+		},
+		{
+			name: "invalid record",
+			tamper: func(lines []string) []string {
+				lines[2] = "not json"
+				return lines
+			},
+			expected: []ChainIssueType{ChainIssueInvalidRecord, ChainIssueGap},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lines := c.tamper(writeChain(t, opts, 4))
+			report := verifyLines(t, lines, opts)
+			assert.Equal(t, c.expected, issueTypes(report))
+		})
+	}
+}
+
+func TestVerifyChainRotatedFile(t *testing.T) {
+	lines := writeChain(t, ChainOptions{}, 5)
+
+	// A rotated file starts part way through the chain.
+	report := verifyLines(t, lines[3:], ChainOptions{})
+	assert.True(t, report.Valid(), report.Issues)
+	assert.Equal(t, 3, report.Records)
+}
+
+func TestVerifyChainUnsignedCheckpoints(t *testing.T) {
+	lines := writeChain(t, ChainOptions{}, 1)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	report, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")), key.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []ChainIssueType{ChainIssueInvalidSignature, ChainIssueInvalidSignature}, issueTypes(report))
+	assert.Equal(t, 1, report.Unanchored)
+}
+
+func TestCheckpointKeys(t *testing.T) {
+	secret, err := newCheckpointKeySecret()
+	require.NoError(t, err)
+
+	signer, err := ParseCheckpointSigningKey(secret.Data[defaultCheckpointKeyID+".pem"])
+	require.NoError(t, err)
+
+	publicKey, err := ParseCheckpointPublicKey(secret.Data[defaultCheckpointKeyID+".pub"])
+	require.NoError(t, err)
+
+	payload := []byte("payload")
+	signature, err := signPayload(signer, payload)
+	require.NoError(t, err)
+	assert.NoError(t, verifyPayload(publicKey, payload, signature))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+
+	signer, err = ParseCheckpointSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	signature, err = signPayload(signer, payload)
+	require.NoError(t, err)
+	assert.NoError(t, verifyPayload(ecKey.Public(), payload, signature))
+	assert.Error(t, verifyPayload(publicKey, payload, signature))
+}
+
+func TestCheckpointSignerFromSecret(t *testing.T) {
+	existing, err := newCheckpointKeySecret()
+	require.NoError(t, err)
+	rotated, err := newCheckpointKeySecret()
+	require.NoError(t, err)
+	existing.Data["key-2.pem"] = rotated.Data[defaultCheckpointKeyID+".pem"]
+	existing.Data["key-2.pub"] = rotated.Data[defaultCheckpointKeyID+".pub"]
+
+	ctrl := gomock.NewController(t)
+	secrets := fake.NewMockClientInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	notFound := apierrors.NewNotFound(corev1.Resource("secrets"), CheckpointKeySecretName)
+	gomock.InOrder(
+		secrets.EXPECT().Get(CheckpointKeySecretNamespace, CheckpointKeySecretName, gomock.Any()).Return(nil, notFound),
+		// another replica created the secret first
+		secrets.EXPECT().Create(gomock.Any()).Return(nil, apierrors.NewAlreadyExists(corev1.Resource("secrets"), CheckpointKeySecretName)),
+		secrets.EXPECT().Get(CheckpointKeySecretNamespace, CheckpointKeySecretName, gomock.Any()).Return(existing, nil),
+	)
+
+	signer, keyID, err := CheckpointSignerFromSecret(secrets)
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", keyID, "the key with the greatest kid is used")
+
+	publicKey, err := ParseCheckpointPublicKey(existing.Data["key-2.pub"])
+	require.NoError(t, err)
+	signature, err := signPayload(signer, []byte("payload"))
+	require.NoError(t, err)
+	assert.NoError(t, verifyPayload(publicKey, []byte("payload"), signature))
+}
@@ -22,6 +22,7 @@
 	sensitiveBodyFields = []string{
 		"credentials", "applicationSecret", "oauthCredential", "serviceAccountCredential", "spKey", "spCert", "certificate", "privateKey", "secretsEncryptionConfig", "manifestUrl",
 		"insecureWindowsNodeCommand", "insecureNodeCommand", "insecureCommand", "command", "nodeCommand", "windowsNodeCommand", "clientRandom",
+		"mfaCode", "mfaSecret", "mfaKeyUri", "recoveryCodes",
 	}
 )
 
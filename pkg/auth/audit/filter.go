@@ -2,14 +2,28 @@
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"regexp"
+	"sync"
+	"time"
 
 	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRateLimitBuckets bounds the number of keys tracked by a single ratelimit filter. Once reached, buckets that
+	// have not been used for rateLimitBucketIdle are removed.
+	maxRateLimitBuckets = 10000
+	rateLimitBucketIdle = time.Minute * 10
 )
 
 type Filter struct {
 	action auditlogv1.FilterAction
 	uri    *regexp.Regexp
+
+	sampler *sampler
+	limiter *rateLimiter
 }
 
 func NewFilter(filter auditlogv1.Filter) (*Filter, error) {
@@ -18,16 +32,201 @@ func NewFilter(filter auditlogv1.Filter) (*Filter, error) {
 		return nil, fmt.Errorf("failed to compile regex '%s': %w", filter.RequestURI, err)
 	}
 
-	return &Filter{
+	f := &Filter{
 		action: filter.Action,
 		uri:    compiled,
-	}, nil
+	}
+
+	switch filter.Action {
+	case auditlogv1.FilterActionSample:
+		if f.sampler, err = newSampler(filter.Sample); err != nil {
+			return nil, err
+		}
+	case auditlogv1.FilterActionRateLimit:
+		if f.limiter, err = newRateLimiter(filter.RateLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (m *Filter) Matches(log *log) bool {
+	return m.uri.MatchString(log.RequestURI)
 }
 
 func (m *Filter) Allowed(log *log) bool {
-	if m.uri.MatchString(log.RequestURI) {
+	if m.Matches(log) {
 		return m.action == auditlogv1.FilterActionAllow
 	}
 
 	return false
 }
+
+// keep decides whether a log matched by a sample or ratelimit filter is kept, and if so how many logs this filter
+// suppressed since it last kept one.
+func (m *Filter) keep(log *log) (bool, uint64) {
+	switch {
+	case m.sampler != nil:
+		return m.sampler.keep()
+	case m.limiter != nil:
+		return m.limiter.keep(log)
+	default:
+		return true, 0
+	}
+}
+
+type sampler struct {
+	oneIn      uint64
+	percentage int
+
+	mu         sync.Mutex
+	count      uint64
+	suppressed uint64
+}
+
+func newSampler(sample *auditlogv1.Sample) (*sampler, error) {
+	if sample == nil {
+		return nil, fmt.Errorf("sample action requires sample to be set")
+	}
+
+	switch {
+	case sample.OneIn > 0 && sample.Percentage > 0:
+		return nil, fmt.Errorf("sample must set only one of oneIn or percentage")
+	case sample.OneIn > 0:
+		return &sampler{oneIn: uint64(sample.OneIn)}, nil
+	case sample.Percentage > 0 && sample.Percentage <= 100:
+		return &sampler{percentage: sample.Percentage}, nil
+	default:
+		return nil, fmt.Errorf("sample must set oneIn to a positive number or percentage between 1 and 100")
+	}
+}
+
+func (s *sampler) keep() (bool, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keep bool
+	if s.oneIn > 0 {
+		keep = s.count%s.oneIn == 0
+		s.count++
+	} else {
+		keep = rand.IntN(100) < s.percentage
+	}
+
+	if !keep {
+		s.suppressed++
+		return false, 0
+	}
+
+	suppressed := s.suppressed
+	s.suppressed = 0
+
+	return true, suppressed
+}
+
+type rateLimitBucket struct {
+	limiter    *rate.Limiter
+	suppressed uint64
+	lastSeen   time.Time
+}
+
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+	key   auditlogv1.RateLimitKey
+
+	mu      sync.Mutex
+	buckets map[string]*rateLimitBucket
+
+	now func() time.Time
+}
+
+func newRateLimiter(limit *auditlogv1.RateLimit) (*rateLimiter, error) {
+	if limit == nil {
+		return nil, fmt.Errorf("ratelimit action requires rateLimit to be set")
+	}
+
+	if limit.EventsPerSecond <= 0 {
+		return nil, fmt.Errorf("rateLimit eventsPerSecond must be a positive number")
+	}
+
+	switch limit.Key {
+	case auditlogv1.RateLimitKeyNone, auditlogv1.RateLimitKeyUser, auditlogv1.RateLimitKeyRequestURI,
+		auditlogv1.RateLimitKeyUserAndRequestURI, auditlogv1.RateLimitKeyUserAndMethod:
+	default:
+		return nil, fmt.Errorf("invalid rateLimit key: '%s'", limit.Key)
+	}
+
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.EventsPerSecond
+	}
+
+	return &rateLimiter{
+		limit:   rate.Limit(limit.EventsPerSecond),
+		burst:   burst,
+		key:     limit.Key,
+		buckets: make(map[string]*rateLimitBucket),
+		now:     time.Now,
+	}, nil
+}
+
+func (r *rateLimiter) keyForLog(log *log) string {
+	user := log.UserLoginName
+	if log.User != nil && log.User.Name != "" {
+		user = log.User.Name
+	}
+
+	switch r.key {
+	case auditlogv1.RateLimitKeyUser:
+		return user
+	case auditlogv1.RateLimitKeyRequestURI:
+		return log.RequestURI
+	case auditlogv1.RateLimitKeyUserAndRequestURI:
+		return user + " " + log.RequestURI
+	case auditlogv1.RateLimitKeyUserAndMethod:
+		return user + " " + log.Method
+	default:
+		return ""
+	}
+}
+
+func (r *rateLimiter) keep(log *log) (bool, uint64) {
+	key := r.keyForLog(log)
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxRateLimitBuckets {
+			r.prune(now)
+		}
+
+		bucket = &rateLimitBucket{
+			limiter: rate.NewLimiter(r.limit, r.burst),
+		}
+		r.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+
+	if !bucket.limiter.AllowN(now, 1) {
+		bucket.suppressed++
+		return false, 0
+	}
+
+	suppressed := bucket.suppressed
+	bucket.suppressed = 0
+
+	return true, suppressed
+}
+
+func (r *rateLimiter) prune(now time.Time) {
+	for key, bucket := range r.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimitBucketIdle {
+			delete(r.buckets, key)
+		}
+	}
+}
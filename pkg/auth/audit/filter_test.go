@@ -3,9 +3,11 @@
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilter(t *testing.T) {
@@ -60,3 +62,78 @@ type testCase struct {
 		})
 	}
 }
+
+func TestSampleFilter(t *testing.T) {
+	f, err := NewFilter(auditlogv1.Filter{
+		Action:     auditlogv1.FilterActionSample,
+		RequestURI: ".*",
+		Sample:     &auditlogv1.Sample{OneIn: 3},
+	})
+	require.NoError(t, err)
+
+	var kept []uint64
+	for i := 0; i < 7; i++ {
+		if keep, suppressed := f.keep(&log{}); keep {
+			kept = append(kept, suppressed)
+		}
+	}
+
+	assert.Equal(t, []uint64{0, 2, 2}, kept)
+
+	// A sample filter never allows a log on its own.
+	assert.False(t, f.Allowed(&log{}))
+}
+
+func TestRateLimitFilter(t *testing.T) {
+	f, err := NewFilter(auditlogv1.Filter{
+		Action:     auditlogv1.FilterActionRateLimit,
+		RequestURI: ".*",
+		RateLimit: &auditlogv1.RateLimit{
+			EventsPerSecond: 1,
+			Burst:           2,
+			Key:             auditlogv1.RateLimitKeyUser,
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	f.limiter.now = func() time.Time { return now }
+
+	alice := &log{User: &User{Name: "alice"}}
+	bob := &log{User: &User{Name: "bob"}}
+
+	keep := func(l *log) bool {
+		k, _ := f.keep(l)
+		return k
+	}
+
+	assert.True(t, keep(alice))
+	assert.True(t, keep(alice))
+	assert.False(t, keep(alice))
+	assert.False(t, keep(alice))
+
+	// Each user has their own bucket.
+	assert.True(t, keep(bob))
+
+	now = now.Add(time.Second)
+	k, suppressed := f.keep(alice)
+	assert.True(t, k)
+	assert.Equal(t, uint64(2), suppressed)
+}
+
+func TestInvalidThrottleFilters(t *testing.T) {
+	cases := []auditlogv1.Filter{
+		{Action: auditlogv1.FilterActionSample},
+		{Action: auditlogv1.FilterActionSample, Sample: &auditlogv1.Sample{}},
+		{Action: auditlogv1.FilterActionSample, Sample: &auditlogv1.Sample{OneIn: 2, Percentage: 50}},
+		{Action: auditlogv1.FilterActionSample, Sample: &auditlogv1.Sample{Percentage: 101}},
+		{Action: auditlogv1.FilterActionRateLimit},
+		{Action: auditlogv1.FilterActionRateLimit, RateLimit: &auditlogv1.RateLimit{}},
+		{Action: auditlogv1.FilterActionRateLimit, RateLimit: &auditlogv1.RateLimit{EventsPerSecond: 1, Key: "group"}},
+	}
+
+	for _, c := range cases {
+		_, err := NewFilter(c)
+		assert.Error(t, err, c)
+	}
+}
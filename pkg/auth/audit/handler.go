@@ -11,6 +11,7 @@
 	"sync"
 	"time"
 
+	"github.com/rancher/rancher/pkg/auth/audit/annotations"
 	"github.com/sirupsen/logrus"
 )
 
@@ -52,6 +53,7 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	user := getUserInfo(req)
 
 	context := context.WithValue(req.Context(), userKeyValue, user)
+	context, recorded := annotations.WithAnnotations(context)
 	req = req.WithContext(context)
 
 	wr := &wrapWriter{
@@ -66,6 +68,7 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	respTimestamp := time.Now().Format(time.RFC3339)
 
 	log := newLog(user, req, wr, reqTimestamp, respTimestamp, rawBody, userName)
+	log.Annotations = recorded.Get()
 
 	if err := h.writer.Write(log); err != nil {
 		// Locking after next is called to avoid performance hits on the request.
@@ -8,6 +8,7 @@
 	"strings"
 	"testing"
 
+	"github.com/rancher/rancher/pkg/auth/audit/annotations"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -81,3 +82,22 @@ func TestMiddleware(t *testing.T) {
 
 	assert.Len(t, requests, 1, "handler did not forward request to next handler as expected")
 }
+
+func TestMiddlewareAnnotations(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		annotations.Add(req.Context(), "example.cattle.io/reason", "locked out")
+	})
+	out := &strings.Builder{}
+	writer, err := NewWriter(out, WriterOptions{})
+	require.NoError(t, err)
+
+	NewAuditLogMiddleware(writer)(next).ServeHTTP(&response{
+		header: http.Header{},
+		body:   bytes.NewBuffer(nil),
+	}, getRequest())
+
+	assert.Contains(t, out.String(), `"annotations":{"example.cattle.io/reason":"locked out"}`)
+
+	// Requests that aren't audited are ignored.
+	annotations.Add(context.Background(), "example.cattle.io/reason", "locked out")
+}
@@ -70,6 +70,9 @@ func decompress(readCloser io.ReadCloser) ([]byte, error) {
 }
 
 type log struct {
+	Sequence     uint64 `json:"sequence,omitempty"`
+	PreviousHash string `json:"previousHash,omitempty"`
+
 	AuditID       k8stypes.UID `json:"auditID,omitempty"`
 	RequestURI    string       `json:"requestURI,omitempty"`
 	User          *User        `json:"user,omitempty"`
@@ -87,6 +90,12 @@ type log struct {
 	RequestBody  map[string]any `json:"requestBody,omitempty"`
 	ResponseBody map[string]any `json:"responseBody,omitempty"`
 
+	// Suppressed is the number of similar logs dropped by a sample or ratelimit filter since this log was kept.
+	Suppressed uint64 `json:"suppressed,omitempty"`
+
+	// Annotations are recorded by the handlers of the request with annotations.Add.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
 	rawRequestBody  []byte
 	rawResponseBody []byte
 }
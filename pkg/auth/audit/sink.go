@@ -0,0 +1,307 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second * 5
+	defaultSinkMaxRetries    = 3
+	defaultSinkQueueSize     = 1000
+
+	sinkRetryBaseDelay = time.Millisecond * 500
+	sinkCloseTimeout   = time.Second * 10
+)
+
+var (
+	ErrSinkFull   = fmt.Errorf("audit log sink is full")
+	ErrSinkClosed = fmt.Errorf("audit log sink is closed")
+)
+
+// sinkTarget delivers batches of encoded logs to a remote destination. Delivery is at-least-once, so a target may
+// receive the same log more than once if a batch is retried after a partial failure.
+type sinkTarget interface {
+	send(ctx context.Context, entries [][]byte) error
+	close() error
+}
+
+type sinkOptions struct {
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	queueSize     int
+}
+
+func sinkOptionsFromDelivery(delivery auditlogv1.SinkDelivery) sinkOptions {
+	opts := sinkOptions{
+		batchSize:     defaultSinkBatchSize,
+		flushInterval: defaultSinkFlushInterval,
+		maxRetries:    defaultSinkMaxRetries,
+		queueSize:     defaultSinkQueueSize,
+	}
+
+	if delivery.BatchSize > 0 {
+		opts.batchSize = delivery.BatchSize
+	}
+	if delivery.FlushInterval != nil && delivery.FlushInterval.Duration > 0 {
+		opts.flushInterval = delivery.FlushInterval.Duration
+	}
+	if delivery.MaxRetries > 0 {
+		opts.maxRetries = delivery.MaxRetries
+	}
+	if delivery.QueueSize > 0 {
+		opts.queueSize = delivery.QueueSize
+	}
+
+	return opts
+}
+
+// sink queues logs in memory and delivers them to its target in batches. When the target is unavailable logs are
+// moved to an on-disk spool. Requests are never held up by a sink: when both the queue and spool are full the log is
+// reported as failed instead.
+type sink struct {
+	name   string
+	spec   auditlogv1.Sink
+	target sinkTarget
+	opts   sinkOptions
+	spool  *spool
+
+	queue  chan []byte
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newSink(name string, target sinkTarget, opts sinkOptions, spool *spool) *sink {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &sink{
+		name:   name,
+		target: target,
+		opts:   opts,
+		spool:  spool,
+
+		queue:  make(chan []byte, opts.queueSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go s.run(ctx)
+
+	return s
+}
+
+func (s *sink) enqueue(entry []byte) error {
+	select {
+	case <-s.done:
+		return fmt.Errorf("%w: '%s'", ErrSinkClosed, s.name)
+	default:
+	}
+
+	// Once anything is spooled, keep spooling so logs are delivered in order.
+	if s.spool == nil || s.spool.empty() {
+		select {
+		case s.queue <- entry:
+			return nil
+		default:
+		}
+	}
+
+	if s.spool != nil {
+		err := s.spool.append(entry)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errSpoolFull) {
+			return fmt.Errorf("failed to spool log for sink '%s': %w", s.name, err)
+		}
+	}
+
+	return fmt.Errorf("%w: '%s'", ErrSinkFull, s.name)
+}
+
+func (s *sink) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, s.opts.batchSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.shutdown(batch)
+			return
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) < s.opts.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			s.drainSpool(ctx)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		s.deliver(ctx, batch)
+		batch = make([][]byte, 0, s.opts.batchSize)
+	}
+}
+
+func (s *sink) drainSpool(ctx context.Context) {
+	if s.spool == nil || s.spool.empty() {
+		return
+	}
+
+	if err := s.spool.drain(s.opts.batchSize, func(entries [][]byte) error {
+		return s.target.send(ctx, entries)
+	}); err != nil {
+		logrus.Debugf("Failed to deliver spooled audit logs to sink '%s': %s", s.name, err)
+	}
+}
+
+// deliver sends a batch to the target, retrying with an exponential backoff before falling back to the spool.
+func (s *sink) deliver(ctx context.Context, batch [][]byte) {
+	var err error
+	delay := sinkRetryBaseDelay
+
+	for attempt := 0; attempt <= s.opts.maxRetries; attempt++ {
+		if err = s.target.send(ctx, batch); err == nil {
+			return
+		}
+
+		if attempt == s.opts.maxRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			attempt = s.opts.maxRetries
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+
+	s.spoolOrReport(batch, err)
+}
+
+func (s *sink) spoolOrReport(batch [][]byte, cause error) {
+	if s.spool != nil {
+		spoolErr := s.spool.append(batch...)
+		if spoolErr == nil {
+			return
+		}
+		cause = errors.Join(cause, spoolErr)
+	}
+
+	logrus.Errorf("Failed to deliver %d audit logs to sink '%s': %s", len(batch), s.name, cause)
+}
+
+// shutdown moves everything still in memory to the spool so it can be delivered after a restart. Without a spool a
+// final delivery attempt is made.
+func (s *sink) shutdown(batch [][]byte) {
+	for len(s.queue) > 0 {
+		batch = append(batch, <-s.queue)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	if s.spool != nil {
+		if err := s.spool.append(batch...); err == nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkCloseTimeout)
+	defer cancel()
+
+	if err := s.target.send(ctx, batch); err != nil {
+		logrus.Errorf("Failed to deliver %d audit logs to sink '%s' on shutdown: %s", len(batch), s.name, err)
+	}
+}
+
+func (s *sink) close() error {
+	s.cancel()
+	<-s.done
+
+	return s.target.close()
+}
+
+// validateSink checks the spec of a sink without creating its target, which would open connections, so that invalid
+// sinks are reported when the policy is created rather than when the first log is sent.
+func validateSink(sink auditlogv1.Sink) error {
+	if err := validateSinkType(sink); err != nil {
+		return err
+	}
+
+	switch {
+	case sink.Syslog != nil:
+		return validateSyslogSink(*sink.Syslog)
+	case sink.Webhook != nil:
+		if err := validateSinkURL(sink.Webhook.URL, sink.Webhook.TLS); err != nil {
+			return fmt.Errorf("invalid webhook sink: %w", err)
+		}
+	default:
+		if err := validateSinkURL(sink.OTLP.Endpoint, sink.OTLP.TLS); err != nil {
+			return fmt.Errorf("invalid otlp sink: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateSinkType(sink auditlogv1.Sink) error {
+	set := 0
+	for _, isSet := range []bool{sink.Syslog != nil, sink.Webhook != nil, sink.OTLP != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("sink '%s' must set exactly one of syslog, webhook or otlp", sink.Name)
+	}
+	return nil
+}
+
+func newSinkTarget(sink auditlogv1.Sink) (sinkTarget, error) {
+	if err := validateSinkType(sink); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case sink.Syslog != nil:
+		return newSyslogTarget(*sink.Syslog)
+	case sink.Webhook != nil:
+		return newWebhookTarget(*sink.Webhook)
+	default:
+		return newOTLPTarget(*sink.OTLP)
+	}
+}
+
+func tlsConfigForSink(config auditlogv1.SinkTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.CABundle)) {
+			return nil, fmt.Errorf("failed to parse CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
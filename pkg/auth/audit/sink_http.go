@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+)
+
+const (
+	sinkHTTPTimeout = time.Second * 30
+
+	otlpLogsPath       = "/v1/logs"
+	otlpScopeName      = "rancher.audit"
+	otlpServiceName    = "rancher"
+	otlpSeverityInfo   = 9
+	otlpSeverityText   = "INFO"
+	otlpAuditIDKey     = "audit.id"
+	otlpServiceNameKey = "service.name"
+)
+
+func parseSinkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url '%s': %w", rawURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url '%s': scheme must be http or https", rawURL)
+	}
+	return u, nil
+}
+
+func validateSinkURL(rawURL string, config auditlogv1.SinkTLS) error {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "https" {
+		if _, err := tlsConfigForSink(config); err != nil {
+			return fmt.Errorf("invalid tls config: %w", err)
+		}
+	}
+	return nil
+}
+
+func newSinkHTTPClient(rawURL string, config auditlogv1.SinkTLS) (*http.Client, *url.URL, error) {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if u.Scheme == "https" {
+		tlsConfig, err := tlsConfigForSink(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid tls config: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, Timeout: sinkHTTPTimeout}, u, nil
+}
+
+// postJSON sends body to url and treats any non 2xx response as a failure so the batch is retried.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status from '%s': %s", url, resp.Status)
+	}
+
+	return nil
+}
+
+// webhookTarget posts batches of logs as a JSON array.
+type webhookTarget struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookTarget(spec auditlogv1.WebhookSink) (*webhookTarget, error) {
+	client, u, err := newSinkHTTPClient(spec.URL, spec.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook sink: %w", err)
+	}
+
+	return &webhookTarget{
+		url:     u.String(),
+		headers: spec.Headers,
+		client:  client,
+	}, nil
+}
+
+func (t *webhookTarget) send(ctx context.Context, entries [][]byte) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, e := range entries {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(e)
+	}
+	body.WriteByte(']')
+
+	return postJSON(ctx, t.client, t.url, t.headers, body.Bytes())
+}
+
+func (t *webhookTarget) close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpExportLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpTarget exports logs using the OTLP/HTTP protocol with JSON encoding. Each log is sent as the string body of a
+// log record so the original audit log can be recovered unchanged by the collector.
+type otlpTarget struct {
+	url                string
+	headers            map[string]string
+	resourceAttributes []otlpKeyValue
+	client             *http.Client
+}
+
+func newOTLPTarget(spec auditlogv1.OTLPSink) (*otlpTarget, error) {
+	client, u, err := newSinkHTTPClient(spec.Endpoint, spec.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp sink: %w", err)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpLogsPath
+	}
+
+	attributes := map[string]string{
+		otlpServiceNameKey: otlpServiceName,
+	}
+	for k, v := range spec.ResourceAttributes {
+		attributes[k] = v
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resourceAttributes := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		resourceAttributes = append(resourceAttributes, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: attributes[k]}})
+	}
+
+	return &otlpTarget{
+		url:                u.String(),
+		headers:            spec.Headers,
+		resourceAttributes: resourceAttributes,
+		client:             client,
+	}, nil
+}
+
+func (t *otlpTarget) request(entries [][]byte, now time.Time) otlpExportLogsRequest {
+	observed := strconv.FormatInt(now.UnixNano(), 10)
+
+	scope := otlpScopeLogs{
+		LogRecords: make([]otlpLogRecord, 0, len(entries)),
+	}
+	scope.Scope.Name = otlpScopeName
+
+	for _, e := range entries {
+		record := otlpLogRecord{
+			TimeUnixNano:         observed,
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       otlpSeverityInfo,
+			SeverityText:         otlpSeverityText,
+			Body:                 otlpAnyValue{StringValue: string(e)},
+		}
+
+		var meta struct {
+			AuditID          string `json:"auditID"`
+			RequestTimestamp string `json:"requestTimestamp"`
+		}
+		if err := json.Unmarshal(e, &meta); err == nil {
+			if ts, err := time.Parse(time.RFC3339, meta.RequestTimestamp); err == nil {
+				record.TimeUnixNano = strconv.FormatInt(ts.UnixNano(), 10)
+			}
+			if meta.AuditID != "" {
+				record.Attributes = []otlpKeyValue{{Key: otlpAuditIDKey, Value: otlpAnyValue{StringValue: meta.AuditID}}}
+			}
+		}
+
+		scope.LogRecords = append(scope.LogRecords, record)
+	}
+
+	resource := otlpResourceLogs{
+		ScopeLogs: []otlpScopeLogs{scope},
+	}
+	resource.Resource.Attributes = t.resourceAttributes
+
+	return otlpExportLogsRequest{
+		ResourceLogs: []otlpResourceLogs{resource},
+	}
+}
+
+func (t *otlpTarget) send(ctx context.Context, entries [][]byte) error {
+	body, err := json.Marshal(t.request(entries, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal otlp export request: %w", err)
+	}
+
+	return postJSON(ctx, t.client, t.url, t.headers, body)
+}
+
+func (t *otlpTarget) close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
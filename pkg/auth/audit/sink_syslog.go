@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+)
+
+const (
+	// syslogFacilityLogAudit is the "log audit" facility defined in RFC5424.
+	syslogFacilityLogAudit = 13
+	syslogSeverityInfo     = 6
+
+	syslogDefaultAppName = "rancher"
+	syslogMsgID          = "audit"
+
+	syslogDialTimeout  = time.Second * 10
+	syslogWriteTimeout = time.Second * 10
+)
+
+// syslogTarget writes each log as an RFC5424 message using octet counting framing (RFC6587) over a persistent TCP or
+// TLS connection. The connection is re-established on the next send after any write error.
+type syslogTarget struct {
+	address   string
+	tlsConfig *tls.Config
+
+	facility int
+	hostname string
+	appName  string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func validateSyslogSink(spec auditlogv1.SyslogSink) error {
+	if _, _, err := net.SplitHostPort(spec.Address); err != nil {
+		return fmt.Errorf("invalid syslog address '%s': %w", spec.Address, err)
+	}
+	if spec.Facility != nil && (*spec.Facility < 0 || *spec.Facility > 23) {
+		return fmt.Errorf("invalid syslog facility %d: must be between 0 and 23", *spec.Facility)
+	}
+	if spec.TLS.Enabled {
+		if _, err := tlsConfigForSink(spec.TLS); err != nil {
+			return fmt.Errorf("invalid syslog tls config: %w", err)
+		}
+	}
+	return nil
+}
+
+func newSyslogTarget(spec auditlogv1.SyslogSink) (*syslogTarget, error) {
+	if err := validateSyslogSink(spec); err != nil {
+		return nil, err
+	}
+
+	t := &syslogTarget{
+		address:  spec.Address,
+		facility: syslogFacilityLogAudit,
+		appName:  syslogDefaultAppName,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+
+	if spec.Facility != nil {
+		t.facility = *spec.Facility
+	}
+
+	if spec.AppName != "" {
+		t.appName = spec.AppName
+	}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		t.hostname = hostname
+	} else {
+		t.hostname = "-"
+	}
+
+	if spec.TLS.Enabled {
+		tlsConfig, err := tlsConfigForSink(spec.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog tls config: %w", err)
+		}
+		t.tlsConfig = tlsConfig
+	}
+
+	return t, nil
+}
+
+// format encodes a log as an RFC5424 message with the octet count prefix expected by RFC6587.
+func (t *syslogTarget) format(entry []byte, timestamp time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "<%d>1 %s %s %s %s %s - ",
+		t.facility*8+syslogSeverityInfo,
+		timestamp.UTC().Format(time.RFC3339Nano),
+		t.hostname,
+		t.appName,
+		t.procID,
+		syslogMsgID,
+	)
+	msg.Write(entry)
+
+	framed := make([]byte, 0, msg.Len()+8)
+	framed = strconv.AppendInt(framed, int64(msg.Len()), 10)
+	framed = append(framed, ' ')
+	framed = append(framed, msg.Bytes()...)
+
+	return framed
+}
+
+func (t *syslogTarget) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+
+	if t.tlsConfig != nil {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: t.tlsConfig}
+		return tlsDialer.DialContext(ctx, "tcp", t.address)
+	}
+
+	return dialer.DialContext(ctx, "tcp", t.address)
+}
+
+func (t *syslogTarget) send(ctx context.Context, entries [][]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		conn, err := t.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog server '%s': %w", t.address, err)
+		}
+		t.conn = conn
+	}
+
+	now := time.Now()
+
+	var buffer bytes.Buffer
+	for _, e := range entries {
+		buffer.Write(t.format(e, now))
+	}
+
+	if err := t.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)); err != nil {
+		t.resetConn()
+		return fmt.Errorf("failed to set syslog write deadline: %w", err)
+	}
+
+	if _, err := t.conn.Write(buffer.Bytes()); err != nil {
+		t.resetConn()
+		return fmt.Errorf("failed to write to syslog server '%s': %w", t.address, err)
+	}
+
+	return nil
+}
+
+func (t *syslogTarget) resetConn() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func (t *syslogTarget) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		return nil
+	}
+
+	err := t.conn.Close()
+	t.conn = nil
+
+	return err
+}
@@ -0,0 +1,471 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+type fakeTarget struct {
+	mu      sync.Mutex
+	fail    bool
+	batches [][][]byte
+}
+
+func (t *fakeTarget) send(_ context.Context, entries [][]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fail {
+		return fmt.Errorf("unavailable")
+	}
+
+	t.batches = append(t.batches, entries)
+
+	return nil
+}
+
+func (t *fakeTarget) close() error {
+	return nil
+}
+
+func (t *fakeTarget) setFail(fail bool) {
+	t.mu.Lock()
+	t.fail = fail
+	t.mu.Unlock()
+}
+
+func (t *fakeTarget) entries() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var entries []string
+	for _, b := range t.batches {
+		for _, e := range b {
+			entries = append(entries, string(e))
+		}
+	}
+
+	return entries
+}
+
+func TestSinkBatching(t *testing.T) {
+	target := &fakeTarget{}
+	s := newSink("test", target, sinkOptions{
+		batchSize:     2,
+		flushInterval: time.Hour,
+		maxRetries:    0,
+		queueSize:     10,
+	}, nil)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.enqueue([]byte(strconv.Itoa(i))))
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(target.entries()) == 4
+	}, time.Second, time.Millisecond*10)
+
+	target.mu.Lock()
+	assert.Len(t, target.batches, 2)
+	target.mu.Unlock()
+
+	require.NoError(t, s.close())
+	assert.ErrorIs(t, s.enqueue([]byte("closed")), ErrSinkClosed)
+}
+
+func TestSinkSpoolsUndeliveredLogs(t *testing.T) {
+	dir := t.TempDir()
+
+	sp, err := openSpool(dir, "test", 0)
+	require.NoError(t, err)
+
+	target := &fakeTarget{fail: true}
+	s := newSink("test", target, sinkOptions{
+		batchSize:     1,
+		flushInterval: time.Millisecond * 20,
+		maxRetries:    0,
+		queueSize:     10,
+	}, sp)
+
+	require.NoError(t, s.enqueue([]byte("a")))
+	assert.Eventually(t, func() bool {
+		return !sp.empty()
+	}, time.Second, time.Millisecond*10)
+
+	// Once logs are spooled new logs must follow them so they are delivered in order.
+	require.NoError(t, s.enqueue([]byte("b")))
+
+	target.setFail(false)
+	assert.Eventually(t, func() bool {
+		return sp.empty()
+	}, time.Second*2, time.Millisecond*10)
+
+	assert.Equal(t, []string{"a", "b"}, target.entries())
+	require.NoError(t, s.close())
+}
+
+func TestSinkBackpressure(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), "test", 4)
+	require.NoError(t, err)
+
+	// An unbuffered queue with a sink that is not running means nothing can be enqueued without the spool.
+	s := &sink{
+		name:  "test",
+		spool: sp,
+		queue: make(chan []byte),
+		done:  make(chan struct{}),
+	}
+
+	require.NoError(t, s.enqueue([]byte("abc")))
+
+	start := time.Now()
+	err = s.enqueue([]byte("def"))
+	assert.ErrorIs(t, err, ErrSinkFull)
+	assert.Less(t, time.Since(start), time.Second, "a full sink must not hold up the request")
+}
+
+func TestSpoolDrain(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), "test", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, sp.append([]byte("a"), []byte("b"), []byte("c")))
+
+	var sent []string
+	err = sp.drain(2, func(entries [][]byte) error {
+		if len(sent) > 0 {
+			return fmt.Errorf("unavailable")
+		}
+		for _, e := range entries {
+			sent = append(sent, string(e))
+		}
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"a", "b"}, sent)
+
+	// A spool reopened after a restart picks up where the last one left off.
+	sp, err = openSpool(filepath.Dir(sp.path), "test", 0)
+	require.NoError(t, err)
+
+	sent = nil
+	require.NoError(t, sp.drain(2, func(entries [][]byte) error {
+		for _, e := range entries {
+			sent = append(sent, string(e))
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"c"}, sent)
+	assert.True(t, sp.empty())
+}
+
+func TestSpoolFull(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), "test", 5)
+	require.NoError(t, err)
+
+	require.NoError(t, sp.append([]byte("ab")))
+	assert.ErrorIs(t, sp.append([]byte("cd")), errSpoolFull)
+	require.NoError(t, sp.append([]byte("c")))
+}
+
+func TestSyslogTarget(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	facility := 4
+	target, err := newSyslogTarget(auditlogv1.SyslogSink{
+		Address:  listener.Addr().String(),
+		Facility: &facility,
+		AppName:  "test",
+	})
+	require.NoError(t, err)
+	target.hostname = "host"
+	target.procID = "1"
+	defer target.close()
+
+	require.NoError(t, target.send(context.Background(), [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}))
+
+	for _, body := range []string{`{"a":1}`, `{"b":2}`} {
+		select {
+		case msg := <-received:
+			assert.True(t, strings.HasPrefix(msg, "<38>1 "), msg)
+			assert.True(t, strings.HasSuffix(msg, " host test 1 audit - "+body), msg)
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestWebhookTarget(t *testing.T) {
+	var received []map[string]any
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	target, err := newWebhookTarget(auditlogv1.WebhookSink{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, target.send(context.Background(), [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}))
+	assert.Equal(t, []map[string]any{{"a": float64(1)}, {"b": float64(2)}}, received)
+	assert.Equal(t, "Bearer token", header)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	target, err = newWebhookTarget(auditlogv1.WebhookSink{URL: failing.URL})
+	require.NoError(t, err)
+	assert.Error(t, target.send(context.Background(), [][]byte{[]byte(`{}`)}))
+}
+
+func TestOTLPTarget(t *testing.T) {
+	var path string
+	var received otlpExportLogsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	target, err := newOTLPTarget(auditlogv1.OTLPSink{
+		Endpoint:           server.URL,
+		ResourceAttributes: map[string]string{"cluster": "local"},
+	})
+	require.NoError(t, err)
+
+	entry := `{"auditID":"1234","requestTimestamp":"2025-01-01T00:00:00Z"}`
+	require.NoError(t, target.send(context.Background(), [][]byte{[]byte(entry)}))
+
+	assert.Equal(t, otlpLogsPath, path)
+	require.Len(t, received.ResourceLogs, 1)
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "cluster", Value: otlpAnyValue{StringValue: "local"}},
+		{Key: otlpServiceNameKey, Value: otlpAnyValue{StringValue: otlpServiceName}},
+	}, received.ResourceLogs[0].Resource.Attributes)
+
+	require.Len(t, received.ResourceLogs[0].ScopeLogs, 1)
+	records := received.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 1)
+	assert.Equal(t, entry, records[0].Body.StringValue)
+	assert.Equal(t, "1735689600000000000", records[0].TimeUnixNano)
+	assert.Equal(t, []otlpKeyValue{{Key: otlpAuditIDKey, Value: otlpAnyValue{StringValue: "1234"}}}, records[0].Attributes)
+}
+
+func TestPolicySinks(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	logs, w := setup(t, WriterOptions{
+		DisableDefaultPolicies: true,
+		SpoolDir:               t.TempDir(),
+	})
+
+	err := w.UpdatePolicy(&auditlogv1.AuditPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "forward-secrets",
+		},
+		Spec: auditlogv1.AuditPolicySpec{
+			Filters: []auditlogv1.Filter{
+				{
+					Action:     auditlogv1.FilterActionAllow,
+					RequestURI: "/api/v1/secrets",
+				},
+			},
+			Sinks: []auditlogv1.Sink{
+				{
+					Name:    "webhook",
+					Webhook: &auditlogv1.WebhookSink{URL: server.URL},
+					Delivery: auditlogv1.SinkDelivery{
+						FlushInterval: &metav1.Duration{Duration: time.Millisecond * 10},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(&log{RequestURI: "/api/v1/secrets"}))
+	require.NoError(t, w.Write(&log{RequestURI: "/api/v1/configmaps"}))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	}, time.Second*2, time.Millisecond*10)
+
+	mu.Lock()
+	assert.Equal(t, "/api/v1/secrets", received[0]["requestURI"])
+	mu.Unlock()
+
+	// The local writer only receives logs allowed by a policy.
+	assert.Len(t, logs.logs, 1)
+
+	assert.True(t, w.RemovePolicy(&auditlogv1.AuditPolicy{ObjectMeta: metav1.ObjectMeta{Name: "forward-secrets"}}))
+}
+
+func TestPolicySinksUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, w := setup(t, WriterOptions{
+		DisableDefaultPolicies: true,
+		SpoolDir:               t.TempDir(),
+	})
+
+	policy := &auditlogv1.AuditPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "forward",
+		},
+		Spec: auditlogv1.AuditPolicySpec{
+			Sinks: []auditlogv1.Sink{
+				{Name: "a", Webhook: &auditlogv1.WebhookSink{URL: server.URL}},
+				{Name: "b", Webhook: &auditlogv1.WebhookSink{URL: server.URL}},
+			},
+		},
+	}
+	require.NoError(t, w.UpdatePolicy(policy))
+	before, ok := w.GetPolicy("forward")
+	require.True(t, ok)
+
+	policy = policy.DeepCopy()
+	policy.Spec.Sinks[1].Delivery.BatchSize = 10
+	require.NoError(t, w.UpdatePolicy(policy))
+	after, ok := w.GetPolicy("forward")
+	require.True(t, ok)
+
+	require.Len(t, after.sinks, 2)
+	assert.Same(t, before.sinks[0], after.sinks[0], "unchanged sinks are kept")
+	assert.NoError(t, after.sinks[0].enqueue([]byte("a")))
+
+	assert.NotSame(t, before.sinks[1], after.sinks[1], "changed sinks are replaced")
+	assert.Same(t, before.sinks[1].spool, after.sinks[1].spool, "the spool is handed over to the new sink")
+	assert.ErrorIs(t, before.sinks[1].enqueue([]byte("b")), ErrSinkClosed, "the replaced sink is closed")
+
+	assert.True(t, w.RemovePolicy(policy))
+	assert.ErrorIs(t, after.sinks[0].enqueue([]byte("a")), ErrSinkClosed)
+}
+
+func TestPolicyInvalidSinks(t *testing.T) {
+	cases := []struct {
+		name  string
+		sinks []auditlogv1.Sink
+	}{
+		{
+			name:  "missing name",
+			sinks: []auditlogv1.Sink{{Webhook: &auditlogv1.WebhookSink{URL: "http://example.com"}}},
+		},
+		{
+			name: "duplicate name",
+			sinks: []auditlogv1.Sink{
+				{Name: "a", Webhook: &auditlogv1.WebhookSink{URL: "http://example.com"}},
+				{Name: "a", Webhook: &auditlogv1.WebhookSink{URL: "http://example.com"}},
+			},
+		},
+		{
+			name:  "no target",
+			sinks: []auditlogv1.Sink{{Name: "a"}},
+		},
+		{
+			name: "multiple targets",
+			sinks: []auditlogv1.Sink{{
+				Name:    "a",
+				Webhook: &auditlogv1.WebhookSink{URL: "http://example.com"},
+				OTLP:    &auditlogv1.OTLPSink{Endpoint: "http://example.com"},
+			}},
+		},
+		{
+			name:  "bad webhook scheme",
+			sinks: []auditlogv1.Sink{{Name: "a", Webhook: &auditlogv1.WebhookSink{URL: "ftp://example.com"}}},
+		},
+		{
+			name:  "bad syslog address",
+			sinks: []auditlogv1.Sink{{Name: "a", Syslog: &auditlogv1.SyslogSink{Address: "example.com"}}},
+		},
+		{
+			name:  "bad syslog facility",
+			sinks: []auditlogv1.Sink{{Name: "a", Syslog: &auditlogv1.SyslogSink{Address: "example.com:514", Facility: ptr.To(24)}}},
+		},
+		{
+			name: "bad otlp ca bundle",
+			sinks: []auditlogv1.Sink{{
+				Name: "a",
+				OTLP: &auditlogv1.OTLPSink{Endpoint: "https://example.com", TLS: auditlogv1.SinkTLS{CABundle: "invalid"}},
+			}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := PolicyFromAuditPolicy(&auditlogv1.AuditPolicy{
+				Spec: auditlogv1.AuditPolicySpec{
+					Sinks: c.sinks,
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
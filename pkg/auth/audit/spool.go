@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	errSpoolFull = errors.New("spool is full")
+)
+
+// spool is a bounded, newline delimited file used to hold logs that could not be delivered to a sink. Logs are only
+// ever appended to the end of the spool, and only ever removed from the front by draining it. A spool is handed over
+// to the sink replacing its owner when a policy is updated, so the outgoing sink may still append to it while the new
+// one drains it.
+type spool struct {
+	mu sync.Mutex
+	// drainMu serializes drains so the same logs are never sent or removed twice.
+	drainMu sync.Mutex
+
+	path    string
+	maxSize int64
+	size    int64
+}
+
+func openSpool(dir string, name string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &spool{
+		path:    filepath.Join(dir, name+".spool"),
+		maxSize: maxSize,
+	}
+
+	info, err := os.Stat(s.path)
+	switch {
+	case err == nil:
+		s.size = info.Size()
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to stat spool file: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size == 0
+}
+
+// append writes all entries to the spool, or none of them if there is not enough room.
+func (s *spool) append(entries ...[]byte) error {
+	var buffer bytes.Buffer
+	for _, e := range entries {
+		buffer.Write(e)
+		buffer.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize > 0 && s.size+int64(buffer.Len()) > s.maxSize {
+		return errSpoolFull
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer f.Close()
+
+	n, err := f.Write(buffer.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to spool file: %w", err)
+	}
+
+	return nil
+}
+
+// drain passes the spooled entries to send in batches of at most batchSize, removing each batch from the spool once
+// send succeeds. Draining stops at the first failed batch, leaving it and everything after it in the spool.
+func (s *spool) drain(batchSize int, send func([][]byte) error) error {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.mu.Lock()
+	data, err := os.ReadFile(s.path)
+	s.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	var delivered int64
+	var sendErr error
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+
+	batch := make([][]byte, 0, batchSize)
+	var batchBytes int64
+	flush := func() bool {
+		if len(batch) == 0 {
+			delivered += batchBytes
+			batchBytes = 0
+			return true
+		}
+		if sendErr = send(batch); sendErr != nil {
+			return false
+		}
+		delivered += batchBytes
+		batch, batchBytes = make([][]byte, 0, batchSize), 0
+		return true
+	}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		batchBytes += int64(len(line)) + 1
+		if len(line) > 0 {
+			batch = append(batch, bytes.Clone(line))
+		}
+		if len(batch) >= batchSize && !flush() {
+			break
+		}
+	}
+	if sendErr == nil {
+		flush()
+	}
+
+	if delivered > 0 {
+		if err := s.truncateFront(delivered); err != nil {
+			return err
+		}
+	}
+
+	return sendErr
+}
+
+// truncateFront removes the first n bytes of the spool, keeping anything appended since the spool was read.
+func (s *spool) truncateFront(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	if n > int64(len(data)) {
+		n = int64(len(data))
+	}
+	remaining := data[n:]
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, remaining, 0600); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace spool file: %w", err)
+	}
+
+	s.size = int64(len(remaining))
+
+	return nil
+}
@@ -4,11 +4,14 @@
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"sync"
 
 	auditlogv1 "github.com/rancher/rancher/pkg/apis/auditlog.cattle.io/v1"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -19,20 +22,59 @@ type Policy struct {
 	Filters   []*Filter
 	Redactors []Redactor
 	Verbosity auditlogv1.LogVerbosity
+	Sinks     []auditlogv1.Sink
+
+	sinks []*sink
 }
 
-func (p Policy) actionForLog(log *log) auditlogv1.FilterAction {
+// actionForLog returns the action of the policy for the log, and whether the log was dropped by a sample or rate limit
+// filter.
+func (p Policy) actionForLog(log *log) (auditlogv1.FilterAction, bool) {
 	if len(p.Filters) == 0 {
-		return auditlogv1.FilterActionAllow
+		return auditlogv1.FilterActionAllow, false
 	}
 
+	var throttle *Filter
 	for _, f := range p.Filters {
-		if f.Allowed(log) {
-			return auditlogv1.FilterActionAllow
+		if !f.Matches(log) {
+			continue
+		}
+
+		switch f.action {
+		case auditlogv1.FilterActionAllow:
+			return auditlogv1.FilterActionAllow, false
+		case auditlogv1.FilterActionSample, auditlogv1.FilterActionRateLimit:
+			if throttle == nil {
+				throttle = f
+			}
 		}
 	}
 
-	return auditlogv1.FilterActionDeny
+	if throttle != nil {
+		keep, suppressed := throttle.keep(log)
+		if keep {
+			log.Suppressed = max(log.Suppressed, suppressed)
+			return auditlogv1.FilterActionAllow, false
+		}
+		return auditlogv1.FilterActionDeny, true
+	}
+
+	return auditlogv1.FilterActionDeny, false
+}
+
+// redactionOnly returns whether the policy only redacts logs, as is the case for the default policies: it has
+// redactors but neither a verbosity nor sinks, and its filters, if any, only select the logs to redact. The logs such
+// policies allow are still dropped by the sample and rate limit filters of other policies.
+func (p Policy) redactionOnly() bool {
+	if len(p.Redactors) == 0 || len(p.Sinks) > 0 || p.Verbosity != (auditlogv1.LogVerbosity{}) {
+		return false
+	}
+	for _, f := range p.Filters {
+		if f.action != auditlogv1.FilterActionAllow {
+			return false
+		}
+	}
+	return true
 }
 
 func PolicyFromAuditPolicy(policy *auditlogv1.AuditPolicy) (Policy, error) {
@@ -40,6 +82,7 @@ func PolicyFromAuditPolicy(policy *auditlogv1.AuditPolicy) (Policy, error) {
 		Filters:   make([]*Filter, len(policy.Spec.Filters)),
 		Redactors: make([]Redactor, len(policy.Spec.AdditionalRedactions)),
 		Verbosity: policy.Spec.Verbosity,
+		Sinks:     policy.Spec.Sinks,
 	}
 
 	if newPolicy.Verbosity.Level != auditlogv1.LevelNull {
@@ -48,7 +91,7 @@ func PolicyFromAuditPolicy(policy *auditlogv1.AuditPolicy) (Policy, error) {
 
 	for i, f := range policy.Spec.Filters {
 		switch f.Action {
-		case auditlogv1.FilterActionAllow, auditlogv1.FilterActionDeny:
+		case auditlogv1.FilterActionAllow, auditlogv1.FilterActionDeny, auditlogv1.FilterActionSample, auditlogv1.FilterActionRateLimit:
 		default:
 			return Policy{}, fmt.Errorf("failed to create filter: invalid filter action: '%s'", f.Action)
 		}
@@ -70,6 +113,21 @@ func PolicyFromAuditPolicy(policy *auditlogv1.AuditPolicy) (Policy, error) {
 		newPolicy.Redactors[i] = redactor
 	}
 
+	names := make(map[string]bool, len(policy.Spec.Sinks))
+	for _, s := range policy.Spec.Sinks {
+		if s.Name == "" {
+			return Policy{}, fmt.Errorf("failed to create sink: name is required")
+		}
+		if names[s.Name] {
+			return Policy{}, fmt.Errorf("failed to create sink: duplicate sink name '%s'", s.Name)
+		}
+		names[s.Name] = true
+
+		if err := validateSink(s); err != nil {
+			return Policy{}, fmt.Errorf("failed to create sink: %w", err)
+		}
+	}
+
 	return newPolicy, nil
 }
 
@@ -77,15 +135,28 @@ type WriterOptions struct {
 	DefaultPolicyLevel auditlogv1.Level
 
 	DisableDefaultPolicies bool
+
+	// SpoolDir is the directory used to persist logs that could not be delivered to a policy's sinks. If empty,
+	// undeliverable logs are only held in memory.
+	SpoolDir string
+
+	// SpoolMaxSize is the maximum size in bytes of each sink's spool. A value of 0 means the spool is unbounded.
+	SpoolMaxSize int64
+
+	// Chain enables hash chaining and signed checkpoints for logs written to the local output.
+	Chain ChainOptions
 }
 
 type Writer struct {
 	WriterOptions
 
+	// updateMutex serializes policy updates so sinks are only handed over once.
+	updateMutex   sync.Mutex
 	policiesMutex sync.RWMutex
 	policies      map[string]Policy
 
 	output io.Writer
+	chain  *chain
 }
 
 func NewWriter(output io.Writer, opts WriterOptions) (*Writer, error) {
@@ -96,6 +167,10 @@ func NewWriter(output io.Writer, opts WriterOptions) (*Writer, error) {
 		output:   output,
 	}
 
+	if opts.Chain.Enabled {
+		w.chain = newChain(opts.Chain)
+	}
+
 	if !opts.DisableDefaultPolicies {
 		for _, v := range DefaultPolicies() {
 			if err := w.UpdatePolicy(&v); err != nil {
@@ -117,13 +192,24 @@ func (w *Writer) Write(log *log) error {
 
 	verbosity := verbosityForLevel(w.DefaultPolicyLevel)
 	action := auditlogv1.FilterActionUnknown
+	var sinks []*sink
+	var throttled, redactionAllowed bool
 
 	w.policiesMutex.RLock()
 	for _, policy := range w.policies {
-		switch policy.actionForLog(log) {
+		decision, dropped := policy.actionForLog(log)
+		throttled = throttled || dropped
+		if decision == auditlogv1.FilterActionAllow && policy.redactionOnly() {
+			redactors = append(redactors, policy.Redactors...)
+			redactionAllowed = true
+			continue
+		}
+
+		switch decision {
 		case auditlogv1.FilterActionAllow:
 			redactors = append(redactors, policy.Redactors...)
 			verbosity = mergeLogVerbosities(verbosity, policy.Verbosity)
+			sinks = append(sinks, policy.sinks...)
 
 			action = auditlogv1.FilterActionAllow
 		case auditlogv1.FilterActionDeny:
@@ -134,6 +220,9 @@ func (w *Writer) Write(log *log) error {
 	}
 	w.policiesMutex.RUnlock()
 
+	if redactionAllowed && !throttled {
+		action = auditlogv1.FilterActionAllow
+	}
 	if action == auditlogv1.FilterActionDeny {
 		return nil
 	}
@@ -146,22 +235,50 @@ func (w *Writer) Write(log *log) error {
 		}
 	}
 
-	data, err := json.Marshal(log)
+	var errs []error
+	var entry []byte
+	var err error
+
+	if w.chain != nil {
+		entry, err = w.chain.write(log, w.output)
+		if err != nil {
+			if entry == nil {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	} else {
+		if entry, err = encodeRecord(log); err != nil {
+			return err
+		}
+
+		if _, err := w.output.Write(append(bytes.Clone(entry), '\n')); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write log: %w", err))
+		}
+	}
+
+	for _, s := range sinks {
+		if err := s.enqueue(entry); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send log to sink: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// encodeRecord encodes a record as a single line of compact JSON without a trailing newline.
+func encodeRecord(record any) ([]byte, error) {
+	data, err := json.Marshal(record)
 	if err != nil {
-		return fmt.Errorf("failed to marshal log: %w", err)
+		return nil, fmt.Errorf("failed to marshal log: %w", err)
 	}
 
 	var buffer bytes.Buffer
 	if err := json.Compact(&buffer, data); err != nil {
-		return fmt.Errorf("failed to compact log: %w", err)
-	}
-	buffer.WriteByte('\n')
-
-	if _, err := w.output.Write(buffer.Bytes()); err != nil {
-		return fmt.Errorf("failed to write log: %w", err)
+		return nil, fmt.Errorf("failed to compact log: %w", err)
 	}
 
-	return nil
+	return buffer.Bytes(), nil
 }
 
 func (w *Writer) UpdatePolicy(policy *auditlogv1.AuditPolicy) error {
@@ -170,23 +287,101 @@ func (w *Writer) UpdatePolicy(policy *auditlogv1.AuditPolicy) error {
 		return err
 	}
 
+	w.updateMutex.Lock()
+	defer w.updateMutex.Unlock()
+
+	w.policiesMutex.RLock()
+	oldPolicy := w.policies[policy.Name]
+	w.policiesMutex.RUnlock()
+
+	var stale []*sink
+	if newPolicy.sinks, stale, err = w.startSinks(policy.Name, newPolicy.Sinks, oldPolicy.sinks); err != nil {
+		return err
+	}
+
 	w.policiesMutex.Lock()
 	w.policies[policy.Name] = newPolicy
 	w.policiesMutex.Unlock()
 
+	closeSinks(stale)
+
 	return nil
 }
 
+// startSinks returns the sinks for the specs of a policy along with the sinks of its previous version that are no
+// longer used. Sinks whose spec did not change are kept as they are. A sink whose spec changed takes over the spool of
+// the sink it replaces, which moves anything still in memory to the spool when it is closed.
+func (w *Writer) startSinks(policyName string, specs []auditlogv1.Sink, current []*sink) ([]*sink, []*sink, error) {
+	currentByName := make(map[string]*sink, len(current))
+	for _, s := range current {
+		currentByName[s.spec.Name] = s
+	}
+
+	var sinks, started []*sink
+	for _, spec := range specs {
+		old := currentByName[spec.Name]
+		if old != nil && reflect.DeepEqual(old.spec, spec) {
+			sinks = append(sinks, old)
+			delete(currentByName, spec.Name)
+			continue
+		}
+
+		target, err := newSinkTarget(spec)
+		if err != nil {
+			closeSinks(started)
+			return nil, nil, fmt.Errorf("failed to create sink: %w", err)
+		}
+
+		var sp *spool
+		switch {
+		case old != nil && old.spool != nil:
+			sp = old.spool
+		case w.SpoolDir != "":
+			if sp, err = openSpool(w.SpoolDir, policyName+"-"+spec.Name, w.SpoolMaxSize); err != nil {
+				target.close()
+				closeSinks(started)
+				return nil, nil, fmt.Errorf("failed to open spool for sink '%s': %w", spec.Name, err)
+			}
+		}
+
+		s := newSink(policyName+"/"+spec.Name, target, sinkOptionsFromDelivery(spec.Delivery), sp)
+		s.spec = spec
+		sinks = append(sinks, s)
+		started = append(started, s)
+	}
+
+	stale := make([]*sink, 0, len(currentByName))
+	for _, s := range current {
+		if currentByName[s.spec.Name] == s {
+			stale = append(stale, s)
+		}
+	}
+
+	return sinks, stale, nil
+}
+
+func closeSinks(sinks []*sink) {
+	for _, s := range sinks {
+		if err := s.close(); err != nil {
+			logrus.Warnf("Failed to close audit log sink '%s': %s", s.name, err)
+		}
+	}
+}
+
 func (w *Writer) RemovePolicy(policy *auditlogv1.AuditPolicy) bool {
+	w.updateMutex.Lock()
+	defer w.updateMutex.Unlock()
+
 	w.policiesMutex.Lock()
-	defer w.policiesMutex.Unlock()
+	oldPolicy, ok := w.policies[policy.Name]
+	delete(w.policies, policy.Name)
+	w.policiesMutex.Unlock()
 
-	if _, ok := w.policies[policy.Name]; ok {
-		delete(w.policies, policy.Name)
-		return true
+	if ok {
+		closeSinks(oldPolicy.sinks)
 	}
 
-	return false
+	return ok
 }
 
 func (w *Writer) GetPolicy(name string) (Policy, bool) {
@@ -205,5 +400,18 @@ func (l *Writer) Start(ctx context.Context) {
 
 	go func() {
 		<-ctx.Done()
+
+		if l.chain != nil {
+			if err := l.chain.checkpoint(l.output); err != nil {
+				logrus.Warnf("Failed to write final audit log checkpoint: %s", err)
+			}
+		}
+
+		l.policiesMutex.Lock()
+		defer l.policiesMutex.Unlock()
+
+		for _, p := range l.policies {
+			closeSinks(p.sinks)
+		}
 	}()
 }
@@ -5,6 +5,7 @@
 	"compress/gzip"
 	"compress/zlib"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 
@@ -100,6 +101,37 @@ func TestAllowList(t *testing.T) {
 	assert.Equal(t, expected, logs.logs)
 }
 
+func TestAllowListWithDefaultPolicies(t *testing.T) {
+	logs, w := setup(t, WriterOptions{
+		DefaultPolicyLevel: auditlogv1.LevelNull,
+	})
+
+	// The default policies allow all logs, so an allow filter doesn't deny the logs it doesn't match.
+	err := w.UpdatePolicy(&auditlogv1.AuditPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "allow-secrets",
+		},
+		Spec: auditlogv1.AuditPolicySpec{
+			Filters: []auditlogv1.Filter{
+				{
+					Action:     auditlogv1.FilterActionAllow,
+					RequestURI: "/api/v1/secrets",
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Write(&log{RequestURI: "/api/v1/secrets"}))
+	assert.NoError(t, w.Write(&log{RequestURI: "/v1/pods"}))
+
+	expected := []log{
+		{RequestURI: "/api/v1/secrets"},
+		{RequestURI: "/v1/pods"},
+	}
+	assert.Equal(t, expected, logs.logs)
+}
+
 func TestBlockList(t *testing.T) {
 	logs, w := setup(t, WriterOptions{
 		DefaultPolicyLevel:     auditlogv1.LevelRequestResponse,
@@ -144,6 +176,58 @@ func TestBlockList(t *testing.T) {
 	assert.Equal(t, expected, logs.logs)
 }
 
+func TestSampledWatches(t *testing.T) {
+	// The default policies only redact logs, so they must not allow the logs the sample filter drops.
+	for _, disableDefaults := range []bool{true, false} {
+		t.Run(fmt.Sprintf("disable default policies %t", disableDefaults), func(t *testing.T) {
+			logs, w := setup(t, WriterOptions{
+				DefaultPolicyLevel:     auditlogv1.LevelNull,
+				DisableDefaultPolicies: disableDefaults,
+			})
+
+			err := w.UpdatePolicy(&auditlogv1.AuditPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sample-watches",
+				},
+				Spec: auditlogv1.AuditPolicySpec{
+					Filters: []auditlogv1.Filter{
+						{
+							Action:     auditlogv1.FilterActionAllow,
+							RequestURI: "/api/v1/secrets",
+						},
+						{
+							Action:     auditlogv1.FilterActionSample,
+							RequestURI: "watch=true",
+							Sample:     &auditlogv1.Sample{OneIn: 2},
+						},
+					},
+				},
+			})
+			assert.NoError(t, err)
+
+			for i := 0; i < 4; i++ {
+				assert.NoError(t, w.Write(&log{RequestURI: "/v1/pods?watch=true"}))
+			}
+
+			// An allow filter takes precedence over the sample filter.
+			assert.NoError(t, w.Write(&log{RequestURI: "/api/v1/secrets?watch=true"}))
+
+			// Logs matching no filter are denied, unless the default policies allow them.
+			assert.NoError(t, w.Write(&log{RequestURI: "/v1/pods"}))
+
+			expected := []log{
+				{RequestURI: "/v1/pods?watch=true"},
+				{RequestURI: "/v1/pods?watch=true", Suppressed: 1},
+				{RequestURI: "/api/v1/secrets?watch=true"},
+			}
+			if !disableDefaults {
+				expected = append(expected, log{RequestURI: "/v1/pods"})
+			}
+			assert.Equal(t, expected, logs.logs)
+		})
+	}
+}
+
 func TestHigherVerbosityForPolicy(t *testing.T) {
 	bodyContent := []byte(`{"password":"password"}`)
 	headers := map[string][]string{
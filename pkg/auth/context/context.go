@@ -1,6 +1,10 @@
 package auth
 
-import "context"
+import (
+	"context"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+)
 
 // saAuthenticatedContextKey is the context key for the SAAuthenticated flag.
 type saAuthenticatedContextKey struct{}
@@ -32,3 +36,19 @@ func GetSAImpersonation(ctx context.Context) string {
 	impSA, _ := ctx.Value(saImpersonationKey).(string)
 	return impSA
 }
+
+// tokenScopeContextKey is the context key for the scope of the token that authenticated the request.
+type tokenScopeContextKey struct{}
+
+var tokenScopeKey = tokenScopeContextKey{}
+
+// SetTokenScope sets the scope of the token that authenticated the request in the context.
+func SetTokenScope(ctx context.Context, scope *ext.TokenScope) context.Context {
+	return context.WithValue(ctx, tokenScopeKey, scope)
+}
+
+// GetTokenScope returns the scope of the token that authenticated the request, or nil if the token is not scoped.
+func GetTokenScope(ctx context.Context) *ext.TokenScope {
+	scope, _ := ctx.Value(tokenScopeKey).(*ext.TokenScope)
+	return scope
+}
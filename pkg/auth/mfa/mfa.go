@@ -0,0 +1,268 @@
+// Package mfa implements TOTP based multi-factor authentication for local users.
+package mfa
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/wrangler"
+	wcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// Issuer is the issuer shown by authenticator apps.
+	Issuer = "Rancher"
+
+	secretField = "totp"
+)
+
+var (
+	// ErrInvalidCode is returned when a code is neither a valid TOTP code nor an unused recovery code.
+	ErrInvalidCode = errors.New("invalid multi-factor authentication code")
+	// ErrNotEnrolled is returned when the user has no confirmed enrollment.
+	ErrNotEnrolled = errors.New("multi-factor authentication is not enabled for the user")
+	// ErrAlreadyEnrolled is returned when enrolling a user with a confirmed enrollment.
+	ErrAlreadyEnrolled = errors.New("multi-factor authentication is already enabled for the user")
+	// ErrNotLocalUser is returned when enrolling a user which can't log in with the local auth provider.
+	ErrNotLocalUser = errors.New("multi-factor authentication is only available to local users")
+)
+
+// Manager enrolls local users in multi-factor authentication and verifies their codes.
+type Manager struct {
+	users    managementcontrollers.UserClient
+	grbCache managementcontrollers.GlobalRoleBindingCache
+	grCache  managementcontrollers.GlobalRoleCache
+	secrets  wcorev1.SecretController
+	now      func() time.Time
+}
+
+// NewManager returns a Manager using the clients of the given context.
+func NewManager(wContext *wrangler.Context) *Manager {
+	return &Manager{
+		users:    wContext.Mgmt.User(),
+		grbCache: wContext.Mgmt.GlobalRoleBinding().Cache(),
+		grCache:  wContext.Mgmt.GlobalRole().Cache(),
+		secrets:  wContext.Core.Secret(),
+		now:      time.Now,
+	}
+}
+
+// Enabled returns whether the user has a confirmed enrollment.
+func Enabled(user *v3.User) bool {
+	return user.MFA != nil && user.MFA.Enabled
+}
+
+// Required returns whether the user, member of the given group principals, must log in with a second factor according
+// to the local-auth-mfa-required setting. In the admins mode, users bound to a global role with admin permissions
+// through their name, one of their principals or one of their groups must use a second factor.
+func (m *Manager) Required(user *v3.User, groupPrincipals []string) (bool, error) {
+	switch settings.LocalAuthMFARequired.Get() {
+	case settings.MFARequiredAll:
+		return true, nil
+	case settings.MFARequiredAdmins:
+		return m.IsAdmin(user, groupPrincipals)
+	}
+	return false, nil
+}
+
+// IsAdmin returns whether the user, member of the given group principals, is bound to a global role with admin
+// permissions through its name, one of its principals or one of its groups.
+func (m *Manager) IsAdmin(user *v3.User, groupPrincipals []string) (bool, error) {
+	grbs, err := m.grbCache.List(labels.Everything())
+	if err != nil {
+		return false, fmt.Errorf("failed to list global role bindings: %w", err)
+	}
+	for _, grb := range grbs {
+		bound := grb.UserName == user.Name ||
+			(grb.UserPrincipalName != "" && slices.Contains(user.PrincipalIDs, grb.UserPrincipalName)) ||
+			(grb.GroupPrincipalName != "" && slices.Contains(groupPrincipals, grb.GroupPrincipalName))
+		if !bound {
+			continue
+		}
+		gr, err := m.grCache.Get(grb.GlobalRoleName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("failed to get global role %s: %w", grb.GlobalRoleName, err)
+		}
+		if rbac.IsAdminGlobalRoleObject(gr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Enroll creates a new TOTP key for the user, replacing any unconfirmed one. The enrollment only takes effect once
+// confirmed with a code of the key. It returns the key and its otpauth:// URI.
+func (m *Manager) Enroll(userName string) (string, string, error) {
+	var secret, uri string
+	err := m.update(userName, func(user *v3.User) error {
+		if user.Username == "" {
+			return ErrNotLocalUser
+		}
+		if Enabled(user) {
+			return ErrAlreadyEnrolled
+		}
+
+		var err error
+		secret, err = GenerateSecret()
+		if err != nil {
+			return err
+		}
+		secretName, err := common.CreateOrUpdateSecrets(m.secrets, secret, secretField, secretAuthType(user.Name))
+		if err != nil {
+			return fmt.Errorf("failed to store TOTP key: %w", err)
+		}
+		uri = KeyURI(Issuer, user.Username, secret)
+		user.MFA = &v3.UserMFA{SecretName: secretName}
+		return nil
+	})
+	return secret, uri, err
+}
+
+// Confirm enables the pending enrollment of the user if the code is valid for its key. It returns the recovery codes
+// of the user, which are only ever shown once.
+func (m *Manager) Confirm(userName, code string) ([]string, error) {
+	var codes []string
+	err := m.update(userName, func(user *v3.User) error {
+		if user.MFA == nil || user.MFA.SecretName == "" {
+			return ErrNotEnrolled
+		}
+		if user.MFA.Enabled {
+			return ErrAlreadyEnrolled
+		}
+		secret, err := m.readSecret(user)
+		if err != nil {
+			return err
+		}
+		step, ok := ValidateCode(secret, code, m.now(), 0)
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		var hashes []string
+		codes, hashes, err = GenerateRecoveryCodes()
+		if err != nil {
+			return err
+		}
+		enrolledAt := metav1.NewTime(m.now())
+		user.MFA.Enabled = true
+		user.MFA.EnrolledAt = &enrolledAt
+		user.MFA.LastUsedStep = step
+		user.MFA.RecoveryCodes = hashes
+		return nil
+	})
+	return codes, err
+}
+
+// Verify checks the TOTP or recovery code of the user and records its use, so it can't be used again.
+func (m *Manager) Verify(userName, code string) error {
+	return m.update(userName, func(user *v3.User) error {
+		return m.verify(user, code)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after verifying the code.
+func (m *Manager) RegenerateRecoveryCodes(userName, code string) ([]string, error) {
+	var codes []string
+	err := m.update(userName, func(user *v3.User) error {
+		if err := m.verify(user, code); err != nil {
+			return err
+		}
+		var hashes []string
+		var err error
+		codes, hashes, err = GenerateRecoveryCodes()
+		if err != nil {
+			return err
+		}
+		user.MFA.RecoveryCodes = hashes
+		return nil
+	})
+	return codes, err
+}
+
+// Disable removes the enrollment of the user and its TOTP key.
+func (m *Manager) Disable(userName string) error {
+	err := m.update(userName, func(user *v3.User) error {
+		user.MFA = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := common.DeleteSecret(m.secrets, secretAuthType(userName), secretField); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete TOTP key: %w", err)
+	}
+	return nil
+}
+
+// verify checks the code against the enrollment of the user and updates the enrollment to prevent its reuse.
+func (m *Manager) verify(user *v3.User, code string) error {
+	if !Enabled(user) {
+		return ErrNotEnrolled
+	}
+	secret, err := m.readSecret(user)
+	if err != nil {
+		return err
+	}
+	if step, ok := ValidateCode(secret, code, m.now(), user.MFA.LastUsedStep); ok {
+		user.MFA.LastUsedStep = step
+		return nil
+	}
+	if remaining, ok := UseRecoveryCode(user.MFA.RecoveryCodes, code); ok {
+		user.MFA.RecoveryCodes = remaining
+		return nil
+	}
+	return ErrInvalidCode
+}
+
+func (m *Manager) readSecret(user *v3.User) (string, error) {
+	// ReadFromSecret returns values not referring to a secret as is, the key must never come from the user itself.
+	if !strings.HasPrefix(user.MFA.SecretName, common.SecretsNamespace+":") {
+		return "", fmt.Errorf("invalid TOTP key secret %q", user.MFA.SecretName)
+	}
+	secret, err := common.ReadFromSecret(m.secrets, user.MFA.SecretName, secretField)
+	if err != nil {
+		return "", fmt.Errorf("failed to read TOTP key: %w", err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("TOTP key secret %s is empty", user.MFA.SecretName)
+	}
+	return secret, nil
+}
+
+// update applies the change to the latest version of the user, retrying on conflicts. The user is not updated if
+// change returns an error.
+func (m *Manager) update(userName string, change func(user *v3.User) error) error {
+	for i := 0; i < 3; i++ {
+		user, err := m.users.Get(userName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		user = user.DeepCopy()
+		if err := change(user); err != nil {
+			return err
+		}
+		_, err = m.users.Update(user)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update user %s", userName)
+}
+
+func secretAuthType(userName string) string {
+	return "mfa-" + userName
+}
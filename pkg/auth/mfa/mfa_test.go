@@ -0,0 +1,198 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTestManager(t *testing.T, users map[string]*v3.User, grbs []*v3.GlobalRoleBinding) *Manager {
+	ctrl := gomock.NewController(t)
+
+	userClient := fake.NewMockNonNamespacedClientInterface[*v3.User, *v3.UserList](ctrl)
+	userClient.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(name string, _ metav1.GetOptions) (*v3.User, error) {
+		user, ok := users[name]
+		if !ok {
+			return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+		}
+		return user.DeepCopy(), nil
+	}).AnyTimes()
+	userClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(user *v3.User) (*v3.User, error) {
+		users[user.Name] = user.DeepCopy()
+		return user, nil
+	}).AnyTimes()
+
+	grbCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRoleBinding](ctrl)
+	grbCache.EXPECT().List(gomock.Any()).Return(grbs, nil).AnyTimes()
+	grs := map[string]*v3.GlobalRole{
+		"admin":            {ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Builtin: true},
+		"user":             {ObjectMeta: metav1.ObjectMeta{Name: "user"}, Builtin: true},
+		"restricted-admin": {ObjectMeta: metav1.ObjectMeta{Name: "restricted-admin"}, Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}, {NonResourceURLs: []string{"*"}, Verbs: []string{"*"}}}},
+	}
+	grCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRole](ctrl)
+	grCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.GlobalRole, error) {
+		if gr, ok := grs[name]; ok {
+			return gr, nil
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+
+	stored := map[string]*v1.Secret{}
+	secretCache := fake.NewMockCacheInterface[*v1.Secret](ctrl)
+	secretCache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*v1.Secret, error) {
+		if secret, ok := stored[name]; ok {
+			return secret, nil
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+	secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+	secrets.EXPECT().Cache().Return(secretCache).AnyTimes()
+	store := func(secret *v1.Secret) (*v1.Secret, error) {
+		secret = secret.DeepCopy()
+		secret.Data = map[string][]byte{}
+		for k, v := range secret.StringData {
+			secret.Data[k] = []byte(v)
+		}
+		stored[secret.Name] = secret
+		return secret, nil
+	}
+	secrets.EXPECT().Create(gomock.Any()).DoAndReturn(store).AnyTimes()
+	secrets.EXPECT().Update(gomock.Any()).DoAndReturn(store).AnyTimes()
+	secrets.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string, _ metav1.GetOptions) (*v1.Secret, error) {
+		return secretCache.Get(namespace, name)
+	}).AnyTimes()
+	secrets.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string, _ *metav1.DeleteOptions) error {
+		if _, ok := stored[name]; !ok {
+			return apierrors.NewNotFound(schema.GroupResource{}, name)
+		}
+		delete(stored, name)
+		return nil
+	}).AnyTimes()
+
+	return &Manager{
+		users:    userClient,
+		grbCache: grbCache,
+		grCache:  grCache,
+		secrets:  secrets,
+		now:      time.Now,
+	}
+}
+
+func TestEnrollment(t *testing.T) {
+	users := map[string]*v3.User{
+		"u-1":      {ObjectMeta: metav1.ObjectMeta{Name: "u-1"}, Username: "admin"},
+		"u-remote": {ObjectMeta: metav1.ObjectMeta{Name: "u-remote"}},
+	}
+	m := newTestManager(t, users, nil)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	_, _, err := m.Enroll("u-remote")
+	assert.ErrorIs(t, err, ErrNotLocalUser)
+
+	secret, uri, err := m.Enroll("u-1")
+	require.NoError(t, err)
+	assert.Contains(t, uri, "secret="+secret)
+	require.NotNil(t, users["u-1"].MFA)
+	assert.Equal(t, "cattle-global-data:mfa-u-1-totp", users["u-1"].MFA.SecretName)
+	assert.False(t, Enabled(users["u-1"]), "the enrollment must be confirmed")
+
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Verify("u-1", code), ErrNotEnrolled)
+	_, err = m.Confirm("u-1", "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	recoveryCodes, err := m.Confirm("u-1", code)
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, RecoveryCodeCount)
+	assert.True(t, Enabled(users["u-1"]))
+	assert.Len(t, users["u-1"].MFA.RecoveryCodes, RecoveryCodeCount)
+
+	_, _, err = m.Enroll("u-1")
+	assert.ErrorIs(t, err, ErrAlreadyEnrolled)
+
+	// The code used to confirm the enrollment can't be used to log in.
+	assert.ErrorIs(t, m.Verify("u-1", code), ErrInvalidCode)
+	now = now.Add(Period)
+	code, err = Code(secret, now)
+	require.NoError(t, err)
+	assert.NoError(t, m.Verify("u-1", code))
+	assert.ErrorIs(t, m.Verify("u-1", code), ErrInvalidCode)
+
+	assert.NoError(t, m.Verify("u-1", recoveryCodes[0]))
+	assert.Len(t, users["u-1"].MFA.RecoveryCodes, RecoveryCodeCount-1)
+	assert.ErrorIs(t, m.Verify("u-1", recoveryCodes[0]), ErrInvalidCode)
+
+	newCodes, err := m.RegenerateRecoveryCodes("u-1", recoveryCodes[1])
+	require.NoError(t, err)
+	assert.Len(t, users["u-1"].MFA.RecoveryCodes, RecoveryCodeCount)
+	assert.ErrorIs(t, m.Verify("u-1", recoveryCodes[2]), ErrInvalidCode)
+	assert.NoError(t, m.Verify("u-1", newCodes[2]))
+
+	require.NoError(t, m.Disable("u-1"))
+	assert.Nil(t, users["u-1"].MFA)
+	_, err = m.readSecret(&v3.User{MFA: &v3.UserMFA{SecretName: "cattle-global-data:mfa-u-1-totp"}})
+	assert.Error(t, err, "the key is deleted")
+	require.NoError(t, m.Disable("u-1"), "disabling twice is not an error")
+}
+
+func TestReadSecretRejectsInlineKeys(t *testing.T) {
+	m := newTestManager(t, map[string]*v3.User{}, nil)
+	_, err := m.readSecret(&v3.User{MFA: &v3.UserMFA{SecretName: rfcSecret}})
+	assert.Error(t, err)
+}
+
+func TestRequired(t *testing.T) {
+	grbs := []*v3.GlobalRoleBinding{
+		{UserName: "u-admin", GlobalRoleName: "admin"},
+		{UserName: "u-user", GlobalRoleName: "user"},
+		{UserName: "u-user", GlobalRoleName: "deleted"},
+		{UserPrincipalName: "local://u-principal", GlobalRoleName: "restricted-admin"},
+		{GroupPrincipalName: "local://g-admins", GlobalRoleName: "admin"},
+	}
+	m := newTestManager(t, map[string]*v3.User{}, grbs)
+	admin := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-admin"}}
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-user"}}
+	principalAdmin := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-principal"}, PrincipalIDs: []string{"local://u-principal"}}
+
+	tests := []struct {
+		setting   string
+		wantAdmin bool
+		wantUser  bool
+	}{
+		{setting: settings.MFARequiredNone},
+		{setting: settings.MFARequiredAdmins, wantAdmin: true},
+		{setting: settings.MFARequiredAll, wantAdmin: true, wantUser: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			require.NoError(t, settings.LocalAuthMFARequired.Set(tt.setting))
+			t.Cleanup(func() { settings.LocalAuthMFARequired.Set(settings.MFARequiredNone) })
+
+			required, err := m.Required(admin, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAdmin, required)
+			required, err = m.Required(principalAdmin, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAdmin, required, "bound to a global role with admin permissions through a principal")
+			required, err = m.Required(user, []string{"local://g-admins"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAdmin, required, "bound to the admin global role through a group")
+			required, err = m.Required(user, []string{"local://g-users"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUser, required)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the test vectors of RFC 6238.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC test vectors use 8 digits, the codes are their last 6 digits.
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range tests {
+		code, err := Code(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := timeStep(now)
+
+	code, err := Code(rfcSecret, now)
+	require.NoError(t, err)
+	step, ok := ValidateCode(rfcSecret, code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, current, step)
+
+	_, ok = ValidateCode(rfcSecret, code, now, step)
+	assert.False(t, ok, "a code can't be used twice")
+
+	previous, err := Code(rfcSecret, now.Add(-Period))
+	require.NoError(t, err)
+	step, ok = ValidateCode(rfcSecret, previous, now, 0)
+	require.True(t, ok, "codes of the previous step are accepted")
+	assert.Equal(t, current-1, step)
+
+	old, err := Code(rfcSecret, now.Add(-2*Period))
+	require.NoError(t, err)
+	_, ok = ValidateCode(rfcSecret, old, now, 0)
+	assert.False(t, ok)
+
+	_, ok = ValidateCode(rfcSecret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = ValidateCode("not base32!", code, now, 0)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	key, err := decodeSecret(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, secretSize)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestKeyURI(t *testing.T) {
+	u, err := url.Parse(KeyURI("Rancher", "admin", "ABC"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Rancher:admin", u.Path)
+	assert.Equal(t, "ABC", u.Query().Get("secret"))
+	assert.Equal(t, "Rancher", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	require.Len(t, hashes, RecoveryCodeCount)
+	assert.Regexp(t, "^[a-z2-7]{4}-[a-z2-7]{4}$", codes[0])
+
+	remaining, ok := UseRecoveryCode(hashes, codes[3])
+	require.True(t, ok)
+	assert.Len(t, remaining, RecoveryCodeCount-1)
+	assert.NotContains(t, remaining, hashes[3])
+
+	_, ok = UseRecoveryCode(remaining, codes[3])
+	assert.False(t, ok, "a recovery code can't be used twice")
+
+	_, ok = UseRecoveryCode(remaining, " "+codes[4][:4]+codes[4][5:])
+	assert.True(t, ok, "the separator and whitespace are optional")
+
+	_, ok = UseRecoveryCode(remaining, "")
+	assert.False(t, ok)
+}
@@ -264,6 +264,10 @@ func (r *refresher) refreshAttributes(attribs *apiv3.UserAttribute) (*v3.UserAtt
 			}
 		}
 
+		if principalID != "" {
+			// groups provisioned through SCIM are not known to the auth provider
+			newGroupPrincipals = tokens.KeepProvisionedGroups(attribs.GroupPrincipals[providerName].Items, newGroupPrincipals)
+		}
 		if len(newGroupPrincipals) == 0 {
 			newGroupPrincipals = nil
 		}
@@ -3,6 +3,7 @@
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"unicode"
 
@@ -11,9 +12,12 @@
 	"github.com/rancher/norman/types"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/accessor"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -35,7 +39,11 @@
 	searchIndexDefaultLen = 6
 )
 
-var invalidHash, _ = bcrypt.GenerateFromPassword([]byte("invalid"), bcrypt.DefaultCost)
+var (
+	invalidHash, _ = bcrypt.GenerateFromPassword([]byte("invalid"), bcrypt.DefaultCost)
+
+	errInvalidMFACode = httperror.NewAPIError(httperror.Unauthorized, "authentication failed")
+)
 
 type Provider struct {
 	userLister   v3.UserLister
@@ -44,6 +52,16 @@ type Provider struct {
 	gmIndexer    cache.Indexer
 	groupIndexer cache.Indexer
 	tokenMGR     *tokens.Manager
+	mfa          mfaVerifier
+	limiter      *loginLimiter
+	users        managementcontrollers.UserClient
+}
+
+// mfaVerifier checks the second factor of local users.
+type mfaVerifier interface {
+	Required(user *v3.User, groupPrincipals []string) (bool, error)
+	IsAdmin(user *v3.User, groupPrincipals []string) (bool, error)
+	Verify(userName, code string) error
 }
 
 func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, tokenMGR *tokens.Manager) common.AuthProvider {
@@ -66,7 +84,11 @@ func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, tokenMGR *tok
 		groupIndexer: gInformer.GetIndexer(),
 		userLister:   mgmtCtx.Management.Users("").Controller().Lister(),
 		tokenMGR:     tokenMGR,
+		mfa:          mfa.NewManager(mgmtCtx.Wrangler),
+		limiter:      newLoginLimiter(),
+		users:        mgmtCtx.Wrangler.Mgmt.User(),
 	}
+	settings.SetValidator(settings.LocalAuthMFARequired.Name, l.validateMFARequired)
 	return l
 }
 
@@ -119,6 +141,11 @@ func (l *Provider) AuthenticateUser(ctx context.Context, input interface{}) (v3.
 
 	username := localInput.Username
 	pwd := localInput.Password
+	source := sourceOf(ctx)
+
+	if wait := l.loginWait(username, source); wait > 0 {
+		return v3.Principal{}, nil, "", tooManyAttempts(wait)
+	}
 
 	authFailedError := httperror.NewAPIError(httperror.Unauthorized, "authentication failed")
 	user, err := l.getUser(username)
@@ -127,24 +154,140 @@ func (l *Provider) AuthenticateUser(ctx context.Context, input interface{}) (v3.
 		// to avoid user enumeration via timing attack (time based side-channel).
 		bcrypt.CompareHashAndPassword(invalidHash, []byte(pwd))
 		logrus.Debugf("Get User [%s] failed during Authentication: %v", username, err)
+		l.loginFailed(ctx, nil, username, source)
 		return v3.Principal{}, nil, "", authFailedError
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pwd)); err != nil {
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pwd))
+	if lockedOut(user, l.limiter.now()) > 0 {
+		// Locked out users fail like unknown usernames, even with the right password, so that the response doesn't
+		// disclose which usernames exist. The failure doesn't extend the lockout.
+		logrus.Debugf("User [%s] is locked out", username)
+		l.loginFailed(ctx, nil, username, source)
+		return v3.Principal{}, nil, "", authFailedError
+	}
+	if err != nil {
 		logrus.Debugf("Authentication failed for User [%s]: %v", username, err)
+		l.loginFailed(ctx, user, username, source)
 		return v3.Principal{}, nil, "", authFailedError
 	}
 
+	groupPrincipals, err := l.getGroupPrincipals(user)
+	if err != nil {
+		return v3.Principal{}, nil, "", errors.Wrapf(err, "failed to get groups for %v", user.Name)
+	}
+
+	if err := l.checkMFA(user, principalNames(groupPrincipals), localInput.MFACode); err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			l.loginFailed(ctx, user, username, source)
+		}
+		return v3.Principal{}, nil, "", err
+	}
+
+	l.loginSucceeded(ctx, user, source)
+	l.checkPasswordAge(user)
+
 	principalID := getLocalPrincipalID(user)
 	userPrincipal := l.toPrincipal("user", user.DisplayName, user.Username, principalID, nil)
 	userPrincipal.Me = true
 
-	groupPrincipals, err := l.getGroupPrincipals(user)
+	return userPrincipal, groupPrincipals, "", nil
+}
+
+// checkMFA verifies the second factor of a user who passed the password check. Users enrolled in multi-factor
+// authentication always need a valid code, users who must use it but are not enrolled can't log in.
+func (l *Provider) checkMFA(user *v3.User, groups []string, code string) error {
+	if !mfa.Enabled(user) {
+		required, err := l.mfa.Required(user, groups)
+		if err != nil {
+			return err
+		}
+		if required {
+			logrus.Infof("Authentication failed for User [%s]: multi-factor authentication is required but not enrolled", user.Username)
+			return httperror.NewAPIError(httperror.Unauthorized, "multi-factor authentication is required but not enrolled")
+		}
+		return nil
+	}
+
+	if code == "" {
+		return httperror.NewAPIError(httperror.Unauthorized, "multi-factor authentication code required")
+	}
+	if err := l.mfa.Verify(user.Name, code); err != nil {
+		logrus.Debugf("Multi-factor authentication failed for User [%s]: %v", user.Username, err)
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			return errInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
+
+// validateMFARequired refuses to require a second factor from admins while a local admin is not enrolled. Enrolling
+// needs a session, so such an admin couldn't log in anymore.
+func (l *Provider) validateMFARequired(value string) error {
+	if value != settings.MFARequiredAdmins && value != settings.MFARequiredAll {
+		return nil
+	}
+
+	users, err := l.userLister.List("", labels.Everything())
 	if err != nil {
-		return v3.Principal{}, nil, "", errors.Wrapf(err, "failed to get groups for %v", user.Name)
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	var unenrolled []string
+	for _, user := range users {
+		if user.Username == "" || mfa.Enabled(user) || (user.Enabled != nil && !*user.Enabled) {
+			continue
+		}
+		groupPrincipals, err := l.getGroupPrincipals(user)
+		if err != nil {
+			return fmt.Errorf("failed to get groups for %v: %w", user.Name, err)
+		}
+		admin, err := l.mfa.IsAdmin(user, principalNames(groupPrincipals))
+		if err != nil {
+			return err
+		}
+		if admin {
+			unenrolled = append(unenrolled, user.Username)
+		}
+	}
+	if len(unenrolled) > 0 {
+		slices.Sort(unenrolled)
+		return fmt.Errorf("admins must enroll in multi-factor authentication before it is required, not enrolled: %s", strings.Join(unenrolled, ", "))
 	}
+	return nil
+}
 
-	return userPrincipal, groupPrincipals, "", nil
+func principalNames(principals []v3.Principal) []string {
+	names := make([]string, 0, len(principals))
+	for _, principal := range principals {
+		names = append(names, principal.Name)
+	}
+	return names
+}
+
+// checkPasswordAge requires the user to change their password at the next login once it is older than the
+// password-max-age setting.
+func (l *Provider) checkPasswordAge(user *v3.User) {
+	maxAge := settings.PasswordMaxAge.GetDuration()
+	if maxAge <= 0 || user.MustChangePassword {
+		return
+	}
+	changedAt := user.CreationTimestamp
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	if l.limiter.now().Sub(changedAt.Time) <= maxAge {
+		return
+	}
+
+	logrus.Infof("[local-auth] Password of user %s is older than %s, it must be changed", user.Username, maxAge)
+	err := updateUser(l.users, user.Name, func(user *v3.User) bool {
+		user.MustChangePassword = true
+		return true
+	})
+	if err != nil {
+		logrus.Errorf("[local-auth] Failed to require user %s to change their password: %v", user.Username, err)
+	}
 }
 
 func getLocalPrincipalID(user *v3.User) string {
@@ -1,15 +1,24 @@
 package local
 
 import (
+	"context"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/rancher/norman/httperror"
 	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
 )
 
 func TestProviderSearchPrincipal(t *testing.T) {
@@ -261,6 +270,110 @@ func TestUserSearchIndexer(t *testing.T) {
 	}
 }
 
+func TestAuthenticateUserMFA(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	enrolled := &v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-enrolled"},
+		Username:   "enrolled",
+		Password:   string(hash),
+		MFA:        &v32.UserMFA{Enabled: true, SecretName: "cattle-global-data:mfa-u-enrolled-totp"},
+	}
+	unenrolled := &v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-unenrolled"},
+		Username:   "unenrolled",
+		Password:   string(hash),
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		code      string
+		required  bool
+		wantError string
+	}{
+		{name: "not enrolled", username: "unenrolled", password: "password"},
+		{name: "required but not enrolled", username: "unenrolled", password: "password", required: true, wantError: "multi-factor authentication is required but not enrolled"},
+		{name: "missing code", username: "enrolled", password: "password", wantError: "multi-factor authentication code required"},
+		{name: "invalid code", username: "enrolled", password: "password", code: "000000", wantError: "authentication failed"},
+		{name: "valid code", username: "enrolled", password: "password", code: "123456"},
+		{name: "wrong password with valid code", username: "enrolled", password: "wrong", code: "123456", wantError: "authentication failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{userNameIndex: userNameIndexer})
+			require.NoError(t, indexer.Add(enrolled))
+			require.NoError(t, indexer.Add(unenrolled))
+			verifier := &fakeMFAVerifier{required: tt.required, validCode: "123456"}
+			provider := Provider{
+				userIndexer: indexer,
+				gmIndexer:   cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{gmPrincipalIndex: gmPIdIndexer}),
+				groupLister: fakeGroupLister{},
+				mfa:         verifier,
+				limiter:     newTestLimiter(lockoutPolicy{window: time.Minute}, time.Now),
+			}
+
+			principal, _, _, err := provider.AuthenticateUser(context.Background(), &v32.BasicLogin{
+				Username: tt.username,
+				Password: tt.password,
+				MFACode:  tt.code,
+			})
+			if tt.wantError != "" {
+				require.Error(t, err)
+				assert.True(t, httperror.IsAPIError(err))
+				assert.Contains(t, err.Error(), tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.username, principal.LoginName)
+		})
+	}
+}
+
+func TestValidateMFARequired(t *testing.T) {
+	admin := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-admin"}, Username: "admin"}
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-user"}, Username: "user"}
+	provider := Provider{
+		userLister:  fakeUserLister{users: []*v3.User{admin, user}},
+		gmIndexer:   cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{gmPrincipalIndex: gmPIdIndexer}),
+		groupLister: fakeGroupLister{},
+		mfa:         &fakeMFAVerifier{},
+	}
+
+	assert.NoError(t, provider.validateMFARequired(settings.MFARequiredNone))
+	assert.ErrorContains(t, provider.validateMFARequired(settings.MFARequiredAdmins), "not enrolled: admin")
+	assert.ErrorContains(t, provider.validateMFARequired(settings.MFARequiredAll), "not enrolled: admin")
+
+	admin.MFA = &v32.UserMFA{Enabled: true}
+	assert.NoError(t, provider.validateMFARequired(settings.MFARequiredAdmins))
+	assert.NoError(t, provider.validateMFARequired(settings.MFARequiredAll), "only admins are checked")
+
+	admin.MFA = nil
+	admin.Enabled = ptr.To(false)
+	assert.NoError(t, provider.validateMFARequired(settings.MFARequiredAdmins), "disabled admins can't log in anyway")
+}
+
+type fakeMFAVerifier struct {
+	required  bool
+	validCode string
+}
+
+func (f *fakeMFAVerifier) Required(user *v3.User, groupPrincipals []string) (bool, error) {
+	return f.required, nil
+}
+
+func (f *fakeMFAVerifier) IsAdmin(user *v3.User, groupPrincipals []string) (bool, error) {
+	return user.Name == "u-admin", nil
+}
+
+func (f *fakeMFAVerifier) Verify(userName, code string) error {
+	if code != f.validCode {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
 func newTestUserIndexer(indexed ...*v3.User) cache.Indexer {
 	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
 		userSearchIndex: userSearchIndexer,
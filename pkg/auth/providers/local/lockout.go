@@ -0,0 +1,314 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/rancher/pkg/auth/audit/annotations"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
+	"github.com/rancher/rancher/pkg/auth/util"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UserLockedOutAnnotation is the annotation of the audit log of the login that locked out a user after too many
+	// failed logins.
+	UserLockedOutAnnotation = "auth.cattle.io/local-user-locked-out"
+	// SourceLockedOutAnnotation is the annotation of the audit log of the login that locked out a source address after
+	// too many failed logins.
+	SourceLockedOutAnnotation = "auth.cattle.io/local-source-locked-out"
+	// UserUnlockedAnnotation is the annotation of the audit log of the request that lifted the lockout of a user.
+	UserUnlockedAnnotation = "auth.cattle.io/local-user-unlocked"
+
+	// maxLimiterEntries bounds the number of usernames and source addresses whose failed logins are tracked.
+	maxLimiterEntries = 10000
+)
+
+var (
+	errTooManyRequests = httperror.ErrorCode{Code: "TooManyRequests", Status: http.StatusTooManyRequests}
+
+	// addAuditAnnotation records lockouts and unlocks in the audit log of the request, replaced in tests.
+	addAuditAnnotation = annotations.Add
+)
+
+// lockoutPolicy are the limits applied to failed logins. A zero threshold disables the respective lockout.
+type lockoutPolicy struct {
+	window          time.Duration
+	userThreshold   int
+	sourceThreshold int
+	duration        time.Duration
+	maxDelay        time.Duration
+}
+
+func lockoutPolicyFromSettings() lockoutPolicy {
+	return lockoutPolicy{
+		window:          settings.LocalAuthFailureWindow.GetDuration(),
+		userThreshold:   settings.LocalAuthUserLockoutThreshold.GetInt(),
+		sourceThreshold: settings.LocalAuthSourceLockoutThreshold.GetInt(),
+		duration:        settings.LocalAuthLockoutDuration.GetDuration(),
+		maxDelay:        settings.LocalAuthMaxFailureDelay.GetDuration(),
+	}
+}
+
+// failures are the recent failed logins of a user or from a source address.
+type failures struct {
+	count       int
+	since       time.Time
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter tracks failed logins per username, per source address and per pair of both on this replica. It enforces
+// a delay doubling with each failure and locks out usernames and addresses exceeding the thresholds of the policy. At
+// most maxLimiterEntries keys are tracked, the least recently failed ones are forgotten first.
+type loginLimiter struct {
+	sync.Mutex
+
+	policy    func() lockoutPolicy
+	now       func() time.Time
+	entries   map[string]*failures
+	lastPrune time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		policy:  lockoutPolicyFromSettings,
+		now:     time.Now,
+		entries: map[string]*failures{},
+	}
+}
+
+func userKey(username string) string {
+	return "user:" + username
+}
+
+func sourceKey(source string) string {
+	return "source:" + source
+}
+
+// loginKey is the key of the failed logins to a username from a source address, which are delayed without affecting
+// logins of the user from other addresses.
+func loginKey(username, source string) string {
+	return "login:" + source + "/" + username
+}
+
+// blocked returns how long the key must wait before its next login attempt, because of a lockout or the delay after
+// its last failure.
+func (l *loginLimiter) blocked(key string) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	policy := l.policy()
+	now := l.now()
+	if wait := entry.lockedUntil.Sub(now); wait > 0 {
+		return wait
+	}
+	return entry.last.Add(failureDelay(entry.count, policy.maxDelay)).Sub(now)
+}
+
+// fail records a failed login of the key. It returns whether the key reached the threshold and is now locked out.
+func (l *loginLimiter) fail(key string, threshold int) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	policy := l.policy()
+	now := l.now()
+	l.prune(now, policy)
+
+	entry, ok := l.entries[key]
+	if !ok && len(l.entries) >= maxLimiterEntries {
+		l.evict(now, policy)
+	}
+	if !ok || now.Sub(entry.since) > policy.window {
+		entry = &failures{since: now}
+		l.entries[key] = entry
+	}
+	entry.count++
+	entry.last = now
+
+	if threshold > 0 && entry.count >= threshold {
+		entry.lockedUntil = now.Add(policy.duration)
+		entry.count = 0
+		entry.since = now
+		return true
+	}
+	return false
+}
+
+// reset forgets the failed logins of the key.
+func (l *loginLimiter) reset(key string) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.entries, key)
+}
+
+// prune drops the entries without failures within the window that are not locked out, at most once per window.
+func (l *loginLimiter) prune(now time.Time, policy lockoutPolicy) {
+	if now.Sub(l.lastPrune) < policy.window {
+		return
+	}
+	l.lastPrune = now
+	l.dropExpired(now, policy)
+}
+
+func (l *loginLimiter) dropExpired(now time.Time, policy lockoutPolicy) {
+	for key, entry := range l.entries {
+		if now.Sub(entry.last) > policy.window && now.After(entry.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// evict makes room for a new entry, dropping the expired entries or else the least recently failed one.
+func (l *loginLimiter) evict(now time.Time, policy lockoutPolicy) {
+	l.dropExpired(now, policy)
+	if len(l.entries) < maxLimiterEntries {
+		return
+	}
+	var oldest string
+	for key, entry := range l.entries {
+		if oldest == "" || entry.last.Before(l.entries[oldest].last) {
+			oldest = key
+		}
+	}
+	delete(l.entries, oldest)
+}
+
+// failureDelay returns the delay after the given number of failures, starting at one second and doubling with each
+// failure up to maxDelay.
+func failureDelay(count int, maxDelay time.Duration) time.Duration {
+	if count <= 0 || maxDelay <= 0 {
+		return 0
+	}
+	delay := time.Duration(math.Pow(2, float64(min(count-1, 32)))) * time.Second
+	return min(delay, maxDelay)
+}
+
+func tooManyAttempts(wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	return httperror.NewAPIError(errTooManyRequests, "too many failed login attempts, retry after "+strconv.Itoa(seconds)+" seconds")
+}
+
+// lockedOut returns how long the user stays locked out.
+func lockedOut(user *v3.User, now time.Time) time.Duration {
+	if user.LockedUntil == nil {
+		return 0
+	}
+	return user.LockedUntil.Sub(now)
+}
+
+// sourceOf returns the source address of the login request in the context.
+func sourceOf(ctx context.Context) string {
+	if req, ok := ctx.Value(util.RequestKey).(*http.Request); ok {
+		return scope.SourceIP(req)
+	}
+	return ""
+}
+
+// loginWait returns how long logins to the username from the source address must wait after previous failures.
+// Failures from other addresses don't delay the login, so a user can't be kept from logging in by others.
+func (l *Provider) loginWait(username, source string) time.Duration {
+	return max(l.limiter.blocked(loginKey(username, source)), l.limiter.blocked(sourceKey(source)))
+}
+
+// loginFailed records a failed login of the username from the source address. The user is nil if no user has the
+// username, the failure is counted nonetheless to not disclose which usernames exist. Users are only locked out when
+// the user lockout threshold is set, since anyone can lock out any user by failing to log in with their username.
+func (l *Provider) loginFailed(ctx context.Context, user *v3.User, username, source string) {
+	policy := l.limiter.policy()
+	l.limiter.fail(loginKey(username, source), 0)
+	if policy.userThreshold > 0 && l.limiter.fail(userKey(username), policy.userThreshold) && user != nil {
+		lockedUntil := l.limiter.now().Add(policy.duration)
+		message := fmt.Sprintf("User %s was locked out until %s after %d failed logins within %s, the last one from %s",
+			username, lockedUntil.UTC().Format(time.RFC3339), policy.userThreshold, policy.window, source)
+		logrus.Warnf("[local-auth] %s", message)
+		if err := l.lockUser(user.Name, lockedUntil); err != nil {
+			logrus.Errorf("[local-auth] Failed to lock out user %s: %v", username, err)
+		}
+		addAuditAnnotation(ctx, UserLockedOutAnnotation, message)
+	}
+
+	if source != "" && l.limiter.fail(sourceKey(source), policy.sourceThreshold) {
+		message := fmt.Sprintf("Source address %s was locked out for %s after %d failed logins within %s, the last one to user %s",
+			source, policy.duration, policy.sourceThreshold, policy.window, username)
+		logrus.Warnf("[local-auth] %s", message)
+		addAuditAnnotation(ctx, SourceLockedOutAnnotation, message)
+	}
+}
+
+// loginSucceeded forgets the failed logins of the user and clears its expired lockout.
+func (l *Provider) loginSucceeded(ctx context.Context, user *v3.User, source string) {
+	l.limiter.reset(userKey(user.Username))
+	l.limiter.reset(loginKey(user.Username, source))
+	if user.LockedUntil == nil {
+		return
+	}
+	if err := Unlock(ctx, l.users, user.Name, "the lockout expired"); err != nil {
+		logrus.Errorf("[local-auth] Failed to clear the expired lockout of user %s: %v", user.Username, err)
+	}
+}
+
+func (l *Provider) lockUser(userName string, until time.Time) error {
+	lockedUntil := metav1.NewTime(until)
+	return updateUser(l.users, userName, func(user *v3.User) bool {
+		user.LockedUntil = &lockedUntil
+		return true
+	})
+}
+
+// Unlock lifts the lockout of the user and records the reason in the audit log of the request of the context.
+func Unlock(ctx context.Context, users managementcontrollers.UserClient, userName, reason string) error {
+	var unlocked *v3.User
+	err := updateUser(users, userName, func(user *v3.User) bool {
+		if user.LockedUntil == nil {
+			return false
+		}
+		user.LockedUntil = nil
+		unlocked = user
+		return true
+	})
+	if err != nil || unlocked == nil {
+		return err
+	}
+
+	message := fmt.Sprintf("User %s was unlocked: %s", unlocked.Username, reason)
+	logrus.Infof("[local-auth] %s", message)
+	addAuditAnnotation(ctx, UserUnlockedAnnotation, message)
+	return nil
+}
+
+// updateUser applies the change to the latest version of the user, retrying on conflicts. The user is only updated if
+// change returns true.
+func updateUser(users managementcontrollers.UserClient, userName string, change func(user *v3.User) bool) error {
+	for i := 0; i < 3; i++ {
+		user, err := users.Get(userName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		user = user.DeepCopy()
+		if !change(user) {
+			return nil
+		}
+		_, err = users.Update(user)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update user %s", userName)
+}
@@ -0,0 +1,288 @@
+package local
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/audit/annotations"
+	"github.com/rancher/rancher/pkg/auth/util"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestLimiter(policy lockoutPolicy, now func() time.Time) *loginLimiter {
+	limiter := newLoginLimiter()
+	limiter.policy = func() lockoutPolicy { return policy }
+	limiter.now = now
+	return limiter
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestFailureDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), failureDelay(0, time.Minute))
+	assert.Equal(t, time.Second, failureDelay(1, time.Minute))
+	assert.Equal(t, 2*time.Second, failureDelay(2, time.Minute))
+	assert.Equal(t, 16*time.Second, failureDelay(5, time.Minute))
+	assert.Equal(t, time.Minute, failureDelay(7, time.Minute))
+	assert.Equal(t, time.Minute, failureDelay(1000, time.Minute))
+	assert.Equal(t, time.Duration(0), failureDelay(3, 0), "a zero max delay disables the delay")
+}
+
+func TestLoginLimiter(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(lockoutPolicy{
+		window:   10 * time.Minute,
+		duration: 15 * time.Minute,
+		maxDelay: 30 * time.Second,
+	}, clock.Now)
+
+	assert.Zero(t, limiter.blocked("user:admin"))
+
+	assert.False(t, limiter.fail("user:admin", 3))
+	assert.Equal(t, time.Second, limiter.blocked("user:admin"))
+	clock.Add(time.Second)
+	assert.LessOrEqual(t, limiter.blocked("user:admin"), time.Duration(0))
+
+	assert.False(t, limiter.fail("user:admin", 3))
+	assert.Equal(t, 2*time.Second, limiter.blocked("user:admin"))
+	assert.Zero(t, limiter.blocked("user:other"), "failures of a key don't delay other keys")
+
+	clock.Add(2 * time.Second)
+	assert.True(t, limiter.fail("user:admin", 3))
+	assert.Equal(t, 15*time.Minute, limiter.blocked("user:admin"))
+
+	clock.Add(15 * time.Minute)
+	assert.LessOrEqual(t, limiter.blocked("user:admin"), time.Duration(0))
+
+	limiter.reset("user:admin")
+	assert.Zero(t, limiter.blocked("user:admin"))
+}
+
+func TestLoginLimiterWindow(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(lockoutPolicy{window: time.Minute, duration: time.Hour}, clock.Now)
+
+	assert.False(t, limiter.fail("source:10.0.0.1", 2))
+	clock.Add(2 * time.Minute)
+	assert.False(t, limiter.fail("source:10.0.0.1", 2), "the first failure is outside of the window")
+	assert.True(t, limiter.fail("source:10.0.0.1", 2))
+
+	assert.False(t, limiter.fail("source:10.0.0.2", 0), "a zero threshold disables the lockout")
+
+	clock.Add(2 * time.Minute)
+	limiter.fail("source:10.0.0.3", 0)
+	assert.Contains(t, limiter.entries, "source:10.0.0.1", "entries are kept while locked out")
+	assert.NotContains(t, limiter.entries, "source:10.0.0.2")
+	assert.Contains(t, limiter.entries, "source:10.0.0.3")
+}
+
+func TestLoginLimiterBound(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(lockoutPolicy{window: time.Hour, duration: time.Hour}, clock.Now)
+
+	for i := 0; i < maxLimiterEntries; i++ {
+		limiter.fail(sourceKey(strconv.Itoa(i)), 0)
+		clock.Add(time.Millisecond)
+	}
+	require.Len(t, limiter.entries, maxLimiterEntries)
+
+	limiter.fail(sourceKey("new"), 0)
+	assert.Len(t, limiter.entries, maxLimiterEntries)
+	assert.NotContains(t, limiter.entries, sourceKey("0"), "the least recently failed entry is forgotten")
+	assert.Contains(t, limiter.entries, sourceKey("1"))
+	assert.Contains(t, limiter.entries, sourceKey("new"))
+}
+
+// recordAnnotations captures the annotations added to the audit log until the end of the test.
+func recordAnnotations(t *testing.T) *[]string {
+	var keys []string
+	addAuditAnnotation = func(_ context.Context, key, _ string) {
+		keys = append(keys, key)
+	}
+	t.Cleanup(func() {
+		addAuditAnnotation = annotations.Add
+	})
+	return &keys
+}
+
+func TestAuthenticateUserLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-admin"},
+		Username:   "admin",
+		Password:   string(hash),
+	}
+
+	users := fake.NewMockNonNamespacedClientInterface[*v3.User, *v32.UserList](ctrl)
+	users.EXPECT().Get("u-admin", gomock.Any()).DoAndReturn(func(string, metav1.GetOptions) (*v3.User, error) {
+		return user, nil
+	}).AnyTimes()
+	users.EXPECT().Update(gomock.Any()).DoAndReturn(func(updated *v3.User) (*v3.User, error) {
+		user = updated
+		return updated, nil
+	}).AnyTimes()
+	recorded := recordAnnotations(t)
+
+	provider := Provider{
+		userIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{userNameIndex: userNameIndexer}),
+		gmIndexer:   cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{gmPrincipalIndex: gmPIdIndexer}),
+		groupLister: fakeGroupLister{},
+		mfa:         &fakeMFAVerifier{},
+		limiter: newTestLimiter(lockoutPolicy{
+			window:          time.Hour,
+			userThreshold:   2,
+			sourceThreshold: 4,
+			duration:        time.Hour,
+			maxDelay:        time.Second,
+		}, clock.Now),
+		users: users,
+	}
+	source := "10.0.0.1"
+	login := func(username, password string) error {
+		// The indexer serves the latest version of the user, like the cache would.
+		require.NoError(t, provider.userIndexer.Update(user))
+		req := httptest.NewRequest("POST", "/v3-public/localProviders/local?action=login", nil)
+		req.RemoteAddr = source + ":1234"
+		ctx := context.WithValue(context.Background(), util.RequestKey, req)
+		_, _, _, err := provider.AuthenticateUser(ctx, &v32.BasicLogin{Username: username, Password: password})
+		clock.Add(2 * time.Second)
+		return err
+	}
+	require.NoError(t, provider.userIndexer.Add(user))
+
+	require.ErrorContains(t, login("admin", "wrong"), "authentication failed")
+	require.ErrorContains(t, login("admin", "wrong"), "authentication failed")
+	require.NotNil(t, user.LockedUntil)
+	assert.Equal(t, []string{UserLockedOutAnnotation}, *recorded)
+
+	// Locked out users get the same response as unknown usernames, even with the right password.
+	require.ErrorContains(t, login("admin", "password"), "authentication failed")
+	assert.Equal(t, []string{UserLockedOutAnnotation}, *recorded, "failures while locked out don't extend the lockout")
+
+	clock.Add(time.Hour)
+	require.NoError(t, login("admin", "password"))
+	assert.Nil(t, user.LockedUntil)
+	assert.Equal(t, []string{UserLockedOutAnnotation, UserUnlockedAnnotation}, *recorded)
+
+	// Unknown usernames count towards the lockout of the source address.
+	require.ErrorContains(t, login("nobody", "wrong"), "authentication failed")
+	require.ErrorContains(t, login("nobody2", "wrong"), "authentication failed")
+	require.ErrorContains(t, login("nobody3", "wrong"), "authentication failed")
+	require.ErrorContains(t, login("nobody4", "wrong"), "authentication failed")
+	err = login("admin", "password")
+	require.Error(t, err)
+	assert.Equal(t, errTooManyRequests, err.(*httperror.APIError).Code)
+	assert.Equal(t, []string{UserLockedOutAnnotation, UserUnlockedAnnotation, SourceLockedOutAnnotation}, *recorded)
+
+	// Logins from other addresses aren't affected.
+	source = "10.0.0.2"
+	require.NoError(t, login("admin", "password"))
+}
+
+func TestAuthenticateUserDelay(t *testing.T) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-admin"},
+		Username:   "admin",
+		Password:   string(hash),
+	}
+	recorded := recordAnnotations(t)
+
+	provider := Provider{
+		userIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{userNameIndex: userNameIndexer}),
+		gmIndexer:   cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{gmPrincipalIndex: gmPIdIndexer}),
+		groupLister: fakeGroupLister{},
+		mfa:         &fakeMFAVerifier{},
+		limiter: newTestLimiter(lockoutPolicy{
+			window:   time.Hour,
+			duration: time.Hour,
+			maxDelay: time.Minute,
+		}, clock.Now),
+	}
+	require.NoError(t, provider.userIndexer.Add(user))
+	login := func(source, password string) error {
+		req := httptest.NewRequest("POST", "/v3-public/localProviders/local?action=login", nil)
+		req.RemoteAddr = source + ":1234"
+		ctx := context.WithValue(context.Background(), util.RequestKey, req)
+		_, _, _, err := provider.AuthenticateUser(ctx, &v32.BasicLogin{Username: "admin", Password: password})
+		return err
+	}
+
+	for i := 0; i < 20; i++ {
+		require.ErrorContains(t, login("10.0.0.1", "wrong"), "authentication failed")
+		clock.Add(time.Minute)
+	}
+	assert.Nil(t, user.LockedUntil, "users aren't locked out unless the user lockout threshold is set")
+	assert.Empty(t, *recorded)
+
+	require.ErrorContains(t, login("10.0.0.1", "wrong"), "authentication failed")
+	err = login("10.0.0.1", "password")
+	require.Error(t, err)
+	assert.Equal(t, errTooManyRequests, err.(*httperror.APIError).Code, "failures delay logins from the same address")
+	require.NoError(t, login("10.0.0.2", "password"), "failures from other addresses don't delay the login")
+}
+
+func TestUnlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	lockedUntil := metav1.NewTime(time.Now().Add(time.Hour))
+	user := &v3.User{
+		ObjectMeta:  metav1.ObjectMeta{Name: "u-admin"},
+		Username:    "admin",
+		LockedUntil: &lockedUntil,
+	}
+
+	users := fake.NewMockNonNamespacedClientInterface[*v3.User, *v32.UserList](ctrl)
+	users.EXPECT().Get("u-admin", gomock.Any()).DoAndReturn(func(string, metav1.GetOptions) (*v3.User, error) {
+		return user, nil
+	}).Times(2)
+	users.EXPECT().Update(gomock.Any()).DoAndReturn(func(updated *v3.User) (*v3.User, error) {
+		user = updated
+		return updated, nil
+	})
+	var messages []string
+	addAuditAnnotation = func(_ context.Context, key, value string) {
+		assert.Equal(t, UserUnlockedAnnotation, key)
+		messages = append(messages, value)
+	}
+	t.Cleanup(func() {
+		addAuditAnnotation = annotations.Add
+	})
+
+	require.NoError(t, Unlock(context.Background(), users, "u-admin", "unlocked by u-other"))
+	assert.Nil(t, user.LockedUntil)
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "unlocked by u-other")
+
+	// Unlocking a user which isn't locked out is a no-op.
+	require.NoError(t, Unlock(context.Background(), users, "u-admin", "unlocked by u-other"))
+	assert.Len(t, messages, 1)
+}
@@ -12,11 +12,13 @@
 	"github.com/rancher/norman/httperror"
 	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/auth/accessor"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	exttokenstore "github.com/rancher/rancher/pkg/ext/stores/tokens"
 	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -47,6 +49,8 @@ type AuthenticatorResponse struct {
 	UserPrincipal string
 	Groups        []string
 	Extras        map[string][]string
+	// Scope is the scope of the ext token that authenticated the request, or nil if the token is not scoped.
+	Scope *ext.TokenScope
 }
 
 // ClusterRouter returns the cluster ID based on the request URL's path.
@@ -65,21 +69,35 @@ type tokenAuthenticator struct {
 	extTokenStore       *exttokenstore.SystemStore
 }
 
-// ToAuthMiddleware converts an Authenticator to an auth.Middleware.
+// ToAuthMiddleware converts an Authenticator to an auth.Middleware. The scope of the token that authenticated the
+// request is set in the context of the request passed on, so that the handlers issuing tokens and the cluster proxy
+// can enforce it.
 func ToAuthMiddleware(a Authenticator) auth.Middleware {
-	f := func(req *http.Request) (user.Info, bool, error) {
-		authResp, err := a.Authenticate(req)
-		if err != nil {
-			return nil, false, err
-		}
-		return &user.DefaultInfo{
-			Name:   authResp.User,
-			UID:    authResp.User,
-			Groups: authResp.Groups,
-			Extra:  authResp.Extras,
-		}, authResp.IsAuthed, err
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			var tokenScope *ext.TokenScope
+			f := func(req *http.Request) (user.Info, bool, error) {
+				authResp, err := a.Authenticate(req)
+				if err != nil {
+					return nil, false, err
+				}
+				tokenScope = authResp.Scope
+				return &user.DefaultInfo{
+					Name:   authResp.User,
+					UID:    authResp.User,
+					Groups: authResp.Groups,
+					Extra:  authResp.Extras,
+				}, authResp.IsAuthed, err
+			}
+			scoped := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if tokenScope != nil {
+					req = req.WithContext(authcontext.SetTokenScope(req.Context(), tokenScope))
+				}
+				next.ServeHTTP(rw, req)
+			})
+			auth.ToMiddleware(auth.AuthenticatorFunc(f))(scoped).ServeHTTP(rw, req)
+		})
 	}
-	return auth.ToMiddleware(auth.AuthenticatorFunc(f))
 }
 
 // NewAuthenticator creates a new token authenticator instance.
@@ -130,6 +148,13 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	if cluster != "" && cluster != a.clusterRouter(req) {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "clusterID does not match")
 	}
+	var tokenScope *ext.TokenScope
+	if extToken, ok := token.(*ext.Token); ok && extToken.IsScoped() {
+		if err := scope.Authorize(extToken.Spec.Scope, req, a.clusterRouter(req)); err != nil {
+			return nil, errors.Wrapf(ErrMustAuthenticate, "%v", err)
+		}
+		tokenScope = extToken.Spec.Scope
+	}
 
 	// If the auth provider is specified make sure it exists and enabled.
 	if token.GetAuthProvider() != "" {
@@ -204,15 +229,17 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 		UserPrincipal: token.GetUserPrincipal().Name,
 		Groups:        groups,
 		Extras:        extras,
+		Scope:         tokenScope,
 	}
 
 	logrus.Debugf("Extras returned %v", authResp.Extras)
 
 	now := a.now().Truncate(time.Second) // Use the second precision.
 	lastUsed := token.GetLastUsedAt()
-	if lastUsed != nil {
+	if _, isExtToken := token.(*ext.Token); lastUsed != nil && !isExtToken {
 		if now.Equal(lastUsed.Time.Truncate(time.Second)) {
-			// Throttle subsecond updates.
+			// Throttle subsecond updates. The usage of ext tokens is
+			// throttled per source address by the token store instead.
 			return authResp, nil
 		}
 	}
@@ -236,7 +263,11 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 			_, err = a.tokenClient.Patch(token.GetName(), types.JSONPatchType, patch)
 			return err
 		case *ext.Token:
-			return a.extTokenStore.UpdateLastUsedAt(token.GetName(), now)
+			clusterID := a.clusterRouter(req)
+			if clusterID == "" {
+				clusterID = scope.LocalCluster
+			}
+			return a.extTokenStore.RecordUsage(token.GetName(), now, scope.SourceIP(req), req.UserAgent(), clusterID)
 		}
 		return fmt.Errorf("unknown token type")
 	}(); err != nil {
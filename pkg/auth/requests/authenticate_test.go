@@ -13,6 +13,7 @@
 	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
 	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/accessor"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
@@ -795,32 +796,51 @@ func TestTokenAuthenticatorAuthenticateExtToken(t *testing.T) {
 		assert.Equal(t, userID, userRefresher.userID)
 		assert.False(t, userRefresher.force)
 		require.NotEmpty(t, patchData)
+
+		var patch []struct {
+			Op    string `json:"op"`
+			Path  string `json:"path"`
+			Value []byte `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(patchData, &patch))
+		require.Len(t, patch, 3)
+		assert.Equal(t, "/metadata/resourceVersion", patch[0].Path)
+		assert.Equal(t, "/data/usage", patch[1].Path)
+		var records []ext.TokenUsageRecord
+		require.NoError(t, json.Unmarshal(patch[1].Value, &records))
+		require.Len(t, records, 1)
+		assert.Equal(t, "192.0.2.1", records[0].SourceIP)
+		assert.Equal(t, "local", records[0].ClusterID)
+		assert.Equal(t, "/data/last-used-at", patch[2].Path)
 	})
 
-	t.Run("subsecond lastUsedAt updates are throttled", func(t *testing.T) {
-		oldTokenLastUsedAt := tokenSecret.Data["last-used-at"]
-		defer func() {
-			tokenSecret.Data["last-used-at"] = oldTokenLastUsedAt
-		}()
-		tokenSecret.Data["last-used-at"] = []byte(now.
-			Truncate(time.Second).
-			Format(time.RFC3339))
+	t.Run("subsecond usage updates are throttled per source address", func(t *testing.T) {
 		patchData = nil
 		userRefresher.reset()
 
+		// The use from the same address within the same second was recorded by the previous test.
 		resp, err := authenticator.Authenticate(req)
 		require.NoError(t, err)
 		require.NotNil(t, resp)
 		require.Empty(t, patchData)
+
+		otherReq := req.Clone(req.Context())
+		otherReq.RemoteAddr = "192.0.2.2:1234"
+		resp, err = authenticator.Authenticate(otherReq)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.NotEmpty(t, patchData)
 	})
 
-	t.Run("past and future lastUsedAt are updated", func(t *testing.T) {
+	t.Run("usage is updated regardless of lastUsedAt", func(t *testing.T) {
 		oldTokenLastUsedAt := tokenSecret.Data["last-used-at"]
 		defer func() {
 			tokenSecret.Data["last-used-at"] = oldTokenLastUsedAt
+			authenticator.now = func() time.Time { return now }
 		}()
+		authenticator.now = func() time.Time { return now.Add(time.Second) }
 		tokenSecret.Data["last-used-at"] = []byte(now.
-			Add(-time.Second).
+			Add(time.Second).
 			Truncate(time.Second).
 			Format(time.RFC3339))
 		patchData = nil
@@ -830,17 +850,6 @@ func TestTokenAuthenticatorAuthenticateExtToken(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, resp)
 		require.NotEmpty(t, patchData)
-
-		tokenSecret.Data["last-used-at"] = []byte(now.
-			Add(time.Second).
-			Truncate(time.Second).
-			Format(time.RFC3339))
-		patchData = nil
-
-		resp, err = authenticator.Authenticate(req)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.NotEmpty(t, patchData)
 	})
 
 	t.Run("error updating lastUsedAt doesn't fail the request", func(t *testing.T) {
@@ -1166,6 +1175,44 @@ func TestTokenAuthenticatorAuthenticateExtToken(t *testing.T) {
 		assert.False(t, userRefresher.called)
 	})
 
+	t.Run("scoped token allows the request", func(t *testing.T) {
+		defer delete(tokenSecret.Data, exttokenstore.FieldScope)
+		tokenSecret.Data[exttokenstore.FieldScope] = []byte(`{"clusters":["c-1"],"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["list"]}]}`)
+
+		userRefresher.reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/k8s/clusters/c-1/api/v1/namespaces/default/pods", nil)
+		req.Header.Set("Authorization", "Bearer ext/"+token.Name+":"+tokenValue)
+
+		resp, err := authenticator.Authenticate(req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		assert.True(t, resp.IsAuthed)
+		assert.Equal(t, []string{"c-1"}, resp.Scope.Clusters)
+		assert.Nil(t, authcontext.GetTokenScope(req.Context()), "the request of the caller is not modified")
+	})
+
+	t.Run("scoped token denies the request", func(t *testing.T) {
+		defer delete(tokenSecret.Data, exttokenstore.FieldScope)
+		tokenSecret.Data[exttokenstore.FieldScope] = []byte(`{"clusters":["c-1"],"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["list"]}]}`)
+
+		for _, path := range []string{
+			"/k8s/clusters/c-2/api/v1/namespaces/default/pods",
+			"/k8s/clusters/c-1/api/v1/namespaces/default/secrets",
+			"/v1/namespaces",
+		} {
+			userRefresher.reset()
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer ext/"+token.Name+":"+tokenValue)
+
+			resp, err := authenticator.Authenticate(req)
+			require.ErrorIs(t, err, ErrMustAuthenticate, path)
+			require.Nil(t, resp)
+			assert.False(t, userRefresher.called)
+		}
+	})
+
 	t.Run("failed to verify token: mismatched 2", func(t *testing.T) {
 		userRefresher.reset()
 
@@ -0,0 +1,378 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filter is a parsed SCIM filter expression, see RFC 7644 section 3.4.2.2.
+type filter interface {
+	matches(resource map[string]interface{}) bool
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right filter
+}
+
+func (f logicalFilter) matches(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.matches(resource) && f.right.matches(resource)
+	}
+	return f.left.matches(resource) || f.right.matches(resource)
+}
+
+type notFilter struct {
+	filter filter
+}
+
+func (f notFilter) matches(resource map[string]interface{}) bool {
+	return !f.filter.matches(resource)
+}
+
+type attributeFilter struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+func (f attributeFilter) matches(resource map[string]interface{}) bool {
+	values := resolve(resource, f.path)
+	if f.op == "pr" {
+		for _, value := range values {
+			if value != nil && value != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == "ne" {
+		return !(attributeFilter{path: f.path, op: "eq", value: f.value}).matches(resource)
+	}
+	if len(values) == 0 {
+		return f.op == "eq" && f.value == nil
+	}
+	for _, value := range values {
+		if compare(value, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter matches if an element of the multi-valued attribute at path matches the filter.
+type valuePathFilter struct {
+	path   []string
+	filter filter
+}
+
+func (f valuePathFilter) matches(resource map[string]interface{}) bool {
+	for _, value := range resolve(resource, f.path) {
+		if element, ok := value.(map[string]interface{}); ok && f.filter.matches(element) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare applies the comparison operator to the attribute value and the value of the filter. Strings are compared
+// case-insensitively.
+func compare(value interface{}, op string, filterValue interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		fv, ok := filterValue.(string)
+		if !ok {
+			return false
+		}
+		v, fv = strings.ToLower(v), strings.ToLower(fv)
+		switch op {
+		case "eq":
+			return v == fv
+		case "co":
+			return strings.Contains(v, fv)
+		case "sw":
+			return strings.HasPrefix(v, fv)
+		case "ew":
+			return strings.HasSuffix(v, fv)
+		case "gt":
+			return v > fv
+		case "ge":
+			return v >= fv
+		case "lt":
+			return v < fv
+		case "le":
+			return v <= fv
+		}
+	case bool:
+		fv, ok := filterValue.(bool)
+		return ok && op == "eq" && v == fv
+	case float64:
+		fv, ok := filterValue.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return v == fv
+		case "gt":
+			return v > fv
+		case "ge":
+			return v >= fv
+		case "lt":
+			return v < fv
+		case "le":
+			return v <= fv
+		}
+	}
+	return false
+}
+
+// resolve returns the values at the attribute path of the resource, descending into multi-valued attributes.
+// Attribute names are case-insensitive.
+func resolve(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if values, ok := value.([]interface{}); ok {
+			return values
+		}
+		return []interface{}{value}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if strings.EqualFold(key, path[0]) {
+				return resolve(child, path[1:])
+			}
+		}
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			values = append(values, resolve(element, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// attributePath splits an attribute path like name.givenName into its parts, dropping the schema URN prefix of fully
+// qualified paths.
+func attributePath(attribute string) []string {
+	if i := strings.LastIndex(attribute, ":"); i >= 0 {
+		attribute = attribute[i+1:]
+	}
+	return strings.Split(attribute, ".")
+}
+
+// toMap converts a resource to the generic representation filters are applied to.
+func toMap(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	return result, json.Unmarshal(data, &result)
+}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// parseFilter parses a SCIM filter expression.
+func parseFilter(expression string) (filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("()[]", c) >= 0:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expression) && expression[end] != '"'; end++ {
+				if expression[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(expression[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string in filter: %w", err)
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(expression) && !unicode.IsSpace(rune(expression[end])) && strings.IndexByte("()[]\"", expression[end]) < 0; end++ {
+			}
+			tokens = append(tokens, token{text: expression[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *filterParser) expect(text string) error {
+	if !p.peekKeyword(text) {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (filter, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	if p.peekKeyword("not") {
+		p.pos++
+		f, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{filter: f}, nil
+	}
+	if p.peekKeyword("(") {
+		return p.parseGroup()
+	}
+
+	attribute := p.tokens[p.pos]
+	if attribute.quoted {
+		return nil, fmt.Errorf("expected attribute, got %q", attribute.text)
+	}
+	p.pos++
+	path := attributePath(attribute.text)
+
+	if p.peekKeyword("[") {
+		p.pos++
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return valuePathFilter{path: path, filter: f}, nil
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected operator after %q", attribute.text)
+	}
+	op := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+	if op == "pr" {
+		return attributeFilter{path: path, op: op}, nil
+	}
+	if !comparisonOperators[op] {
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected value after %q", op)
+	}
+	value, err := parseValue(p.tokens[p.pos])
+	if err != nil {
+		return nil, err
+	}
+	p.pos++
+	return attributeFilter{path: path, op: op, value: value}, nil
+}
+
+func (p *filterParser) parseGroup() (filter, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseValue(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", t.text)
+	}
+	return number, nil
+}
@@ -0,0 +1,79 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	resource := map[string]interface{}{
+		"userName":    "Alice@example.com",
+		"displayName": "Alice",
+		"active":      true,
+		"name":        map[string]interface{}{"givenName": "Alice", "familyName": "Smith"},
+		"emails": []interface{}{
+			map[string]interface{}{"type": "work", "value": "alice@example.com"},
+			map[string]interface{}{"type": "home", "value": "alice@home.example"},
+		},
+		"meta": map[string]interface{}{"version": 3.0},
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: `userName eq "alice@example.com"`, want: true},
+		{filter: `USERNAME EQ "ALICE@EXAMPLE.COM"`, want: true},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice@example.com"`, want: true},
+		{filter: `userName eq "bob@example.com"`, want: false},
+		{filter: `userName ne "bob@example.com"`, want: true},
+		{filter: `userName sw "alice"`, want: true},
+		{filter: `userName ew ".com"`, want: true},
+		{filter: `userName co "@"`, want: true},
+		{filter: `name.familyName eq "Smith"`, want: true},
+		{filter: `active eq true`, want: true},
+		{filter: `active eq false`, want: false},
+		{filter: `meta.version ge 3`, want: true},
+		{filter: `meta.version lt 3`, want: false},
+		{filter: `externalId pr`, want: false},
+		{filter: `displayName pr`, want: true},
+		{filter: `externalId eq null`, want: true},
+		{filter: `emails.value eq "alice@home.example"`, want: true},
+		{filter: `emails[type eq "work" and value co "example.com"]`, want: true},
+		{filter: `emails[type eq "work" and value co "home"]`, want: false},
+		{filter: `userName eq "bob" or displayName eq "Alice"`, want: true},
+		{filter: `userName eq "bob" or displayName eq "Alice" and active eq false`, want: false},
+		{filter: `(userName eq "bob" or displayName eq "Alice") and active eq true`, want: true},
+		{filter: `not (userName eq "bob")`, want: true},
+		{filter: `displayName eq "say \"hi\""`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.matches(resource))
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName foo "bar"`,
+		`userName eq "unterminated`,
+		`(userName eq "a"`,
+		`emails[type eq "work"`,
+		`userName eq "a" extra`,
+		`"userName" eq "a"`,
+		`userName eq bar`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := parseFilter(filter)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package scim
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/cognito"
+	"github.com/rancher/rancher/pkg/auth/providers/genericoidc"
+	"github.com/rancher/rancher/pkg/auth/providers/keycloakoidc"
+	"github.com/rancher/rancher/pkg/auth/providers/oidc"
+	"github.com/rancher/rancher/pkg/auth/providers/saml"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type groupResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	DisplayName string      `json:"displayName"`
+	Members     []reference `json:"members"`
+	Meta        meta        `json:"meta"`
+}
+
+type groupInput struct {
+	DisplayName string      `json:"displayName"`
+	Members     []reference `json:"members"`
+}
+
+// groupProviders are the auth providers SCIM is served for. Their group principals are identified by the name of the
+// group asserted on login, <provider>_group://<name>, so the groups provisioned through SCIM match the groups of the
+// users logging in. Other providers identify groups by IDs or distinguished names which SCIM clients don't send.
+var groupProviders = map[string]bool{
+	saml.PingName:       true,
+	saml.ADFSName:       true,
+	saml.KeyCloakName:   true,
+	saml.OKTAName:       true,
+	saml.ShibbolethName: true,
+	oidc.Name:           true,
+	keycloakoidc.Name:   true,
+	genericoidc.Name:    true,
+	cognito.Name:        true,
+}
+
+func groupPrincipalPrefix(provider string) string {
+	return provider + "_group://"
+}
+
+// groupID returns the ID of the group with the name. Groups are identified by their name, which isn't necessarily
+// safe to use in URLs.
+func groupID(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func groupName(id string) (string, error) {
+	name, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(name) == 0 {
+		return "", newError(http.StatusNotFound, "", fmt.Sprintf("group %s not found", id))
+	}
+	return string(name), nil
+}
+
+// groupMembers returns the names of the members of the groups of the provider by group name.
+func (h *Handler) groupMembers(provider string) (map[string][]string, error) {
+	attributes, err := h.userAttributeCache.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	members := map[string][]string{}
+	for _, attribs := range attributes {
+		for _, principal := range attribs.GroupPrincipals[provider].Items {
+			if group, ok := strings.CutPrefix(principal.Name, groupPrincipalPrefix(provider)); ok {
+				members[group] = append(members[group], attribs.Name)
+			}
+		}
+	}
+	return members, nil
+}
+
+func (h *Handler) toGroupResource(req *request, name string, members []string) groupResource {
+	resource := groupResource{
+		Schemas:     []string{groupSchema},
+		ID:          groupID(name),
+		DisplayName: name,
+		Members:     []reference{},
+		Meta: meta{
+			ResourceType: "Group",
+			Location:     req.baseURL + "/Groups/" + groupID(name),
+		},
+	}
+	slices.Sort(members)
+	for _, member := range members {
+		ref := reference{Value: member, Ref: req.baseURL + "/Users/" + member}
+		if user, err := h.userCache.Get(member); err == nil {
+			ref.Display = user.DisplayName
+		}
+		resource.Members = append(resource.Members, ref)
+	}
+	return resource
+}
+
+func (h *Handler) listGroups(req *request) (int, interface{}, error) {
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resources []groupResource
+	for name, groupMembers := range members {
+		resources = append(resources, h.toGroupResource(req, name, groupMembers))
+	}
+	response, err := list(req, resources, func(g groupResource) string { return g.ID })
+	return http.StatusOK, response, err
+}
+
+// getGroup returns the group of the request. Groups without members are not stored, they are returned nonetheless
+// since the group may exist in the identity provider.
+func (h *Handler) getGroup(req *request) (int, interface{}, error) {
+	name, err := groupName(req.id)
+	if err != nil {
+		return 0, nil, err
+	}
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, h.toGroupResource(req, name, members[name]), nil
+}
+
+func (h *Handler) createGroup(req *request) (int, interface{}, error) {
+	input := &groupInput{}
+	if err := decode(req, input); err != nil {
+		return 0, nil, err
+	}
+	if input.DisplayName == "" {
+		return 0, nil, newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(members[input.DisplayName]) > 0 {
+		return 0, nil, newError(http.StatusConflict, "uniqueness", fmt.Sprintf("group %s already exists", input.DisplayName))
+	}
+
+	desired, err := memberNames(input.Members)
+	if err != nil {
+		return 0, nil, err
+	}
+	updated, err := h.setMembers(req.provider, input.DisplayName, nil, desired)
+	if err != nil {
+		return 0, nil, err
+	}
+	logrus.Infof("[scim] Provisioned group %s of auth provider %s", input.DisplayName, req.provider)
+	return http.StatusCreated, h.toGroupResource(req, input.DisplayName, updated), nil
+}
+
+func (h *Handler) replaceGroup(req *request) (int, interface{}, error) {
+	name, err := groupName(req.id)
+	if err != nil {
+		return 0, nil, err
+	}
+	input := &groupInput{}
+	if err := decode(req, input); err != nil {
+		return 0, nil, err
+	}
+	if input.DisplayName != "" && input.DisplayName != name {
+		return 0, nil, newError(http.StatusBadRequest, "mutability", "displayName can't be changed")
+	}
+	desired, err := memberNames(input.Members)
+	if err != nil {
+		return 0, nil, err
+	}
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	updated, err := h.setMembers(req.provider, name, members[name], desired)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, h.toGroupResource(req, name, updated), nil
+}
+
+func (h *Handler) patchGroup(req *request) (int, interface{}, error) {
+	name, err := groupName(req.id)
+	if err != nil {
+		return 0, nil, err
+	}
+	patch, err := decodePatch(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	desired, err := patchMembers(patch, name, members[name])
+	if err != nil {
+		return 0, nil, err
+	}
+	updated, err := h.setMembers(req.provider, name, members[name], desired)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, h.toGroupResource(req, name, updated), nil
+}
+
+func (h *Handler) deleteGroup(req *request) (int, interface{}, error) {
+	name, err := groupName(req.id)
+	if err != nil {
+		return 0, nil, err
+	}
+	members, err := h.groupMembers(req.provider)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := h.setMembers(req.provider, name, members[name], nil); err != nil {
+		return 0, nil, err
+	}
+	logrus.Infof("[scim] Deleted group %s of auth provider %s", name, req.provider)
+	return http.StatusNoContent, nil, nil
+}
+
+// patchMembers returns the members of the group after applying the PATCH operations to its current members.
+func patchMembers(patch *patchRequest, name string, current []string) ([]string, error) {
+	members := slices.Clone(current)
+	apply := func(op, path string, value json.RawMessage) error {
+		lowerPath := strings.ToLower(path)
+		switch {
+		case lowerPath == "displayname":
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil || s != name {
+				return newError(http.StatusBadRequest, "mutability", "displayName can't be changed")
+			}
+			return nil
+		case lowerPath == "members":
+			var refs []reference
+			if len(value) > 0 && string(value) != "null" {
+				if err := json.Unmarshal(value, &refs); err != nil {
+					return newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid members: %v", err))
+				}
+			}
+			names, err := memberNames(refs)
+			if err != nil {
+				return err
+			}
+			switch op {
+			case "add":
+				members = append(members, names...)
+			case "replace":
+				members = names
+			case "remove":
+				if len(refs) == 0 {
+					members = nil
+				} else {
+					members = slices.DeleteFunc(members, func(m string) bool { return slices.Contains(names, m) })
+				}
+			}
+			return nil
+		case strings.HasPrefix(lowerPath, "members["):
+			// A value path like members[value eq "u-abcde"] selecting the members to remove.
+			if op != "remove" {
+				return newError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %s for %s", path, op))
+			}
+			f, err := parseFilter(path)
+			if err != nil {
+				return newError(http.StatusBadRequest, "invalidPath", err.Error())
+			}
+			members = slices.DeleteFunc(members, func(m string) bool {
+				return f.matches(map[string]interface{}{"members": []interface{}{map[string]interface{}{"value": m}}})
+			})
+			return nil
+		}
+		return newError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %s", path))
+	}
+
+	for _, operation := range patch.Operations {
+		if operation.Path != "" {
+			if err := apply(operation.Op, operation.Path, operation.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "the value of an operation without path must be an object")
+		}
+		for attribute, value := range values {
+			if err := apply(operation.Op, attribute, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return members, nil
+}
+
+func memberNames(refs []reference) ([]string, error) {
+	var names []string
+	for _, ref := range refs {
+		if ref.Value == "" {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "member without value")
+		}
+		names = append(names, ref.Value)
+	}
+	return names, nil
+}
+
+// setMembers adds the group to the UserAttributes of the desired members and removes it from the other current
+// members. It returns the members of the group.
+func (h *Handler) setMembers(provider, name string, current, desired []string) ([]string, error) {
+	for _, member := range desired {
+		if slices.Contains(current, member) {
+			continue
+		}
+		user, err := h.userCache.Get(member)
+		if apierrors.IsNotFound(err) {
+			return nil, newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("user %s not found", member))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := userName(user, provider); !ok {
+			return nil, newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("user %s is not a user of auth provider %s", member, provider))
+		}
+		if err := h.updateGroupPrincipals(user, provider, name, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, member := range current {
+		if slices.Contains(desired, member) {
+			continue
+		}
+		user, err := h.userCache.Get(member)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := h.updateGroupPrincipals(user, provider, name, false); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(desired)
+	return slices.Compact(desired), nil
+}
+
+// updateGroupPrincipals adds the group to or removes it from the group principals of the user, creating its
+// UserAttribute if needed. Added groups are marked as provisioned, so that logins and the auth provider refresh keep
+// them.
+func (h *Handler) updateGroupPrincipals(user *v3.User, provider, name string, member bool) error {
+	principalID := groupPrincipalPrefix(provider) + name
+	for i := 0; i < 3; i++ {
+		attribs, err := h.userAttributes.Get(user.Name, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		if create {
+			attribs = &v3.UserAttribute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: user.Name,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "management.cattle.io/v3",
+						Kind:       "User",
+						UID:        user.UID,
+						Name:       user.Name,
+					}},
+				},
+				GroupPrincipals: map[string]v3.Principals{},
+				ExtraByProvider: map[string]map[string][]string{},
+			}
+		}
+		attribs = attribs.DeepCopy()
+		if attribs.GroupPrincipals == nil {
+			attribs.GroupPrincipals = map[string]v3.Principals{}
+		}
+
+		principals := attribs.GroupPrincipals[provider]
+		i := slices.IndexFunc(principals.Items, func(p v3.Principal) bool { return p.Name == principalID })
+		switch {
+		case member && i < 0:
+			principals.Items = append(principals.Items, v3.Principal{
+				ObjectMeta:    metav1.ObjectMeta{Name: principalID},
+				DisplayName:   name,
+				Provider:      provider,
+				PrincipalType: "group",
+				MemberOf:      true,
+				ExtraInfo:     map[string]string{tokens.ProvisionedGroupExtraInfo: "true"},
+			})
+		case !member && i >= 0:
+			principals.Items = slices.Delete(principals.Items, i, i+1)
+		default:
+			return nil
+		}
+		attribs.GroupPrincipals[provider] = principals
+
+		if create {
+			_, err = h.userAttributes.Create(attribs)
+		} else {
+			_, err = h.userAttributes.Update(attribs)
+		}
+		if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update user attribute %s", user.Name)
+}
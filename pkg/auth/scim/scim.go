@@ -0,0 +1,442 @@
+// Package scim implements a SCIM 2.0 server (RFC 7643, RFC 7644) provisioning the users and groups of an auth
+// provider from the identity provider.
+//
+// Each auth provider is served under /v1-scim/<provider>. Requests are authenticated with a bearer token stored in a
+// secret in the cattle-global-data namespace labeled with cattle.io/kind=scim-auth-token and
+// authn.management.cattle.io/provider=<provider>, under the key token. Users are Rancher users with a principal of the
+// provider, their SCIM userName is the ID of the principal. Groups are the group principals of the provider, their
+// members are stored in the UserAttributes of the users. SCIM is only served for the SAML and OIDC providers, whose
+// group principals are identified by the group name.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/auth/providers"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	exttokenstore "github.com/rancher/rancher/pkg/ext/stores/tokens"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/rancher/rancher/pkg/user"
+	wcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// KindLabel and ProviderLabel select the secrets holding the bearer tokens of an auth provider.
+	KindLabel     = "cattle.io/kind"
+	ProviderLabel = "authn.management.cattle.io/provider"
+	// TokenKind is the value of KindLabel of the secrets holding bearer tokens.
+	TokenKind = "scim-auth-token"
+	// TokenField is the key of the bearer token in its secret.
+	TokenField = "token"
+
+	// ExternalIDAnnotation holds the externalId the identity provider assigned to a user.
+	ExternalIDAnnotation = "authn.management.cattle.io/scim-external-id"
+
+	// URLPrefix is the path the SCIM server is served under.
+	URLPrefix = "/v1-scim"
+
+	contentType = "application/scim+json"
+	maxResults  = 1000
+
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	resourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// extTokenStore is the subset of the ext token store used to revoke the tokens of deprovisioned users.
+type extTokenStore interface {
+	ListForUser(userName string) (*ext.TokenList, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Disable(name string) error
+}
+
+// Handler serves the SCIM API of all auth providers.
+type Handler struct {
+	users              managementcontrollers.UserClient
+	userCache          managementcontrollers.UserCache
+	userAttributes     managementcontrollers.UserAttributeClient
+	userAttributeCache managementcontrollers.UserAttributeCache
+	tokens             managementcontrollers.TokenClient
+	tokenCache         managementcontrollers.TokenCache
+	extTokens          extTokenStore
+	secrets            wcorev1.SecretCache
+	userManager        user.Manager
+	providerEnabled    func(provider string) (bool, error)
+	router             *mux.Router
+}
+
+// NewHandler returns the handler of the SCIM API.
+func NewHandler(scaledContext *config.ScaledContext) *Handler {
+	mgmt := scaledContext.Wrangler.Mgmt
+	h := &Handler{
+		users:              mgmt.User(),
+		userCache:          mgmt.User().Cache(),
+		userAttributes:     mgmt.UserAttribute(),
+		userAttributeCache: mgmt.UserAttribute().Cache(),
+		tokens:             mgmt.Token(),
+		tokenCache:         mgmt.Token().Cache(),
+		extTokens:          exttokenstore.NewSystemFromWrangler(scaledContext.Wrangler),
+		secrets:            scaledContext.Wrangler.Core.Secret().Cache(),
+		userManager:        scaledContext.UserManager,
+		providerEnabled:    providerEnabled,
+	}
+	h.router = h.newRouter()
+	return h
+}
+
+func (h *Handler) newRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.UseEncodedPath()
+	r := router.PathPrefix(URLPrefix + "/{provider}").Subrouter()
+	r.Use(h.authenticate)
+
+	r.Methods(http.MethodGet).Path("/ServiceProviderConfig").HandlerFunc(serviceProviderConfig)
+	r.Methods(http.MethodGet).Path("/ResourceTypes").HandlerFunc(resourceTypes)
+
+	r.Methods(http.MethodGet).Path("/Users").HandlerFunc(h.handle(h.listUsers))
+	r.Methods(http.MethodPost).Path("/Users").HandlerFunc(h.handle(h.createUser))
+	r.Methods(http.MethodGet).Path("/Users/{id}").HandlerFunc(h.handle(h.getUser))
+	r.Methods(http.MethodPut).Path("/Users/{id}").HandlerFunc(h.handle(h.replaceUser))
+	r.Methods(http.MethodPatch).Path("/Users/{id}").HandlerFunc(h.handle(h.patchUser))
+	r.Methods(http.MethodDelete).Path("/Users/{id}").HandlerFunc(h.handle(h.deleteUser))
+
+	r.Methods(http.MethodGet).Path("/Groups").HandlerFunc(h.handle(h.listGroups))
+	r.Methods(http.MethodPost).Path("/Groups").HandlerFunc(h.handle(h.createGroup))
+	r.Methods(http.MethodGet).Path("/Groups/{id}").HandlerFunc(h.handle(h.getGroup))
+	r.Methods(http.MethodPut).Path("/Groups/{id}").HandlerFunc(h.handle(h.replaceGroup))
+	r.Methods(http.MethodPatch).Path("/Groups/{id}").HandlerFunc(h.handle(h.patchGroup))
+	r.Methods(http.MethodDelete).Path("/Groups/{id}").HandlerFunc(h.handle(h.deleteGroup))
+
+	r.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeError(rw, newError(http.StatusNotFound, "", "resource not found"))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeError(rw, newError(http.StatusMethodNotAllowed, "", "method not allowed"))
+	})
+	return router
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	h.router.ServeHTTP(rw, req)
+}
+
+// authenticate only passes requests with a bearer token of an enabled auth provider.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider := mux.Vars(req)["provider"]
+		if provider == providers.LocalProvider {
+			writeError(rw, newError(http.StatusNotFound, "", "local users can't be provisioned"))
+			return
+		}
+		enabled, err := h.providerEnabled(provider)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		if !enabled {
+			writeError(rw, newError(http.StatusNotFound, "", fmt.Sprintf("auth provider %s is not enabled", provider)))
+			return
+		}
+
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(rw, newError(http.StatusUnauthorized, "", "bearer token required"))
+			return
+		}
+		valid, err := h.validToken(provider, token)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		if !valid {
+			writeError(rw, newError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// validToken returns whether the token matches one of the tokens of the provider. A provider can have multiple
+// tokens to allow their rotation.
+func (h *Handler) validToken(provider, token string) (bool, error) {
+	secrets, err := h.secrets.List(common.SecretsNamespace, labels.SelectorFromSet(labels.Set{
+		KindLabel:     TokenKind,
+		ProviderLabel: provider,
+	}))
+	if err != nil {
+		return false, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	valid := false
+	for _, secret := range secrets {
+		expected := secret.Data[TokenField]
+		if len(expected) > 0 && subtle.ConstantTimeCompare(expected, []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid, nil
+}
+
+func providerEnabled(provider string) (bool, error) {
+	if !providers.ProviderNames[provider] || !groupProviders[provider] {
+		return false, nil
+	}
+	disabled, err := providers.IsDisabledProvider(provider)
+	if err != nil {
+		return false, err
+	}
+	return !disabled, nil
+}
+
+// request is a SCIM request to a provider.
+type request struct {
+	*http.Request
+	provider string
+	id       string
+	baseURL  string
+}
+
+// handle adapts a function handling a SCIM request to an http.HandlerFunc, writing its response or error.
+func (h *Handler) handle(f func(req *request) (int, interface{}, error)) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		provider := vars["provider"]
+		status, body, err := f(&request{
+			Request:  req,
+			provider: provider,
+			id:       vars["id"],
+			baseURL:  baseURL(req, provider),
+		})
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		writeResponse(rw, status, body)
+	}
+}
+
+// baseURL returns the URL of the SCIM API of the provider.
+func baseURL(req *http.Request, provider string) string {
+	serverURL := settings.ServerURL.Get()
+	if serverURL == "" {
+		scheme := "https"
+		if req.TLS == nil && req.Header.Get("X-Forwarded-Proto") != "https" {
+			scheme = "http"
+		}
+		serverURL = scheme + "://" + req.Host
+	}
+	return strings.TrimSuffix(serverURL, "/") + URLPrefix + "/" + provider
+}
+
+func decode(req *request, into interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(into); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("invalid request body: %v", err))
+	}
+	return nil
+}
+
+func writeResponse(rw http.ResponseWriter, status int, body interface{}) {
+	rw.Header().Set("Content-Type", contentType)
+	rw.WriteHeader(status)
+	if body == nil {
+		return
+	}
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		logrus.Errorf("[scim] Failed to write response: %v", err)
+	}
+}
+
+// scimError is an error with the status and SCIM error type of its response.
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func newError(status int, scimType, detail string) *scimError {
+	return &scimError{status: status, scimType: scimType, detail: detail}
+}
+
+func (e *scimError) Error() string {
+	return e.detail
+}
+
+func writeError(rw http.ResponseWriter, err error) {
+	var serr *scimError
+	if !errors.As(err, &serr) {
+		logrus.Errorf("[scim] %v", err)
+		serr = newError(http.StatusInternalServerError, "", "internal error")
+	}
+	writeResponse(rw, serr.status, map[string]interface{}{
+		"schemas":  []string{errorSchema},
+		"status":   strconv.Itoa(serr.status),
+		"scimType": serr.scimType,
+		"detail":   serr.detail,
+	})
+}
+
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location"`
+	Version      string `json:"version,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// list filters, sorts and paginates the resources according to the filter, startIndex and count query parameters.
+func list[T any](req *request, resources []T, id func(T) string) (*listResponse, error) {
+	query := req.URL.Query()
+
+	if expression := query.Get("filter"); expression != "" {
+		f, err := parseFilter(expression)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidFilter", err.Error())
+		}
+		var matching []T
+		for _, resource := range resources {
+			m, err := toMap(resource)
+			if err != nil {
+				return nil, err
+			}
+			if f.matches(m) {
+				matching = append(matching, resource)
+			}
+		}
+		resources = matching
+	}
+	sort.Slice(resources, func(i, j int) bool { return id(resources[i]) < id(resources[j]) })
+
+	startIndex, err := queryInt(query.Get("startIndex"), 1)
+	if err != nil {
+		return nil, err
+	}
+	count, err := queryInt(query.Get("count"), maxResults)
+	if err != nil {
+		return nil, err
+	}
+	startIndex = max(startIndex, 1)
+	count = min(max(count, 0), maxResults)
+
+	page := []interface{}{}
+	for i := startIndex - 1; i < len(resources) && len(page) < count; i++ {
+		page = append(page, resources[i])
+	}
+	return &listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}, nil
+}
+
+func queryInt(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid integer %q", value))
+	}
+	return i, nil
+}
+
+func serviceProviderConfig(rw http.ResponseWriter, req *http.Request) {
+	writeResponse(rw, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{serviceProviderConfigSchema},
+		"patch":          map[string]interface{}{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+		"changePassword": map[string]interface{}{"supported": false},
+		"sort":           map[string]interface{}{"supported": false},
+		"etag":           map[string]interface{}{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with a bearer token of the auth provider",
+			"primary":     true,
+		}},
+	})
+}
+
+func resourceTypes(rw http.ResponseWriter, req *http.Request) {
+	resources := []interface{}{
+		map[string]interface{}{
+			"schemas":  []string{resourceTypeSchema},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   userSchema,
+		},
+		map[string]interface{}{
+			"schemas":  []string{resourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   groupSchema,
+		},
+	}
+	writeResponse(rw, http.StatusOK, &listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// patchRequest is a SCIM PATCH request, see RFC 7644 section 3.5.2.
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func decodePatch(req *request) (*patchRequest, error) {
+	patch := &patchRequest{}
+	if err := decode(req, patch); err != nil {
+		return nil, err
+	}
+	if len(patch.Operations) == 0 {
+		return nil, newError(http.StatusBadRequest, "invalidValue", "no operations")
+	}
+	for i := range patch.Operations {
+		patch.Operations[i].Op = strings.ToLower(patch.Operations[i].Op)
+		switch patch.Operations[i].Op {
+		case "add", "replace", "remove":
+		default:
+			return nil, newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("unsupported operation %q", patch.Operations[i].Op))
+		}
+	}
+	return patch, nil
+}
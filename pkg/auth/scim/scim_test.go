@@ -0,0 +1,484 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/user/mocks"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+)
+
+const testToken = "secret-token"
+
+type fakeExtTokens struct {
+	tokens   []ext.Token
+	deleted  []string
+	disabled []string
+}
+
+func (f *fakeExtTokens) ListForUser(userName string) (*ext.TokenList, error) {
+	return &ext.TokenList{Items: f.tokens}, nil
+}
+
+func (f *fakeExtTokens) Delete(name string, options *metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeExtTokens) Disable(name string) error {
+	f.disabled = append(f.disabled, name)
+	return nil
+}
+
+type testHandler struct {
+	*Handler
+	users              *fake.MockNonNamespacedClientInterface[*v3.User, *v3.UserList]
+	userCache          *fake.MockNonNamespacedCacheInterface[*v3.User]
+	userAttributes     *fake.MockNonNamespacedClientInterface[*v3.UserAttribute, *v3.UserAttributeList]
+	userAttributeCache *fake.MockNonNamespacedCacheInterface[*v3.UserAttribute]
+	tokens             *fake.MockNonNamespacedClientInterface[*v3.Token, *v3.TokenList]
+	tokenCache         *fake.MockNonNamespacedCacheInterface[*v3.Token]
+	extTokens          *fakeExtTokens
+	userManager        *mocks.MockManager
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	ctrl := gomock.NewController(t)
+	secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+	secrets.EXPECT().List("cattle-global-data", gomock.Any()).DoAndReturn(func(namespace string, selector labels.Selector) ([]*corev1.Secret, error) {
+		require.True(t, selector.Matches(labels.Set{KindLabel: TokenKind, ProviderLabel: "okta"}))
+		return []*corev1.Secret{
+			{Data: map[string][]byte{TokenField: []byte("old-token")}},
+			{Data: map[string][]byte{TokenField: []byte(testToken)}},
+		}, nil
+	}).AnyTimes()
+
+	th := &testHandler{
+		users:              fake.NewMockNonNamespacedClientInterface[*v3.User, *v3.UserList](ctrl),
+		userCache:          fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl),
+		userAttributes:     fake.NewMockNonNamespacedClientInterface[*v3.UserAttribute, *v3.UserAttributeList](ctrl),
+		userAttributeCache: fake.NewMockNonNamespacedCacheInterface[*v3.UserAttribute](ctrl),
+		tokens:             fake.NewMockNonNamespacedClientInterface[*v3.Token, *v3.TokenList](ctrl),
+		tokenCache:         fake.NewMockNonNamespacedCacheInterface[*v3.Token](ctrl),
+		extTokens:          &fakeExtTokens{},
+		userManager:        mocks.NewMockManager(ctrl),
+	}
+	th.Handler = &Handler{
+		users:              th.users,
+		userCache:          th.userCache,
+		userAttributes:     th.userAttributes,
+		userAttributeCache: th.userAttributeCache,
+		tokens:             th.tokens,
+		tokenCache:         th.tokenCache,
+		extTokens:          th.extTokens,
+		secrets:            secrets,
+		userManager:        th.userManager,
+		providerEnabled: func(provider string) (bool, error) {
+			return provider == "okta", nil
+		},
+	}
+	th.router = th.newRouter()
+	return th
+}
+
+func (th *testHandler) do(t *testing.T, method, path, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, "https://rancher.example.com"+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rw := httptest.NewRecorder()
+	th.ServeHTTP(rw, req)
+	assert.Equal(t, contentType, rw.Header().Get("Content-Type"))
+
+	var response map[string]interface{}
+	if rw.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	}
+	return rw.Code, response
+}
+
+func TestAuthenticate(t *testing.T) {
+	th := newTestHandler(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid token", path: "/v1-scim/okta/ServiceProviderConfig", token: testToken, wantStatus: http.StatusOK},
+		{name: "rotated token", path: "/v1-scim/okta/ServiceProviderConfig", token: "old-token", wantStatus: http.StatusOK},
+		{name: "missing token", path: "/v1-scim/okta/ServiceProviderConfig", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", path: "/v1-scim/okta/ServiceProviderConfig", token: "other", wantStatus: http.StatusUnauthorized},
+		{name: "disabled provider", path: "/v1-scim/github/ServiceProviderConfig", token: testToken, wantStatus: http.StatusNotFound},
+		{name: "local provider", path: "/v1-scim/local/ServiceProviderConfig", token: testToken, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rw := httptest.NewRecorder()
+			th.ServeHTTP(rw, req)
+			assert.Equal(t, tt.wantStatus, rw.Code)
+		})
+	}
+}
+
+func testUsers() []*v3.User {
+	return []*v3.User{
+		{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-alice", Annotations: map[string]string{ExternalIDAnnotation: "00u1"}},
+			DisplayName:  "Alice",
+			PrincipalIDs: []string{"okta_user://alice@example.com", "local://u-alice"},
+		},
+		{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-bob"},
+			DisplayName:  "Bob",
+			PrincipalIDs: []string{"okta_user://bob@example.com"},
+			Enabled:      ptr.To(false),
+		},
+		{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-carol"},
+			DisplayName:  "Carol",
+			PrincipalIDs: []string{"github_user://1234"},
+		},
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	th := newTestHandler(t)
+	th.userCache.EXPECT().List(gomock.Any()).Return(testUsers(), nil).AnyTimes()
+	th.userAttributeCache.EXPECT().Get("u-alice").Return(&v3.UserAttribute{
+		GroupPrincipals: map[string]v3.Principals{
+			"okta":   {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "okta_group://admins"}}}},
+			"github": {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "github_org://1"}}}},
+		},
+	}, nil).AnyTimes()
+	th.userAttributeCache.EXPECT().Get("u-bob").Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "u-bob")).AnyTimes()
+
+	status, response := th.do(t, http.MethodGet, "/v1-scim/okta/Users", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2.0, response["totalResults"])
+	resources := response["Resources"].([]interface{})
+	require.Len(t, resources, 2)
+	alice := resources[0].(map[string]interface{})
+	assert.Equal(t, "u-alice", alice["id"])
+	assert.Equal(t, "alice@example.com", alice["userName"])
+	assert.Equal(t, "00u1", alice["externalId"])
+	assert.Equal(t, true, alice["active"])
+	assert.Equal(t, "https://rancher.example.com/v1-scim/okta/Users/u-alice", alice["meta"].(map[string]interface{})["location"])
+	groups := alice["groups"].([]interface{})
+	require.Len(t, groups, 1)
+	assert.Equal(t, "admins", groups[0].(map[string]interface{})["display"])
+	assert.Equal(t, false, resources[1].(map[string]interface{})["active"])
+
+	status, response = th.do(t, http.MethodGet, `/v1-scim/okta/Users?filter=userName+eq+%22BOB@example.com%22`, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1.0, response["totalResults"])
+	assert.Equal(t, "u-bob", response["Resources"].([]interface{})[0].(map[string]interface{})["id"])
+
+	status, response = th.do(t, http.MethodGet, "/v1-scim/okta/Users?startIndex=2&count=5", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2.0, response["totalResults"])
+	assert.Equal(t, 1.0, response["itemsPerPage"])
+
+	status, response = th.do(t, http.MethodGet, `/v1-scim/okta/Users?filter=userName+eq`, "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalidFilter", response["scimType"])
+}
+
+func TestGetUserOfOtherProvider(t *testing.T) {
+	th := newTestHandler(t)
+	th.userCache.EXPECT().Get("u-carol").Return(testUsers()[2], nil)
+
+	status, response := th.do(t, http.MethodGet, "/v1-scim/okta/Users/u-carol", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "404", response["status"])
+}
+
+func TestCreateUser(t *testing.T) {
+	th := newTestHandler(t)
+	created := &v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-dave"},
+		DisplayName:  "Dave Jones",
+		PrincipalIDs: []string{"okta_user://dave@example.com"},
+	}
+	th.userManager.EXPECT().GetUserByPrincipalID("okta_user://dave@example.com").Return(nil, nil)
+	th.userManager.EXPECT().EnsureUser("okta_user://dave@example.com", "Dave Jones").Return(created, nil)
+	th.users.EXPECT().Get("u-dave", gomock.Any()).Return(created, nil)
+	th.users.EXPECT().Update(gomock.Any()).DoAndReturn(func(user *v3.User) (*v3.User, error) {
+		assert.Equal(t, "00u2", user.Annotations[ExternalIDAnnotation])
+		return user, nil
+	})
+	th.userAttributeCache.EXPECT().Get("u-dave").Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "u-dave"))
+
+	status, response := th.do(t, http.MethodPost, "/v1-scim/okta/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "dave@example.com",
+		"externalId": "00u2",
+		"name": {"givenName": "Dave", "familyName": "Jones"},
+		"active": true
+	}`)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "u-dave", response["id"])
+	assert.Equal(t, "dave@example.com", response["userName"])
+
+	th.userManager.EXPECT().GetUserByPrincipalID("okta_user://dave@example.com").Return(created, nil)
+	status, response = th.do(t, http.MethodPost, "/v1-scim/okta/Users", `{"userName": "dave@example.com"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "uniqueness", response["scimType"])
+}
+
+func TestPatchUserDeactivate(t *testing.T) {
+	th := newTestHandler(t)
+	alice := testUsers()[0]
+	alice.PrincipalIDs = []string{"okta_user://alice@example.com"}
+	th.userCache.EXPECT().Get("u-alice").Return(alice, nil)
+	th.users.EXPECT().Get("u-alice", gomock.Any()).Return(alice, nil)
+	th.users.EXPECT().Update(gomock.Any()).DoAndReturn(func(user *v3.User) (*v3.User, error) {
+		assert.False(t, *user.Enabled)
+		assert.Equal(t, "Alice Smith", user.DisplayName)
+		return user, nil
+	})
+	th.userAttributeCache.EXPECT().Get("u-alice").Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "u-alice"))
+
+	th.tokenCache.EXPECT().List(labels.SelectorFromSet(labels.Set{tokens.UserIDLabel: "u-alice"})).Return([]*v3.Token{
+		{ObjectMeta: metav1.ObjectMeta{Name: "token-login"}, AuthProvider: "okta"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "token-api"}, AuthProvider: "okta", IsDerived: true},
+	}, nil)
+	th.tokens.EXPECT().Delete("token-login", gomock.Any()).Return(nil)
+	th.tokens.EXPECT().Update(gomock.Any()).DoAndReturn(func(token *v3.Token) (*v3.Token, error) {
+		assert.Equal(t, "token-api", token.Name)
+		assert.False(t, *token.Enabled)
+		return token, nil
+	})
+	th.extTokens.tokens = []ext.Token{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ext-login"}, Spec: ext.TokenSpec{Kind: "session", UserPrincipal: ext.TokenPrincipal{Provider: "okta"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ext-api"}, Spec: ext.TokenSpec{Enabled: ptr.To(true), UserPrincipal: ext.TokenPrincipal{Provider: "okta"}}},
+	}
+
+	status, response := th.do(t, http.MethodPatch, "/v1-scim/okta/Users/u-alice", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "value": {"displayName": "Alice Smith", "emails[type eq \"work\"].value": "alice@example.com"}}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, response["active"])
+	assert.Equal(t, []string{"ext-login"}, th.extTokens.deleted)
+	assert.Equal(t, []string{"ext-api"}, th.extTokens.disabled)
+}
+
+func TestReplaceUserDeactivateWithOtherPrincipals(t *testing.T) {
+	th := newTestHandler(t)
+	alice := testUsers()[0]
+	th.userCache.EXPECT().Get("u-alice").Return(alice, nil)
+	th.users.EXPECT().Get("u-alice", gomock.Any()).Return(alice, nil)
+	th.users.EXPECT().Update(gomock.Any()).DoAndReturn(func(user *v3.User) (*v3.User, error) {
+		assert.Equal(t, []string{"local://u-alice"}, user.PrincipalIDs)
+		assert.Nil(t, user.Enabled, "users with other principals are not disabled")
+		return user, nil
+	})
+	th.userAttributeCache.EXPECT().Get("u-alice").Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "u-alice"))
+
+	th.tokenCache.EXPECT().List(labels.SelectorFromSet(labels.Set{tokens.UserIDLabel: "u-alice"})).Return([]*v3.Token{
+		{ObjectMeta: metav1.ObjectMeta{Name: "token-okta"}, AuthProvider: "okta"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "token-local"}, AuthProvider: "local"},
+	}, nil)
+	th.tokens.EXPECT().Delete("token-okta", gomock.Any()).Return(nil)
+	th.extTokens.tokens = []ext.Token{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ext-local"}, Spec: ext.TokenSpec{Kind: "session", UserPrincipal: ext.TokenPrincipal{Provider: "local"}}},
+	}
+
+	status, response := th.do(t, http.MethodPut, "/v1-scim/okta/Users/u-alice", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice@example.com",
+		"displayName": "Alice",
+		"externalId": "00u1",
+		"active": false
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, response["active"])
+	assert.Equal(t, "alice@example.com", response["userName"])
+	assert.Empty(t, th.extTokens.deleted)
+	assert.Empty(t, th.extTokens.disabled)
+}
+
+func TestDeleteUser(t *testing.T) {
+	th := newTestHandler(t)
+	for _, user := range testUsers()[:2] {
+		th.userCache.EXPECT().Get(user.Name).Return(user, nil)
+		th.users.EXPECT().Get(user.Name, gomock.Any()).Return(user, nil)
+		th.tokenCache.EXPECT().List(labels.SelectorFromSet(labels.Set{tokens.UserIDLabel: user.Name})).Return(nil, nil)
+	}
+	var updated []*v3.User
+	th.users.EXPECT().Update(gomock.Any()).DoAndReturn(func(user *v3.User) (*v3.User, error) {
+		updated = append(updated, user)
+		return user, nil
+	}).Times(2)
+
+	status, _ := th.do(t, http.MethodDelete, "/v1-scim/okta/Users/u-alice", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = th.do(t, http.MethodDelete, "/v1-scim/okta/Users/u-bob", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	require.Len(t, updated, 2)
+	assert.Equal(t, []string{"local://u-alice"}, updated[0].PrincipalIDs, "users with other principals are kept")
+	assert.Nil(t, updated[0].Enabled)
+	assert.Empty(t, updated[1].PrincipalIDs)
+	assert.False(t, *updated[1].Enabled, "users without principals are deactivated")
+}
+
+func TestUserPatchChanges(t *testing.T) {
+	patch := func(operations string) *patchRequest {
+		p := &patchRequest{}
+		require.NoError(t, json.Unmarshal([]byte(`{"Operations":`+operations+`}`), p))
+		return p
+	}
+
+	changes, err := userPatchChanges(patch(`[{"op":"replace","path":"externalId","value":"00u3"},{"op":"remove","path":"displayName"}]`), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "00u3", *changes.externalID)
+	assert.Equal(t, "", *changes.displayName)
+	assert.Nil(t, changes.active)
+
+	_, err = userPatchChanges(patch(`[{"op":"replace","path":"userName","value":"mallory"}]`), "alice")
+	assert.ErrorContains(t, err, "userName can't be changed")
+
+	changes, err = userPatchChanges(patch(`[{"op":"replace","path":"userName","value":"alice"}]`), "alice")
+	require.NoError(t, err)
+	assert.False(t, changes.apply(&v3.User{}))
+
+	_, err = userPatchChanges(patch(`[{"op":"replace","path":"active","value":"maybe"}]`), "alice")
+	assert.Error(t, err)
+}
+
+func TestPatchMembers(t *testing.T) {
+	patch := func(operations string) *patchRequest {
+		p := &patchRequest{}
+		require.NoError(t, json.Unmarshal([]byte(`{"Operations":`+operations+`}`), p))
+		return p
+	}
+	current := []string{"u-alice", "u-bob"}
+
+	tests := []struct {
+		name       string
+		operations string
+		want       []string
+		wantError  string
+	}{
+		{
+			name:       "add",
+			operations: `[{"op":"add","path":"members","value":[{"value":"u-carol"}]}]`,
+			want:       []string{"u-alice", "u-bob", "u-carol"},
+		},
+		{
+			name:       "remove by value path",
+			operations: `[{"op":"remove","path":"members[value eq \"u-alice\"]"}]`,
+			want:       []string{"u-bob"},
+		},
+		{
+			name:       "remove by value",
+			operations: `[{"op":"remove","path":"members","value":[{"value":"u-bob"}]}]`,
+			want:       []string{"u-alice"},
+		},
+		{
+			name:       "remove all",
+			operations: `[{"op":"remove","path":"members"}]`,
+		},
+		{
+			name:       "replace without path",
+			operations: `[{"op":"replace","value":{"displayName":"admins","members":[{"value":"u-carol"}]}}]`,
+			want:       []string{"u-carol"},
+		},
+		{
+			name:       "rename",
+			operations: `[{"op":"replace","path":"displayName","value":"operators"}]`,
+			wantError:  "displayName can't be changed",
+		},
+		{
+			name:       "member without value",
+			operations: `[{"op":"add","path":"members","value":[{"display":"Carol"}]}]`,
+			wantError:  "member without value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members, err := patchMembers(patch(tt.operations), "admins", current)
+			if tt.wantError != "" {
+				assert.ErrorContains(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, members)
+		})
+	}
+	assert.Equal(t, []string{"u-alice", "u-bob"}, current, "the current members must not be modified")
+}
+
+func TestPatchGroup(t *testing.T) {
+	th := newTestHandler(t)
+	users := testUsers()
+	th.userAttributeCache.EXPECT().List(gomock.Any()).Return([]*v3.UserAttribute{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "u-alice"},
+			GroupPrincipals: map[string]v3.Principals{
+				"okta": {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "okta_group://admins"}}}},
+			},
+		},
+	}, nil)
+	th.userCache.EXPECT().Get("u-alice").Return(users[0], nil).AnyTimes()
+	th.userCache.EXPECT().Get("u-bob").Return(users[1], nil).AnyTimes()
+
+	// Bob has no UserAttribute yet, Alice is removed from the group.
+	th.userAttributes.EXPECT().Get("u-bob", gomock.Any()).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "u-bob"))
+	th.userAttributes.EXPECT().Create(gomock.Any()).DoAndReturn(func(attribs *v3.UserAttribute) (*v3.UserAttribute, error) {
+		assert.Equal(t, "u-bob", attribs.Name)
+		require.Len(t, attribs.GroupPrincipals["okta"].Items, 1)
+		principal := attribs.GroupPrincipals["okta"].Items[0]
+		assert.Equal(t, "okta_group://admins", principal.Name)
+		assert.Equal(t, "group", principal.PrincipalType)
+		return attribs, nil
+	})
+	th.userAttributes.EXPECT().Get("u-alice", gomock.Any()).Return(&v3.UserAttribute{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-alice"},
+		GroupPrincipals: map[string]v3.Principals{
+			"okta": {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "okta_group://admins"}}}},
+		},
+	}, nil)
+	th.userAttributes.EXPECT().Update(gomock.Any()).DoAndReturn(func(attribs *v3.UserAttribute) (*v3.UserAttribute, error) {
+		assert.Equal(t, "u-alice", attribs.Name)
+		assert.Empty(t, attribs.GroupPrincipals["okta"].Items)
+		return attribs, nil
+	})
+
+	status, response := th.do(t, http.MethodPatch, "/v1-scim/okta/Groups/"+groupID("admins"), `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "add", "path": "members", "value": [{"value": "u-bob"}]},
+			{"op": "remove", "path": "members[value eq \"u-alice\"]"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "admins", response["displayName"])
+	members := response["members"].([]interface{})
+	require.Len(t, members, 1)
+	assert.Equal(t, "u-bob", members[0].(map[string]interface{})["value"])
+	assert.Equal(t, "Bob", members[0].(map[string]interface{})["display"])
+}
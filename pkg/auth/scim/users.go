@@ -0,0 +1,497 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+)
+
+type userResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Active      bool        `json:"active"`
+	Groups      []reference `json:"groups,omitempty"`
+	Meta        meta        `json:"meta"`
+}
+
+type reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type userInput struct {
+	ExternalID  string    `json:"externalId"`
+	UserName    string    `json:"userName"`
+	DisplayName string    `json:"displayName"`
+	Name        *name     `json:"name"`
+	Active      *flexBool `json:"active"`
+}
+
+type name struct {
+	Formatted  string `json:"formatted"`
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+// displayName returns the display name of the input, falling back to its name.
+func (u *userInput) displayName() string {
+	if u.DisplayName != "" || u.Name == nil {
+		return u.DisplayName
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// flexBool is a boolean also accepting the strings "true" and "false", which some identity providers send.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*b = flexBool(parsed)
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
+// userChanges are the changes of a PUT or PATCH request to a user.
+type userChanges struct {
+	externalID  *string
+	displayName *string
+	active      *bool
+}
+
+func (c userChanges) apply(user *v3.User) bool {
+	changed := false
+	if c.externalID != nil && user.Annotations[ExternalIDAnnotation] != *c.externalID {
+		if *c.externalID == "" {
+			delete(user.Annotations, ExternalIDAnnotation)
+		} else {
+			if user.Annotations == nil {
+				user.Annotations = map[string]string{}
+			}
+			user.Annotations[ExternalIDAnnotation] = *c.externalID
+		}
+		changed = true
+	}
+	if c.displayName != nil && user.DisplayName != *c.displayName {
+		user.DisplayName = *c.displayName
+		changed = true
+	}
+	if c.active != nil && ptr.Deref(user.Enabled, true) != *c.active {
+		user.Enabled = ptr.To(*c.active)
+		changed = true
+	}
+	return changed
+}
+
+func userPrincipalPrefix(provider string) string {
+	return provider + "_user://"
+}
+
+// withoutProviderPrincipals returns the principal IDs without the principals of the provider.
+func withoutProviderPrincipals(principalIDs []string, provider string) []string {
+	return slices.DeleteFunc(slices.Clone(principalIDs), func(principalID string) bool {
+		return strings.HasPrefix(principalID, userPrincipalPrefix(provider))
+	})
+}
+
+// userName returns the SCIM userName of the user, the ID of its principal of the provider.
+func userName(user *v3.User, provider string) (string, bool) {
+	for _, principalID := range user.PrincipalIDs {
+		if name, ok := strings.CutPrefix(principalID, userPrincipalPrefix(provider)); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (h *Handler) toUserResource(req *request, user *v3.User) (userResource, error) {
+	name, _ := userName(user, req.provider)
+	resource := userResource{
+		Schemas:     []string{userSchema},
+		ID:          user.Name,
+		ExternalID:  user.Annotations[ExternalIDAnnotation],
+		UserName:    name,
+		DisplayName: user.DisplayName,
+		Active:      ptr.Deref(user.Enabled, true),
+		Meta: meta{
+			ResourceType: "User",
+			Created:      user.CreationTimestamp.UTC().Format(time.RFC3339),
+			Location:     req.baseURL + "/Users/" + user.Name,
+			Version:      `W/"` + user.ResourceVersion + `"`,
+		},
+	}
+
+	attribs, err := h.userAttributeCache.Get(user.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return resource, err
+	}
+	if attribs != nil {
+		for _, principal := range attribs.GroupPrincipals[req.provider].Items {
+			if group, ok := strings.CutPrefix(principal.Name, groupPrincipalPrefix(req.provider)); ok {
+				resource.Groups = append(resource.Groups, reference{
+					Value:   groupID(group),
+					Display: group,
+					Ref:     req.baseURL + "/Groups/" + groupID(group),
+				})
+			}
+		}
+	}
+	return resource, nil
+}
+
+// providerUser returns the user of the request, which must have a principal of the provider.
+func (h *Handler) providerUser(req *request) (*v3.User, error) {
+	user, err := h.userCache.Get(req.id)
+	if apierrors.IsNotFound(err) {
+		return nil, newError(http.StatusNotFound, "", fmt.Sprintf("user %s not found", req.id))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := userName(user, req.provider); !ok {
+		return nil, newError(http.StatusNotFound, "", fmt.Sprintf("user %s not found", req.id))
+	}
+	return user, nil
+}
+
+func (h *Handler) listUsers(req *request) (int, interface{}, error) {
+	users, err := h.userCache.List(labels.Everything())
+	if err != nil {
+		return 0, nil, err
+	}
+	var resources []userResource
+	for _, user := range users {
+		if _, ok := userName(user, req.provider); !ok {
+			continue
+		}
+		resource, err := h.toUserResource(req, user)
+		if err != nil {
+			return 0, nil, err
+		}
+		resources = append(resources, resource)
+	}
+	response, err := list(req, resources, func(u userResource) string { return u.ID })
+	return http.StatusOK, response, err
+}
+
+func (h *Handler) getUser(req *request) (int, interface{}, error) {
+	user, err := h.providerUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := h.toUserResource(req, user)
+	return http.StatusOK, resource, err
+}
+
+func (h *Handler) createUser(req *request) (int, interface{}, error) {
+	input := &userInput{}
+	if err := decode(req, input); err != nil {
+		return 0, nil, err
+	}
+	if input.UserName == "" {
+		return 0, nil, newError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	principalID := userPrincipalPrefix(req.provider) + input.UserName
+	existing, err := h.userManager.GetUserByPrincipalID(principalID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if existing != nil {
+		return 0, nil, newError(http.StatusConflict, "uniqueness", fmt.Sprintf("user %s already exists", input.UserName))
+	}
+
+	user, err := h.userManager.EnsureUser(principalID, input.displayName())
+	if err != nil {
+		return 0, nil, err
+	}
+	logrus.Infof("[scim] Provisioned user %s for principal %s", user.Name, principalID)
+
+	changes := userChanges{externalID: &input.ExternalID}
+	if input.Active != nil {
+		changes.active = ptr.To(bool(*input.Active))
+	}
+	user, err = h.updateUser(user.Name, req.provider, changes)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := h.toUserResource(req, user)
+	return http.StatusCreated, resource, err
+}
+
+func (h *Handler) replaceUser(req *request) (int, interface{}, error) {
+	user, err := h.providerUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	input := &userInput{}
+	if err := decode(req, input); err != nil {
+		return 0, nil, err
+	}
+	if current, _ := userName(user, req.provider); input.UserName != "" && input.UserName != current {
+		return 0, nil, newError(http.StatusBadRequest, "mutability", "userName can't be changed")
+	}
+
+	displayName := input.displayName()
+	changes := userChanges{externalID: &input.ExternalID, displayName: &displayName}
+	if input.Active != nil {
+		changes.active = ptr.To(bool(*input.Active))
+	}
+	current, _ := userName(user, req.provider)
+	user, err = h.updateUser(user.Name, req.provider, changes)
+	if err != nil {
+		return 0, nil, err
+	}
+	return h.updatedUserResource(req, user, current)
+}
+
+func (h *Handler) patchUser(req *request) (int, interface{}, error) {
+	user, err := h.providerUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	patch, err := decodePatch(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	current, _ := userName(user, req.provider)
+	changes, err := userPatchChanges(patch, current)
+	if err != nil {
+		return 0, nil, err
+	}
+	user, err = h.updateUser(user.Name, req.provider, changes)
+	if err != nil {
+		return 0, nil, err
+	}
+	return h.updatedUserResource(req, user, current)
+}
+
+// updatedUserResource returns the resource of the updated user. A user deactivated by removing the principal of the
+// provider is reported as inactive with its former userName.
+func (h *Handler) updatedUserResource(req *request, user *v3.User, currentUserName string) (int, interface{}, error) {
+	resource, err := h.toUserResource(req, user)
+	if _, ok := userName(user, req.provider); !ok {
+		resource.UserName = currentUserName
+		resource.Active = false
+	}
+	return http.StatusOK, resource, err
+}
+
+// userPatchChanges returns the changes of the PATCH operations. Attributes not stored by Rancher, like emails, are
+// ignored.
+func userPatchChanges(patch *patchRequest, currentUserName string) (userChanges, error) {
+	changes := userChanges{}
+	set := func(op, attribute string, value json.RawMessage) error {
+		attribute = strings.ToLower(strings.Join(attributePath(attribute), "."))
+		switch attribute {
+		case "active":
+			if op == "remove" {
+				return newError(http.StatusBadRequest, "mutability", "active can't be removed")
+			}
+			var active flexBool
+			if err := json.Unmarshal(value, &active); err != nil {
+				return newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid active: %v", err))
+			}
+			changes.active = ptr.To(bool(active))
+		case "displayname", "externalid":
+			var s string
+			if op != "remove" {
+				if err := json.Unmarshal(value, &s); err != nil {
+					return newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid %s: %v", attribute, err))
+				}
+			}
+			if attribute == "displayname" {
+				changes.displayName = &s
+			} else {
+				changes.externalID = &s
+			}
+		case "username":
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil || s != currentUserName {
+				return newError(http.StatusBadRequest, "mutability", "userName can't be changed")
+			}
+		default:
+			logrus.Debugf("[scim] Ignoring unsupported user attribute %s", attribute)
+		}
+		return nil
+	}
+
+	for _, operation := range patch.Operations {
+		if operation.Path != "" {
+			if err := set(operation.Op, operation.Path, operation.Value); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return changes, newError(http.StatusBadRequest, "invalidValue", "the value of an operation without path must be an object")
+		}
+		for attribute, value := range values {
+			if err := set(operation.Op, attribute, value); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// deleteUser deprovisions the user of the provider. The user itself is never deleted, since it may have other
+// principals, like the local principal of the admin: the principal of the provider is removed from the user and the
+// tokens of the provider are revoked. Users left without principals are deactivated.
+func (h *Handler) deleteUser(req *request) (int, interface{}, error) {
+	user, err := h.providerUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := h.removePrincipal(user.Name, req.provider); err != nil {
+		return 0, nil, err
+	}
+	if err := h.revokeTokens(user.Name, req.provider); err != nil {
+		return 0, nil, fmt.Errorf("failed to revoke tokens of user %s: %w", user.Name, err)
+	}
+	logrus.Infof("[scim] Deprovisioned user %s of auth provider %s", user.Name, req.provider)
+	return http.StatusNoContent, nil, nil
+}
+
+// removePrincipal removes the principal of the provider from the latest version of the user, retrying on conflicts.
+func (h *Handler) removePrincipal(userName, provider string) error {
+	for i := 0; i < 3; i++ {
+		user, err := h.users.Get(userName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		user = user.DeepCopy()
+		user.PrincipalIDs = withoutProviderPrincipals(user.PrincipalIDs, provider)
+		if len(user.PrincipalIDs) == 0 {
+			user.Enabled = ptr.To(false)
+		}
+		_, err = h.users.Update(user)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update user %s", userName)
+}
+
+// updateUser applies the changes to the latest version of the user, retrying on conflicts. The tokens of the provider
+// are revoked if the changes deactivate the user. Like deprovisioning, deactivating a user with other principals, like
+// the local principal of the admin, only removes the principal of the provider, so that the provider can't lock the
+// user out.
+func (h *Handler) updateUser(userName, provider string, changes userChanges) (*v3.User, error) {
+	for i := 0; i < 3; i++ {
+		user, err := h.users.Get(userName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		wasActive := ptr.Deref(user.Enabled, true)
+		user = user.DeepCopy()
+
+		unlinked := false
+		attempt := changes
+		if attempt.active != nil && !*attempt.active {
+			if principalIDs := withoutProviderPrincipals(user.PrincipalIDs, provider); len(principalIDs) > 0 {
+				user.PrincipalIDs = principalIDs
+				attempt.active = nil
+				unlinked = true
+			}
+		}
+		if !attempt.apply(user) && !unlinked {
+			return user, nil
+		}
+		user, err = h.users.Update(user)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if unlinked || (wasActive && !ptr.Deref(user.Enabled, true)) {
+			logrus.Infof("[scim] Deactivated user %s of auth provider %s", user.Name, provider)
+			if err := h.revokeTokens(user.Name, provider); err != nil {
+				return nil, fmt.Errorf("failed to revoke tokens of user %s: %w", user.Name, err)
+			}
+		}
+		return user, nil
+	}
+	return nil, fmt.Errorf("unable to update user %s", userName)
+}
+
+// revokeTokens deletes the login tokens of the user issued through the provider and disables the tokens derived from
+// them, as the auth provider refresh does for users who lost access, but without waiting for the next refresh. Tokens
+// of other auth providers, like the local one, are kept.
+func (h *Handler) revokeTokens(userName, provider string) error {
+	v3Tokens, err := h.tokenCache.List(labels.SelectorFromSet(labels.Set{tokens.UserIDLabel: userName}))
+	if err != nil {
+		return err
+	}
+	for _, token := range v3Tokens {
+		if token.AuthProvider != provider {
+			continue
+		}
+		if !token.IsDerived {
+			err = h.tokens.Delete(token.Name, &metav1.DeleteOptions{})
+		} else if ptr.Deref(token.Enabled, true) {
+			token = token.DeepCopy()
+			token.Enabled = ptr.To(false)
+			_, err = h.tokens.Update(token)
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	extTokens, err := h.extTokens.ListForUser(userName)
+	if err != nil {
+		return err
+	}
+	for _, token := range extTokens.Items {
+		if token.GetAuthProvider() != provider {
+			continue
+		}
+		if !token.GetIsDerived() {
+			err = h.extTokens.Delete(token.Name, &metav1.DeleteOptions{})
+		} else if token.GetIsEnabled() {
+			err = h.extTokens.Disable(token.Name)
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -16,6 +16,7 @@
 	"github.com/rancher/norman/types/convert"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/accessor"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	"github.com/rancher/rancher/pkg/auth/util"
 	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
@@ -288,6 +289,10 @@ func (m *Manager) deriveToken(request *types.APIContext) error {
 
 	r := request.Request
 
+	if err := scope.CheckIssuance(r.Context()); err != nil {
+		return httperror.NewAPIErrorLong(http.StatusForbidden, util.GetHTTPErrorCode(http.StatusForbidden), err.Error())
+	}
+
 	tokenAuthValue := GetTokenAuthFromRequest(r)
 	if tokenAuthValue == "" {
 		// no cookie or auth header, cannot authenticate
@@ -626,6 +631,7 @@ func (m *Manager) UserAttributeCreateOrUpdate(userID, provider string, groupPrin
 		userExtraInfo = make(map[string][]string)
 	}
 
+	groupPrincipals = KeepProvisionedGroups(attribs.GroupPrincipals[provider].Items, groupPrincipals)
 	shouldUpdate := m.userAttributeChanged(attribs, provider, userExtraInfo, groupPrincipals)
 	if len(loginTime) > 0 && !loginTime[0].IsZero() {
 		// Login time is truncated to seconds as the corresponding user label is set as epoch time.
@@ -686,6 +692,34 @@ func (m *Manager) userAttributeChanged(attribs *v32.UserAttribute, provider stri
 	return !reflect.DeepEqual(attribs.ExtraByProvider[provider], extraInfo)
 }
 
+// ProvisionedGroupExtraInfo is the key of the ExtraInfo marking the group principals provisioned through SCIM rather
+// than asserted by the auth provider on login.
+const ProvisionedGroupExtraInfo = "provisioned"
+
+// KeepProvisionedGroups returns the group principals of a user asserted by the auth provider along with the
+// provisioned group principals among the existing ones, which the auth provider doesn't know about.
+func KeepProvisionedGroups(existing, groupPrincipals []v32.Principal) []v32.Principal {
+	var provisioned []v32.Principal
+	names := map[string]bool{}
+	for _, principal := range existing {
+		if principal.ExtraInfo[ProvisionedGroupExtraInfo] == "true" {
+			provisioned = append(provisioned, principal)
+			names[principal.Name] = true
+		}
+	}
+	if len(provisioned) == 0 {
+		return groupPrincipals
+	}
+
+	var result []v32.Principal
+	for _, principal := range groupPrincipals {
+		if !names[principal.Name] {
+			result = append(result, principal)
+		}
+	}
+	return append(result, provisioned...)
+}
+
 // PerUserCacheProviders is a set of provider names for which the token manager creates a per-user login token.
 var PerUserCacheProviders = []string{"github", "azuread", "googleoauth", "oidc", "keycloakoidc", "genericoidc", "cognito"}
 
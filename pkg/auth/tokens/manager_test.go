@@ -296,3 +296,19 @@ func TestUserAttributeCreateOrUpdateUpdatesGroups(t *testing.T) {
 	require.Len(t, principals.Items, 1)
 	assert.Equal(t, principals.Items[0].Name, "group1")
 }
+
+func TestKeepProvisionedGroups(t *testing.T) {
+	provisioned := v3.Principal{
+		ObjectMeta: v1.ObjectMeta{Name: "okta_group://admins"},
+		ExtraInfo:  map[string]string{ProvisionedGroupExtraInfo: "true"},
+	}
+	asserted := []v3.Principal{
+		{ObjectMeta: v1.ObjectMeta{Name: "okta_group://admins"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "okta_group://devs"}},
+	}
+	existing := []v3.Principal{provisioned, {ObjectMeta: v1.ObjectMeta{Name: "okta_group://stale"}}}
+
+	assert.Equal(t, []v3.Principal{asserted[1], provisioned}, KeepProvisionedGroups(existing, asserted))
+	assert.Equal(t, []v3.Principal{provisioned}, KeepProvisionedGroups(existing, nil))
+	assert.Equal(t, asserted, KeepProvisionedGroups(existing[1:], asserted))
+}
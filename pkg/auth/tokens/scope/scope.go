@@ -0,0 +1,273 @@
+// Package scope enforces the scope of ext tokens, which restricts the clusters, Kubernetes API requests and source
+// addresses a token can be used for.
+package scope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
+	"github.com/rancher/rancher/pkg/settings"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// LocalCluster is the cluster ID requests that are not routed to a downstream cluster are attributed to.
+const LocalCluster = "local"
+
+const clusterPathPrefix = "/k8s/clusters/"
+
+// discoveryPaths are the non-resource paths a token restricted by rules can read, so that clients like kubectl can
+// discover the available APIs.
+var discoveryPaths = []string{"/api", "/apis", "/version", "/openapi"}
+
+// ErrIssuance is returned when a scoped token is used to create tokens or kubeconfigs, which would escape its scope.
+var ErrIssuance = errors.New("scoped tokens can't be used to create tokens or kubeconfigs")
+
+var requestInfoFactory = request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("apis", "api"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// Validate returns an error if the scope is not well-formed.
+func Validate(scope *ext.TokenScope) error {
+	if scope == nil {
+		return nil
+	}
+	if len(scope.Clusters) == 0 && len(scope.Rules) == 0 && len(scope.SourceCIDRs) == 0 {
+		return errors.New("scope must restrict at least one of clusters, rules or sourceCIDRs")
+	}
+	for _, cluster := range scope.Clusters {
+		if cluster == "" {
+			return errors.New("scope.clusters must not contain empty cluster IDs")
+		}
+	}
+	for i, rule := range scope.Rules {
+		if len(rule.APIGroups) == 0 {
+			return fmt.Errorf("scope.rules[%d].apiGroups must not be empty", i)
+		}
+		if len(rule.Resources) == 0 || slices.Contains(rule.Resources, "") {
+			return fmt.Errorf("scope.rules[%d].resources must not be empty", i)
+		}
+		if len(rule.Verbs) == 0 || slices.Contains(rule.Verbs, "") {
+			return fmt.Errorf("scope.rules[%d].verbs must not be empty", i)
+		}
+	}
+	for _, cidr := range scope.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("scope.sourceCIDRs contains an invalid CIDR %q: %w", cidr, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckIssuance returns ErrIssuance if the request of the context was authenticated with a scoped token.
+func CheckIssuance(ctx context.Context) error {
+	if authcontext.GetTokenScope(ctx) != nil {
+		return ErrIssuance
+	}
+	return nil
+}
+
+// Authorize returns an error if the request is not allowed by the scope. clusterID is the cluster the request is
+// routed to, or the empty string if it is not routed to a downstream cluster.
+func Authorize(scope *ext.TokenScope, req *http.Request, clusterID string) error {
+	if scope == nil {
+		return nil
+	}
+	if !AllowsSource(scope, req) {
+		return fmt.Errorf("source address %s is not allowed by the token scope", SourceIP(req))
+	}
+	if !AllowsCluster(scope, clusterID) {
+		return fmt.Errorf("cluster %s is not allowed by the token scope", clusterOrLocal(clusterID))
+	}
+	path := req.URL.Path
+	if clusterID != "" {
+		// requests to the Kubernetes API of a cluster are proxied without the cluster prefix
+		path = strings.TrimPrefix(path, clusterPathPrefix+clusterID)
+	}
+	if !AllowsKubernetesRequest(scope, req, path) {
+		return fmt.Errorf("%s %s is not allowed by the token scope", req.Method, req.URL.Path)
+	}
+
+	return nil
+}
+
+// AllowsSource returns true if the source address of the request is in one of the CIDRs of the scope.
+func AllowsSource(scope *ext.TokenScope, req *http.Request) bool {
+	if scope == nil || len(scope.SourceCIDRs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(SourceIP(req))
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range scope.SourceCIDRs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsCluster returns true if the scope allows requests to the cluster. The empty cluster ID stands for requests
+// that are not routed to a downstream cluster, which are attributed to the local cluster.
+func AllowsCluster(scope *ext.TokenScope, clusterID string) bool {
+	if scope == nil || len(scope.Clusters) == 0 {
+		return true
+	}
+
+	return slices.Contains(scope.Clusters, clusterOrLocal(clusterID))
+}
+
+// AllowsKubernetesRequest returns true if the request, with the given Kubernetes API path, matches one of the rules of
+// the scope. Read-only requests to the discovery endpoints are always allowed. Requests that are not Kubernetes API
+// requests are not allowed by scopes with rules.
+func AllowsKubernetesRequest(scope *ext.TokenScope, req *http.Request, path string) bool {
+	if scope == nil || len(scope.Rules) == 0 {
+		return true
+	}
+
+	kubeReq := req.Clone(req.Context())
+	kubeReq.URL.Path = path
+	info, err := requestInfoFactory.NewRequestInfo(kubeReq)
+	if err != nil {
+		return false
+	}
+	if !info.IsResourceRequest {
+		return info.Verb == "get" && isDiscoveryPath(info.Path)
+	}
+
+	resource := info.Resource
+	if info.Subresource != "" {
+		resource += "/" + info.Subresource
+	}
+	for _, rule := range scope.Rules {
+		if matches(rule.APIGroups, info.APIGroup) && matches(rule.Resources, resource) && matches(rule.Verbs, info.Verb) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SourceIP returns the source address of the request. The X-Forwarded-For header is only honored for requests from
+// the proxies of the trusted-proxy-cidrs setting, each of which appends the address it received the request from to
+// the header. The source is the last address of the header that isn't a trusted proxy, since any earlier addresses are
+// set by the client and can't be trusted.
+func SourceIP(req *http.Request) string {
+	source, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		source = req.RemoteAddr
+	}
+
+	proxies := trustedProxies()
+	if len(proxies) == 0 || !containsIP(proxies, source) {
+		return source
+	}
+	addresses := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+		if address == "" {
+			continue
+		}
+		source = address
+		if !containsIP(proxies, address) {
+			break
+		}
+	}
+
+	return source
+}
+
+var trustedProxiesCache struct {
+	sync.Mutex
+	value    string
+	networks []*net.IPNet
+}
+
+// trustedProxies returns the networks of the trusted-proxy-cidrs setting, ignoring invalid CIDRs.
+func trustedProxies() []*net.IPNet {
+	value := settings.TrustedProxyCIDRs.Get()
+
+	trustedProxiesCache.Lock()
+	defer trustedProxiesCache.Unlock()
+	if value == trustedProxiesCache.value {
+		return trustedProxiesCache.networks
+	}
+
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(value, ",") {
+		if _, network, err := net.ParseCIDR(strings.TrimSpace(cidr)); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	trustedProxiesCache.value, trustedProxiesCache.networks = value, networks
+
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Summary returns a short human-readable description of the scope.
+func Summary(scope *ext.TokenScope) string {
+	if scope == nil {
+		return "<none>"
+	}
+
+	var parts []string
+	if len(scope.Clusters) > 0 {
+		parts = append(parts, "clusters="+strings.Join(scope.Clusters, ","))
+	}
+	if len(scope.Rules) > 0 {
+		parts = append(parts, fmt.Sprintf("rules=%d", len(scope.Rules)))
+	}
+	if len(scope.SourceCIDRs) > 0 {
+		parts = append(parts, "sourceCIDRs="+strings.Join(scope.SourceCIDRs, ","))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func clusterOrLocal(clusterID string) string {
+	if clusterID == "" {
+		return LocalCluster
+	}
+
+	return clusterID
+}
+
+func isDiscoveryPath(path string) bool {
+	for _, prefix := range discoveryPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matches(values []string, value string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, value)
+}
@@ -0,0 +1,210 @@
+package scope
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		scope   *ext.TokenScope
+		wantErr string
+	}{
+		"no scope": {},
+		"valid scope": {
+			scope: &ext.TokenScope{
+				Clusters:    []string{"c-1"},
+				Rules:       []ext.TokenScopeRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+				SourceCIDRs: []string{"10.0.0.0/8", "fd00::/8"},
+			},
+		},
+		"empty scope": {
+			scope:   &ext.TokenScope{},
+			wantErr: "scope must restrict at least one of clusters, rules or sourceCIDRs",
+		},
+		"empty cluster ID": {
+			scope:   &ext.TokenScope{Clusters: []string{""}},
+			wantErr: "scope.clusters must not contain empty cluster IDs",
+		},
+		"rule without verbs": {
+			scope:   &ext.TokenScope{Rules: []ext.TokenScopeRule{{APIGroups: []string{""}, Resources: []string{"pods"}}}},
+			wantErr: "scope.rules[0].verbs must not be empty",
+		},
+		"invalid CIDR": {
+			scope:   &ext.TokenScope{SourceCIDRs: []string{"10.0.0.1"}},
+			wantErr: `scope.sourceCIDRs contains an invalid CIDR "10.0.0.1"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(test.scope)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	require.NoError(t, settings.TrustedProxyCIDRs.Set("192.168.0.0/16"))
+	t.Cleanup(func() { settings.TrustedProxyCIDRs.Set("") })
+
+	readPods := ext.TokenScopeRule{APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}, Verbs: []string{"get", "list"}}
+	allApps := ext.TokenScopeRule{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"*"}}
+
+	tests := map[string]struct {
+		scope      *ext.TokenScope
+		method     string
+		path       string
+		clusterID  string
+		remoteAddr string
+		header     http.Header
+		wantErr    string
+	}{
+		"no scope": {
+			path: "/v3/users",
+		},
+		"allowed cluster": {
+			scope:     &ext.TokenScope{Clusters: []string{"c-1"}},
+			path:      "/k8s/clusters/c-1/api/v1/secrets",
+			clusterID: "c-1",
+		},
+		"denied cluster": {
+			scope:     &ext.TokenScope{Clusters: []string{"c-1"}},
+			path:      "/k8s/clusters/c-2/api/v1/pods",
+			clusterID: "c-2",
+			wantErr:   "cluster c-2 is not allowed by the token scope",
+		},
+		"Rancher API is the local cluster": {
+			scope:   &ext.TokenScope{Clusters: []string{"c-1"}},
+			path:    "/v3/users",
+			wantErr: "cluster local is not allowed by the token scope",
+		},
+		"allowed request": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods}},
+			path:      "/k8s/clusters/c-1/api/v1/namespaces/default/pods",
+			clusterID: "c-1",
+		},
+		"allowed subresource": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods}},
+			path:      "/k8s/clusters/c-1/api/v1/namespaces/default/pods/web/log",
+			clusterID: "c-1",
+		},
+		"allowed wildcards": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods, allApps}},
+			method:    http.MethodDelete,
+			path:      "/k8s/clusters/c-1/apis/apps/v1/namespaces/default/deployments/web",
+			clusterID: "c-1",
+		},
+		"denied verb": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods}},
+			method:    http.MethodDelete,
+			path:      "/k8s/clusters/c-1/api/v1/namespaces/default/pods/web",
+			clusterID: "c-1",
+			wantErr:   "DELETE /k8s/clusters/c-1/api/v1/namespaces/default/pods/web is not allowed by the token scope",
+		},
+		"denied resource": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods}},
+			path:      "/k8s/clusters/c-1/api/v1/namespaces/default/secrets",
+			clusterID: "c-1",
+			wantErr:   "is not allowed by the token scope",
+		},
+		"discovery is allowed": {
+			scope:     &ext.TokenScope{Rules: []ext.TokenScopeRule{readPods}},
+			path:      "/k8s/clusters/c-1/apis/apps/v1",
+			clusterID: "c-1",
+		},
+		"Rancher API is denied by rules": {
+			scope:   &ext.TokenScope{Rules: []ext.TokenScopeRule{allApps}},
+			path:    "/v3/users",
+			wantErr: "GET /v3/users is not allowed by the token scope",
+		},
+		"allowed source address": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "10.1.2.3:43210",
+		},
+		"denied source address": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "192.168.0.1:43210",
+			wantErr:    "source address 192.168.0.1 is not allowed by the token scope",
+		},
+		"source address set by proxy": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "192.168.0.1:43210",
+			header:     http.Header{"X-Forwarded-For": {"10.1.2.3"}},
+		},
+		"source address spoofed by client": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "192.168.0.1:43210",
+			header:     http.Header{"X-Forwarded-For": {"10.1.2.3, 172.16.0.1"}},
+			wantErr:    "source address 172.16.0.1 is not allowed by the token scope",
+		},
+		"source address set through trusted proxies": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "192.168.0.1:43210",
+			header:     http.Header{"X-Forwarded-For": {"172.16.0.1, 10.1.2.3", "192.168.0.2"}},
+		},
+		"source address set by untrusted client": {
+			scope:      &ext.TokenScope{SourceCIDRs: []string{"10.0.0.0/8"}},
+			path:       "/v3/users",
+			remoteAddr: "172.16.0.1:43210",
+			header:     http.Header{"X-Forwarded-For": {"10.1.2.3"}},
+			wantErr:    "source address 172.16.0.1 is not allowed by the token scope",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, test.path, nil)
+			if test.remoteAddr != "" {
+				req.RemoteAddr = test.remoteAddr
+			}
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+
+			err := Authorize(test.scope, req, test.clusterID)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "<none>", Summary(nil))
+	assert.Equal(t, "clusters=c-1,c-2 rules=1 sourceCIDRs=10.0.0.0/8", Summary(&ext.TokenScope{
+		Clusters:    []string{"c-1", "c-2"},
+		Rules:       []ext.TokenScopeRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+		SourceCIDRs: []string{"10.0.0.0/8"},
+	}))
+}
+
+func TestCheckIssuance(t *testing.T) {
+	assert.NoError(t, CheckIssuance(context.Background()))
+
+	ctx := authcontext.SetTokenScope(context.Background(), &ext.TokenScope{Clusters: []string{"c-1"}})
+	assert.ErrorIs(t, CheckIssuance(ctx), ErrIssuance)
+}
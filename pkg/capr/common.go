@@ -112,6 +112,7 @@
 	InfrastructureReady          = condition.Cond(capi.InfrastructureReadyCondition)
 	SystemUpgradeControllerReady = condition.Cond("SystemUpgradeControllerReady")
 	Bootstrapped                 = condition.Cond("Bootstrapped")
+	CanaryHalted                 = condition.Cond("CanaryHalted")
 
 	RuntimeK3S  = "k3s"
 	RuntimeRKE2 = "rke2"
@@ -0,0 +1,293 @@
+package planner
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// canaryCheckPrefix prefixes the names of the probes rendered for the checks of the canary strategy, so they
+	// can't collide with the probes of the plan.
+	canaryCheckPrefix = "canary-"
+	// defaultProbeFailureThreshold is the failure threshold of probes that don't specify one.
+	defaultProbeFailureThreshold = 3
+
+	WaitingForCanaryMessage = "waiting for canary machines"
+	CanaryHaltedReason      = "CanaryHalted"
+)
+
+// canaryRollout tracks the canary phase of the worker tiers during a reconciliation. Canary machines are reconciled
+// ahead of the other worker machines, whose disruptive plan changes are held until the canary machines have been
+// upgraded and healthy for the soak duration. A nil canaryRollout holds nothing.
+type canaryRollout struct {
+	selector     labels.Selector
+	soakDuration time.Duration
+	generation   int64
+	now          time.Time
+	previous     *rkev1.CanaryStatus
+
+	// pending are the canary machines with disruptive plan changes that have not been delivered yet.
+	pending map[string]bool
+	// waiting are the other worker machines whose disruptive plan changes are held.
+	waiting   map[string]bool
+	canaries  int
+	failed    []string
+	halted    bool
+	soakStart time.Time
+}
+
+// newCanaryRollout returns the canary rollout for the canary strategy of the control plane, or nil if it has none.
+func newCanaryRollout(cp *rkev1.RKEControlPlane, previous *rkev1.CanaryStatus, now time.Time) (*canaryRollout, error) {
+	strategy := cp.Spec.UpgradeStrategy.Canary
+	if strategy == nil {
+		return nil, nil
+	}
+
+	selector, err := canarySelector(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &canaryRollout{
+		selector:     selector,
+		soakDuration: strategy.SoakDuration.Duration,
+		generation:   cp.Generation,
+		now:          now,
+		previous:     previous,
+		pending:      map[string]bool{},
+		waiting:      map[string]bool{},
+	}, nil
+}
+
+func canarySelector(strategy *rkev1.CanaryStrategy) (labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&strategy.MachineSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canary machine selector: %w", err)
+	}
+	if selector.Empty() {
+		return nil, fmt.Errorf("canary machine selector must not select all machines")
+	}
+	return selector, nil
+}
+
+// isCanary returns whether the entry is a canary machine. It can be used as a roleFilter.
+func (c *canaryRollout) isCanary(entry *planEntry) bool {
+	return c != nil && c.selector.Matches(labels.Set(entry.Machine.Labels))
+}
+
+// hold returns whether the disruptive plan change of the entry must wait for the canary phase to complete, and
+// records the machine as waiting if so. Changes of canary machines are never held, but recorded as pending, and
+// changes that were already started, that is the node is draining, are completed.
+func (c *canaryRollout) hold(entry *planEntry) bool {
+	if c == nil {
+		return false
+	}
+	if c.isCanary(entry) {
+		c.pending[entry.Machine.Name] = true
+		return false
+	}
+	if isInDrain(entry) || (!c.halted && (c.canaries == 0 || c.soaked())) {
+		return false
+	}
+	c.waiting[entry.Machine.Name] = true
+	return true
+}
+
+// evaluate determines the state of the canary phase from the canary machines once they have been reconciled. The
+// rollout halts if any canary machine failed, and stays halted until the generation of the control plane changes.
+// Without canary machines, there is nothing to wait for. It returns whether the rollout was halted by this evaluation.
+func (c *canaryRollout) evaluate(entries []*planEntry) bool {
+	c.canaries = len(entries)
+	upgraded := len(c.pending) == 0
+	for _, entry := range entries {
+		if canaryFailed(entry) {
+			c.failed = append(c.failed, entry.Machine.Name)
+		}
+		if entry.Plan == nil || entry.Plan.AppliedPlan == nil || !reflect.DeepEqual(entry.Plan.Plan, *entry.Plan.AppliedPlan) ||
+			!entry.Plan.InSync || !entry.Plan.Healthy {
+			upgraded = false
+		}
+	}
+	sort.Strings(c.failed)
+
+	if c.previous != nil && c.previous.Halted && c.previous.HaltedGeneration == c.generation {
+		c.halted = true
+		if len(c.failed) == 0 {
+			c.failed = c.previous.FailedMachines
+		}
+		return false
+	}
+	if len(c.failed) > 0 {
+		c.halted = true
+		return true
+	}
+
+	if upgraded {
+		c.soakStart = c.now
+		if c.previous != nil && c.previous.SoakStartTime != nil {
+			c.soakStart = c.previous.SoakStartTime.Time
+		}
+	}
+	return false
+}
+
+// canaryFailed returns whether the plan of the canary machine failed to apply, or a probe of its applied plan has
+// exceeded its failure threshold.
+func canaryFailed(entry *planEntry) bool {
+	if entry.Plan == nil {
+		return false
+	}
+	if entry.Plan.Failed {
+		return true
+	}
+	if entry.Plan.AppliedPlan == nil || !reflect.DeepEqual(entry.Plan.Plan, *entry.Plan.AppliedPlan) {
+		return false
+	}
+	for name, status := range entry.Plan.ProbeStatus {
+		threshold := entry.Plan.Plan.Probes[name].FailureThreshold
+		if threshold <= 0 {
+			threshold = defaultProbeFailureThreshold
+		}
+		if !status.Healthy && status.FailureCount >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// soaked returns whether the canary machines have been upgraded and healthy for the soak duration.
+func (c *canaryRollout) soaked() bool {
+	return !c.soakStart.IsZero() && !c.now.Before(c.soakStart.Add(c.soakDuration))
+}
+
+// soakRemaining returns how long the held plan changes still have to wait for the soak duration to pass, or zero if
+// they don't wait for it.
+func (c *canaryRollout) soakRemaining() time.Duration {
+	if c == nil || c.halted || c.soakStart.IsZero() || len(c.waiting) == 0 {
+		return 0
+	}
+	return c.soakStart.Add(c.soakDuration).Sub(c.now)
+}
+
+// status returns the canary status to set on the control plane, which is nil unless plan changes are held or the
+// rollout is halted.
+func (c *canaryRollout) status() *rkev1.CanaryStatus {
+	if c == nil || (!c.halted && len(c.waiting) == 0) {
+		return nil
+	}
+
+	status := &rkev1.CanaryStatus{
+		FailedMachines: c.failed,
+	}
+	if c.halted {
+		status.Halted = true
+		status.HaltedGeneration = c.generation
+	}
+	if !c.soakStart.IsZero() {
+		soakStart := metav1.NewTime(c.soakStart)
+		status.SoakStartTime = &soakStart
+	}
+	for machineName := range c.waiting {
+		status.WaitingMachines = append(status.WaitingMachines, machineName)
+	}
+	sort.Strings(status.WaitingMachines)
+
+	return status
+}
+
+// haltedMessage returns the message of the CanaryHalted condition and event.
+func (c *canaryRollout) haltedMessage() string {
+	return fmt.Sprintf("rollout halted because canary machine(s) %s failed, update the spec to resume", strings.Join(c.failed, ", "))
+}
+
+// canaryProbes renders the checks of the canary strategy as probes for the plan of a canary machine.
+func canaryProbes(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (map[string]plan.Probe, error) {
+	strategy := controlPlane.Spec.UpgradeStrategy.Canary
+	if strategy == nil || len(strategy.Checks) == 0 || !(isOnlyLinuxWorker(entry) || isOnlyWindowsWorker(entry)) {
+		return nil, nil
+	}
+
+	selector, err := canarySelector(strategy)
+	if err != nil {
+		return nil, err
+	}
+	if !selector.Matches(labels.Set(entry.Machine.Labels)) {
+		return nil, nil
+	}
+
+	probes := map[string]plan.Probe{}
+	for _, check := range strategy.Checks {
+		timeout := check.TimeoutSeconds
+		if timeout <= 0 {
+			timeout = 5
+		}
+		failureThreshold := check.FailureThreshold
+		if failureThreshold <= 0 {
+			failureThreshold = defaultProbeFailureThreshold
+		}
+		probes[canaryCheckPrefix+check.Name] = plan.Probe{
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      timeout,
+			SuccessThreshold:    1,
+			FailureThreshold:    failureThreshold,
+			HTTPGetAction: plan.HTTPGetAction{
+				URL:      check.URL,
+				Insecure: check.Insecure,
+				CACert:   check.CACert,
+			},
+		}
+	}
+	return probes, nil
+}
+
+// splitCanaryProbes splits the probes of a plan into the probes rendered for the checks of the canary strategy and
+// the others.
+func splitCanaryProbes(all map[string]plan.Probe) (probes, checks map[string]plan.Probe) {
+	for name, probe := range all {
+		if strings.HasPrefix(name, canaryCheckPrefix) {
+			if checks == nil {
+				checks = map[string]plan.Probe{}
+			}
+			checks[name] = probe
+			continue
+		}
+		if probes == nil {
+			probes = map[string]plan.Probe{}
+		}
+		probes[name] = probe
+	}
+	return probes, checks
+}
+
+func newCanaryHaltedEvent(cp *rkev1.RKEControlPlane, message string) *corev1.Event {
+	now := metav1.Now()
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cp.Name + ".",
+			Namespace:    cp.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: rkev1.SchemeGroupVersion.String(),
+			Kind:       "RKEControlPlane",
+			Namespace:  cp.Namespace,
+			Name:       cp.Name,
+			UID:        cp.UID,
+		},
+		Reason:         CanaryHaltedReason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "rancher"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
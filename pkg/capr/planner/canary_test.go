@@ -0,0 +1,220 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func newCanaryEntry(name string, canary bool, node *plan.Node) *planEntry {
+	machineLabels := map[string]string{capr.WorkerRoleLabel: "true"}
+	if canary {
+		machineLabels[capr.RKEMachinePoolNameLabel] = "canary"
+	}
+	return &planEntry{
+		Machine:  &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: machineLabels}},
+		Metadata: &plan.Metadata{Labels: machineLabels},
+		Plan:     node,
+	}
+}
+
+func newCanaryControlPlane(soakDuration time.Duration, checks ...rkev1.CanaryCheck) *rkev1.RKEControlPlane {
+	cp := &rkev1.RKEControlPlane{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	cp.Spec.UpgradeStrategy.Canary = &rkev1.CanaryStrategy{
+		MachineSelector: metav1.LabelSelector{MatchLabels: map[string]string{capr.RKEMachinePoolNameLabel: "canary"}},
+		SoakDuration:    metav1.Duration{Duration: soakDuration},
+		Checks:          checks,
+	}
+	return cp
+}
+
+func appliedNode(healthy bool, probeStatus map[string]plan.ProbeStatus) *plan.Node {
+	nodePlan := plan.NodePlan{Probes: map[string]plan.Probe{"kubelet": {FailureThreshold: 2}}}
+	return &plan.Node{
+		Plan:        nodePlan,
+		AppliedPlan: &nodePlan,
+		InSync:      true,
+		Healthy:     healthy,
+		ProbeStatus: probeStatus,
+	}
+}
+
+func TestCanaryRollout(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+	healthy := appliedNode(true, map[string]plan.ProbeStatus{"kubelet": {Healthy: true}})
+	worker := newCanaryEntry("worker", false, healthy)
+
+	t.Run("no canary strategy", func(t *testing.T) {
+		canary, err := newCanaryRollout(&rkev1.RKEControlPlane{}, nil, now)
+		require.NoError(t, err)
+		assert.Nil(t, canary)
+		assert.False(t, canary.hold(worker))
+		assert.Nil(t, canary.status())
+	})
+
+	t.Run("selector must select", func(t *testing.T) {
+		cp := newCanaryControlPlane(time.Hour)
+		cp.Spec.UpgradeStrategy.Canary.MachineSelector = metav1.LabelSelector{}
+		_, err := newCanaryRollout(cp, nil, now)
+		assert.Error(t, err)
+	})
+
+	t.Run("workers wait for pending canaries", func(t *testing.T) {
+		canary, err := newCanaryRollout(newCanaryControlPlane(time.Hour), nil, now)
+		require.NoError(t, err)
+		canaryEntry := newCanaryEntry("canary", true, healthy)
+
+		assert.False(t, canary.hold(canaryEntry))
+		assert.False(t, canary.evaluate([]*planEntry{canaryEntry}))
+		assert.True(t, canary.hold(worker))
+		assert.Equal(t, &rkev1.CanaryStatus{WaitingMachines: []string{"worker"}}, canary.status())
+		assert.Zero(t, canary.soakRemaining())
+	})
+
+	t.Run("workers wait for the soak duration", func(t *testing.T) {
+		canary, err := newCanaryRollout(newCanaryControlPlane(time.Hour), nil, now)
+		require.NoError(t, err)
+
+		assert.False(t, canary.evaluate([]*planEntry{newCanaryEntry("canary", true, healthy)}))
+		assert.True(t, canary.hold(worker))
+		// changes that were already started are completed
+		assert.False(t, canary.hold(&planEntry{Machine: worker.Machine, Metadata: &plan.Metadata{Annotations: map[string]string{capr.DrainAnnotation: "{}"}}}))
+		assert.Equal(t, time.Hour, canary.soakRemaining())
+
+		soakStart := metav1.NewTime(now)
+		assert.Equal(t, &rkev1.CanaryStatus{SoakStartTime: &soakStart, WaitingMachines: []string{"worker"}}, canary.status())
+
+		soaked, err := newCanaryRollout(newCanaryControlPlane(time.Hour), canary.status(), now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.False(t, soaked.evaluate([]*planEntry{newCanaryEntry("canary", true, healthy)}))
+		assert.False(t, soaked.hold(worker))
+		assert.Nil(t, soaked.status())
+	})
+
+	t.Run("no canary machines", func(t *testing.T) {
+		canary, err := newCanaryRollout(newCanaryControlPlane(time.Hour), nil, now)
+		require.NoError(t, err)
+		assert.False(t, canary.evaluate(nil))
+		assert.False(t, canary.hold(worker))
+	})
+
+	t.Run("failed canary halts the rollout until the spec changes", func(t *testing.T) {
+		failing := newCanaryEntry("canary", true, appliedNode(false, map[string]plan.ProbeStatus{"kubelet": {FailureCount: 2}}))
+		canary, err := newCanaryRollout(newCanaryControlPlane(0), nil, now)
+		require.NoError(t, err)
+
+		assert.True(t, canary.evaluate([]*planEntry{failing, newCanaryEntry("canary-2", true, healthy)}))
+		assert.True(t, canary.hold(worker))
+		assert.Equal(t, &rkev1.CanaryStatus{
+			Halted:           true,
+			HaltedGeneration: 2,
+			FailedMachines:   []string{"canary"},
+			WaitingMachines:  []string{"worker"},
+		}, canary.status())
+		assert.Zero(t, canary.soakRemaining())
+
+		// the rollout stays halted when the canary recovers
+		stillHalted, err := newCanaryRollout(newCanaryControlPlane(0), canary.status(), now)
+		require.NoError(t, err)
+		assert.False(t, stillHalted.evaluate([]*planEntry{newCanaryEntry("canary", true, healthy)}))
+		assert.True(t, stillHalted.hold(worker))
+		assert.Equal(t, []string{"canary"}, stillHalted.status().FailedMachines)
+
+		cp := newCanaryControlPlane(0)
+		cp.Generation = 3
+		resumed, err := newCanaryRollout(cp, canary.status(), now)
+		require.NoError(t, err)
+		assert.False(t, resumed.evaluate([]*planEntry{newCanaryEntry("canary", true, healthy)}))
+		assert.False(t, resumed.hold(worker))
+	})
+}
+
+func TestCanaryFailed(t *testing.T) {
+	assert.False(t, canaryFailed(newCanaryEntry("a", true, nil)))
+	assert.True(t, canaryFailed(newCanaryEntry("a", true, &plan.Node{Failed: true})))
+	// probes below their failure threshold
+	assert.False(t, canaryFailed(newCanaryEntry("a", true, appliedNode(false, map[string]plan.ProbeStatus{"kubelet": {FailureCount: 1}}))))
+	assert.True(t, canaryFailed(newCanaryEntry("a", true, appliedNode(false, map[string]plan.ProbeStatus{"kubelet": {FailureCount: 2}}))))
+	// probes of a plan that is not applied yet
+	unapplied := appliedNode(false, map[string]plan.ProbeStatus{"kubelet": {FailureCount: 5}})
+	unapplied.AppliedPlan = &plan.NodePlan{}
+	assert.False(t, canaryFailed(newCanaryEntry("a", true, unapplied)))
+}
+
+func TestCanaryProbes(t *testing.T) {
+	cp := newCanaryControlPlane(time.Hour, rkev1.CanaryCheck{Name: "app", URL: "http://127.0.0.1:8080/healthz"},
+		rkev1.CanaryCheck{Name: "ingress", URL: "https://127.0.0.1/healthz", CACert: "/etc/ssl/ca.crt", TimeoutSeconds: 10, FailureThreshold: 5})
+
+	probes, err := canaryProbes(cp, newCanaryEntry("canary", true, nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]plan.Probe{
+		"canary-app": {
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      5,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+			HTTPGetAction:       plan.HTTPGetAction{URL: "http://127.0.0.1:8080/healthz"},
+		},
+		"canary-ingress": {
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      10,
+			SuccessThreshold:    1,
+			FailureThreshold:    5,
+			HTTPGetAction:       plan.HTTPGetAction{URL: "https://127.0.0.1/healthz", CACert: "/etc/ssl/ca.crt"},
+		},
+	}, probes)
+
+	probes, err = canaryProbes(cp, newCanaryEntry("worker", false, nil))
+	require.NoError(t, err)
+	assert.Empty(t, probes)
+}
+
+func TestCanaryChecksChangeIsMinor(t *testing.T) {
+	kubelet := plan.Probe{HTTPGetAction: plan.HTTPGetAction{URL: "https://127.0.0.1:10250/healthz"}}
+	check := plan.Probe{HTTPGetAction: plan.HTTPGetAction{URL: "http://127.0.0.1:8080/healthz"}}
+	old := plan.NodePlan{
+		Instructions: []plan.OneTimeInstruction{{Name: "install"}},
+		Probes:       map[string]plan.Probe{"kubelet": kubelet},
+	}
+
+	// adding a check
+	added := plan.NodePlan{
+		Instructions: old.Instructions,
+		Probes:       map[string]plan.Probe{"kubelet": kubelet, "canary-app": check},
+	}
+	assert.True(t, minorPlanChangeDetected(old, added))
+
+	// editing a check
+	edited := plan.NodePlan{
+		Instructions: old.Instructions,
+		Probes:       map[string]plan.Probe{"kubelet": kubelet, "canary-app": {HTTPGetAction: plan.HTTPGetAction{URL: "http://127.0.0.1:8080/ready"}}},
+	}
+	assert.True(t, minorPlanChangeDetected(added, edited))
+
+	// removing a check
+	assert.True(t, minorPlanChangeDetected(added, old))
+
+	// no change at all
+	assert.False(t, minorPlanChangeDetected(added, added))
+
+	// changing a check along with other probes
+	changed := plan.NodePlan{
+		Instructions: old.Instructions,
+		Probes:       map[string]plan.Probe{"kubelet": {HTTPGetAction: plan.HTTPGetAction{URL: "https://127.0.0.1:10248/healthz"}}, "canary-app": check},
+	}
+	assert.False(t, minorPlanChangeDetected(old, changed))
+
+	// changing a check along with the instructions
+	reinstalled := plan.NodePlan{
+		Instructions: []plan.OneTimeInstruction{{Name: "upgrade"}},
+		Probes:       added.Probes,
+	}
+	assert.False(t, minorPlanChangeDetected(old, reinstalled))
+}
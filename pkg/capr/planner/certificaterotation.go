@@ -13,7 +13,7 @@
 )
 
 // rotateCertificates checks if there is a need to rotate any certificates and updates the plan accordingly.
-func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan, window *maintenanceWindow) (rkev1.RKEControlPlaneStatus, error) {
 	if !shouldRotate(controlPlane) {
 		return status, nil
 	}
@@ -31,6 +31,11 @@ func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status
 	// Assemble our list of nodes in order of etcd-only, etcd with controlplane, controlplane-only, and everything else
 	orderedEntriesToRotate := collectOrderedCertificateRotationEntries(clusterPlan)
 
+	if !certificateRotationStarted(controlPlane.Spec.RotateCertificates, orderedEntriesToRotate) && window.holdRotation(certificateRotation) {
+		logrus.Debugf("[planner] rkecluster %s/%s: holding certificate rotation until the maintenance window opens", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
 	for _, node := range orderedEntriesToRotate {
 		if !shouldRotateEntry(controlPlane.Spec.RotateCertificates, node) {
 			continue
@@ -59,6 +64,24 @@ func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status
 	return status, errWaiting("certificate rotation done")
 }
 
+// certificateRotationStarted returns whether the rotation plan of the current generation was assigned to any of the
+// given entries.
+func certificateRotationStarted(rotation *rkev1.RotateCertificates, entries []*planEntry) bool {
+	prefix := "idempotent-certificate-rotation/"
+	generation := "-" + PlanHash([]byte(strconv.FormatInt(rotation.Generation, 10))) + "-"
+	for _, entry := range entries {
+		if entry.Plan == nil {
+			continue
+		}
+		for _, instruction := range entry.Plan.Plan.Instructions {
+			if strings.HasPrefix(instruction.Name, prefix) && strings.Contains(instruction.Name, generation) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func collectOrderedCertificateRotationEntries(clusterPlan *plan.Plan) []*planEntry {
 	orderedEntriesToRotate := collect(clusterPlan, IsOnlyEtcd)                                                        // etcd or etcd + worker
 	orderedEntriesToRotate = append(orderedEntriesToRotate, collect(clusterPlan, roleAnd(isControlPlane, isEtcd))...) // etcd + controlplane or etcd + controlplane+worker
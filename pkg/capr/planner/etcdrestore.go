@@ -812,6 +812,9 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 
 	switch cp.Status.ETCDSnapshotRestorePhase {
 	case rkev1.ETCDSnapshotPhaseStarted:
+		if snapshot != nil && snapshot.Status.Verification != nil && snapshot.Status.Verification.Phase == rkev1.ETCDSnapshotVerificationFailed {
+			logrus.Warnf("[planner] rkecluster %s/%s: restoring etcd snapshot %s/%s that failed verification: %s", cp.Namespace, cp.Name, snapshot.Namespace, snapshot.Name, snapshot.Status.Verification.Message)
+		}
 		if status.Initialized || status.Ready {
 			status.Initialized = false
 			status.Ready = false
@@ -845,7 +848,7 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		}
 		logrus.Infof("[planner] rkecluster %s/%s: running full reconcile during etcd restore to initially restart cluster", cp.Namespace, cp.Name)
 		// Run a full reconcile of the cluster at this point, ignoring drain and concurrency.
-		if status, err := p.fullReconcile(cp, status, tokensSecret, clusterPlan, true); err != nil {
+		if status, err := p.fullReconcile(cp, status, tokensSecret, clusterPlan, true, nil); err != nil {
 			return status, err
 		}
 		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhasePostRestoreNodeCleanup)
@@ -860,7 +863,7 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		}
 		logrus.Infof("[planner] rkecluster %s/%s: running full reconcile during etcd restore to restart cluster", cp.Namespace, cp.Name)
 		// Run a full reconcile of the cluster at this point, ignoring drain and concurrency.
-		if status, err := p.fullReconcile(cp, status, tokensSecret, clusterPlan, true); err != nil {
+		if status, err := p.fullReconcile(cp, status, tokensSecret, clusterPlan, true, nil); err != nil {
 			return status, err
 		}
 		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseFinished)
@@ -0,0 +1,154 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/robfig/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// blackoutDateLayout is the layout of the blackout dates of a maintenance window.
+	blackoutDateLayout = "2006-01-02"
+	// maxWindowSearch is the number of window openings that are skipped at most because they fall on a blackout date
+	// when looking for the next opening.
+	maxWindowSearch = 1000
+
+	WaitingForMaintenanceWindowMessage = "waiting for maintenance window"
+
+	// certificateRotation and encryptionKeyRotation are the rotations reported as waiting for the maintenance window.
+	certificateRotation   = "certificates"
+	encryptionKeyRotation = "encryptionKeys"
+)
+
+// maintenanceWindow tracks the major plan changes and rotations that are held during a reconciliation because the
+// maintenance window of the upgrade strategy is closed. A nil maintenanceWindow holds nothing.
+type maintenanceWindow struct {
+	open      bool
+	next      time.Time
+	waiting   map[string]bool
+	rotations []string
+}
+
+// newMaintenanceWindow evaluates the maintenance window configuration at the given time. It returns nil if there is no
+// maintenance window configured, as plan changes are then never held.
+func newMaintenanceWindow(config *rkev1.MaintenanceWindow, now time.Time) (*maintenanceWindow, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if len(config.Windows) == 0 {
+		return nil, fmt.Errorf("at least one window must be specified")
+	}
+
+	location := time.UTC
+	if config.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(config.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %s: %w", config.TimeZone, err)
+		}
+	}
+
+	blackoutDates := map[string]bool{}
+	for _, date := range config.BlackoutDates {
+		if _, err := time.Parse(blackoutDateLayout, date); err != nil {
+			return nil, fmt.Errorf("invalid blackout date %s: %w", date, err)
+		}
+		blackoutDates[date] = true
+	}
+	isBlackout := func(t time.Time) bool {
+		return blackoutDates[t.Format(blackoutDateLayout)]
+	}
+
+	now = now.In(location)
+	window := &maintenanceWindow{
+		waiting: map[string]bool{},
+	}
+	for _, w := range config.Windows {
+		schedule, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %s: %w", w.Schedule, err)
+		}
+		if w.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("duration of schedule %s must be positive", w.Schedule)
+		}
+
+		// The window is open if it opened within its duration, unless it opened on a blackout date or today is one.
+		if start := schedule.Next(now.Add(-w.Duration.Duration)); !start.IsZero() && !start.After(now) && !isBlackout(start) && !isBlackout(now) {
+			window.open = true
+		}
+
+		next := now
+		for i := 0; i < maxWindowSearch; i++ {
+			next = schedule.Next(next)
+			if next.IsZero() || !isBlackout(next) {
+				break
+			}
+		}
+		if !next.IsZero() && !isBlackout(next) && (window.next.IsZero() || next.Before(window.next)) {
+			window.next = next
+		}
+	}
+
+	return window, nil
+}
+
+// hold returns whether the major plan change of the entry must wait for the maintenance window to open, and records
+// the machine as waiting if so. Changes that were already started, that is the node is draining, are completed.
+func (w *maintenanceWindow) hold(entry *planEntry) bool {
+	if !w.holds(entry) {
+		return false
+	}
+	w.waiting[entry.Machine.Name] = true
+	return true
+}
+
+// holds returns whether a major plan change of the entry is held, without recording the machine as waiting.
+func (w *maintenanceWindow) holds(entry *planEntry) bool {
+	return w != nil && !w.open && !isInDrain(entry)
+}
+
+// holdNew returns whether the initial plan of a new machine must wait for the maintenance window to open, and records
+// the machine as waiting if so. This is the case once changes of its tier or of a tier reconciled before it are held,
+// so that the machine doesn't join with a plan the other machines are held back from.
+func (w *maintenanceWindow) holdNew(entry *planEntry, tierHeld bool) bool {
+	if w == nil || w.open || (!tierHeld && len(w.waiting) == 0) {
+		return false
+	}
+	w.waiting[entry.Machine.Name] = true
+	return true
+}
+
+// holdRotation returns whether the given rotation, which was not started yet, must wait for the maintenance window to
+// open, and records it as waiting if so. Rotations that were started are always completed.
+func (w *maintenanceWindow) holdRotation(rotation string) bool {
+	if w == nil || w.open {
+		return false
+	}
+	w.rotations = append(w.rotations, rotation)
+	return true
+}
+
+// status returns the status of the maintenance window to set on the control plane, which is nil unless changes or
+// rotations are waiting for the window to open.
+func (w *maintenanceWindow) status() *rkev1.MaintenanceWindowStatus {
+	if w == nil || (len(w.waiting) == 0 && len(w.rotations) == 0) {
+		return nil
+	}
+
+	status := &rkev1.MaintenanceWindowStatus{
+		WaitingRotations: w.rotations,
+	}
+	for machineName := range w.waiting {
+		status.WaitingMachines = append(status.WaitingMachines, machineName)
+	}
+	sort.Strings(status.WaitingMachines)
+	if !w.next.IsZero() {
+		next := metav1.NewTime(w.next)
+		status.NextWindow = &next
+	}
+
+	return status
+}
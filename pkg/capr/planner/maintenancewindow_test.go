@@ -0,0 +1,196 @@
+package planner
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestNewMaintenanceWindow(t *testing.T) {
+	// Saturdays from 22:00 to 02:00 in Berlin.
+	saturdayNights := []rkev1.MaintenanceWindowSchedule{{Schedule: "0 22 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}}}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		config       *rkev1.MaintenanceWindow
+		now          time.Time
+		expectedOpen bool
+		expectedNext time.Time
+		expectedErr  string
+	}{
+		{
+			name:         "window open",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Europe/Berlin"},
+			now:          time.Date(2025, 3, 8, 23, 0, 0, 0, berlin),
+			expectedOpen: true,
+			expectedNext: time.Date(2025, 3, 15, 22, 0, 0, 0, berlin),
+		},
+		{
+			name:         "window open past midnight",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Europe/Berlin"},
+			now:          time.Date(2025, 3, 9, 1, 30, 0, 0, berlin),
+			expectedOpen: true,
+			expectedNext: time.Date(2025, 3, 15, 22, 0, 0, 0, berlin),
+		},
+		{
+			name:         "window closed",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Europe/Berlin"},
+			now:          time.Date(2025, 3, 10, 14, 0, 0, 0, berlin),
+			expectedNext: time.Date(2025, 3, 15, 22, 0, 0, 0, berlin),
+		},
+		{
+			name:         "windows are evaluated in the time zone",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Europe/Berlin"},
+			now:          time.Date(2025, 3, 8, 22, 30, 0, 0, time.UTC),
+			expectedOpen: true,
+			expectedNext: time.Date(2025, 3, 15, 22, 0, 0, 0, berlin),
+		},
+		{
+			name:         "windows default to UTC",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights},
+			now:          time.Date(2025, 3, 8, 23, 30, 0, 0, berlin),
+			expectedOpen: true,
+			expectedNext: time.Date(2025, 3, 15, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "blackout date",
+			config:       &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Europe/Berlin", BlackoutDates: []string{"2025-03-08", "2025-03-15"}},
+			now:          time.Date(2025, 3, 8, 23, 0, 0, 0, berlin),
+			expectedNext: time.Date(2025, 3, 22, 22, 0, 0, 0, berlin),
+		},
+		{
+			name: "earliest of multiple windows",
+			config: &rkev1.MaintenanceWindow{Windows: append([]rkev1.MaintenanceWindowSchedule{
+				{Schedule: "0 3 * * 1-5", Duration: metav1.Duration{Duration: time.Hour}},
+			}, saturdayNights...)},
+			now:          time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 3, 11, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "invalid schedule",
+			config:      &rkev1.MaintenanceWindow{Windows: []rkev1.MaintenanceWindowSchedule{{Schedule: "every saturday", Duration: metav1.Duration{Duration: time.Hour}}}},
+			expectedErr: "invalid schedule every saturday",
+		},
+		{
+			name:        "missing duration",
+			config:      &rkev1.MaintenanceWindow{Windows: []rkev1.MaintenanceWindowSchedule{{Schedule: "0 22 * * 6"}}},
+			expectedErr: "duration of schedule 0 22 * * 6 must be positive",
+		},
+		{
+			name:        "invalid time zone",
+			config:      &rkev1.MaintenanceWindow{Windows: saturdayNights, TimeZone: "Mars/Olympus"},
+			expectedErr: "invalid time zone Mars/Olympus",
+		},
+		{
+			name:        "invalid blackout date",
+			config:      &rkev1.MaintenanceWindow{Windows: saturdayNights, BlackoutDates: []string{"03/08/2025"}},
+			expectedErr: "invalid blackout date 03/08/2025",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := newMaintenanceWindow(tt.config, tt.now)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOpen, window.open)
+			assert.True(t, tt.expectedNext.Equal(window.next), "expected next window %s, got %s", tt.expectedNext, window.next)
+		})
+	}
+
+	window, err := newMaintenanceWindow(nil, time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, window)
+}
+
+func TestMaintenanceWindowHold(t *testing.T) {
+	newEntry := func(name string, annotations map[string]string) *planEntry {
+		return &planEntry{
+			Machine:  &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			Metadata: &plan.Metadata{Annotations: annotations},
+		}
+	}
+	next := time.Date(2025, 3, 15, 22, 0, 0, 0, time.UTC)
+
+	var nilWindow *maintenanceWindow
+	assert.False(t, nilWindow.hold(newEntry("a", nil)))
+	assert.Nil(t, nilWindow.status())
+
+	open := &maintenanceWindow{open: true, next: next, waiting: map[string]bool{}}
+	assert.False(t, open.hold(newEntry("a", nil)))
+	assert.Nil(t, open.status())
+
+	closed := &maintenanceWindow{next: next, waiting: map[string]bool{}}
+	assert.True(t, closed.hold(newEntry("b", nil)))
+	assert.True(t, closed.hold(newEntry("a", nil)))
+	assert.True(t, closed.hold(newEntry("a", nil)))
+	// changes of draining nodes were started before the window closed
+	assert.False(t, closed.hold(newEntry("c", map[string]string{capr.DrainAnnotation: "{}"})))
+
+	nextWindow := metav1.NewTime(next)
+	assert.Equal(t, &rkev1.MaintenanceWindowStatus{
+		NextWindow:      &nextWindow,
+		WaitingMachines: []string{"a", "b"},
+	}, closed.status())
+}
+
+func TestMaintenanceWindowHoldNewAndRotations(t *testing.T) {
+	newEntry := func(name string) *planEntry {
+		return &planEntry{
+			Machine:  &capi.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			Metadata: &plan.Metadata{},
+		}
+	}
+
+	var nilWindow *maintenanceWindow
+	assert.False(t, nilWindow.holdNew(newEntry("a"), true))
+	assert.False(t, nilWindow.holdRotation(certificateRotation))
+
+	open := &maintenanceWindow{open: true, waiting: map[string]bool{}}
+	assert.False(t, open.holdNew(newEntry("a"), true))
+	assert.False(t, open.holdRotation(certificateRotation))
+	assert.Nil(t, open.status())
+
+	closed := &maintenanceWindow{waiting: map[string]bool{}}
+	// new machines join as long as no changes are held
+	assert.False(t, closed.holdNew(newEntry("a"), false))
+	assert.Nil(t, closed.status())
+	assert.True(t, closed.holdNew(newEntry("a"), true))
+	// changes of a tier reconciled before are held
+	assert.True(t, closed.holdNew(newEntry("b"), false))
+	assert.True(t, closed.holdRotation(encryptionKeyRotation))
+
+	assert.Equal(t, &rkev1.MaintenanceWindowStatus{
+		WaitingMachines:  []string{"a", "b"},
+		WaitingRotations: []string{encryptionKeyRotation},
+	}, closed.status())
+}
+
+func TestCertificateRotationStarted(t *testing.T) {
+	controlPlane := &rkev1.RKEControlPlane{Spec: rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.30.0+rke2r1"}}
+	rotation := &rkev1.RotateCertificates{Generation: 2}
+	newEntry := func(generation int64) *planEntry {
+		return &planEntry{
+			Plan: &plan.Node{Plan: plan.NodePlan{Instructions: []plan.OneTimeInstruction{
+				idempotentStopInstruction(controlPlane, "certificate-rotation/stop", strconv.FormatInt(generation, 10), "rke2-server"),
+			}}},
+		}
+	}
+
+	assert.False(t, certificateRotationStarted(rotation, []*planEntry{{}, {Plan: &plan.Node{}}}))
+	assert.False(t, certificateRotationStarted(rotation, []*planEntry{newEntry(1)}))
+	assert.True(t, certificateRotationStarted(rotation, []*planEntry{newEntry(1), newEntry(2)}))
+}
@@ -12,6 +12,8 @@
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/moby/locker"
@@ -117,6 +119,7 @@ type Planner struct {
 	etcdSnapshotCache             rkecontrollers.ETCDSnapshotCache
 	secretClient                  corecontrollers.SecretClient
 	secretCache                   corecontrollers.SecretCache
+	events                        corecontrollers.EventClient
 	configMapCache                corecontrollers.ConfigMapCache
 	machines                      capicontrollers.MachineClient
 	machinesCache                 capicontrollers.MachineCache
@@ -125,7 +128,7 @@ type Planner struct {
 	capiClusters                  capicontrollers.ClusterCache
 	managementClusters            mgmtcontrollers.ClusterCache
 	rancherClusterCache           ranchercontrollers.ClusterCache
-	locker                        locker.Locker
+	locker                        *locker.Locker
 	etcdS3Args                    s3Args
 	retrievalFunctions            InfoFunctions
 
@@ -146,9 +149,15 @@ type InfoFunctions struct {
 	GetBootstrapManifests   func(plane *rkev1.RKEControlPlane) ([]plan.File, error)
 }
 
+// clusterRegTokenIndexer guards the registration of the ClusterRegToken indexer, as planners are created for both the
+// controllers and the plan preview API and the cache panics on duplicate indexers.
+var clusterRegTokenIndexer sync.Once
+
 func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions) *Planner {
-	clients.Mgmt.ClusterRegistrationToken().Cache().AddIndexer(ClusterRegToken, func(obj *v3.ClusterRegistrationToken) ([]string, error) {
-		return []string{obj.Spec.ClusterName}, nil
+	clusterRegTokenIndexer.Do(func() {
+		clients.Mgmt.ClusterRegistrationToken().Cache().AddIndexer(ClusterRegToken, func(obj *v3.ClusterRegistrationToken) ([]string, error) {
+			return []string{obj.Spec.ClusterName}, nil
+		})
 	})
 	equalities := equality.Semantic.Copy()
 	_ = equalities.AddFunc(func(a, b plan.File) bool {
@@ -162,6 +171,7 @@ func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions
 		machinesCache:                 clients.CAPI.Machine().Cache(),
 		secretClient:                  clients.Core.Secret(),
 		secretCache:                   clients.Core.Secret().Cache(),
+		events:                        clients.Core.Event(),
 		configMapCache:                clients.Core.ConfigMap().Cache(),
 		clusterRegistrationTokenCache: clients.Mgmt.ClusterRegistrationToken().Cache(),
 		capiClient:                    clients.CAPI.Cluster(),
@@ -172,6 +182,7 @@ func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions
 		rkeBootstrap:                  clients.RKE.RKEBootstrap(),
 		rkeBootstrapCache:             clients.RKE.RKEBootstrap().Cache(),
 		etcdSnapshotCache:             clients.RKE.ETCDSnapshot().Cache(),
+		locker:                        locker.New(),
 		etcdS3Args: s3Args{
 			secretCache: clients.Core.Secret().Cache(),
 		},
@@ -180,6 +191,38 @@ func New(ctx context.Context, clients *wrangler.Context, functions InfoFunctions
 	}
 }
 
+// evaluateCanary evaluates the canary phase once the canary machines have been reconciled, and reports a halted
+// rollout through the CanaryHalted condition and an event. The condition is cleared if there is no canary phase.
+func (p *Planner) evaluateCanary(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan, canary *canaryRollout) rkev1.RKEControlPlaneStatus {
+	var newlyHalted bool
+	if canary != nil {
+		workers := roleOr(isOnlyLinuxWorker, isOnlyWindowsWorker)
+		newlyHalted = canary.evaluate(collect(clusterPlan, roleAnd(roleAnd(workers, canary.isCanary), roleNot(isDeleting))))
+	}
+	status.Canary = canary.status()
+
+	if canary == nil || !canary.halted {
+		if capr.CanaryHalted.IsTrue(&status) {
+			capr.CanaryHalted.False(&status)
+			capr.CanaryHalted.Message(&status, "")
+			capr.CanaryHalted.Reason(&status, "")
+		}
+		return status
+	}
+
+	message := canary.haltedMessage()
+	capr.CanaryHalted.True(&status)
+	capr.CanaryHalted.Message(&status, message)
+	capr.CanaryHalted.Reason(&status, CanaryHaltedReason)
+	if newlyHalted {
+		logrus.Warnf("[planner] rkecluster %s/%s: %s", cp.Namespace, cp.Name, message)
+		if _, err := p.events.Create(newCanaryHaltedEvent(cp, message)); err != nil {
+			logrus.Errorf("[planner] rkecluster %s/%s: failed to record canary halted event: %v", cp.Namespace, cp.Name, err)
+		}
+	}
+	return status
+}
+
 func (p *Planner) setMachineConditionStatus(clusterPlan *plan.Plan, machineNames []string, messagePrefix string, messages map[string][]string) error {
 	var waiting bool
 	for _, machineName := range machineNames {
@@ -325,11 +368,17 @@ func (p *Planner) Process(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlan
 		return status, err
 	}
 
-	if status, err = p.rotateCertificates(cp, status, clusterSecretTokens, plan); err != nil {
+	// Rotations that were not started yet and disruptive plan changes wait for the maintenance window to open.
+	window, err := newMaintenanceWindow(cp.Spec.UpgradeStrategy.MaintenanceWindow, time.Now())
+	if err != nil {
+		return status, fmt.Errorf("invalid maintenance window: %w", err)
+	}
+
+	if status, err = p.rotateCertificates(cp, status, clusterSecretTokens, plan, window); err != nil {
 		return status, err
 	}
 
-	if status, err = p.rotateEncryptionKeys(cp, status, clusterSecretTokens, plan, releaseData); err != nil {
+	if status, err = p.rotateEncryptionKeys(cp, status, clusterSecretTokens, plan, releaseData, window); err != nil {
 		return status, err
 	}
 
@@ -348,10 +397,12 @@ func (p *Planner) Process(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlan
 		return status, errWaiting("rkecontrolplane was already initialized but no etcd machines exist that have plans, indicating the etcd plane has been entirely replaced. Restoration from etcd snapshot is required.")
 	}
 
-	return p.fullReconcile(cp, status, clusterSecretTokens, plan, false)
+	return p.fullReconcile(cp, status, clusterSecretTokens, plan, false, window)
 }
 
-func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, clusterSecretTokens plan.Secret, plan *plan.Plan, ignoreDrainAndConcurrency bool) (rkev1.RKEControlPlaneStatus, error) {
+// fullReconcile reconciles the plans of all tiers. The maintenance window is only honored if drain and concurrency
+// are not ignored.
+func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, clusterSecretTokens plan.Secret, plan *plan.Plan, ignoreDrainAndConcurrency bool, window *maintenanceWindow) (rkev1.RKEControlPlaneStatus, error) {
 	// on the first run through, electInitNode will return a `generic.ErrSkip` as it is attempting to wait for the cache to catch up.
 	joinServer, err := p.electInitNode(cp, plan, true)
 	if err != nil {
@@ -362,6 +413,7 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		firstIgnoreError                             error
 		controlPlaneDrainOptions, workerDrainOptions rkev1.DrainOptions
 		controlPlaneConcurrency, workerConcurrency   string
+		canary                                       *canaryRollout
 	)
 
 	if !ignoreDrainAndConcurrency {
@@ -369,10 +421,16 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		workerDrainOptions = cp.Spec.UpgradeStrategy.WorkerDrainOptions
 		controlPlaneConcurrency = cp.Spec.UpgradeStrategy.ControlPlaneConcurrency
 		workerConcurrency = cp.Spec.UpgradeStrategy.WorkerConcurrency
+		canary, err = newCanaryRollout(cp, status.Canary, time.Now())
+		if err != nil {
+			return status, err
+		}
+	} else {
+		window = nil
 	}
 
 	// select all etcd and then filter to just initNodes so that unavailable count is correct
-	err = p.reconcile(cp, clusterSecretTokens, plan, true, bootstrapTier, isEtcd, isNotInitNodeOrIsDeleting, "1", "", controlPlaneDrainOptions, -1, 1, false)
+	err = p.reconcile(cp, clusterSecretTokens, plan, true, bootstrapTier, isEtcd, isNotInitNodeOrIsDeleting, "1", "", controlPlaneDrainOptions, -1, 1, false, window, nil)
 	capr.Bootstrapped.True(&status)
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
@@ -391,14 +449,14 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 	}
 
 	// Process all nodes that have the etcd role and are NOT an init node or deleting. Only process 1 node at a time.
-	err = p.reconcile(cp, clusterSecretTokens, plan, true, etcdTier, isEtcd, isInitNodeOrDeleting, "1", joinServer, controlPlaneDrainOptions, -1, 1, false)
+	err = p.reconcile(cp, clusterSecretTokens, plan, true, etcdTier, isEtcd, isInitNodeOrDeleting, "1", joinServer, controlPlaneDrainOptions, -1, 1, false, window, nil)
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
 	}
 
 	// Process all nodes that have the controlplane role and are NOT an init node or deleting.
-	err = p.reconcile(cp, clusterSecretTokens, plan, true, controlPlaneTier, isControlPlane, isInitNodeOrDeleting, controlPlaneConcurrency, joinServer, controlPlaneDrainOptions, -1, 1, false)
+	err = p.reconcile(cp, clusterSecretTokens, plan, true, controlPlaneTier, isControlPlane, isInitNodeOrDeleting, controlPlaneConcurrency, joinServer, controlPlaneDrainOptions, -1, 1, false, window, nil)
 	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
 	if err != nil {
 		return status, err
@@ -415,14 +473,7 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		return status, errWaiting("marking control plane as initialized and ready")
 	}
 
-	// Process all nodes that are ONLY linux worker nodes.
-	err = p.reconcile(cp, clusterSecretTokens, plan, false, workerTier, isOnlyLinuxWorker, isInitNodeOrDeleting, workerConcurrency, "", workerDrainOptions, -1, 1, false)
-	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
-	if err != nil {
-		return status, err
-	}
-
-	// Process all nodes that are ONLY windows worker nodes.
+	// Process all nodes that are ONLY windows worker nodes with these settings.
 	resetFailureCountOnRestart := false
 	windowsMaxFailures := -1
 	windowsMaxFailureThreshold := 1
@@ -439,12 +490,49 @@ func (p *Planner) fullReconcile(cp *rkev1.RKEControlPlane, status rkev1.RKEContr
 		resetFailureCountOnRestart = true
 	}
 
-	err = p.reconcile(cp, clusterSecretTokens, plan, false, workerTier, isOnlyWindowsWorker, isInitNodeOrDeleting, workerConcurrency, "", workerDrainOptions, windowsMaxFailures, windowsMaxFailureThreshold, resetFailureCountOnRestart)
-	firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
+	reconcileWorkers := func(exclude roleFilter) error {
+		// Process all nodes that are ONLY linux worker nodes.
+		err := p.reconcile(cp, clusterSecretTokens, plan, false, workerTier, isOnlyLinuxWorker, exclude, workerConcurrency, "", workerDrainOptions, -1, 1, false, window, canary)
+		firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
+		if err != nil {
+			return err
+		}
+
+		// Process all nodes that are ONLY windows worker nodes.
+		err = p.reconcile(cp, clusterSecretTokens, plan, false, workerTier, isOnlyWindowsWorker, exclude, workerConcurrency, "", workerDrainOptions, windowsMaxFailures, windowsMaxFailureThreshold, resetFailureCountOnRestart, window, canary)
+		firstIgnoreError, err = ignoreErrors(firstIgnoreError, err)
+		return err
+	}
+
+	if canary != nil {
+		// Process the canary worker nodes ahead of the other worker nodes, which are held until the canary nodes have
+		// been upgraded and healthy for the soak duration.
+		err = reconcileWorkers(roleOr(isInitNodeOrDeleting, roleNot(canary.isCanary)))
+		status = p.evaluateCanary(cp, status, plan, canary)
+		if err != nil {
+			return status, err
+		}
+		err = reconcileWorkers(roleOr(isInitNodeOrDeleting, canary.isCanary))
+	} else {
+		status = p.evaluateCanary(cp, status, plan, nil)
+		err = reconcileWorkers(isInitNodeOrDeleting)
+	}
 	if err != nil {
 		return status, err
 	}
 
+	// Report the plan changes that are waiting for the canary phase, and process them once the soak duration passed.
+	status.Canary = canary.status()
+	if remaining := canary.soakRemaining(); remaining > 0 {
+		p.rkeControlPlanes.EnqueueAfter(cp.Namespace, cp.Name, remaining)
+	}
+
+	// Report the plan changes that are waiting for the maintenance window, and process them once it opens.
+	status.MaintenanceWindow = window.status()
+	if status.MaintenanceWindow != nil && status.MaintenanceWindow.NextWindow != nil {
+		p.rkeControlPlanes.EnqueueAfter(cp.Namespace, cp.Name, time.Until(status.MaintenanceWindow.NextWindow.Time))
+	}
+
 	if firstIgnoreError != nil {
 		return status, errWaiting(firstIgnoreError.Error())
 	}
@@ -598,16 +686,22 @@ func calculateConcurrency(maxUnavailable string, reconcilables []*reconcilable,
 }
 
 func minorPlanChangeDetected(old, new plan.NodePlan) bool {
+	oldProbes, oldChecks := splitCanaryProbes(old.Probes)
+	newProbes, newChecks := splitCanaryProbes(new.Probes)
 	if !equality.Semantic.DeepEqual(old.Instructions, new.Instructions) ||
 		!equality.Semantic.DeepEqual(old.PeriodicInstructions, new.PeriodicInstructions) ||
-		!equality.Semantic.DeepEqual(old.Probes, new.Probes) ||
+		!equality.Semantic.DeepEqual(oldProbes, newProbes) ||
 		old.Error != new.Error {
 		return false
 	}
 
+	// The probes rendered for the canary checks only determine whether the canary phase succeeds, so changing the
+	// checks is a minor change that must not re-apply the plan disruptively.
+	checksChanged := !equality.Semantic.DeepEqual(oldChecks, newChecks)
+
 	if len(old.Files) == 0 && len(new.Files) == 0 {
-		// if the old plan had no files and no new files were found, there was no plan change detected
-		return false
+		// if the old plan had no files and no new files were found, only a change of the checks can be detected
+		return checksChanged
 	}
 
 	newFiles := make(map[string]plan.File)
@@ -641,7 +735,7 @@ func minorPlanChangeDetected(old, new plan.NodePlan) bool {
 		// There were new files and all were not major
 		return true
 	}
-	return false
+	return checksChanged
 }
 
 func kubeletVersionUpToDate(controlPlane *rkev1.RKEControlPlane, machine *capi.Machine) bool {
@@ -856,10 +950,10 @@ type reconcilable struct {
 	minorChange bool
 }
 
-func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, required bool, tierName string, include, exclude roleFilter, maxUnavailable, forcedJoinURL string, drainOptions rkev1.DrainOptions, maxFailures, failureThreshold int, resetFailureCountOnSystemAgentRestart bool) error {
+func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, required bool, tierName string, include, exclude roleFilter, maxUnavailable, forcedJoinURL string, drainOptions rkev1.DrainOptions, maxFailures, failureThreshold int, resetFailureCountOnSystemAgentRestart bool, window *maintenanceWindow, canary *canaryRollout) error {
 	var (
-		ready, outOfSync, nonReady, errMachines, draining, uncordoned []string
-		messages                                                      = map[string][]string{}
+		ready, outOfSync, nonReady, errMachines, draining, uncordoned, held, canaryHeld []string
+		messages                                                                        = map[string][]string{}
 	)
 
 	entries := collect(clusterPlan, include)
@@ -901,6 +995,15 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		return err
 	}
 
+	// New machines wait for the maintenance window as well if the changes of the other machines of the tier are held.
+	tierHeld := false
+	for _, r := range reconcilables {
+		if r.change && !r.minorChange && window.holds(r.entry) {
+			tierHeld = true
+			break
+		}
+	}
+
 	for _, r := range reconcilables {
 		logrus.Tracef("[planner] rkecluster %s/%s reconcile tier %s - processing machine entry: %s/%s", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
 		// we exclude here and not in collect to ensure that include matched at least one node
@@ -924,7 +1027,11 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		}
 		messages[r.entry.Machine.Name] = summary.Message
 
-		if r.entry.Plan == nil {
+		if r.entry.Plan == nil && window.holdNew(r.entry, tierHeld) {
+			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - holding initial plan for machine %s/%s until the maintenance window opens", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
+			held = append(held, r.entry.Machine.Name)
+			messages[r.entry.Machine.Name] = append(messages[r.entry.Machine.Name], WaitingForMaintenanceWindowMessage)
+		} else if r.entry.Plan == nil {
 			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - setting initial plan for machine %s/%s", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
 			logrus.Tracef("[planner] rkecluster %s/%s reconcile tier %s - initial plan for machine %s/%s new: %+v", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name, r.desiredPlan)
 			outOfSync = append(outOfSync, r.entry.Machine.Name)
@@ -938,6 +1045,14 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 			if err := p.store.UpdatePlan(r.entry, r.desiredPlan, r.joinedURL, maxFailures, failureThreshold); err != nil {
 				return err
 			}
+		} else if r.change && canary.hold(r.entry) {
+			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - plan for machine %s/%s did not match, holding until the canary phase completes", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
+			canaryHeld = append(canaryHeld, r.entry.Machine.Name)
+			messages[r.entry.Machine.Name] = append(messages[r.entry.Machine.Name], WaitingForCanaryMessage)
+		} else if r.change && window.hold(r.entry) {
+			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - plan for machine %s/%s did not match, holding until the maintenance window opens", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
+			held = append(held, r.entry.Machine.Name)
+			messages[r.entry.Machine.Name] = append(messages[r.entry.Machine.Name], WaitingForMaintenanceWindowMessage)
 		} else if r.change {
 			logrus.Debugf("[planner] rkecluster %s/%s reconcile tier %s - plan for machine %s/%s did not match, appending to outOfSync", controlPlane.Namespace, controlPlane.Name, tierName, r.entry.Machine.Namespace, r.entry.Machine.Name)
 			outOfSync = append(outOfSync, r.entry.Machine.Name)
@@ -1026,6 +1141,23 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		firstError = err
 	}
 
+	// Changes that wait for the maintenance window or the canary phase must not block the changes of the other tiers,
+	// so the resulting waiting error is ignored.
+	var heldError error
+	if err := p.setMachineConditionStatus(clusterPlan, held, fmt.Sprintf("waiting for maintenance window to configure %s node(s) ", tierName), messages); IsErrWaiting(err) {
+		heldError = errIgnore(err.Error())
+	} else if err != nil && firstError == nil {
+		firstError = err
+	}
+
+	if err := p.setMachineConditionStatus(clusterPlan, canaryHeld, fmt.Sprintf("waiting for canary machines to configure %s node(s) ", tierName), messages); IsErrWaiting(err) {
+		if heldError == nil {
+			heldError = errIgnore(err.Error())
+		}
+	} else if err != nil && firstError == nil {
+		firstError = err
+	}
+
 	// Ensure that the conditions that we control are updated.
 	if err := p.setMachineConditionStatus(clusterPlan, ready, "", nil); err != nil && firstError == nil {
 		firstError = err
@@ -1045,7 +1177,7 @@ func (p *Planner) reconcile(controlPlane *rkev1.RKEControlPlane, tokensSecret pl
 		return errIgnore("non-ready " + tierName + " machine(s) " + atMostThree(nonReady) + detailedMessage(nonReady, messages))
 	}
 
-	return nil
+	return heldError
 }
 
 // generatePlanWithConfigFiles will generate a node plan with the corresponding config files for the entry in question.
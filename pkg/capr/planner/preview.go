@@ -0,0 +1,436 @@
+package planner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	PreviewAdded   = "Added"
+	PreviewRemoved = "Removed"
+	PreviewChanged = "Changed"
+
+	// previewRedacted replaces the sensitive values in a plan preview.
+	previewRedacted = "<redacted>"
+	// minSecretValueLength is the length below which values of secrets are not redacted, since values like "true" would
+	// redact unrelated values.
+	minSecretValueLength = 6
+)
+
+// sensitiveKey matches the keys of config file entries, environment variables and arguments whose values must not be
+// shown in a plan preview, in addition to the values read from secrets.
+var sensitiveKey = regexp.MustCompile(`(?i)(token|password|secret|key|credential|auth|cert)`)
+
+// MachinePlanPreview describes how the plan of a machine would change.
+type MachinePlanPreview struct {
+	MachineName string `json:"machineName"`
+	// Initial denotes that no plan was delivered to the machine yet, so the plan would be its initial plan.
+	Initial bool `json:"initial,omitempty"`
+	// Changed denotes that the plan of the machine would change.
+	Changed bool `json:"changed"`
+	// MinorChange denotes that the change would be applied immediately, without regard to concurrency or draining.
+	MinorChange bool `json:"minorChange,omitempty"`
+	// Drain denotes that the machine would be drained before the change is applied.
+	Drain bool `json:"drain,omitempty"`
+	// Files are the files of the plan that would be added, removed or changed.
+	Files []PlanItemPreview `json:"files,omitempty"`
+	// Instructions are the one time instructions of the plan that would be added, removed or changed.
+	Instructions []PlanItemPreview `json:"instructions,omitempty"`
+	// Error is the error encountered while rendering the plan of the machine.
+	Error string `json:"error,omitempty"`
+}
+
+// PlanItemPreview describes how a file or instruction of a plan would change.
+type PlanItemPreview struct {
+	// Name is the path of a file or the name of an instruction.
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// Changes are the values that would change, as far as they can be determined. Sensitive values are redacted.
+	Changes []PlanValueChange `json:"changes,omitempty"`
+}
+
+// PlanValueChange is a value of a file or instruction that would change. Old or New is empty if the value would be
+// added or removed.
+type PlanValueChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Preview renders the plans the planner would deliver to the machines of the control plane if its spec were replaced
+// by the given spec, and compares them to the current plans of the machines. Nothing is delivered or persisted.
+func (p *Planner) Preview(cp *rkev1.RKEControlPlane, spec rkev1.RKEControlPlaneSpec) ([]MachinePlanPreview, error) {
+	capiCluster, err := capr.GetOwnerCAPICluster(cp, p.capiClusters)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterPlan, _, err := p.store.Load(capiCluster, cp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The plans are rendered by a copy of the planner recording the secrets it reads, so that every value taken from a
+	// secret is redacted from the preview.
+	secrets := &recordingSecretCache{SecretCache: p.secretCache}
+	previewer := *p
+	previewer.secretCache = secrets
+	previewer.etcdS3Args.secretCache = secrets
+	redactor := &previewRedactor{secrets: secrets}
+
+	// The state secret isn't created here, a cluster without one has never been planned.
+	_, tokensSecret, err := previewer.ensureRKEStateSecret(cp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := cp.DeepCopy()
+	candidate.Spec = spec
+
+	var result []MachinePlanPreview
+	for _, entry := range collect(clusterPlan, anyRole) {
+		preview := MachinePlanPreview{
+			MachineName: entry.Machine.Name,
+			Initial:     entry.Plan == nil,
+		}
+
+		var joinServer string
+		if entry.Plan != nil {
+			joinServer = entry.Plan.JoinedTo
+		}
+		joinServer, err = determineJoinURL(candidate, entry, clusterPlan, joinServer)
+		if err != nil {
+			preview.Error = err.Error()
+			result = append(result, preview)
+			continue
+		}
+
+		desiredPlan, _, err := previewer.desiredPlan(candidate, tokensSecret, entry, joinServer)
+		if err != nil {
+			preview.Error = err.Error()
+			result = append(result, preview)
+			continue
+		}
+
+		var currentPlan plan.NodePlan
+		if entry.Plan != nil {
+			currentPlan = entry.Plan.Plan
+			preview.Changed = !p.equalities.DeepEqual(currentPlan, desiredPlan)
+			preview.MinorChange = minorPlanChangeDetected(currentPlan, desiredPlan)
+
+			drainOptions := spec.UpgradeStrategy.WorkerDrainOptions
+			if isEtcd(entry) || isControlPlane(entry) {
+				drainOptions = spec.UpgradeStrategy.ControlPlaneDrainOptions
+			}
+			preview.Drain = preview.Changed && !preview.MinorChange && drainOptions.Enabled && len(clusterPlan.Machines) > 1 &&
+				shouldDrain(entry.Plan.AppliedPlan, desiredPlan)
+		} else {
+			preview.Changed = true
+		}
+
+		preview.Files = redactor.files(currentPlan.Files, desiredPlan.Files)
+		preview.Instructions = redactor.instructions(currentPlan.Instructions, desiredPlan.Instructions)
+		result = append(result, preview)
+	}
+
+	return result, nil
+}
+
+// recordingSecretCache records the values of the secrets read through it.
+type recordingSecretCache struct {
+	corecontrollers.SecretCache
+
+	lock   sync.Mutex
+	values map[string]bool
+}
+
+func (c *recordingSecretCache) Get(namespace, name string) (*corev1.Secret, error) {
+	secret, err := c.SecretCache.Get(namespace, name)
+	if err == nil {
+		c.record(secret)
+	}
+	return secret, err
+}
+
+func (c *recordingSecretCache) List(namespace string, selector labels.Selector) ([]*corev1.Secret, error) {
+	secrets, err := c.SecretCache.List(namespace, selector)
+	for _, secret := range secrets {
+		c.record(secret)
+	}
+	return secrets, err
+}
+
+func (c *recordingSecretCache) GetByIndex(indexName, key string) ([]*corev1.Secret, error) {
+	secrets, err := c.SecretCache.GetByIndex(indexName, key)
+	for _, secret := range secrets {
+		c.record(secret)
+	}
+	return secrets, err
+}
+
+func (c *recordingSecretCache) record(secret *corev1.Secret) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.values == nil {
+		c.values = map[string]bool{}
+	}
+	for _, value := range secret.Data {
+		if len(value) >= minSecretValueLength {
+			c.values[string(value)] = true
+		}
+	}
+	for _, value := range secret.StringData {
+		if len(value) >= minSecretValueLength {
+			c.values[value] = true
+		}
+	}
+}
+
+// containsSecret returns whether the value contains the value of a recorded secret.
+func (c *recordingSecretCache) containsSecret(value string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for secretValue := range c.values {
+		if strings.Contains(value, secretValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// previewRedactor compares plans, redacting the values taken from secrets and the values of sensitive keys.
+type previewRedactor struct {
+	secrets *recordingSecretCache
+}
+
+// files compares the files of the plans by path. The changes of files holding a YAML or JSON map, such as the
+// config file of the distribution, are listed by key.
+func (r *previewRedactor) files(current, desired []plan.File) []PlanItemPreview {
+	currentFiles := map[string]plan.File{}
+	for _, file := range current {
+		currentFiles[file.Path] = file
+	}
+
+	var result []PlanItemPreview
+	for _, file := range desired {
+		currentFile, ok := currentFiles[file.Path]
+		delete(currentFiles, file.Path)
+		if !ok {
+			result = append(result, PlanItemPreview{Name: file.Path, Action: PreviewAdded})
+			continue
+		}
+		if currentFile.Content == file.Content && currentFile.Permissions == file.Permissions {
+			continue
+		}
+
+		item := PlanItemPreview{Name: file.Path, Action: PreviewChanged}
+		if currentFile.Permissions != file.Permissions {
+			item.Changes = append(item.Changes, PlanValueChange{Path: "permissions", Old: currentFile.Permissions, New: file.Permissions})
+		}
+		item.Changes = append(item.Changes, r.fileContent(currentFile.Content, file.Content)...)
+		result = append(result, item)
+	}
+	for path := range currentFiles {
+		result = append(result, PlanItemPreview{Name: path, Action: PreviewRemoved})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// fileContent lists the changed keys of the base64 encoded contents if both hold a YAML or JSON map.
+func (r *previewRedactor) fileContent(current, desired string) []PlanValueChange {
+	currentValues, ok := decodeFileMap(current)
+	if !ok {
+		return nil
+	}
+	desiredValues, ok := decodeFileMap(desired)
+	if !ok {
+		return nil
+	}
+
+	currentFields, desiredFields := map[string]interface{}{}, map[string]interface{}{}
+	flattenPreview("", currentValues, currentFields)
+	flattenPreview("", desiredValues, desiredFields)
+
+	var changes []PlanValueChange
+	for path, desiredValue := range desiredFields {
+		currentValue, ok := currentFields[path]
+		delete(currentFields, path)
+		if ok && reflect.DeepEqual(currentValue, desiredValue) {
+			continue
+		}
+		change := PlanValueChange{Path: path, New: r.value(path, desiredValue)}
+		if ok {
+			change.Old = r.value(path, currentValue)
+		}
+		changes = append(changes, change)
+	}
+	for path, currentValue := range currentFields {
+		changes = append(changes, PlanValueChange{Path: path, Old: r.value(path, currentValue)})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func decodeFileMap(content string) (map[string]interface{}, bool) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, false
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// flattenPreview records the values of the map by their dotted path in fields. Lists are leaves.
+func flattenPreview(prefix string, values map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenPreview(path, nested, fields)
+			continue
+		}
+		fields[path] = value
+	}
+}
+
+// value returns the value of the path for the preview, redacted if it was read from a secret or its path is sensitive.
+func (r *previewRedactor) value(path string, value interface{}) string {
+	if sensitiveKey.MatchString(path) {
+		return previewRedacted
+	}
+	if list, ok := value.([]interface{}); ok {
+		var items []string
+		for _, item := range list {
+			items = append(items, r.arg(fmt.Sprint(item)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return r.string(fmt.Sprint(value))
+}
+
+// string redacts the value if it contains the value of a secret.
+func (r *previewRedactor) string(value string) string {
+	if r.secrets != nil && r.secrets.containsSecret(value) {
+		return previewRedacted
+	}
+	return value
+}
+
+// arg redacts the value of an argument or environment variable of the form key=value whose key is sensitive or which
+// contains the value of a secret.
+func (r *previewRedactor) arg(arg string) string {
+	key, value, ok := strings.Cut(arg, "=")
+	if ok && (sensitiveKey.MatchString(key) || r.string(value) == previewRedacted) {
+		return key + "=" + previewRedacted
+	}
+	return r.string(arg)
+}
+
+// instructions compares the one time instructions of the plans by name.
+func (r *previewRedactor) instructions(current, desired []plan.OneTimeInstruction) []PlanItemPreview {
+	currentInstructions := map[string]plan.OneTimeInstruction{}
+	for _, instruction := range current {
+		currentInstructions[instruction.Name] = instruction
+	}
+
+	var result []PlanItemPreview
+	for _, instruction := range desired {
+		currentInstruction, ok := currentInstructions[instruction.Name]
+		delete(currentInstructions, instruction.Name)
+		if !ok {
+			result = append(result, PlanItemPreview{Name: instruction.Name, Action: PreviewAdded})
+			continue
+		}
+
+		var changes []PlanValueChange
+		if currentInstruction.Image != instruction.Image {
+			changes = append(changes, PlanValueChange{Path: "image", Old: r.string(currentInstruction.Image), New: r.string(instruction.Image)})
+		}
+		if currentInstruction.Command != instruction.Command {
+			changes = append(changes, PlanValueChange{Path: "command", Old: r.string(currentInstruction.Command), New: r.string(instruction.Command)})
+		}
+		if !reflect.DeepEqual(currentInstruction.Args, instruction.Args) {
+			changes = append(changes, PlanValueChange{Path: "args", Old: r.args(currentInstruction.Args), New: r.args(instruction.Args)})
+		}
+		changes = append(changes, r.env(currentInstruction.Env, instruction.Env)...)
+		if len(changes) > 0 || currentInstruction.SaveOutput != instruction.SaveOutput {
+			result = append(result, PlanItemPreview{Name: instruction.Name, Action: PreviewChanged, Changes: changes})
+		}
+	}
+	for name := range currentInstructions {
+		result = append(result, PlanItemPreview{Name: name, Action: PreviewRemoved})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (r *previewRedactor) args(args []string) string {
+	redacted := make([]string, 0, len(args))
+	for _, arg := range args {
+		redacted = append(redacted, r.arg(arg))
+	}
+	return strings.Join(redacted, " ")
+}
+
+// env compares the environment variables of the form KEY=value by key.
+func (r *previewRedactor) env(current, desired []string) []PlanValueChange {
+	parse := func(env []string) map[string]string {
+		result := map[string]string{}
+		for _, v := range env {
+			key, value, _ := strings.Cut(v, "=")
+			result[key] = value
+		}
+		return result
+	}
+	currentEnv, desiredEnv := parse(current), parse(desired)
+
+	var changes []PlanValueChange
+	for key, value := range desiredEnv {
+		currentValue, ok := currentEnv[key]
+		delete(currentEnv, key)
+		if ok && currentValue == value {
+			continue
+		}
+		change := PlanValueChange{Path: "env." + key, New: r.value(key, value)}
+		if ok {
+			change.Old = r.value(key, currentValue)
+		}
+		changes = append(changes, change)
+	}
+	for key, value := range currentEnv {
+		changes = append(changes, PlanValueChange{Path: "env." + key, Old: r.value(key, value)})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
@@ -0,0 +1,112 @@
+package planner
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPreviewFiles(t *testing.T) {
+	encode := func(content string) string {
+		return base64.StdEncoding.EncodeToString([]byte(content))
+	}
+	current := []plan.File{
+		{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: encode(`{"token":"old","cni":"calico","kube-apiserver-arg":["audit-log-maxage=30"],"etcd-s3":false}`)},
+		{Path: "/etc/rancher/rke2/registries.yaml", Content: encode("mirrors: {}\n")},
+		{Path: "/var/lib/rancher/rke2/server/manifests/rancher/managed-chart-config.yaml", Content: encode("not a map"), Permissions: "0600"},
+	}
+	desired := []plan.File{
+		{Path: "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml", Content: encode(`{"token":"new","cni":"cilium","kube-apiserver-arg":["audit-log-maxage=60"],"etcd-s3-secret-key":"secret"}`)},
+		{Path: "/var/lib/rancher/rke2/server/manifests/rancher/managed-chart-config.yaml", Content: encode("still not a map"), Permissions: "0600"},
+		{Path: "/var/lib/rancher/rke2/agent/etc/containerd/config.toml.tmpl", Content: encode("version = 2")},
+	}
+
+	assert.Equal(t, []PlanItemPreview{
+		{
+			Name:   "/etc/rancher/rke2/config.yaml.d/50-rancher.yaml",
+			Action: PreviewChanged,
+			Changes: []PlanValueChange{
+				{Path: "cni", Old: "calico", New: "cilium"},
+				{Path: "etcd-s3", Old: "false"},
+				{Path: "etcd-s3-secret-key", New: previewRedacted},
+				{Path: "kube-apiserver-arg", Old: "[audit-log-maxage=30]", New: "[audit-log-maxage=60]"},
+				{Path: "token", Old: previewRedacted, New: previewRedacted},
+			},
+		},
+		{Name: "/etc/rancher/rke2/registries.yaml", Action: PreviewRemoved},
+		{Name: "/var/lib/rancher/rke2/agent/etc/containerd/config.toml.tmpl", Action: PreviewAdded},
+		{Name: "/var/lib/rancher/rke2/server/manifests/rancher/managed-chart-config.yaml", Action: PreviewChanged},
+	}, (&previewRedactor{}).files(current, desired))
+
+	assert.Empty(t, (&previewRedactor{}).files(current, current))
+}
+
+func TestPreviewInstructions(t *testing.T) {
+	current := []plan.OneTimeInstruction{
+		{Name: "install", Image: "rancher/system-agent-installer-rke2:v1.30.1-rke2r1", Command: "sh", Env: []string{"INSTALL_RKE2_EXEC=server", "CATTLE_TOKEN=old"}},
+		{Name: "capture-address", Command: "sh", Args: []string{"-c", "ip addr"}},
+	}
+	desired := []plan.OneTimeInstruction{
+		{Name: "install", Image: "rancher/system-agent-installer-rke2:v1.30.2-rke2r1", Command: "sh", Env: []string{"INSTALL_RKE2_EXEC=server", "CATTLE_TOKEN=new", "RESTART_STAMP=abc"}},
+		{Name: "etcd-snapshot", Command: "rke2", Args: []string{"etcd-snapshot", "--s3-secret-key=secret"}},
+	}
+
+	assert.Equal(t, []PlanItemPreview{
+		{Name: "capture-address", Action: PreviewRemoved},
+		{Name: "etcd-snapshot", Action: PreviewAdded},
+		{
+			Name:   "install",
+			Action: PreviewChanged,
+			Changes: []PlanValueChange{
+				{Path: "image", Old: "rancher/system-agent-installer-rke2:v1.30.1-rke2r1", New: "rancher/system-agent-installer-rke2:v1.30.2-rke2r1"},
+				{Path: "env.CATTLE_TOKEN", Old: previewRedacted, New: previewRedacted},
+				{Path: "env.RESTART_STAMP", New: "abc"},
+			},
+		},
+	}, (&previewRedactor{}).instructions(current, desired))
+
+	changes := (&previewRedactor{}).instructions(
+		[]plan.OneTimeInstruction{{Name: "etcd-snapshot", Args: []string{"--s3-secret-key=old", "--s3-bucket=a"}}},
+		[]plan.OneTimeInstruction{{Name: "etcd-snapshot", Args: []string{"--s3-secret-key=new", "--s3-bucket=b"}}},
+	)
+	assert.Equal(t, []PlanValueChange{{
+		Path: "args",
+		Old:  "--s3-secret-key=" + previewRedacted + " --s3-bucket=a",
+		New:  "--s3-secret-key=" + previewRedacted + " --s3-bucket=b",
+	}}, changes[0].Changes)
+}
+
+func TestPreviewRedactsSecretValues(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	secretCache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+	secretCache.EXPECT().Get("fleet-default", "registry-auth").Return(&corev1.Secret{
+		Data: map[string][]byte{"username": []byte("robot$pull"), "password": []byte("hunter2hunter2"), "flag": []byte("on")},
+	}, nil)
+
+	secrets := &recordingSecretCache{SecretCache: secretCache}
+	_, err := secrets.Get("fleet-default", "registry-auth")
+	require.NoError(t, err)
+	r := &previewRedactor{secrets: secrets}
+
+	encode := func(content string) string {
+		return base64.StdEncoding.EncodeToString([]byte(content))
+	}
+	changes := r.fileContent(
+		encode(`{"mirrors":{"docker.io":{"endpoint":"https://old.example.com"}},"mode":"on"}`),
+		encode(`{"mirrors":{"docker.io":{"endpoint":"https://robot$pull@registry.example.com"}},"user":"robot$pull","mode":"off"}`),
+	)
+	assert.Equal(t, []PlanValueChange{
+		{Path: "mirrors.docker.io.endpoint", Old: "https://old.example.com", New: previewRedacted},
+		{Path: "mode", Old: "on", New: "off"},
+		{Path: "user", New: previewRedacted},
+	}, changes, "values holding values of secrets are redacted regardless of their key, short values are not")
+
+	assert.Equal(t, "--registry-user="+previewRedacted+" --opt=value", r.args([]string{"--registry-user=hunter2hunter2", "--opt=value"}))
+	assert.Equal(t, previewRedacted, r.arg("robot$pull"))
+}
@@ -163,6 +163,14 @@ func (p *Planner) generateProbes(controlPlane *rkev1.RKEControlPlane, entry *pla
 
 	probes = replaceURLForProbes(probes, loopbackAddress)
 
+	checks, err := canaryProbes(controlPlane, entry)
+	if err != nil {
+		return probes, err
+	}
+	for name, probe := range checks {
+		probes[name] = probe
+	}
+
 	return probes, nil
 }
 
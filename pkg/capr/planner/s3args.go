@@ -185,3 +185,35 @@ func getS3Credential(secretCache corecontrollers.SecretCache, namespace, name st
 		Folder:        string(data["defaultFolder"]),
 	}, nil
 }
+
+// S3Config is an S3 configuration with the defaults of its cloud credential applied, along with the keys of the cloud
+// credential.
+type S3Config struct {
+	rkev1.ETCDSnapshotS3
+	AccessKey string
+	SecretKey string
+}
+
+// ResolveS3Config applies the defaults of the cloud credential of the S3 configuration to it. If the S3 configuration
+// does not reference a cloud credential, the cloud credential with the given default name is used.
+func ResolveS3Config(secretCache corecontrollers.SecretCache, namespace string, s3 *rkev1.ETCDSnapshotS3, defaultCredentialName string) (S3Config, error) {
+	credName := first(s3.CloudCredentialName, defaultCredentialName)
+	s3Cred, err := getS3Credential(secretCache, namespace, credName)
+	if err != nil {
+		return S3Config{}, err
+	}
+
+	return S3Config{
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{
+			Endpoint:            first(s3.Endpoint, s3Cred.Endpoint),
+			EndpointCA:          first(s3.EndpointCA, s3Cred.EndpointCA),
+			SkipSSLVerify:       s3.SkipSSLVerify || s3Cred.SkipSSLVerify,
+			Bucket:              first(s3.Bucket, s3Cred.Bucket),
+			Region:              first(s3.Region, s3Cred.Region),
+			CloudCredentialName: credName,
+			Folder:              first(s3.Folder, s3Cred.Folder),
+		},
+		AccessKey: s3Cred.AccessKey,
+		SecretKey: s3Cred.SecretKey,
+	}, nil
+}
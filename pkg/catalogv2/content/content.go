@@ -18,6 +18,8 @@
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,6 +36,7 @@
 	"github.com/rancher/rancher/pkg/catalogv2/helm"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
 	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/settings"
 	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
@@ -263,6 +266,72 @@ func (c *Manager) Chart(namespace, name, chartName, version string, skipFilter b
 	}
 }
 
+// Verify verifies the chart archive of a chart version against the verification policy of the Helm repository.
+//
+// The chart archive of an HTTP Helm repository is verified against its provenance file, the chart archive of
+// an OCI Helm repository must match the chart layer of the OCI artifact that carries the cosign signatures.
+//
+// The function returns nil if the repository has no verification policy, or if the policy only warns about failures.
+func (c *Manager) Verify(namespace, name, chartName, version string, chartData []byte) error {
+	repo, err := c.getRepo(namespace, name)
+	if err != nil {
+		return err
+	}
+	policy := repo.spec.Verification
+	if policy == nil {
+		return nil
+	}
+
+	index, err := c.Index(namespace, name, "", true)
+	if err != nil {
+		return err
+	}
+	chart, err := index.Get(chartName, version)
+	if err != nil {
+		return err
+	}
+
+	return verify.Enforce(policy, chartName+"-"+version, c.verify(repo, chart, chartData))
+}
+
+func (c *Manager) verify(repo repoDef, chart *repo.ChartVersion, chartData []byte) error {
+	if repo.status.Commit != "" {
+		return verify.ErrGitUnsupported
+	}
+	if len(chart.URLs) == 0 {
+		return errors.New("chart has no urls specified")
+	}
+
+	verifier, err := verify.New(c.secrets, repo.spec.Verification)
+	if err != nil {
+		return err
+	}
+	secret, err := catalogv2.GetSecret(c.secrets, repo.spec, repo.metadata.Namespace)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(chartData)
+	chartDigest := hex.EncodeToString(sum[:])
+	if registry.IsOCI(chart.URLs[0]) {
+		layerDigest, err := oci.VerifyChart(secret, chart, *repo.spec, verifier)
+		if err != nil {
+			return err
+		}
+		if layerDigest.Encoded() != chartDigest {
+			return fmt.Errorf("chart archive does not match the verified OCI artifact: sha256:%s != %s", chartDigest, layerDigest)
+		}
+		return nil
+	}
+
+	prov, fileName, err := helmhttp.Provenance(secret, repo.status.URL, repo.spec.CABundle, repo.spec.InsecureSkipTLSverify, repo.spec.DisableSameOriginCheck, chart)
+	if err != nil {
+		return err
+	}
+
+	return verifier.Provenance(prov, fileName, chartDigest)
+}
+
 // Info retrieves detailed information about a specific Helm chart from a Helm repository.
 //
 // The function uses the Chart method to get the content of the Helm chart.
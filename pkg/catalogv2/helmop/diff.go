@@ -0,0 +1,330 @@
+package helmop
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// dryRunAction is the action of the operations that preview an upgrade.
+	dryRunAction = "dry-run"
+	// redacted replaces the values of the data of secrets in the changes of a diff.
+	redacted = "<redacted>"
+
+	// dryRunScriptFile and dryRunFilterFile are the files of the operation secret running the commands of a dry run.
+	dryRunScriptFile = "dry-run.sh"
+	dryRunFilterFile = "dry-run.jq"
+)
+
+// dryRunScript runs the commands of a dry run operation in place of helm-cmd. The releases printed by helm hold the
+// values and the rendered secrets of the charts, so they are reduced by the dryRunFilter before they are printed to the
+// logs of the pod, which Diff reads.
+const dryRunScript = `#!/bin/bash
+set -e -o pipefail
+for operation in operation*; do
+	xargs -0 helm < "${operation}" | jq -c -f ` + dryRunFilterFile + `
+done
+`
+
+// dryRunFilter reduces a release printed by helm to its name, namespace, version, status, chart metadata and
+// manifest, dropping its values, chart files and hooks. The values of the data and stringData of the secrets of the
+// manifest are replaced by the redacted marker, so changes of secret data are not shown by Diff.
+const dryRunFilter = `def redactsecret:
+  if test("(?m)^kind:[ ]*[\"']?Secret[\"']?[ ]*$") then
+    reduce (split("\n")[]) as $line ({lines: [], block: false, indent: null};
+      if ($line | test("^(data|stringData):")) then
+        .block = true | .indent = null | .lines += [($line | sub(":.*$"; ":"))]
+      elif .block and ($line | test("^([ \t]|$)")) then
+        if .indent == null and ($line != "") then .indent = ($line | capture("^(?<i>[ \t]+)").i) else . end
+        | .indent as $indent
+        | if $indent != null and ($line | test("^" + $indent + "[^ \t#-]")) then
+            .lines += [($line | sub("^(?<k>[ \t]+(\"[^\"]*\"|'[^']*'|[^:]*)):.*$"; "\(.k): ` + redacted + `"))]
+          else . end
+      else
+        .block = false | .lines += [$line]
+      end)
+    | .lines | join("\n")
+  else . end;
+{
+  name: .name,
+  namespace: .namespace,
+  version: .version,
+  info: {status: .info.status},
+  chart: {metadata: .chart.metadata},
+  manifest: ((.manifest // "") | split("\n---") | map(redactsecret) | join("\n---"))
+}
+`
+
+// Diff receives the namespace and name of a dry run operation that completed successfully.
+// Reads the deployed and upgraded releases printed by the operation from the logs of its pod and
+// returns the changes the upgrade would make to the manifests of the releases. The logs of dry run
+// operations are only read by Diff, they aren't available through the logs link.
+func (s *Operations) Diff(ctx context.Context, namespace, name string) (*types2.ChartDiffOutput, error) {
+	op, pod, err := s.getOperationPod(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Status.Action != dryRunAction {
+		return nil, apierror.NewAPIError(validation.ActionNotAvailable, "operation is not a dry run")
+	}
+	if !helmSucceeded(pod) {
+		return nil, apierror.NewAPIError(validation.InvalidState, "dry run has not completed successfully")
+	}
+
+	client, err := s.cg.AdminK8sInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{Container: "helm"}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	releases, err := readReleases(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffReleases(releases)
+}
+
+// helmSucceeded returns whether the helm container of the operation pod terminated successfully
+func helmSucceeded(pod *v1.Pod) bool {
+	for _, container := range pod.Status.ContainerStatuses {
+		if container.Name == "helm" {
+			return container.State.Terminated != nil && container.State.Terminated.ExitCode == 0
+		}
+	}
+	return false
+}
+
+// readReleases returns the releases printed as JSON in the logs of a dry run operation, in the order they were printed.
+// Lines that aren't releases are skipped.
+func readReleases(logs io.Reader) ([]*release.Release, error) {
+	var (
+		releases []*release.Release
+		// Releases are printed on a single line that can be far longer than bufio.Scanner allows.
+		reader = bufio.NewReader(logs)
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[0] == '{' {
+			rel := &release.Release{}
+			if json.Unmarshal(bytes.TrimSpace(line), rel) == nil && rel.Name != "" {
+				releases = append(releases, rel)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return releases, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// diffReleases receives the releases printed by a dry run operation, which prints the deployed release
+// before the upgraded release for each chart, and returns the changes between their manifests.
+func diffReleases(releases []*release.Release) (*types2.ChartDiffOutput, error) {
+	if len(releases)%2 != 0 {
+		return nil, fmt.Errorf("expected a deployed and an upgraded release for each chart, found %d releases", len(releases))
+	}
+
+	output := &types2.ChartDiffOutput{}
+	for i := 0; i < len(releases); i += 2 {
+		from, to := releases[i], releases[i+1]
+		if from.Name != to.Name || from.Namespace != to.Namespace {
+			return nil, fmt.Errorf("expected upgraded release %s/%s, found %s/%s", from.Namespace, from.Name, to.Namespace, to.Name)
+		}
+
+		releaseDiff, err := diffManifests(from.Manifest, to.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare the manifests of release %s/%s: %w", from.Namespace, from.Name, err)
+		}
+		releaseDiff.ReleaseName = from.Name
+		releaseDiff.Namespace = from.Namespace
+		releaseDiff.FromVersion = chartVersion(from)
+		releaseDiff.ToVersion = chartVersion(to)
+		output.Releases = append(output.Releases, releaseDiff)
+	}
+
+	return output, nil
+}
+
+func chartVersion(rel *release.Release) string {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return ""
+	}
+	return rel.Chart.Metadata.Version
+}
+
+// diffManifests returns the objects of the manifest to that aren't in the manifest from, the objects of
+// the manifest from that aren't in the manifest to, and the objects of both whose fields differ.
+// Objects are sorted by their api version, kind, namespace and name and changes by their path.
+func diffManifests(from, to string) (types2.ReleaseDiff, error) {
+	var result types2.ReleaseDiff
+
+	fromObjs, fromKeys, err := manifestObjects(from)
+	if err != nil {
+		return result, err
+	}
+	toObjs, toKeys, err := manifestObjects(to)
+	if err != nil {
+		return result, err
+	}
+
+	for _, key := range toKeys {
+		toObj := toObjs[key]
+		fromObj, ok := fromObjs[key]
+		if !ok {
+			result.Added = append(result.Added, objectDiff(toObj))
+			continue
+		}
+		if changes := diffObjects(fromObj, toObj); len(changes) > 0 {
+			diff := objectDiff(toObj)
+			diff.Changes = changes
+			result.Changed = append(result.Changed, diff)
+		}
+	}
+	for _, key := range fromKeys {
+		if _, ok := toObjs[key]; !ok {
+			result.Removed = append(result.Removed, objectDiff(fromObjs[key]))
+		}
+	}
+
+	return result, nil
+}
+
+// manifestObjects returns the objects of the manifest by their key, along with the sorted keys.
+func manifestObjects(manifest string) (map[string]*unstructured.Unstructured, []string, error) {
+	objs, err := yaml.ToObjects(strings.NewReader(manifest))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := map[string]*unstructured.Unstructured{}
+	var keys []string
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected object %T in manifest", obj)
+		}
+		key := strings.Join([]string{u.GetAPIVersion(), u.GetKind(), u.GetNamespace(), u.GetName()}, "/")
+		if _, ok := result[key]; !ok {
+			keys = append(keys, key)
+		}
+		result[key] = u
+	}
+	sort.Strings(keys)
+
+	return result, keys, nil
+}
+
+func objectDiff(obj *unstructured.Unstructured) types2.ObjectDiff {
+	return types2.ObjectDiff{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// diffObjects returns the changes of the fields of the objects, redacting the data of secrets.
+func diffObjects(from, to *unstructured.Unstructured) []types2.FieldChange {
+	fromFields, toFields := map[string]interface{}{}, map[string]interface{}{}
+	flatten("", from.Object, fromFields)
+	flatten("", to.Object, toFields)
+
+	paths := map[string]bool{}
+	for path := range fromFields {
+		paths[path] = true
+	}
+	for path := range toFields {
+		paths[path] = true
+	}
+
+	isSecret := to.GetAPIVersion() == "v1" && to.GetKind() == "Secret"
+	var changes []types2.FieldChange
+	for path := range paths {
+		fromValue, inFrom := fromFields[path]
+		toValue, inTo := toFields[path]
+		if inFrom == inTo && reflect.DeepEqual(fromValue, toValue) {
+			continue
+		}
+
+		change := types2.FieldChange{Path: path}
+		if isSecret && (strings.HasPrefix(path, ".data") || strings.HasPrefix(path, ".stringData")) {
+			if inFrom {
+				change.Old = redacted
+			}
+			if inTo {
+				change.New = redacted
+			}
+		} else {
+			if inFrom {
+				change.Old = encodeValue(fromValue)
+			}
+			if inTo {
+				change.New = encodeValue(toValue)
+			}
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// flatten records the leaves of the value by their path in fields. Empty maps and slices are leaves.
+func flatten(path string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			fields[path] = v
+		}
+		for key, value := range v {
+			if strings.ContainsAny(key, ".[]") {
+				flatten(fmt.Sprintf("%s[%q]", path, key), value, fields)
+			} else {
+				flatten(path+"."+key, value, fields)
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[path] = v
+		}
+		for i, value := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), value, fields)
+		}
+	default:
+		fields[path] = v
+	}
+}
+
+func encodeValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
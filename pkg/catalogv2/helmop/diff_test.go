@@ -0,0 +1,200 @@
+package helmop
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/api/steve/catalog/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const deployedManifest = `---
+# Source: test/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+  namespace: test-ns
+data:
+  password: b2xk
+  username: YWRtaW4=
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+  namespace: test-ns
+  labels:
+    app.kubernetes.io/version: "1.0.0"
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: test
+        image: test:1.0.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+  namespace: test-ns
+`
+
+const upgradedManifest = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+  namespace: test-ns
+data:
+  password: bmV3
+  username: YWRtaW4=
+  token: dG9rZW4=
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+  namespace: test-ns
+  labels:
+    app.kubernetes.io/version: "1.1.0"
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: test
+        image: test:1.1.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: added
+  namespace: test-ns
+`
+
+func Test_diffManifests(t *testing.T) {
+	diff, err := diffManifests(deployedManifest, upgradedManifest)
+	require.NoError(t, err)
+
+	assert.Equal(t, []types.ObjectDiff{{APIVersion: "v1", Kind: "Service", Namespace: "test-ns", Name: "added"}}, diff.Added)
+	assert.Equal(t, []types.ObjectDiff{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "removed"}}, diff.Removed)
+	assert.Equal(t, []types.ObjectDiff{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Namespace:  "test-ns",
+			Name:       "test",
+			Changes: []types.FieldChange{
+				{Path: `.metadata.labels["app.kubernetes.io/version"]`, Old: `"1.0.0"`, New: `"1.1.0"`},
+				{Path: ".spec.template.spec.containers[0].image", Old: `"test:1.0.0"`, New: `"test:1.1.0"`},
+			},
+		},
+		{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Namespace:  "test-ns",
+			Name:       "test",
+			Changes: []types.FieldChange{
+				{Path: ".data.password", Old: redacted, New: redacted},
+				{Path: ".data.token", New: redacted},
+			},
+		},
+	}, diff.Changed)
+
+	for _, object := range diff.Changed {
+		for _, change := range object.Changes {
+			assert.NotContains(t, change.Old+change.New, "bmV3")
+		}
+	}
+}
+
+func Test_readReleases(t *testing.T) {
+	newRelease := func(manifest, version string) *release.Release {
+		return &release.Release{
+			Name:      "test",
+			Namespace: "test-ns",
+			Manifest:  manifest,
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "test", Version: version}},
+		}
+	}
+	var logs []string
+	for _, rel := range []*release.Release{newRelease(deployedManifest, "1.0.0"), newRelease(upgradedManifest, "1.1.0")} {
+		data, err := json.Marshal(rel)
+		require.NoError(t, err)
+		logs = append(logs, "helm upgrade --dry-run=server --output=json test", string(data))
+	}
+
+	releases, err := readReleases(strings.NewReader(strings.Join(logs, "\n")))
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+
+	output, err := diffReleases(releases)
+	require.NoError(t, err)
+	require.Len(t, output.Releases, 1)
+	assert.Equal(t, "test", output.Releases[0].ReleaseName)
+	assert.Equal(t, "test-ns", output.Releases[0].Namespace)
+	assert.Equal(t, "1.0.0", output.Releases[0].FromVersion)
+	assert.Equal(t, "1.1.0", output.Releases[0].ToVersion)
+	assert.Len(t, output.Releases[0].Changed, 2)
+
+	_, err = diffReleases(releases[:1])
+	assert.Error(t, err)
+}
+
+func Test_dryRunFilter(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq is not installed")
+	}
+	filter := filepath.Join(t.TempDir(), dryRunFilterFile)
+	require.NoError(t, os.WriteFile(filter, []byte(dryRunFilter), 0600))
+
+	var logs []string
+	for _, manifest := range []string{deployedManifest, upgradedManifest} {
+		data, err := json.Marshal(&release.Release{
+			Name:      "test",
+			Namespace: "test-ns",
+			Manifest:  manifest,
+			Config:    map[string]interface{}{"password": "hunter2"},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{Name: "test", Version: "1.0.0"},
+				Values:   map[string]interface{}{"password": "hunter2"},
+			},
+			Hooks: []*release.Hook{{Name: "test-hook", Manifest: "password: hunter2"}},
+		})
+		require.NoError(t, err)
+
+		cmd := exec.Command("jq", "-c", "-f", filter)
+		cmd.Stdin = strings.NewReader(string(data))
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		logs = append(logs, string(output))
+	}
+
+	joined := strings.Join(logs, "")
+	for _, secret := range []string{"hunter2", "b2xk", "bmV3", "YWRtaW4=", "dG9rZW4="} {
+		assert.NotContains(t, joined, secret)
+	}
+
+	releases, err := readReleases(strings.NewReader(joined))
+	require.NoError(t, err)
+	output, err := diffReleases(releases)
+	require.NoError(t, err)
+	require.Len(t, output.Releases, 1)
+	assert.Equal(t, "1.0.0", output.Releases[0].ToVersion)
+	assert.Contains(t, output.Releases[0].Changed, types.ObjectDiff{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  "test-ns",
+		Name:       "test",
+		Changes:    []types.FieldChange{{Path: ".data.token", New: redacted}},
+	}, "added keys of secrets are still shown, without their values")
+}
@@ -22,6 +22,7 @@
 	"time"
 	"unicode/utf8"
 
+	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	types2 "github.com/rancher/rancher/pkg/api/steve/catalog/types"
 	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
@@ -228,6 +229,29 @@ func (s *Operations) Upgrade(ctx context.Context, user user.Info, namespace, nam
 	return s.createOperation(ctx, user, status, cmds, imageOverride)
 }
 
+// DryRun gets the commands to preview the upgrade using the given namespace, name and options and gets the user using the isApp flag as false.
+// Returns a catalog.Operation that represents the helm operation to be created, whose changes are returned by Diff once it completes
+func (s *Operations) DryRun(ctx context.Context, user user.Info, namespace, name string, options io.Reader, imageOverride string) (*catalog.Operation, error) {
+	status, cmds, err := s.getDryRunCommand(namespace, name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.AutomaticCPTolerations {
+		status.Tolerations, err = s.AddCpTaintsToTolerations(status.Tolerations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add tolerations for CP nodes: %w", err)
+		}
+	}
+
+	user, err = s.getUser(user, namespace, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createOperation(ctx, user, status, cmds, imageOverride)
+}
+
 // Install gets the install commands using the given namespace, name and options and gets the user using the isApp flag as false.
 // Returns a catalog.Operation that represents the helm operation to be created
 func (s *Operations) Install(ctx context.Context, user user.Info, namespace, name string, options io.Reader, imageOverride string) (*catalog.Operation, error) {
@@ -300,31 +324,45 @@ func (s *Operations) proxyLogRequest(rw http.ResponseWriter, req *http.Request,
 // Log receives a response writer, a http request, the namespace and name of an operation.
 // Gets the pod of the operation and proxies the request to get logs of said pod
 func (s *Operations) Log(rw http.ResponseWriter, req *http.Request, namespace, name string) error {
-	op, err := s.ops.Get(namespace, name, metav1.GetOptions{})
+	op, pod, err := s.getOperationPod(namespace, name)
 	if err != nil {
 		return err
 	}
+	if op.Status.Action == dryRunAction {
+		return apierror.NewAPIError(validation.ActionNotAvailable, "the logs of dry run operations are not available, use the diff link")
+	}
 
-	pod, err := s.pods.Get(op.Status.PodNamespace, op.Status.PodName, metav1.GetOptions{})
+	client, err := s.cg.AdminK8sInterface()
 	if err != nil {
 		return err
 	}
 
+	return s.proxyLogRequest(rw, req, pod, client)
+}
+
+// getOperationPod returns the operation with the given namespace and name along with its pod.
+// Returns validation.NotFound if the pod doesn't belong to the operation
+func (s *Operations) getOperationPod(namespace, name string) (*catalog.Operation, *v1.Pod, error) {
+	op, err := s.ops.Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pod, err := s.pods.Get(op.Status.PodNamespace, op.Status.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// check if the pod and op have objects depended by them and that they aren't the same
 	if len(pod.OwnerReferences) == 0 || len(op.OwnerReferences) == 0 || pod.OwnerReferences[0].UID != op.OwnerReferences[0].UID {
-		return validation.NotFound
+		return nil, nil, validation.NotFound
 	}
 
 	if pod.Labels[podimpersonation.TokenLabel] != op.Status.Token {
-		return validation.NotFound
-	}
-
-	client, err := s.cg.AdminK8sInterface()
-	if err != nil {
-		return err
+		return nil, nil, validation.NotFound
 	}
 
-	return s.proxyLogRequest(rw, req, pod, client)
+	return op, pod, nil
 }
 
 // getSpec receives the namespace and name of either an app or a repo according to the value of the isApp flag.
@@ -477,6 +515,41 @@ func (s *Operations) getUpgradeCommand(repoNamespace, repoName string, body io.R
 	return status, commands, nil
 }
 
+// getDryRunCommand receives the repository namespace and name and body of the request.
+// Returns the status of the operation that will be created and a list of Command to preview the upgrade of the charts received in the request.
+// For each chart, the deployed release is printed before the upgraded release is rendered against the cluster without being applied,
+// both as JSON, so that Diff can compare their manifests
+func (s *Operations) getDryRunCommand(repoNamespace, repoName string, body io.Reader) (catalog.OperationStatus, Commands, error) {
+	status, upgrades, err := s.getUpgradeCommand(repoNamespace, repoName, body)
+	if err != nil {
+		return status, nil, err
+	}
+	status.Action = dryRunAction
+
+	var commands Commands
+	for _, cmd := range upgrades {
+		commands = append(commands, Command{
+			Operation: "status",
+			ArgObjects: []interface{}{map[string]interface{}{
+				"output": "json",
+			}},
+			ReleaseName:      cmd.ReleaseName,
+			ReleaseNamespace: status.Namespace,
+		})
+
+		// The upgrade must not install a release that isn't deployed yet, there would be nothing to compare it to
+		cmd.ArgObjects = append(cmd.ArgObjects, map[string]interface{}{
+			"install":   "false",
+			"dryRun":    "server",
+			"output":    "json",
+			"namespace": status.Namespace,
+		})
+		commands = append(commands, cmd)
+	}
+
+	return status, commands, nil
+}
+
 // Command represents a command that will be run inside a helm operation
 type Command struct {
 	Operation        string        // type of operation, eg upgrade, install, uninstall
@@ -745,6 +818,11 @@ func (s *Operations) getChartCommand(namespace, name, chartName, chartVersion st
 		return Command{}, err
 	}
 
+	// Verify the chart as it was downloaded, before the annotations are injected.
+	if err := s.contentManager.Verify(namespace, name, chartName, chartVersion, chartData); err != nil {
+		return Command{}, err
+	}
+
 	chartData, err = injectAnnotation(chartData, annotations)
 	if err != nil {
 		return Command{}, err
@@ -843,7 +921,7 @@ func namespace(ns string) string {
 // Uses the Operations.Impersonator and Operations.ops to do it.
 // Returns the created catalog.Operation struct
 func (s *Operations) createOperation(ctx context.Context, user user.Info, status catalog.OperationStatus, cmds Commands, imageOverride string) (*catalog.Operation, error) {
-	if status.Action != "uninstall" {
+	if status.Action != "uninstall" && status.Action != dryRunAction {
 		_, err := s.createNamespace(ctx, status.Namespace, status.ProjectID)
 		if err != nil {
 			return nil, err
@@ -854,6 +932,10 @@ func (s *Operations) createOperation(ctx context.Context, user user.Info, status
 	if err != nil {
 		return nil, err
 	}
+	if status.Action == dryRunAction {
+		secretData[dryRunScriptFile] = []byte(dryRunScript)
+		secretData[dryRunFilterFile] = []byte(dryRunFilter)
+	}
 
 	var kustomize bool
 	for _, cmd := range cmds {
@@ -864,6 +946,9 @@ func (s *Operations) createOperation(ctx context.Context, user user.Info, status
 		break
 	}
 	pod, podOptions := s.createPod(secretData, kustomize, imageOverride, status.Tolerations)
+	if status.Action == dryRunAction {
+		pod.Spec.Containers[0].Command = []string{"/bin/bash", dryRunScriptFile}
+	}
 	pod, err = s.Impersonator.CreatePod(ctx, user, pod, podOptions)
 	if err != nil {
 		return nil, err
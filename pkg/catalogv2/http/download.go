@@ -2,6 +2,7 @@
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,12 +16,16 @@
 
 	"sigs.k8s.io/yaml"
 
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/repo"
 	corev1 "k8s.io/api/core/v1"
 )
 
+// maxProvenanceSize limits the size of the provenance files that are downloaded.
+const maxProvenanceSize = 1 << 20
+
 func Icon(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, chart *repo.ChartVersion) (io.ReadCloser, string, error) {
 	if len(chart.URLs) == 0 {
 		return nil, "", fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
@@ -78,6 +83,96 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 	}
 	defer client.CloseIdleConnections()
 
+	u, err := chartURL(repoURL, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+}
+
+// Provenance downloads the Helm provenance file of the chart, which is published next to the chart archive.
+// It returns the provenance file and the file name of the chart archive.
+func Provenance(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, chart *repo.ChartVersion) ([]byte, string, error) {
+	if len(chart.URLs) == 0 {
+		return nil, "", fmt.Errorf("failed to find chartName %s version %s: %w", chart.Name, chart.Version, validation.NotFound)
+	}
+
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.CloseIdleConnections()
+
+	return provenance(client, repoURL, chart)
+}
+
+// VerifyIndex verifies the provenance files of the chart versions of the index whose verification
+// result isn't recorded or cached yet, and records the results in the index and the cache.
+func VerifyIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool, index *repo.IndexFile, verifier *verify.Verifier, results *verify.Results) error {
+	client, err := HelmClient(secret, caBundle, insecureSkipTLSVerify, disableSameOriginCheck, repoURL)
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+
+	for _, versions := range index.Entries {
+		for _, chart := range versions {
+			if verify.Annotated(chart) {
+				continue
+			}
+			if chart.Digest == "" {
+				verify.Annotate(chart, errors.New("index has no digest for the chart archive"))
+				continue
+			}
+			if results.Lookup(chart) {
+				continue
+			}
+			prov, fileName, err := provenance(client, repoURL, chart)
+			if err == nil {
+				err = verifier.Provenance(prov, fileName, chart.Digest)
+			}
+			results.Record(chart, err)
+		}
+	}
+
+	return nil
+}
+
+func provenance(client *http.Client, repoURL string, chart *repo.ChartVersion) ([]byte, string, error) {
+	if len(chart.URLs) == 0 {
+		return nil, "", errors.New("chart has no urls specified")
+	}
+	u, err := chartURL(repoURL, chart)
+	if err != nil {
+		return nil, "", err
+	}
+	fileName := path.Base(u.Path)
+	u.Path += ".prov"
+	u.RawPath = ""
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download provenance file of %s: %s", fileName, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProvenanceSize))
+	return data, fileName, err
+}
+
+// chartURL returns the URL of the chart archive, resolving URLs relative to the repository URL.
+func chartURL(repoURL string, chart *repo.ChartVersion) (*url.URL, error) {
 	u, err := url.Parse(chart.URLs[0])
 	if err != nil {
 		return nil, err
@@ -96,14 +191,7 @@ func Chart(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipT
 		u.RawQuery = base.RawQuery
 	}
 
-	resp, err := client.Get(u.String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	data, err := ioutil.ReadAll(resp.Body)
-	return ioutil.NopCloser(bytes.NewBuffer(data)), err
+	return u, nil
 }
 
 func DownloadIndex(secret *corev1.Secret, repoURL string, caBundle []byte, insecureSkipTLSVerify bool, disableSameOriginCheck bool) (*repo.IndexFile, error) {
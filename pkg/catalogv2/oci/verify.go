@@ -0,0 +1,85 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// maxManifestSize limits the size of the chart manifests that are fetched for verification.
+const maxManifestSize = 4 * 1024 * 1024 // 4 MiB
+
+// VerifyChart verifies the cosign signatures of the OCI artifact of the chart version.
+// It returns the digest of the chart layer of the verified artifact, which the chart
+// archive that is installed must match.
+func VerifyChart(credentialSecret *corev1.Secret, chart *repo.ChartVersion, clusterRepoSpec v1.RepoSpec, verifier *verify.Verifier) (digest.Digest, error) {
+	if len(chart.URLs) == 0 {
+		return "", errors.New("chart has no urls specified")
+	}
+	chartURL := chart.URLs[0]
+
+	ociClient, err := NewClient(chartURL, clusterRepoSpec, credentialSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to create an OCI client for url %s: %w", chartURL, err)
+	}
+	orasRepository, err := ociClient.GetOrasRepository()
+	if err != nil {
+		return "", fmt.Errorf("failed to create an OCI repository for url %s: %w", chartURL, err)
+	}
+
+	return verifyChart(context.Background(), orasRepository, ociClient.tag, verifier)
+}
+
+// VerifyIndex verifies the chart versions of the index whose verification result isn't
+// recorded or cached yet, and records the results in the index and the cache.
+func VerifyIndex(credentialSecret *corev1.Secret, index *repo.IndexFile, clusterRepoSpec v1.RepoSpec, verifier *verify.Verifier, results *verify.Results) {
+	for _, versions := range index.Entries {
+		for _, chart := range versions {
+			if verify.Annotated(chart) || results.Lookup(chart) {
+				continue
+			}
+			_, err := VerifyChart(credentialSecret, chart, clusterRepoSpec, verifier)
+			results.Record(chart, err)
+		}
+	}
+}
+
+func verifyChart(ctx context.Context, target oras.ReadOnlyTarget, tag string, verifier *verify.Verifier) (digest.Digest, error) {
+	desc, err := target.Resolve(ctx, tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve tag %s: %w", tag, err)
+	}
+	if err := verifier.Cosign(ctx, target, desc.Digest); err != nil {
+		return "", err
+	}
+
+	if desc.Size > maxManifestSize {
+		return "", fmt.Errorf("the manifest of tag %s has size more than %d which is not supported", tag, maxManifestSize)
+	}
+	manifestBlob, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch the manifest blob of tag %s: %w", tag, err)
+	}
+	var manifest ocispecv1.Manifest
+	if err := json.Unmarshal(manifestBlob, &manifest); err != nil {
+		return "", fmt.Errorf("unable to unmarshal manifest blob of tag %s: %w", tag, err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == registry.ChartLayerMediaType {
+			return layer.Digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find the chart layer of tag %s", tag)
+}
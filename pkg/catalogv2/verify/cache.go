@@ -0,0 +1,126 @@
+package verify
+
+import (
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// failureBackoff is the time a verification failure is cached for initially. It doubles with every failure of the
+	// same chart version up to maxFailureBackoff.
+	failureBackoff    = 10 * time.Minute
+	maxFailureBackoff = 24 * time.Hour
+)
+
+// Cache caches the verification results of the chart versions of ClusterRepos, so that the chart versions of an index
+// are not verified again every time the index is refreshed.
+type Cache struct {
+	lock         sync.Mutex
+	repositories map[string]*Results
+	newBackoff   func() *flowcontrol.Backoff
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		repositories: map[string]*Results{},
+		newBackoff: func() *flowcontrol.Backoff {
+			return flowcontrol.NewBackOff(failureBackoff, maxFailureBackoff)
+		},
+	}
+}
+
+// Repository returns the cached verification results of the ClusterRepo. The results of a previous generation are
+// dropped, as the verification policy might have changed since. A nil Cache caches nothing.
+func (c *Cache) Repository(name string, generation int64) *Results {
+	if c == nil {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	results := c.repositories[name]
+	if results == nil || results.generation != generation {
+		results = &Results{
+			generation: generation,
+			results:    map[resultKey]error{},
+			backoff:    c.newBackoff(),
+		}
+		c.repositories[name] = results
+	}
+	return results
+}
+
+// Results are the cached verification results of the chart versions of a ClusterRepo, keyed by their version and
+// digest. Failures are cached as well, and the chart version is verified again once the backoff of its failure passed.
+type Results struct {
+	lock       sync.Mutex
+	generation int64
+	results    map[resultKey]error
+	backoff    *flowcontrol.Backoff
+}
+
+type resultKey struct {
+	name    string
+	version string
+	digest  string
+	url     string
+}
+
+func (k resultKey) String() string {
+	return k.name + "/" + k.version + "/" + k.digest + "/" + k.url
+}
+
+func newResultKey(chartVersion *repo.ChartVersion) resultKey {
+	key := resultKey{
+		name:    chartVersion.Name,
+		version: chartVersion.Version,
+		digest:  chartVersion.Digest,
+	}
+	if len(chartVersion.URLs) > 0 {
+		key.url = chartVersion.URLs[0]
+	}
+	return key
+}
+
+// Lookup records the cached verification result of the chart version in its annotations. It returns false if the
+// chart version must be verified, because it has no cached result or the backoff of its failure passed.
+func (r *Results) Lookup(chartVersion *repo.ChartVersion) bool {
+	if r == nil {
+		return false
+	}
+	key := newResultKey(chartVersion)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err, ok := r.results[key]
+	if !ok || (err != nil && !r.backoff.IsInBackOffSinceUpdate(key.String(), r.backoff.Clock.Now())) {
+		return false
+	}
+	Annotate(chartVersion, err)
+	return true
+}
+
+// Record records the verification result of the chart version in its annotations and caches it.
+func (r *Results) Record(chartVersion *repo.ChartVersion, err error) {
+	Annotate(chartVersion, err)
+	if r == nil {
+		return
+	}
+	key := newResultKey(chartVersion)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results[key] = err
+	if err != nil {
+		r.backoff.Next(key.String(), r.backoff.Clock.Now())
+	} else {
+		r.backoff.DeleteEntry(key.String())
+	}
+}
@@ -0,0 +1,222 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+const (
+	signatureAnnotation    = "dev.cosignproject.cosign/signature"
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	dsseMediaType          = "application/vnd.dsse.envelope.v1+json"
+	inTotoPayloadType      = "application/vnd.in-toto+json"
+
+	// maxCosignBlobSize limits the size of the cosign manifests and layers that are fetched.
+	maxCosignBlobSize = 1 << 20
+)
+
+// simpleSigningPayload is the payload signed by cosign signatures.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// envelope is a DSSE envelope holding a cosign attestation.
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// statement is an in-toto statement.
+type statement struct {
+	Subject []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// Cosign verifies that the manifest is signed by a trusted public key. If the policy requires
+// attestations, it also verifies that an attestation for the manifest is signed by a trusted key.
+// Signatures and attestations are looked up with the tag scheme of cosign.
+func (v *Verifier) Cosign(ctx context.Context, target oras.ReadOnlyTarget, manifest digest.Digest) error {
+	if len(v.publicKeys) == 0 {
+		return errors.New("no cosign public keys configured")
+	}
+
+	layers, err := fetchLayers(ctx, target, cosignTag(manifest, "sig"))
+	if err != nil {
+		return fmt.Errorf("failed to fetch cosign signatures: %w", err)
+	}
+	if !v.signed(layers, manifest) {
+		return fmt.Errorf("no cosign signature of %s is signed by a trusted key", manifest)
+	}
+
+	if !v.requireAttestation {
+		return nil
+	}
+	layers, err = fetchLayers(ctx, target, cosignTag(manifest, "att"))
+	if err != nil {
+		return fmt.Errorf("failed to fetch cosign attestations: %w", err)
+	}
+	if !v.attested(layers, manifest) {
+		return fmt.Errorf("no cosign attestation of %s is signed by a trusted key", manifest)
+	}
+
+	return nil
+}
+
+func (v *Verifier) signed(layers []layer, manifest digest.Digest) bool {
+	for _, l := range layers {
+		if l.desc.MediaType != simpleSigningMediaType {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(l.desc.Annotations[signatureAnnotation])
+		if err != nil || !v.verifySignature(l.data, sig) {
+			continue
+		}
+		var payload simpleSigningPayload
+		if err := json.Unmarshal(l.data, &payload); err != nil {
+			continue
+		}
+		if payload.Critical.Image.DockerManifestDigest == manifest.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *Verifier) attested(layers []layer, manifest digest.Digest) bool {
+	for _, l := range layers {
+		if l.desc.MediaType != dsseMediaType {
+			continue
+		}
+		var env envelope
+		if err := json.Unmarshal(l.data, &env); err != nil || env.PayloadType != inTotoPayloadType {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			continue
+		}
+
+		signed := false
+		for _, s := range env.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(s.Sig)
+			if err == nil && v.verifySignature(pae(env.PayloadType, payload), sig) {
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			continue
+		}
+
+		var st statement
+		if err := json.Unmarshal(payload, &st); err != nil {
+			continue
+		}
+		for _, subject := range st.Subject {
+			if subject.Digest[manifest.Algorithm().String()] == manifest.Encoded() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// verifySignature verifies the signature of the message with any of the trusted public keys.
+func (v *Verifier) verifySignature(message, sig []byte) bool {
+	hash := sha256.Sum256(message)
+	for _, publicKey := range v.publicKeys {
+		switch key := publicKey.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, message, sig) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pae is the pre-authentication encoding of DSSE, which is what the signatures of an envelope sign.
+func pae(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// cosignTag returns the tag cosign stores the signatures or attestations of a manifest under.
+func cosignTag(manifest digest.Digest, suffix string) string {
+	return fmt.Sprintf("%s-%s.%s", manifest.Algorithm(), manifest.Encoded(), suffix)
+}
+
+type layer struct {
+	desc ocispecv1.Descriptor
+	data []byte
+}
+
+// fetchLayers fetches the layers of the manifest with the given tag.
+func fetchLayers(ctx context.Context, target oras.ReadOnlyTarget, tag string) ([]layer, error) {
+	desc, err := target.Resolve(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	manifestBlob, err := fetch(ctx, target, desc)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ocispecv1.Manifest
+	if err := json.Unmarshal(manifestBlob, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest %s: %w", tag, err)
+	}
+
+	layers := make([]layer, 0, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		data, err := fetch(ctx, target, desc)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{desc: desc, data: data})
+	}
+
+	return layers, nil
+}
+
+func fetch(ctx context.Context, target oras.ReadOnlyTarget, desc ocispecv1.Descriptor) ([]byte, error) {
+	if desc.Size > maxCosignBlobSize {
+		return nil, fmt.Errorf("%s has size more than %d which is not supported", desc.Digest, maxCosignBlobSize)
+	}
+
+	return content.FetchAll(ctx, target, desc)
+}
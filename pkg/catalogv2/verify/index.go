@@ -0,0 +1,114 @@
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const (
+	// StatusAnnotation is the chart version annotation in the index holding the verification result.
+	StatusAnnotation = "catalog.cattle.io/verification"
+	// MessageAnnotation is the chart version annotation in the index describing a verification failure.
+	MessageAnnotation = "catalog.cattle.io/verification-message"
+
+	StatusVerified = "Verified"
+	StatusFailed   = "Failed"
+
+	// maxStatusFailures is the number of failures described in the status message.
+	maxStatusFailures = 3
+)
+
+// Annotate records the verification result of the chart version in its annotations.
+func Annotate(chartVersion *repo.ChartVersion, err error) {
+	if chartVersion.Metadata == nil {
+		chartVersion.Metadata = &chart.Metadata{}
+	}
+	if chartVersion.Annotations == nil {
+		chartVersion.Annotations = map[string]string{}
+	}
+
+	if err != nil {
+		chartVersion.Annotations[StatusAnnotation] = StatusFailed
+		chartVersion.Annotations[MessageAnnotation] = err.Error()
+		return
+	}
+	chartVersion.Annotations[StatusAnnotation] = StatusVerified
+	delete(chartVersion.Annotations, MessageAnnotation)
+}
+
+// Annotated returns whether the verification result of the chart version is recorded.
+func Annotated(chartVersion *repo.ChartVersion) bool {
+	return chartVersion.Metadata != nil && chartVersion.Annotations[StatusAnnotation] != ""
+}
+
+// Reset removes the verification results from the chart versions of the index.
+func Reset(index *repo.IndexFile) {
+	for _, versions := range index.Entries {
+		for _, chartVersion := range versions {
+			if chartVersion.Metadata == nil {
+				continue
+			}
+			delete(chartVersion.Annotations, StatusAnnotation)
+			delete(chartVersion.Annotations, MessageAnnotation)
+		}
+	}
+}
+
+// Reuse copies the successful verification results of the previous index to the chart versions
+// of the index that have the same digest and URLs, so that they are not verified again.
+// Failures are not reused, as they might be transient.
+func Reuse(index, previous *repo.IndexFile) {
+	if previous == nil {
+		return
+	}
+	for name, versions := range index.Entries {
+		for _, chartVersion := range versions {
+			if chartVersion.Digest == "" || len(chartVersion.URLs) == 0 {
+				continue
+			}
+			prev, err := previous.Get(name, chartVersion.Version)
+			if err != nil || prev.Digest != chartVersion.Digest || len(prev.URLs) == 0 || prev.URLs[0] != chartVersion.URLs[0] {
+				continue
+			}
+			if prev.Metadata != nil && prev.Annotations[StatusAnnotation] == StatusVerified {
+				Annotate(chartVersion, nil)
+			}
+		}
+	}
+}
+
+// Summarize summarizes the verification results of the chart versions of the index.
+func Summarize(index *repo.IndexFile) *v1.VerificationStatus {
+	status := &v1.VerificationStatus{}
+	var failures []string
+	for _, versions := range index.Entries {
+		for _, chartVersion := range versions {
+			if chartVersion.Metadata == nil {
+				continue
+			}
+			switch chartVersion.Annotations[StatusAnnotation] {
+			case StatusVerified:
+				status.Verified++
+			case StatusFailed:
+				status.Failed++
+				failures = append(failures, fmt.Sprintf("%s-%s: %s", chartVersion.Name, chartVersion.Version, chartVersion.Annotations[MessageAnnotation]))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		// Entries are iterated in random order.
+		sort.Strings(failures)
+		if len(failures) > maxStatusFailures {
+			failures = append(failures[:maxStatusFailures], fmt.Sprintf("and %d more", len(failures)-maxStatusFailures))
+		}
+		status.Message = fmt.Sprintf("%d chart versions failed verification: %s", status.Failed, strings.Join(failures, "; "))
+	}
+
+	return status
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"           //nolint
+	"golang.org/x/crypto/openpgp/clearsign" //nolint
+	"helm.sh/helm/v3/pkg/provenance"
+	"sigs.k8s.io/yaml"
+)
+
+// Provenance verifies that the Helm provenance file is signed by a key of the keyring and
+// that it holds the given SHA256 digest of the named chart archive.
+func (v *Verifier) Provenance(prov []byte, fileName, digest string) error {
+	if len(v.keyring) == 0 {
+		return errors.New("no provenance keyring configured")
+	}
+
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return errors.New("provenance file has no signature block")
+	}
+	if _, err := openpgp.CheckDetachedSignature(v.keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body); err != nil {
+		return fmt.Errorf("provenance file is not signed by a trusted key: %w", err)
+	}
+
+	// The message block holds the chart metadata and the checksums, separated by a YAML document end marker.
+	parts := bytes.Split(block.Plaintext, []byte("\n...\n"))
+	if len(parts) < 2 {
+		return errors.New("provenance file has no checksums")
+	}
+	sums := &provenance.SumCollection{}
+	if err := yaml.Unmarshal(parts[1], sums); err != nil {
+		return fmt.Errorf("failed to parse provenance checksums: %w", err)
+	}
+
+	sum, ok := sums.Files[fileName]
+	if !ok {
+		return fmt.Errorf("provenance file has no checksum for %s", fileName)
+	}
+	if want := "sha256:" + strings.TrimPrefix(digest, "sha256:"); sum != want {
+		return fmt.Errorf("checksum of %s does not match the provenance file: %s != %s", fileName, want, sum)
+	}
+
+	return nil
+}
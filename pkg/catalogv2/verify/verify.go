@@ -0,0 +1,141 @@
+// Package verify verifies Helm charts against the verification policy of their ClusterRepo.
+// Charts of HTTP Helm repositories are verified against their Helm provenance files and
+// charts of OCI Helm repositories against their cosign signatures and attestations.
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	namespaces "github.com/rancher/rancher/pkg/namespace"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp" //nolint
+)
+
+// KeyringKey is the key of the keyring in the provenance keyring secret.
+const KeyringKey = "keyring"
+
+// ErrGitUnsupported is returned when verifying charts of Git Helm repositories.
+var ErrGitUnsupported = errors.New("chart verification is not supported for Git Helm repositories")
+
+// Verifier verifies charts with the keys referenced by a verification policy.
+type Verifier struct {
+	keyring            openpgp.EntityList
+	publicKeys         []crypto.PublicKey
+	requireAttestation bool
+}
+
+// New returns a Verifier with the keys referenced by the verification policy.
+func New(secrets corecontrollers.SecretCache, policy *v1.VerificationPolicy) (*Verifier, error) {
+	v := &Verifier{}
+	if policy == nil {
+		return v, nil
+	}
+
+	if policy.Provenance != nil && policy.Provenance.KeyringSecret != nil {
+		data, err := secretData(secrets, policy.Provenance.KeyringSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get provenance keyring: %w", err)
+		}
+		v.keyring, err = parseKeyring(data[KeyringKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provenance keyring: %w", err)
+		}
+	}
+
+	if policy.Cosign != nil {
+		v.requireAttestation = policy.Cosign.RequireAttestation
+		if policy.Cosign.PublicKeysSecret != nil {
+			data, err := secretData(secrets, policy.Cosign.PublicKeysSecret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get cosign public keys: %w", err)
+			}
+			v.publicKeys, err = parsePublicKeys(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cosign public keys: %w", err)
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// Enforce returns the verification error of a chart if the policy enforces verification.
+// Otherwise, the error is only logged.
+func Enforce(policy *v1.VerificationPolicy, chart string, err error) error {
+	if err == nil || policy == nil {
+		return nil
+	}
+	if policy.Mode == v1.VerificationModeWarn {
+		logrus.Warnf("[verify] Chart %s failed verification: %v", chart, err)
+		return nil
+	}
+
+	return fmt.Errorf("chart %s failed verification: %w", chart, err)
+}
+
+func secretData(secrets corecontrollers.SecretCache, ref *v1.SecretReference) (map[string][]byte, error) {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = namespaces.System
+	}
+	secret, err := secrets.Get(namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Data, nil
+}
+
+// parseKeyring parses an ASCII armored or binary PGP keyring.
+func parseKeyring(data []byte) (openpgp.EntityList, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("key %q is empty", KeyringKey)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// parsePublicKeys parses the PEM encoded public keys of all keys of the secret data.
+func parsePublicKeys(data map[string][]byte) ([]crypto.PublicKey, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var publicKeys []crypto.PublicKey
+	for _, key := range keys {
+		rest := data[key]
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+	if len(publicKeys) == 0 {
+		return nil, errors.New("no PEM encoded public keys found")
+	}
+
+	return publicKeys, nil
+}
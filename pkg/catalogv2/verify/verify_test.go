@@ -0,0 +1,417 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	v1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/openpgp"           //nolint
+	"golang.org/x/crypto/openpgp/armor"     //nolint
+	"golang.org/x/crypto/openpgp/clearsign" //nolint
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	clocktesting "k8s.io/utils/clock/testing"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func newEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+	return entity
+}
+
+func armoredKeyring(t *testing.T, entity *openpgp.Entity) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func signProvenance(t *testing.T, entity *openpgp.Entity, fileName, digest string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, entity.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(w, "apiVersion: v2\nname: foo\nversion: 1.0.0\n\n...\nfiles:\n  %s: sha256:%s\n", fileName, digest)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestNew(t *testing.T) {
+	trusted := newEntity(t, "trusted")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	policy := &v1.VerificationPolicy{
+		Provenance: &v1.ProvenanceVerification{KeyringSecret: &v1.SecretReference{Name: "keyring"}},
+		Cosign:     &v1.CosignVerification{PublicKeysSecret: &v1.SecretReference{Name: "keys", Namespace: "fleet-default"}, RequireAttestation: true},
+	}
+
+	t.Run("keys are loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		secrets.EXPECT().Get("cattle-system", "keyring").Return(&corev1.Secret{Data: map[string][]byte{KeyringKey: armoredKeyring(t, trusted)}}, nil)
+		secrets.EXPECT().Get("fleet-default", "keys").Return(&corev1.Secret{Data: map[string][]byte{"a.pub": publicKeyPEM, "b.pub": publicKeyPEM}}, nil)
+
+		verifier, err := New(secrets, policy)
+		require.NoError(t, err)
+		assert.Len(t, verifier.keyring, 1)
+		assert.Len(t, verifier.publicKeys, 2)
+		assert.True(t, verifier.requireAttestation)
+	})
+
+	t.Run("no public keys", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		secrets.EXPECT().Get("cattle-system", "keyring").Return(&corev1.Secret{Data: map[string][]byte{KeyringKey: armoredKeyring(t, trusted)}}, nil)
+		secrets.EXPECT().Get("fleet-default", "keys").Return(&corev1.Secret{Data: map[string][]byte{"a.pub": []byte("not a key")}}, nil)
+
+		_, err := New(secrets, policy)
+		assert.ErrorContains(t, err, "failed to parse cosign public keys: no PEM encoded public keys found")
+	})
+
+	t.Run("empty keyring", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		secrets.EXPECT().Get("cattle-system", "keyring").Return(&corev1.Secret{}, nil)
+
+		_, err := New(secrets, policy)
+		assert.ErrorContains(t, err, `failed to parse provenance keyring: key "keyring" is empty`)
+	})
+}
+
+func TestEnforce(t *testing.T) {
+	err := fmt.Errorf("bad signature")
+	assert.NoError(t, Enforce(nil, "foo-1.0.0", err))
+	assert.NoError(t, Enforce(&v1.VerificationPolicy{}, "foo-1.0.0", nil))
+	assert.NoError(t, Enforce(&v1.VerificationPolicy{Mode: v1.VerificationModeWarn}, "foo-1.0.0", err))
+	assert.EqualError(t, Enforce(&v1.VerificationPolicy{}, "foo-1.0.0", err), "chart foo-1.0.0 failed verification: bad signature")
+}
+
+func TestProvenance(t *testing.T) {
+	trusted := newEntity(t, "trusted")
+	untrusted := newEntity(t, "untrusted")
+	keyring, err := parseKeyring(armoredKeyring(t, trusted))
+	require.NoError(t, err)
+	verifier := &Verifier{keyring: keyring}
+
+	const digest = "4a5b6c"
+	tests := map[string]struct {
+		prov     []byte
+		fileName string
+		digest   string
+		wantErr  string
+	}{
+		"verified": {
+			prov:     signProvenance(t, trusted, "foo-1.0.0.tgz", digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   digest,
+		},
+		"verified with prefixed digest": {
+			prov:     signProvenance(t, trusted, "foo-1.0.0.tgz", digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   "sha256:" + digest,
+		},
+		"digest mismatch": {
+			prov:     signProvenance(t, trusted, "foo-1.0.0.tgz", digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   "ffffff",
+			wantErr:  "checksum of foo-1.0.0.tgz does not match the provenance file",
+		},
+		"other file": {
+			prov:     signProvenance(t, trusted, "bar-1.0.0.tgz", digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   digest,
+			wantErr:  "provenance file has no checksum for foo-1.0.0.tgz",
+		},
+		"untrusted key": {
+			prov:     signProvenance(t, untrusted, "foo-1.0.0.tgz", digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   digest,
+			wantErr:  "provenance file is not signed by a trusted key",
+		},
+		"not signed": {
+			prov:     []byte("files:\n  foo-1.0.0.tgz: sha256:" + digest),
+			fileName: "foo-1.0.0.tgz",
+			digest:   digest,
+			wantErr:  "provenance file has no signature block",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := verifier.Provenance(test.prov, test.fileName, test.digest)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("no keyring", func(t *testing.T) {
+		err := (&Verifier{}).Provenance(signProvenance(t, trusted, "foo-1.0.0.tgz", digest), "foo-1.0.0.tgz", digest)
+		assert.EqualError(t, err, "no provenance keyring configured")
+	})
+}
+
+// pushManifest pushes a manifest with the given layers to the store and tags it.
+func pushManifest(t *testing.T, store *memory.Store, tag string, layers ...ocispecv1.Descriptor) ocispecv1.Descriptor {
+	t.Helper()
+	config := pushBlob(t, store, ocispecv1.MediaTypeEmptyJSON, []byte("{}"), nil)
+	manifest, err := json.Marshal(ocispecv1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecv1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    append([]ocispecv1.Descriptor{}, layers...),
+	})
+	require.NoError(t, err)
+	desc := pushBlob(t, store, ocispecv1.MediaTypeImageManifest, manifest, nil)
+	if tag != "" {
+		require.NoError(t, store.Tag(context.Background(), desc, tag))
+	}
+	return desc
+}
+
+func pushBlob(t *testing.T, store *memory.Store, mediaType string, data []byte, annotations map[string]string) ocispecv1.Descriptor {
+	t.Helper()
+	desc := ocispecv1.Descriptor{
+		MediaType:   mediaType,
+		Digest:      digest.FromBytes(data),
+		Size:        int64(len(data)),
+		Annotations: annotations,
+	}
+	exists, err := store.Exists(context.Background(), desc)
+	require.NoError(t, err)
+	if !exists {
+		require.NoError(t, store.Push(context.Background(), desc, bytes.NewReader(data)))
+	}
+	return desc
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, message []byte) string {
+	t.Helper()
+	hash := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func signature(t *testing.T, store *memory.Store, key *ecdsa.PrivateKey, manifest digest.Digest) ocispecv1.Descriptor {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example.com/charts/foo"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, manifest))
+	return pushBlob(t, store, simpleSigningMediaType, payload, map[string]string{signatureAnnotation: sign(t, key, payload)})
+}
+
+func attestation(t *testing.T, store *memory.Store, key *ecdsa.PrivateKey, manifest digest.Digest) ocispecv1.Descriptor {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2","subject":[{"name":"example.com/charts/foo","digest":{"sha256":%q}}],"predicate":{}}`, manifest.Encoded()))
+	env, err := json.Marshal(map[string]any{
+		"payloadType": inTotoPayloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures":  []map[string]string{{"sig": sign(t, key, pae(inTotoPayloadType, payload))}},
+	})
+	require.NoError(t, err)
+	return pushBlob(t, store, dsseMediaType, env, nil)
+}
+
+func TestCosign(t *testing.T) {
+	trusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		requireAttestation bool
+		setup              func(t *testing.T, store *memory.Store, manifest digest.Digest)
+		wantErr            string
+	}{
+		"signed": {
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, untrusted, manifest), signature(t, store, trusted, manifest))
+			},
+		},
+		"signed and attested": {
+			requireAttestation: true,
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, trusted, manifest))
+				pushManifest(t, store, cosignTag(manifest, "att"), attestation(t, store, trusted, manifest))
+			},
+		},
+		"not signed": {
+			setup:   func(t *testing.T, store *memory.Store, manifest digest.Digest) {},
+			wantErr: "failed to fetch cosign signatures",
+		},
+		"signed by untrusted key": {
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, untrusted, manifest))
+			},
+			wantErr: "is signed by a trusted key",
+		},
+		"signature for another manifest": {
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, trusted, digest.FromString("other")))
+			},
+			wantErr: "is signed by a trusted key",
+		},
+		"attestation missing": {
+			requireAttestation: true,
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, trusted, manifest))
+			},
+			wantErr: "failed to fetch cosign attestations",
+		},
+		"attestation by untrusted key": {
+			requireAttestation: true,
+			setup: func(t *testing.T, store *memory.Store, manifest digest.Digest) {
+				pushManifest(t, store, cosignTag(manifest, "sig"), signature(t, store, trusted, manifest))
+				pushManifest(t, store, cosignTag(manifest, "att"), attestation(t, store, untrusted, manifest))
+			},
+			wantErr: "no cosign attestation of",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := memory.New()
+			chartLayer := pushBlob(t, store, "application/vnd.cncf.helm.chart.content.v1.tar+gzip", []byte("chart"), nil)
+			manifest := pushManifest(t, store, "1.0.0", chartLayer)
+			test.setup(t, store, manifest.Digest)
+
+			verifier := &Verifier{publicKeys: []crypto.PublicKey{trusted.Public()}, requireAttestation: test.requireAttestation}
+			err := verifier.Cosign(context.Background(), store, manifest.Digest)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func chartVersion(name, version, digest string, annotations map[string]string) *repo.ChartVersion {
+	return &repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: name, Version: version, Annotations: annotations},
+		Digest:   digest,
+		URLs:     []string{name + "-" + version + ".tgz"},
+	}
+}
+
+func TestIndex(t *testing.T) {
+	verified := map[string]string{StatusAnnotation: StatusVerified}
+	failed := map[string]string{StatusAnnotation: StatusFailed, MessageAnnotation: "bad signature"}
+
+	previous := repo.NewIndexFile()
+	previous.Entries["foo"] = repo.ChartVersions{
+		chartVersion("foo", "1.0.0", "aaa", verified),
+		chartVersion("foo", "1.1.0", "bbb", failed),
+		chartVersion("foo", "1.2.0", "ccc", verified),
+	}
+
+	index := repo.NewIndexFile()
+	index.Entries["foo"] = repo.ChartVersions{
+		chartVersion("foo", "1.0.0", "aaa", nil),
+		chartVersion("foo", "1.1.0", "bbb", nil),
+		chartVersion("foo", "1.2.0", "changed", nil),
+	}
+
+	Reuse(index, previous)
+	assert.True(t, Annotated(index.Entries["foo"][0]))
+	assert.False(t, Annotated(index.Entries["foo"][1]), "failures are not reused")
+	assert.False(t, Annotated(index.Entries["foo"][2]), "results of other archives are not reused")
+
+	Annotate(index.Entries["foo"][1], fmt.Errorf("bad signature"))
+	Annotate(index.Entries["foo"][2], nil)
+	assert.Equal(t, &v1.VerificationStatus{
+		Verified: 2,
+		Failed:   1,
+		Message:  "1 chart versions failed verification: foo-1.1.0: bad signature",
+	}, Summarize(index))
+
+	Reset(index)
+	assert.Equal(t, &v1.VerificationStatus{}, Summarize(index))
+}
+
+func TestCache(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	cache := NewCache()
+	cache.newBackoff = func() *flowcontrol.Backoff {
+		return flowcontrol.NewFakeBackOff(failureBackoff, maxFailureBackoff, clock)
+	}
+
+	results := cache.Repository("repo", 1)
+	good := chartVersion("foo", "1.0.0", "aaa", nil)
+	bad := chartVersion("foo", "1.1.0", "bbb", nil)
+	assert.False(t, results.Lookup(good))
+	results.Record(good, nil)
+	results.Record(bad, fmt.Errorf("bad signature"))
+
+	// results are cached by version and digest
+	assert.True(t, results.Lookup(chartVersion("foo", "1.0.0", "aaa", nil)))
+	assert.False(t, results.Lookup(chartVersion("foo", "1.0.0", "changed", nil)))
+	assert.Same(t, results, cache.Repository("repo", 1))
+
+	// failures are cached until their backoff passed
+	refreshed := chartVersion("foo", "1.1.0", "bbb", nil)
+	assert.True(t, results.Lookup(refreshed))
+	assert.Equal(t, "bad signature", failed(refreshed))
+	clock.Step(failureBackoff)
+	assert.False(t, results.Lookup(refreshed))
+
+	// the backoff grows with every failure
+	results.Record(refreshed, fmt.Errorf("bad signature"))
+	clock.Step(failureBackoff)
+	assert.True(t, results.Lookup(refreshed))
+	clock.Step(failureBackoff)
+	assert.False(t, results.Lookup(refreshed))
+
+	// successes are cached indefinitely
+	clock.Step(maxFailureBackoff)
+	assert.True(t, results.Lookup(chartVersion("foo", "1.0.0", "aaa", nil)))
+
+	// results of previous generations are dropped
+	assert.False(t, cache.Repository("repo", 2).Lookup(chartVersion("foo", "1.0.0", "aaa", nil)))
+	assert.False(t, cache.Repository("other", 1).Lookup(chartVersion("foo", "1.0.0", "aaa", nil)))
+
+	// a nil cache caches nothing
+	var none *Cache
+	assert.False(t, none.Repository("repo", 1).Lookup(good))
+	none.Repository("repo", 1).Record(bad, nil)
+	assert.Equal(t, StatusVerified, bad.Annotations[StatusAnnotation])
+}
+
+func failed(chartVersion *repo.ChartVersion) string {
+	if chartVersion.Annotations[StatusAnnotation] != StatusFailed {
+		return ""
+	}
+	return chartVersion.Annotations[MessageAnnotation]
+}
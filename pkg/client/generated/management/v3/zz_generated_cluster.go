@@ -14,6 +14,7 @@
 	ClusterFieldAgentEnvVars                                         = "agentEnvVars"
 	ClusterFieldAgentFeatures                                        = "agentFeatures"
 	ClusterFieldAgentImage                                           = "agentImage"
+	ClusterFieldAgentSessions                                        = "agentSessions"
 	ClusterFieldAgentImageOverride                                   = "agentImageOverride"
 	ClusterFieldAllocatable                                          = "allocatable"
 	ClusterFieldAnnotations                                          = "annotations"
@@ -95,6 +96,7 @@ type Cluster struct {
 	AgentEnvVars                                         []EnvVar                       `json:"agentEnvVars,omitempty" yaml:"agentEnvVars,omitempty"`
 	AgentFeatures                                        map[string]bool                `json:"agentFeatures,omitempty" yaml:"agentFeatures,omitempty"`
 	AgentImage                                           string                         `json:"agentImage,omitempty" yaml:"agentImage,omitempty"`
+	AgentSessions                                        []ClusterAgentSession          `json:"agentSessions,omitempty" yaml:"agentSessions,omitempty"`
 	AgentImageOverride                                   string                         `json:"agentImageOverride,omitempty" yaml:"agentImageOverride,omitempty"`
 	Allocatable                                          map[string]string              `json:"allocatable,omitempty" yaml:"allocatable,omitempty"`
 	Annotations                                          map[string]string              `json:"annotations,omitempty" yaml:"annotations,omitempty"`
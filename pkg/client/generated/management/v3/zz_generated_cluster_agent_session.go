@@ -0,0 +1,28 @@
+package client
+
+const (
+	ClusterAgentSessionType                         = "clusterAgentSession"
+	ClusterAgentSessionFieldClientKey               = "clientKey"
+	ClusterAgentSessionFieldClosedByReplicaShutdown = "closedByReplicaShutdown"
+	ClusterAgentSessionFieldConnectedAt             = "connectedAt"
+	ClusterAgentSessionFieldDisconnectedAt          = "disconnectedAt"
+	ClusterAgentSessionFieldPeer                    = "peer"
+	ClusterAgentSessionFieldRecentAddressChanges    = "recentAddressChanges"
+	ClusterAgentSessionFieldRecentReconnects        = "recentReconnects"
+	ClusterAgentSessionFieldRecentReconnectsSince   = "recentReconnectsSince"
+	ClusterAgentSessionFieldReconnects              = "reconnects"
+	ClusterAgentSessionFieldRemoteAddress           = "remoteAddress"
+)
+
+type ClusterAgentSession struct {
+	ClientKey               string `json:"clientKey,omitempty" yaml:"clientKey,omitempty"`
+	ClosedByReplicaShutdown bool   `json:"closedByReplicaShutdown,omitempty" yaml:"closedByReplicaShutdown,omitempty"`
+	ConnectedAt             string `json:"connectedAt,omitempty" yaml:"connectedAt,omitempty"`
+	DisconnectedAt          string `json:"disconnectedAt,omitempty" yaml:"disconnectedAt,omitempty"`
+	Peer                    string `json:"peer,omitempty" yaml:"peer,omitempty"`
+	RecentAddressChanges    int64  `json:"recentAddressChanges,omitempty" yaml:"recentAddressChanges,omitempty"`
+	RecentReconnects        int64  `json:"recentReconnects,omitempty" yaml:"recentReconnects,omitempty"`
+	RecentReconnectsSince   string `json:"recentReconnectsSince,omitempty" yaml:"recentReconnectsSince,omitempty"`
+	Reconnects              int64  `json:"reconnects,omitempty" yaml:"reconnects,omitempty"`
+	RemoteAddress           string `json:"remoteAddress,omitempty" yaml:"remoteAddress,omitempty"`
+}
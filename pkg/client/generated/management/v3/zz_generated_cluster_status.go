@@ -8,6 +8,7 @@
 	ClusterStatusFieldAPIEndpoint                                = "apiEndpoint"
 	ClusterStatusFieldAgentFeatures                              = "agentFeatures"
 	ClusterStatusFieldAgentImage                                 = "agentImage"
+	ClusterStatusFieldAgentSessions                              = "agentSessions"
 	ClusterStatusFieldAllocatable                                = "allocatable"
 	ClusterStatusFieldAppliedAgentEnvVars                        = "appliedAgentEnvVars"
 	ClusterStatusFieldAppliedClusterAgentDeploymentCustomization = "appliedClusterAgentDeploymentCustomization"
@@ -50,6 +51,7 @@ type ClusterStatus struct {
 	APIEndpoint                                string                        `json:"apiEndpoint,omitempty" yaml:"apiEndpoint,omitempty"`
 	AgentFeatures                              map[string]bool               `json:"agentFeatures,omitempty" yaml:"agentFeatures,omitempty"`
 	AgentImage                                 string                        `json:"agentImage,omitempty" yaml:"agentImage,omitempty"`
+	AgentSessions                              []ClusterAgentSession         `json:"agentSessions,omitempty" yaml:"agentSessions,omitempty"`
 	Allocatable                                map[string]string             `json:"allocatable,omitempty" yaml:"allocatable,omitempty"`
 	AppliedAgentEnvVars                        []EnvVar                      `json:"appliedAgentEnvVars,omitempty" yaml:"appliedAgentEnvVars,omitempty"`
 	AppliedClusterAgentDeploymentCustomization *AgentDeploymentCustomization `json:"appliedClusterAgentDeploymentCustomization,omitempty" yaml:"appliedClusterAgentDeploymentCustomization,omitempty"`
@@ -0,0 +1,10 @@
+package client
+
+const (
+	MFACodeInputType         = "mfaCodeInput"
+	MFACodeInputFieldMFACode = "mfaCode"
+)
+
+type MFACodeInput struct {
+	MFACode string `json:"mfaCode,omitempty" yaml:"mfaCode,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	MFAEnrollOutputType           = "mfaEnrollOutput"
+	MFAEnrollOutputFieldMFAKeyURI = "mfaKeyUri"
+	MFAEnrollOutputFieldMFASecret = "mfaSecret"
+)
+
+type MFAEnrollOutput struct {
+	MFAKeyURI string `json:"mfaKeyUri,omitempty" yaml:"mfaKeyUri,omitempty"`
+	MFASecret string `json:"mfaSecret,omitempty" yaml:"mfaSecret,omitempty"`
+}
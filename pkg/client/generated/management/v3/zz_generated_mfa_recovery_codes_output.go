@@ -0,0 +1,10 @@
+package client
+
+const (
+	MFARecoveryCodesOutputType               = "mfaRecoveryCodesOutput"
+	MFARecoveryCodesOutputFieldRecoveryCodes = "recoveryCodes"
+)
+
+type MFARecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recoveryCodes,omitempty" yaml:"recoveryCodes,omitempty"`
+}
@@ -5,6 +5,7 @@
 	OIDCClientSpecFieldDescription                   = "description"
 	OIDCClientSpecFieldRedirectURIs                  = "redirectURIs"
 	OIDCClientSpecFieldRefreshTokenExpirationSeconds = "refreshTokenExpirationSeconds"
+	OIDCClientSpecFieldServicePrincipal              = "servicePrincipal"
 	OIDCClientSpecFieldTokenExpirationSeconds        = "tokenExpirationSeconds"
 )
 
@@ -12,5 +13,6 @@ type OIDCClientSpec struct {
 	Description                   string   `json:"description,omitempty" yaml:"description,omitempty"`
 	RedirectURIs                  []string `json:"redirectURIs,omitempty" yaml:"redirectURIs,omitempty"`
 	RefreshTokenExpirationSeconds int64    `json:"refreshTokenExpirationSeconds,omitempty" yaml:"refreshTokenExpirationSeconds,omitempty"`
+	ServicePrincipal              string   `json:"servicePrincipal,omitempty" yaml:"servicePrincipal,omitempty"`
 	TokenExpirationSeconds        int64    `json:"tokenExpirationSeconds,omitempty" yaml:"tokenExpirationSeconds,omitempty"`
 }
@@ -1,12 +1,14 @@
 package client
 
 const (
-	OIDCClientStatusType               = "oidcClientStatus"
-	OIDCClientStatusFieldClientID      = "clientID"
-	OIDCClientStatusFieldClientSecrets = "clientSecrets"
+	OIDCClientStatusType                  = "oidcClientStatus"
+	OIDCClientStatusFieldClientID         = "clientID"
+	OIDCClientStatusFieldClientSecrets    = "clientSecrets"
+	OIDCClientStatusFieldServicePrincipal = "servicePrincipal"
 )
 
 type OIDCClientStatus struct {
-	ClientID      string                            `json:"clientID,omitempty" yaml:"clientID,omitempty"`
-	ClientSecrets map[string]OIDCClientSecretStatus `json:"clientSecrets,omitempty" yaml:"clientSecrets,omitempty"`
+	ClientID         string                            `json:"clientID,omitempty" yaml:"clientID,omitempty"`
+	ClientSecrets    map[string]OIDCClientSecretStatus `json:"clientSecrets,omitempty" yaml:"clientSecrets,omitempty"`
+	ServicePrincipal string                            `json:"servicePrincipal,omitempty" yaml:"servicePrincipal,omitempty"`
 }
@@ -11,11 +11,13 @@
 	SettingFieldCreatorID       = "creatorId"
 	SettingFieldCustomized      = "customized"
 	SettingFieldDefault         = "default"
+	SettingFieldHistory         = "history"
 	SettingFieldLabels          = "labels"
 	SettingFieldName            = "name"
 	SettingFieldOwnerReferences = "ownerReferences"
 	SettingFieldRemoved         = "removed"
 	SettingFieldSource          = "source"
+	SettingFieldType            = "type"
 	SettingFieldUUID            = "uuid"
 	SettingFieldValue           = "value"
 )
@@ -27,11 +29,13 @@ type Setting struct {
 	CreatorID       string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
 	Customized      bool              `json:"customized,omitempty" yaml:"customized,omitempty"`
 	Default         string            `json:"default,omitempty" yaml:"default,omitempty"`
+	History         []SettingRevision `json:"history,omitempty" yaml:"history,omitempty"`
 	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Name            string            `json:"name,omitempty" yaml:"name,omitempty"`
 	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	Removed         string            `json:"removed,omitempty" yaml:"removed,omitempty"`
 	Source          string            `json:"source,omitempty" yaml:"source,omitempty"`
+	Type            string            `json:"type,omitempty" yaml:"type,omitempty"`
 	UUID            string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
 	Value           string            `json:"value,omitempty" yaml:"value,omitempty"`
 }
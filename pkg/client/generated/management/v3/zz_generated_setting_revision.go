@@ -0,0 +1,18 @@
+package client
+
+const (
+	SettingRevisionType          = "settingRevision"
+	SettingRevisionFieldNewValue = "newValue"
+	SettingRevisionFieldOldValue = "oldValue"
+	SettingRevisionFieldRevision = "revision"
+	SettingRevisionFieldTime     = "time"
+	SettingRevisionFieldUser     = "user"
+)
+
+type SettingRevision struct {
+	NewValue string `json:"newValue,omitempty" yaml:"newValue,omitempty"`
+	OldValue string `json:"oldValue,omitempty" yaml:"oldValue,omitempty"`
+	Revision int64  `json:"revision,omitempty" yaml:"revision,omitempty"`
+	Time     string `json:"time,omitempty" yaml:"time,omitempty"`
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+}
@@ -13,11 +13,15 @@
 	UserFieldDescription          = "description"
 	UserFieldEnabled              = "enabled"
 	UserFieldLabels               = "labels"
+	UserFieldLockedUntil          = "lockedUntil"
 	UserFieldMe                   = "me"
+	UserFieldMFA                  = "mfa"
 	UserFieldMustChangePassword   = "mustChangePassword"
 	UserFieldName                 = "name"
 	UserFieldOwnerReferences      = "ownerReferences"
 	UserFieldPassword             = "password"
+	UserFieldPasswordChangedAt    = "passwordChangedAt"
+	UserFieldPasswordHistory      = "passwordHistory"
 	UserFieldPrincipalIDs         = "principalIds"
 	UserFieldRemoved              = "removed"
 	UserFieldState                = "state"
@@ -36,11 +40,15 @@ type User struct {
 	Description          string            `json:"description,omitempty" yaml:"description,omitempty"`
 	Enabled              *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	LockedUntil          string            `json:"lockedUntil,omitempty" yaml:"lockedUntil,omitempty"`
 	Me                   bool              `json:"me,omitempty" yaml:"me,omitempty"`
+	MFA                  *UserMFA          `json:"mfa,omitempty" yaml:"mfa,omitempty"`
 	MustChangePassword   bool              `json:"mustChangePassword,omitempty" yaml:"mustChangePassword,omitempty"`
 	Name                 string            `json:"name,omitempty" yaml:"name,omitempty"`
 	OwnerReferences      []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
 	Password             string            `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordChangedAt    string            `json:"passwordChangedAt,omitempty" yaml:"passwordChangedAt,omitempty"`
+	PasswordHistory      []string          `json:"passwordHistory,omitempty" yaml:"passwordHistory,omitempty"`
 	PrincipalIDs         []string          `json:"principalIds,omitempty" yaml:"principalIds,omitempty"`
 	Removed              string            `json:"removed,omitempty" yaml:"removed,omitempty"`
 	State                string            `json:"state,omitempty" yaml:"state,omitempty"`
@@ -71,11 +79,23 @@ type UserOperations interface {
 
 	ActionRefreshauthprovideraccess(resource *User) error
 
+	ActionResetmfa(resource *User) (*User, error)
+
 	ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error)
 
+	ActionUnlock(resource *User) (*User, error)
+
 	CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error
 
+	CollectionActionConfirmmfa(resource *UserCollection, input *MFACodeInput) (*MFARecoveryCodesOutput, error)
+
+	CollectionActionDisablemfa(resource *UserCollection, input *MFACodeInput) error
+
+	CollectionActionEnrollmfa(resource *UserCollection) (*MFAEnrollOutput, error)
+
 	CollectionActionRefreshauthprovideraccess(resource *UserCollection) error
+
+	CollectionActionRegeneratemfarecoverycodes(resource *UserCollection, input *MFACodeInput) (*MFARecoveryCodesOutput, error)
 }
 
 func newUserClient(apiClient *Client) *UserClient {
@@ -152,18 +172,53 @@ func (c *UserClient) ActionRefreshauthprovideraccess(resource *User) error {
 	return err
 }
 
+func (c *UserClient) ActionResetmfa(resource *User) (*User, error) {
+	resp := &User{}
+	err := c.apiClient.Ops.DoAction(UserType, "resetmfa", &resource.Resource, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error) {
 	resp := &User{}
 	err := c.apiClient.Ops.DoAction(UserType, "setpassword", &resource.Resource, input, resp)
 	return resp, err
 }
 
+func (c *UserClient) ActionUnlock(resource *User) (*User, error) {
+	resp := &User{}
+	err := c.apiClient.Ops.DoAction(UserType, "unlock", &resource.Resource, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "changepassword", &resource.Collection, input, nil)
 	return err
 }
 
+func (c *UserClient) CollectionActionConfirmmfa(resource *UserCollection, input *MFACodeInput) (*MFARecoveryCodesOutput, error) {
+	resp := &MFARecoveryCodesOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "confirmmfa", &resource.Collection, input, resp)
+	return resp, err
+}
+
+func (c *UserClient) CollectionActionDisablemfa(resource *UserCollection, input *MFACodeInput) error {
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "disablemfa", &resource.Collection, input, nil)
+	return err
+}
+
+func (c *UserClient) CollectionActionEnrollmfa(resource *UserCollection) (*MFAEnrollOutput, error) {
+	resp := &MFAEnrollOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "enrollmfa", &resource.Collection, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) CollectionActionRefreshauthprovideraccess(resource *UserCollection) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "refreshauthprovideraccess", &resource.Collection, nil, nil)
 	return err
 }
+
+func (c *UserClient) CollectionActionRegeneratemfarecoverycodes(resource *UserCollection, input *MFACodeInput) (*MFARecoveryCodesOutput, error) {
+	resp := &MFARecoveryCodesOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "regeneratemfarecoverycodes", &resource.Collection, input, resp)
+	return resp, err
+}
@@ -0,0 +1,18 @@
+package client
+
+const (
+	UserMFAType               = "userMFA"
+	UserMFAFieldEnabled       = "enabled"
+	UserMFAFieldEnrolledAt    = "enrolledAt"
+	UserMFAFieldLastUsedStep  = "lastUsedStep"
+	UserMFAFieldRecoveryCodes = "recoveryCodes"
+	UserMFAFieldSecretName    = "secretName"
+)
+
+type UserMFA struct {
+	Enabled       bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	EnrolledAt    string   `json:"enrolledAt,omitempty" yaml:"enrolledAt,omitempty"`
+	LastUsedStep  int64    `json:"lastUsedStep,omitempty" yaml:"lastUsedStep,omitempty"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty" yaml:"recoveryCodes,omitempty"`
+	SecretName    string   `json:"secretName,omitempty" yaml:"secretName,omitempty"`
+}
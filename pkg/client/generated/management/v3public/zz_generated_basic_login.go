@@ -3,6 +3,7 @@
 const (
 	BasicLoginType              = "basicLogin"
 	BasicLoginFieldDescription  = "description"
+	BasicLoginFieldMFACode      = "mfaCode"
 	BasicLoginFieldPassword     = "password"
 	BasicLoginFieldResponseType = "responseType"
 	BasicLoginFieldTTLMillis    = "ttl"
@@ -11,6 +12,7 @@
 
 type BasicLogin struct {
 	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+	MFACode      string `json:"mfaCode,omitempty" yaml:"mfaCode,omitempty"`
 	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
 	ResponseType string `json:"responseType,omitempty" yaml:"responseType,omitempty"`
 	TTLMillis    int64  `json:"ttl,omitempty" yaml:"ttl,omitempty"`
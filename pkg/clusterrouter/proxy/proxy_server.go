@@ -15,6 +15,7 @@
 	"github.com/rancher/norman/httperror"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	authcontext "github.com/rancher/rancher/pkg/auth/context"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	dialer2 "github.com/rancher/rancher/pkg/dialer"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/impersonation"
@@ -53,6 +54,7 @@ type RemoteService struct {
 	clusterContextGetter           ClusterContextGetter
 	tokenCreator                   tokenCreator
 	impersonatorAccountTokenGetter impersonatorAccountTokenGetter
+	limiter                        *requestLimiter
 }
 
 var (
@@ -106,6 +108,7 @@ func NewLocal(localConfig *rest.Config, cluster *v3.Cluster) (*RemoteService, er
 			return *hostURL, nil
 		},
 		transport: transportGetter,
+		limiter:   newRequestLimiter(cluster.Name),
 	}
 	if localConfig.BearerToken != "" {
 		rs.localAuth = "Bearer " + localConfig.BearerToken
@@ -164,6 +167,7 @@ func NewRemote(cluster *v3.Cluster, clusterLister v3.ClusterLister, factory dial
 		clusterContextGetter:           clusterContextGetter,
 		tokenCreator:                   tokenCreator,
 		impersonatorAccountTokenGetter: getImpersonatorAccountToken,
+		limiter:                        newRequestLimiter(cluster.Name),
 	}, nil
 }
 
@@ -241,6 +245,26 @@ func (r *RemoteService) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	u.Path = strings.TrimPrefix(req.URL.Path, prefix(r.cluster))
 	u.RawQuery = req.URL.RawQuery
 
+	// Requests authenticated with a scoped token are checked against the scope using the proxied path.
+	if tokenScope := authcontext.GetTokenScope(req.Context()); !scope.AllowsCluster(tokenScope, r.cluster.Name) ||
+		!scope.AllowsKubernetesRequest(tokenScope, req, u.Path) {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(fmt.Sprintf("%s %s is not allowed by the token scope", req.Method, req.URL.Path)))
+		return
+	}
+
+	if r.limiter != nil {
+		var userName string
+		if userInfo, ok := request.UserFrom(req.Context()); ok {
+			userName = userInfo.GetName()
+		}
+		release, ok := r.limiter.limit(rw, req, userName)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+
 	proto := req.Header.Get("X-Forwarded-Proto")
 	if proto != "" {
 		req.URL.Scheme = proto
@@ -9,6 +9,7 @@
 	"testing"
 
 	"github.com/pkg/errors"
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	auth "github.com/rancher/rancher/pkg/auth/context"
 	"github.com/stretchr/testify/assert"
@@ -22,6 +23,7 @@ func TestServeHTTP(t *testing.T) {
 		tokenCreator                   tokenCreator
 		impersonatorAccountTokenGetter impersonatorAccountTokenGetter
 		header                         http.Header
+		path                           string
 		ctx                            func() context.Context
 		wantHeader                     http.Header
 		wantErr                        string
@@ -114,6 +116,56 @@ func TestServeHTTP(t *testing.T) {
 			wantHeader: map[string][]string{},
 			wantErr:    "",
 		},
+		"scoped token - request not allowed": {
+			header: map[string][]string{},
+			path:   "/k8s/clusters/test/api/v1/namespaces/default/secrets",
+			ctx: func() context.Context {
+				ctx := request.WithUser(context.TODO(), &user.DefaultInfo{
+					Name: "user",
+					UID:  "user",
+				})
+				return auth.SetTokenScope(ctx, &ext.TokenScope{
+					Rules: []ext.TokenScopeRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+				})
+			},
+			wantHeader: map[string][]string{},
+			wantErr:    "is not allowed by the token scope",
+		},
+		"scoped token - cluster not allowed": {
+			header: map[string][]string{},
+			path:   "/k8s/clusters/test/api/v1/namespaces/default/pods",
+			ctx: func() context.Context {
+				ctx := request.WithUser(context.TODO(), &user.DefaultInfo{
+					Name: "user",
+					UID:  "user",
+				})
+				return auth.SetTokenScope(ctx, &ext.TokenScope{Clusters: []string{"other"}})
+			},
+			wantHeader: map[string][]string{},
+			wantErr:    "is not allowed by the token scope",
+		},
+		"scoped token - request allowed": {
+			header: map[string][]string{},
+			path:   "/k8s/clusters/test/api/v1/namespaces/default/pods",
+			ctx: func() context.Context {
+				ctx := request.WithUser(context.TODO(), &user.DefaultInfo{
+					Name: "user",
+					UID:  "user",
+				})
+				return auth.SetTokenScope(ctx, &ext.TokenScope{
+					Clusters: []string{"test"},
+					Rules:    []ext.TokenScopeRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}},
+				})
+			},
+			impersonatorAccountTokenGetter: func(_ user.Info, _ ClusterContextGetter, _ string) (string, error) {
+				return "fake-token", nil
+			},
+			wantHeader: map[string][]string{
+				"Authorization": {
+					"Bearer fake-token",
+				},
+			},
+		},
 		"user not authenticated": {
 			header: map[string][]string{},
 			ctx: func() context.Context {
@@ -142,9 +194,14 @@ func TestServeHTTP(t *testing.T) {
 				tokenCreator:                   test.tokenCreator,
 				impersonatorAccountTokenGetter: test.impersonatorAccountTokenGetter,
 			}
+			path := test.path
+			if path == "" {
+				path = "http://localhost"
+			}
 			req := &http.Request{
+				Method: http.MethodGet,
 				URL: &url.URL{
-					Path: "http://localhost",
+					Path: path,
 				},
 				Header: test.header,
 			}
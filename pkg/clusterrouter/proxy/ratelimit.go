@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher/pkg/metrics"
+	"github.com/rancher/rancher/pkg/settings"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// priorityClass groups proxied requests so that each group is limited separately and a flood of one can't starve the
+// others.
+type priorityClass string
+
+const (
+	classRead     priorityClass = "read"
+	classMutating priorityClass = "mutating"
+	// classWatch covers all long-running requests: watches, followed logs and upgraded connections such as exec.
+	classWatch priorityClass = "watch"
+)
+
+const (
+	reasonClusterQPS      = "cluster-qps"
+	reasonUserQPS         = "user-qps"
+	reasonClusterInflight = "cluster-inflight"
+	reasonUserInflight    = "user-inflight"
+
+	// userIdleTimeout is how long the state kept for a user without requests in flight is retained.
+	userIdleTimeout = 10 * time.Minute
+)
+
+// limits are the limits applied to the requests proxied to a cluster. A zero value disables the respective limit.
+type limits struct {
+	clusterQPS      int
+	userQPS         int
+	clusterInflight map[priorityClass]int
+	userInflight    int
+	userWatches     int
+}
+
+func limitsFromSettings() limits {
+	return limits{
+		clusterQPS: settings.ClusterProxyClusterQPS.GetInt(),
+		userQPS:    settings.ClusterProxyUserQPS.GetInt(),
+		clusterInflight: map[priorityClass]int{
+			classRead:     settings.ClusterProxyClusterMaxInflightReads.GetInt(),
+			classMutating: settings.ClusterProxyClusterMaxInflightMutating.GetInt(),
+			classWatch:    settings.ClusterProxyClusterMaxWatches.GetInt(),
+		},
+		userInflight: settings.ClusterProxyUserMaxInflight.GetInt(),
+		userWatches:  settings.ClusterProxyUserMaxWatches.GetInt(),
+	}
+}
+
+// rejection describes why a request was not admitted and when the client should retry.
+type rejection struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+// requestLimiter enforces the concurrency and QPS limits of the requests proxied to a single cluster.
+type requestLimiter struct {
+	sync.Mutex
+
+	clusterName string
+	limits      func() limits
+	now         func() time.Time
+
+	clusterRate     *rate.Limiter
+	clusterInflight map[priorityClass]int
+	users           map[string]*userState
+	lastPrune       time.Time
+}
+
+type userState struct {
+	rate     *rate.Limiter
+	inflight int
+	watches  int
+	lastSeen time.Time
+}
+
+func newRequestLimiter(clusterName string) *requestLimiter {
+	return &requestLimiter{
+		clusterName:     clusterName,
+		limits:          limitsFromSettings,
+		now:             time.Now,
+		clusterInflight: map[priorityClass]int{},
+		users:           map[string]*userState{},
+	}
+}
+
+// classify returns the priority class of the request.
+func classify(req *http.Request) priorityClass {
+	if httpstream.IsUpgradeRequest(req) {
+		return classWatch
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		query := req.URL.Query()
+		if isTrue(query.Get("watch")) || isTrue(query.Get("follow")) || strings.Contains(req.URL.Path, "/watch/") {
+			return classWatch
+		}
+		return classRead
+	default:
+		return classMutating
+	}
+}
+
+func isTrue(value string) bool {
+	b, err := strconv.ParseBool(value)
+	return err == nil && b
+}
+
+// acquire admits a request of the given class from the given user. When admitted the returned function must be called
+// once the request completes, otherwise the returned rejection says why the request was not admitted.
+func (l *requestLimiter) acquire(userName string, class priorityClass) (func(), *rejection) {
+	l.Lock()
+	defer l.Unlock()
+
+	lim := l.limits()
+	now := l.now()
+	l.prune(now)
+
+	user, ok := l.users[userName]
+	if !ok {
+		user = &userState{}
+		l.users[userName] = user
+	}
+	user.lastSeen = now
+
+	if maxInflight := lim.clusterInflight[class]; maxInflight > 0 && l.clusterInflight[class] >= maxInflight {
+		return nil, &rejection{reason: reasonClusterInflight, retryAfter: time.Second}
+	}
+	// Watches are only limited by concurrency, a long-running request counts once no matter how long it lasts.
+	if class == classWatch {
+		if lim.userWatches > 0 && user.watches >= lim.userWatches {
+			return nil, &rejection{reason: reasonUserInflight, retryAfter: time.Second}
+		}
+	} else {
+		if lim.userInflight > 0 && user.inflight >= lim.userInflight {
+			return nil, &rejection{reason: reasonUserInflight, retryAfter: time.Second}
+		}
+
+		user.rate = updateRate(user.rate, lim.userQPS)
+		userReservation, delay := reserve(user.rate, now)
+		if delay > 0 {
+			return nil, &rejection{reason: reasonUserQPS, retryAfter: delay}
+		}
+		l.clusterRate = updateRate(l.clusterRate, lim.clusterQPS)
+		if _, delay := reserve(l.clusterRate, now); delay > 0 {
+			if userReservation != nil {
+				userReservation.CancelAt(now)
+			}
+			return nil, &rejection{reason: reasonClusterQPS, retryAfter: delay}
+		}
+	}
+
+	l.clusterInflight[class]++
+	if class == classWatch {
+		user.watches++
+	} else {
+		user.inflight++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.Lock()
+			defer l.Unlock()
+			l.clusterInflight[class]--
+			if class == classWatch {
+				user.watches--
+			} else {
+				user.inflight--
+			}
+			user.lastSeen = l.now()
+		})
+	}, nil
+}
+
+// prune drops the state of users that have been idle for longer than userIdleTimeout.
+func (l *requestLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < userIdleTimeout {
+		return
+	}
+	l.lastPrune = now
+	for name, user := range l.users {
+		if user.inflight == 0 && user.watches == 0 && now.Sub(user.lastSeen) > userIdleTimeout {
+			delete(l.users, name)
+		}
+	}
+}
+
+// updateRate returns a rate limiter allowing qps requests per second with a burst of the same size, reusing the given
+// one if possible. It returns nil if qps is not positive.
+func updateRate(limiter *rate.Limiter, qps int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	if limiter == nil {
+		return rate.NewLimiter(rate.Limit(qps), qps)
+	}
+	if limiter.Limit() != rate.Limit(qps) {
+		limiter.SetLimit(rate.Limit(qps))
+		limiter.SetBurst(qps)
+	}
+	return limiter
+}
+
+// reserve takes a token from the limiter. If none is available the reservation is cancelled and the time until one
+// is available is returned.
+func reserve(limiter *rate.Limiter, now time.Time) (*rate.Reservation, time.Duration) {
+	if limiter == nil {
+		return nil, 0
+	}
+	r := limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return nil, delay
+	}
+	return r, 0
+}
+
+// limit admits the request or, if it exceeds a limit, responds with 429 Too Many Requests and a Retry-After header.
+// It returns the function to call once an admitted request completes and whether the request was admitted.
+func (l *requestLimiter) limit(rw http.ResponseWriter, req *http.Request, userName string) (func(), bool) {
+	class := classify(req)
+	release, rejected := l.acquire(userName, class)
+	if rejected == nil {
+		return release, true
+	}
+
+	metrics.IncClusterProxyRejected(l.clusterName, string(class), rejected.reason)
+	retryAfter := int(math.Ceil(rejected.retryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	rw.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	rw.WriteHeader(http.StatusTooManyRequests)
+	rw.Write([]byte(fmt.Sprintf("too many requests to cluster %s (%s), retry after %d seconds", l.clusterName, rejected.reason, retryAfter)))
+	return nil, false
+}
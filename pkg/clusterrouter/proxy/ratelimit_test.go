@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := map[string]struct {
+		method string
+		target string
+		header http.Header
+		want   priorityClass
+	}{
+		"list": {
+			method: http.MethodGet,
+			target: "/k8s/clusters/c-1/api/v1/pods",
+			want:   classRead,
+		},
+		"watch": {
+			method: http.MethodGet,
+			target: "/k8s/clusters/c-1/api/v1/pods?watch=true",
+			want:   classWatch,
+		},
+		"deprecated watch path": {
+			method: http.MethodGet,
+			target: "/k8s/clusters/c-1/api/v1/watch/pods",
+			want:   classWatch,
+		},
+		"followed logs": {
+			method: http.MethodGet,
+			target: "/k8s/clusters/c-1/api/v1/namespaces/default/pods/p/log?follow=1",
+			want:   classWatch,
+		},
+		"exec": {
+			method: http.MethodPost,
+			target: "/k8s/clusters/c-1/api/v1/namespaces/default/pods/p/exec",
+			header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}},
+			want:   classWatch,
+		},
+		"create": {
+			method: http.MethodPost,
+			target: "/k8s/clusters/c-1/api/v1/namespaces",
+			want:   classMutating,
+		},
+		"delete": {
+			method: http.MethodDelete,
+			target: "/k8s/clusters/c-1/api/v1/namespaces/default",
+			want:   classMutating,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			assert.Equal(t, tt.want, classify(req))
+		})
+	}
+}
+
+func newTestLimiter(lim limits) (*requestLimiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRequestLimiter("c-1")
+	l.limits = func() limits { return lim }
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAcquireConcurrency(t *testing.T) {
+	l, _ := newTestLimiter(limits{
+		clusterInflight: map[priorityClass]int{classRead: 2, classWatch: 1},
+		userInflight:    1,
+	})
+
+	releaseA, rejected := l.acquire("user-a", classRead)
+	require.Nil(t, rejected)
+
+	_, rejected = l.acquire("user-a", classRead)
+	require.NotNil(t, rejected)
+	assert.Equal(t, reasonUserInflight, rejected.reason)
+
+	releaseB, rejected := l.acquire("user-b", classRead)
+	require.Nil(t, rejected)
+
+	_, rejected = l.acquire("user-c", classRead)
+	require.NotNil(t, rejected)
+	assert.Equal(t, reasonClusterInflight, rejected.reason)
+
+	// Mutating requests and watches are limited separately from reads.
+	releaseMutating, rejected := l.acquire("user-c", classMutating)
+	require.Nil(t, rejected)
+	releaseWatch, rejected := l.acquire("user-c", classWatch)
+	require.Nil(t, rejected)
+	_, rejected = l.acquire("user-d", classWatch)
+	require.NotNil(t, rejected)
+	assert.Equal(t, reasonClusterInflight, rejected.reason)
+
+	releaseA()
+	releaseA() // Releasing twice has no effect.
+	_, rejected = l.acquire("user-c", classRead)
+	require.NotNil(t, rejected, "user-c has a mutating request in flight")
+	_, rejected = l.acquire("user-a", classRead)
+	require.Nil(t, rejected)
+
+	releaseB()
+	releaseMutating()
+	releaseWatch()
+	assert.Equal(t, 1, l.clusterInflight[classRead])
+	assert.Equal(t, 0, l.clusterInflight[classMutating])
+	assert.Equal(t, 0, l.clusterInflight[classWatch])
+}
+
+func TestAcquireQPS(t *testing.T) {
+	l, now := newTestLimiter(limits{
+		clusterQPS: 3,
+		userQPS:    2,
+	})
+
+	for i := 0; i < 2; i++ {
+		release, rejected := l.acquire("user-a", classRead)
+		require.Nil(t, rejected)
+		release()
+	}
+	_, rejected := l.acquire("user-a", classMutating)
+	require.NotNil(t, rejected)
+	assert.Equal(t, reasonUserQPS, rejected.reason)
+	assert.Equal(t, 500*time.Millisecond, rejected.retryAfter)
+
+	release, rejected := l.acquire("user-b", classRead)
+	require.Nil(t, rejected)
+	release()
+	_, rejected = l.acquire("user-b", classRead)
+	require.NotNil(t, rejected)
+	assert.Equal(t, reasonClusterQPS, rejected.reason)
+
+	// Watches are not subject to the QPS limits.
+	release, rejected = l.acquire("user-a", classWatch)
+	require.Nil(t, rejected)
+	release()
+
+	*now = now.Add(time.Second)
+	release, rejected = l.acquire("user-a", classRead)
+	require.Nil(t, rejected)
+	release()
+}
+
+func TestAcquirePrunesIdleUsers(t *testing.T) {
+	l, now := newTestLimiter(limits{})
+
+	release, rejected := l.acquire("user-a", classRead)
+	require.Nil(t, rejected)
+	release()
+	releaseWatch, rejected := l.acquire("user-b", classWatch)
+	require.Nil(t, rejected)
+
+	*now = now.Add(2 * userIdleTimeout)
+	release, rejected = l.acquire("user-c", classRead)
+	require.Nil(t, rejected)
+	release()
+
+	assert.NotContains(t, l.users, "user-a")
+	assert.Contains(t, l.users, "user-b", "users with requests in flight are kept")
+	releaseWatch()
+}
+
+func TestLimit(t *testing.T) {
+	l, _ := newTestLimiter(limits{userQPS: 1})
+
+	rw := httptest.NewRecorder()
+	release, ok := l.limit(rw, httptest.NewRequest(http.MethodGet, "/k8s/clusters/c-1/api/v1/pods", nil), "user-a")
+	require.True(t, ok)
+	release()
+
+	rw = httptest.NewRecorder()
+	_, ok = l.limit(rw, httptest.NewRequest(http.MethodGet, "/k8s/clusters/c-1/api/v1/pods", nil), "user-a")
+	require.False(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+}
@@ -15,6 +15,7 @@
 	"github.com/rancher/rancher/pkg/controllers/capr/plansecret"
 	"github.com/rancher/rancher/pkg/controllers/capr/rkecluster"
 	"github.com/rancher/rancher/pkg/controllers/capr/rkecontrolplane"
+	"github.com/rancher/rancher/pkg/controllers/capr/snapshotverification"
 	"github.com/rancher/rancher/pkg/controllers/capr/unmanaged"
 	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/provisioningv2/image"
@@ -25,14 +26,19 @@
 	"github.com/rancher/rancher/pkg/wrangler"
 )
 
-func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager *kubeconfig.Manager) error {
-	rkePlanner := planner.New(ctx, clients, planner.InfoFunctions{
+// NewPlanner returns a planner that resolves images, release data and system information the way Rancher does.
+func NewPlanner(ctx context.Context, clients *wrangler.Context) *planner.Planner {
+	return planner.New(ctx, clients, planner.InfoFunctions{
 		ImageResolver:           image.ResolveWithControlPlane,
 		ReleaseData:             capr.GetKDMReleaseData,
 		SystemAgentImage:        settings.SystemAgentInstallerImage.Get,
 		SystemPodLabelSelectors: systeminfo.NewRetriever(clients).GetSystemPodLabelSelectors,
 		GetBootstrapManifests:   prebootstrap.NewRetriever(clients).GeneratePreBootstrapClusterAgentManifest,
 	})
+}
+
+func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager *kubeconfig.Manager) error {
+	rkePlanner := NewPlanner(ctx, clients)
 	if features.MCM.Enabled() {
 		if err := dynamicschema.Register(ctx, clients); err != nil {
 			return err
@@ -48,6 +54,7 @@ func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager
 	rkecontrolplane.Register(ctx, clients)
 	managesystemagent.Register(ctx, clients)
 	machinedrain.Register(ctx, clients)
+	snapshotverification.Register(ctx, clients)
 
 	return nil
 }
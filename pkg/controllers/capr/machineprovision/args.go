@@ -13,6 +13,7 @@
 	"strings"
 
 	"github.com/rancher/rancher/pkg/controllers/management/drivers/nodedriver"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	"github.com/rancher/wrangler/v3/pkg/data"
 	"github.com/rancher/wrangler/v3/pkg/data/convert"
 	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
@@ -250,12 +251,17 @@ func (h *handler) getSecretData(machine *capi.Machine, obj data.Object, create b
 	return bootstrapName, cloudCredentialSecretName, result, nil
 }
 
+// GetCloudCredentialSecret returns the cloud credential secret referenced by name, decrypted if it is encrypted.
 func GetCloudCredentialSecret(secrets corecontrollers.SecretCache, ns, name string) (*corev1.Secret, error) {
 	globalNS, globalName := kv.Split(name, ":")
 	if globalName != "" && globalNS == namespace.GlobalNamespace {
-		return secrets.Get(globalNS, globalName)
+		ns, name = globalNS, globalName
 	}
-	return secrets.Get(ns, name)
+	secret, err := secrets.Get(ns, name)
+	if err != nil {
+		return nil, err
+	}
+	return encryptedstore.DecryptSecret(secret)
 }
 
 // addAwsClusterOwnedTag will add a tag to the machine arguments of an AWS machine of the form
@@ -0,0 +1,356 @@
+package snapshotverification
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/name"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	fetchContainer   = "fetch"
+	restoreContainer = "restore"
+	etcdContainer    = "etcd"
+	verifyContainer  = "verify"
+
+	snapshotVolume = "snapshot"
+	tmpVolume      = "tmp"
+	caVolume       = "ca"
+	caKey          = "ca.crt"
+
+	defaultTimeoutSeconds = 600
+)
+
+// fetchScript downloads the snapshot object from S3 with a path-style request signed with the credentials and checks its
+// size. Compressed snapshots are decompressed. The integrity of the snapshot is checked by restoring it.
+const fetchScript = `set -euo pipefail
+fail() { echo "$1" > /dev/termination-log; exit 1; }
+args=(--fail --silent --show-error --output /snapshot/object)
+if [ -n "${AWS_ACCESS_KEY_ID:-}" ]; then
+  args+=(--aws-sigv4 "aws:amz:${S3_REGION}:s3" --user "${AWS_ACCESS_KEY_ID}:${AWS_SECRET_ACCESS_KEY}")
+fi
+if [ "${S3_SKIP_SSL_VERIFY}" = "true" ]; then
+  args+=(--insecure)
+elif [ -s /ca/ca.crt ]; then
+  args+=(--cacert /ca/ca.crt)
+fi
+curl "${args[@]}" "${S3_ENDPOINT}/${S3_BUCKET}/${S3_KEY}" 2>/tmp/error || fail "failed to download snapshot: $(cat /tmp/error)"
+size=$(stat -c %s /snapshot/object)
+if [ "${EXPECTED_SIZE}" != "0" ] && [ "${size}" != "${EXPECTED_SIZE}" ]; then
+  fail "snapshot object has ${size} bytes, expected ${EXPECTED_SIZE} bytes"
+fi
+case "${S3_KEY}" in
+*.zip) unzip -p /snapshot/object > /snapshot/snapshot.db 2>/tmp/error || fail "failed to decompress snapshot: $(cat /tmp/error)" ;;
+*) mv /snapshot/object /snapshot/snapshot.db ;;
+esac
+echo "{\"size\":${size}}" > /dev/termination-log
+`
+
+// verifyScript waits for the throw-away etcd serving the restored snapshot and counts its keys through the gRPC
+// gateway. The range from "\0" to "\0" covers all keys.
+const verifyScript = `set -euo pipefail
+fail() { echo "$1" > /dev/termination-log; exit 1; }
+for i in $(seq 1 120); do
+  curl --silent --fail http://127.0.0.1:2379/health > /dev/null && break
+  sleep 1
+done
+response=$(curl --silent --show-error --fail -X POST http://127.0.0.1:2379/v3/kv/range \
+  -d '{"key":"AA==","range_end":"AA==","count_only":true}' 2>/tmp/error) || fail "restored etcd is not serving requests: $(cat /tmp/error)"
+count=$(echo "${response}" | sed -n 's/.*"count":"\{0,1\}\([0-9]*\).*/\1/p')
+revision=$(echo "${response}" | sed -n 's/.*"revision":"\{0,1\}\([0-9]*\).*/\1/p')
+echo "{\"keyCount\":${count:-0},\"revision\":${revision:-0}}" > /dev/termination-log
+`
+
+// fetchResult is the termination message of the fetch container.
+type fetchResult struct {
+	Size int64 `json:"size"`
+}
+
+// verifyResult is the termination message of the verify container.
+type verifyResult struct {
+	KeyCount int64 `json:"keyCount"`
+	Revision int64 `json:"revision"`
+}
+
+// s3Object returns the bucket and key of the location of an S3 snapshot.
+func s3Object(snapshot *rkev1.ETCDSnapshot) (string, string, error) {
+	location, err := url.Parse(snapshot.SnapshotFile.Location)
+	if err != nil {
+		return "", "", err
+	}
+	if location.Scheme != "s3" || location.Host == "" || strings.TrimPrefix(location.Path, "/") == "" {
+		return "", "", fmt.Errorf("invalid S3 snapshot location %s", snapshot.SnapshotFile.Location)
+	}
+	return location.Host, strings.TrimPrefix(location.Path, "/"), nil
+}
+
+// endpointURL returns the URL of the S3 endpoint. Endpoints without a scheme, as configured for the distribution, are
+// served over HTTPS, an explicit http:// scheme allows verifying against a plain HTTP stand-in such as MinIO.
+func endpointURL(endpoint string) string {
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// endpointCA returns the PEM encoded CA of the S3 configuration. The endpoint CA of a snapshot may be the path of the
+// CA file on the node that took it, in which case the CA of the S3 configuration of the control plane is used.
+func endpointCA(s3Config planner.S3Config, controlPlane *rkev1.RKEControlPlane) string {
+	ca := s3Config.EndpointCA
+	if strings.HasSuffix(ca, ".crt") && !strings.Contains(ca, "BEGIN") {
+		ca = ""
+		if controlPlane.Spec.ETCD != nil && controlPlane.Spec.ETCD.S3 != nil {
+			ca = controlPlane.Spec.ETCD.S3.EndpointCA
+		}
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(ca); err == nil {
+		return string(decoded)
+	}
+	return ca
+}
+
+func jobName(snapshot *rkev1.ETCDSnapshot) string {
+	return name.SafeConcatName(snapshot.Name, "verify")
+}
+
+// verificationObjects returns the secret holding the S3 credentials and the job verifying the snapshot.
+func verificationObjects(snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane, config *rkev1.ETCDSnapshotVerification, s3Config planner.S3Config) (*corev1.Secret, *batchv1.Job, error) {
+	bucket, key, err := s3Object(snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	image := settings.FullEtcdSnapshotVerificationImage()
+	timeout := int64(config.TimeoutSeconds)
+	if timeout <= 0 {
+		timeout = defaultTimeoutSeconds
+	}
+	region := s3Config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	ownerReferences := []metav1.OwnerReference{{
+		APIVersion: rkev1.SchemeGroupVersion.String(),
+		Kind:       "ETCDSnapshot",
+		Name:       snapshot.Name,
+		UID:        snapshot.UID,
+	}}
+	labels := map[string]string{
+		capr.ClusterNameLabel: snapshot.Spec.ClusterName,
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jobName(snapshot),
+			Namespace:       snapshot.Namespace,
+			Labels:          labels,
+			OwnerReferences: ownerReferences,
+		},
+		StringData: map[string]string{
+			"AWS_ACCESS_KEY_ID":     s3Config.AccessKey,
+			"AWS_SECRET_ACCESS_KEY": s3Config.SecretKey,
+			"S3_ENDPOINT":           endpointURL(s3Config.Endpoint),
+			"S3_REGION":             region,
+			"S3_BUCKET":             bucket,
+			"S3_KEY":                key,
+			"S3_SKIP_SSL_VERIFY":    strconv.FormatBool(s3Config.SkipSSLVerify),
+			"EXPECTED_SIZE":         strconv.FormatInt(snapshot.SnapshotFile.Size, 10),
+			caKey:                   endpointCA(s3Config, controlPlane),
+		},
+	}
+
+	backoffLimit := int32(0)
+	sidecar := corev1.ContainerRestartPolicyAlways
+	snapshotMount := corev1.VolumeMount{Name: snapshotVolume, MountPath: "/snapshot"}
+	tmpMount := corev1.VolumeMount{Name: tmpVolume, MountPath: "/tmp"}
+	// The containers only write to the volumes, so they run unprivileged with a read-only root filesystem.
+	user := int64(1000)
+	nonRoot, privilegeEscalation, readOnly, automountToken := true, false, true, false
+	securityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &privilegeEscalation,
+		ReadOnlyRootFilesystem:   &readOnly,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+	envFrom := []corev1.EnvFromSource{{
+		SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+		},
+	}}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jobName(snapshot),
+			Namespace:       snapshot.Namespace,
+			Labels:          labels,
+			OwnerReferences: ownerReferences,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &timeout,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountToken,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   &nonRoot,
+						RunAsUser:      &user,
+						RunAsGroup:     &user,
+						FSGroup:        &user,
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name:         snapshotVolume,
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
+						{
+							Name:         tmpVolume,
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
+						{
+							Name: caVolume,
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+								SecretName: secret.Name,
+								Items:      []corev1.KeyToPath{{Key: caKey, Path: caKey}},
+							}},
+						},
+					},
+					InitContainers: []corev1.Container{
+						{
+							Name:                     fetchContainer,
+							Image:                    settings.FullShellImage(),
+							Command:                  []string{"bash", "-c", fetchScript},
+							EnvFrom:                  envFrom,
+							VolumeMounts:             []corev1.VolumeMount{snapshotMount, tmpMount, {Name: caVolume, MountPath: "/ca", ReadOnly: true}},
+							SecurityContext:          securityContext,
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+						{
+							// Restoring checks the integrity hash appended to the snapshot.
+							Name:                     restoreContainer,
+							Image:                    image,
+							Command:                  []string{"etcdutl", "snapshot", "restore", "/snapshot/snapshot.db", "--data-dir=/snapshot/data"},
+							VolumeMounts:             []corev1.VolumeMount{snapshotMount},
+							SecurityContext:          securityContext,
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+						{
+							// The throw-away etcd runs as a sidecar, so that it is stopped once the verification completed.
+							Name:          etcdContainer,
+							Image:         image,
+							RestartPolicy: &sidecar,
+							Command: []string{
+								"etcd",
+								"--data-dir=/snapshot/data",
+								"--listen-client-urls=http://127.0.0.1:2379",
+								"--advertise-client-urls=http://127.0.0.1:2379",
+								"--listen-peer-urls=http://127.0.0.1:2380",
+								"--experimental-initial-corrupt-check=true",
+							},
+							VolumeMounts:             []corev1.VolumeMount{snapshotMount},
+							SecurityContext:          securityContext,
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:                     verifyContainer,
+							Image:                    settings.FullShellImage(),
+							Command:                  []string{"bash", "-c", verifyScript},
+							VolumeMounts:             []corev1.VolumeMount{tmpMount},
+							SecurityContext:          securityContext,
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return secret, job, nil
+}
+
+// verificationResult returns the result of the verification from the pod of the completed job. If the job failed, the
+// message of the container that failed is recorded, or the message of the job if none did.
+func verificationResult(job *batchv1.Job, pod *corev1.Pod, failed bool) rkev1.ETCDSnapshotVerificationStatus {
+	result := rkev1.ETCDSnapshotVerificationStatus{
+		Phase: rkev1.ETCDSnapshotVerificationVerified,
+	}
+	if failed {
+		result.Phase = rkev1.ETCDSnapshotVerificationFailed
+	}
+
+	if pod == nil {
+		if failed {
+			result.Message = jobFailedMessage(job)
+		}
+		return result
+	}
+
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		terminated := status.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		message := strings.TrimSpace(terminated.Message)
+		if terminated.ExitCode != 0 && status.Name != etcdContainer {
+			result.Phase = rkev1.ETCDSnapshotVerificationFailed
+			if result.Message == "" {
+				result.Message = fmt.Sprintf("%s: %s", status.Name, message)
+			}
+			continue
+		}
+
+		switch status.Name {
+		case fetchContainer:
+			var fetch fetchResult
+			if err := json.Unmarshal([]byte(message), &fetch); err == nil {
+				result.Size = fetch.Size
+			}
+		case verifyContainer:
+			var verify verifyResult
+			if err := json.Unmarshal([]byte(message), &verify); err != nil {
+				result.Phase = rkev1.ETCDSnapshotVerificationFailed
+				result.Message = fmt.Sprintf("%s: invalid result %q", status.Name, message)
+				continue
+			}
+			result.KeyCount = verify.KeyCount
+			result.Revision = verify.Revision
+		}
+	}
+
+	if result.Phase == rkev1.ETCDSnapshotVerificationFailed && result.Message == "" {
+		result.Message = jobFailedMessage(job)
+	}
+	return result
+}
+
+func jobFailedMessage(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return strings.TrimSpace(condition.Reason + ": " + condition.Message)
+		}
+	}
+	return "verification job failed"
+}
@@ -0,0 +1,181 @@
+package snapshotverification
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVerificationObjects(t *testing.T) {
+	snapshot := &rkev1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test-etcd-snapshot-s3", UID: "uid"},
+		Spec:       rkev1.ETCDSnapshotSpec{ClusterName: "test"},
+		SnapshotFile: rkev1.ETCDSnapshotFile{
+			Location: "s3://snapshots/test/etcd-snapshot-1.zip",
+			Size:     1024,
+			S3:       &rkev1.ETCDSnapshotS3{Bucket: "snapshots", Folder: "test", EndpointCA: "/var/lib/rancher/rke2/server/s3-endpoint-ca.crt"},
+			Status:   "successful",
+		},
+	}
+	controlPlane := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			ClusterConfiguration: rkev1.ClusterConfiguration{
+				ETCD: &rkev1.ETCD{
+					S3: &rkev1.ETCDSnapshotS3{EndpointCA: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0t"},
+				},
+			},
+		},
+	}
+	s3Config := planner.S3Config{
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{Endpoint: "http://minio:9000", Bucket: "snapshots", EndpointCA: snapshot.SnapshotFile.S3.EndpointCA},
+		AccessKey:      "access",
+		SecretKey:      "secret",
+	}
+
+	secret, job, err := verificationObjects(snapshot, controlPlane, &rkev1.ETCDSnapshotVerification{}, s3Config)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"AWS_ACCESS_KEY_ID":     "access",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"S3_ENDPOINT":           "http://minio:9000",
+		"S3_REGION":             "us-east-1",
+		"S3_BUCKET":             "snapshots",
+		"S3_KEY":                "test/etcd-snapshot-1.zip",
+		"S3_SKIP_SSL_VERIFY":    "false",
+		"EXPECTED_SIZE":         "1024",
+		"ca.crt":                "-----BEGIN CERTIFICATE-----",
+	}, secret.StringData)
+
+	assert.Equal(t, "test-etcd-snapshot-s3-verify", job.Name)
+	assert.Equal(t, secret.Name, job.Name)
+	assert.Equal(t, "test", job.Labels[capr.ClusterNameLabel])
+	assert.Equal(t, "ETCDSnapshot", job.OwnerReferences[0].Kind)
+	assert.Equal(t, int64(defaultTimeoutSeconds), *job.Spec.ActiveDeadlineSeconds)
+
+	podSpec := job.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 3)
+	assert.Equal(t, "rancher/mirrored-coreos-etcd:v3.5.21", podSpec.InitContainers[1].Image)
+	assert.True(t, *podSpec.SecurityContext.RunAsNonRoot)
+	assert.False(t, *podSpec.AutomountServiceAccountToken)
+	for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+		assert.False(t, *container.SecurityContext.AllowPrivilegeEscalation, container.Name)
+		assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem, container.Name)
+	}
+	assert.Equal(t, corev1.ContainerRestartPolicyAlways, *podSpec.InitContainers[2].RestartPolicy)
+
+	require.NoError(t, settings.SystemDefaultRegistry.Set("registry.example.com"))
+	defer settings.SystemDefaultRegistry.Set("")
+	_, job, err = verificationObjects(snapshot, controlPlane, &rkev1.ETCDSnapshotVerification{}, s3Config)
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/rancher/mirrored-coreos-etcd:v3.5.21", job.Spec.Template.Spec.InitContainers[1].Image)
+	assert.Equal(t, "registry.example.com/rancher/mirrored-coreos-etcd:v3.5.21", job.Spec.Template.Spec.InitContainers[2].Image)
+
+	_, job, err = verificationObjects(snapshot, controlPlane, &rkev1.ETCDSnapshotVerification{TimeoutSeconds: 60}, s3Config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), *job.Spec.ActiveDeadlineSeconds)
+
+	snapshot.SnapshotFile.Location = "file:///var/lib/rancher/rke2/server/db/snapshots/etcd-snapshot-1"
+	_, _, err = verificationObjects(snapshot, controlPlane, &rkev1.ETCDSnapshotVerification{}, s3Config)
+	assert.Error(t, err)
+}
+
+func TestVerificationResult(t *testing.T) {
+	terminated := func(name string, exitCode int32, message string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name:  name,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message}},
+		}
+	}
+	deadlineExceeded := &batchv1.Job{
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type:    batchv1.JobFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "DeadlineExceeded",
+			Message: "Job was active longer than specified deadline",
+		}}},
+	}
+
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		pod      *corev1.Pod
+		failed   bool
+		expected rkev1.ETCDSnapshotVerificationStatus
+	}{
+		{
+			name: "verified",
+			job:  &batchv1.Job{},
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					terminated(fetchContainer, 0, `{"size":1024}`),
+					terminated(restoreContainer, 0, ""),
+					terminated(etcdContainer, 143, "terminated"),
+				},
+				ContainerStatuses: []corev1.ContainerStatus{
+					terminated(verifyContainer, 0, `{"keyCount":1234,"revision":5678}`),
+				},
+			}},
+			expected: rkev1.ETCDSnapshotVerificationStatus{
+				Phase:    rkev1.ETCDSnapshotVerificationVerified,
+				Size:     1024,
+				KeyCount: 1234,
+				Revision: 5678,
+			},
+		},
+		{
+			name: "corrupt snapshot",
+			job:  &batchv1.Job{},
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					terminated(fetchContainer, 0, `{"size":1024}`),
+					terminated(restoreContainer, 1, "Error: expected sha256 [...], got [...]\n"),
+				},
+			}},
+			failed: true,
+			expected: rkev1.ETCDSnapshotVerificationStatus{
+				Phase:   rkev1.ETCDSnapshotVerificationFailed,
+				Size:    1024,
+				Message: "restore: Error: expected sha256 [...], got [...]",
+			},
+		},
+		{
+			name: "deadline exceeded",
+			job:  deadlineExceeded,
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					terminated(fetchContainer, 0, `{"size":1024}`),
+				},
+			}},
+			failed: true,
+			expected: rkev1.ETCDSnapshotVerificationStatus{
+				Phase:   rkev1.ETCDSnapshotVerificationFailed,
+				Size:    1024,
+				Message: "DeadlineExceeded: Job was active longer than specified deadline",
+			},
+		},
+		{
+			name:   "pod removed",
+			job:    deadlineExceeded,
+			failed: true,
+			expected: rkev1.ETCDSnapshotVerificationStatus{
+				Phase:   rkev1.ETCDSnapshotVerificationFailed,
+				Message: "DeadlineExceeded: Job was active longer than specified deadline",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, verificationResult(tt.job, tt.pod, tt.failed))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package snapshotverification
+
+import (
+	"context"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	rkecontroller "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	batchcontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/batch/v1"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type handler struct {
+	etcdSnapshots     rkecontroller.ETCDSnapshotController
+	controlPlaneCache rkecontroller.RKEControlPlaneCache
+	jobs              batchcontrollers.JobClient
+	jobCache          batchcontrollers.JobCache
+	secrets           corecontrollers.SecretClient
+	secretCache       corecontrollers.SecretCache
+	podCache          corecontrollers.PodCache
+}
+
+// Register sets up the etcd snapshot verification controller. For clusters with snapshot verification enabled, each
+// successful S3 snapshot is verified once by a job that downloads it, checks its integrity and restores it into a
+// throw-away etcd. The result is recorded in the status of the etcd snapshot object, so that a snapshot that has been
+// proven restorable can be picked for a restore. Jobs of a cluster run one at a time.
+func Register(ctx context.Context, clients *wrangler.Context) {
+	h := &handler{
+		etcdSnapshots:     clients.RKE.ETCDSnapshot(),
+		controlPlaneCache: clients.RKE.RKEControlPlane().Cache(),
+		jobs:              clients.Batch.Job(),
+		jobCache:          clients.Batch.Job().Cache(),
+		secrets:           clients.Core.Secret(),
+		secretCache:       clients.Core.Secret().Cache(),
+		podCache:          clients.Core.Pod().Cache(),
+	}
+
+	clients.RKE.ETCDSnapshot().OnChange(ctx, "etcd-snapshot-verification", h.OnChange)
+	clients.Batch.Job().OnChange(ctx, "etcd-snapshot-verification-job", h.OnJobChange)
+}
+
+// OnJobChange enqueues the etcd snapshot verified by the job.
+func (h *handler) OnJobChange(_ string, job *batchv1.Job) (*batchv1.Job, error) {
+	if job == nil {
+		return job, nil
+	}
+	for _, owner := range job.OwnerReferences {
+		if owner.APIVersion == rkev1.SchemeGroupVersion.String() && owner.Kind == "ETCDSnapshot" {
+			h.etcdSnapshots.Enqueue(job.Namespace, owner.Name)
+		}
+	}
+	return job, nil
+}
+
+func (h *handler) OnChange(_ string, snapshot *rkev1.ETCDSnapshot) (*rkev1.ETCDSnapshot, error) {
+	if snapshot == nil || snapshot.DeletionTimestamp != nil || snapshot.Spec.ClusterName == "" ||
+		snapshot.SnapshotFile.S3 == nil || snapshot.SnapshotFile.Status != "successful" {
+		return snapshot, nil
+	}
+
+	// Snapshots are verified once.
+	if status := snapshot.Status.Verification; status != nil && status.Phase != rkev1.ETCDSnapshotVerificationRunning {
+		return snapshot, nil
+	}
+
+	controlPlane, err := h.controlPlaneCache.Get(snapshot.Namespace, snapshot.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		return snapshot, nil
+	} else if err != nil {
+		return snapshot, err
+	}
+	if controlPlane.Spec.ETCD == nil || controlPlane.Spec.ETCD.SnapshotVerification == nil {
+		return snapshot, nil
+	}
+
+	job, err := h.jobCache.Get(snapshot.Namespace, jobName(snapshot))
+	if apierrors.IsNotFound(err) {
+		return h.startVerification(snapshot, controlPlane)
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	if finished, failed := jobFinished(job); finished {
+		return h.completeVerification(snapshot, job, failed)
+	}
+	return snapshot, nil
+}
+
+// jobFinished returns whether the job has finished, and whether it failed.
+func jobFinished(job *batchv1.Job) (bool, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, false
+		case batchv1.JobFailed:
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// startVerification creates the verification job of the snapshot, unless a verification job of the cluster is already
+// running.
+func (h *handler) startVerification(snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane) (*rkev1.ETCDSnapshot, error) {
+	jobs, err := h.jobCache.List(snapshot.Namespace, labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: snapshot.Spec.ClusterName,
+	}))
+	if err != nil {
+		return snapshot, err
+	}
+	for _, job := range jobs {
+		if finished, _ := jobFinished(job); finished {
+			continue
+		}
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == "ETCDSnapshot" && owner.Name != snapshot.Name {
+				h.etcdSnapshots.EnqueueAfter(snapshot.Namespace, snapshot.Name, 30*time.Second)
+				return snapshot, nil
+			}
+		}
+	}
+
+	var defaultCredentialName string
+	if controlPlane.Spec.ETCD.S3 != nil {
+		defaultCredentialName = controlPlane.Spec.ETCD.S3.CloudCredentialName
+	}
+	s3Config, err := planner.ResolveS3Config(h.secretCache, controlPlane.Namespace, snapshot.SnapshotFile.S3, defaultCredentialName)
+	if err != nil {
+		return h.setVerification(snapshot, rkev1.ETCDSnapshotVerificationStatus{
+			Phase:   rkev1.ETCDSnapshotVerificationFailed,
+			Message: err.Error(),
+		})
+	}
+
+	secret, job, err := verificationObjects(snapshot, controlPlane, controlPlane.Spec.ETCD.SnapshotVerification, s3Config)
+	if err != nil {
+		return h.setVerification(snapshot, rkev1.ETCDSnapshotVerificationStatus{
+			Phase:   rkev1.ETCDSnapshotVerificationFailed,
+			Message: err.Error(),
+		})
+	}
+
+	if existing, err := h.secretCache.Get(secret.Namespace, secret.Name); apierrors.IsNotFound(err) {
+		if _, err := h.secrets.Create(secret); err != nil && !apierrors.IsAlreadyExists(err) {
+			return snapshot, err
+		}
+	} else if err != nil {
+		return snapshot, err
+	} else {
+		existing = existing.DeepCopy()
+		existing.Data = nil
+		existing.StringData = secret.StringData
+		if _, err := h.secrets.Update(existing); err != nil {
+			return snapshot, err
+		}
+	}
+	if _, err := h.jobs.Create(job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return snapshot, err
+	}
+
+	logrus.Infof("[snapshotverification] rkecluster %s/%s: verifying etcd snapshot %s", snapshot.Namespace, snapshot.Spec.ClusterName, snapshot.Name)
+	now := metav1.Now()
+	return h.setVerification(snapshot, rkev1.ETCDSnapshotVerificationStatus{
+		Phase:     rkev1.ETCDSnapshotVerificationRunning,
+		StartTime: &now,
+	})
+}
+
+// completeVerification records the result of the completed job and removes the job and its secret.
+func (h *handler) completeVerification(snapshot *rkev1.ETCDSnapshot, job *batchv1.Job, failed bool) (*rkev1.ETCDSnapshot, error) {
+	var pod *corev1.Pod
+	if job.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+		if err != nil {
+			return snapshot, err
+		}
+		pods, err := h.podCache.List(job.Namespace, selector)
+		if err != nil {
+			return snapshot, err
+		}
+		for _, p := range pods {
+			if pod == nil || p.CreationTimestamp.After(pod.CreationTimestamp.Time) {
+				pod = p
+			}
+		}
+	}
+
+	result := verificationResult(job, pod, failed)
+	if snapshot.Status.Verification != nil {
+		result.StartTime = snapshot.Status.Verification.StartTime
+	}
+	now := metav1.Now()
+	result.CompletionTime = &now
+
+	snapshot, err := h.setVerification(snapshot, result)
+	if err != nil {
+		return snapshot, err
+	}
+
+	logrus.Infof("[snapshotverification] rkecluster %s/%s: verification of etcd snapshot %s completed: %s %s", snapshot.Namespace, snapshot.Spec.ClusterName, snapshot.Name, result.Phase, result.Message)
+	propagation := metav1.DeletePropagationBackground
+	if err := h.jobs.Delete(job.Namespace, job.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+		return snapshot, err
+	}
+	if err := h.secrets.Delete(job.Namespace, job.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+func (h *handler) setVerification(snapshot *rkev1.ETCDSnapshot, status rkev1.ETCDSnapshotVerificationStatus) (*rkev1.ETCDSnapshot, error) {
+	snapshot = snapshot.DeepCopy()
+	snapshot.Status.Verification = &status
+	return h.etcdSnapshots.UpdateStatus(snapshot)
+}
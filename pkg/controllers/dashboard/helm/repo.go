@@ -16,6 +16,7 @@
 	"github.com/rancher/rancher/pkg/catalogv2"
 	"github.com/rancher/rancher/pkg/catalogv2/git"
 	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	namespaces "github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/wrangler/v3/pkg/apply"
@@ -50,6 +51,7 @@ type repoHandler struct {
 	configMaps     corev1controllers.ConfigMapClient
 	configMapCache corev1controllers.ConfigMapCache
 	apply          apply.Apply
+	verifications  *verify.Cache
 }
 
 func RegisterRepos(ctx context.Context,
@@ -64,6 +66,7 @@ func RegisterRepos(ctx context.Context,
 		configMaps:     configMap,
 		configMapCache: configMapCache,
 		apply:          apply.WithCacheTypes(configMap).WithStrictCaching().WithSetOwnerReference(false, false),
+		verifications:  verify.NewCache(),
 	}
 
 	clusterRepos.OnChange(ctx, "helm-clusterrepo-download-on-change", h.ClusterRepoOnChange)
@@ -311,6 +314,7 @@ func (r *repoHandler) download(repository *catalog.ClusterRepo, newStatus *catal
 		return setErrorCondition(repository, err, newStatus, interval, repoCondition, r.clusterRepos)
 	}
 
+	r.verifyIndex(repository, newStatus, secret, index, owner)
 	index.SortEntries()
 	cm, err := createOrUpdateMap(metadata.Namespace, index, owner, r.apply)
 	if err != nil {
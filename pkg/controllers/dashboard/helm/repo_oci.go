@@ -19,6 +19,7 @@
 	"github.com/rancher/rancher/pkg/catalogv2/oci"
 	"github.com/rancher/rancher/pkg/catalogv2/oci/capturewindowclient"
 	"github.com/rancher/rancher/pkg/catalogv2/roundtripper"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
 	catalogcontrollers "github.com/rancher/rancher/pkg/generated/controllers/catalog.cattle.io/v1"
 	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	"github.com/rancher/wrangler/v3/pkg/apply"
@@ -51,6 +52,7 @@ type OCIRepohandler struct {
 	configMapController   corev1controllers.ConfigMapController
 	secretCacheController corev1controllers.SecretCache
 	apply                 apply.Apply
+	verifications         *verify.Cache
 }
 
 type retryPolicy struct {
@@ -75,6 +77,7 @@ func RegisterOCIRepo(ctx context.Context,
 		configMapController:   configMapController,
 		secretCacheController: secretsController,
 		apply:                 apply.WithCacheTypes(configMapController).WithStrictCaching().WithSetOwnerReference(false, false),
+		verifications:         verify.NewCache(),
 	}
 
 	clusterRepoController.OnChange(ctx, "oci-clusterrepo-helm", ociRepoHandler.onClusterRepoChange)
@@ -215,6 +218,7 @@ func (o *OCIRepohandler) onClusterRepoChange(key string, clusterRepo *catalog.Cl
 		return setErrorCondition(clusterRepo, err, newStatus, ociInterval, ociCondition, o.clusterRepoController)
 	}
 
+	o.verifyIndex(clusterRepo, newStatus, secret, index)
 	newIndexBytes, err := json.Marshal(index)
 	if err != nil {
 		logrus.Errorf("Error while marshalling indexfile for cluster repo %s: %v", clusterRepo.Name, err)
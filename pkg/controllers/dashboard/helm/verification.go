@@ -0,0 +1,100 @@
+package helm
+
+import (
+	"errors"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	helmhttp "github.com/rancher/rancher/pkg/catalogv2/http"
+	"github.com/rancher/rancher/pkg/catalogv2/oci"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// verifyIndex verifies the chart versions of the index of an HTTP or Git Helm repository against
+// its verification policy. The results are recorded in the index and summarized in the status.
+func (r *repoHandler) verifyIndex(repository *catalog.ClusterRepo, status *catalog.RepoStatus, secret *corev1.Secret, index *repo.IndexFile, owner metav1.OwnerReference) {
+	policy := repository.Spec.Verification
+	if policy == nil {
+		setVerificationStatus(status, nil, index, nil)
+		return
+	}
+	if repository.Spec.GitRepo != "" {
+		setVerificationStatus(status, policy, index, verify.ErrGitUnsupported)
+		return
+	}
+
+	verifier, err := verify.New(r.secrets, policy)
+	if err != nil {
+		setVerificationStatus(status, policy, index, err)
+		return
+	}
+
+	// Reuse the results of the previous index, unless the policy changed since.
+	if repository.Status.ObservedGeneration == repository.Generation {
+		previous, err := getIndexfile(repository.Status, repository.Spec, r.configMaps, owner, repository.Namespace)
+		if err != nil {
+			logrus.Debugf("failed to get the previous index of cluster repo %s: %v", repository.Name, err)
+		} else {
+			verify.Reuse(index, previous)
+		}
+	}
+
+	spec := repository.Spec
+	results := r.verifications.Repository(repository.Name, repository.Generation)
+	err = helmhttp.VerifyIndex(secret, spec.URL, spec.CABundle, spec.InsecureSkipTLSverify, spec.DisableSameOriginCheck, index, verifier, results)
+	setVerificationStatus(status, policy, index, err)
+}
+
+// verifyIndex verifies the chart versions of the index of an OCI Helm repository against its
+// verification policy. The results are recorded in the index and summarized in the status.
+// Chart versions carried over from the previous index keep their results.
+func (o *OCIRepohandler) verifyIndex(clusterRepo *catalog.ClusterRepo, status *catalog.RepoStatus, secret *corev1.Secret, index *repo.IndexFile) {
+	policy := clusterRepo.Spec.Verification
+	// Drop the results of the previous index if the policy changed since.
+	if policy == nil || clusterRepo.Status.ObservedGeneration != clusterRepo.Generation {
+		verify.Reset(index)
+	}
+	if policy == nil {
+		setVerificationStatus(status, nil, index, nil)
+		return
+	}
+
+	verifier, err := verify.New(o.secretCacheController, policy)
+	if err != nil {
+		setVerificationStatus(status, policy, index, err)
+		return
+	}
+
+	results := o.verifications.Repository(clusterRepo.Name, clusterRepo.Generation)
+	oci.VerifyIndex(secret, index, clusterRepo.Spec, verifier, results)
+	setVerificationStatus(status, policy, index, nil)
+}
+
+// setVerificationStatus summarizes the verification results recorded in the index into the status.
+// err is an error that prevented the charts from being verified at all.
+func setVerificationStatus(status *catalog.RepoStatus, policy *catalog.VerificationPolicy, index *repo.IndexFile, err error) {
+	if policy == nil {
+		status.Verification = nil
+		for i, cond := range status.Conditions {
+			if cond.Type == string(catalog.ChartsVerified) {
+				status.Conditions = append(status.Conditions[:i], status.Conditions[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	if err != nil {
+		status.Verification = &catalog.VerificationStatus{Message: err.Error()}
+	} else {
+		status.Verification = verify.Summarize(index)
+		if status.Verification.Failed > 0 {
+			err = errors.New(status.Verification.Message)
+		}
+	}
+	condition.Cond(catalog.ChartsVerified).SetError(status, "", err)
+}
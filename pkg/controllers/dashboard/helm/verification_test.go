@@ -0,0 +1,60 @@
+package helm
+
+import (
+	"errors"
+	"testing"
+
+	catalog "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/verify"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestSetVerificationStatus(t *testing.T) {
+	cond := condition.Cond(catalog.ChartsVerified)
+	newIndex := func(errs ...error) *repo.IndexFile {
+		index := repo.NewIndexFile()
+		for _, err := range errs {
+			chartVersion := &repo.ChartVersion{Metadata: &chart.Metadata{Name: "foo", Version: "1.0.0"}}
+			verify.Annotate(chartVersion, err)
+			index.Entries["foo"] = append(index.Entries["foo"], chartVersion)
+		}
+		return index
+	}
+	policy := &catalog.VerificationPolicy{}
+
+	t.Run("all verified", func(t *testing.T) {
+		status := &catalog.RepoStatus{}
+		setVerificationStatus(status, policy, newIndex(nil, nil), nil)
+		assert.Equal(t, &catalog.VerificationStatus{Verified: 2}, status.Verification)
+		assert.True(t, cond.IsTrue(status))
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		status := &catalog.RepoStatus{}
+		setVerificationStatus(status, policy, newIndex(nil, errors.New("bad signature")), nil)
+		assert.Equal(t, 1, status.Verification.Failed)
+		assert.True(t, cond.IsFalse(status))
+		assert.Equal(t, "1 chart versions failed verification: foo-1.0.0: bad signature", cond.GetMessage(status))
+	})
+
+	t.Run("charts can't be verified", func(t *testing.T) {
+		status := &catalog.RepoStatus{}
+		setVerificationStatus(status, policy, newIndex(), verify.ErrGitUnsupported)
+		assert.Equal(t, &catalog.VerificationStatus{Message: verify.ErrGitUnsupported.Error()}, status.Verification)
+		assert.True(t, cond.IsFalse(status))
+	})
+
+	t.Run("policy removed", func(t *testing.T) {
+		status := &catalog.RepoStatus{}
+		setVerificationStatus(status, policy, newIndex(nil), nil)
+		condition.Cond(catalog.RepoDownloaded).True(status)
+
+		setVerificationStatus(status, nil, newIndex(), nil)
+		assert.Nil(t, status.Verification)
+		assert.Len(t, status.Conditions, 1)
+		assert.Equal(t, string(catalog.RepoDownloaded), status.Conditions[0].Type)
+	})
+}
@@ -6,6 +6,7 @@
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -20,6 +21,7 @@ func Register(settingController managementcontrollers.SettingController) error {
 	sp := &settingsProvider{
 		settings:     settingController,
 		settingCache: settingController.Cache(),
+		now:          time.Now,
 	}
 
 	return settings.SetProvider(sp)
@@ -29,6 +31,8 @@ type settingsProvider struct {
 	settings     managementcontrollers.SettingClient
 	settingCache managementcontrollers.SettingCache
 	fallback     map[string]string
+	// now is used to timestamp revisions, it is overridden in tests.
+	now func() time.Time
 }
 
 func (s *settingsProvider) Get(name string) string {
@@ -58,12 +62,15 @@ func (s *settingsProvider) Set(name, value string) error {
 	if envValue != "" {
 		return fmt.Errorf("setting %s can not be set because it is from environment variable", name)
 	}
+	if err := settings.Validate(name, value); err != nil {
+		return err
+	}
 	obj, err := s.settings.Get(name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	obj.Value = value
+	s.setValue(obj, value)
 	_, err = s.settings.Update(obj)
 	return err
 }
@@ -77,12 +84,21 @@ func (s *settingsProvider) SetIfUnset(name, value string) error {
 	if obj.Value != "" {
 		return nil
 	}
+	if err := settings.Validate(name, value); err != nil {
+		return err
+	}
 
-	obj.Value = value
+	s.setValue(obj, value)
 	_, err = s.settings.Update(obj)
 	return err
 }
 
+// setValue sets the value of obj and records the change in its history as made by Rancher itself.
+func (s *settingsProvider) setValue(obj *v3.Setting, value string) {
+	obj.History = settings.AppendRevision(obj.History, obj.Value, value, "", s.now())
+	obj.Value = value
+}
+
 // SetAll iterates through a map of settings.Setting and updates corresponding settings in k8s
 // to match any values set for them via their respective CATTLE_<setting-name> env var, their
 // source to "env" if configured by an env var, and their default to match the setting in the map.
@@ -97,6 +113,12 @@ func (s *settingsProvider) SetAll(settingsMap map[string]settings.Setting) error
 	for name, setting := range settingsMap {
 		key := settings.GetEnvKey(name)
 		envValue, envOk := os.LookupEnv(key)
+		if envOk {
+			if err := setting.Validate(envValue); err != nil {
+				logrus.Warnf("Invalid value for environment variable %s: %v", key, err)
+			}
+		}
+		settingType := string(setting.GetType())
 
 		obj, err := s.settings.Get(setting.Name, metav1.GetOptions{})
 		if apierrors.IsNotFound(err) {
@@ -105,6 +127,7 @@ func (s *settingsProvider) SetAll(settingsMap map[string]settings.Setting) error
 					Name: setting.Name,
 				},
 				Default: setting.Default,
+				Type:    settingType,
 			}
 			if anyInstalled && setting.DefaultOnUpgrade != "" {
 				newSetting.Default = setting.DefaultOnUpgrade
@@ -136,6 +159,10 @@ func (s *settingsProvider) SetAll(settingsMap map[string]settings.Setting) error
 				}
 				update = true
 			}
+			if obj.Type != settingType {
+				obj.Type = settingType
+				update = true
+			}
 			if envOk && obj.Source != "env" {
 				obj.Source = "env"
 				update = true
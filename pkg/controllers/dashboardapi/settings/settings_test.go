@@ -5,6 +5,7 @@
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
@@ -433,6 +434,45 @@ func TestSetAllWithDefaultOnUpgrade(t *testing.T) {
 	})
 }
 
+func TestSetRecordsHistory(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	settings.NewSetting("test-history-int", "5").WithRange(1, 10)
+
+	client := fake.NewMockNonNamespacedControllerInterface[*v3.Setting, *v3.SettingList](gomock.NewController(t))
+	provider := settingsProvider{
+		settings: client,
+		now:      func() time.Time { return now },
+	}
+	store := map[string]v3.Setting{
+		"test-history-int": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-history-int",
+			},
+			Default: "5",
+		},
+	}
+	get, set, _ := storeOperations(store)
+	client.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(get).AnyTimes()
+	client.EXPECT().Update(gomock.Any()).DoAndReturn(set).AnyTimes()
+
+	require.NoError(t, provider.SetIfUnset("test-history-int", "3"))
+	require.NoError(t, provider.Set("test-history-int", "7"))
+	require.NoError(t, provider.Set("test-history-int", "7"))
+
+	err := provider.Set("test-history-int", "11")
+	require.Error(t, err)
+	err = provider.SetIfUnset("test-history-int", "11")
+	require.NoError(t, err) // The value is already set.
+
+	s, err := get("test-history-int", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "7", s.Value)
+	assert.Equal(t, []v3.SettingRevision{
+		{Revision: 1, OldValue: "", NewValue: "3", Time: metav1.NewTime(now)},
+		{Revision: 2, OldValue: "3", NewValue: "7", Time: metav1.NewTime(now)},
+	}, s.History)
+}
+
 func storeOperations(store map[string]v3.Setting) (get, set, list) {
 	get := func(name string, opts metav1.GetOptions) (*v3.Setting, error) {
 		val, ok := store[name]
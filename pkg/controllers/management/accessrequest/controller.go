@@ -0,0 +1,367 @@
+// Package accessrequest grants the access requested by approved AccessRequests and revokes it at expiry.
+package accessrequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/controllers"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/wrangler"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/name"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	controllerName = "access-request"
+
+	// AccessRequestLabel is set on the bindings created for an access request, to the name of the request.
+	AccessRequestLabel = "management.cattle.io/access-request"
+
+	// eventNamespace is the namespace of the events recorded for access requests, which are not namespaced.
+	eventNamespace = "default"
+)
+
+var (
+	// ErrNotPending is returned when reviewing a request that is not pending.
+	ErrNotPending = errors.New("access request is not pending")
+	// ErrSelfReview is returned when a user reviews their own request.
+	ErrSelfReview = errors.New("users can't review their own access requests")
+)
+
+type controller struct {
+	accessRequests mgmtcontrollers.AccessRequestController
+	users          mgmtcontrollers.UserCache
+	roleTemplates  mgmtcontrollers.RoleTemplateCache
+	clusters       mgmtcontrollers.ClusterCache
+	projects       mgmtcontrollers.ProjectCache
+	crtbs          mgmtcontrollers.ClusterRoleTemplateBindingClient
+	prtbs          mgmtcontrollers.ProjectRoleTemplateBindingClient
+	events         corecontrollers.EventClient
+	now            func() time.Time
+}
+
+// Register registers the controller granting and revoking the access of AccessRequests.
+func Register(ctx context.Context, wContext *wrangler.Context) {
+	c := &controller{
+		accessRequests: wContext.Mgmt.AccessRequest(),
+		users:          wContext.Mgmt.User().Cache(),
+		roleTemplates:  wContext.Mgmt.RoleTemplate().Cache(),
+		clusters:       wContext.Mgmt.Cluster().Cache(),
+		projects:       wContext.Mgmt.Project().Cache(),
+		crtbs:          wContext.Mgmt.ClusterRoleTemplateBinding(),
+		prtbs:          wContext.Mgmt.ProjectRoleTemplateBinding(),
+		events:         wContext.Core.Event(),
+		now:            time.Now,
+	}
+	wContext.Mgmt.AccessRequest().OnChange(ctx, controllerName, c.onChange)
+}
+
+// onChange moves a request through its phases. New requests are validated and become pending until they are
+// reviewed, approved requests get their binding created and active requests have their binding removed at expiry.
+// Reviews are recorded by the approve and deny actions of the request.
+func (c *controller) onChange(_ string, request *v3.AccessRequest) (*v3.AccessRequest, error) {
+	if request == nil || request.DeletionTimestamp != nil {
+		return request, nil
+	}
+
+	switch request.Status.Phase {
+	case "":
+		invalid, err := c.validate(request)
+		if err != nil {
+			return request, err
+		}
+		if invalid != "" {
+			return c.invalidate(request, invalid)
+		}
+		return c.record(request, v3.AccessRequestPending, v3.AccessRequestEvent{
+			Type:    v3.AccessRequestRequestedEvent,
+			User:    request.Spec.UserName,
+			Message: fmt.Sprintf("requested %s for %s: %s", request.Spec.RoleTemplateName, target(request), request.Spec.Justification),
+		})
+	case v3.AccessRequestApproved:
+		return c.grant(request)
+	case v3.AccessRequestActive:
+		return c.expire(request)
+	}
+
+	return request, nil
+}
+
+// validate checks that the requested access can be granted. It returns the reason it can't, or an error if that
+// could not be determined.
+func (c *controller) validate(request *v3.AccessRequest) (string, error) {
+	spec := request.Spec
+	if (spec.ClusterName == "") == (spec.ProjectName == "") {
+		return "exactly one of clusterName and projectName must be set", nil
+	}
+	if spec.Duration.Duration <= 0 {
+		return "duration must be positive", nil
+	}
+	if maxDuration := settings.AccessRequestMaxDuration.GetDuration(); maxDuration > 0 && spec.Duration.Duration > maxDuration {
+		return fmt.Sprintf("duration %s exceeds the maximum of %s", spec.Duration.Duration, maxDuration), nil
+	}
+
+	if _, err := c.users.Get(spec.UserName); apierrors.IsNotFound(err) {
+		return fmt.Sprintf("user %s does not exist", spec.UserName), nil
+	} else if err != nil {
+		return "", err
+	}
+
+	roleTemplate, err := c.roleTemplates.Get(spec.RoleTemplateName)
+	if apierrors.IsNotFound(err) {
+		return fmt.Sprintf("role template %s does not exist", spec.RoleTemplateName), nil
+	} else if err != nil {
+		return "", err
+	}
+	if roleTemplate.Locked {
+		return fmt.Sprintf("role template %s is locked", spec.RoleTemplateName), nil
+	}
+
+	if spec.ClusterName != "" {
+		if roleTemplate.Context != "cluster" {
+			return fmt.Sprintf("role template %s can't be granted on a cluster", spec.RoleTemplateName), nil
+		}
+		if _, err := c.clusters.Get(spec.ClusterName); apierrors.IsNotFound(err) {
+			return fmt.Sprintf("cluster %s does not exist", spec.ClusterName), nil
+		} else if err != nil {
+			return "", err
+		}
+		return "", nil
+	}
+
+	if roleTemplate.Context != "project" {
+		return fmt.Sprintf("role template %s can't be granted on a project", spec.RoleTemplateName), nil
+	}
+	clusterName, projectName, ok := strings.Cut(spec.ProjectName, ":")
+	if !ok || clusterName == "" || projectName == "" {
+		return fmt.Sprintf("project name %s is not in the format clusterName:projectName", spec.ProjectName), nil
+	}
+	if _, err := c.projects.Get(clusterName, projectName); apierrors.IsNotFound(err) {
+		return fmt.Sprintf("project %s does not exist", spec.ProjectName), nil
+	} else if err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// grant creates the binding of an approved request. The request is validated again, since the role template,
+// cluster or project could have changed while the request was waiting for its review.
+func (c *controller) grant(request *v3.AccessRequest) (*v3.AccessRequest, error) {
+	invalid, err := c.validate(request)
+	if err != nil {
+		return request, err
+	}
+	if invalid != "" {
+		return c.invalidate(request, invalid)
+	}
+
+	var project *v3.Project
+	if request.Spec.ProjectName != "" {
+		clusterName, projectName, _ := strings.Cut(request.Spec.ProjectName, ":")
+		if project, err = c.projects.Get(clusterName, projectName); err != nil {
+			return request, err
+		}
+	}
+	var meta metav1.ObjectMeta
+	switch binding := NewBinding(request, project).(type) {
+	case *v3.ClusterRoleTemplateBinding:
+		meta = binding.ObjectMeta
+		_, err = c.crtbs.Create(binding)
+	case *v3.ProjectRoleTemplateBinding:
+		meta = binding.ObjectMeta
+		_, err = c.prtbs.Create(binding)
+	}
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return request, fmt.Errorf("failed to create binding for access request %s: %w", request.Name, err)
+	}
+
+	expiration := metav1.NewTime(c.now().Add(request.Spec.Duration.Duration))
+	request = request.DeepCopy()
+	request.Status.ExpirationTime = &expiration
+	request.Status.BindingNamespace = meta.Namespace
+	request.Status.BindingName = meta.Name
+	request, err = c.record(request, v3.AccessRequestActive, v3.AccessRequestEvent{
+		Type:    v3.AccessRequestGrantedEvent,
+		Message: fmt.Sprintf("granted %s for %s until %s", request.Spec.RoleTemplateName, target(request), expiration.UTC().Format(time.RFC3339)),
+	})
+	if err != nil {
+		return request, err
+	}
+
+	c.accessRequests.EnqueueAfter(request.Name, request.Spec.Duration.Duration)
+	return request, nil
+}
+
+// NewBinding returns the binding granting the access of a request, a ClusterRoleTemplateBinding for cluster requests
+// and a ProjectRoleTemplateBinding for project requests. project is the requested project, or nil for cluster requests.
+func NewBinding(request *v3.AccessRequest, project *v3.Project) runtime.Object {
+	meta := metav1.ObjectMeta{
+		Name: name.SafeConcatName("access-request", request.Name),
+		Labels: map[string]string{
+			AccessRequestLabel:          request.Name,
+			controllers.K8sManagedByKey: controllers.ManagerValue,
+		},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: v3.SchemeGroupVersion.String(),
+			Kind:       "AccessRequest",
+			Name:       request.Name,
+			UID:        request.UID,
+		}},
+	}
+	if project == nil {
+		meta.Namespace = request.Spec.ClusterName
+		return &v3.ClusterRoleTemplateBinding{
+			TypeMeta:         metav1.TypeMeta{APIVersion: v3.SchemeGroupVersion.String(), Kind: "ClusterRoleTemplateBinding"},
+			ObjectMeta:       meta,
+			ClusterName:      request.Spec.ClusterName,
+			RoleTemplateName: request.Spec.RoleTemplateName,
+			UserName:         request.Spec.UserName,
+		}
+	}
+	meta.Namespace = project.GetProjectBackingNamespace()
+	return &v3.ProjectRoleTemplateBinding{
+		TypeMeta:         metav1.TypeMeta{APIVersion: v3.SchemeGroupVersion.String(), Kind: "ProjectRoleTemplateBinding"},
+		ObjectMeta:       meta,
+		ProjectName:      request.Spec.ProjectName,
+		RoleTemplateName: request.Spec.RoleTemplateName,
+		UserName:         request.Spec.UserName,
+	}
+}
+
+// expire removes the binding of an active request once it has expired.
+func (c *controller) expire(request *v3.AccessRequest) (*v3.AccessRequest, error) {
+	if request.Status.ExpirationTime != nil {
+		if remaining := request.Status.ExpirationTime.Sub(c.now()); remaining > 0 {
+			c.accessRequests.EnqueueAfter(request.Name, remaining)
+			return request, nil
+		}
+	}
+
+	var err error
+	if request.Status.BindingName != "" {
+		if request.Spec.ClusterName != "" {
+			err = c.crtbs.Delete(request.Status.BindingNamespace, request.Status.BindingName, &metav1.DeleteOptions{})
+		} else {
+			err = c.prtbs.Delete(request.Status.BindingNamespace, request.Status.BindingName, &metav1.DeleteOptions{})
+		}
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return request, fmt.Errorf("failed to remove binding of access request %s: %w", request.Name, err)
+	}
+
+	return c.record(request, v3.AccessRequestExpired, v3.AccessRequestEvent{
+		Type:    v3.AccessRequestExpiredEvent,
+		Message: fmt.Sprintf("revoked %s for %s", request.Spec.RoleTemplateName, target(request)),
+	})
+}
+
+// Review records the review of a pending request by the given user. Users can't review their own requests.
+func Review(request *v3.AccessRequest, reviewer string, approve bool, comment string, now time.Time) (*v3.AccessRequest, v3.AccessRequestEvent, error) {
+	if request.Status.Phase != v3.AccessRequestPending {
+		return nil, v3.AccessRequestEvent{}, fmt.Errorf("%w: access request %s is %s", ErrNotPending, request.Name, strings.ToLower(string(request.Status.Phase)))
+	}
+	if reviewer == request.Spec.UserName {
+		return nil, v3.AccessRequestEvent{}, ErrSelfReview
+	}
+
+	step := v3.AccessRequestEvent{
+		Type:    v3.AccessRequestApprovedEvent,
+		Time:    metav1.NewTime(now),
+		User:    reviewer,
+		Message: "approved the request",
+	}
+	phase := v3.AccessRequestApproved
+	if !approve {
+		step.Type = v3.AccessRequestDeniedEvent
+		step.Message = "denied the request"
+		phase = v3.AccessRequestDenied
+	}
+	if comment != "" {
+		step.Message += ": " + comment
+	}
+
+	request = request.DeepCopy()
+	request.Status.Phase = phase
+	request.Status.Reviewer = reviewer
+	request.Status.ReviewTime = &step.Time
+	request.Status.ReviewComment = comment
+	request.Status.History = append(request.Status.History, step)
+	return request, step, nil
+}
+
+func (c *controller) invalidate(request *v3.AccessRequest, message string) (*v3.AccessRequest, error) {
+	request = request.DeepCopy()
+	request.Status.Message = message
+	return c.record(request, v3.AccessRequestInvalid, v3.AccessRequestEvent{
+		Type:    v3.AccessRequestInvalidEvent,
+		Message: message,
+	})
+}
+
+// record moves the request to the given phase and appends the step to its history. The step is also logged and
+// recorded as an event, so that it is kept after the request is deleted.
+func (c *controller) record(request *v3.AccessRequest, phase v3.AccessRequestPhase, step v3.AccessRequestEvent) (*v3.AccessRequest, error) {
+	step.Time = metav1.NewTime(c.now())
+	request = request.DeepCopy()
+	request.Status.Phase = phase
+	request.Status.History = append(request.Status.History, step)
+	updated, err := c.accessRequests.UpdateStatus(request)
+	if err != nil {
+		return request, err
+	}
+
+	logrus.Infof("[%s] AccessRequest %s for user %s: %s", controllerName, request.Name, request.Spec.UserName, step.Message)
+	if _, err := c.events.Create(NewEvent(updated, step)); err != nil {
+		logrus.Errorf("[%s] Failed to record event for AccessRequest %s: %v", controllerName, request.Name, err)
+	}
+	return updated, nil
+}
+
+// NewEvent returns the event recording a step of the request.
+func NewEvent(request *v3.AccessRequest, step v3.AccessRequestEvent) *corev1.Event {
+	eventType := corev1.EventTypeNormal
+	if step.Type == v3.AccessRequestInvalidEvent {
+		eventType = corev1.EventTypeWarning
+	}
+	message := step.Message
+	if step.User != "" {
+		message = step.User + " " + message
+	}
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: request.Name + ".",
+			Namespace:    eventNamespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: v3.SchemeGroupVersion.String(),
+			Kind:       "AccessRequest",
+			Name:       request.Name,
+			UID:        request.UID,
+		},
+		Reason:         "AccessRequest" + step.Type,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "rancher"},
+		FirstTimestamp: step.Time,
+		LastTimestamp:  step.Time,
+		Count:          1,
+	}
+}
+
+func target(request *v3.AccessRequest) string {
+	if request.Spec.ClusterName != "" {
+		return "cluster " + request.Spec.ClusterName
+	}
+	return "project " + request.Spec.ProjectName
+}
@@ -0,0 +1,289 @@
+package accessrequest
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type mocks struct {
+	accessRequests *fake.MockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList]
+	users          *fake.MockNonNamespacedCacheInterface[*v3.User]
+	roleTemplates  *fake.MockNonNamespacedCacheInterface[*v3.RoleTemplate]
+	clusters       *fake.MockNonNamespacedCacheInterface[*v3.Cluster]
+	projects       *fake.MockCacheInterface[*v3.Project]
+	crtbs          *fake.MockClientInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList]
+	prtbs          *fake.MockClientInterface[*v3.ProjectRoleTemplateBinding, *v3.ProjectRoleTemplateBindingList]
+	events         *fake.MockClientInterface[*corev1.Event, *corev1.EventList]
+}
+
+func newController(t *testing.T, now time.Time) (*controller, *mocks) {
+	ctrl := gomock.NewController(t)
+	m := &mocks{
+		accessRequests: fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl),
+		users:          fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl),
+		roleTemplates:  fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl),
+		clusters:       fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl),
+		projects:       fake.NewMockCacheInterface[*v3.Project](ctrl),
+		crtbs:          fake.NewMockClientInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl),
+		prtbs:          fake.NewMockClientInterface[*v3.ProjectRoleTemplateBinding, *v3.ProjectRoleTemplateBindingList](ctrl),
+		events:         fake.NewMockClientInterface[*corev1.Event, *corev1.EventList](ctrl),
+	}
+	m.events.EXPECT().Create(gomock.Any()).Return(&corev1.Event{}, nil).AnyTimes()
+	m.accessRequests.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(request *v3.AccessRequest) (*v3.AccessRequest, error) {
+		return request, nil
+	}).AnyTimes()
+
+	return &controller{
+		accessRequests: m.accessRequests,
+		users:          m.users,
+		roleTemplates:  m.roleTemplates,
+		clusters:       m.clusters,
+		projects:       m.projects,
+		crtbs:          m.crtbs,
+		prtbs:          m.prtbs,
+		events:         m.events,
+		now:            func() time.Time { return now },
+	}, m
+}
+
+func newAccessRequest(phase v3.AccessRequestPhase) *v3.AccessRequest {
+	return &v3.AccessRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "ar-1", UID: "uid"},
+		Spec: v3.AccessRequestSpec{
+			UserName:         "u-oncall",
+			RoleTemplateName: "cluster-owner",
+			ClusterName:      "c-abc",
+			Duration:         metav1.Duration{Duration: 2 * time.Hour},
+			Justification:    "INC-42",
+		},
+		Status: v3.AccessRequestStatus{Phase: phase},
+	}
+}
+
+func TestOnChangeNewRequest(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	notFound := apierrors.NewNotFound(schema.GroupResource{}, "")
+
+	tests := map[string]struct {
+		modify        func(*v3.AccessRequest)
+		setupMocks    func(*mocks)
+		expectedPhase v3.AccessRequestPhase
+		expectedMsg   string
+	}{
+		"valid cluster request is pending": {
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+				m.roleTemplates.EXPECT().Get("cluster-owner").Return(&v3.RoleTemplate{Context: "cluster"}, nil)
+				m.clusters.EXPECT().Get("c-abc").Return(&v3.Cluster{}, nil)
+			},
+			expectedPhase: v3.AccessRequestPending,
+		},
+		"valid project request is pending": {
+			modify: func(request *v3.AccessRequest) {
+				request.Spec.ClusterName = ""
+				request.Spec.ProjectName = "c-abc:p-xyz"
+				request.Spec.RoleTemplateName = "project-member"
+			},
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+				m.roleTemplates.EXPECT().Get("project-member").Return(&v3.RoleTemplate{Context: "project"}, nil)
+				m.projects.EXPECT().Get("c-abc", "p-xyz").Return(&v3.Project{}, nil)
+			},
+			expectedPhase: v3.AccessRequestPending,
+		},
+		"cluster and project are exclusive": {
+			modify: func(request *v3.AccessRequest) {
+				request.Spec.ProjectName = "c-abc:p-xyz"
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "exactly one of clusterName and projectName must be set",
+		},
+		"duration exceeds the maximum": {
+			modify: func(request *v3.AccessRequest) {
+				request.Spec.Duration.Duration = 48 * time.Hour
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "duration 48h0m0s exceeds the maximum of 24h0m0s",
+		},
+		"unknown user": {
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(nil, notFound)
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "user u-oncall does not exist",
+		},
+		"locked role template": {
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+				m.roleTemplates.EXPECT().Get("cluster-owner").Return(&v3.RoleTemplate{Context: "cluster", Locked: true}, nil)
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "role template cluster-owner is locked",
+		},
+		"project role template on a cluster": {
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+				m.roleTemplates.EXPECT().Get("cluster-owner").Return(&v3.RoleTemplate{Context: "project"}, nil)
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "role template cluster-owner can't be granted on a cluster",
+		},
+		"malformed project name": {
+			modify: func(request *v3.AccessRequest) {
+				request.Spec.ClusterName = ""
+				request.Spec.ProjectName = "p-xyz"
+			},
+			setupMocks: func(m *mocks) {
+				m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+				m.roleTemplates.EXPECT().Get("cluster-owner").Return(&v3.RoleTemplate{Context: "project"}, nil)
+			},
+			expectedPhase: v3.AccessRequestInvalid,
+			expectedMsg:   "project name p-xyz is not in the format clusterName:projectName",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, m := newController(t, now)
+			if tt.setupMocks != nil {
+				tt.setupMocks(m)
+			}
+			request := newAccessRequest("")
+			if tt.modify != nil {
+				tt.modify(request)
+			}
+
+			got, err := c.onChange("", request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPhase, got.Status.Phase)
+			assert.Equal(t, tt.expectedMsg, got.Status.Message)
+			require.Len(t, got.Status.History, 1)
+			assert.Equal(t, metav1.NewTime(now), got.Status.History[0].Time)
+			if tt.expectedPhase == v3.AccessRequestPending {
+				assert.Equal(t, v3.AccessRequestRequestedEvent, got.Status.History[0].Type)
+				assert.Equal(t, "u-oncall", got.Status.History[0].User)
+			}
+		})
+	}
+}
+
+func TestOnChangeGrant(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	c, m := newController(t, now)
+	m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+	m.roleTemplates.EXPECT().Get("cluster-owner").Return(&v3.RoleTemplate{Context: "cluster"}, nil)
+	m.clusters.EXPECT().Get("c-abc").Return(&v3.Cluster{}, nil)
+	m.crtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(crtb *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
+		assert.Equal(t, "access-request-ar-1", crtb.Name)
+		assert.Equal(t, "c-abc", crtb.Namespace)
+		assert.Equal(t, "ar-1", crtb.Labels[AccessRequestLabel])
+		assert.Equal(t, "AccessRequest", crtb.OwnerReferences[0].Kind)
+		assert.Equal(t, "c-abc", crtb.ClusterName)
+		assert.Equal(t, "cluster-owner", crtb.RoleTemplateName)
+		assert.Equal(t, "u-oncall", crtb.UserName)
+		return crtb, nil
+	})
+	m.accessRequests.EXPECT().EnqueueAfter("ar-1", 2*time.Hour)
+
+	got, err := c.onChange("", newAccessRequest(v3.AccessRequestApproved))
+	require.NoError(t, err)
+	assert.Equal(t, v3.AccessRequestActive, got.Status.Phase)
+	assert.Equal(t, now.Add(2*time.Hour), got.Status.ExpirationTime.Time)
+	assert.Equal(t, "c-abc", got.Status.BindingNamespace)
+	assert.Equal(t, "access-request-ar-1", got.Status.BindingName)
+	require.Len(t, got.Status.History, 1)
+	assert.Equal(t, v3.AccessRequestGrantedEvent, got.Status.History[0].Type)
+}
+
+func TestOnChangeGrantProject(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	c, m := newController(t, now)
+	project := &v3.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c-abc", Name: "p-xyz"},
+		Status:     v3.ProjectStatus{BackingNamespace: "c-abc-p-xyz"},
+	}
+	m.users.EXPECT().Get("u-oncall").Return(&v3.User{}, nil)
+	m.roleTemplates.EXPECT().Get("project-member").Return(&v3.RoleTemplate{Context: "project"}, nil)
+	m.projects.EXPECT().Get("c-abc", "p-xyz").Return(project, nil).Times(2)
+	m.prtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(prtb *v3.ProjectRoleTemplateBinding) (*v3.ProjectRoleTemplateBinding, error) {
+		assert.Equal(t, "c-abc-p-xyz", prtb.Namespace)
+		assert.Equal(t, "c-abc:p-xyz", prtb.ProjectName)
+		return nil, apierrors.NewAlreadyExists(schema.GroupResource{}, prtb.Name)
+	})
+	m.accessRequests.EXPECT().EnqueueAfter("ar-1", 2*time.Hour)
+
+	request := newAccessRequest(v3.AccessRequestApproved)
+	request.Spec.ClusterName = ""
+	request.Spec.ProjectName = "c-abc:p-xyz"
+	request.Spec.RoleTemplateName = "project-member"
+
+	got, err := c.onChange("", request)
+	require.NoError(t, err)
+	assert.Equal(t, v3.AccessRequestActive, got.Status.Phase)
+	assert.Equal(t, "c-abc-p-xyz", got.Status.BindingNamespace)
+}
+
+func TestOnChangeExpire(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	active := func(expiration time.Time) *v3.AccessRequest {
+		request := newAccessRequest(v3.AccessRequestActive)
+		request.Status.ExpirationTime = &metav1.Time{Time: expiration}
+		request.Status.BindingNamespace = "c-abc"
+		request.Status.BindingName = "access-request-ar-1"
+		return request
+	}
+
+	t.Run("not expired yet", func(t *testing.T) {
+		c, m := newController(t, now)
+		m.accessRequests.EXPECT().EnqueueAfter("ar-1", 30*time.Minute)
+
+		got, err := c.onChange("", active(now.Add(30*time.Minute)))
+		require.NoError(t, err)
+		assert.Equal(t, v3.AccessRequestActive, got.Status.Phase)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		c, m := newController(t, now)
+		m.crtbs.EXPECT().Delete("c-abc", "access-request-ar-1", gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, ""))
+
+		got, err := c.onChange("", active(now.Add(-time.Second)))
+		require.NoError(t, err)
+		assert.Equal(t, v3.AccessRequestExpired, got.Status.Phase)
+		require.Len(t, got.Status.History, 1)
+		assert.Equal(t, v3.AccessRequestExpiredEvent, got.Status.History[0].Type)
+	})
+}
+
+func TestReview(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	request, step, err := Review(newAccessRequest(v3.AccessRequestPending), "u-lead", true, "go ahead", now)
+	require.NoError(t, err)
+	assert.Equal(t, v3.AccessRequestApproved, request.Status.Phase)
+	assert.Equal(t, "u-lead", request.Status.Reviewer)
+	assert.Equal(t, "go ahead", request.Status.ReviewComment)
+	assert.Equal(t, now, request.Status.ReviewTime.Time)
+	assert.Equal(t, []v3.AccessRequestEvent{step}, request.Status.History)
+	assert.Equal(t, "approved the request: go ahead", step.Message)
+
+	request, step, err = Review(newAccessRequest(v3.AccessRequestPending), "u-lead", false, "", now)
+	require.NoError(t, err)
+	assert.Equal(t, v3.AccessRequestDenied, request.Status.Phase)
+	assert.Equal(t, v3.AccessRequestDeniedEvent, step.Type)
+
+	_, _, err = Review(newAccessRequest(v3.AccessRequestPending), "u-oncall", true, "", now)
+	assert.ErrorIs(t, err, ErrSelfReview)
+
+	_, _, err = Review(newAccessRequest(v3.AccessRequestActive), "u-lead", true, "", now)
+	assert.ErrorIs(t, err, ErrNotPending)
+}
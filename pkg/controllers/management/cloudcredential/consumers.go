@@ -0,0 +1,185 @@
+package cloudcredential
+
+import (
+	"slices"
+	"strings"
+
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+)
+
+const (
+	// ByCloudCredentialConsumer indexes provisioning and management clusters by every cloud credential they
+	// reference, in the format <namespace>:<name>.
+	ByCloudCredentialConsumer = "cloudcredential.cattle.io/by-consumer"
+
+	usageCluster     = "cluster"
+	usageMachinePool = "machinePool:"
+	usageETCDS3      = "etcdS3"
+	usageETCDReplica = "etcdS3Replica"
+)
+
+// consumerIndex tracks the resources referencing each cloud credential.
+type consumerIndex struct {
+	provClusters provcontrollers.ClusterCache
+	mgmtClusters mgmtcontrollers.ClusterCache
+}
+
+func newConsumerIndex(provClusters provcontrollers.ClusterCache, mgmtClusters mgmtcontrollers.ClusterCache) *consumerIndex {
+	provClusters.AddIndexer(ByCloudCredentialConsumer, func(obj *provv1.Cluster) ([]string, error) {
+		return credentialsOf(provClusterConsumers(obj)), nil
+	})
+	mgmtClusters.AddIndexer(ByCloudCredentialConsumer, func(obj *apimgmtv3.Cluster) ([]string, error) {
+		return credentialsOf(mgmtClusterConsumers(obj)), nil
+	})
+	return &consumerIndex{
+		provClusters: provClusters,
+		mgmtClusters: mgmtClusters,
+	}
+}
+
+// Consumers returns the resources referencing the cloud credential, sorted by kind, namespace, name and usage.
+func (i *consumerIndex) Consumers(ns, name string) ([]Consumer, error) {
+	key := ns + ":" + name
+	var result []Consumer
+	provClusters, err := i.provClusters.GetByIndex(ByCloudCredentialConsumer, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range provClusters {
+		result = append(result, filterConsumers(provClusterConsumers(cluster), key)...)
+	}
+	mgmtClusters, err := i.mgmtClusters.GetByIndex(ByCloudCredentialConsumer, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range mgmtClusters {
+		result = append(result, filterConsumers(mgmtClusterConsumers(cluster), key)...)
+	}
+	slices.SortFunc(result, func(a, b Consumer) int {
+		return strings.Compare(a.Kind+"/"+a.Namespace+"/"+a.Name+"/"+a.Usage, b.Kind+"/"+b.Namespace+"/"+b.Name+"/"+b.Usage)
+	})
+	return result, nil
+}
+
+// consumerRef is a reference of a consumer to a cloud credential.
+type consumerRef struct {
+	Consumer
+	credential string
+}
+
+func provClusterConsumers(cluster *provv1.Cluster) []consumerRef {
+	consumer := Consumer{
+		Kind:       "Cluster",
+		APIVersion: provv1.SchemeGroupVersion.String(),
+		Namespace:  cluster.Namespace,
+		Name:       cluster.Name,
+	}
+	var refs []consumerRef
+	add := func(credential, usage string) {
+		if credential == "" {
+			return
+		}
+		c := consumer
+		c.Usage = usage
+		refs = append(refs, consumerRef{Consumer: c, credential: normalizeCredential(credential, cluster.Namespace)})
+	}
+	add(cluster.Spec.CloudCredentialSecretName, usageCluster)
+	if rkeConfig := cluster.Spec.RKEConfig; rkeConfig != nil {
+		for _, pool := range rkeConfig.MachinePools {
+			add(pool.CloudCredentialSecretName, usageMachinePool+pool.Name)
+		}
+		if etcd := rkeConfig.ETCD; etcd != nil {
+			if etcd.S3 != nil {
+				add(etcd.S3.CloudCredentialName, usageETCDS3)
+			}
+			if etcd.SnapshotPolicy != nil && etcd.SnapshotPolicy.Replica != nil {
+				add(etcd.SnapshotPolicy.Replica.CloudCredentialName, usageETCDReplica)
+			}
+		}
+	}
+	return refs
+}
+
+func mgmtClusterConsumers(cluster *apimgmtv3.Cluster) []consumerRef {
+	var credential string
+	switch {
+	case cluster.Spec.EKSConfig != nil:
+		credential = cluster.Spec.EKSConfig.AmazonCredentialSecret
+	case cluster.Spec.AKSConfig != nil:
+		credential = cluster.Spec.AKSConfig.AzureCredentialSecret
+	case cluster.Spec.GKEConfig != nil:
+		credential = cluster.Spec.GKEConfig.GoogleCredentialSecret
+	}
+	if credential == "" {
+		return nil
+	}
+	return []consumerRef{{
+		Consumer: Consumer{
+			Kind:       "Cluster",
+			APIVersion: apimgmtv3.SchemeGroupVersion.String(),
+			Name:       cluster.Name,
+			Usage:      usageCluster,
+		},
+		credential: normalizeCredential(credential, namespace.GlobalNamespace),
+	}}
+}
+
+// normalizeCredential returns the reference to a cloud credential in the format <namespace>:<name>. References
+// without a namespace are in defaultNamespace.
+func normalizeCredential(credential, defaultNamespace string) string {
+	ns, name := kv.Split(credential, ":")
+	if name == "" {
+		return defaultNamespace + ":" + ns
+	}
+	return credential
+}
+
+func credentialsOf(refs []consumerRef) []string {
+	var result []string
+	for _, ref := range refs {
+		if !slices.Contains(result, ref.credential) {
+			result = append(result, ref.credential)
+		}
+	}
+	return result
+}
+
+func filterConsumers(refs []consumerRef, credential string) []Consumer {
+	var result []Consumer
+	for _, ref := range refs {
+		if ref.credential == credential {
+			result = append(result, ref.Consumer)
+		}
+	}
+	return result
+}
+
+// syncProvClusterConsumers enqueues the cloud credentials referenced by a provisioning cluster, so their consumers are
+// updated.
+func (c *Controller) syncProvClusterConsumers(_ string, cluster *provv1.Cluster) (*provv1.Cluster, error) {
+	if cluster != nil {
+		c.enqueueCredentials(provClusterConsumers(cluster))
+	}
+	return cluster, nil
+}
+
+// syncMgmtClusterConsumers enqueues the cloud credentials referenced by a management cluster, so their consumers are
+// updated.
+func (c *Controller) syncMgmtClusterConsumers(_ string, cluster *apimgmtv3.Cluster) (*apimgmtv3.Cluster, error) {
+	if cluster != nil {
+		c.enqueueCredentials(mgmtClusterConsumers(cluster))
+	}
+	return cluster, nil
+}
+
+func (c *Controller) enqueueCredentials(refs []consumerRef) {
+	for _, credential := range credentialsOf(refs) {
+		ns, name := kv.Split(credential, ":")
+		c.secrets.Enqueue(ns, name)
+	}
+}
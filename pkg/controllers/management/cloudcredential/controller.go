@@ -7,12 +7,15 @@
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/rancher/rancher/pkg/api/norman/customization/cred"
 	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/controllers/management/rbac"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	"github.com/rancher/rancher/pkg/features"
 	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	typesv1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	"github.com/rancher/rancher/pkg/namespace"
 	"github.com/rancher/rancher/pkg/types/config"
@@ -36,6 +39,15 @@ type Controller struct {
 	secretClient      corecontrollers.SecretClient
 	tokenClient       mgmtcontrollers.TokenClient
 	apply             apply.Apply
+
+	secrets      corecontrollers.SecretController
+	secretCache  corecontrollers.SecretCache
+	events       corecontrollers.EventClient
+	provClusters provcontrollers.ClusterController
+	mgmtClusters mgmtcontrollers.ClusterController
+	consumers    *consumerIndex
+	validators   map[string]Validator
+	now          func() time.Time
 }
 
 func Register(ctx context.Context, management *config.ManagementContext, clients *wrangler.Context) {
@@ -47,8 +59,19 @@ func Register(ctx context.Context, management *config.ManagementContext, clients
 			clients.Core.Secret(),
 			clients.Mgmt.Token(),
 		),
+		secrets:      clients.Core.Secret(),
+		secretCache:  clients.Core.Secret().Cache(),
+		events:       clients.Core.Event(),
+		provClusters: clients.Provisioning.Cluster(),
+		mgmtClusters: clients.Mgmt.Cluster(),
+		consumers:    newConsumerIndex(clients.Provisioning.Cluster().Cache(), clients.Mgmt.Cluster().Cache()),
+		validators:   validators,
+		now:          time.Now,
 	}
 	management.Core.Secrets("").AddHandler(ctx, "management-cloudcredential-controller", m.ccSync)
+	clients.Core.Secret().OnChange(ctx, "cloud-credential-validation", m.syncValidation)
+	clients.Provisioning.Cluster().OnChange(ctx, "cloud-credential-consumers", m.syncProvClusterConsumers)
+	clients.Mgmt.Cluster().OnChange(ctx, "cloud-credential-consumers", m.syncMgmtClusterConsumers)
 	if features.Harvester.Enabled() {
 		clients.Core.Secret().OnChange(ctx, "harvester-cloud-credential-token", m.syncHarvesterToken)
 	}
@@ -73,9 +96,13 @@ func (c *Controller) syncHarvesterToken(key string, secret *v1.Secret) (*v1.Secr
 		return secret, nil
 	}
 
-	var err error
+	plain, err := encryptedstore.DecryptSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt harvester cloud credential secret %s: %w", key, err)
+	}
+	kubeconfigContent := plain.Data["harvestercredentialConfig-kubeconfigContent"]
 
-	d := sha256.Sum256(secret.Data["harvestercredentialConfig-kubeconfigContent"])
+	d := sha256.Sum256(kubeconfigContent)
 	checksum := hex.EncodeToString(d[:])
 
 	if secret.DeletionTimestamp == nil && secret.Annotations[harvesterCloudCredentialTokenChecksumAnnotation] == checksum {
@@ -124,7 +151,7 @@ func (c *Controller) syncHarvesterToken(key string, secret *v1.Secret) (*v1.Secr
 	// in practice a kubeconfig will only ever have one token, but we need to handle the case where users may be
 	// modifying the secret directly and properly extend/delete tokens as necessary.
 	tokenNames := make(cred.Set[string])
-	err = cred.TokenNamesFromContent(secret.Data["harvestercredentialConfig-kubeconfigContent"], tokenNames)
+	err = cred.TokenNamesFromContent(kubeconfigContent, tokenNames)
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +189,8 @@ func (c *Controller) ccSync(_ string, cloudCredential *v1.Secret) (runtime.Objec
 	if cloudCredential == nil || cloudCredential.DeletionTimestamp != nil {
 		return cloudCredential, nil
 	}
-	if !configExists(cloudCredential.Data) {
+	if !configExists(cloudCredential.Data) || cloudCredential.Labels[RotationSourceLabel] == "true" {
+		// rotation sources and backups are not cloud credentials users have access to on their own
 		return cloudCredential, nil
 	}
 	metaAccessor, err := meta.Accessor(cloudCredential)
@@ -0,0 +1,196 @@
+package cloudcredential
+
+import (
+	"fmt"
+
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+)
+
+// rotate swaps the data of a cloud credential with the data of the secret named by its RotateFromAnnotation. The new
+// data is validated before the swap, and the previous data is kept in a backup secret until the credential is
+// validated again, so it can be rolled back. All resources using the credential are re-reconciled after the swap.
+func (c *Controller) rotate(secret *v1.Secret) (*v1.Secret, error) {
+	sourceName := secret.Annotations[RotateFromAnnotation]
+	status, err := GetStatus(secret)
+	if err != nil {
+		logrus.Errorf("[cloud-credential] %v", err)
+		status = &Status{}
+	}
+	if backup, err := c.findBackup(secret); err != nil {
+		return nil, err
+	} else if backup != nil {
+		return c.rejectRotation(secret, status, "RotationInProgress", "the previous rotation of the credential is not completed yet")
+	}
+
+	source, err := c.secretCache.Get(secret.Namespace, sourceName)
+	if apierrors.IsNotFound(err) {
+		return c.rejectRotation(secret, status, "SourceNotFound", fmt.Sprintf("secret %s not found", sourceName))
+	} else if err != nil {
+		return nil, err
+	}
+	if source.Labels[RotationSourceLabel] != "true" {
+		return c.rejectRotation(secret, status, "InvalidSource", fmt.Sprintf("secret %s is missing the %s label", sourceName, RotationSourceLabel))
+	}
+	if source.Labels[BackupOfLabel] != "" || !isOwnedBy(source, secret) {
+		return c.rejectRotation(secret, status, "InvalidSource", fmt.Sprintf("secret %s is not owned by the credential", sourceName))
+	}
+
+	plain, err := encryptedstore.DecryptSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt cloud credential %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	plainSource, err := encryptedstore.DecryptSecret(source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secret %s/%s: %w", source.Namespace, source.Name, err)
+	}
+	driver, _ := credentialData(plain.Data)
+	if sourceDriver, _ := credentialData(plainSource.Data); sourceDriver != driver {
+		return c.rejectRotation(secret, status, "DriverMismatch", fmt.Sprintf("secret %s holds credentials of driver %q instead of %q", sourceName, sourceDriver, driver))
+	}
+	if condition := c.validate(plainSource.Data); condition.Status == metav1.ConditionFalse {
+		return c.rejectRotation(secret, status, "ValidationFailed", condition.Message)
+	}
+
+	consumers, err := c.consumers.Consumers(secret.Namespace, secret.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := encrypt(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: secret.Name + "-backup-",
+			Namespace:    secret.Namespace,
+			Labels: map[string]string{
+				RotationSourceLabel: "true",
+				BackupOfLabel:       string(secret.UID),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Name:       secret.Name,
+				UID:        secret.UID,
+				Controller: ptr.To(true),
+			}},
+		},
+		Type: plain.Type,
+		Data: plain.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	backup, err = c.secretClient.Create(backup)
+	if err != nil {
+		return nil, fmt.Errorf("unable to back up cloud credential %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+
+	logrus.Infof("[cloud-credential] Rotating cloud credential %s to the data of secret %s", secret.Name, sourceName)
+	rotated := plain.DeepCopy()
+	rotated.Data = plainSource.Data
+	delete(rotated.Annotations, RotateFromAnnotation)
+	status.Consumers = consumers
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    RotatedCondition,
+		Status:  metav1.ConditionUnknown,
+		Reason:  "Validating",
+		Message: "the data of the credential was swapped and is being validated",
+	})
+	rotated, _, err = setStatus(rotated, status)
+	if err != nil {
+		return nil, err
+	}
+	if rotated, err = encrypt(rotated); err != nil {
+		return nil, err
+	}
+	updated, err := c.secretClient.Update(rotated)
+	if err != nil {
+		if deleteErr := c.deleteSecret(backup.Namespace, backup.Name); deleteErr != nil {
+			logrus.Errorf("[cloud-credential] Failed to delete backup %s of cloud credential %s: %v", backup.Name, secret.Name, deleteErr)
+		}
+		return nil, err
+	}
+
+	if err := c.deleteSecret(source.Namespace, source.Name); err != nil {
+		logrus.Errorf("[cloud-credential] Failed to delete secret %s after rotating cloud credential %s: %v", source.Name, secret.Name, err)
+	}
+	c.enqueueConsumers(consumers)
+	return updated, nil
+}
+
+// isOwnedBy returns whether obj has an owner reference to the cloud credential.
+func isOwnedBy(obj, secret *v1.Secret) bool {
+	for _, owner := range obj.OwnerReferences {
+		if owner.Kind == "Secret" && owner.UID == secret.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// encrypt encrypts a decrypted copy of a cloud credential or its backup before it is written, if cloud credentials
+// are encrypted.
+func encrypt(secret *v1.Secret) (*v1.Secret, error) {
+	if !encryptedstore.CloudCredentialsEnabled() {
+		return secret, nil
+	}
+	encrypted, err := encryptedstore.EncryptSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt cloud credential data: %w", err)
+	}
+	return encrypted, nil
+}
+
+// findBackup returns the backup of the previous data of a cloud credential kept during its rotation, or nil if no
+// rotation is in progress.
+func (c *Controller) findBackup(secret *v1.Secret) (*v1.Secret, error) {
+	backups, err := c.secretCache.List(secret.Namespace, labels.SelectorFromSet(labels.Set{BackupOfLabel: string(secret.UID)}))
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		if isBackupOf(backup, secret) {
+			return backup, nil
+		}
+	}
+	return nil, nil
+}
+
+// isBackupOf returns whether backup was created by the controller to keep the previous data of the cloud credential.
+func isBackupOf(backup, secret *v1.Secret) bool {
+	if backup.Labels[RotationSourceLabel] != "true" || backup.Labels[BackupOfLabel] != string(secret.UID) {
+		return false
+	}
+	owner := metav1.GetControllerOf(backup)
+	return owner != nil && owner.Kind == "Secret" && owner.UID == secret.UID
+}
+
+// rejectRotation records why a rotation was not started and removes the rotation request from the credential, leaving
+// its data unchanged.
+func (c *Controller) rejectRotation(secret *v1.Secret, status *Status, reason, message string) (*v1.Secret, error) {
+	logrus.Warnf("[cloud-credential] Rotation of cloud credential %s rejected: %s", secret.Name, message)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    RotatedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	updated, _, err := setStatus(secret, status)
+	if err != nil {
+		return nil, err
+	}
+	if updated == secret {
+		updated = secret.DeepCopy()
+	}
+	delete(updated.Annotations, RotateFromAnnotation)
+	if updated, err = c.secretClient.Update(updated); err != nil {
+		return nil, err
+	}
+	c.recordEvent(secret, v1.EventTypeWarning, "CloudCredentialRotationRejected", message)
+	return updated, nil
+}
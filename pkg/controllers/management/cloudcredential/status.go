@@ -0,0 +1,101 @@
+package cloudcredential
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// StatusAnnotation holds the Status of a cloud credential as JSON.
+	StatusAnnotation = "cloudcredential.cattle.io/status"
+	// RotateFromAnnotation requests the rotation of a cloud credential to the data of the secret it names, which must
+	// be in the namespace of the credential, carry the RotationSourceLabel and have an owner reference to the
+	// credential.
+	RotateFromAnnotation = "cloudcredential.cattle.io/rotate-from"
+	// RotationSourceLabel marks secrets holding the data a cloud credential is rotated to or from, which are not cloud
+	// credentials on their own.
+	RotationSourceLabel = "cloudcredential.cattle.io/rotation-source"
+	// BackupOfLabel holds the UID of the cloud credential whose previous data is kept in a backup secret during a
+	// rotation. Backups are created by the controller only, and a rotation is in progress as long as one exists.
+	BackupOfLabel = "cloudcredential.cattle.io/backup-of"
+
+	// ValidatedCondition reports whether the credential was accepted by its provider when last validated.
+	ValidatedCondition = "Validated"
+	// RotatedCondition reports the outcome of the last rotation of the credential.
+	RotatedCondition = "Rotated"
+)
+
+// Status is the state of a cloud credential maintained by the controller. It is informational only, as the owner of
+// the credential can modify it.
+type Status struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Checksum is the checksum of the data last validated.
+	Checksum string `json:"checksum,omitempty"`
+	// ValidatedAt is when the credential was last validated.
+	ValidatedAt *metav1.Time `json:"validatedAt,omitempty"`
+	// Consumers are the resources referencing the credential.
+	Consumers []Consumer `json:"consumers,omitempty"`
+}
+
+// Consumer is a resource referencing a cloud credential.
+type Consumer struct {
+	// Kind of the resource, e.g. Cluster.
+	Kind string `json:"kind"`
+	// APIVersion of the resource.
+	APIVersion string `json:"apiVersion"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Usage describes how the credential is used, e.g. machinePool:pool1 or etcdS3.
+	Usage string `json:"usage"`
+}
+
+// GetStatus returns the status of the cloud credential.
+func GetStatus(secret *v1.Secret) (*Status, error) {
+	status := &Status{}
+	value := secret.Annotations[StatusAnnotation]
+	if value == "" {
+		return status, nil
+	}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation on cloud credential %s: %w", StatusAnnotation, secret.Name, err)
+	}
+	return status, nil
+}
+
+// setStatus returns a copy of the secret with the status set, and whether it differs from the current status.
+func setStatus(secret *v1.Secret, status *Status) (*v1.Secret, bool, error) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return nil, false, err
+	}
+	if secret.Annotations[StatusAnnotation] == string(data) {
+		return secret, false, nil
+	}
+	secret = secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[StatusAnnotation] = string(data)
+	return secret, true, nil
+}
+
+// checksum returns a checksum of the data of a cloud credential.
+func checksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%s%d:", len(k), k, len(data[k]))
+		h.Write(data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,227 @@
+package cloudcredential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	validationTimeout = 30 * time.Second
+	maxMessageLength  = 512
+)
+
+// syncValidation validates a cloud credential when its data changes and periodically, records the resources using
+// it, and drives its rotation. The results are recorded in the status annotation of the credential.
+func (c *Controller) syncValidation(key string, secret *v1.Secret) (*v1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil || secret.Namespace != namespace.GlobalNamespace ||
+		secret.Labels[RotationSourceLabel] == "true" || !configExists(secret.Data) {
+		return secret, nil
+	}
+	if secret.Annotations[RotateFromAnnotation] != "" {
+		return c.rotate(secret)
+	}
+
+	status, err := GetStatus(secret)
+	if err != nil {
+		logrus.Errorf("[cloud-credential] %v", err)
+		status = &Status{}
+	}
+	plain, err := encryptedstore.DecryptSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt cloud credential %s: %w", key, err)
+	}
+
+	status.Consumers, err = c.consumers.Consumers(secret.Namespace, secret.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	interval := validationInterval()
+	sum := checksum(plain.Data)
+	if status.Checksum != sum || status.ValidatedAt == nil || (interval > 0 && now.Sub(status.ValidatedAt.Time) >= interval) {
+		var previous metav1.Condition
+		if existing := meta.FindStatusCondition(status.Conditions, ValidatedCondition); existing != nil {
+			previous = *existing
+		}
+		condition := c.validate(plain.Data)
+		meta.SetStatusCondition(&status.Conditions, condition)
+		status.Checksum = sum
+		status.ValidatedAt = &metav1.Time{Time: now}
+		if condition.Status == metav1.ConditionFalse && (previous.Status != metav1.ConditionFalse || previous.Message != condition.Message) {
+			c.recordEvent(secret, v1.EventTypeWarning, "CloudCredentialInvalid", condition.Message)
+		}
+	}
+
+	backup, err := c.findBackup(secret)
+	if err != nil {
+		return nil, err
+	}
+	if backup != nil {
+		if validated := meta.FindStatusCondition(status.Conditions, ValidatedCondition); validated.Status == metav1.ConditionFalse {
+			return c.rollback(secret, plain, status, backup, validated.Message)
+		}
+		if err := c.deleteSecret(backup.Namespace, backup.Name); err != nil {
+			return nil, err
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    RotatedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Rotated",
+			Message: "the credential was rotated",
+		})
+		c.recordEvent(secret, v1.EventTypeNormal, "CloudCredentialRotated", "the credential was rotated")
+	}
+
+	updated, changed, err := setStatus(secret, status)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if updated, err = c.secretClient.Update(updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if interval > 0 {
+		c.secrets.EnqueueAfter(secret.Namespace, secret.Name, status.ValidatedAt.Add(interval).Sub(now))
+	}
+	return updated, nil
+}
+
+// validate returns the Validated condition of a cloud credential with the given data.
+func (c *Controller) validate(data map[string][]byte) metav1.Condition {
+	condition := metav1.Condition{Type: ValidatedCondition}
+	driver, fields := credentialData(data)
+	validator := c.validators[driver]
+	if validator == nil {
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "NoValidator"
+		condition.Message = fmt.Sprintf("credentials of driver %s can not be validated", driver)
+		return condition
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validationTimeout)
+	defer cancel()
+	err := validator(ctx, fields)
+	switch {
+	case err == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Valid"
+	case errors.Is(err, errNotValidated):
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "NotValidated"
+		condition.Message = err.Error()
+	default:
+		logrus.Debugf("[cloud-credential] Validation of %s cloud credential failed: %v", driver, err)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Invalid"
+		condition.Message = sanitize(err).Error()
+		if len(condition.Message) > maxMessageLength {
+			condition.Message = condition.Message[:maxMessageLength]
+		}
+	}
+	return condition
+}
+
+// rollback restores the data of a rotated cloud credential from its backup, since the new data failed validation.
+func (c *Controller) rollback(secret, plain *v1.Secret, status *Status, backup *v1.Secret, message string) (*v1.Secret, error) {
+	if !isBackupOf(backup, secret) {
+		return nil, fmt.Errorf("secret %s/%s is not a backup of cloud credential %s", backup.Namespace, backup.Name, secret.Name)
+	}
+	plainBackup, err := encryptedstore.DecryptSecret(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("[cloud-credential] Rolling back rotation of cloud credential %s: %s", secret.Name, message)
+	restored := plain.DeepCopy()
+	restored.Data = plainBackup.Data
+	status.Checksum = ""
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    RotatedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "RolledBack",
+		Message: "the new data failed validation and the previous data was restored: " + message,
+	})
+	restored, _, err = setStatus(restored, status)
+	if err != nil {
+		return nil, err
+	}
+	if restored, err = encrypt(restored); err != nil {
+		return nil, err
+	}
+	updated, err := c.secretClient.Update(restored)
+	if err != nil {
+		return nil, err
+	}
+	c.recordEvent(secret, v1.EventTypeWarning, "CloudCredentialRotationRolledBack", message)
+	if err := c.deleteSecret(backup.Namespace, backup.Name); err != nil {
+		return nil, err
+	}
+	c.enqueueConsumers(status.Consumers)
+	return updated, nil
+}
+
+func (c *Controller) deleteSecret(ns, name string) error {
+	if err := c.secretClient.Delete(ns, name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// enqueueConsumers re-reconciles the resources using a cloud credential, so they pick up its new data.
+func (c *Controller) enqueueConsumers(consumers []Consumer) {
+	for _, consumer := range consumers {
+		if consumer.Namespace == "" {
+			c.mgmtClusters.Enqueue(consumer.Name)
+		} else {
+			c.provClusters.Enqueue(consumer.Namespace, consumer.Name)
+		}
+	}
+}
+
+func (c *Controller) recordEvent(secret *v1.Secret, eventType, reason, message string) {
+	now := metav1.NewTime(c.now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: secret.Name + ".",
+			Namespace:    secret.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Namespace:  secret.Namespace,
+			Name:       secret.Name,
+			UID:        secret.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "rancher"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := c.events.Create(event); err != nil {
+		logrus.Errorf("[cloud-credential] Failed to record event %s for cloud credential %s: %v", reason, secret.Name, err)
+	}
+}
+
+func validationInterval() time.Duration {
+	if settings.CloudCredentialValidationInterval.Get() == "" {
+		return 0
+	}
+	return settings.CloudCredentialValidationInterval.GetDuration()
+}
@@ -0,0 +1,410 @@
+package cloudcredential
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type testController struct {
+	*Controller
+	secrets      *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList]
+	secretCache  *fake.MockCacheInterface[*corev1.Secret]
+	events       *fake.MockClientInterface[*corev1.Event, *corev1.EventList]
+	provClusters *fake.MockControllerInterface[*provv1.Cluster, *provv1.ClusterList]
+	mgmtClusters *fake.MockNonNamespacedControllerInterface[*v3.Cluster, *v3.ClusterList]
+	updated      []*corev1.Secret
+	recorded     []*corev1.Event
+	// stored are the secrets returned by lists of the secret cache.
+	stored  []*corev1.Secret
+	deleted []string
+}
+
+func newTestController(t *testing.T, now time.Time, validator Validator, clusters ...*provv1.Cluster) *testController {
+	ctrl := gomock.NewController(t)
+	tc := &testController{
+		secrets:      fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl),
+		secretCache:  fake.NewMockCacheInterface[*corev1.Secret](ctrl),
+		events:       fake.NewMockClientInterface[*corev1.Event, *corev1.EventList](ctrl),
+		provClusters: fake.NewMockControllerInterface[*provv1.Cluster, *provv1.ClusterList](ctrl),
+		mgmtClusters: fake.NewMockNonNamespacedControllerInterface[*v3.Cluster, *v3.ClusterList](ctrl),
+	}
+	provClusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+	provClusterCache.EXPECT().AddIndexer(ByCloudCredentialConsumer, gomock.Any())
+	provClusterCache.EXPECT().GetByIndex(ByCloudCredentialConsumer, gomock.Any()).DoAndReturn(func(_, key string) ([]*provv1.Cluster, error) {
+		var result []*provv1.Cluster
+		for _, cluster := range clusters {
+			for _, credential := range credentialsOf(provClusterConsumers(cluster)) {
+				if credential == key {
+					result = append(result, cluster)
+				}
+			}
+		}
+		return result, nil
+	}).AnyTimes()
+	mgmtClusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+	mgmtClusterCache.EXPECT().AddIndexer(ByCloudCredentialConsumer, gomock.Any())
+	mgmtClusterCache.EXPECT().GetByIndex(ByCloudCredentialConsumer, gomock.Any()).Return(nil, nil).AnyTimes()
+
+	tc.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		tc.updated = append(tc.updated, secret)
+		return secret, nil
+	}).AnyTimes()
+	tc.secrets.EXPECT().EnqueueAfter(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	tc.secrets.EXPECT().Create(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		secret = secret.DeepCopy()
+		secret.Name = secret.GenerateName + "abcde"
+		tc.stored = append(tc.stored, secret)
+		return secret, nil
+	}).AnyTimes()
+	tc.secrets.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ns, name string, _ *metav1.DeleteOptions) error {
+		tc.deleted = append(tc.deleted, name)
+		tc.stored = slices.DeleteFunc(tc.stored, func(s *corev1.Secret) bool { return s.Name == name })
+		return nil
+	}).AnyTimes()
+	tc.secretCache.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ string, selector labels.Selector) ([]*corev1.Secret, error) {
+		var result []*corev1.Secret
+		for _, secret := range tc.stored {
+			if selector.Matches(labels.Set(secret.Labels)) {
+				result = append(result, secret)
+			}
+		}
+		return result, nil
+	}).AnyTimes()
+	tc.events.EXPECT().Create(gomock.Any()).DoAndReturn(func(event *corev1.Event) (*corev1.Event, error) {
+		tc.recorded = append(tc.recorded, event)
+		return event, nil
+	}).AnyTimes()
+
+	tc.Controller = &Controller{
+		secretClient: tc.secrets,
+		secrets:      tc.secrets,
+		secretCache:  tc.secretCache,
+		events:       tc.events,
+		provClusters: tc.provClusters,
+		mgmtClusters: tc.mgmtClusters,
+		consumers:    newConsumerIndex(provClusterCache, mgmtClusterCache),
+		validators:   map[string]Validator{"digitalocean": validator},
+		now:          func() time.Time { return now },
+	}
+	return tc
+}
+
+func newCredential(name, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "cattle-global-data",
+			Name:      name,
+			UID:       types.UID("uid-" + name),
+		},
+		Data: map[string][]byte{
+			"digitaloceancredentialConfig-accessToken": []byte(token),
+		},
+	}
+}
+
+// tokenValidator accepts only the given token.
+func tokenValidator(valid string) Validator {
+	return func(_ context.Context, data map[string]string) error {
+		if data["accessToken"] != valid {
+			return errors.New("unauthorized")
+		}
+		return nil
+	}
+}
+
+func mustGetStatus(t *testing.T, secret *corev1.Secret) *Status {
+	t.Helper()
+	status, err := GetStatus(secret)
+	require.NoError(t, err)
+	return status
+}
+
+func TestSyncValidation(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "c1"},
+		Spec: provv1.ClusterSpec{
+			RKEConfig: &provv1.RKEConfig{
+				MachinePools: []provv1.RKEMachinePool{{
+					Name:                "pool1",
+					RKECommonNodeConfig: rkev1.RKECommonNodeConfig{CloudCredentialSecretName: "cattle-global-data:cc"},
+				}},
+				ClusterConfiguration: rkev1.ClusterConfiguration{
+					ETCD: &rkev1.ETCD{S3: &rkev1.ETCDSnapshotS3{CloudCredentialName: "cattle-global-data:cc"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		validator     Validator
+		wantUpdate    bool
+		wantCondition metav1.ConditionStatus
+		wantReason    string
+		wantEvents    int
+		wantConsumers []Consumer
+	}{
+		{
+			name:          "valid credential",
+			secret:        newCredential("cc", "good"),
+			validator:     tokenValidator("good"),
+			wantUpdate:    true,
+			wantCondition: metav1.ConditionTrue,
+			wantReason:    "Valid",
+			wantConsumers: []Consumer{
+				{Kind: "Cluster", APIVersion: "provisioning.cattle.io/v1", Namespace: "fleet-default", Name: "c1", Usage: "etcdS3"},
+				{Kind: "Cluster", APIVersion: "provisioning.cattle.io/v1", Namespace: "fleet-default", Name: "c1", Usage: "machinePool:pool1"},
+			},
+		},
+		{
+			name:          "invalid credential",
+			secret:        newCredential("cc", "bad"),
+			validator:     tokenValidator("good"),
+			wantUpdate:    true,
+			wantCondition: metav1.ConditionFalse,
+			wantReason:    "Invalid",
+			wantEvents:    1,
+			wantConsumers: []Consumer{
+				{Kind: "Cluster", APIVersion: "provisioning.cattle.io/v1", Namespace: "fleet-default", Name: "c1", Usage: "etcdS3"},
+				{Kind: "Cluster", APIVersion: "provisioning.cattle.io/v1", Namespace: "fleet-default", Name: "c1", Usage: "machinePool:pool1"},
+			},
+		},
+		{
+			name:          "not validated",
+			secret:        newCredential("other", "good"),
+			validator:     func(context.Context, map[string]string) error { return errNotValidated },
+			wantUpdate:    true,
+			wantCondition: metav1.ConditionUnknown,
+			wantReason:    "NotValidated",
+		},
+		{
+			name:          "no validator",
+			secret:        newCredential("other", "good"),
+			wantUpdate:    true,
+			wantCondition: metav1.ConditionUnknown,
+			wantReason:    "NoValidator",
+		},
+		{
+			name: "rotation source",
+			secret: func() *corev1.Secret {
+				s := newCredential("source", "good")
+				s.Labels = map[string]string{RotationSourceLabel: "true"}
+				return s
+			}(),
+		},
+		{
+			name: "not a cloud credential",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-global-data", Name: "cc"},
+				Data:       map[string][]byte{"token": []byte("good")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestController(t, now, tt.validator, cluster)
+			if tt.validator == nil {
+				tc.validators = nil
+			}
+
+			result, err := tc.syncValidation("", tt.secret)
+			require.NoError(t, err)
+			if !tt.wantUpdate {
+				assert.Empty(t, tc.updated)
+				assert.Equal(t, tt.secret, result)
+				return
+			}
+			require.Len(t, tc.updated, 1)
+			status := mustGetStatus(t, result)
+			condition := meta.FindStatusCondition(status.Conditions, ValidatedCondition)
+			require.NotNil(t, condition)
+			assert.Equal(t, tt.wantCondition, condition.Status)
+			assert.Equal(t, tt.wantReason, condition.Reason)
+			assert.Equal(t, now, status.ValidatedAt.Time.UTC())
+			assert.Equal(t, checksum(tt.secret.Data), status.Checksum)
+			assert.Equal(t, tt.wantConsumers, status.Consumers)
+			assert.Len(t, tc.recorded, tt.wantEvents)
+
+			// syncing again within the interval does not validate or update the credential again
+			tc.updated = nil
+			tc.validators = map[string]Validator{"digitalocean": func(context.Context, map[string]string) error {
+				t.Fatal("unexpected validation")
+				return nil
+			}}
+			_, err = tc.syncValidation("", result)
+			require.NoError(t, err)
+			assert.Empty(t, tc.updated)
+		})
+	}
+}
+
+func TestSyncValidationRevalidatesAfterInterval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tc := newTestController(t, now, tokenValidator("good"))
+	secret, err := tc.syncValidation("", newCredential("cc", "good"))
+	require.NoError(t, err)
+
+	// the token was revoked at the provider
+	tc.validators["digitalocean"] = tokenValidator("new")
+	tc.now = func() time.Time { return now.Add(7 * time.Hour) }
+	secret, err = tc.syncValidation("", secret)
+	require.NoError(t, err)
+
+	condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, ValidatedCondition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Len(t, tc.recorded, 1)
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "c1"},
+		Spec:       provv1.ClusterSpec{CloudCredentialSecretName: "cattle-global-data:cc"},
+	}
+	rotating := func() *corev1.Secret {
+		s := newCredential("cc", "old")
+		s.Annotations = map[string]string{RotateFromAnnotation: "staged"}
+		return s
+	}
+	staged := newCredential("staged", "new")
+	staged.Labels = map[string]string{RotationSourceLabel: "true"}
+	staged.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Secret", Name: "cc", UID: "uid-cc"}}
+
+	t.Run("swaps data and completes after validation", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("new"), cluster)
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(staged, nil)
+		tc.provClusters.EXPECT().Enqueue("fleet-default", "c1").Times(1)
+
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		assert.NotContains(t, secret.Annotations, RotateFromAnnotation)
+		assert.Equal(t, []string{"staged"}, tc.deleted)
+		require.Len(t, tc.stored, 1)
+		backup := tc.stored[0]
+		assert.Equal(t, "cc-backup-abcde", backup.Name)
+		assert.True(t, isBackupOf(backup, secret))
+		assert.Equal(t, []byte("old"), backup.Data["digitaloceancredentialConfig-accessToken"])
+
+		secret, err = tc.syncValidation("", secret)
+		require.NoError(t, err)
+		status := mustGetStatus(t, secret)
+		assert.True(t, meta.IsStatusConditionTrue(status.Conditions, RotatedCondition))
+		assert.True(t, meta.IsStatusConditionTrue(status.Conditions, ValidatedCondition))
+		assert.Equal(t, []byte("new"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		assert.Equal(t, []string{"staged", "cc-backup-abcde"}, tc.deleted)
+	})
+
+	t.Run("rejects invalid data", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("old"), cluster)
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(staged, nil)
+
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		assert.NotContains(t, secret.Annotations, RotateFromAnnotation)
+		condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "ValidationFailed", condition.Reason)
+		assert.Len(t, tc.recorded, 1)
+	})
+
+	t.Run("rejects sources without label", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("new"), cluster)
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(newCredential("staged", "new"), nil)
+
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+		condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition)
+		assert.Equal(t, "InvalidSource", condition.Reason)
+	})
+
+	t.Run("rejects sources of other credentials", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("new"), cluster)
+		other := staged.DeepCopy()
+		other.OwnerReferences[0].UID = "uid-victim"
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(other, nil)
+
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition)
+		assert.Equal(t, "InvalidSource", condition.Reason)
+		assert.Empty(t, tc.deleted)
+	})
+
+	t.Run("rejects missing sources", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("new"), cluster)
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "staged"))
+
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+		condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition)
+		assert.Equal(t, "SourceNotFound", condition.Reason)
+	})
+
+	t.Run("rolls back when validation fails after the swap", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("new"), cluster)
+		tc.secretCache.EXPECT().Get("cattle-global-data", "staged").Return(staged, nil)
+		tc.provClusters.EXPECT().Enqueue("fleet-default", "c1").Times(2)
+		secret, err := tc.syncValidation("", rotating())
+		require.NoError(t, err)
+
+		// the new data was revoked before it could be validated
+		tc.validators["digitalocean"] = tokenValidator("old")
+		secret, err = tc.syncValidation("", secret)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		assert.Empty(t, tc.stored)
+		condition := meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "RolledBack", condition.Reason)
+
+		// the restored data is validated again
+		secret, err = tc.syncValidation("", secret)
+		require.NoError(t, err)
+		assert.True(t, meta.IsStatusConditionTrue(mustGetStatus(t, secret).Conditions, ValidatedCondition))
+	})
+
+	t.Run("ignores backups not created for the credential", func(t *testing.T) {
+		tc := newTestController(t, now, tokenValidator("other"), cluster)
+		// another credential labelled as backup of cc, without being owned by it
+		forged := newCredential("victim", "other")
+		forged.Labels = map[string]string{RotationSourceLabel: "true", BackupOfLabel: "uid-cc"}
+		tc.stored = append(tc.stored, forged)
+
+		secret, err := tc.syncValidation("", newCredential("cc", "bad"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("bad"), secret.Data["digitaloceancredentialConfig-accessToken"])
+		assert.Empty(t, tc.deleted)
+		assert.Nil(t, meta.FindStatusCondition(mustGetStatus(t, secret).Conditions, RotatedCondition))
+	})
+}
+
+func TestNormalizeCredential(t *testing.T) {
+	assert.Equal(t, "fleet-default:cc", normalizeCredential("cc", "fleet-default"))
+	assert.Equal(t, "cattle-global-data:cc", normalizeCredential("cattle-global-data:cc", "fleet-default"))
+}
@@ -0,0 +1,271 @@
+package cloudcredential
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksapi "github.com/rancher/rancher/pkg/api/norman/customization/aks"
+	"github.com/rancher/rancher/pkg/httpproxy"
+	"github.com/rancher/rancher/pkg/multiclustermanager/whitelist"
+	"golang.org/x/oauth2/google"
+)
+
+// Validator tests the data of a cloud credential against its provider. The data is keyed by field, without the
+// <driver>credentialConfig- prefix of the secret keys.
+type Validator func(ctx context.Context, data map[string]string) error
+
+// errNotValidated is returned by validators which can not validate a credential, e.g. because optional fields they
+// need are not set. The Validated condition is set to Unknown.
+var errNotValidated = errors.New("credential can not be validated")
+
+// errUnreachable replaces the transport errors of validators in the status of a credential, which its owner can read,
+// so that validating credentials can't be used to probe the network Rancher runs in.
+var errUnreachable = errors.New("unable to reach the provider")
+
+var (
+	digitalOceanAccountURL = "https://api.digitalocean.com/v2/account"
+	linodeProfileURL       = "https://api.linode.com/v4/profile"
+
+	// allowedHosts returns the hosts custom endpoints of credentials may point to, which are the hosts the meta proxy
+	// allows, see the whitelist-domain setting and the whitelisted domains of the drivers.
+	allowedHosts = whitelist.Proxy.Get
+)
+
+var (
+	// awsRegion matches AWS regions, which are part of the host name of the endpoints.
+	awsRegion = regexp.MustCompile(`^[a-z0-9-]+$`)
+	// s3Hosts are the official S3 endpoints that are allowed in addition to the allowed hosts.
+	s3Hosts = []string{"s3.amazonaws.com", "s3.%.amazonaws.com"}
+	// googleTokenURIs are the official token endpoints of Google service account keys.
+	googleTokenURIs = []string{"", "https://oauth2.googleapis.com/token", "https://accounts.google.com/o/oauth2/token"}
+)
+
+// validators are the validators of the cloud credential drivers, keyed by driver name. Harvester credentials are not
+// validated, as their kubeconfig may run credential plugins in the Rancher server.
+var validators = map[string]Validator{
+	"amazonec2":    validateAmazonEC2,
+	"azure":        validateAzure,
+	"digitalocean": validateDigitalOcean,
+	"google":       validateGoogle,
+	"linode":       validateLinode,
+	"s3":           validateS3,
+}
+
+// RegisterValidator registers the validator of the cloud credentials of a driver, replacing any existing one.
+func RegisterValidator(driver string, validator Validator) {
+	validators[driver] = validator
+}
+
+// credentialData returns the driver of a cloud credential and its data keyed by field.
+func credentialData(data map[string][]byte) (string, map[string]string) {
+	var driver string
+	fields := map[string]string{}
+	for key, value := range data {
+		prefix, field, found := strings.Cut(key, "credentialConfig-")
+		if !found || strings.Contains(field, "-") {
+			continue
+		}
+		driver = prefix
+		fields[field] = string(value)
+	}
+	return driver, fields
+}
+
+// sanitize returns the error of a validator to record in the status of the credential. Transport errors are replaced
+// by errUnreachable, and AWS errors are reduced to their status and code.
+func sanitize(err error) error {
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return errUnreachable
+	}
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		return fmt.Errorf("request failed with status %d: %s", requestFailure.StatusCode(), requestFailure.Code())
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if awsErr.Code() == request.ErrCodeRequestError || awsErr.Code() == request.CanceledErrorCode {
+			return errUnreachable
+		}
+		return errors.New(awsErr.Code())
+	}
+	return err
+}
+
+// defaultRegion returns the default region of an AWS credential.
+func defaultRegion(data map[string]string) (string, error) {
+	region := data["defaultRegion"]
+	if region == "" {
+		return "us-east-1", nil
+	}
+	if !awsRegion.MatchString(region) {
+		return "", fmt.Errorf("invalid region %q", region)
+	}
+	return region, nil
+}
+
+func validateAmazonEC2(ctx context.Context, data map[string]string) error {
+	region, err := defaultRegion(data)
+	if err != nil {
+		return err
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(data["accessKey"], data["secretKey"], ""),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	return err
+}
+
+// validateS3 checks access to the default bucket of the credential, or lists the buckets if there is none. Custom
+// endpoints are only validated if they are official S3 endpoints or allowed hosts.
+func validateS3(ctx context.Context, data map[string]string) error {
+	region, err := defaultRegion(data)
+	if err != nil {
+		return err
+	}
+	config := &aws.Config{
+		Region:           aws.String(region),
+		Credentials:      credentials.NewStaticCredentials(data["accessKey"], data["secretKey"], ""),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+	if endpoint := data["defaultEndpoint"]; endpoint != "" {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid endpoint %q", data["defaultEndpoint"])
+		}
+		if !httpproxy.IsAllowed(append(allowedHosts(), s3Hosts...), u.Hostname()) {
+			return fmt.Errorf("%w: endpoint %s is not an allowed domain", errNotValidated, u.Hostname())
+		}
+		config.Endpoint = aws.String(endpoint)
+	}
+	if data["defaultEndpointCA"] != "" || data["defaultSkipSSLVerify"] == "true" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: data["defaultSkipSSLVerify"] == "true"} // #nosec G402 -- opted in by the credential
+		if ca := data["defaultEndpointCA"]; ca != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(ca)) {
+				return fmt.Errorf("invalid endpoint CA")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		config.HTTPClient = &http.Client{Transport: transport}
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return err
+	}
+	client := s3.New(sess)
+	if bucket := data["defaultBucket"]; bucket != "" {
+		_, err = client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		return err
+	}
+	_, err = client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	return err
+}
+
+func validateAzure(ctx context.Context, data map[string]string) error {
+	cloudConfig, environment := aksapi.GetEnvironment(data["environment"])
+	tenantID := data["tenantId"]
+	if tenantID == "" {
+		if data["subscriptionId"] == "" {
+			return errNotValidated
+		}
+		var err error
+		if tenantID, err = aks.FindTenantID(ctx, environment, data["subscriptionId"]); err != nil {
+			return fmt.Errorf("failed to find tenant of subscription: %w", err)
+		}
+	}
+	credential, err := azidentity.NewClientSecretCredential(tenantID, data["clientId"], data["clientSecret"], &azidentity.ClientSecretCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: cloudConfig},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{cloudConfig.Services[cloud.ResourceManager].Audience + "/.default"},
+	})
+	return err
+}
+
+// validateGoogle gets a token for the service account key of the credential. Other types of credentials are not
+// validated, as they may read files or run commands in the Rancher server.
+func validateGoogle(ctx context.Context, data map[string]string) error {
+	var key struct {
+		Type     string `json:"type"`
+		TokenURI string `json:"token_uri"`
+	}
+	if err := json.Unmarshal([]byte(data["authEncodedJson"]), &key); err != nil {
+		return fmt.Errorf("invalid service account key: %w", err)
+	}
+	if key.Type != "service_account" {
+		return fmt.Errorf("%w: credentials of type %q are not supported", errNotValidated, key.Type)
+	}
+	if !slices.Contains(googleTokenURIs, key.TokenURI) {
+		return fmt.Errorf("%w: token URI %s is not an official Google endpoint", errNotValidated, key.TokenURI)
+	}
+	credentials, err := google.CredentialsFromJSON(ctx, []byte(data["authEncodedJson"]), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return err
+	}
+	_, err = credentials.TokenSource.Token()
+	return err
+}
+
+func validateDigitalOcean(ctx context.Context, data map[string]string) error {
+	return checkBearerToken(ctx, digitalOceanAccountURL, data["accessToken"])
+}
+
+func validateLinode(ctx context.Context, data map[string]string) error {
+	return checkBearerToken(ctx, linodeProfileURL, data["token"])
+}
+
+// checkBearerToken checks that a GET request to url is authorized with token.
+func checkBearerToken(ctx context.Context, url, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
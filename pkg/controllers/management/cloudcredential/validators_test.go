@@ -0,0 +1,73 @@
+package cloudcredential
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialData(t *testing.T) {
+	driver, fields := credentialData(map[string][]byte{
+		"s3credentialConfig-accessKey":     []byte("key"),
+		"s3credentialConfig-defaultRegion": []byte("us-west-2"),
+		"unrelated":                        []byte("value"),
+	})
+	assert.Equal(t, "s3", driver)
+	assert.Equal(t, map[string]string{"accessKey": "key", "defaultRegion": "us-west-2"}, fields)
+}
+
+func TestCheckBearerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	previous := digitalOceanAccountURL
+	digitalOceanAccountURL = server.URL
+	defer func() { digitalOceanAccountURL = previous }()
+
+	assert.NoError(t, validateDigitalOcean(context.Background(), map[string]string{"accessToken": "good"}))
+	assert.ErrorContains(t, validateDigitalOcean(context.Background(), map[string]string{"accessToken": "bad"}), "status 401")
+}
+
+func TestValidateS3Endpoint(t *testing.T) {
+	previous := allowedHosts
+	allowedHosts = func() []string { return []string{"minio.example.com"} }
+	defer func() { allowedHosts = previous }()
+
+	for _, endpoint := range []string{"169.254.169.254", "http://10.0.0.1:9000", "internal.svc.cluster.local"} {
+		err := validateS3(context.Background(), map[string]string{"defaultEndpoint": endpoint})
+		assert.ErrorIs(t, err, errNotValidated, endpoint)
+	}
+	assert.ErrorContains(t, validateS3(context.Background(), map[string]string{"defaultEndpoint": "file:///etc/passwd"}), "invalid endpoint")
+	assert.ErrorContains(t, validateS3(context.Background(), map[string]string{"defaultRegion": "evil.com/"}), "invalid region")
+}
+
+func TestValidateGoogleKey(t *testing.T) {
+	err := validateGoogle(context.Background(), map[string]string{"authEncodedJson": `{"type":"external_account","credential_source":{"file":"/etc/passwd"}}`})
+	assert.ErrorIs(t, err, errNotValidated)
+	err = validateGoogle(context.Background(), map[string]string{"authEncodedJson": `{"type":"service_account","token_uri":"http://169.254.169.254/"}`})
+	assert.ErrorIs(t, err, errNotValidated)
+}
+
+func TestSanitize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Close()
+
+	previous := linodeProfileURL
+	linodeProfileURL = server.URL
+	defer func() { linodeProfileURL = previous }()
+
+	err := validateLinode(context.Background(), map[string]string{"token": "token"})
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, errUnreachable, sanitize(err))
+
+	err = awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "request")
+	assert.EqualError(t, sanitize(err), "request failed with status 403: AccessDenied")
+}
@@ -11,6 +11,7 @@
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/capr"
 	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/rancher/pkg/wrangler"
 	"github.com/rancher/remotedialer"
 	"github.com/rancher/wrangler/v3/pkg/condition"
@@ -57,6 +58,9 @@ func (c *checker) check() error {
 		if err := c.checkCluster(cluster); err != nil {
 			logrus.Errorf("failed to check connectivity of cluster [%s]: %v", cluster.Name, err)
 		}
+		if err := c.checkSessions(cluster); err != nil {
+			logrus.Errorf("failed to check agent sessions of cluster [%s]: %v", cluster.Name, err)
+		}
 	}
 	return nil
 }
@@ -76,6 +80,7 @@ func (c *checker) hasSession(cluster *v3.Cluster) bool {
 	client := &http.Client{
 		Transport: transport,
 	}
+	start := time.Now()
 	resp, err := client.Get("http://not-used/ping")
 	if err != nil {
 		return false
@@ -84,7 +89,25 @@ func (c *checker) hasSession(cluster *v3.Cluster) bool {
 		ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	}()
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	tunnelserver.ObservePingLatency(cluster.Name, time.Since(start))
+	return true
+}
+
+// checkSessions prunes the closed agent sessions of the cluster and clears its flapping condition once the sessions
+// are stable again. Conflicts are resolved on the next check.
+func (c *checker) checkSessions(cluster *v3.Cluster) error {
+	cluster = cluster.DeepCopy()
+	if !tunnelserver.ResolveSessions(cluster, time.Now()) {
+		return nil
+	}
+	_, err := c.clusters.Update(cluster)
+	if apierror.IsConflict(err) || apierror.IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
 func (c *checker) checkCluster(cluster *v3.Cluster) error {
@@ -17,6 +17,7 @@
 	"github.com/rancher/rancher/pkg/controllers/management/drivers/kontainerdriver"
 	"github.com/rancher/rancher/pkg/controllers/management/drivers/nodedriver"
 	"github.com/rancher/rancher/pkg/controllers/management/node"
+	"github.com/rancher/rancher/pkg/controllers/management/secretencryption"
 	"github.com/rancher/rancher/pkg/controllers/management/secretmigrator"
 	"github.com/rancher/rancher/pkg/controllers/management/settings"
 	"github.com/rancher/rancher/pkg/controllers/management/usercontrollers"
@@ -46,6 +47,7 @@ func Register(ctx context.Context, management *config.ManagementContext, manager
 	node.Register(ctx, management, manager)
 
 	secretmigrator.Register(ctx, management)
+	secretencryption.Register(ctx, wrangler)
 	settings.Register(ctx, management)
 	managementlegacy.Register(ctx, management, manager)
 
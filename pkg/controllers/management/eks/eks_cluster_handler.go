@@ -24,6 +24,7 @@
 	"github.com/rancher/rancher/pkg/controllers/management/rbac"
 	"github.com/rancher/rancher/pkg/controllers/management/secretmigrator"
 	"github.com/rancher/rancher/pkg/dialer"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	mgmtv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kontainer-engine/drivers/util"
 	"github.com/rancher/rancher/pkg/namespace"
@@ -579,6 +580,10 @@ func (e *eksOperatorController) getAWSSession(cluster *mgmtv3.Cluster) (*session
 		if err != nil {
 			return nil, fmt.Errorf("error getting secret %s/%s: %w", ns, id, err)
 		}
+		secret, err = encryptedstore.DecryptSecret(secret)
+		if err != nil {
+			return nil, err
+		}
 
 		accessKeyBytes := secret.Data["amazonec2credentialConfig-accessKey"]
 		secretKeyBytes := secret.Data["amazonec2credentialConfig-secretKey"]
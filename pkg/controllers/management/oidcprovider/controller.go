@@ -58,6 +58,12 @@ func Register(ctx context.Context, wContext *wrangler.Context) {
 		now:             time.Now,
 	}
 	oidcClient.OnChange(ctx, "oidcclient-change", controller.onChange)
+
+	signingKey := &signingKeyController{
+		secrets: wContext.Core.Secret(),
+		now:     time.Now,
+	}
+	wContext.Core.Secret().OnChange(ctx, "oidc-signing-key-rotation", signingKey.onChange)
 }
 
 // onChange sets a new client id in the status field, and creates a k8s with the client secret.
@@ -235,6 +241,10 @@ func (c *oidcClientController) updateStatusIfNeeded(oidcClient *v3.OIDCClient, s
 	observedStatus := v3.OIDCClientStatus{
 		ClientID: secret.Name,
 	}
+	// the authorization of the service principal is revoked when the service principal changes
+	if oidcClient.Status.ServicePrincipal == oidcClient.Spec.ServicePrincipal {
+		observedStatus.ServicePrincipal = oidcClient.Status.ServicePrincipal
+	}
 	if len(observedClientSecrets) > 0 {
 		observedStatus.ClientSecrets = observedClientSecrets
 	}
@@ -142,6 +142,59 @@ type mockParams struct {
 				}, nil)
 			},
 		},
+		"authorization of the service principal is kept while it matches the spec": {
+			oidcClient: &v3.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fakeOIDCClientName,
+					UID:  fakeOIDCClientUID,
+				},
+				Spec: v3.OIDCClientSpec{
+					ServicePrincipal: "u-service",
+				},
+				Status: v3.OIDCClientStatus{
+					ClientID:         fakeClientId,
+					ServicePrincipal: "u-service",
+				},
+			},
+			setupMock: func(p *mockParams, _ *v3.OIDCClient) {
+				p.secretCache.EXPECT().Get(secretNamespace, fakeClientId).Return(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: fakeClientId,
+					},
+				}, nil)
+			},
+		},
+		"authorization of the service principal is revoked when the spec changes": {
+			oidcClient: &v3.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fakeOIDCClientName,
+					UID:  fakeOIDCClientUID,
+				},
+				Spec: v3.OIDCClientSpec{
+					ServicePrincipal: "u-admin",
+				},
+				Status: v3.OIDCClientStatus{
+					ClientID:         fakeClientId,
+					ServicePrincipal: "u-service",
+				},
+			},
+			setupMock: func(p *mockParams, oidcClient *v3.OIDCClient) {
+				p.secretCache.EXPECT().Get(secretNamespace, fakeClientId).Return(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: fakeClientId,
+					},
+				}, nil)
+				patchOp := []map[string]interface{}{
+					{
+						"op":    "add",
+						"path":  "/status",
+						"value": v3.OIDCClientStatus{ClientID: fakeClientId},
+					},
+				}
+				patchBytes, _ := json.Marshal(patchOp)
+				p.oidcClient.EXPECT().Patch(fakeOIDCClientName, types.JSONPatchType, patchBytes, "status").Return(oidcClient, nil)
+			},
+		},
 		"new client secret is created with annotation": {
 			oidcClient: &v3.OIDCClient{
 				ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,171 @@
+package oidcprovider
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/oidc/signingkey"
+	"github.com/rancher/rancher/pkg/settings"
+	corev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxSigningKeyCheckInterval is the longest time between two checks of the signing key, so changes to the rotation
+// settings are picked up without a change to the secret.
+const maxSigningKeyCheckInterval = time.Hour
+
+type signingKeyController struct {
+	secrets corev1.SecretController
+	now     func() time.Time
+}
+
+// onChange rotates the OIDC provider signing key when it is older than the rotation interval or was created with a
+// different algorithm, and removes the public keys of rotated keys once the overlap window has passed.
+func (c *signingKeyController) onChange(_ string, secret *v1.Secret) (*v1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil ||
+		secret.Namespace != signingkey.SecretNamespace || secret.Name != signingkey.SecretName {
+		return secret, nil
+	}
+
+	updated, next, err := c.rotate(secret.DeepCopy())
+	if err != nil {
+		return secret, err
+	}
+	if updated != nil {
+		if secret, err = c.secrets.Update(updated); err != nil {
+			return secret, fmt.Errorf("failed to update OIDC signing key: %w", err)
+		}
+	}
+
+	c.secrets.EnqueueAfter(secret.Namespace, secret.Name, next)
+
+	return secret, nil
+}
+
+// rotate applies the rotation policy to the secret. It returns the secret if it was modified, and how long until it
+// must be checked again.
+func (c *signingKeyController) rotate(secret *v1.Secret) (*v1.Secret, time.Duration, error) {
+	now := c.now().UTC()
+	next := maxSigningKeyCheckInterval
+	changed := false
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	retired := map[string]string{}
+	if value := secret.Annotations[signingkey.RetiredKeysAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &retired); err != nil {
+			logrus.Warnf("[OIDC provider] ignoring invalid %s annotation: %v", signingkey.RetiredKeysAnnotation, err)
+			retired = map[string]string{}
+		}
+	}
+
+	// remove the public keys of retired keys once the overlap window has passed
+	overlap := settings.OIDCSigningKeyOverlap.GetDuration()
+	for kid, value := range retired {
+		retiredAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			logrus.Warnf("[OIDC provider] ignoring invalid retirement time of signing key %s: %v", kid, err)
+			retiredAt = now
+			retired[kid] = now.Format(time.RFC3339)
+			changed = true
+		}
+		if remaining := retiredAt.Add(overlap).Sub(now); remaining > 0 {
+			next = min(next, remaining)
+			continue
+		}
+		logrus.Infof("[OIDC provider] removing public key of retired signing key %s", kid)
+		delete(secret.Data, kid+signingkey.PublicKeySuffix)
+		delete(retired, kid)
+		changed = true
+	}
+
+	var currentKids []string
+	algorithm := ""
+	for name, value := range secret.Data {
+		if kid, ok := strings.CutSuffix(name, signingkey.PrivateKeySuffix); ok {
+			currentKids = append(currentKids, kid)
+			if key, err := signingkey.ParsePrivateKey(value); err == nil {
+				algorithm, _ = signingkey.Algorithm(key.Public())
+			}
+		}
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, secret.Annotations[signingkey.CreatedAtAnnotation])
+	if err != nil {
+		// keys created before rotation was supported are considered new
+		createdAt = now
+		secret.Annotations[signingkey.CreatedAtAnnotation] = now.Format(time.RFC3339)
+		changed = true
+	}
+
+	wantAlgorithm := settings.OIDCSigningKeyAlgorithm.Get()
+	if !slices.Contains(signingkey.Algorithms, wantAlgorithm) {
+		logrus.Warnf("[OIDC provider] ignoring unsupported signing key algorithm %q", wantAlgorithm)
+		wantAlgorithm = cmp.Or(algorithm, signingkey.RS256)
+	}
+	interval := signingKeyRotationInterval()
+	rotate := len(currentKids) != 1 || algorithm != wantAlgorithm
+	if interval > 0 {
+		if remaining := createdAt.Add(interval).Sub(now); remaining > 0 {
+			next = min(next, remaining)
+		} else {
+			rotate = true
+		}
+	}
+
+	if rotate {
+		privateKeyPEM, publicKeyPEM, err := signingkey.Generate(wantAlgorithm)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to generate OIDC signing key: %w", err)
+		}
+		kid := fmt.Sprintf("key-%d", now.Unix())
+		for _, oldKid := range currentKids {
+			delete(secret.Data, oldKid+signingkey.PrivateKeySuffix)
+			if _, ok := secret.Data[oldKid+signingkey.PublicKeySuffix]; ok {
+				retired[oldKid] = now.Format(time.RFC3339)
+				next = min(next, overlap)
+			}
+		}
+		secret.Data[kid+signingkey.PrivateKeySuffix] = privateKeyPEM
+		secret.Data[kid+signingkey.PublicKeySuffix] = publicKeyPEM
+		secret.Annotations[signingkey.CreatedAtAnnotation] = now.Format(time.RFC3339)
+		if interval > 0 {
+			next = min(next, interval)
+		}
+		changed = true
+		logrus.Infof("[OIDC provider] rotated signing key, new key %s (%s) replaces %s", kid, wantAlgorithm, strings.Join(currentKids, ","))
+	}
+
+	if !changed {
+		return nil, next, nil
+	}
+
+	if len(retired) > 0 {
+		value, err := json.Marshal(retired)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to marshal retired signing keys: %w", err)
+		}
+		secret.Annotations[signingkey.RetiredKeysAnnotation] = string(value)
+	} else {
+		delete(secret.Annotations, signingkey.RetiredKeysAnnotation)
+	}
+
+	return secret, max(next, time.Second), nil
+}
+
+func signingKeyRotationInterval() time.Duration {
+	if settings.OIDCSigningKeyRotationInterval.Get() == "" {
+		return 0
+	}
+
+	return settings.OIDCSigningKeyRotationInterval.GetDuration()
+}
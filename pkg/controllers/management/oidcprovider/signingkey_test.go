@@ -0,0 +1,196 @@
+package oidcprovider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/rancher/pkg/oidc/signingkey"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func setSetting(t *testing.T, setting settings.Setting, value string) {
+	t.Helper()
+	previous := setting.Get()
+	require.NoError(t, setting.Set(value))
+	t.Cleanup(func() {
+		_ = setting.Set(previous)
+	})
+}
+
+func newSigningKeySecret(t *testing.T, kid string, algorithm string, annotations map[string]string) *v1.Secret {
+	t.Helper()
+	privateKeyPEM, publicKeyPEM, err := signingkey.Generate(algorithm)
+	require.NoError(t, err)
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        signingkey.SecretName,
+			Namespace:   signingkey.SecretNamespace,
+			Annotations: annotations,
+		},
+		Data: map[string][]byte{
+			kid + signingkey.PrivateKeySuffix: privateKeyPEM,
+			kid + signingkey.PublicKeySuffix:  publicKeyPEM,
+		},
+	}
+}
+
+func TestSigningKeyRotate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	setSetting(t, settings.OIDCSigningKeyAlgorithm, signingkey.ES256)
+	setSetting(t, settings.OIDCSigningKeyOverlap, "24h")
+
+	tests := map[string]struct {
+		interval        string
+		secret          func(t *testing.T) *v1.Secret
+		wantUpdate      bool
+		wantNext        time.Duration
+		wantPrivateKids []string
+		wantPublicKids  []string
+		wantRetired     map[string]string
+	}{
+		"key is not rotated before the interval has passed": {
+			interval: "720h",
+			secret: func(t *testing.T) *v1.Secret {
+				return newSigningKeySecret(t, "key", signingkey.ES256, map[string]string{
+					signingkey.CreatedAtAnnotation: now.Add(-30 * time.Minute).Format(time.RFC3339),
+				})
+			},
+			wantNext:        time.Hour,
+			wantPrivateKids: []string{"key"},
+			wantPublicKids:  []string{"key"},
+		},
+		"key created before rotation was supported is considered new": {
+			interval: "720h",
+			secret: func(t *testing.T) *v1.Secret {
+				return newSigningKeySecret(t, "key", signingkey.ES256, nil)
+			},
+			wantUpdate:      true,
+			wantNext:        time.Hour,
+			wantPrivateKids: []string{"key"},
+			wantPublicKids:  []string{"key"},
+		},
+		"key is rotated once the interval has passed": {
+			interval: "720h",
+			secret: func(t *testing.T) *v1.Secret {
+				return newSigningKeySecret(t, "key", signingkey.ES256, map[string]string{
+					signingkey.CreatedAtAnnotation: now.Add(-720 * time.Hour).Format(time.RFC3339),
+				})
+			},
+			wantUpdate:      true,
+			wantNext:        time.Hour,
+			wantPrivateKids: []string{"key-1735689600"},
+			wantPublicKids:  []string{"key", "key-1735689600"},
+			wantRetired:     map[string]string{"key": now.Format(time.RFC3339)},
+		},
+		"key is rotated when the algorithm changes": {
+			secret: func(t *testing.T) *v1.Secret {
+				return newSigningKeySecret(t, "key", signingkey.RS256, map[string]string{
+					signingkey.CreatedAtAnnotation: now.Format(time.RFC3339),
+				})
+			},
+			wantUpdate:      true,
+			wantNext:        time.Hour,
+			wantPrivateKids: []string{"key-1735689600"},
+			wantPublicKids:  []string{"key", "key-1735689600"},
+			wantRetired:     map[string]string{"key": now.Format(time.RFC3339)},
+		},
+		"retired public key is kept during the overlap window": {
+			secret: func(t *testing.T) *v1.Secret {
+				secret := newSigningKeySecret(t, "key-2", signingkey.ES256, map[string]string{
+					signingkey.CreatedAtAnnotation:   now.Add(-2 * time.Hour).Format(time.RFC3339),
+					signingkey.RetiredKeysAnnotation: `{"key-1":"` + now.Add(-23*time.Hour-50*time.Minute).Format(time.RFC3339) + `"}`,
+				})
+				secret.Data["key-1.pub"] = secret.Data["key-2.pub"]
+				return secret
+			},
+			wantNext:        10 * time.Minute,
+			wantPrivateKids: []string{"key-2"},
+			wantPublicKids:  []string{"key-1", "key-2"},
+		},
+		"retired public key is removed after the overlap window": {
+			secret: func(t *testing.T) *v1.Secret {
+				secret := newSigningKeySecret(t, "key-2", signingkey.ES256, map[string]string{
+					signingkey.CreatedAtAnnotation:   now.Add(-25 * time.Hour).Format(time.RFC3339),
+					signingkey.RetiredKeysAnnotation: `{"key-1":"` + now.Add(-25*time.Hour).Format(time.RFC3339) + `"}`,
+				})
+				secret.Data["key-1.pub"] = secret.Data["key-2.pub"]
+				return secret
+			},
+			wantUpdate:      true,
+			wantNext:        time.Hour,
+			wantPrivateKids: []string{"key-2"},
+			wantPublicKids:  []string{"key-2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			setSetting(t, settings.OIDCSigningKeyRotationInterval, test.interval)
+			c := &signingKeyController{now: func() time.Time { return now }}
+			secret := test.secret(t)
+
+			updated, next, err := c.rotate(secret.DeepCopy())
+			require.NoError(t, err)
+			assert.Equal(t, test.wantNext, next)
+			if !test.wantUpdate {
+				assert.Nil(t, updated)
+				updated = secret
+			}
+			require.NotNil(t, updated)
+
+			var privateKids, publicKids []string
+			for name := range updated.Data {
+				if kid, ok := strings.CutSuffix(name, signingkey.PrivateKeySuffix); ok {
+					privateKids = append(privateKids, kid)
+				}
+				if kid, ok := strings.CutSuffix(name, signingkey.PublicKeySuffix); ok {
+					publicKids = append(publicKids, kid)
+				}
+			}
+			assert.ElementsMatch(t, test.wantPrivateKids, privateKids)
+			assert.ElementsMatch(t, test.wantPublicKids, publicKids)
+
+			if test.wantUpdate {
+				var retired map[string]string
+				if value, ok := updated.Annotations[signingkey.RetiredKeysAnnotation]; ok {
+					require.NoError(t, json.Unmarshal([]byte(value), &retired))
+				}
+				assert.Equal(t, test.wantRetired, retired)
+			}
+		})
+	}
+}
+
+func TestSigningKeyOnChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	setSetting(t, settings.OIDCSigningKeyAlgorithm, signingkey.ES256)
+	setSetting(t, settings.OIDCSigningKeyRotationInterval, "30m")
+
+	secret := newSigningKeySecret(t, "key", signingkey.ES256, map[string]string{
+		signingkey.CreatedAtAnnotation: now.Add(-time.Hour).Format(time.RFC3339),
+	})
+	secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+	secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *v1.Secret) (*v1.Secret, error) {
+		assert.Contains(t, secret.Data, "key-1735689600.pem")
+		return secret, nil
+	})
+	secrets.EXPECT().EnqueueAfter(signingkey.SecretNamespace, signingkey.SecretName, 30*time.Minute)
+
+	c := &signingKeyController{secrets: secrets, now: func() time.Time { return now }}
+	_, err := c.onChange("", secret)
+	assert.NoError(t, err)
+
+	// other secrets are ignored
+	_, err = c.onChange("", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: signingkey.SecretNamespace}})
+	assert.NoError(t, err)
+}
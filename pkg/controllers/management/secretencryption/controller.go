@@ -0,0 +1,175 @@
+// Package secretencryption keeps the envelope encryption of secrets up to date with the configured key provider.
+package secretencryption
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/wrangler"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	byHostedCloudCredential = "secretencryption.cattle.io/by-hosted-cloud-credential"
+	rotationCheckInterval   = 10 * time.Minute
+)
+
+type controller struct {
+	ctx              context.Context
+	envelope         *encryptedstore.Envelope
+	cloudCredentials bool
+	secrets          corecontrollers.SecretController
+	secretCache      corecontrollers.SecretCache
+	clusterCache     mgmtcontrollers.ClusterCache
+}
+
+// Register registers the controller encrypting secrets with the current key encryption key. It does nothing if
+// encryption is not configured.
+func Register(ctx context.Context, wContext *wrangler.Context) {
+	envelope := encryptedstore.Default()
+	if envelope == nil {
+		return
+	}
+	c := &controller{
+		ctx:              ctx,
+		envelope:         envelope,
+		cloudCredentials: encryptedstore.CloudCredentialsEnabled(),
+		secrets:          wContext.Core.Secret(),
+		secretCache:      wContext.Core.Secret().Cache(),
+		clusterCache:     wContext.Mgmt.Cluster().Cache(),
+	}
+	c.clusterCache.AddIndexer(byHostedCloudCredential, encryptedstore.HostedCloudCredentials)
+	wContext.Core.Secret().OnChange(ctx, "secret-encryption", c.onSecretChange)
+	wContext.Mgmt.Cluster().OnChange(ctx, "secret-encryption-hosted-cluster", c.onClusterChange)
+	go c.checkRotation(ctx)
+}
+
+// onSecretChange encrypts the secrets of encrypted stores and, if enabled, cloud credentials, when they have values
+// in plaintext or their data encryption key was wrapped by a previous key encryption key. Cloud credentials of hosted
+// clusters are decrypted instead, since the cluster operators read them directly.
+func (c *controller) onSecretChange(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil {
+		return secret, nil
+	}
+
+	cloudCredential := isCloudCredential(secret)
+	if cloudCredential {
+		hosted, err := c.clusterCache.GetByIndex(byHostedCloudCredential, secret.Namespace+":"+secret.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(hosted) > 0 {
+			if !encryptedstore.IsEncrypted(secret) {
+				return secret, nil
+			}
+			logrus.Infof("[secret-encryption] Decrypting cloud credential %s/%s used by hosted cluster %s", secret.Namespace, secret.Name, hosted[0].Name)
+			decrypted, err := c.envelope.Decrypt(c.ctx, secret)
+			if err != nil {
+				return nil, err
+			}
+			return c.secrets.Update(decrypted)
+		}
+	}
+
+	if secret.Labels[encryptedstore.StoreLabel] != "true" && !encryptedstore.IsEncrypted(secret) &&
+		!(cloudCredential && c.cloudCredentials) {
+		return secret, nil
+	}
+
+	needsEncryption, err := c.envelope.NeedsEncryption(c.ctx, secret)
+	if err != nil || !needsEncryption {
+		return secret, err
+	}
+	encrypted, err := c.envelope.Encrypt(c.ctx, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	if c.envelope.Provider() == nil {
+		logrus.Debugf("[secret-encryption] Decrypting secret %s/%s", secret.Namespace, secret.Name)
+	} else {
+		logrus.Debugf("[secret-encryption] Encrypting secret %s/%s with key %s", secret.Namespace, secret.Name, encrypted.Annotations[encryptedstore.KeyIDAnnotation])
+	}
+	return c.secrets.Update(encrypted)
+}
+
+// onClusterChange enqueues the cloud credential of a hosted cluster, so it is decrypted if needed.
+func (c *controller) onClusterChange(_ string, cluster *v3.Cluster) (*v3.Cluster, error) {
+	if cluster == nil {
+		return cluster, nil
+	}
+	credentials, _ := encryptedstore.HostedCloudCredentials(cluster)
+	for _, credential := range credentials {
+		ns, name := kv.Split(credential, ":")
+		c.secrets.Enqueue(ns, name)
+	}
+	return cluster, nil
+}
+
+// checkRotation periodically enqueues the encrypted secrets whose data encryption key was not wrapped by the current
+// key encryption key, since rotating keys at the key provider does not change any secret. With the identity, all
+// encrypted secrets are enqueued, so they are decrypted.
+func (c *controller) checkRotation(ctx context.Context) {
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.enqueueStale(ctx); err != nil {
+			logrus.Errorf("[secret-encryption] Failed to check for rotated keys: %v", err)
+		}
+	}
+}
+
+func (c *controller) enqueueStale(ctx context.Context) error {
+	provider := c.envelope.Provider()
+	var providerName, keyID string
+	if provider != nil {
+		var err error
+		if keyID, err = provider.KeyID(ctx); err != nil {
+			return err
+		}
+		providerName = provider.Name()
+	}
+	secrets, err := c.secretCache.List("", labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if !encryptedstore.IsEncrypted(secret) {
+			continue
+		}
+		if secret.Annotations[encryptedstore.ProviderAnnotation] != providerName ||
+			secret.Annotations[encryptedstore.KeyIDAnnotation] != keyID {
+			c.secrets.Enqueue(secret.Namespace, secret.Name)
+		}
+	}
+	return nil
+}
+
+// isCloudCredential returns whether the secret is a cloud credential, which has data keys of the format
+// <driver>credentialConfig-<field>.
+func isCloudCredential(secret *corev1.Secret) bool {
+	if secret.Namespace != namespace.GlobalNamespace {
+		return false
+	}
+	for key := range secret.Data {
+		splitKey := strings.Split(key, "-")
+		if len(splitKey) == 2 && strings.HasSuffix(splitKey[0], "Config") {
+			return true
+		}
+	}
+	return false
+}
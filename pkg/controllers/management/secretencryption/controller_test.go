@@ -0,0 +1,188 @@
+package secretencryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/encryptedstore"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newEnvelope returns an envelope using a local key provider with the given keys, the first being the current one. The
+// secret of each key is derived from its name.
+func newEnvelope(t *testing.T, keys ...string) *encryptedstore.Envelope {
+	t.Helper()
+	var content strings.Builder
+	content.WriteString("keys:\n")
+	for _, key := range keys {
+		secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{key[len(key)-1]}, 32))
+		fmt.Fprintf(&content, "- name: %s\n  secret: %s\n", key, secret)
+	}
+	keyFile := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(content.String()), 0600))
+	provider, err := encryptedstore.NewLocalKeyProvider(keyFile)
+	require.NoError(t, err)
+	return encryptedstore.NewEnvelope(provider)
+}
+
+func newCloudCredential() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc-abc", Namespace: "cattle-global-data"},
+		Data: map[string][]byte{
+			"amazonec2credentialConfig-accessKey": []byte("AKIA"),
+			"amazonec2credentialConfig-secretKey": []byte("secret"),
+		},
+	}
+}
+
+func newStoreSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mc-node1",
+			Namespace: "cattle-system",
+			Labels:    map[string]string{encryptedstore.StoreLabel: "true"},
+		},
+		Data: map[string][]byte{"extractedConfig": []byte("config")},
+	}
+}
+
+func TestOnSecretChange(t *testing.T) {
+	ctx := context.Background()
+	envelope := newEnvelope(t, "key1")
+	encryptedCredential, err := envelope.Encrypt(ctx, newCloudCredential())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		secret           *corev1.Secret
+		cloudCredentials bool
+		hostedClusters   []*v3.Cluster
+		wantKeyID        string
+		wantDecrypted    bool
+	}{
+		{
+			name:      "store secret is encrypted",
+			secret:    newStoreSecret(),
+			wantKeyID: "key1",
+		},
+		{
+			name:   "cloud credential is not encrypted if disabled",
+			secret: newCloudCredential(),
+		},
+		{
+			name:             "cloud credential is encrypted if enabled",
+			secret:           newCloudCredential(),
+			cloudCredentials: true,
+			wantKeyID:        "key1",
+		},
+		{
+			name:             "cloud credential of hosted cluster is not encrypted",
+			secret:           newCloudCredential(),
+			cloudCredentials: true,
+			hostedClusters:   []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-abc"}}},
+		},
+		{
+			name:             "encrypted cloud credential of hosted cluster is decrypted",
+			secret:           encryptedCredential,
+			cloudCredentials: true,
+			hostedClusters:   []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-abc"}}},
+			wantDecrypted:    true,
+		},
+		{
+			name:   "encrypted secret is left as is",
+			secret: encryptedCredential,
+		},
+		{
+			name: "unrelated secret is ignored",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "cattle-system"},
+				Data:       map[string][]byte{"tls.key": []byte("key")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().GetByIndex(byHostedCloudCredential, "cattle-global-data:cc-abc").Return(tt.hostedClusters, nil).AnyTimes()
+
+			var updated *corev1.Secret
+			if tt.wantKeyID != "" || tt.wantDecrypted {
+				secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+					updated = secret
+					return secret, nil
+				})
+			}
+
+			c := &controller{
+				ctx:              ctx,
+				envelope:         envelope,
+				cloudCredentials: tt.cloudCredentials,
+				secrets:          secrets,
+				clusterCache:     clusterCache,
+			}
+			_, err := c.onSecretChange("", tt.secret)
+			require.NoError(t, err)
+
+			switch {
+			case tt.wantKeyID != "":
+				require.NotNil(t, updated)
+				assert.Equal(t, tt.wantKeyID, updated.Annotations[encryptedstore.KeyIDAnnotation])
+				decrypted, err := envelope.Decrypt(ctx, updated)
+				require.NoError(t, err)
+				plain, err := envelope.Decrypt(ctx, tt.secret)
+				require.NoError(t, err)
+				assert.Equal(t, plain.Data, decrypted.Data)
+			case tt.wantDecrypted:
+				require.NotNil(t, updated)
+				assert.False(t, encryptedstore.IsEncrypted(updated))
+				assert.Equal(t, []byte("secret"), updated.Data["amazonec2credentialConfig-secretKey"])
+			}
+		})
+	}
+}
+
+func TestOnSecretChangeKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	encrypted, err := newEnvelope(t, "key1").Encrypt(ctx, newStoreSecret())
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	secretCache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+	secretCache.EXPECT().List("", gomock.Any()).Return([]*corev1.Secret{encrypted, newCloudCredential()}, nil)
+	secrets.EXPECT().Enqueue("cattle-system", "mc-node1")
+
+	// key2 is the current key, key1 is still available to decrypt existing secrets
+	rotated := newEnvelope(t, "key2", "key1")
+	c := &controller{
+		ctx:         ctx,
+		envelope:    rotated,
+		secrets:     secrets,
+		secretCache: secretCache,
+	}
+	require.NoError(t, c.enqueueStale(ctx))
+
+	secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		assert.Equal(t, "key2", secret.Annotations[encryptedstore.KeyIDAnnotation])
+		decrypted, err := newEnvelope(t, "key2").Decrypt(ctx, secret)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("config"), decrypted.Data["extractedConfig"])
+		return secret, nil
+	})
+	_, err = c.onSecretChange("", encrypted)
+	require.NoError(t, err)
+}
@@ -0,0 +1,187 @@
+// Package tokenusage reports anomalies in the usage history of ext tokens.
+package tokenusage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/ext/stores/tokens"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/wrangler"
+	corev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// AnomalyCondition is the type of the token condition reporting anomalous usage.
+	AnomalyCondition = "UsageAnomaly"
+	// NewNetworkReason is reported when a token is used from a network it was not used from before.
+	NewNetworkReason = "NewNetwork"
+	// DormantReason is reported when a token is used after not being used for longer than the dormancy period.
+	DormantReason = "Dormant"
+)
+
+type controller struct {
+	secrets corev1.SecretController
+	events  corev1.EventClient
+}
+
+// Register registers the controller flagging anomalous usage of ext tokens.
+func Register(ctx context.Context, wContext *wrangler.Context) {
+	c := &controller{
+		secrets: wContext.Core.Secret(),
+		events:  wContext.Core.Event(),
+	}
+	wContext.Core.Secret().OnChange(ctx, "ext-token-usage-anomaly", c.onChange)
+}
+
+// onChange checks the most recent usage record of a token against its usage history. Anomalies are reported by
+// setting the anomaly condition on the token and emitting a warning event for it. Each record is reported once.
+func (c *controller) onChange(_ string, secret *v1.Secret) (*v1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil || secret.Namespace != tokens.TokenNamespace ||
+		secret.Labels[tokens.SecretKindLabel] != tokens.SecretKindLabelValue {
+		return secret, nil
+	}
+
+	records, err := tokens.UsageFromSecret(secret)
+	if err != nil {
+		logrus.Errorf("[%s] Failed to read usage of token %s: %v", AnomalyCondition, secret.Name, err)
+		return secret, nil
+	}
+	reason, message := detect(records, dormancyPeriod())
+	if reason == "" {
+		return secret, nil
+	}
+
+	var conditions []metav1.Condition
+	if conditionsBytes := secret.Data[tokens.FieldConditions]; len(conditionsBytes) > 0 {
+		if err := json.Unmarshal(conditionsBytes, &conditions); err != nil {
+			logrus.Errorf("[%s] Failed to read conditions of token %s: %v", AnomalyCondition, secret.Name, err)
+			return secret, nil
+		}
+	}
+
+	seen := records[0].FirstSeen
+	if existing := meta.FindStatusCondition(conditions, AnomalyCondition); existing != nil && !existing.LastTransitionTime.Before(&seen) {
+		// The most recent record was already reported.
+		return secret, nil
+	}
+
+	// Emit the event first, so that it is retried if the condition can't be stored.
+	if _, err := c.events.Create(newEvent(secret, reason, message, seen)); err != nil {
+		return secret, fmt.Errorf("failed to create event for token %s: %w", secret.Name, err)
+	}
+
+	meta.RemoveStatusCondition(&conditions, AnomalyCondition)
+	meta.SetStatusCondition(&conditions, metav1.Condition{
+		Type:               AnomalyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: seen,
+	})
+	conditionsBytes, err := json.Marshal(conditions)
+	if err != nil {
+		return secret, err
+	}
+
+	secret = secret.DeepCopy()
+	secret.Data[tokens.FieldConditions] = conditionsBytes
+	return c.secrets.Update(secret)
+}
+
+// detect checks the most recent record against the earlier records. It returns the reason and message of the
+// anomaly, or empty strings if the usage is not anomalous. The first use of a token is never anomalous.
+func detect(records []ext.TokenUsageRecord, dormancy time.Duration) (string, string) {
+	if len(records) < 2 {
+		return "", ""
+	}
+	latest, earlier := records[0], records[1:]
+
+	var reasons, messages []string
+	lastUsed := earlier[0].LastSeen
+	for _, record := range earlier[1:] {
+		if lastUsed.Before(&record.LastSeen) {
+			lastUsed = record.LastSeen
+		}
+	}
+	if dormancy > 0 && latest.FirstSeen.Sub(lastUsed.Time) > dormancy {
+		reasons = append(reasons, DormantReason)
+		messages = append(messages, fmt.Sprintf("token used from %s after not being used since %s",
+			latest.SourceIP, lastUsed.UTC().Format(time.RFC3339)))
+	}
+
+	if network := networkOf(latest.SourceIP); network != "" {
+		known := false
+		for _, record := range earlier {
+			if networkOf(record.SourceIP) == network {
+				known = true
+				break
+			}
+		}
+		if !known {
+			reasons = append(reasons, NewNetworkReason)
+			messages = append(messages, fmt.Sprintf("token used from %s in network %s it was not used from before",
+				latest.SourceIP, network))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return "", ""
+	}
+
+	return reasons[0], strings.Join(messages, "; ")
+}
+
+// networkOf returns the /24 network of an IPv4 address or the /64 network of an IPv6 address, or the empty string if
+// the address can't be parsed.
+func networkOf(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+func newEvent(secret *v1.Secret, reason, message string, seen metav1.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: secret.Name + ".",
+			Namespace:    tokens.TokenNamespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: tokens.GV.String(),
+			Kind:       tokens.GVK.Kind,
+			Name:       secret.Name,
+			UID:        types.UID(secret.Data[tokens.FieldUID]),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "rancher"},
+		FirstTimestamp: seen,
+		LastTimestamp:  seen,
+		Count:          1,
+	}
+}
+
+func dormancyPeriod() time.Duration {
+	if settings.TokenUsageDormancyPeriod.Get() == "" {
+		return 0
+	}
+
+	return settings.TokenUsageDormancyPeriod.GetDuration()
+}
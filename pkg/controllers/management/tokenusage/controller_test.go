@@ -0,0 +1,211 @@
+package tokenusage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/ext/stores/tokens"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func record(firstSeen, lastSeen time.Time, sourceIP string) ext.TokenUsageRecord {
+	return ext.TokenUsageRecord{
+		FirstSeen: metav1.NewTime(firstSeen),
+		LastSeen:  metav1.NewTime(lastSeen),
+		Count:     1,
+		SourceIP:  sourceIP,
+		UserAgent: "kubectl",
+		ClusterID: "local",
+	}
+}
+
+func newTokenSecret(t *testing.T, records []ext.TokenUsageRecord, conditions []metav1.Condition) *v1.Secret {
+	t.Helper()
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "token-abcde",
+			Namespace: tokens.TokenNamespace,
+			Labels:    map[string]string{tokens.SecretKindLabel: tokens.SecretKindLabelValue},
+		},
+		Data: map[string][]byte{
+			tokens.FieldUID: []byte("2905498-kafld-lkad"),
+		},
+	}
+	if records != nil {
+		usageBytes, err := json.Marshal(records)
+		require.NoError(t, err)
+		secret.Data[tokens.FieldUsage] = usageBytes
+	}
+	if conditions != nil {
+		conditionsBytes, err := json.Marshal(conditions)
+		require.NoError(t, err)
+		secret.Data[tokens.FieldConditions] = conditionsBytes
+	}
+
+	return secret
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]struct {
+		records     []ext.TokenUsageRecord
+		wantReason  string
+		wantMessage string
+	}{
+		"no usage": {},
+		"first use": {
+			records: []ext.TokenUsageRecord{record(now, now, "10.0.0.1")},
+		},
+		"known network": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "10.0.0.2"),
+				record(now.Add(-time.Hour), now.Add(-time.Minute), "10.0.0.1"),
+			},
+		},
+		"new network": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "192.168.1.1"),
+				record(now.Add(-time.Hour), now.Add(-time.Minute), "10.0.0.1"),
+			},
+			wantReason:  NewNetworkReason,
+			wantMessage: "token used from 192.168.1.1 in network 192.168.1.0/24 it was not used from before",
+		},
+		"new IPv6 network": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "fd00:1::1"),
+				record(now.Add(-time.Hour), now.Add(-time.Minute), "fd00:2::1"),
+			},
+			wantReason:  NewNetworkReason,
+			wantMessage: "token used from fd00:1::1 in network fd00:1::/64 it was not used from before",
+		},
+		"dormant": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "10.0.0.1"),
+				record(now.Add(-50*time.Hour), now.Add(-49*time.Hour), "10.0.0.1"),
+				record(now.Add(-60*time.Hour), now.Add(-59*time.Hour), "10.0.0.2"),
+			},
+			wantReason:  DormantReason,
+			wantMessage: "token used from 10.0.0.1 after not being used since 2024-12-30T11:00:00Z",
+		},
+		"used by another client in between": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "10.0.0.1"),
+				record(now.Add(-50*time.Hour), now.Add(-49*time.Hour), "10.0.0.1"),
+				record(now.Add(-60*time.Hour), now.Add(-time.Hour), "10.0.0.2"),
+			},
+		},
+		"dormant and new network": {
+			records: []ext.TokenUsageRecord{
+				record(now, now, "192.168.1.1"),
+				record(now.Add(-50*time.Hour), now.Add(-49*time.Hour), "10.0.0.1"),
+			},
+			wantReason:  DormantReason,
+			wantMessage: "token used from 192.168.1.1 after not being used since 2024-12-30T11:00:00Z; token used from 192.168.1.1 in network 192.168.1.0/24 it was not used from before",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reason, message := detect(test.records, 24*time.Hour)
+			assert.Equal(t, test.wantReason, reason)
+			assert.Equal(t, test.wantMessage, message)
+		})
+	}
+
+	t.Run("dormancy check disabled", func(t *testing.T) {
+		reason, _ := detect([]ext.TokenUsageRecord{
+			record(now, now, "10.0.0.1"),
+			record(now.Add(-50*time.Hour), now.Add(-49*time.Hour), "10.0.0.1"),
+		}, 0)
+		assert.Empty(t, reason)
+	})
+}
+
+func TestOnChange(t *testing.T) {
+	previous := settings.TokenUsageDormancyPeriod.Get()
+	require.NoError(t, settings.TokenUsageDormancyPeriod.Set("24h"))
+	t.Cleanup(func() {
+		_ = settings.TokenUsageDormancyPeriod.Set(previous)
+	})
+
+	anomalous := []ext.TokenUsageRecord{
+		record(now, now, "192.168.1.1"),
+		record(now.Add(-time.Hour), now.Add(-time.Minute), "10.0.0.1"),
+	}
+
+	t.Run("anomaly is reported", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+		events := fake.NewMockClientInterface[*v1.Event, *v1.EventList](ctrl)
+
+		events.EXPECT().Create(gomock.Any()).DoAndReturn(func(event *v1.Event) (*v1.Event, error) {
+			assert.Equal(t, tokens.TokenNamespace, event.Namespace)
+			assert.Equal(t, "ext.cattle.io/v1", event.InvolvedObject.APIVersion)
+			assert.Equal(t, "Token", event.InvolvedObject.Kind)
+			assert.Equal(t, "token-abcde", event.InvolvedObject.Name)
+			assert.Equal(t, NewNetworkReason, event.Reason)
+			assert.Equal(t, v1.EventTypeWarning, event.Type)
+			return event, nil
+		})
+		secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *v1.Secret) (*v1.Secret, error) {
+			var conditions []metav1.Condition
+			require.NoError(t, json.Unmarshal(secret.Data[tokens.FieldConditions], &conditions))
+			require.Len(t, conditions, 1)
+			assert.Equal(t, AnomalyCondition, conditions[0].Type)
+			assert.Equal(t, metav1.ConditionTrue, conditions[0].Status)
+			assert.Equal(t, NewNetworkReason, conditions[0].Reason)
+			assert.True(t, conditions[0].LastTransitionTime.Equal(&anomalous[0].FirstSeen))
+			return secret, nil
+		})
+
+		c := &controller{secrets: secrets, events: events}
+		_, err := c.onChange("", newTokenSecret(t, anomalous, nil))
+		assert.NoError(t, err)
+	})
+
+	t.Run("anomaly is reported once", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+		events := fake.NewMockClientInterface[*v1.Event, *v1.EventList](ctrl)
+
+		c := &controller{secrets: secrets, events: events}
+		_, err := c.onChange("", newTokenSecret(t, anomalous, []metav1.Condition{{
+			Type:               AnomalyCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             NewNetworkReason,
+			LastTransitionTime: anomalous[0].FirstSeen,
+		}}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("usage is not anomalous", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+		events := fake.NewMockClientInterface[*v1.Event, *v1.EventList](ctrl)
+
+		c := &controller{secrets: secrets, events: events}
+		_, err := c.onChange("", newTokenSecret(t, anomalous[1:], nil))
+		assert.NoError(t, err)
+	})
+
+	t.Run("other secrets are ignored", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*v1.Secret, *v1.SecretList](ctrl)
+		events := fake.NewMockClientInterface[*v1.Event, *v1.EventList](ctrl)
+
+		c := &controller{secrets: secrets, events: events}
+		secret := newTokenSecret(t, anomalous, nil)
+		secret.Labels = nil
+		_, err := c.onChange("", secret)
+		assert.NoError(t, err)
+	})
+}
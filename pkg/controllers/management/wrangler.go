@@ -3,6 +3,7 @@
 import (
 	"context"
 	"github.com/rancher/rancher/pkg/clustermanager"
+	"github.com/rancher/rancher/pkg/controllers/management/accessrequest"
 	"github.com/rancher/rancher/pkg/controllers/management/aks"
 	"github.com/rancher/rancher/pkg/controllers/management/authprovisioningv2"
 	"github.com/rancher/rancher/pkg/controllers/management/clusterupstreamrefresher"
@@ -11,6 +12,7 @@
 	"github.com/rancher/rancher/pkg/controllers/management/gke"
 	"github.com/rancher/rancher/pkg/controllers/management/k3sbasedupgrade"
 	"github.com/rancher/rancher/pkg/controllers/management/oidcprovider"
+	"github.com/rancher/rancher/pkg/controllers/management/tokenusage"
 	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/wrangler"
@@ -22,6 +24,7 @@ func RegisterWrangler(ctx context.Context, wranglerContext *wrangler.Context, ma
 	eks.Register(ctx, wranglerContext, management)
 	gke.Register(ctx, wranglerContext, management)
 	clusterupstreamrefresher.Register(ctx, wranglerContext)
+	accessrequest.Register(ctx, wranglerContext)
 
 	feature.Register(ctx, management, wranglerContext)
 
@@ -35,5 +38,9 @@ func RegisterWrangler(ctx context.Context, wranglerContext *wrangler.Context, ma
 		oidcprovider.Register(ctx, wranglerContext)
 	}
 
+	if features.ExtTokens.Enabled() {
+		tokenusage.Register(ctx, wranglerContext)
+	}
+
 	return nil
 }
@@ -9,6 +9,7 @@
 	"github.com/rancher/rancher/pkg/controllers/managementlegacy/compose/common"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/cavalidator"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/clusterauthtoken"
+	"github.com/rancher/rancher/pkg/controllers/managementuser/etcdsnapshotpolicy"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/healthsyncer"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/machinerole"
 	"github.com/rancher/rancher/pkg/controllers/managementuser/networkpolicy"
@@ -43,6 +44,7 @@ func Register(ctx context.Context, mgmt *config.ScaledContext, cluster *config.U
 			if features.Provisioningv2ETCDSnapshotBackPopulation.Enabled() {
 				cluster.K3s = k3s.New(cluster.ControllerFactory)
 				snapshotbackpopulate.Register(ctx, cluster)
+				etcdsnapshotpolicy.Register(ctx, cluster)
 			}
 			cluster.Plan = upgrade.New(cluster.ControllerFactory)
 			rkecontrolplanecondition.Register(ctx,
@@ -0,0 +1,489 @@
+package etcdsnapshotpolicy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	k3scontrollers "github.com/k3s-io/api/pkg/generated/controllers/k3s.cattle.io/v1"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkev1controllers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/types/config"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	PrimaryDestination = "primary"
+	ReplicaDestination = "replica"
+
+	// syncInterval is the interval at which the destinations are reconciled with the etcd snapshot objects if
+	// neither the policy nor the etcd snapshot objects change.
+	syncInterval = 15 * time.Minute
+	// syncTimeout bounds the time spent on the S3 operations of a reconciliation.
+	syncTimeout = 10 * time.Minute
+	// metadataFolder is the folder next to the snapshots the distribution stores the snapshot metadata in.
+	metadataFolder = ".metadata"
+)
+
+type handler struct {
+	// ctx is the context of the controller, the background reconciliations stop once it is cancelled.
+	ctx                        context.Context
+	clusterName                string
+	clusterCache               provisioningcontrollers.ClusterCache
+	clusterController          provisioningcontrollers.ClusterController
+	controlPlaneCache          rkev1controllers.RKEControlPlaneCache
+	etcdSnapshotCache          rkev1controllers.ETCDSnapshotCache
+	etcdSnapshotController     rkev1controllers.ETCDSnapshotController
+	etcdSnapshotFileController k3scontrollers.ETCDSnapshotFileController
+	secretCache                corecontrollers.SecretCache
+	newStore                   func(config planner.S3Config) (objectStore, error)
+	now                        func() time.Time
+
+	lock        sync.Mutex
+	lastSync    time.Time
+	lastSyncKey string
+	// syncing is whether a reconciliation of the destinations is running.
+	syncing bool
+	// result is the status computed by the last reconciliation, until it is written to the cluster.
+	result *provv1.ETCDSnapshotPolicyStatus
+}
+
+// Register sets up the etcd snapshot policy controller. This controller applies the retention of the etcd snapshot
+// policy of the cluster to its S3 snapshots, replicates the retained snapshots to the replica destination and reports
+// the drift between the etcd snapshot objects and the destinations in the status of the cluster. Snapshots are pruned
+// by deleting them from the destinations and deleting the downstream snapshot, which the snapshotbackpopulate
+// controller then removes from the management cluster. The destinations are reconciled in the background, since the
+// S3 operations can take minutes.
+func Register(ctx context.Context, userContext *config.UserContext) {
+	h := &handler{
+		ctx:                        ctx,
+		clusterName:                userContext.ClusterName,
+		clusterCache:               userContext.Management.Wrangler.Provisioning.Cluster().Cache(),
+		clusterController:          userContext.Management.Wrangler.Provisioning.Cluster(),
+		controlPlaneCache:          userContext.Management.Wrangler.RKE.RKEControlPlane().Cache(),
+		etcdSnapshotCache:          userContext.Management.Wrangler.RKE.ETCDSnapshot().Cache(),
+		etcdSnapshotController:     userContext.Management.Wrangler.RKE.ETCDSnapshot(),
+		etcdSnapshotFileController: userContext.K3s.V1().ETCDSnapshotFile(),
+		secretCache:                userContext.Management.Wrangler.Core.Secret().Cache(),
+		newStore:                   newS3Store,
+		now:                        time.Now,
+	}
+
+	userContext.Management.Wrangler.Provisioning.Cluster().OnChange(ctx, "etcdsnapshotpolicy", h.OnChange)
+	userContext.Management.Wrangler.RKE.ETCDSnapshot().OnChange(ctx, "etcdsnapshotpolicy", h.OnSnapshotChange)
+}
+
+// OnSnapshotChange enqueues the cluster of the etcd snapshot, so that new snapshots are replicated and pruned in a
+// timely manner.
+func (h *handler) OnSnapshotChange(_ string, snapshot *rkev1.ETCDSnapshot) (*rkev1.ETCDSnapshot, error) {
+	if snapshot == nil || snapshot.Spec.ClusterName == "" {
+		return snapshot, nil
+	}
+
+	cluster, err := h.clusterCache.Get(snapshot.Namespace, snapshot.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		return snapshot, nil
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	if cluster.Status.ClusterName == h.clusterName && snapshotPolicy(cluster) != nil {
+		h.clusterController.Enqueue(cluster.Namespace, cluster.Name)
+	}
+	return snapshot, nil
+}
+
+// OnChange reconciles the S3 snapshots of the cluster with its etcd snapshot policy.
+func (h *handler) OnChange(_ string, cluster *provv1.Cluster) (*provv1.Cluster, error) {
+	if cluster == nil || cluster.DeletionTimestamp != nil || cluster.Status.ClusterName != h.clusterName {
+		return cluster, nil
+	}
+
+	policy := snapshotPolicy(cluster)
+	if policy == nil {
+		h.takeResult()
+		if cluster.Status.ETCDSnapshotPolicy == nil {
+			return cluster, nil
+		}
+		cluster = cluster.DeepCopy()
+		cluster.Status.ETCDSnapshotPolicy = nil
+		return h.clusterController.UpdateStatus(cluster)
+	}
+
+	controlPlane, err := h.controlPlaneCache.Get(cluster.Namespace, cluster.Name)
+	if apierrors.IsNotFound(err) {
+		return cluster, nil
+	} else if err != nil {
+		return cluster, err
+	}
+
+	// if controlplane is currently performing a restore, snapshots are not touched until the restore completed
+	if controlPlane.Spec.ETCDSnapshotRestore != nil && controlPlane.Status.ETCDSnapshotRestore != nil &&
+		controlPlane.Spec.ETCDSnapshotRestore.Generation != controlPlane.Status.ETCDSnapshotRestore.Generation {
+		h.clusterController.EnqueueAfter(cluster.Namespace, cluster.Name, 1*time.Minute)
+		return cluster, nil
+	}
+
+	snapshots, err := h.etcdSnapshotCache.List(cluster.Namespace, labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: cluster.Name,
+	}))
+	if err != nil {
+		return cluster, err
+	}
+
+	key, err := syncKey(policy, cluster.Spec.RKEConfig.ETCD.S3, snapshots)
+	if err != nil {
+		return cluster, err
+	}
+	if h.start(key) {
+		h.clusterController.EnqueueAfter(cluster.Namespace, cluster.Name, syncInterval)
+		go h.run(cluster.DeepCopy(), policy.DeepCopy(), snapshots)
+	}
+
+	status := h.takeResult()
+	if status == nil || equality.Semantic.DeepEqual(cluster.Status.ETCDSnapshotPolicy, status) {
+		return cluster, nil
+	}
+	cluster = cluster.DeepCopy()
+	cluster.Status.ETCDSnapshotPolicy = status
+	if cluster, err = h.clusterController.UpdateStatus(cluster); err != nil {
+		h.restoreResult(status)
+		return cluster, err
+	}
+	return cluster, nil
+}
+
+// start returns whether the destinations must be reconciled, which is the case if no reconciliation is running and
+// the policy or the etcd snapshot objects changed since the last reconciliation, or the sync interval passed.
+func (h *handler) start(key string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := h.now()
+	if h.syncing || (key == h.lastSyncKey && now.Sub(h.lastSync) < syncInterval) {
+		return false
+	}
+	h.syncing = true
+	h.lastSync = now
+	h.lastSyncKey = key
+	return true
+}
+
+// run reconciles the destinations and enqueues the cluster, so that the resulting status is written to it.
+func (h *handler) run(cluster *provv1.Cluster, policy *rkev1.ETCDSnapshotPolicy, snapshots []*rkev1.ETCDSnapshot) {
+	ctx, cancel := context.WithTimeout(h.ctx, syncTimeout)
+	defer cancel()
+	status := h.sync(ctx, cluster, policy, snapshots)
+
+	h.lock.Lock()
+	h.syncing = false
+	h.result = status
+	h.lock.Unlock()
+	h.clusterController.Enqueue(cluster.Namespace, cluster.Name)
+}
+
+// takeResult returns and clears the status computed by the last reconciliation, if it was not written yet.
+func (h *handler) takeResult() *provv1.ETCDSnapshotPolicyStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	result := h.result
+	h.result = nil
+	return result
+}
+
+// restoreResult keeps a status that failed to be written, so that it is written once the cluster is reconciled again,
+// unless a newer reconciliation finished in the meantime.
+func (h *handler) restoreResult(status *provv1.ETCDSnapshotPolicyStatus) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.result == nil {
+		h.result = status
+	}
+}
+
+// sync applies the policy to the S3 snapshots of the cluster and returns the resulting status.
+func (h *handler) sync(ctx context.Context, cluster *provv1.Cluster, policy *rkev1.ETCDSnapshotPolicy, snapshots []*rkev1.ETCDSnapshot) *provv1.ETCDSnapshotPolicyStatus {
+	logPrefix := getLogPrefix(cluster)
+	etcd := cluster.Spec.RKEConfig.ETCD
+	status := &provv1.ETCDSnapshotPolicyStatus{}
+
+	primary := provv1.ETCDSnapshotDestinationStatus{Name: PrimaryDestination}
+	if !planner.S3Enabled(etcd.S3) {
+		primary.Error = "S3 is not configured for the etcd snapshots of the cluster"
+		status.Destinations = append(status.Destinations, primary)
+		return status
+	}
+	primaryConfig, primaryStore, primaryObjects, err := h.openDestination(ctx, cluster.Namespace, etcd.S3, "", &primary)
+	if err != nil {
+		primary.Error = err.Error()
+		status.Destinations = append(status.Destinations, primary)
+		return status
+	}
+
+	var (
+		replica        *provv1.ETCDSnapshotDestinationStatus
+		replicaStore   objectStore
+		replicaObjects map[string]bool
+	)
+	if policy.Replica != nil {
+		replica = &provv1.ETCDSnapshotDestinationStatus{Name: ReplicaDestination}
+		_, replicaStore, replicaObjects, err = h.openDestination(ctx, cluster.Namespace, policy.Replica, primaryConfig.CloudCredentialName, replica)
+		if err != nil {
+			replica.Error = err.Error()
+			replicaStore = nil
+		}
+	}
+
+	plan := newSyncPlan(trackSnapshots(snapshots, primaryConfig), policy.Retention, primaryObjects, replicaObjects)
+	status.RetainedSnapshots = len(plan.retained)
+	primary.MissingSnapshots = plan.primaryMissing
+	primary.UntrackedObjects = plan.primaryUntracked
+
+	var primaryErrs, replicaErrs []error
+	for _, snapshot := range plan.prune {
+		logrus.Infof("%s pruning etcd snapshot %s", logPrefix, snapshot.name)
+		if err := h.prune(ctx, string(cluster.UID), snapshot, primaryStore, replicaStore, replicaObjects); err != nil {
+			primaryErrs = append(primaryErrs, fmt.Errorf("failed to prune snapshot %s: %w", snapshot.name, err))
+		}
+	}
+
+	if replica != nil && replicaStore != nil {
+		replica.MissingSnapshots = plan.replicaMissing
+		for _, snapshot := range plan.replicate {
+			logrus.Debugf("%s replicating etcd snapshot %s", logPrefix, snapshot.name)
+			if err := replicate(ctx, string(cluster.UID), snapshot.object, primaryStore, replicaStore); err != nil {
+				replica.MissingSnapshots = append(replica.MissingSnapshots, snapshot.name)
+				replicaErrs = append(replicaErrs, fmt.Errorf("failed to replicate snapshot %s: %w", snapshot.name, err))
+			}
+		}
+		replica.UntrackedObjects = plan.replicaUntracked
+		sort.Strings(replica.MissingSnapshots)
+	}
+
+	if err := errors.Join(primaryErrs...); err != nil {
+		primary.Error = err.Error()
+	}
+	status.Destinations = append(status.Destinations, primary)
+	if replica != nil {
+		if err := errors.Join(replicaErrs...); err != nil && replica.Error == "" {
+			replica.Error = err.Error()
+		}
+		status.Destinations = append(status.Destinations, *replica)
+	}
+	return status
+}
+
+// openDestination resolves the S3 configuration, records the destination in its status, and lists its objects.
+func (h *handler) openDestination(ctx context.Context, namespace string, s3 *rkev1.ETCDSnapshotS3, defaultCredentialName string, status *provv1.ETCDSnapshotDestinationStatus) (planner.S3Config, objectStore, map[string]bool, error) {
+	s3Config, err := planner.ResolveS3Config(h.secretCache, namespace, s3, defaultCredentialName)
+	if err != nil {
+		return s3Config, nil, nil, err
+	}
+	status.Endpoint = s3Config.Endpoint
+	status.Bucket = s3Config.Bucket
+	status.Folder = s3Config.Folder
+
+	store, err := h.newStore(s3Config)
+	if err != nil {
+		return s3Config, nil, nil, err
+	}
+	objects, err := store.list(ctx)
+	if err != nil {
+		return s3Config, nil, nil, err
+	}
+	return s3Config, store, objects, nil
+}
+
+// prune deletes the snapshot from the destinations and deletes the downstream snapshot. The etcd snapshot object is
+// deleted directly if there is no downstream snapshot. The object in the replica destination is only deleted if it was
+// replicated for the cluster with the given UID, since the replica destination may be shared.
+func (h *handler) prune(ctx context.Context, clusterUID string, snapshot trackedSnapshot, primaryStore, replicaStore objectStore, replicaObjects map[string]bool) error {
+	if err := primaryStore.delete(ctx, snapshot.object); err != nil {
+		return err
+	}
+	if err := primaryStore.delete(ctx, path.Join(metadataFolder, snapshot.object)); err != nil {
+		return err
+	}
+	if replicaStore != nil && replicaObjects[snapshot.object] {
+		replicatedBy, err := replicaStore.replicatedBy(ctx, snapshot.object)
+		if err != nil {
+			return err
+		}
+		if replicatedBy == clusterUID {
+			if err := replicaStore.delete(ctx, snapshot.object); err != nil {
+				return err
+			}
+		}
+	}
+
+	if snapshot.downstreamName != "" {
+		err := h.etcdSnapshotFileController.Delete(snapshot.downstreamName, &metav1.DeleteOptions{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	namespace, name, _ := strings.Cut(snapshot.name, "/")
+	if err := h.etcdSnapshotController.Delete(namespace, name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// replicate copies the object from the primary to the replica destination, recording that it was replicated for the
+// cluster with the given UID.
+func replicate(ctx context.Context, clusterUID, object string, primaryStore, replicaStore objectStore) error {
+	body, err := primaryStore.get(ctx, object)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return replicaStore.put(ctx, object, clusterUID, body)
+}
+
+// syncPlan is the set of operations that reconcile the destinations with the etcd snapshot objects.
+type syncPlan struct {
+	retained []trackedSnapshot
+	// prune are the snapshots that are not retained.
+	prune []trackedSnapshot
+	// replicate are the retained snapshots that exist in the primary but not in the replica destination.
+	replicate []trackedSnapshot
+	// primaryMissing are the names of the retained snapshots that don't exist in the primary destination.
+	primaryMissing []string
+	// primaryUntracked are the objects in the primary destination that don't belong to an etcd snapshot object.
+	primaryUntracked []string
+	// replicaMissing are the names of the retained snapshots that exist in neither destination.
+	replicaMissing []string
+	// replicaUntracked are the objects in the replica destination that don't belong to an etcd snapshot object. They
+	// are reported but never deleted, since they may belong to other clusters or be missing from the etcd snapshot
+	// cache.
+	replicaUntracked []string
+}
+
+// newSyncPlan determines how to reconcile the destinations with the snapshots tracked in the primary destination. The
+// replica objects are nil if there is no replica destination. Snapshots whose object is missing in the primary
+// destination are retained like any other snapshot, so that they are reported rather than pruned.
+func newSyncPlan(snapshots []trackedSnapshot, retention *rkev1.ETCDSnapshotRetention, primaryObjects, replicaObjects map[string]bool) syncPlan {
+	var plan syncPlan
+
+	keep := retained(snapshots, retention)
+	tracked := map[string]bool{}
+	for _, snapshot := range snapshots {
+		tracked[snapshot.object] = true
+		if !keep[snapshot.name] {
+			plan.prune = append(plan.prune, snapshot)
+			continue
+		}
+
+		plan.retained = append(plan.retained, snapshot)
+		if !primaryObjects[snapshot.object] {
+			plan.primaryMissing = append(plan.primaryMissing, snapshot.name)
+		}
+		if replicaObjects != nil && !replicaObjects[snapshot.object] {
+			if primaryObjects[snapshot.object] {
+				plan.replicate = append(plan.replicate, snapshot)
+			} else {
+				plan.replicaMissing = append(plan.replicaMissing, snapshot.name)
+			}
+		}
+	}
+
+	for object := range primaryObjects {
+		if !tracked[object] {
+			plan.primaryUntracked = append(plan.primaryUntracked, object)
+		}
+	}
+	for object := range replicaObjects {
+		if !tracked[object] {
+			plan.replicaUntracked = append(plan.replicaUntracked, object)
+		}
+	}
+
+	sort.Strings(plan.primaryMissing)
+	sort.Strings(plan.primaryUntracked)
+	sort.Strings(plan.replicaMissing)
+	sort.Strings(plan.replicaUntracked)
+	return plan
+}
+
+// trackSnapshots returns the successful S3 snapshots stored in the folder of the bucket of the primary destination.
+// Snapshots stored elsewhere, for instance before the S3 configuration changed, are not managed by the policy.
+func trackSnapshots(snapshots []*rkev1.ETCDSnapshot, primary planner.S3Config) []trackedSnapshot {
+	folder := strings.Trim(primary.Folder, "/")
+
+	var result []trackedSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.SnapshotFile.S3 == nil || snapshot.SnapshotFile.Status != "successful" {
+			continue
+		}
+		location, err := url.Parse(snapshot.SnapshotFile.Location)
+		if err != nil || location.Scheme != "s3" || location.Host != primary.Bucket {
+			continue
+		}
+		dir, object := path.Split(strings.TrimPrefix(location.Path, "/"))
+		if object == "" || strings.Trim(dir, "/") != folder {
+			continue
+		}
+
+		tracked := trackedSnapshot{
+			name:     snapshot.Namespace + "/" + snapshot.Name,
+			nodeName: snapshot.SnapshotFile.NodeName,
+			object:   object,
+		}
+		if snapshot.Annotations != nil {
+			tracked.downstreamName = snapshot.Annotations[capr.SnapshotNameAnnotation]
+		}
+		if snapshot.SnapshotFile.CreatedAt != nil {
+			tracked.createdAt = snapshot.SnapshotFile.CreatedAt.Time
+		}
+		result = append(result, tracked)
+	}
+	return result
+}
+
+// snapshotPolicy returns the etcd snapshot policy of the cluster, or nil if it has none.
+func snapshotPolicy(cluster *provv1.Cluster) *rkev1.ETCDSnapshotPolicy {
+	if cluster.Spec.RKEConfig == nil || cluster.Spec.RKEConfig.ETCD == nil {
+		return nil
+	}
+	return cluster.Spec.RKEConfig.ETCD.SnapshotPolicy
+}
+
+// syncKey returns a key that changes whenever the policy, the S3 configuration or the etcd snapshot objects change.
+func syncKey(policy *rkev1.ETCDSnapshotPolicy, s3 *rkev1.ETCDSnapshotS3, snapshots []*rkev1.ETCDSnapshot) (string, error) {
+	versions := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		versions = append(versions, snapshot.Name+"@"+snapshot.ResourceVersion)
+	}
+	sort.Strings(versions)
+
+	data, err := json.Marshal([]interface{}{policy, s3, versions})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func getLogPrefix(cluster *provv1.Cluster) string {
+	return fmt.Sprintf("[etcdsnapshotpolicy] rkecluster %s/%s:", cluster.Namespace, cluster.Name)
+}
@@ -0,0 +1,267 @@
+package etcdsnapshotpolicy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	k3s "github.com/k3s-io/api/k3s.cattle.io/v1"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeStore struct {
+	objects       map[string][]byte
+	replicatedFor map[string]string
+	failPut       bool
+}
+
+func (f *fakeStore) list(_ context.Context) (map[string]bool, error) {
+	result := map[string]bool{}
+	for name := range f.objects {
+		if !strings.Contains(name, "/") {
+			result[name] = true
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeStore) get(_ context.Context, name string) (io.ReadCloser, error) {
+	data, ok := f.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", name)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f *fakeStore) put(_ context.Context, name, replicatedBy string, body io.Reader) error {
+	if f.failPut {
+		return fmt.Errorf("access denied")
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	f.objects[name] = data
+	if f.replicatedFor == nil {
+		f.replicatedFor = map[string]string{}
+	}
+	f.replicatedFor[name] = replicatedBy
+	return nil
+}
+
+func (f *fakeStore) replicatedBy(_ context.Context, name string) (string, error) {
+	return f.replicatedFor[name], nil
+}
+
+func (f *fakeStore) delete(_ context.Context, name string) error {
+	delete(f.objects, name)
+	return nil
+}
+
+func newSnapshot(name, location string, createdAt time.Time) *rkev1.ETCDSnapshot {
+	return &rkev1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "fleet-default",
+			Name:        name,
+			Annotations: map[string]string{capr.SnapshotNameAnnotation: "downstream-" + name},
+		},
+		SnapshotFile: rkev1.ETCDSnapshotFile{
+			NodeName:  "node",
+			Location:  location,
+			CreatedAt: &metav1.Time{Time: createdAt},
+			S3:        &rkev1.ETCDSnapshotS3{Bucket: "primary"},
+			Status:    "successful",
+		},
+	}
+}
+
+func TestTrackSnapshots(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	local := newSnapshot("local", "file:///var/lib/rancher/rke2/server/db/snapshots/etcd-snapshot-1", createdAt)
+	local.SnapshotFile.S3 = nil
+	failed := newSnapshot("failed", "s3://primary/snapshots/etcd-snapshot-2", createdAt)
+	failed.SnapshotFile.Status = "failed"
+
+	tracked := trackSnapshots([]*rkev1.ETCDSnapshot{
+		newSnapshot("tracked", "s3://primary/snapshots/etcd-snapshot-3", createdAt),
+		newSnapshot("other-bucket", "s3://other/snapshots/etcd-snapshot-4", createdAt),
+		newSnapshot("other-folder", "s3://primary/archive/etcd-snapshot-5", createdAt),
+		local,
+		failed,
+	}, planner.S3Config{ETCDSnapshotS3: rkev1.ETCDSnapshotS3{Bucket: "primary", Folder: "/snapshots/"}})
+
+	assert.Equal(t, []trackedSnapshot{{
+		name:           "fleet-default/tracked",
+		downstreamName: "downstream-tracked",
+		nodeName:       "node",
+		createdAt:      createdAt,
+		object:         "etcd-snapshot-3",
+	}}, tracked)
+}
+
+func TestNewSyncPlan(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	snapshots := []trackedSnapshot{
+		{name: "new", nodeName: "node", createdAt: now, object: "new"},
+		{name: "previous", nodeName: "node", createdAt: now.Add(-time.Hour), object: "previous"},
+		{name: "lost", nodeName: "node", createdAt: now.Add(-2 * time.Hour), object: "lost"},
+		{name: "expired", nodeName: "node", createdAt: now.Add(-3 * time.Hour), object: "expired"},
+	}
+	retention := &rkev1.ETCDSnapshotRetention{Hourly: 3}
+
+	plan := newSyncPlan(snapshots, retention,
+		map[string]bool{"new": true, "previous": true, "expired": true, "manual": true},
+		map[string]bool{"previous": true, "expired": true, "stale": true},
+	)
+
+	assert.Equal(t, snapshots[:3], plan.retained)
+	assert.Equal(t, snapshots[3:], plan.prune)
+	assert.Equal(t, snapshots[:1], plan.replicate)
+	assert.Equal(t, []string{"lost"}, plan.primaryMissing)
+	assert.Equal(t, []string{"manual"}, plan.primaryUntracked)
+	assert.Equal(t, []string{"lost"}, plan.replicaMissing)
+	assert.Equal(t, []string{"stale"}, plan.replicaUntracked)
+
+	plan = newSyncPlan(snapshots, retention, map[string]bool{"new": true}, nil)
+	assert.Empty(t, plan.replicate, "nothing is replicated without a replica destination")
+	assert.Empty(t, plan.replicaMissing)
+	assert.Empty(t, plan.replicaUntracked)
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	primary := &fakeStore{objects: map[string][]byte{
+		"etcd-snapshot-new":               []byte("new"),
+		"etcd-snapshot-old":               []byte("old"),
+		".metadata/etcd-snapshot-old":     []byte("metadata"),
+		"etcd-snapshot-not-backpopulated": []byte("unknown"),
+		"etcd-snapshot-shared":            []byte("shared"),
+	}}
+	replica := &fakeStore{
+		objects: map[string][]byte{
+			"etcd-snapshot-old":    []byte("old"),
+			"etcd-snapshot-shared": []byte("shared"),
+			"stale":                []byte("stale"),
+		},
+		replicatedFor: map[string]string{
+			"etcd-snapshot-old":    "uid-cluster",
+			"etcd-snapshot-shared": "uid-other-cluster",
+		},
+	}
+
+	etcdSnapshotFileController := fake.NewMockNonNamespacedControllerInterface[*k3s.ETCDSnapshotFile, *k3s.ETCDSnapshotFileList](ctrl)
+	etcdSnapshotFileController.EXPECT().Delete("downstream-old", gomock.Any()).Return(nil)
+	etcdSnapshotFileController.EXPECT().Delete("downstream-shared", gomock.Any()).Return(nil)
+
+	h := &handler{
+		etcdSnapshotFileController: etcdSnapshotFileController,
+		newStore: func(config planner.S3Config) (objectStore, error) {
+			if config.Bucket == "replica" {
+				return replica, nil
+			}
+			return primary, nil
+		},
+	}
+
+	cluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test-cluster", UID: "uid-cluster"},
+		Spec: provv1.ClusterSpec{
+			RKEConfig: &provv1.RKEConfig{
+				ClusterConfiguration: rkev1.ClusterConfiguration{
+					ETCD: &rkev1.ETCD{
+						S3: &rkev1.ETCDSnapshotS3{Bucket: "primary", Folder: "snapshots", Endpoint: "minio.example.com"},
+					},
+				},
+			},
+		},
+	}
+	policy := &rkev1.ETCDSnapshotPolicy{
+		Retention: &rkev1.ETCDSnapshotRetention{Daily: 1},
+		Replica:   &rkev1.ETCDSnapshotS3{Bucket: "replica"},
+	}
+
+	status := h.sync(context.Background(), cluster, policy, []*rkev1.ETCDSnapshot{
+		newSnapshot("new", "s3://primary/snapshots/etcd-snapshot-new", now),
+		newSnapshot("old", "s3://primary/snapshots/etcd-snapshot-old", now.Add(-24*time.Hour)),
+		newSnapshot("shared", "s3://primary/snapshots/etcd-snapshot-shared", now.Add(-48*time.Hour)),
+	})
+
+	assert.Equal(t, &provv1.ETCDSnapshotPolicyStatus{
+		RetainedSnapshots: 1,
+		Destinations: []provv1.ETCDSnapshotDestinationStatus{
+			{
+				Name:             PrimaryDestination,
+				Endpoint:         "minio.example.com",
+				Bucket:           "primary",
+				Folder:           "snapshots",
+				UntrackedObjects: []string{"etcd-snapshot-not-backpopulated"},
+			},
+			{
+				Name:             ReplicaDestination,
+				Bucket:           "replica",
+				UntrackedObjects: []string{"stale"},
+			},
+		},
+	}, status)
+	assert.Equal(t, map[string][]byte{
+		"etcd-snapshot-new":               []byte("new"),
+		"etcd-snapshot-not-backpopulated": []byte("unknown"),
+	}, primary.objects)
+	assert.Equal(t, map[string][]byte{
+		"etcd-snapshot-new":    []byte("new"),
+		"etcd-snapshot-shared": []byte("shared"),
+		"stale":                []byte("stale"),
+	}, replica.objects, "only objects replicated for the cluster are pruned from the replica destination")
+	assert.Equal(t, "uid-cluster", replica.replicatedFor["etcd-snapshot-new"])
+
+	// failing replication is reported as drift of the replica destination
+	replica.failPut = true
+	replica.objects = map[string][]byte{}
+	status = h.sync(context.Background(), cluster, policy, []*rkev1.ETCDSnapshot{
+		newSnapshot("new", "s3://primary/snapshots/etcd-snapshot-new", now),
+	})
+	assert.Equal(t, []string{"fleet-default/new"}, status.Destinations[1].MissingSnapshots)
+	assert.Contains(t, status.Destinations[1].Error, "access denied")
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	h := &handler{now: func() time.Time { return now }}
+
+	assert.True(t, h.start("key"))
+	assert.False(t, h.start("changed"), "no reconciliation starts while one is running")
+
+	h.syncing = false
+	h.result = &provv1.ETCDSnapshotPolicyStatus{RetainedSnapshots: 1}
+	assert.False(t, h.start("key"), "the sync interval did not pass")
+	assert.True(t, h.start("changed"))
+
+	status := h.takeResult()
+	assert.Equal(t, 1, status.RetainedSnapshots)
+	assert.Nil(t, h.takeResult())
+
+	h.restoreResult(status)
+	h.restoreResult(&provv1.ETCDSnapshotPolicyStatus{RetainedSnapshots: 2})
+	assert.Equal(t, status, h.takeResult(), "a failed write doesn't replace a newer result")
+}
@@ -0,0 +1,85 @@
+package etcdsnapshotpolicy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+)
+
+// trackedSnapshot is an etcd snapshot object whose snapshot is stored in the primary destination.
+type trackedSnapshot struct {
+	name           string
+	downstreamName string
+	nodeName       string
+	createdAt      time.Time
+	// object is the name of the snapshot object relative to the folder of the destination.
+	object string
+}
+
+// retentionPeriod assigns snapshots to the periods of a retention scheme, of which count are retained.
+type retentionPeriod struct {
+	count int
+	key   func(t time.Time) string
+}
+
+// retained returns the names of the snapshots retained by the grandfather-father-son retention. For each period, the
+// newest snapshot of each of the most recent periods containing a snapshot is retained, per node. Snapshots without a
+// creation time can't be assigned to a period and are always retained, as are all snapshots if the retention retains
+// nothing.
+func retained(snapshots []trackedSnapshot, retention *rkev1.ETCDSnapshotRetention) map[string]bool {
+	result := map[string]bool{}
+
+	if retention == nil || retention.Hourly+retention.Daily+retention.Weekly+retention.Monthly <= 0 {
+		for _, snapshot := range snapshots {
+			result[snapshot.name] = true
+		}
+		return result
+	}
+
+	periods := []retentionPeriod{
+		{count: retention.Hourly, key: func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{count: retention.Daily, key: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{count: retention.Weekly, key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{count: retention.Monthly, key: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	byNode := map[string][]trackedSnapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.createdAt.IsZero() {
+			result[snapshot.name] = true
+			continue
+		}
+		byNode[snapshot.nodeName] = append(byNode[snapshot.nodeName], snapshot)
+	}
+
+	for _, nodeSnapshots := range byNode {
+		sort.Slice(nodeSnapshots, func(i, j int) bool {
+			if nodeSnapshots[i].createdAt.Equal(nodeSnapshots[j].createdAt) {
+				return nodeSnapshots[i].name > nodeSnapshots[j].name
+			}
+			return nodeSnapshots[i].createdAt.After(nodeSnapshots[j].createdAt)
+		})
+
+		for _, period := range periods {
+			seen := map[string]bool{}
+			for _, snapshot := range nodeSnapshots {
+				if len(seen) >= period.count {
+					break
+				}
+				key := period.key(snapshot.createdAt.UTC())
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				result[snapshot.name] = true
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,77 @@
+package etcdsnapshotpolicy
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetained(t *testing.T) {
+	t.Parallel()
+
+	// Hourly snapshots of two nodes from Friday 2025-02-28 00:00 to Tuesday 2025-03-04 23:00.
+	start := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	var snapshots []trackedSnapshot
+	for i := 0; i < 5*24; i++ {
+		createdAt := start.Add(time.Duration(i) * time.Hour)
+		for _, node := range []string{"a", "b"} {
+			snapshots = append(snapshots, trackedSnapshot{
+				name:      node + "-" + createdAt.Format("0102T15"),
+				nodeName:  node,
+				createdAt: createdAt,
+			})
+		}
+	}
+	snapshots = append(snapshots, trackedSnapshot{name: "unknown-creation-time", nodeName: "a"})
+
+	tests := []struct {
+		name      string
+		retention *rkev1.ETCDSnapshotRetention
+		expected  []string
+	}{
+		{
+			name:      "hourly",
+			retention: &rkev1.ETCDSnapshotRetention{Hourly: 2},
+			expected:  []string{"a-0304T22", "a-0304T23", "b-0304T22", "b-0304T23"},
+		},
+		{
+			name:      "daily",
+			retention: &rkev1.ETCDSnapshotRetention{Daily: 3},
+			expected:  []string{"a-0302T23", "a-0303T23", "a-0304T23", "b-0302T23", "b-0303T23", "b-0304T23"},
+		},
+		{
+			name:      "weekly",
+			retention: &rkev1.ETCDSnapshotRetention{Weekly: 4},
+			expected:  []string{"a-0302T23", "a-0304T23", "b-0302T23", "b-0304T23"},
+		},
+		{
+			name:      "monthly",
+			retention: &rkev1.ETCDSnapshotRetention{Monthly: 12},
+			expected:  []string{"a-0228T23", "a-0304T23", "b-0228T23", "b-0304T23"},
+		},
+		{
+			name:      "periods overlap",
+			retention: &rkev1.ETCDSnapshotRetention{Hourly: 1, Daily: 2, Monthly: 2},
+			expected:  []string{"a-0228T23", "a-0303T23", "a-0304T23", "b-0228T23", "b-0303T23", "b-0304T23"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			expected := map[string]bool{"unknown-creation-time": true}
+			for _, name := range tt.expected {
+				expected[name] = true
+			}
+			assert.Equal(t, expected, retained(snapshots, tt.retention))
+		})
+	}
+
+	for _, retention := range []*rkev1.ETCDSnapshotRetention{nil, {}} {
+		assert.Len(t, retained(snapshots, retention), len(snapshots), "all snapshots are retained if the retention retains nothing")
+	}
+}
@@ -0,0 +1,186 @@
+package etcdsnapshotpolicy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rancher/rancher/pkg/capr/planner"
+)
+
+const (
+	defaultRegion = "us-east-1"
+	// replicatedByMetadata is the user metadata of the objects replicated by the controller, holding the UID of the
+	// cluster they were replicated for.
+	replicatedByMetadata = "Rancher-Replicated-By"
+)
+
+// objectStore is the folder of an S3 bucket snapshots are stored in. Objects are named relative to the folder.
+type objectStore interface {
+	// list returns the names of the objects directly within the folder.
+	list(ctx context.Context) (map[string]bool, error)
+	get(ctx context.Context, name string) (io.ReadCloser, error)
+	// put uploads the object, recording the UID of the cluster it is replicated for.
+	put(ctx context.Context, name, replicatedBy string, body io.Reader) error
+	// replicatedBy returns the UID of the cluster the object was replicated for, or an empty string if it was not
+	// replicated by the controller.
+	replicatedBy(ctx context.Context, name string) (string, error)
+	delete(ctx context.Context, name string) error
+}
+
+type s3Store struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// newS3Store returns the object store for the folder of the bucket of the S3 configuration.
+func newS3Store(config planner.S3Config) (objectStore, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket name was not set")
+	}
+
+	awsConfig := aws.NewConfig().
+		WithRegion(defaultRegion).
+		WithCredentials(credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""))
+	if config.Region != "" {
+		awsConfig.WithRegion(config.Region)
+	}
+	if config.Endpoint != "" {
+		endpoint := config.Endpoint
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		// S3-compatible endpoints other than AWS don't generally support virtual-hosted-style requests.
+		awsConfig.WithEndpoint(endpoint).WithS3ForcePathStyle(!strings.HasSuffix(strings.TrimSuffix(config.Endpoint, "/"), "amazonaws.com"))
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.SkipSSLVerify}
+	if config.EndpointCA != "" && !config.SkipSSLVerify {
+		pool, err := endpointCAPool(config.EndpointCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	awsConfig.WithHTTPClient(&http.Client{Transport: transport})
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error getting new aws session: %w", err)
+	}
+
+	client := s3.New(sess)
+	store := &s3Store{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+		bucket:   config.Bucket,
+	}
+	if folder := strings.Trim(config.Folder, "/"); folder != "" {
+		store.prefix = folder + "/"
+	}
+	return store, nil
+}
+
+// endpointCAPool returns a certificate pool holding the endpoint CA, which is in base64-encoded or plain PEM format.
+func endpointCAPool(endpointCA string) (*x509.CertPool, error) {
+	pem := []byte(endpointCA)
+	if decoded, err := base64.StdEncoding.DecodeString(endpointCA); err == nil {
+		pem = decoded
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("endpoint CA does not contain a PEM encoded certificate")
+	}
+	return pool, nil
+}
+
+func (s *s3Store) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *s3Store) list(ctx context.Context) (map[string]bool, error) {
+	objects := map[string]bool{}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.prefix),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			if name := path.Base(aws.StringValue(object.Key)); name != "" && aws.StringValue(object.Key) != s.prefix {
+				objects[name] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects of bucket %s: %w", s.bucket, err)
+	}
+	return objects, nil
+}
+
+func (s *s3Store) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s of bucket %s: %w", s.key(name), s.bucket, err)
+	}
+	return output.Body, nil
+}
+
+func (s *s3Store) put(ctx context.Context, name, replicatedBy string, body io.Reader) error {
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.key(name)),
+		Body:     body,
+		Metadata: map[string]*string{replicatedByMetadata: aws.String(replicatedBy)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object %s to bucket %s: %w", s.key(name), s.bucket, err)
+	}
+	return nil
+}
+
+func (s *s3Store) replicatedBy(ctx context.Context, name string) (string, error) {
+	output, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get metadata of object %s of bucket %s: %w", s.key(name), s.bucket, err)
+	}
+	// S3-compatible stores don't agree on the case of the metadata keys they return.
+	for key, value := range output.Metadata {
+		if strings.EqualFold(key, replicatedByMetadata) {
+			return aws.StringValue(value), nil
+		}
+	}
+	return "", nil
+}
+
+func (s *s3Store) delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s of bucket %s: %w", s.key(name), s.bucket, err)
+	}
+	return nil
+}
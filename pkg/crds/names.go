@@ -143,6 +143,7 @@ func ClusterAuthCRDs() []string {
 // MCMCRDs returns a list of CRD names needed for Multi Cluster Management.
 func MCMCRDs() []string {
 	return []string{
+		"accessrequests.management.cattle.io",
 		"authconfigs.management.cattle.io",
 		"clusters.management.cattle.io",
 		"clusterregistrationtokens.management.cattle.io",
@@ -201,6 +202,7 @@ func SCCRegistrationCRDs() []string {
 
 // MigratedResources map list of resource that have been migrated after all resource have a CRD this can be removed.
 var MigratedResources = map[string]bool{
+	"accessrequests.management.cattle.io":                             true,
 	"activedirectoryproviders.management.cattle.io":                   false,
 	"apiservices.management.cattle.io":                                false,
 	"apps.catalog.cattle.io":                                          false,
@@ -13,6 +13,7 @@
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/types/config/dialer"
 	"github.com/rancher/rancher/pkg/wrangler"
@@ -157,7 +158,7 @@ func (f *Factory) clusterDialer(clusterName, address string, retryOnError bool)
 
 	if f.TunnelServer.HasSession(cluster.Name) {
 		logrus.Tracef("dialerFactory: tunnel session found for cluster [%s]", cluster.Name)
-		cd := f.TunnelServer.Dialer(cluster.Name)
+		cd := tunnelserver.Dialer(f.TunnelServer, cluster.Name)
 		return func(ctx context.Context, network, address string) (net.Conn, error) {
 			logrus.Tracef("dialerFactory: returning network [%s] and address [%s] as clusterDialer", network, address)
 			return cd(ctx, network, address)
@@ -173,7 +174,7 @@ func (f *Factory) clusterDialer(clusterName, address string, retryOnError bool)
 	for i := 0; i < 4; i++ {
 		if f.TunnelServer.HasSession(cluster.Name) {
 			logrus.Debugf("Cluster [%s] has reconnected, resuming", cluster.Name)
-			cd := f.TunnelServer.Dialer(cluster.Name)
+			cd := tunnelserver.Dialer(f.TunnelServer, cluster.Name)
 			return func(ctx context.Context, network, address string) (net.Conn, error) {
 				logrus.Tracef("dialerFactory: returning network [%s] and address [%s] as clusterDialer", network, address)
 				return cd(ctx, network, address)
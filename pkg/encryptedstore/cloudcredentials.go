@@ -0,0 +1,29 @@
+package encryptedstore
+
+import (
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+)
+
+// HostedCloudCredentials returns the cloud credentials read by the operator of a hosted cluster, in the format
+// <namespace>:<name>. These are stored unencrypted, since the cluster operators read them directly.
+func HostedCloudCredentials(obj *v3.Cluster) ([]string, error) {
+	var credential string
+	switch {
+	case obj.Spec.EKSConfig != nil:
+		credential = obj.Spec.EKSConfig.AmazonCredentialSecret
+	case obj.Spec.AKSConfig != nil:
+		credential = obj.Spec.AKSConfig.AzureCredentialSecret
+	case obj.Spec.GKEConfig != nil:
+		credential = obj.Spec.GKEConfig.GoogleCredentialSecret
+	}
+	if credential == "" {
+		return nil, nil
+	}
+	if !strings.Contains(credential, ":") {
+		credential = namespace.GlobalNamespace + ":" + credential
+	}
+	return []string{credential}, nil
+}
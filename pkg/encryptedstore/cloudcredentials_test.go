@@ -0,0 +1,47 @@
+package encryptedstore
+
+import (
+	"testing"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	eksv1 "github.com/rancher/eks-operator/pkg/apis/eks.cattle.io/v1"
+	gkev1 "github.com/rancher/gke-operator/pkg/apis/gke.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostedCloudCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v3.ClusterSpec
+		want []string
+	}{
+		{
+			name: "eks",
+			spec: v3.ClusterSpec{EKSConfig: &eksv1.EKSClusterConfigSpec{AmazonCredentialSecret: "cattle-global-data:cc-eks"}},
+			want: []string{"cattle-global-data:cc-eks"},
+		},
+		{
+			name: "aks without namespace",
+			spec: v3.ClusterSpec{AKSConfig: &aksv1.AKSClusterConfigSpec{AzureCredentialSecret: "cc-aks"}},
+			want: []string{"cattle-global-data:cc-aks"},
+		},
+		{
+			name: "gke",
+			spec: v3.ClusterSpec{GKEConfig: &gkev1.GKEClusterConfigSpec{GoogleCredentialSecret: "cattle-global-data:cc-gke"}},
+			want: []string{"cattle-global-data:cc-gke"},
+		},
+		{
+			name: "not hosted",
+			spec: v3.ClusterSpec{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HostedCloudCredentials(&v3.Cluster{Spec: tt.spec})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
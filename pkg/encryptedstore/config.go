@@ -0,0 +1,148 @@
+package encryptedstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ProviderLocal = "local"
+	ProviderKMSv2 = "kmsv2"
+	ProviderVault = "vault"
+	// ProviderIdentity stores secrets in plaintext. Together with decrypt-only providers, it decrypts the secrets
+	// that were encrypted before.
+	ProviderIdentity = "identity"
+)
+
+// Config configures the key provider used to encrypt the data encryption keys of secrets.
+//
+// An example configuration using the transit secrets engine of HashiCorp Vault:
+//
+//	provider: vault
+//	cloudCredentials: true
+//	vault:
+//	  address: https://vault.example.com:8200
+//	  key: rancher
+//	  tokenFile: /var/run/secrets/vault/token
+//	decryptOnly:
+//	- provider: local
+//	  local:
+//	    keyFile: /etc/rancher/encryption/keys.yaml
+type Config struct {
+	ProviderConfig `json:",inline"`
+	// CloudCredentials enables the encryption of cloud credentials. Credentials used by hosted clusters are left
+	// unencrypted, since the cluster operators read them directly.
+	CloudCredentials bool `json:"cloudCredentials,omitempty"`
+	// DecryptOnly are the key providers which are only used to decrypt secrets, which are then encrypted again by
+	// Provider. This migrates secrets from one provider to another, or to plaintext with the identity provider.
+	DecryptOnly []ProviderConfig `json:"decryptOnly,omitempty"`
+}
+
+// ProviderConfig configures a key provider.
+type ProviderConfig struct {
+	// Provider is the name of the key provider, one of local, kmsv2, vault or identity.
+	Provider string `json:"provider"`
+
+	Local *LocalConfig `json:"local,omitempty"`
+	KMSv2 *KMSv2Config `json:"kmsv2,omitempty"`
+	Vault *VaultConfig `json:"vault,omitempty"`
+}
+
+// LoadConfig reads the configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption provider config: %w", err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption provider config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// NewKeyProvider returns the key provider of the configuration, which is nil for the identity.
+func NewKeyProvider(config *ProviderConfig) (KeyProvider, error) {
+	switch config.Provider {
+	case ProviderIdentity:
+		return nil, nil
+	case ProviderLocal:
+		if config.Local == nil {
+			return nil, fmt.Errorf("missing configuration of the %s key provider", config.Provider)
+		}
+		return NewLocalKeyProvider(config.Local.KeyFile)
+	case ProviderKMSv2:
+		if config.KMSv2 == nil {
+			return nil, fmt.Errorf("missing configuration of the %s key provider", config.Provider)
+		}
+		return NewKMSv2KeyProvider(config.KMSv2)
+	case ProviderVault:
+		if config.Vault == nil {
+			return nil, fmt.Errorf("missing configuration of the %s key provider", config.Provider)
+		}
+		return NewVaultKeyProvider(config.Vault)
+	}
+	return nil, fmt.Errorf("unknown key provider %q", config.Provider)
+}
+
+var (
+	defaultEnvelope         *Envelope
+	encryptCloudCredentials bool
+	defaultMu               sync.RWMutex
+)
+
+// Configure enables the envelope encryption of secrets with the key provider configured in the file at path. Secrets
+// are stored unencrypted if Configure is never called.
+func Configure(ctx context.Context, path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	provider, err := NewKeyProvider(&config.ProviderConfig)
+	if err != nil {
+		return err
+	}
+	var decryptOnly []KeyProvider
+	for i := range config.DecryptOnly {
+		p, err := NewKeyProvider(&config.DecryptOnly[i])
+		if err != nil {
+			return fmt.Errorf("invalid decrypt-only key provider: %w", err)
+		}
+		if p == nil {
+			return fmt.Errorf("invalid decrypt-only key provider: %s can't decrypt", ProviderIdentity)
+		}
+		decryptOnly = append(decryptOnly, p)
+	}
+	if provider != nil {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if _, err := provider.KeyID(ctx); err != nil {
+			return fmt.Errorf("key provider %s is not ready: %w", provider.Name(), err)
+		}
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultEnvelope = NewEnvelope(provider, decryptOnly...)
+	encryptCloudCredentials = config.CloudCredentials
+	return nil
+}
+
+// Default returns the envelope configured by Configure, or nil if encryption is disabled.
+func Default() *Envelope {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultEnvelope
+}
+
+// CloudCredentialsEnabled returns whether cloud credentials are encrypted.
+func CloudCredentialsEnabled() bool {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultEnvelope != nil && defaultEnvelope.provider != nil && encryptCloudCredentials
+}
@@ -0,0 +1,349 @@
+package encryptedstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	// ProviderAnnotation is the name of the key provider which wrapped the data encryption key of a secret.
+	ProviderAnnotation = "encryption.cattle.io/provider"
+	// KeyIDAnnotation is the ID of the key encryption key which wrapped the data encryption key of a secret.
+	KeyIDAnnotation = "encryption.cattle.io/key-id"
+	// DEKAnnotation holds the wrapped data encryption key of a secret.
+	DEKAnnotation = "encryption.cattle.io/dek"
+
+	dekSize          = 32
+	dekCacheSize     = 1024
+	dekCacheDuration = 10 * time.Minute
+	providerTimeout  = 10 * time.Second
+)
+
+// valuePrefix marks encrypted values, so values written in plaintext to an encrypted secret are told apart.
+var valuePrefix = []byte("cattle:enc:v1:")
+
+// KeyProvider wraps and unwraps data encryption keys with a key encryption key it manages.
+type KeyProvider interface {
+	// Name returns the name of the provider.
+	Name() string
+	// KeyID returns the ID of the key encryption key currently used to wrap keys.
+	KeyID(ctx context.Context) (string, error)
+	// Wrap encrypts a data encryption key with the current key encryption key.
+	Wrap(ctx context.Context, dek []byte) (*WrappedKey, error)
+	// Unwrap decrypts a data encryption key wrapped by Wrap.
+	Unwrap(ctx context.Context, key *WrappedKey) ([]byte, error)
+}
+
+// WrappedKey is a data encryption key encrypted by a KeyProvider.
+type WrappedKey struct {
+	KeyID       string            `json:"keyID"`
+	Ciphertext  []byte            `json:"ciphertext"`
+	Annotations map[string][]byte `json:"annotations,omitempty"`
+}
+
+// Envelope encrypts the data of secrets with a data encryption key unique to each secret, which is in turn encrypted
+// by a KeyProvider and stored in the annotations of the secret. The keys of the data are left in plaintext, so the
+// type of the secret can still be determined without decrypting it.
+type Envelope struct {
+	provider    KeyProvider
+	decryptOnly []KeyProvider
+	deks        *cache.LRUExpireCache
+}
+
+// NewEnvelope returns an Envelope using provider to wrap the data encryption keys. Keys wrapped by the decryptOnly
+// providers are unwrapped as well, so secrets can be migrated from one provider to another. A nil provider is the
+// identity: secrets are stored in plaintext, and encrypted secrets are decrypted when they are written again.
+func NewEnvelope(provider KeyProvider, decryptOnly ...KeyProvider) *Envelope {
+	return &Envelope{
+		provider:    provider,
+		decryptOnly: decryptOnly,
+		deks:        cache.NewLRUExpireCache(dekCacheSize),
+	}
+}
+
+// Provider returns the key provider of the envelope, which is nil for the identity.
+func (e *Envelope) Provider() KeyProvider {
+	return e.provider
+}
+
+// IsEncrypted returns whether the secret holds an envelope encrypted data encryption key.
+func IsEncrypted(secret *corev1.Secret) bool {
+	return secret != nil && secret.Annotations[DEKAnnotation] != ""
+}
+
+// Encrypt returns a copy of the secret with all of its data encrypted by a new data encryption key. StringData is
+// merged into Data beforehand. Values which are already encrypted are decrypted and encrypted again. The identity
+// returns the decrypted copy.
+func (e *Envelope) Encrypt(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	plain, err := e.Decrypt(ctx, secret)
+	if err != nil || e.provider == nil {
+		return plain, err
+	}
+
+	dek := make([]byte, dekSize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("failed to generate data encryption key: %w", err)
+	}
+	wrapped, err := e.wrap(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+	encodedKey, err := encodeWrappedKey(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	result := plain.DeepCopy()
+	result.StringData = nil
+	for k, v := range plain.Data {
+		ciphertext, err := seal(dek, []byte(k), v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s of secret %s/%s: %w", k, secret.Namespace, secret.Name, err)
+		}
+		result.Data[k] = ciphertext
+	}
+	if result.Annotations == nil {
+		result.Annotations = map[string]string{}
+	}
+	result.Annotations[ProviderAnnotation] = e.provider.Name()
+	result.Annotations[KeyIDAnnotation] = wrapped.KeyID
+	result.Annotations[DEKAnnotation] = encodedKey
+	e.deks.Add(encodedKey, dek, dekCacheDuration)
+	return result, nil
+}
+
+// Decrypt returns a copy of the secret with its data decrypted and StringData merged into Data. The encryption
+// annotations are removed from the copy, so writing it back stores the secret in plaintext.
+func (e *Envelope) Decrypt(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	result := secret.DeepCopy()
+	if result.Data == nil {
+		result.Data = map[string][]byte{}
+	}
+	for k, v := range result.StringData {
+		result.Data[k] = []byte(v)
+	}
+	result.StringData = nil
+
+	if IsEncrypted(secret) {
+		for k, v := range secret.Data {
+			if !bytes.HasPrefix(v, valuePrefix) {
+				continue
+			}
+			plaintext, err := e.DecryptValue(ctx, secret.Annotations, k, v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s of secret %s/%s: %w", k, secret.Namespace, secret.Name, err)
+			}
+			result.Data[k] = plaintext
+		}
+	}
+
+	delete(result.Annotations, ProviderAnnotation)
+	delete(result.Annotations, KeyIDAnnotation)
+	delete(result.Annotations, DEKAnnotation)
+	return result, nil
+}
+
+// DecryptValue decrypts the value of key in the data of a secret with the given annotations. Values which are not
+// encrypted are returned as they are. The data encryption key is unwrapped by the provider of the envelope or one of
+// its decrypt-only providers, whichever has the name the key was wrapped by.
+func (e *Envelope) DecryptValue(ctx context.Context, annotations map[string]string, key string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, valuePrefix) {
+		return value, nil
+	}
+	dek, err := e.unwrap(ctx, annotations[ProviderAnnotation], annotations[DEKAnnotation])
+	if err != nil {
+		return nil, err
+	}
+	return open(dek, []byte(key), value)
+}
+
+// NeedsEncryption returns whether the secret has values which are not encrypted, or whose data encryption key was
+// not wrapped by the current key encryption key. For the identity, it returns whether the secret is encrypted.
+func (e *Envelope) NeedsEncryption(ctx context.Context, secret *corev1.Secret) (bool, error) {
+	if e.provider == nil {
+		return IsEncrypted(secret), nil
+	}
+	if len(secret.StringData) > 0 {
+		return true, nil
+	}
+	for _, v := range secret.Data {
+		if !bytes.HasPrefix(v, valuePrefix) {
+			return true, nil
+		}
+	}
+	if len(secret.Data) == 0 {
+		return false, nil
+	}
+	if secret.Annotations[ProviderAnnotation] != e.provider.Name() {
+		return true, nil
+	}
+	keyID, err := e.keyID(ctx)
+	if err != nil {
+		return false, err
+	}
+	return secret.Annotations[KeyIDAnnotation] != keyID, nil
+}
+
+func (e *Envelope) keyID(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+	return e.provider.KeyID(ctx)
+}
+
+func (e *Envelope) wrap(ctx context.Context, dek []byte) (*WrappedKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+	wrapped, err := e.provider.Wrap(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data encryption key with key provider %s: %w", e.provider.Name(), err)
+	}
+	return wrapped, nil
+}
+
+// unwrap returns the data encryption key wrapped by the provider with the given name, trying the provider of the
+// envelope first and the decrypt-only providers in order.
+func (e *Envelope) unwrap(ctx context.Context, providerName, encodedKey string) ([]byte, error) {
+	if dek, ok := e.deks.Get(encodedKey); ok {
+		return dek.([]byte), nil
+	}
+	wrapped, err := decodeWrappedKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, provider := range append([]KeyProvider{e.provider}, e.decryptOnly...) {
+		if provider == nil || provider.Name() != providerName {
+			continue
+		}
+		dek, err := e.unwrapWith(ctx, provider, wrapped)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		e.deks.Add(encodedKey, dek, dekCacheDuration)
+		return dek, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("data encryption key was wrapped by key provider %q, which is not configured", providerName)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (e *Envelope) unwrapWith(ctx context.Context, provider KeyProvider, wrapped *WrappedKey) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+	dek, err := provider.Unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data encryption key with key provider %s: %w", provider.Name(), err)
+	}
+	if len(dek) != dekSize {
+		return nil, fmt.Errorf("invalid data encryption key size %d", len(dek))
+	}
+	return dek, nil
+}
+
+func encodeWrappedKey(key *WrappedKey) (string, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func decodeWrappedKey(encodedKey string) (*WrappedKey, error) {
+	if encodedKey == "" {
+		return nil, fmt.Errorf("missing %s annotation", DEKAnnotation)
+	}
+	data, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", DEKAnnotation, err)
+	}
+	key := &WrappedKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", DEKAnnotation, err)
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, returning the value prefix, the nonce and the ciphertext.
+func seal(key, additionalData, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	result := append(append([]byte{}, valuePrefix...), nonce...)
+	return aead.Seal(result, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a value encrypted by seal.
+func open(key, additionalData, value []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	value = bytes.TrimPrefix(value, valuePrefix)
+	if len(value) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value is too short")
+	}
+	return aead.Open(nil, value[:aead.NonceSize()], value[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts the secret with the envelope configured by Configure. The secret is returned as it is if
+// encryption is disabled, and decrypted if the identity is configured.
+func EncryptSecret(secret *corev1.Secret) (*corev1.Secret, error) {
+	envelope := Default()
+	if envelope == nil {
+		return secret, nil
+	}
+	return envelope.Encrypt(context.Background(), secret)
+}
+
+// DecryptSecret returns a decrypted copy of the secret if it is encrypted, otherwise the secret itself.
+func DecryptSecret(secret *corev1.Secret) (*corev1.Secret, error) {
+	if !IsEncrypted(secret) {
+		return secret, nil
+	}
+	envelope := Default()
+	if envelope == nil {
+		return nil, fmt.Errorf("secret %s/%s is encrypted, but no encryption provider is configured", secret.Namespace, secret.Name)
+	}
+	return envelope.Decrypt(context.Background(), secret)
+}
+
+// DecryptValue decrypts the value of key in the data of a secret with the given annotations, using the envelope
+// configured by Configure. Values which are not encrypted are returned as they are.
+func DecryptValue(annotations map[string]string, key string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, valuePrefix) || annotations[DEKAnnotation] == "" {
+		return value, nil
+	}
+	envelope := Default()
+	if envelope == nil {
+		return nil, fmt.Errorf("value %s is encrypted, but no encryption provider is configured", key)
+	}
+	return envelope.DecryptValue(context.Background(), annotations, key, value)
+}
@@ -0,0 +1,303 @@
+package encryptedstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestKey(name string, b byte) localKey {
+	return localKey{Name: name, Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))}
+}
+
+func newTestEnvelope(t *testing.T, keys ...localKey) *Envelope {
+	t.Helper()
+	provider, err := newLocalKeyProvider(keys)
+	require.NoError(t, err)
+	return NewEnvelope(provider)
+}
+
+func newTestSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cc-abc",
+			Namespace:   "cattle-global-data",
+			Annotations: map[string]string{"field.cattle.io/creatorId": "u-abc"},
+		},
+		Data: map[string][]byte{
+			"amazonec2credentialConfig-accessKey": []byte("AKIA"),
+			"amazonec2credentialConfig-secretKey": []byte("secret"),
+		},
+		StringData: map[string]string{
+			"amazonec2credentialConfig-defaultRegion": "us-west-2",
+		},
+	}
+}
+
+func TestEnvelopeEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	envelope := newTestEnvelope(t, newTestKey("key1", 1))
+	secret := newTestSecret()
+
+	encrypted, err := envelope.Encrypt(ctx, secret)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.Nil(t, encrypted.StringData)
+	assert.Equal(t, ProviderLocal, encrypted.Annotations[ProviderAnnotation])
+	assert.Equal(t, "key1", encrypted.Annotations[KeyIDAnnotation])
+	assert.Equal(t, "u-abc", encrypted.Annotations["field.cattle.io/creatorId"])
+	assert.Len(t, encrypted.Data, 3)
+	for k, v := range encrypted.Data {
+		assert.True(t, bytes.HasPrefix(v, valuePrefix), k)
+		assert.NotContains(t, string(v), "secret")
+	}
+	// the original secret is left untouched
+	assert.Equal(t, []byte("secret"), secret.Data["amazonec2credentialConfig-secretKey"])
+
+	needsEncryption, err := envelope.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.False(t, needsEncryption)
+
+	// decrypt with a new envelope, so the data encryption key is unwrapped instead of read from the cache
+	decrypted, err := newTestEnvelope(t, newTestKey("key1", 1)).Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.False(t, IsEncrypted(decrypted))
+	assert.Equal(t, map[string][]byte{
+		"amazonec2credentialConfig-accessKey":     []byte("AKIA"),
+		"amazonec2credentialConfig-secretKey":     []byte("secret"),
+		"amazonec2credentialConfig-defaultRegion": []byte("us-west-2"),
+	}, decrypted.Data)
+	assert.Equal(t, map[string]string{"field.cattle.io/creatorId": "u-abc"}, decrypted.Annotations)
+}
+
+func TestEnvelopeDecryptErrors(t *testing.T) {
+	ctx := context.Background()
+	encrypted, err := newTestEnvelope(t, newTestKey("key1", 1)).Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+
+	_, err = newTestEnvelope(t, newTestKey("key2", 2)).Decrypt(ctx, encrypted)
+	assert.ErrorContains(t, err, "unknown key key1")
+
+	_, err = newTestEnvelope(t, newTestKey("key1", 2)).Decrypt(ctx, encrypted)
+	assert.Error(t, err)
+
+	// values are bound to their key
+	swapped := encrypted.DeepCopy()
+	swapped.Data["amazonec2credentialConfig-accessKey"] = encrypted.Data["amazonec2credentialConfig-secretKey"]
+	_, err = newTestEnvelope(t, newTestKey("key1", 1)).Decrypt(ctx, swapped)
+	assert.Error(t, err)
+}
+
+func TestEnvelopeMixedPlaintext(t *testing.T) {
+	ctx := context.Background()
+	envelope := newTestEnvelope(t, newTestKey("key1", 1))
+	encrypted, err := envelope.Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+
+	// a value written in plaintext to an encrypted secret, e.g. by an update through the API
+	encrypted.Data["amazonec2credentialConfig-secretKey"] = []byte("rotated")
+
+	needsEncryption, err := envelope.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.True(t, needsEncryption)
+
+	decrypted, err := envelope.Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("AKIA"), decrypted.Data["amazonec2credentialConfig-accessKey"])
+	assert.Equal(t, []byte("rotated"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+
+	reencrypted, err := envelope.Encrypt(ctx, encrypted)
+	require.NoError(t, err)
+	decrypted, err = envelope.Decrypt(ctx, reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("rotated"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+}
+
+func TestEnvelopeKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	encrypted, err := newTestEnvelope(t, newTestKey("key1", 1)).Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+
+	rotated := newTestEnvelope(t, newTestKey("key2", 2), newTestKey("key1", 1))
+	needsEncryption, err := rotated.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.True(t, needsEncryption)
+
+	reencrypted, err := rotated.Encrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "key2", reencrypted.Annotations[KeyIDAnnotation])
+	assert.NotEqual(t, encrypted.Annotations[DEKAnnotation], reencrypted.Annotations[DEKAnnotation])
+
+	// the old key is no longer needed once all secrets were encrypted again
+	decrypted, err := newTestEnvelope(t, newTestKey("key2", 2)).Decrypt(ctx, reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+}
+
+// namedKeyProvider is a key provider with another name, standing in for a provider of another kind.
+type namedKeyProvider struct {
+	KeyProvider
+	name string
+}
+
+func (p *namedKeyProvider) Name() string {
+	return p.name
+}
+
+func TestEnvelopeDecryptOnly(t *testing.T) {
+	ctx := context.Background()
+	oldProvider, err := newLocalKeyProvider([]localKey{newTestKey("key1", 1)})
+	require.NoError(t, err)
+	old := &namedKeyProvider{KeyProvider: oldProvider, name: "old"}
+	encrypted, err := NewEnvelope(old).Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+
+	newProvider, err := newLocalKeyProvider([]localKey{newTestKey("key2", 2)})
+	require.NoError(t, err)
+	_, err = NewEnvelope(newProvider).Decrypt(ctx, encrypted)
+	assert.ErrorContains(t, err, `key provider "old", which is not configured`)
+
+	migrating := NewEnvelope(newProvider, old)
+	needsEncryption, err := migrating.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.True(t, needsEncryption)
+
+	reencrypted, err := migrating.Encrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, ProviderLocal, reencrypted.Annotations[ProviderAnnotation])
+	assert.Equal(t, "key2", reencrypted.Annotations[KeyIDAnnotation])
+
+	// the decrypt-only provider is no longer needed once all secrets were encrypted again
+	decrypted, err := NewEnvelope(newProvider).Decrypt(ctx, reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+}
+
+func TestEnvelopeIdentity(t *testing.T) {
+	ctx := context.Background()
+	provider, err := newLocalKeyProvider([]localKey{newTestKey("key1", 1)})
+	require.NoError(t, err)
+	encrypted, err := NewEnvelope(provider).Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+
+	identity := NewEnvelope(nil, provider)
+	needsEncryption, err := identity.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.True(t, needsEncryption)
+
+	decrypted, err := identity.Encrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.False(t, IsEncrypted(decrypted))
+	assert.Equal(t, []byte("secret"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+	assert.Equal(t, []byte("us-west-2"), decrypted.Data["amazonec2credentialConfig-defaultRegion"])
+
+	needsEncryption, err = identity.NeedsEncryption(ctx, decrypted)
+	require.NoError(t, err)
+	assert.False(t, needsEncryption)
+}
+
+func TestNewLocalKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys.yaml")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: "keys:\n- name: key2\n  secret: " + key + "\n- name: key1\n  secret: " + key + "\n",
+		},
+		{
+			name:    "no keys",
+			content: "keys: []\n",
+			wantErr: "no keys configured",
+		},
+		{
+			name:    "short key",
+			content: "keys:\n- name: key1\n  secret: " + base64.StdEncoding.EncodeToString([]byte("short")) + "\n",
+			wantErr: "must be 32 bytes",
+		},
+		{
+			name:    "duplicate key",
+			content: "keys:\n- name: key1\n  secret: " + key + "\n- name: key1\n  secret: " + key + "\n",
+			wantErr: "duplicate key key1",
+		},
+		{
+			name:    "unknown field",
+			content: "keys:\n- name: key1\n  secret: " + key + "\n  provider: aes\n",
+			wantErr: "failed to parse key file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(keyFile, []byte(tt.content), 0600))
+			provider, err := NewLocalKeyProvider(keyFile)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			keyID, err := provider.KeyID(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "key2", keyID)
+		})
+	}
+}
+
+func TestPrepareSecretForUpdate(t *testing.T) {
+	defer func() { defaultEnvelope = nil }()
+	defaultEnvelope = newTestEnvelope(t, newTestKey("key1", 1))
+
+	secret, err := EncryptSecret(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mc-node1", Namespace: "cattle-system", Labels: map[string]string{StoreLabel: "true"}},
+		Data:       map[string][]byte{"extractedConfig": []byte("config")},
+	})
+	require.NoError(t, err)
+
+	_, changed, err := prepareSecretForUpdate(secret, map[string]string{"extractedConfig": "config"})
+	require.NoError(t, err)
+	assert.False(t, changed, "unchanged data must not update the secret")
+
+	updated, changed, err := prepareSecretForUpdate(secret, map[string]string{"extractedConfig": "new"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, IsEncrypted(updated))
+	decrypted, err := DecryptSecret(updated)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), decrypted.Data["extractedConfig"])
+
+	// secrets written before encryption was enabled are labeled and encrypted on the next update
+	plain := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mc-node2", Namespace: "cattle-system"},
+		Data:       map[string][]byte{"extractedConfig": []byte("config")},
+	}
+	updated, changed, err = prepareSecretForUpdate(plain, map[string]string{"extractedConfig": "config"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "true", updated.Labels[StoreLabel])
+	assert.True(t, IsEncrypted(updated))
+}
+
+func TestDecryptSecretWithoutProvider(t *testing.T) {
+	encrypted, err := newTestEnvelope(t, newTestKey("key1", 1)).Encrypt(context.Background(), newTestSecret())
+	require.NoError(t, err)
+
+	_, err = DecryptSecret(encrypted)
+	assert.ErrorContains(t, err, "no encryption provider is configured")
+
+	plain := newTestSecret()
+	result, err := DecryptSecret(plain)
+	require.NoError(t, err)
+	assert.Same(t, plain, result)
+}
@@ -0,0 +1,133 @@
+package encryptedstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	kmsapi "k8s.io/kms/apis/v2"
+)
+
+const (
+	kmsv2APIVersion     = "v2"
+	kmsv2Healthy        = "ok"
+	kmsv2DefaultTimeout = 3 * time.Second
+	keyIDCacheDuration  = time.Minute
+)
+
+// KMSv2Config configures a key provider using a Kubernetes KMS v2 plugin, so the plugins deployed for the encryption
+// at rest of the Kubernetes API server can be reused.
+type KMSv2Config struct {
+	// Endpoint is the unix socket of the plugin, e.g. unix:///var/run/kmsplugin/socket.sock.
+	Endpoint string `json:"endpoint"`
+	// Timeout of the calls to the plugin, defaults to 3 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type kmsv2KeyProvider struct {
+	client  kmsapi.KeyManagementServiceClient
+	timeout time.Duration
+
+	mu        sync.Mutex
+	keyID     string
+	keyIDTime time.Time
+	now       func() time.Time
+}
+
+// NewKMSv2KeyProvider returns a key provider using the KMS v2 plugin listening on the configured endpoint.
+func NewKMSv2KeyProvider(config *KMSv2Config) (KeyProvider, error) {
+	if !strings.HasPrefix(config.Endpoint, "unix://") {
+		return nil, fmt.Errorf("endpoint of KMS v2 plugin must be a unix socket, got %q", config.Endpoint)
+	}
+	conn, err := grpc.NewClient(config.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KMS v2 plugin client: %w", err)
+	}
+	timeout := kmsv2DefaultTimeout
+	if config.Timeout != nil && config.Timeout.Duration > 0 {
+		timeout = config.Timeout.Duration
+	}
+	return newKMSv2KeyProvider(kmsapi.NewKeyManagementServiceClient(conn), timeout), nil
+}
+
+func newKMSv2KeyProvider(client kmsapi.KeyManagementServiceClient, timeout time.Duration) *kmsv2KeyProvider {
+	return &kmsv2KeyProvider{
+		client:  client,
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+func (k *kmsv2KeyProvider) Name() string {
+	return ProviderKMSv2
+}
+
+// KeyID returns the key ID reported by the status of the plugin. The key ID is cached for a minute, like the API
+// server does, so it isn't queried for every secret.
+func (k *kmsv2KeyProvider) KeyID(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keyID != "" && k.now().Sub(k.keyIDTime) < keyIDCacheDuration {
+		return k.keyID, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	status, err := k.client.Status(ctx, &kmsapi.StatusRequest{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get status of KMS v2 plugin: %w", err)
+	}
+	if status.Version != kmsv2APIVersion {
+		return "", fmt.Errorf("unsupported KMS plugin API version %q", status.Version)
+	}
+	if status.Healthz != kmsv2Healthy {
+		return "", fmt.Errorf("KMS v2 plugin is not healthy: %s", status.Healthz)
+	}
+	if status.KeyId == "" {
+		return "", fmt.Errorf("KMS v2 plugin returned an empty key ID")
+	}
+	k.keyID = status.KeyId
+	k.keyIDTime = k.now()
+	return k.keyID, nil
+}
+
+func (k *kmsv2KeyProvider) Wrap(ctx context.Context, dek []byte) (*WrappedKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	resp, err := k.client.Encrypt(ctx, &kmsapi.EncryptRequest{
+		Plaintext: dek,
+		Uid:       string(uuid.NewUUID()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.KeyId == "" {
+		return nil, fmt.Errorf("KMS v2 plugin returned an empty key ID")
+	}
+	return &WrappedKey{
+		KeyID:       resp.KeyId,
+		Ciphertext:  resp.Ciphertext,
+		Annotations: resp.Annotations,
+	}, nil
+}
+
+func (k *kmsv2KeyProvider) Unwrap(ctx context.Context, key *WrappedKey) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	resp, err := k.client.Decrypt(ctx, &kmsapi.DecryptRequest{
+		Ciphertext:  key.Ciphertext,
+		Uid:         string(uuid.NewUUID()),
+		KeyId:       key.KeyID,
+		Annotations: key.Annotations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
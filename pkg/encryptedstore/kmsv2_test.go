@@ -0,0 +1,80 @@
+package encryptedstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	kmsapi "k8s.io/kms/apis/v2"
+)
+
+type fakeKMSv2Client struct {
+	status *kmsapi.StatusResponse
+}
+
+func (f *fakeKMSv2Client) Status(_ context.Context, _ *kmsapi.StatusRequest, _ ...grpc.CallOption) (*kmsapi.StatusResponse, error) {
+	return f.status, nil
+}
+
+func (f *fakeKMSv2Client) Encrypt(_ context.Context, req *kmsapi.EncryptRequest, _ ...grpc.CallOption) (*kmsapi.EncryptResponse, error) {
+	return &kmsapi.EncryptResponse{
+		Ciphertext:  append([]byte(f.status.KeyId+":"), req.Plaintext...),
+		KeyId:       f.status.KeyId,
+		Annotations: map[string][]byte{"kms.example.com/version": []byte("1")},
+	}, nil
+}
+
+func (f *fakeKMSv2Client) Decrypt(_ context.Context, req *kmsapi.DecryptRequest, _ ...grpc.CallOption) (*kmsapi.DecryptResponse, error) {
+	if string(req.Annotations["kms.example.com/version"]) != "1" {
+		return nil, assert.AnError
+	}
+	return &kmsapi.DecryptResponse{Plaintext: req.Ciphertext[len(req.KeyId)+1:]}, nil
+}
+
+func TestKMSv2KeyProvider(t *testing.T) {
+	client := &fakeKMSv2Client{status: &kmsapi.StatusResponse{Version: "v2", Healthz: "ok", KeyId: "key1"}}
+	provider := newKMSv2KeyProvider(client, time.Second)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	keyID, err := provider.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+
+	envelope := NewEnvelope(provider)
+	encrypted, err := envelope.Encrypt(ctx, newTestSecret())
+	require.NoError(t, err)
+	assert.Equal(t, ProviderKMSv2, encrypted.Annotations[ProviderAnnotation])
+	assert.Equal(t, "key1", encrypted.Annotations[KeyIDAnnotation])
+
+	decrypted, err := NewEnvelope(provider).Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), decrypted.Data["amazonec2credentialConfig-secretKey"])
+
+	// a key rotation reported by the plugin is noticed once the cached key ID expires
+	client.status = &kmsapi.StatusResponse{Version: "v2", Healthz: "ok", KeyId: "key2"}
+	needsEncryption, err := envelope.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.False(t, needsEncryption)
+	now = now.Add(keyIDCacheDuration)
+	needsEncryption, err = envelope.NeedsEncryption(ctx, encrypted)
+	require.NoError(t, err)
+	assert.True(t, needsEncryption)
+
+	client.status = &kmsapi.StatusResponse{Version: "v2", Healthz: "unavailable", KeyId: "key2"}
+	now = now.Add(keyIDCacheDuration)
+	_, err = provider.KeyID(ctx)
+	assert.ErrorContains(t, err, "not healthy")
+}
+
+func TestNewKMSv2KeyProvider(t *testing.T) {
+	_, err := NewKMSv2KeyProvider(&KMSv2Config{Endpoint: "localhost:8080"})
+	assert.ErrorContains(t, err, "must be a unix socket")
+
+	_, err = NewKMSv2KeyProvider(&KMSv2Config{Endpoint: "unix:///var/run/kmsplugin/socket.sock"})
+	assert.NoError(t, err)
+}
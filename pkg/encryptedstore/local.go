@@ -0,0 +1,106 @@
+package encryptedstore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LocalConfig configures the local key provider.
+type LocalConfig struct {
+	// KeyFile is the path of the file holding the key encryption keys.
+	KeyFile string `json:"keyFile"`
+}
+
+// localKeyFile is the format of the key file of the local key provider. The first key is used to wrap new data
+// encryption keys, the others are only used to unwrap existing ones. Keys are rotated by adding a new key at the top
+// of the list, and removing the old key once all secrets were encrypted again.
+//
+//	keys:
+//	- name: key2
+//	  secret: <base64 encoded 32 byte key>
+//	- name: key1
+//	  secret: <base64 encoded 32 byte key>
+type localKeyFile struct {
+	Keys []localKey `json:"keys"`
+}
+
+type localKey struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+type localKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewLocalKeyProvider returns a key provider wrapping data encryption keys with AES-GCM, using the keys in keyFile.
+func NewLocalKeyProvider(keyFile string) (KeyProvider, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	file := &localKeyFile{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", keyFile, err)
+	}
+	return newLocalKeyProvider(file.Keys)
+}
+
+func newLocalKeyProvider(keys []localKey) (*localKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys configured")
+	}
+	provider := &localKeyProvider{
+		current: keys[0].Name,
+		keys:    map[string][]byte{},
+	}
+	for _, key := range keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("key name must not be empty")
+		}
+		if _, ok := provider.keys[key.Name]; ok {
+			return nil, fmt.Errorf("duplicate key %s", key.Name)
+		}
+		secret, err := base64.StdEncoding.DecodeString(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret of key %s: %w", key.Name, err)
+		}
+		if len(secret) != 32 {
+			return nil, fmt.Errorf("secret of key %s must be 32 bytes, got %d", key.Name, len(secret))
+		}
+		provider.keys[key.Name] = secret
+	}
+	return provider, nil
+}
+
+func (l *localKeyProvider) Name() string {
+	return ProviderLocal
+}
+
+func (l *localKeyProvider) KeyID(_ context.Context) (string, error) {
+	return l.current, nil
+}
+
+func (l *localKeyProvider) Wrap(_ context.Context, dek []byte) (*WrappedKey, error) {
+	ciphertext, err := seal(l.keys[l.current], []byte(l.current), dek)
+	if err != nil {
+		return nil, err
+	}
+	return &WrappedKey{
+		KeyID:      l.current,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func (l *localKeyProvider) Unwrap(_ context.Context, key *WrappedKey) ([]byte, error) {
+	secret, ok := l.keys[key.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", key.KeyID)
+	}
+	return open(secret, []byte(key.KeyID), key.Ciphertext)
+}
@@ -14,6 +14,9 @@
 
 const (
 	defaultNamespace = "cattle-system"
+
+	// StoreLabel marks the secrets written by a GenericEncryptedStore, which are encrypted if encryption is enabled.
+	StoreLabel = "encryption.cattle.io/encrypted-store"
 )
 
 type GenericEncryptedStore struct {
@@ -53,6 +56,11 @@ func (g *GenericEncryptedStore) Get(name string) (map[string]string, error) {
 		return nil, err
 	}
 
+	sec, err = DecryptSecret(sec)
+	if err != nil {
+		return nil, err
+	}
+
 	result := map[string]string{}
 	for k, v := range sec.Data {
 		result[k] = string(v)
@@ -77,9 +85,13 @@ func (g *GenericEncryptedStore) set(name string, data map[string]string, owner *
 		sec = &corev1.Secret{}
 		sec.Name = g.getKey(name)
 		sec.StringData = data
+		sec.Labels = map[string]string{StoreLabel: "true"}
 		if owner != nil {
 			sec.SetOwnerReferences([]metav1.OwnerReference{*owner})
 		}
+		if sec, err = EncryptSecret(sec); err != nil {
+			return err
+		}
 		if _, err := g.secrets.Create(sec); err != nil {
 			if !errors.IsAlreadyExists(err) {
 				return err
@@ -93,8 +105,11 @@ func (g *GenericEncryptedStore) set(name string, data map[string]string, owner *
 		return err
 	}
 
-	secToUpdate := prepareSecretForUpdate(sec, data)
-	if !reflect.DeepEqual(secToUpdate.Data, sec.Data) {
+	secToUpdate, changed, err := prepareSecretForUpdate(sec, data)
+	if err != nil {
+		return err
+	}
+	if changed {
 		logrus.Debugf("[GenericEncryptedStore]: updating secret %v", g.getKey(name))
 
 		if owner != nil {
@@ -135,8 +150,11 @@ func (g *GenericEncryptedStore) updateSecretWithBackoff(name string, data map[st
 			logrus.Errorf("[GenericEncryptedStore]: error getting secret %v from db: %v", g.getKey(name), err)
 			return false, err
 		}
-		secToUpdate := prepareSecretForUpdate(secret, data)
-		if !reflect.DeepEqual(secToUpdate.Data, secret.Data) {
+		secToUpdate, changed, err := prepareSecretForUpdate(secret, data)
+		if err != nil {
+			return false, err
+		}
+		if changed {
 			_, err = g.secrets.Update(secToUpdate)
 			if err != nil {
 				if errors.IsConflict(err) {
@@ -152,15 +170,30 @@ func (g *GenericEncryptedStore) updateSecretWithBackoff(name string, data map[st
 	})
 }
 
-func prepareSecretForUpdate(secret *corev1.Secret, data map[string]string) *corev1.Secret {
-	secToUpdate := secret.DeepCopy()
+// prepareSecretForUpdate merges data into the secret, and returns whether the secret needs to be updated. The data is
+// compared and merged in plaintext, and the result encrypted if encryption is enabled. Secrets created before
+// StoreLabel was introduced are updated once to add it, so they are picked up by the re-encryption controller.
+func prepareSecretForUpdate(secret *corev1.Secret, data map[string]string) (*corev1.Secret, bool, error) {
+	plain, err := DecryptSecret(secret)
+	if err != nil {
+		return nil, false, err
+	}
+	secToUpdate := plain.DeepCopy()
 	if secToUpdate.Data == nil {
 		secToUpdate.Data = map[string][]byte{}
 	}
 	for k, v := range data {
 		secToUpdate.Data[k] = []byte(v)
 	}
-	return secToUpdate
+	if reflect.DeepEqual(secToUpdate.Data, plain.Data) && secToUpdate.Labels[StoreLabel] == "true" {
+		return secToUpdate, false, nil
+	}
+	if secToUpdate.Labels == nil {
+		secToUpdate.Labels = map[string]string{}
+	}
+	secToUpdate.Labels[StoreLabel] = "true"
+	secToUpdate, err = EncryptSecret(secToUpdate)
+	return secToUpdate, true, err
 }
 
 func (g *GenericEncryptedStore) Remove(name string) error {
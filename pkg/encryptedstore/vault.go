@@ -0,0 +1,197 @@
+package encryptedstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultVaultMount = "transit"
+
+// VaultConfig configures a key provider using the transit secrets engine of HashiCorp Vault.
+type VaultConfig struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200.
+	Address string `json:"address"`
+	// Namespace is the Vault Enterprise namespace of the transit secrets engine.
+	Namespace string `json:"namespace,omitempty"`
+	// Mount is the path the transit secrets engine is mounted at, defaults to transit.
+	Mount string `json:"mount,omitempty"`
+	// Key is the name of the transit key.
+	Key string `json:"key"`
+	// TokenFile is the path of a file holding the Vault token. It is read for every request, so the token can be
+	// renewed by an agent.
+	TokenFile string `json:"tokenFile"`
+	// CAFile is the path of the CA bundle used to verify the Vault server.
+	CAFile string `json:"caFile,omitempty"`
+}
+
+type vaultKeyProvider struct {
+	config VaultConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	keyID     string
+	keyIDTime time.Time
+	now       func() time.Time
+}
+
+// NewVaultKeyProvider returns a key provider wrapping data encryption keys with a Vault transit key. Rotating the
+// transit key rotates the key encryption key, as the key ID includes the key version.
+func NewVaultKeyProvider(config *VaultConfig) (KeyProvider, error) {
+	if config.Address == "" || config.Key == "" || config.TokenFile == "" {
+		return nil, fmt.Errorf("address, key and tokenFile of the vault key provider are required")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in vault CA file %s", config.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return newVaultKeyProvider(*config, &http.Client{Transport: transport, Timeout: providerTimeout}), nil
+}
+
+func newVaultKeyProvider(config VaultConfig, client *http.Client) *vaultKeyProvider {
+	if config.Mount == "" {
+		config.Mount = defaultVaultMount
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &vaultKeyProvider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (v *vaultKeyProvider) Name() string {
+	return ProviderVault
+}
+
+// KeyID returns the name and latest version of the transit key, e.g. rancher:v3. The key ID is cached for a minute.
+func (v *vaultKeyProvider) KeyID(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.keyID != "" && v.now().Sub(v.keyIDTime) < keyIDCacheDuration {
+		return v.keyID, nil
+	}
+
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "keys", nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.Data.LatestVersion == 0 {
+		return "", fmt.Errorf("vault transit key %s has no versions", v.config.Key)
+	}
+	v.keyID = fmt.Sprintf("%s:v%d", v.config.Key, resp.Data.LatestVersion)
+	v.keyIDTime = v.now()
+	return v.keyID, nil
+}
+
+func (v *vaultKeyProvider) Wrap(ctx context.Context, dek []byte) (*WrappedKey, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}
+	if err := v.do(ctx, http.MethodPost, "encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	keyID, err := v.keyIDOf(resp.Data.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return &WrappedKey{
+		KeyID:      keyID,
+		Ciphertext: []byte(resp.Data.Ciphertext),
+	}, nil
+}
+
+func (v *vaultKeyProvider) Unwrap(ctx context.Context, key *WrappedKey) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	req := map[string]string{"ciphertext": string(key.Ciphertext)}
+	if err := v.do(ctx, http.MethodPost, "decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// keyIDOf returns the key ID of a ciphertext returned by the transit secrets engine, which has the format
+// vault:v<version>:<ciphertext>.
+func (v *vaultKeyProvider) keyIDOf(ciphertext string) (string, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return "", fmt.Errorf("unexpected vault ciphertext format")
+	}
+	return v.config.Key + ":" + parts[1], nil
+}
+
+func (v *vaultKeyProvider) do(ctx context.Context, method, operation string, body, result any) error {
+	token, err := os.ReadFile(v.config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read vault token: %w", err)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := fmt.Sprintf("%s/v1/%s/%s/%s", v.config.Address, v.config.Mount, operation, url.PathEscape(v.config.Key))
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", strings.TrimSpace(string(token)))
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(data, &vaultErr)
+		return fmt.Errorf("vault %s on key %s failed with status %d: %s", operation, v.config.Key, resp.StatusCode, strings.Join(vaultErr.Errors, ", "))
+	}
+	return json.Unmarshal(data, result)
+}
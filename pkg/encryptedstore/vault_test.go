@@ -0,0 +1,108 @@
+package encryptedstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault implements the encrypt, decrypt and keys endpoints of the transit secrets engine. Ciphertexts are the
+// base64 encoded plaintext prefixed with the key version.
+type fakeVault struct {
+	version int
+}
+
+func (f *fakeVault) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("X-Vault-Token") != "s.token" || req.Header.Get("X-Vault-Namespace") != "admin" {
+		rw.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(rw, `{"errors":["permission denied"]}`)
+		return
+	}
+	var body map[string]string
+	if req.Method == http.MethodPost {
+		_ = json.NewDecoder(req.Body).Decode(&body)
+	}
+	var data map[string]any
+	switch req.URL.Path {
+	case "/v1/secrets/keys/rancher":
+		data = map[string]any{"latest_version": f.version}
+	case "/v1/secrets/encrypt/rancher":
+		data = map[string]any{"ciphertext": fmt.Sprintf("vault:v%d:%s", f.version, body["plaintext"])}
+	case "/v1/secrets/decrypt/rancher":
+		parts := strings.SplitN(body["ciphertext"], ":", 3)
+		data = map[string]any{"plaintext": parts[2]}
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(rw, `{"errors":[]}`)
+		return
+	}
+	_ = json.NewEncoder(rw).Encode(map[string]any{"data": data})
+}
+
+func TestVaultKeyProvider(t *testing.T) {
+	vault := &fakeVault{version: 1}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.token\n"), 0600))
+
+	now := time.Now()
+	provider := newVaultKeyProvider(VaultConfig{
+		Address:   server.URL + "/",
+		Namespace: "admin",
+		Mount:     "secrets",
+		Key:       "rancher",
+		TokenFile: tokenFile,
+	}, server.Client())
+	provider.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	keyID, err := provider.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rancher:v1", keyID)
+
+	dek := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := provider.Wrap(ctx, dek)
+	require.NoError(t, err)
+	assert.Equal(t, "rancher:v1", wrapped.KeyID)
+	assert.Equal(t, "vault:v1:"+base64.StdEncoding.EncodeToString(dek), string(wrapped.Ciphertext))
+
+	unwrapped, err := provider.Unwrap(ctx, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	// the key ID is cached until it expires
+	vault.version = 2
+	keyID, err = provider.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rancher:v1", keyID)
+	now = now.Add(keyIDCacheDuration)
+	keyID, err = provider.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rancher:v2", keyID)
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.expired"), 0600))
+	_, err = provider.Wrap(ctx, dek)
+	assert.ErrorContains(t, err, "failed with status 403: permission denied")
+}
+
+func TestNewVaultKeyProvider(t *testing.T) {
+	_, err := NewVaultKeyProvider(&VaultConfig{Address: "https://vault.example.com"})
+	assert.Error(t, err)
+
+	provider, err := NewVaultKeyProvider(&VaultConfig{Address: "https://vault.example.com", Key: "rancher", TokenFile: "/token"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultVaultMount, provider.(*vaultKeyProvider).config.Mount)
+}
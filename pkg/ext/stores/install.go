@@ -6,6 +6,7 @@
 	extv1 "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/ext/stores/kubeconfig"
+	"github.com/rancher/rancher/pkg/ext/stores/permissionreview"
 	"github.com/rancher/rancher/pkg/ext/stores/tokens"
 	"github.com/rancher/rancher/pkg/ext/stores/useractivity"
 	"github.com/rancher/rancher/pkg/features"
@@ -33,14 +34,31 @@ func InstallStores(
 	}
 	logrus.Infof("Successfully installed useractivity store")
 
+	if err := server.Install(
+		permissionreview.PluralName,
+		permissionreview.GVK,
+		permissionreview.New(wranglerContext, server.GetAuthorizer()),
+	); err != nil {
+		return fmt.Errorf("unable to install %s store: %w", permissionreview.SingularName, err)
+	}
+	logrus.Infof("Successfully installed permissionreview store")
+
 	if features.ExtTokens.Enabled() {
+		tokenStore := tokens.NewFromWrangler(wranglerContext, server.GetAuthorizer())
 		if err := server.Install(
 			tokens.PluralName,
 			tokens.GVK,
-			tokens.NewFromWrangler(wranglerContext, server.GetAuthorizer()),
+			tokenStore,
 		); err != nil {
 			return fmt.Errorf("unable to install %s store: %w", tokens.SingularName, err)
 		}
+		if err := server.Install(
+			tokens.PluralName+"/"+tokens.UsageSubresource,
+			tokens.UsageGVK,
+			tokens.NewUsageStore(tokenStore),
+		); err != nil {
+			return fmt.Errorf("unable to install %s %s store: %w", tokens.SingularName, tokens.UsageSubresource, err)
+		}
 		logrus.Infof("Successfully installed token store")
 	} else {
 		logrus.Infof("Feature ext-tokens is disabled")
@@ -18,6 +18,7 @@
 	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	extcommon "github.com/rancher/rancher/pkg/ext/common"
 	exttokens "github.com/rancher/rancher/pkg/ext/stores/tokens"
 	ctrlv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
@@ -228,6 +229,11 @@ func (s *Store) Create(
 		return nil, apierrors.NewForbidden(gvr.GroupResource(), "", fmt.Errorf("user %s is not a Rancher user", userInfo.GetName()))
 	}
 
+	// A scoped token must not be able to escape its scope by creating a kubeconfig with new tokens.
+	if err := scope.CheckIssuance(ctx); err != nil {
+		return nil, apierrors.NewForbidden(gvr.GroupResource(), "", err)
+	}
+
 	extras := userInfo.GetExtra()
 
 	authTokenID := first(extras[common.ExtraRequestTokenID])
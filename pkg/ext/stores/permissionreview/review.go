@@ -0,0 +1,392 @@
+package permissionreview
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	k8srbac "k8s.io/kubernetes/plugin/pkg/auth/authorizer/rbac"
+)
+
+const (
+	localCluster = "local"
+
+	projectIDAnnotation = "field.cattle.io/projectId"
+
+	globalRoleBindingKind          = "GlobalRoleBinding"
+	clusterRoleTemplateBindingKind = "ClusterRoleTemplateBinding"
+	projectRoleTemplateBindingKind = "ProjectRoleTemplateBinding"
+)
+
+// clusterAdminRule is the rule of the cluster-admin ClusterRole, which is bound in every downstream cluster for admin
+// global roles.
+var clusterAdminRule = rbacv1.PolicyRule{
+	APIGroups: []string{rbacv1.APIGroupAll},
+	Resources: []string{rbacv1.ResourceAll},
+	Verbs:     []string{rbacv1.VerbAll},
+}
+
+// subject is the user or group principal under review.
+type subject struct {
+	userName   string
+	principals sets.Set[string]
+	groups     sets.Set[string]
+}
+
+// matches returns the name the binding fields match the subject with, or an empty string if they don't match.
+func (s *subject) matches(userName, userPrincipalName, groupPrincipalName string) string {
+	switch {
+	case userName != "" && userName == s.userName:
+		return userName
+	case userPrincipalName != "" && s.principals.Has(userPrincipalName):
+		return userPrincipalName
+	case groupPrincipalName != "" && s.groups.Has(groupPrincipalName):
+		return groupPrincipalName
+	}
+	return ""
+}
+
+// namespaceProject returns the project the namespace of the given cluster belongs to, in the format
+// "clusterName:projectName", or an empty string if the namespace doesn't exist or isn't in a project.
+func (s *Store) namespaceProject(ctx context.Context, clusterName, namespace string) (string, error) {
+	ns, err := s.getNamespace(ctx, clusterName, namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", apierrors.NewInternalError(fmt.Errorf("error getting namespace %s in cluster %s: %w", namespace, clusterName, err))
+	}
+	projectID := ns.Annotations[projectIDAnnotation]
+	if projectCluster, _, found := strings.Cut(projectID, ":"); !found || projectCluster != clusterName {
+		return "", nil
+	}
+	return projectID, nil
+}
+
+// review evaluates the request of the spec for its subject. The spec is expected to be defaulted.
+func (s *Store) review(spec *ext.PermissionReviewSpec) (*ext.PermissionReviewStatus, error) {
+	if _, err := s.clusterCache.Get(spec.ClusterName); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("cluster %s not found", spec.ClusterName))
+		}
+		return nil, apierrors.NewInternalError(fmt.Errorf("error getting cluster %s: %w", spec.ClusterName, err))
+	}
+
+	var project *v3.Project
+	if spec.ProjectName != "" {
+		_, projectName, _ := strings.Cut(spec.ProjectName, ":")
+		var err error
+		project, err = s.projectCache.Get(spec.ClusterName, projectName)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, apierrors.NewBadRequest(fmt.Sprintf("project %s not found", spec.ProjectName))
+			}
+			return nil, apierrors.NewInternalError(fmt.Errorf("error getting project %s: %w", spec.ProjectName, err))
+		}
+	}
+
+	subj, err := s.subjectFor(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := &authorizer.AttributesRecord{
+		Verb:            spec.Verb,
+		Namespace:       spec.Namespace,
+		APIGroup:        spec.APIGroup,
+		Resource:        spec.Resource,
+		Subresource:     spec.Subresource,
+		Name:            spec.ResourceName,
+		ResourceRequest: true,
+	}
+	local := spec.ClusterName == localCluster
+	// Project role template bindings are bound with RoleBindings in the namespaces of the project, so they only
+	// grant namespaced requests.
+	projectScoped := project != nil && spec.Namespace != ""
+
+	status := &ext.PermissionReviewStatus{}
+	boundGlobalRoles := sets.New[string]()
+	boundRoleTemplates := sets.New[string]()
+
+	grbs, err := s.globalRoleBindingCache.List(labels.Everything())
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error listing global role bindings: %w", err))
+	}
+	for _, grb := range grbs {
+		name := subj.matches(grb.UserName, grb.UserPrincipalName, grb.GroupPrincipalName)
+		if name == "" {
+			continue
+		}
+		boundGlobalRoles.Insert(grb.GlobalRoleName)
+		gr, err := s.globalRoleCache.Get(grb.GlobalRoleName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, apierrors.NewInternalError(fmt.Errorf("error getting global role %s: %w", grb.GlobalRoleName, err))
+		}
+		grant, err := s.globalRoleGrant(gr, attrs, local)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.BindingKind = globalRoleBindingKind
+			grant.BindingName = grb.Name
+			grant.Subject = name
+			status.Grants = append(status.Grants, *grant)
+		}
+	}
+
+	crtbs, err := s.crtbCache.List(spec.ClusterName, labels.Everything())
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error listing cluster role template bindings in cluster %s: %w", spec.ClusterName, err))
+	}
+	for _, crtb := range crtbs {
+		name := subj.matches(crtb.UserName, crtb.UserPrincipalName, crtb.GroupPrincipalName)
+		if name == "" {
+			continue
+		}
+		boundRoleTemplates.Insert(crtb.RoleTemplateName)
+		grant, err := s.roleTemplateGrant(crtb.RoleTemplateName, nil, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.BindingKind = clusterRoleTemplateBindingKind
+			grant.BindingNamespace = crtb.Namespace
+			grant.BindingName = crtb.Name
+			grant.Subject = name
+			status.Grants = append(status.Grants, *grant)
+		}
+	}
+
+	if projectScoped {
+		namespace := project.GetProjectBackingNamespace()
+		prtbs, err := s.prtbCache.List(namespace, labels.Everything())
+		if err != nil {
+			return nil, apierrors.NewInternalError(fmt.Errorf("error listing project role template bindings in namespace %s: %w", namespace, err))
+		}
+		for _, prtb := range prtbs {
+			name := subj.matches(prtb.UserName, prtb.UserPrincipalName, prtb.GroupPrincipalName)
+			if name == "" {
+				continue
+			}
+			boundRoleTemplates.Insert(prtb.RoleTemplateName)
+			grant, err := s.roleTemplateGrant(prtb.RoleTemplateName, nil, attrs)
+			if err != nil {
+				return nil, err
+			}
+			if grant != nil {
+				grant.BindingKind = projectRoleTemplateBindingKind
+				grant.BindingNamespace = prtb.Namespace
+				grant.BindingName = prtb.Name
+				grant.Subject = name
+				status.Grants = append(status.Grants, *grant)
+			}
+		}
+	}
+	status.Allowed = len(status.Grants) > 0
+
+	// Candidates are the roles not bound to the subject that would allow the request.
+	globalRoles, err := s.globalRoleCache.List(labels.Everything())
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error listing global roles: %w", err))
+	}
+	for _, gr := range globalRoles {
+		if boundGlobalRoles.Has(gr.Name) {
+			continue
+		}
+		grant, err := s.globalRoleGrant(gr, attrs, local)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.BindingKind = globalRoleBindingKind
+			status.Candidates = append(status.Candidates, *grant)
+		}
+	}
+
+	roleTemplates, err := s.roleTemplateCache.List(labels.Everything())
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error listing role templates: %w", err))
+	}
+	for _, rt := range roleTemplates {
+		if boundRoleTemplates.Has(rt.Name) {
+			continue
+		}
+		var kind string
+		switch {
+		case rt.Context == "cluster":
+			kind = clusterRoleTemplateBindingKind
+		case rt.Context == "project" && projectScoped:
+			kind = projectRoleTemplateBindingKind
+		default:
+			continue
+		}
+		grant, err := s.roleTemplateGrant(rt.Name, nil, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.BindingKind = kind
+			status.Candidates = append(status.Candidates, *grant)
+		}
+	}
+
+	sortGrants(status.Grants)
+	sortGrants(status.Candidates)
+
+	return status, nil
+}
+
+// subjectFor returns the subject of the spec with the principals and groups of the user.
+func (s *Store) subjectFor(spec *ext.PermissionReviewSpec) (*subject, error) {
+	subj := &subject{
+		principals: sets.New[string](),
+		groups:     sets.New[string](),
+	}
+	if spec.GroupPrincipalName != "" {
+		subj.groups.Insert(spec.GroupPrincipalName)
+		return subj, nil
+	}
+
+	user, err := s.userCache.Get(spec.UserName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("user %s not found", spec.UserName))
+		}
+		return nil, apierrors.NewInternalError(fmt.Errorf("error getting user %s: %w", spec.UserName, err))
+	}
+	subj.userName = user.Name
+	subj.principals.Insert(user.PrincipalIDs...)
+
+	attribs, err := s.userAttributeCache.Get(user.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error getting user attributes of user %s: %w", user.Name, err))
+	}
+	if attribs != nil {
+		for _, principals := range attribs.GroupPrincipals {
+			for _, principal := range principals.Items {
+				subj.groups.Insert(principal.Name)
+			}
+		}
+	}
+
+	return subj, nil
+}
+
+// globalRoleGrant returns the first grant of the global role allowing the request, or nil if it doesn't allow it.
+// In the local cluster the Rules and NamespacedRules of the global role apply, in downstream clusters its
+// InheritedClusterRoles and, for admin global roles, the cluster-admin ClusterRole.
+func (s *Store) globalRoleGrant(gr *v3.GlobalRole, attrs *authorizer.AttributesRecord, local bool) (*ext.PermissionGrant, error) {
+	if local {
+		if rule := firstMatch(gr.Rules, attrs); rule != nil {
+			return &ext.PermissionGrant{GlobalRoleName: gr.Name, Source: ext.PermissionGrantSourceRules, Rule: *rule}, nil
+		}
+		if attrs.Namespace == "" {
+			return nil, nil
+		}
+		if rule := firstMatch(gr.NamespacedRules[attrs.Namespace], attrs); rule != nil {
+			return &ext.PermissionGrant{GlobalRoleName: gr.Name, Source: ext.PermissionGrantSourceNamespacedRules, Rule: *rule}, nil
+		}
+		return nil, nil
+	}
+
+	if rbac.IsAdminGlobalRoleObject(gr) {
+		return &ext.PermissionGrant{GlobalRoleName: gr.Name, Source: ext.PermissionGrantSourceClusterAdmin, Rule: clusterAdminRule}, nil
+	}
+	for _, name := range gr.InheritedClusterRoles {
+		grant, err := s.roleTemplateGrant(name, nil, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.GlobalRoleName = gr.Name
+			grant.Source = ext.PermissionGrantSourceInheritedClusterRoles
+			return grant, nil
+		}
+	}
+	return nil, nil
+}
+
+// roleTemplateGrant returns the first grant of the role template or the role templates it inherits allowing the
+// request, or nil if none allows it. The chain is the role templates leading to the role template.
+func (s *Store) roleTemplateGrant(name string, chain []string, attrs *authorizer.AttributesRecord) (*ext.PermissionGrant, error) {
+	if slices.Contains(chain, name) {
+		return nil, nil // Inheritance cycle.
+	}
+	rt, err := s.roleTemplateCache.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("error getting role template %s: %w", name, err))
+	}
+	chain = append(slices.Clone(chain), name)
+
+	rules, source, err := s.roleTemplateRules(rt)
+	if err != nil {
+		return nil, err
+	}
+	if rule := firstMatch(rules, attrs); rule != nil {
+		return &ext.PermissionGrant{RoleTemplateNames: chain, Locked: rt.Locked, Source: source, Rule: *rule}, nil
+	}
+
+	for _, inherited := range rt.RoleTemplateNames {
+		grant, err := s.roleTemplateGrant(inherited, chain, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grant.Locked = grant.Locked || rt.Locked
+			return grant, nil
+		}
+	}
+	return nil, nil
+}
+
+// roleTemplateRules returns the rules of the role template and the field they come from. External role templates use
+// their ExternalRules and fall back to the ClusterRole of the same name in the local cluster.
+func (s *Store) roleTemplateRules(rt *v3.RoleTemplate) ([]rbacv1.PolicyRule, string, error) {
+	if !rt.External {
+		return rt.Rules, ext.PermissionGrantSourceRules, nil
+	}
+	if rt.ExternalRules != nil {
+		return rt.ExternalRules, ext.PermissionGrantSourceExternalRules, nil
+	}
+	cr, err := s.clusterRoleCache.Get(rt.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, ext.PermissionGrantSourceClusterRole, nil
+	} else if err != nil {
+		return nil, "", apierrors.NewInternalError(fmt.Errorf("error getting cluster role %s: %w", rt.Name, err))
+	}
+	return cr.Rules, ext.PermissionGrantSourceClusterRole, nil
+}
+
+// firstMatch returns the first rule allowing the request, or nil if none does.
+func firstMatch(rules []rbacv1.PolicyRule, attrs *authorizer.AttributesRecord) *rbacv1.PolicyRule {
+	for i := range rules {
+		if k8srbac.RuleAllows(attrs, &rules[i]) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// sortGrants sorts grants by binding, then by global role and role templates, to return them in a stable order.
+func sortGrants(grants []ext.PermissionGrant) {
+	key := func(g ext.PermissionGrant) string {
+		return strings.Join([]string{g.BindingKind, g.BindingNamespace, g.BindingName, g.GlobalRoleName, strings.Join(g.RoleTemplateNames, "/")}, "\x00")
+	}
+	sort.SliceStable(grants, func(i, j int) bool {
+		return key(grants[i]) < key(grants[j])
+	})
+}
@@ -0,0 +1,197 @@
+// Package permissionreview implements the create-only PermissionReview resource, which explains the effective
+// permissions of a user or group principal across clusters.
+package permissionreview
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	mgmt "github.com/rancher/rancher/pkg/apis/management.cattle.io"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/wrangler"
+	rbaccontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+const (
+	Kind         = "PermissionReview"
+	SingularName = "permissionreview"
+	PluralName   = SingularName + "s"
+)
+
+var (
+	GVK = ext.SchemeGroupVersion.WithKind(Kind)
+	GVR = ext.SchemeGroupVersion.WithResource(PluralName)
+)
+
+// +k8s:openapi-gen=false
+// +k8s:deepcopy-gen=false
+// Store implements storage for [ext.PermissionReview]. Reviews are evaluated on create and never stored.
+type Store struct {
+	authorizer             authorizer.Authorizer
+	clusterCache           mgmtcontrollers.ClusterCache
+	clusterRoleCache       rbaccontrollers.ClusterRoleCache
+	crtbCache              mgmtcontrollers.ClusterRoleTemplateBindingCache
+	globalRoleCache        mgmtcontrollers.GlobalRoleCache
+	globalRoleBindingCache mgmtcontrollers.GlobalRoleBindingCache
+	projectCache           mgmtcontrollers.ProjectCache
+	prtbCache              mgmtcontrollers.ProjectRoleTemplateBindingCache
+	roleTemplateCache      mgmtcontrollers.RoleTemplateCache
+	userCache              mgmtcontrollers.UserCache
+	userAttributeCache     mgmtcontrollers.UserAttributeCache
+	// getNamespace gets a namespace of the given cluster.
+	getNamespace func(ctx context.Context, clusterName, name string) (*corev1.Namespace, error)
+}
+
+// New creates a new instance of [Store].
+func New(wranglerContext *wrangler.Context, authorizer authorizer.Authorizer) *Store {
+	namespaceCache := wranglerContext.Core.Namespace().Cache()
+	return &Store{
+		authorizer:             authorizer,
+		clusterCache:           wranglerContext.Mgmt.Cluster().Cache(),
+		clusterRoleCache:       wranglerContext.RBAC.ClusterRole().Cache(),
+		crtbCache:              wranglerContext.Mgmt.ClusterRoleTemplateBinding().Cache(),
+		globalRoleCache:        wranglerContext.Mgmt.GlobalRole().Cache(),
+		globalRoleBindingCache: wranglerContext.Mgmt.GlobalRoleBinding().Cache(),
+		projectCache:           wranglerContext.Mgmt.Project().Cache(),
+		prtbCache:              wranglerContext.Mgmt.ProjectRoleTemplateBinding().Cache(),
+		roleTemplateCache:      wranglerContext.Mgmt.RoleTemplate().Cache(),
+		userCache:              wranglerContext.Mgmt.User().Cache(),
+		userAttributeCache:     wranglerContext.Mgmt.UserAttribute().Cache(),
+		getNamespace: func(ctx context.Context, clusterName, name string) (*corev1.Namespace, error) {
+			if clusterName == localCluster {
+				return namespaceCache.Get(name)
+			}
+			client, err := wranglerContext.MultiClusterManager.K8sClient(clusterName)
+			if err != nil {
+				return nil, err
+			}
+			if client == nil {
+				return nil, fmt.Errorf("no client available for cluster %s", clusterName)
+			}
+			return client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		},
+	}
+}
+
+// GroupVersionKind implements [rest.GroupVersionKindProvider].
+func (s *Store) GroupVersionKind(_ schema.GroupVersion) schema.GroupVersionKind {
+	return GVK
+}
+
+// NamespaceScoped implements [rest.Scoper].
+func (s *Store) NamespaceScoped() bool {
+	return false
+}
+
+// GetSingularName implements [rest.SingularNameProvider].
+func (s *Store) GetSingularName() string {
+	return SingularName
+}
+
+// New implements [rest.Storage].
+func (s *Store) New() runtime.Object {
+	obj := &ext.PermissionReview{}
+	obj.GetObjectKind().SetGroupVersionKind(GVK)
+	return obj
+}
+
+// Destroy implements [rest.Storage].
+func (s *Store) Destroy() {}
+
+// Create implements [rest.Creator].
+// Create evaluates the review and returns it with its status set. Users can review their own permissions, reviewing
+// other users or group principals requires being allowed to list global role bindings.
+func (s *Store) Create(
+	ctx context.Context,
+	obj runtime.Object,
+	createValidation rest.ValidateObjectFunc,
+	_ *metav1.CreateOptions,
+) (runtime.Object, error) {
+	userInfo, ok := request.UserFrom(ctx)
+	if !ok {
+		return nil, apierrors.NewInternalError(fmt.Errorf("missing user info"))
+	}
+
+	review, ok := obj.(*ext.PermissionReview)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid object type %T", obj))
+	}
+
+	if createValidation != nil {
+		if err := createValidation(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+
+	review = review.DeepCopy()
+	spec := &review.Spec
+	if spec.UserName != "" && spec.GroupPrincipalName != "" {
+		return nil, apierrors.NewBadRequest("only one of spec.userName and spec.groupPrincipalName can be set")
+	}
+	if spec.Resource == "" {
+		return nil, apierrors.NewBadRequest("spec.resource is required")
+	}
+	if spec.Verb == "" {
+		return nil, apierrors.NewBadRequest("spec.verb is required")
+	}
+	if spec.UserName == "" && spec.GroupPrincipalName == "" {
+		spec.UserName = userInfo.GetName()
+	}
+
+	if spec.GroupPrincipalName != "" || spec.UserName != userInfo.GetName() {
+		decision, _, err := s.authorizer.Authorize(ctx, &authorizer.AttributesRecord{
+			User:            userInfo,
+			Verb:            "list",
+			APIGroup:        mgmt.GroupName,
+			Resource:        v3.GlobalRoleBindingResourceName,
+			ResourceRequest: true,
+		})
+		if err != nil {
+			return nil, apierrors.NewInternalError(fmt.Errorf("error checking if user %s can review other subjects: %w", userInfo.GetName(), err))
+		}
+		if decision != authorizer.DecisionAllow {
+			return nil, apierrors.NewForbidden(GVR.GroupResource(), "", fmt.Errorf("user %s is not allowed to review the permissions of other users", userInfo.GetName()))
+		}
+	}
+
+	if spec.ProjectName != "" {
+		clusterName, _, found := strings.Cut(spec.ProjectName, ":")
+		if !found {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("spec.projectName %s must be in the format clusterName:projectName", spec.ProjectName))
+		}
+		if spec.ClusterName == "" {
+			spec.ClusterName = clusterName
+		} else if spec.ClusterName != clusterName {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("spec.projectName %s is not in cluster %s", spec.ProjectName, spec.ClusterName))
+		}
+	}
+	if spec.ClusterName == "" {
+		spec.ClusterName = localCluster
+	}
+	if spec.ProjectName == "" && spec.Namespace != "" {
+		projectName, err := s.namespaceProject(ctx, spec.ClusterName, spec.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		spec.ProjectName = projectName
+	}
+
+	status, err := s.review(spec)
+	if err != nil {
+		return nil, err
+	}
+	review.Status = *status
+
+	return review, nil
+}
@@ -0,0 +1,528 @@
+package permissionreview
+
+import (
+	"context"
+	"testing"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8suser "k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+var (
+	podsRule = rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get", "list"},
+	}
+	secretsRule = rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"secrets"},
+		Verbs:     []string{"*"},
+	}
+	adminRules = []rbacv1.PolicyRule{
+		{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+		{NonResourceURLs: []string{"*"}, Verbs: []string{"*"}},
+	}
+)
+
+// objects are the objects served by the caches of the store under test.
+type objects struct {
+	globalRoles        []*v3.GlobalRole
+	globalRoleBindings []*v3.GlobalRoleBinding
+	roleTemplates      []*v3.RoleTemplate
+	crtbs              []*v3.ClusterRoleTemplateBinding
+	prtbs              []*v3.ProjectRoleTemplateBinding
+	clusterRoles       []*rbacv1.ClusterRole
+	namespaces         map[string][]*corev1.Namespace
+}
+
+func notFound(name string) error {
+	return apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+func newTestStore(t *testing.T, objs objects, allowOthers bool) *Store {
+	ctrl := gomock.NewController(t)
+
+	users := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	users.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+		switch name {
+		case "u-alice":
+			return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}, PrincipalIDs: []string{"local://u-alice", "github_user://1"}}, nil
+		case "u-bob":
+			return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}, PrincipalIDs: []string{"local://u-bob"}}, nil
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	userAttributes := fake.NewMockNonNamespacedCacheInterface[*v3.UserAttribute](ctrl)
+	userAttributes.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.UserAttribute, error) {
+		if name == "u-alice" {
+			return &v3.UserAttribute{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				GroupPrincipals: map[string]v3.Principals{
+					"github": {Items: []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "github_team://devs"}}}},
+				},
+			}, nil
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	clusters := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+	clusters.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.Cluster, error) {
+		if name == "local" || name == "c-downstream" {
+			return &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	projects := fake.NewMockCacheInterface[*v3.Project](ctrl)
+	projects.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*v3.Project, error) {
+		if namespace == "c-downstream" && name == "p-web" {
+			return &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Status:     v3.ProjectStatus{BackingNamespace: "c-downstream-p-web"},
+			}, nil
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	globalRoles := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRole](ctrl)
+	globalRoles.EXPECT().List(labels.Everything()).Return(objs.globalRoles, nil).AnyTimes()
+	globalRoles.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.GlobalRole, error) {
+		for _, gr := range objs.globalRoles {
+			if gr.Name == name {
+				return gr, nil
+			}
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	grbs := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRoleBinding](ctrl)
+	grbs.EXPECT().List(labels.Everything()).Return(objs.globalRoleBindings, nil).AnyTimes()
+
+	roleTemplates := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+	roleTemplates.EXPECT().List(labels.Everything()).Return(objs.roleTemplates, nil).AnyTimes()
+	roleTemplates.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.RoleTemplate, error) {
+		for _, rt := range objs.roleTemplates {
+			if rt.Name == name {
+				return rt, nil
+			}
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	crtbs := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+	crtbs.EXPECT().List(gomock.Any(), labels.Everything()).DoAndReturn(func(namespace string, _ labels.Selector) ([]*v3.ClusterRoleTemplateBinding, error) {
+		var result []*v3.ClusterRoleTemplateBinding
+		for _, crtb := range objs.crtbs {
+			if crtb.Namespace == namespace {
+				result = append(result, crtb)
+			}
+		}
+		return result, nil
+	}).AnyTimes()
+
+	prtbs := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+	prtbs.EXPECT().List(gomock.Any(), labels.Everything()).DoAndReturn(func(namespace string, _ labels.Selector) ([]*v3.ProjectRoleTemplateBinding, error) {
+		var result []*v3.ProjectRoleTemplateBinding
+		for _, prtb := range objs.prtbs {
+			if prtb.Namespace == namespace {
+				result = append(result, prtb)
+			}
+		}
+		return result, nil
+	}).AnyTimes()
+
+	clusterRoles := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+	clusterRoles.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*rbacv1.ClusterRole, error) {
+		for _, cr := range objs.clusterRoles {
+			if cr.Name == name {
+				return cr, nil
+			}
+		}
+		return nil, notFound(name)
+	}).AnyTimes()
+
+	return &Store{
+		authorizer: authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+			if allowOthers && a.GetVerb() == "list" && a.GetResource() == v3.GlobalRoleBindingResourceName {
+				return authorizer.DecisionAllow, "", nil
+			}
+			return authorizer.DecisionDeny, "", nil
+		}),
+		clusterCache:           clusters,
+		clusterRoleCache:       clusterRoles,
+		crtbCache:              crtbs,
+		globalRoleCache:        globalRoles,
+		globalRoleBindingCache: grbs,
+		projectCache:           projects,
+		prtbCache:              prtbs,
+		roleTemplateCache:      roleTemplates,
+		userCache:              users,
+		userAttributeCache:     userAttributes,
+		getNamespace: func(_ context.Context, clusterName, name string) (*corev1.Namespace, error) {
+			for _, ns := range objs.namespaces[clusterName] {
+				if ns.Name == name {
+					return ns, nil
+				}
+			}
+			return nil, notFound(name)
+		},
+	}
+}
+
+func userContext(name string) context.Context {
+	return request.WithUser(context.Background(), &k8suser.DefaultInfo{Name: name})
+}
+
+func TestCreate(t *testing.T) {
+	objs := objects{
+		globalRoles: []*v3.GlobalRole{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+				Builtin:    true,
+				Rules:      adminRules,
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "secrets-reader"},
+				NamespacedRules: map[string][]rbacv1.PolicyRule{
+					"cattle-global-data": {secretsRule},
+				},
+			},
+			{
+				ObjectMeta:            metav1.ObjectMeta{Name: "pod-viewer-everywhere"},
+				InheritedClusterRoles: []string{"cluster-member"},
+			},
+		},
+		globalRoleBindings: []*v3.GlobalRoleBinding{
+			{ObjectMeta: metav1.ObjectMeta{Name: "grb-alice"}, UserName: "u-alice", GlobalRoleName: "secrets-reader"},
+			{ObjectMeta: metav1.ObjectMeta{Name: "grb-devs"}, GroupPrincipalName: "github_team://devs", GlobalRoleName: "pod-viewer-everywhere"},
+		},
+		roleTemplates: []*v3.RoleTemplate{
+			{
+				ObjectMeta:        metav1.ObjectMeta{Name: "cluster-member"},
+				Context:           "cluster",
+				RoleTemplateNames: []string{"view-pods"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "view-pods"},
+				Context:    "project",
+				Locked:     true,
+				Rules:      []rbacv1.PolicyRule{podsRule},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "external-pods"},
+				Context:    "project",
+				External:   true,
+			},
+		},
+		crtbs: []*v3.ClusterRoleTemplateBinding{
+			{
+				ObjectMeta:        metav1.ObjectMeta{Namespace: "c-downstream", Name: "crtb-bob"},
+				UserPrincipalName: "local://u-bob",
+				ClusterName:       "c-downstream",
+				RoleTemplateName:  "cluster-member",
+			},
+		},
+		prtbs: []*v3.ProjectRoleTemplateBinding{
+			{
+				ObjectMeta:       metav1.ObjectMeta{Namespace: "c-downstream-p-web", Name: "prtb-alice"},
+				UserName:         "u-alice",
+				ProjectName:      "c-downstream:p-web",
+				RoleTemplateName: "external-pods",
+			},
+		},
+		clusterRoles: []*rbacv1.ClusterRole{
+			{ObjectMeta: metav1.ObjectMeta{Name: "external-pods"}, Rules: []rbacv1.PolicyRule{podsRule}},
+		},
+		namespaces: map[string][]*corev1.Namespace{
+			"c-downstream": {
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "web",
+						Annotations: map[string]string{projectIDAnnotation: "c-downstream:p-web"},
+					},
+				},
+				{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		user        string
+		allowOthers bool
+		spec        ext.PermissionReviewSpec
+		wantSpec    *ext.PermissionReviewSpec
+		want        ext.PermissionReviewStatus
+		wantErr     func(error) bool
+	}{
+		{
+			name: "namespaced rules of a global role in the local cluster",
+			user: "u-alice",
+			spec: ext.PermissionReviewSpec{Namespace: "cattle-global-data", Resource: "secrets", Verb: "create"},
+			wantSpec: &ext.PermissionReviewSpec{
+				UserName:    "u-alice",
+				ClusterName: "local",
+				Namespace:   "cattle-global-data",
+				Resource:    "secrets",
+				Verb:        "create",
+			},
+			want: ext.PermissionReviewStatus{
+				Allowed: true,
+				Grants: []ext.PermissionGrant{{
+					BindingKind:    "GlobalRoleBinding",
+					BindingName:    "grb-alice",
+					Subject:        "u-alice",
+					GlobalRoleName: "secrets-reader",
+					Source:         ext.PermissionGrantSourceNamespacedRules,
+					Rule:           secretsRule,
+				}},
+				Candidates: []ext.PermissionGrant{{
+					BindingKind:    "GlobalRoleBinding",
+					GlobalRoleName: "admin",
+					Source:         ext.PermissionGrantSourceRules,
+					Rule:           adminRules[0],
+				}},
+			},
+		},
+		{
+			name: "inherited cluster roles through a group and project role template with a cluster role",
+			user: "u-alice",
+			spec: ext.PermissionReviewSpec{
+				ProjectName: "c-downstream:p-web",
+				Namespace:   "web",
+				Resource:    "pods",
+				Verb:        "get",
+			},
+			want: ext.PermissionReviewStatus{
+				Allowed: true,
+				Grants: []ext.PermissionGrant{
+					{
+						BindingKind:       "GlobalRoleBinding",
+						BindingName:       "grb-devs",
+						Subject:           "github_team://devs",
+						GlobalRoleName:    "pod-viewer-everywhere",
+						RoleTemplateNames: []string{"cluster-member", "view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceInheritedClusterRoles,
+						Rule:              podsRule,
+					},
+					{
+						BindingKind:       "ProjectRoleTemplateBinding",
+						BindingNamespace:  "c-downstream-p-web",
+						BindingName:       "prtb-alice",
+						Subject:           "u-alice",
+						RoleTemplateNames: []string{"external-pods"},
+						Source:            ext.PermissionGrantSourceClusterRole,
+						Rule:              podsRule,
+					},
+				},
+				Candidates: []ext.PermissionGrant{
+					{
+						BindingKind:       "ClusterRoleTemplateBinding",
+						RoleTemplateNames: []string{"cluster-member", "view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceRules,
+						Rule:              podsRule,
+					},
+					{
+						BindingKind:    "GlobalRoleBinding",
+						GlobalRoleName: "admin",
+						Source:         ext.PermissionGrantSourceClusterAdmin,
+						Rule:           clusterAdminRule,
+					},
+					{
+						BindingKind:       "ProjectRoleTemplateBinding",
+						RoleTemplateNames: []string{"view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceRules,
+						Rule:              podsRule,
+					},
+				},
+			},
+		},
+		{
+			name: "project resolved from the namespace",
+			user: "u-bob",
+			spec: ext.PermissionReviewSpec{ClusterName: "c-downstream", Namespace: "web", Resource: "pods", Verb: "get"},
+			wantSpec: &ext.PermissionReviewSpec{
+				UserName:    "u-bob",
+				ClusterName: "c-downstream",
+				ProjectName: "c-downstream:p-web",
+				Namespace:   "web",
+				Resource:    "pods",
+				Verb:        "get",
+			},
+			want: ext.PermissionReviewStatus{
+				Allowed: true,
+				Grants: []ext.PermissionGrant{{
+					BindingKind:       "ClusterRoleTemplateBinding",
+					BindingNamespace:  "c-downstream",
+					BindingName:       "crtb-bob",
+					Subject:           "local://u-bob",
+					RoleTemplateNames: []string{"cluster-member", "view-pods"},
+					Locked:            true,
+					Source:            ext.PermissionGrantSourceRules,
+					Rule:              podsRule,
+				}},
+				Candidates: []ext.PermissionGrant{
+					{
+						BindingKind:    "GlobalRoleBinding",
+						GlobalRoleName: "admin",
+						Source:         ext.PermissionGrantSourceClusterAdmin,
+						Rule:           clusterAdminRule,
+					},
+					{
+						BindingKind:       "GlobalRoleBinding",
+						GlobalRoleName:    "pod-viewer-everywhere",
+						RoleTemplateNames: []string{"cluster-member", "view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceInheritedClusterRoles,
+						Rule:              podsRule,
+					},
+					{
+						BindingKind:       "ProjectRoleTemplateBinding",
+						RoleTemplateNames: []string{"external-pods"},
+						Source:            ext.PermissionGrantSourceClusterRole,
+						Rule:              podsRule,
+					},
+					{
+						BindingKind:       "ProjectRoleTemplateBinding",
+						RoleTemplateNames: []string{"view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceRules,
+						Rule:              podsRule,
+					},
+				},
+			},
+		},
+		{
+			name: "namespace without a project",
+			user: "u-bob",
+			spec: ext.PermissionReviewSpec{ClusterName: "c-downstream", Namespace: "default", Resource: "pods", Verb: "get"},
+			wantSpec: &ext.PermissionReviewSpec{
+				UserName:    "u-bob",
+				ClusterName: "c-downstream",
+				Namespace:   "default",
+				Resource:    "pods",
+				Verb:        "get",
+			},
+			want: ext.PermissionReviewStatus{
+				Allowed: true,
+				Grants: []ext.PermissionGrant{{
+					BindingKind:       "ClusterRoleTemplateBinding",
+					BindingNamespace:  "c-downstream",
+					BindingName:       "crtb-bob",
+					Subject:           "local://u-bob",
+					RoleTemplateNames: []string{"cluster-member", "view-pods"},
+					Locked:            true,
+					Source:            ext.PermissionGrantSourceRules,
+					Rule:              podsRule,
+				}},
+				Candidates: []ext.PermissionGrant{
+					{
+						BindingKind:    "GlobalRoleBinding",
+						GlobalRoleName: "admin",
+						Source:         ext.PermissionGrantSourceClusterAdmin,
+						Rule:           clusterAdminRule,
+					},
+					{
+						BindingKind:       "GlobalRoleBinding",
+						GlobalRoleName:    "pod-viewer-everywhere",
+						RoleTemplateNames: []string{"cluster-member", "view-pods"},
+						Locked:            true,
+						Source:            ext.PermissionGrantSourceInheritedClusterRoles,
+						Rule:              podsRule,
+					},
+				},
+			},
+		},
+		{
+			name:        "other user denied through a cluster role template binding to a principal",
+			user:        "u-admin",
+			allowOthers: true,
+			spec: ext.PermissionReviewSpec{
+				UserName:    "u-bob",
+				ClusterName: "c-downstream",
+				Resource:    "pods",
+				Verb:        "delete",
+			},
+			want: ext.PermissionReviewStatus{
+				Candidates: []ext.PermissionGrant{{
+					BindingKind:    "GlobalRoleBinding",
+					GlobalRoleName: "admin",
+					Source:         ext.PermissionGrantSourceClusterAdmin,
+					Rule:           clusterAdminRule,
+				}},
+			},
+		},
+		{
+			name: "other user forbidden",
+			user: "u-bob",
+			spec: ext.PermissionReviewSpec{UserName: "u-alice", Resource: "pods", Verb: "get"},
+			wantErr: func(err error) bool {
+				return apierrors.IsForbidden(err)
+			},
+		},
+		{
+			name:        "user and group principal",
+			user:        "u-admin",
+			allowOthers: true,
+			spec:        ext.PermissionReviewSpec{UserName: "u-alice", GroupPrincipalName: "github_team://devs", Resource: "pods", Verb: "get"},
+			wantErr:     apierrors.IsBadRequest,
+		},
+		{
+			name:    "project in another cluster",
+			user:    "u-alice",
+			spec:    ext.PermissionReviewSpec{ClusterName: "local", ProjectName: "c-downstream:p-web", Resource: "pods", Verb: "get"},
+			wantErr: apierrors.IsBadRequest,
+		},
+		{
+			name:    "unknown cluster",
+			user:    "u-alice",
+			spec:    ext.PermissionReviewSpec{ClusterName: "c-unknown", Resource: "pods", Verb: "get"},
+			wantErr: apierrors.IsBadRequest,
+		},
+		{
+			name:    "missing verb",
+			user:    "u-alice",
+			spec:    ext.PermissionReviewSpec{Resource: "pods"},
+			wantErr: apierrors.IsBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, objs, tt.allowOthers)
+
+			obj, err := store.Create(userContext(tt.user), &ext.PermissionReview{Spec: tt.spec}, nil, nil)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, tt.wantErr(err), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+
+			review, ok := obj.(*ext.PermissionReview)
+			require.True(t, ok)
+			if tt.wantSpec != nil {
+				assert.Equal(t, *tt.wantSpec, review.Spec)
+			}
+			assert.Equal(t, tt.want, review.Status)
+		})
+	}
+}
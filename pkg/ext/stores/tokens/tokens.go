@@ -20,6 +20,7 @@
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
 	extcommon "github.com/rancher/rancher/pkg/ext/common"
 	v3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
@@ -59,6 +60,7 @@
 	GeneratePrefix       = "token-"
 
 	// names of the data fields used by the backing secrets to store token information
+	FieldConditions       = "conditions"
 	FieldDescription      = "description"
 	FieldEnabled          = "enabled"
 	FieldHash             = "hash"
@@ -67,8 +69,10 @@
 	FieldLastUpdateTime   = "last-update-time"
 	FieldLastUsedAt       = "last-used-at"
 	FieldPrincipal        = "principal"
+	FieldScope            = "scope"
 	FieldTTL              = "ttl"
 	FieldUID              = "kube-uid"
+	FieldUsage            = "usage"
 	FieldUserID           = "user-id"
 
 	SingularName = "token"
@@ -120,6 +124,7 @@ type SystemStore struct {
 	hasher          hashHandler         // access to generation and hashing of secret values
 	auth            authHandler         // access to user retrieval from context
 	tableConverter  rest.TableConvertor // custom column formatting
+	usage           *usageThrottle      // throttling of usage updates
 }
 
 // NewFromWrangler is a convenience function for creating a token store.
@@ -168,6 +173,7 @@ func New(
 			tableConverter: printerstorage.TableConvertor{
 				TableGenerator: printers.NewTableGenerator().With(printHandler),
 			},
+			usage: newUsageThrottle(),
 		},
 	}
 	return &tokenStore
@@ -212,6 +218,7 @@ func NewSystem(
 		timer:           timer,
 		hasher:          hasher,
 		auth:            auth,
+		usage:           newUsageThrottle(),
 	}
 	return &tokenStore
 }
@@ -346,6 +353,7 @@ func printHandler(h printers.PrintHandler) {
 		{Name: "User", Type: "string", Priority: 1, Description: "User is the owner of the token"},
 		{Name: "Kind", Type: "string", Description: "Kind/purpose of the token"},
 		{Name: "TTL", Type: "string", Description: "The time-to-live for the token"},
+		{Name: "Scope", Type: "string", Description: "Restrictions on what the token can be used for"},
 		{Name: "Age", Type: "string", Description: metav1.ObjectMeta{}.SwaggerDoc()["creationTimestamp"]},
 		{Name: "Description", Type: "string", Priority: 1, Description: "Human readable description of the token"},
 	}
@@ -362,6 +370,7 @@ func printToken(token *ext.Token, options printers.GenerateOptions) ([]metav1.Ta
 			token.Spec.UserID,
 			token.Spec.Kind,
 			duration.HumanDuration(time.Duration(token.Spec.TTL) * time.Millisecond),
+			scope.Summary(token.Spec.Scope),
 			translateTimestampSince(token.CreationTimestamp),
 			token.Spec.Description,
 		},
@@ -486,6 +495,10 @@ func (t *SystemStore) Create(ctx context.Context, group schema.GroupResource, to
 	// check if the user does not wish to actually change anything
 	dryRun := options != nil && len(options.DryRun) > 0 && options.DryRun[0] == metav1.DryRunAll
 
+	if err := scope.Validate(token.Spec.Scope); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid spec.scope: %s", err))
+	}
+
 	user, err := t.userClient.Get(token.Spec.UserID)
 	if err != nil {
 		return nil, apierrors.NewInternalError(fmt.Errorf("failed to retrieve user %s: %w",
@@ -505,6 +518,11 @@ func (t *SystemStore) Create(ctx context.Context, group schema.GroupResource, to
 		return nil, apierrors.NewInternalError(err)
 	}
 
+	// A scoped token must not be able to escape its scope by creating a new token.
+	if extToken, ok := requestToken.(*ext.Token); ok && extToken.IsScoped() {
+		return nil, apierrors.NewForbidden(group, "", fmt.Errorf("scoped tokens can't be used to create tokens"))
+	}
+
 	rtPrincipal := requestToken.GetUserPrincipal()
 	token.Spec.UserPrincipal = ext.TokenPrincipal{
 		Name:           rtPrincipal.ObjectMeta.Name,
@@ -775,6 +793,10 @@ func (t *SystemStore) update(sessionID string, fullPermission bool, oldToken, to
 		return nil, apierrors.NewBadRequest("spec.userprincipal is immutable")
 	}
 
+	if !reflect.DeepEqual(token.Spec.Scope, oldToken.Spec.Scope) {
+		return nil, apierrors.NewBadRequest("spec.scope is immutable")
+	}
+
 	// Regular users are not allowed to extend the TTL.
 	if !fullPermission {
 		ttl, err := clampMaxTTL(token.Spec.TTL)
@@ -804,6 +826,13 @@ func (t *SystemStore) update(sessionID string, fullPermission bool, oldToken, to
 		return token, nil
 	}
 
+	// The usage history is not part of the token, carry it over.
+	if currentSecret, err := t.secretCache.Get(TokenNamespace, token.Name); err == nil {
+		if usage := currentSecret.Data[FieldUsage]; len(usage) > 0 {
+			secret.StringData[FieldUsage] = string(usage)
+		}
+	}
+
 	newSecret, err := t.secretClient.Update(secret)
 	if err != nil {
 		return nil, apierrors.NewInternalError(fmt.Errorf("failed to save updated token: %w", err))
@@ -1365,6 +1394,14 @@ func toSecret(token *ext.Token) (*corev1.Secret, error) {
 	secret.StringData[FieldTTL] = fmt.Sprintf("%d", ttl)
 	secret.StringData[FieldUserID] = token.Spec.UserID
 
+	if token.Spec.Scope != nil {
+		scopeBytes, err := json.Marshal(token.Spec.Scope)
+		if err != nil {
+			return nil, err
+		}
+		secret.StringData[FieldScope] = string(scopeBytes)
+	}
+
 	// status elements
 	lastUsedAtAsString := ""
 	if token.Status.LastUsedAt != nil {
@@ -1375,6 +1412,14 @@ func toSecret(token *ext.Token) (*corev1.Secret, error) {
 	secret.StringData[FieldLastUpdateTime] = token.Status.LastUpdateTime
 	secret.StringData[FieldLastActivitySeen] = ""
 
+	if len(token.Status.Conditions) > 0 {
+		conditionsBytes, err := json.Marshal(token.Status.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		secret.StringData[FieldConditions] = string(conditionsBytes)
+	}
+
 	return secret, nil
 }
 
@@ -1430,6 +1475,13 @@ func fromSecret(secret *corev1.Secret) (*ext.Token, error) {
 	}
 	token.Spec.TTL = ttl
 
+	if scopeBytes := secret.Data[FieldScope]; len(scopeBytes) > 0 {
+		token.Spec.Scope = &ext.TokenScope{}
+		if err := json.Unmarshal(scopeBytes, token.Spec.Scope); err != nil {
+			return nil, fmt.Errorf("failed to parse scope: %w", err)
+		}
+	}
+
 	// status information
 	if token.Status.Hash = string(secret.Data[FieldHash]); token.Status.Hash == "" {
 		return nil, fmt.Errorf("token hash missing")
@@ -1451,6 +1503,12 @@ func fromSecret(secret *corev1.Secret) (*ext.Token, error) {
 	}
 	token.Status.LastActivitySeen = lastActivitySeen
 
+	if conditionsBytes := secret.Data[FieldConditions]; len(conditionsBytes) > 0 {
+		if err := json.Unmarshal(conditionsBytes, &token.Status.Conditions); err != nil {
+			return nil, fmt.Errorf("failed to parse conditions: %w", err)
+		}
+	}
+
 	if err := setExpired(token); err != nil {
 		return nil, fmt.Errorf("failed to set expiration information: %w", err)
 	}
@@ -836,6 +836,67 @@ func Test_Store_Create(t *testing.T) {
 					Return(&mockUser{name: "lkajdlksjlkds"}, false, true, nil)
 			},
 		},
+		{
+			name: "invalid scope",
+			err:  apierrors.NewBadRequest("invalid spec.scope: scope.sourceCIDRs contains an invalid CIDR \"10.0.0.1\": invalid CIDR address: 10.0.0.1"),
+			tok: &ext.Token{
+				Spec: ext.TokenSpec{
+					UserID: "world",
+					Scope:  &ext.TokenScope{SourceCIDRs: []string{"10.0.0.1"}},
+				},
+			},
+			opts: &metav1.CreateOptions{},
+			storeSetup: func( // configure store backend clients
+				space *fake.MockNonNamespacedControllerInterface[*corev1.Namespace, *corev1.NamespaceList],
+				secrets *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList],
+				scache *fake.MockCacheInterface[*corev1.Secret],
+				users *fake.MockNonNamespacedCacheInterface[*v3.User],
+				token *fake.MockNonNamespacedCacheInterface[*v3.Token],
+				timer *MocktimeHandler,
+				hasher *MockhashHandler,
+				auth *MockauthHandler) {
+
+				auth.EXPECT().UserName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(&mockUser{name: "world"}, false, true, nil)
+			},
+		},
+		{
+			name: "request token is scoped",
+			err:  apierrors.NewForbidden(GVR.GroupResource(), "", fmt.Errorf("scoped tokens can't be used to create tokens")),
+			tok: &ext.Token{
+				Spec: ext.TokenSpec{
+					UserID: "world",
+				},
+			},
+			opts: &metav1.CreateOptions{},
+			storeSetup: func( // configure store backend clients
+				space *fake.MockNonNamespacedControllerInterface[*corev1.Namespace, *corev1.NamespaceList],
+				secrets *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList],
+				scache *fake.MockCacheInterface[*corev1.Secret],
+				users *fake.MockNonNamespacedCacheInterface[*v3.User],
+				token *fake.MockNonNamespacedCacheInterface[*v3.Token],
+				timer *MocktimeHandler,
+				hasher *MockhashHandler,
+				auth *MockauthHandler) {
+
+				auth.EXPECT().UserName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(&mockUser{name: "world"}, false, true, nil)
+
+				users.EXPECT().Get("world").
+					Return(enabledUser, nil)
+
+				// session token fetch, not a v3 token, but a scoped ext token
+				auth.EXPECT().SessionID(gomock.Any()).
+					Return("session-token")
+				token.EXPECT().Get("session-token").
+					Return(nil, someerror)
+				scopedSecret := properSecret.DeepCopy()
+				scopedSecret.Name = "session-token"
+				scopedSecret.Data[FieldScope] = []byte(`{"clusters":["c-1"]}`)
+				scache.EXPECT().Get("cattle-tokens", "session-token").
+					Return(scopedSecret, nil)
+			},
+		},
 		// token generation and hash errors -- no mocking -- unable to induce and test
 		{
 			name: "user retrieval error",
@@ -1599,6 +1660,18 @@ func Test_SystemStore_Update(t *testing.T) {
 			}(),
 			err: apierrors.NewBadRequest("spec.kind is immutable"),
 		},
+		{
+			name:     "reject scope change",
+			fullPerm: true,
+			opts:     &metav1.UpdateOptions{},
+			old:      &properToken,
+			token: func() *ext.Token {
+				changed := properToken.DeepCopy()
+				changed.Spec.Scope = &ext.TokenScope{Clusters: []string{"c-1"}}
+				return changed
+			}(),
+			err: apierrors.NewBadRequest("spec.scope is immutable"),
+		},
 		// Tests comparing inbound token against stored token, acceptable changes, and other errors
 		{
 			name:     "accept ttl extension (full permission)",
@@ -1809,6 +1882,57 @@ func Test_SystemStore_Update(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			name:     "ok, usage history and conditions are kept",
+			fullPerm: true,
+			opts:     &metav1.UpdateOptions{},
+			old: func() *ext.Token {
+				old := properToken.DeepCopy()
+				old.Status.Conditions = []metav1.Condition{{Type: "UsageAnomaly", Status: metav1.ConditionTrue, Reason: "NewNetwork"}}
+				return old
+			}(),
+			token: func() *ext.Token {
+				changed := properToken.DeepCopy()
+				changed.Spec.Description = "changed"
+				return changed
+			}(),
+			rtok: func() *ext.Token {
+				changed := properToken.DeepCopy()
+				changed.Spec.Description = "changed"
+				changed.Status.LastUpdateTime = "this is a fake now"
+				changed.Status.Conditions = []metav1.Condition{{Type: "UsageAnomaly", Status: metav1.ConditionTrue, Reason: "NewNetwork"}}
+				return changed
+			}(),
+			storeSetup: func(
+				secrets *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList],
+				scache *fake.MockCacheInterface[*corev1.Secret],
+				timer *MocktimeHandler,
+				hasher *MockhashHandler,
+				auth *MockauthHandler) {
+
+				// Fake current time
+				timer.EXPECT().Now().Return("this is a fake now")
+
+				current := properSecret.DeepCopy()
+				current.Data[FieldUsage] = []byte(`[{"firstSeen":"2025-01-01T00:00:00Z","lastSeen":"2025-01-01T00:00:00Z","count":1}]`)
+				scache.EXPECT().Get("cattle-tokens", properSecret.Name).Return(current, nil)
+
+				secrets.EXPECT().
+					Update(gomock.Any()).
+					DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+						assert.Equal(t, string(current.Data[FieldUsage]), secret.StringData[FieldUsage])
+						assert.JSONEq(t, `[{"type":"UsageAnomaly","status":"True","reason":"NewNetwork","message":"","lastTransitionTime":null}]`,
+							secret.StringData[FieldConditions])
+
+						changed := current.DeepCopy()
+						changed.Data[FieldDescription] = []byte("changed")
+						changed.Data[FieldLastUpdateTime] = []byte("this is a fake now")
+						changed.Data[FieldConditions] = []byte(secret.StringData[FieldConditions])
+						return changed, nil
+					})
+			},
+			err: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -1831,6 +1955,8 @@ func Test_SystemStore_Update(t *testing.T) {
 			if test.storeSetup != nil {
 				test.storeSetup(secrets, scache, timer, hasher, auth)
 			}
+			// the current secret is read to carry over the usage history
+			scache.EXPECT().Get("cattle-tokens", gomock.Any()).Return(&properSecret, nil).AnyTimes()
 
 			// perform test and validate results
 			tok, err := store.update("", test.fullPerm, test.old, test.token, test.opts)
@@ -0,0 +1,272 @@
+package tokens
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// UsageSubresource is the name of the token subresource serving the usage history.
+	UsageSubresource = "usage"
+	// MaxUsageRecords is the maximum number of usage records kept per token.
+	MaxUsageRecords = 20
+	// UsageIdleGap is the time after which a use by a client starts a new usage record.
+	UsageIdleGap = time.Hour
+
+	maxUserAgentLength = 256
+)
+
+// UsageGVK is the GroupVersionKind of the token usage subresource.
+var UsageGVK = schema.GroupVersionKind{
+	Group:   GV.Group,
+	Version: GV.Version,
+	Kind:    "TokenUsage",
+}
+
+// +k8s:openapi-gen=false
+// +k8s:deepcopy-gen=false
+
+// UsageStore serves the usage history of tokens as the usage subresource of
+// the tokens. Users can read the usage history of the tokens they can read.
+type UsageStore struct {
+	store *Store
+}
+
+// NewUsageStore returns the store for the usage subresource of the tokens in
+// the given token store.
+func NewUsageStore(store *Store) *UsageStore {
+	return &UsageStore{store: store}
+}
+
+// GroupVersionKind implements [rest.GroupVersionKindProvider], a required interface.
+func (u *UsageStore) GroupVersionKind(_ schema.GroupVersion) schema.GroupVersionKind {
+	return UsageGVK
+}
+
+// NamespaceScoped implements [rest.Scoper], a required interface.
+func (u *UsageStore) NamespaceScoped() bool {
+	return false
+}
+
+// New implements [rest.Storage], a required interface.
+func (u *UsageStore) New() runtime.Object {
+	obj := &ext.TokenUsage{}
+	obj.GetObjectKind().SetGroupVersionKind(UsageGVK)
+	return obj
+}
+
+// Destroy implements [rest.Storage], a required interface.
+func (u *UsageStore) Destroy() {
+}
+
+// Get implements [rest.Getter], the interface to support the `get` verb.
+func (u *UsageStore) Get(
+	ctx context.Context,
+	name string,
+	options *metav1.GetOptions) (runtime.Object, error) {
+	// Access to the usage history follows access to the token.
+	if _, err := u.store.get(ctx, name, options); err != nil {
+		return nil, err
+	}
+
+	return u.store.GetUsage(name)
+}
+
+// GetUsage returns the usage history of the named token.
+func (t *SystemStore) GetUsage(name string) (*ext.TokenUsage, error) {
+	secret, err := t.secretCache.Get(TokenNamespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, apierrors.NewNotFound(GVR.GroupResource(), name)
+		}
+		return nil, apierrors.NewInternalError(fmt.Errorf("failed to retrieve token %s: %w", name, err))
+	}
+
+	records, err := UsageFromSecret(secret)
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("failed to extract usage of token %s: %w", name, err))
+	}
+
+	return &ext.TokenUsage{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       UsageGVK.Kind,
+			APIVersion: GV.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              secret.Name,
+			UID:               types.UID(secret.Data[FieldUID]),
+			CreationTimestamp: secret.CreationTimestamp,
+		},
+		Records: records,
+	}, nil
+}
+
+// usageThrottle throttles the usage updates of a token to one per second and
+// source address, so uses from other addresses are still recorded.
+type usageThrottle struct {
+	sync.Mutex
+	last map[usageThrottleKey]time.Time
+}
+
+type usageThrottleKey struct {
+	name     string
+	sourceIP string
+}
+
+func newUsageThrottle() *usageThrottle {
+	return &usageThrottle{last: map[usageThrottleKey]time.Time{}}
+}
+
+// allow returns whether a use of the named token from the source address at
+// now, which has a precision of a second, is recorded.
+func (u *usageThrottle) allow(name, sourceIP string, now time.Time) bool {
+	u.Lock()
+	defer u.Unlock()
+
+	key := usageThrottleKey{name: name, sourceIP: sourceIP}
+	if last, ok := u.last[key]; ok && last.Equal(now) {
+		return false
+	}
+	// Only the uses of the current second are needed, drop the others.
+	for k, last := range u.last {
+		if !last.Equal(now) {
+			delete(u.last, k)
+		}
+	}
+	u.last[key] = now
+	return true
+}
+
+// RecordUsage adds a use of the token to its usage history and patches the
+// last-used-at information of the token. Called during authentication.
+// Uses by the same client are aggregated into a single record until the
+// client is idle for longer than [UsageIdleGap]. Only the most recent
+// [MaxUsageRecords] records are kept. Uses from the same source address within
+// the same second are only recorded once. The patch is conditional on the
+// resource version of the token, and retried on conflicts, so concurrent uses
+// are not lost.
+func (t *SystemStore) RecordUsage(name string, now time.Time, sourceIP, userAgent, clusterID string) error {
+	if !t.usage.allow(name, sourceIP, now) {
+		return nil
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	// The cache is tried first, retries read the current secret.
+	fromCache := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var secret *corev1.Secret
+		var err error
+		if fromCache {
+			secret, err = t.secretCache.Get(TokenNamespace, name)
+			fromCache = false
+		} else {
+			secret, err = t.secretClient.Get(TokenNamespace, name, metav1.GetOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		records, err := UsageFromSecret(secret)
+		if err != nil {
+			return err
+		}
+		records = addUsage(records, now, sourceIP, userAgent, clusterID)
+
+		usageBytes, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+
+		// Operate directly on the backend secret holding the token. The
+		// resource version makes the patch fail with a conflict if the
+		// secret was changed since it was read.
+		patch, err := json.Marshal([]struct {
+			Op    string `json:"op"`
+			Path  string `json:"path"`
+			Value any    `json:"value"`
+		}{{
+			Op:    "replace",
+			Path:  "/metadata/resourceVersion",
+			Value: secret.ResourceVersion,
+		}, {
+			Op:    "add",
+			Path:  "/data/" + FieldUsage,
+			Value: base64.StdEncoding.EncodeToString(usageBytes),
+		}, {
+			Op:    "replace",
+			Path:  "/data/" + FieldLastUsedAt,
+			Value: base64.StdEncoding.EncodeToString([]byte(now.Format(time.RFC3339))),
+		}})
+		if err != nil {
+			return err
+		}
+
+		_, err = t.secretClient.Patch(TokenNamespace, name, types.JSONPatchType, patch)
+		return err
+	})
+}
+
+// UsageFromSecret extracts the usage history from the backing secret of a token.
+func UsageFromSecret(secret *corev1.Secret) ([]ext.TokenUsageRecord, error) {
+	records := []ext.TokenUsageRecord{}
+	if usageBytes := secret.Data[FieldUsage]; len(usageBytes) > 0 {
+		if err := json.Unmarshal(usageBytes, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse usage: %w", err)
+		}
+	}
+
+	return records, nil
+}
+
+// addUsage adds a use to the records, which are ordered by the time they were
+// first seen, most recent first.
+func addUsage(records []ext.TokenUsageRecord, now time.Time, sourceIP, userAgent, clusterID string) []ext.TokenUsageRecord {
+	for i := range records {
+		record := &records[i]
+		if record.SourceIP != sourceIP || record.UserAgent != userAgent || record.ClusterID != clusterID {
+			continue
+		}
+		if now.Sub(record.LastSeen.Time) > UsageIdleGap {
+			break
+		}
+		record.LastSeen = metav1.NewTime(now)
+		record.Count++
+		return records
+	}
+
+	records = append([]ext.TokenUsageRecord{{
+		FirstSeen: metav1.NewTime(now),
+		LastSeen:  metav1.NewTime(now),
+		Count:     1,
+		SourceIP:  sourceIP,
+		UserAgent: userAgent,
+		ClusterID: clusterID,
+	}}, records...)
+	if len(records) > MaxUsageRecords {
+		records = records[:MaxUsageRecords]
+	}
+
+	return records
+}
+
+var (
+	_ rest.Getter                   = &UsageStore{}
+	_ rest.Storage                  = &UsageStore{}
+	_ rest.Scoper                   = &UsageStore{}
+	_ rest.GroupVersionKindProvider = &UsageStore{}
+)
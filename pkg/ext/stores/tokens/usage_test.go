@@ -0,0 +1,314 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ext "github.com/rancher/rancher/pkg/apis/ext.cattle.io/v1"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_addUsage(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := func(firstSeen, lastSeen time.Time, count int64, sourceIP string) ext.TokenUsageRecord {
+		return ext.TokenUsageRecord{
+			FirstSeen: metav1.NewTime(firstSeen),
+			LastSeen:  metav1.NewTime(lastSeen),
+			Count:     count,
+			SourceIP:  sourceIP,
+			UserAgent: "kubectl",
+			ClusterID: "local",
+		}
+	}
+
+	tests := map[string]struct {
+		records  []ext.TokenUsageRecord
+		sourceIP string
+		want     []ext.TokenUsageRecord
+	}{
+		"first use": {
+			sourceIP: "10.0.0.1",
+			want:     []ext.TokenUsageRecord{record(now, now, 1, "10.0.0.1")},
+		},
+		"use by the same client is aggregated": {
+			records: []ext.TokenUsageRecord{
+				record(now.Add(-time.Minute), now.Add(-time.Minute), 1, "10.0.0.2"),
+				record(now.Add(-time.Hour), now.Add(-10*time.Minute), 5, "10.0.0.1"),
+			},
+			sourceIP: "10.0.0.1",
+			want: []ext.TokenUsageRecord{
+				record(now.Add(-time.Minute), now.Add(-time.Minute), 1, "10.0.0.2"),
+				record(now.Add(-time.Hour), now, 6, "10.0.0.1"),
+			},
+		},
+		"use by another client starts a new record": {
+			records:  []ext.TokenUsageRecord{record(now.Add(-time.Hour), now.Add(-time.Minute), 5, "10.0.0.1")},
+			sourceIP: "10.0.0.2",
+			want: []ext.TokenUsageRecord{
+				record(now, now, 1, "10.0.0.2"),
+				record(now.Add(-time.Hour), now.Add(-time.Minute), 5, "10.0.0.1"),
+			},
+		},
+		"use after being idle starts a new record": {
+			records:  []ext.TokenUsageRecord{record(now.Add(-3*time.Hour), now.Add(-2*time.Hour), 5, "10.0.0.1")},
+			sourceIP: "10.0.0.1",
+			want: []ext.TokenUsageRecord{
+				record(now, now, 1, "10.0.0.1"),
+				record(now.Add(-3*time.Hour), now.Add(-2*time.Hour), 5, "10.0.0.1"),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := addUsage(test.records, now, test.sourceIP, "kubectl", "local")
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	t.Run("oldest records are dropped", func(t *testing.T) {
+		var records []ext.TokenUsageRecord
+		for i := 0; i < MaxUsageRecords; i++ {
+			records = append(records, record(now.Add(-time.Duration(i)*time.Minute), now, 1, fmt.Sprintf("10.0.0.%d", i)))
+		}
+
+		got := addUsage(records, now, "10.0.1.1", "kubectl", "local")
+		require.Len(t, got, MaxUsageRecords)
+		assert.Equal(t, "10.0.1.1", got[0].SourceIP)
+		assert.Equal(t, records[MaxUsageRecords-2], got[MaxUsageRecords-1])
+	})
+}
+
+func Test_SystemStore_RecordUsage(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("patch usage and last-used-at, ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		secret := properSecret.DeepCopy()
+		secret.ResourceVersion = "42"
+		scache.EXPECT().Get("cattle-tokens", "atoken").Return(secret, nil)
+
+		var patchData []byte
+		secrets.EXPECT().Patch("cattle-tokens", "atoken", types.JSONPatchType, gomock.Any()).
+			DoAndReturn(func(space, name string, pt types.PatchType, data []byte, subresources ...any) (*corev1.Secret, error) {
+				patchData = data
+				return nil, nil
+			})
+
+		store := NewSystem(nil, nil, secrets, users, nil, nil, nil, nil)
+		err := store.RecordUsage("atoken", now, "10.0.0.1", strings.Repeat("a", 300), "local")
+		require.NoError(t, err)
+
+		var patch []struct {
+			Op    string          `json:"op"`
+			Path  string          `json:"path"`
+			Value json.RawMessage `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(patchData, &patch))
+		require.Len(t, patch, 3)
+		assert.Equal(t, "replace", patch[0].Op)
+		assert.Equal(t, "/metadata/resourceVersion", patch[0].Path)
+		assert.JSONEq(t, `"42"`, string(patch[0].Value))
+		assert.Equal(t, "add", patch[1].Op)
+		assert.Equal(t, "/data/usage", patch[1].Path)
+		assert.Equal(t, "replace", patch[2].Op)
+		assert.Equal(t, "/data/last-used-at", patch[2].Path)
+		var lastUsedAt []byte
+		require.NoError(t, json.Unmarshal(patch[2].Value, &lastUsedAt))
+		assert.Equal(t, "2025-01-01T12:00:00Z", string(lastUsedAt))
+
+		var usage []byte
+		require.NoError(t, json.Unmarshal(patch[1].Value, &usage))
+		var records []ext.TokenUsageRecord
+		require.NoError(t, json.Unmarshal(usage, &records))
+		assert.Equal(t, []ext.TokenUsageRecord{{
+			FirstSeen: metav1.NewTime(now.Local()),
+			LastSeen:  metav1.NewTime(now.Local()),
+			Count:     1,
+			SourceIP:  "10.0.0.1",
+			UserAgent: strings.Repeat("a", maxUserAgentLength),
+			ClusterID: "local",
+		}}, records)
+	})
+
+	t.Run("conflict, retried with the current secret", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		stale := properSecret.DeepCopy()
+		stale.ResourceVersion = "1"
+		scache.EXPECT().Get("cattle-tokens", "atoken").Return(stale, nil)
+
+		// Another use was recorded concurrently.
+		current := properSecret.DeepCopy()
+		current.ResourceVersion = "2"
+		usage, err := json.Marshal([]ext.TokenUsageRecord{{
+			FirstSeen: metav1.NewTime(now.Add(-2 * UsageIdleGap)),
+			LastSeen:  metav1.NewTime(now.Add(-2 * UsageIdleGap)),
+			Count:     1,
+			SourceIP:  "10.0.0.2",
+		}})
+		require.NoError(t, err)
+		current.Data[FieldUsage] = usage
+		secrets.EXPECT().Get("cattle-tokens", "atoken", metav1.GetOptions{}).Return(current, nil)
+
+		var patches [][]byte
+		secrets.EXPECT().Patch("cattle-tokens", "atoken", types.JSONPatchType, gomock.Any()).
+			DoAndReturn(func(space, name string, pt types.PatchType, data []byte, subresources ...any) (*corev1.Secret, error) {
+				patches = append(patches, data)
+				if len(patches) == 1 {
+					return nil, apierrors.NewConflict(GVR.GroupResource(), name, fmt.Errorf("modified"))
+				}
+				return nil, nil
+			}).Times(2)
+
+		store := NewSystem(nil, nil, secrets, users, nil, nil, nil, nil)
+		require.NoError(t, store.RecordUsage("atoken", now, "10.0.0.1", "kubectl", "local"))
+
+		var patch []struct {
+			Path  string          `json:"path"`
+			Value json.RawMessage `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(patches[1], &patch))
+		assert.JSONEq(t, `"2"`, string(patch[0].Value))
+		var recordsBytes []byte
+		require.NoError(t, json.Unmarshal(patch[1].Value, &recordsBytes))
+		var records []ext.TokenUsageRecord
+		require.NoError(t, json.Unmarshal(recordsBytes, &records))
+		require.Len(t, records, 2)
+		assert.Equal(t, "10.0.0.1", records[0].SourceIP)
+		assert.Equal(t, "10.0.0.2", records[1].SourceIP)
+	})
+
+	t.Run("uses within a second are throttled per source address", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		scache.EXPECT().Get("cattle-tokens", "atoken").Return(properSecret.DeepCopy(), nil).Times(3)
+		secrets.EXPECT().Patch("cattle-tokens", "atoken", types.JSONPatchType, gomock.Any()).Return(nil, nil).Times(3)
+
+		store := NewSystem(nil, nil, secrets, users, nil, nil, nil, nil)
+		require.NoError(t, store.RecordUsage("atoken", now, "10.0.0.1", "kubectl", "local"))
+		require.NoError(t, store.RecordUsage("atoken", now, "10.0.0.1", "kubectl", "local"))
+		require.NoError(t, store.RecordUsage("atoken", now, "10.0.0.2", "kubectl", "local"))
+		require.NoError(t, store.RecordUsage("atoken", now.Add(time.Second), "10.0.0.1", "kubectl", "local"))
+	})
+
+	t.Run("patch usage, error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		scache.EXPECT().Get("cattle-tokens", "atoken").Return(properSecret.DeepCopy(), nil)
+		secrets.EXPECT().Patch("cattle-tokens", "atoken", types.JSONPatchType, gomock.Any()).
+			Return(nil, fmt.Errorf("some error"))
+
+		store := NewSystem(nil, nil, secrets, users, nil, nil, nil, nil)
+		err := store.RecordUsage("atoken", now, "10.0.0.1", "kubectl", "local")
+		assert.Equal(t, fmt.Errorf("some error"), err)
+	})
+
+	t.Run("broken usage data", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		broken := properSecret.DeepCopy()
+		broken.Data[FieldUsage] = []byte("{")
+		scache.EXPECT().Get("cattle-tokens", "atoken").Return(broken, nil)
+
+		store := NewSystem(nil, nil, secrets, users, nil, nil, nil, nil)
+		err := store.RecordUsage("atoken", now, "10.0.0.1", "kubectl", "local")
+		assert.ErrorContains(t, err, "failed to parse usage")
+	})
+}
+
+func Test_UsageStore_Get(t *testing.T) {
+	usageSecret := properSecret.DeepCopy()
+	usageSecret.Data[FieldUsage] = []byte(`[{"firstSeen":"2025-01-01T00:00:00Z","lastSeen":"2025-01-01T01:00:00Z","count":3,"sourceIP":"10.0.0.1","userAgent":"kubectl","clusterID":"c-1"}]`)
+
+	t.Run("not owned, no permission, not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+		auth := NewMockauthHandler(ctrl)
+
+		auth.EXPECT().SessionID(gomock.Any()).Return("")
+		auth.EXPECT().UserName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&mockUser{name: "lkajdl/ksjlkds"}, false, true, nil)
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		scache.EXPECT().Get("cattle-tokens", "bogus").Return(usageSecret, nil)
+
+		store := NewUsageStore(New(nil, nil, nil, secrets, users, nil, nil, nil, auth))
+		usage, err := store.Get(context.TODO(), "bogus", &metav1.GetOptions{})
+
+		assert.Equal(t, bogusNotFoundError, err)
+		assert.Nil(t, usage)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+		scache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+		users := fake.NewMockNonNamespacedControllerInterface[*v3.User, *v3.UserList](ctrl)
+		auth := NewMockauthHandler(ctrl)
+
+		auth.EXPECT().SessionID(gomock.Any()).Return("")
+		auth.EXPECT().UserName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&mockUser{name: "lkajdlksjlkds"}, false, true, nil)
+		users.EXPECT().Cache().Return(nil)
+		secrets.EXPECT().Cache().Return(scache)
+		scache.EXPECT().Get("cattle-tokens", "bogus").Return(usageSecret, nil).Times(2)
+
+		store := NewUsageStore(New(nil, nil, nil, secrets, users, nil, nil, nil, auth))
+		obj, err := store.Get(context.TODO(), "bogus", &metav1.GetOptions{})
+		require.NoError(t, err)
+
+		usage, ok := obj.(*ext.TokenUsage)
+		require.True(t, ok)
+		assert.Equal(t, "TokenUsage", usage.Kind)
+		assert.Equal(t, "bogus", usage.Name)
+		assert.Equal(t, []ext.TokenUsageRecord{{
+			FirstSeen: metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Local()),
+			LastSeen:  metav1.NewTime(time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC).Local()),
+			Count:     3,
+			SourceIP:  "10.0.0.1",
+			UserAgent: "kubectl",
+			ClusterID: "c-1",
+		}}, usage.Records)
+	})
+}
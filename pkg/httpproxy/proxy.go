@@ -12,6 +12,7 @@
 	prov "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/controllers/management/cluster"
 	provcluster "github.com/rancher/rancher/pkg/controllers/provisioningv2/cluster"
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	mgmtv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	provv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
@@ -68,7 +69,13 @@ type proxy struct {
 }
 
 func (p *proxy) isAllowed(host string) bool {
-	for _, valid := range p.validHostsSupplier() {
+	return IsAllowed(p.validHostsSupplier(), host)
+}
+
+// IsAllowed returns whether the host matches one of the valid hosts, which may start with a "*" wildcard or contain "%"
+// placeholders for a single domain label.
+func IsAllowed(validHosts []string, host string) bool {
+	for _, valid := range validHosts {
 		if valid == host {
 			return true
 		}
@@ -229,7 +236,11 @@ func (p *proxy) secretGetter(req *http.Request, cAuth string) SecretGetter {
 				return nil, unauthorizedErr
 			}
 		}
-		return p.credentials.Controller().Lister().Get(namespace, name)
+		secret, err := p.credentials.Controller().Lister().Get(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return encryptedstore.DecryptSecret(secret)
 	}
 }
 
@@ -112,6 +112,7 @@
 	"mirrored-cloud-provider-vsphere":                         "https://github.com/kubernetes/cloud-provider-vsphere",
 	"mirrored-cluster-api-controller":                         "https://github.com/kubernetes-sigs/cluster-api",
 	"mirrored-coredns-coredns":                                "https://github.com/coredns/coredns",
+	"mirrored-coreos-etcd":                                    "https://github.com/etcd-io/etcd",
 	"mirrored-coreos-prometheus-config-reloader":              "https://github.com/prometheus-operator/prometheus-operator/pkgs/container/prometheus-config-reloader",
 	"mirrored-coreos-prometheus-operator":                     "https://github.com/prometheus-operator/prometheus-operator",
 	"mirrored-curlimages-curl":                                "https://github.com/curl/curl-docker",
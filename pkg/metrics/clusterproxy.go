@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var clusterProxyRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "cluster_proxy",
+		Name:      "rejected_requests_total",
+		Help:      "Number of requests to downstream clusters rejected by the cluster proxy rate limits",
+	},
+	[]string{"cluster", "class", "reason"},
+)
+
+// IncClusterProxyRejected counts a request to the given cluster rejected by the cluster proxy rate limits.
+// The class is the priority class of the request and the reason the limit that rejected it.
+func IncClusterProxyRejected(clusterID, class, reason string) {
+	if prometheusMetrics {
+		clusterProxyRejected.With(
+			prometheus.Labels{
+				"cluster": clusterID,
+				"class":   class,
+				"reason":  reason,
+			}).Inc()
+	}
+}
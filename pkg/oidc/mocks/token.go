@@ -10,7 +10,7 @@
 package mocks
 
 import (
-	rsa "crypto/rsa"
+	crypto "crypto"
 	reflect "reflect"
 
 	session "github.com/rancher/rancher/pkg/oidc/provider/session"
@@ -95,10 +95,10 @@ func (m *MocksigningKeyGetter) EXPECT() *MocksigningKeyGetterMockRecorder {
 }
 
 // GetPublicKey mocks base method.
-func (m *MocksigningKeyGetter) GetPublicKey(kid string) (*rsa.PublicKey, error) {
+func (m *MocksigningKeyGetter) GetPublicKey(kid string) (crypto.PublicKey, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPublicKey", kid)
-	ret0, _ := ret[0].(*rsa.PublicKey)
+	ret0, _ := ret[0].(crypto.PublicKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -110,10 +110,10 @@ func (mr *MocksigningKeyGetterMockRecorder) GetPublicKey(kid any) *gomock.Call {
 }
 
 // GetSigningKey mocks base method.
-func (m *MocksigningKeyGetter) GetSigningKey() (*rsa.PrivateKey, string, error) {
+func (m *MocksigningKeyGetter) GetSigningKey() (crypto.Signer, string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetSigningKey")
-	ret0, _ := ret[0].(*rsa.PrivateKey)
+	ret0, _ := ret[0].(crypto.Signer)
 	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
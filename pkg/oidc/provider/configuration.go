@@ -4,6 +4,7 @@
 	"encoding/json"
 	"net/http"
 
+	"github.com/rancher/rancher/pkg/oidc/signingkey"
 	"github.com/rancher/rancher/pkg/settings"
 )
 
@@ -17,19 +18,23 @@ type OpenIDConfiguration struct {
 	TokenEndpoint string `json:"token_endpoint"`
 	// UserInfoEndpoint is the userinfo endpoint
 	UserInfoEndpoint string `json:"userinfo_endpoint"`
+	// IntrospectionEndpoint is the token introspection endpoint
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+	// RevocationEndpoint is the token revocation endpoint
+	RevocationEndpoint string `json:"revocation_endpoint"`
 	// JWKSURI is the jwksuri endpoint
 	JWKSURI string `json:"jwks_uri"`
 	// ResponseTypesSupported response types supported, only 'code' is supported
 	ResponseTypesSupported []string `json:"response_types_supported"`
 	// SubjectTypesSupported subject types supported, only 'public' is supported
 	SubjectTypesSupported []string `json:"subject_types_supported"`
-	// IDTokenSigningAlgsValuesSupported only RS256 is supported
+	// IDTokenSigningAlgsValuesSupported RS256 and ES256 are supported
 	IDTokenSigningAlgsValuesSupported []string `json:"id_token_signing_alg_values_supported"`
 	// CodeChallengeMethodsSupported only S256 is supported
 	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
 	// ScopesSupported can be openid, profile, offline_token
 	ScopesSupported []string `json:"scopes_supported"`
-	// GrantTypesSupported only code is supported
+	// GrantTypesSupported authorization_code, refresh_token, client_credentials and token exchange are supported
 	GrantTypesSupported []string `json:"grant_types_supported"`
 }
 
@@ -40,12 +45,14 @@ func openIDConfigurationEndpoint(w http.ResponseWriter, r *http.Request) {
 		TokenEndpoint:                     oidcProviderHost() + "/token",
 		JWKSURI:                           oidcProviderHost() + "/.well-known/jwks.json",
 		UserInfoEndpoint:                  oidcProviderHost() + "/userinfo",
+		IntrospectionEndpoint:             oidcProviderHost() + "/introspect",
+		RevocationEndpoint:                oidcProviderHost() + "/revoke",
 		ResponseTypesSupported:            []string{"code"},
 		SubjectTypesSupported:             []string{"public"},
-		IDTokenSigningAlgsValuesSupported: []string{"RS256"},
+		IDTokenSigningAlgsValuesSupported: signingkey.Algorithms,
 		CodeChallengeMethodsSupported:     []string{"S256"},
 		ScopesSupported:                   []string{"openid", "profile", "offline_access"},
-		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials", tokenExchangeGrantType},
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -16,6 +16,16 @@
 	UnsupportedResponseType = "unsupported_response_type"
 	// InvalidScope the requested scope is invalid, unknown, or malformed
 	InvalidScope = "invalid_scope"
+	// InvalidClient client authentication failed.
+	InvalidClient = "invalid_client"
+	// UnauthorizedClient the authenticated client is not authorized to use this authorization grant type.
+	UnauthorizedClient = "unauthorized_client"
+	// UnsupportedGrantType the authorization grant type is not supported by the authorization server.
+	UnsupportedGrantType = "unsupported_grant_type"
+	// InvalidTarget the requested audience is invalid, unknown, or malformed.
+	InvalidTarget = "invalid_target"
+	// UnsupportedTokenType the authorization server does not support the revocation of the presented token type.
+	UnsupportedTokenType = "unsupported_token_type"
 	// ServerError the authorization server encountered an unexpected condition that prevented it from fulfilling the request.
 	ServerError = "server_error"
 )
@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	oidcerror "github.com/rancher/rancher/pkg/oidc/provider/error"
+	"github.com/sirupsen/logrus"
+)
+
+// IntrospectionResponse represents the response returned by the introspection endpoint as described in RFC 7662.
+type IntrospectionResponse struct {
+	// Active indicates whether the token is currently active. All other fields are omitted for inactive tokens.
+	Active bool `json:"active"`
+	// Scope is a space-separated list of the scopes of the token.
+	Scope string `json:"scope,omitempty"`
+	// ClientID is the client ID of the OIDC client the token was issued to.
+	ClientID string `json:"client_id,omitempty"`
+	// Username is the username of the user the token was issued for.
+	Username string `json:"username,omitempty"`
+	// TokenType is the type of the token.
+	TokenType string `json:"token_type,omitempty"`
+	// Exp is when the token expires, in seconds since the epoch.
+	Exp int64 `json:"exp,omitempty"`
+	// Iat is when the token was issued, in seconds since the epoch.
+	Iat int64 `json:"iat,omitempty"`
+	// Sub is the ID of the user the token was issued for.
+	Sub string `json:"sub,omitempty"`
+	// Aud is the audience of the token.
+	Aud []string `json:"aud,omitempty"`
+	// Iss is the issuer of the token.
+	Iss string `json:"iss,omitempty"`
+}
+
+// introspectEndpoint handles the introspection endpoint of the OIDC provider. Any OIDC client can introspect tokens, so resource servers
+// must be registered as OIDC clients.
+func (h *tokenHandler) introspectEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oidcerror.WriteError(oidcerror.InvalidRequest, fmt.Sprintf("error parsing parameters from request %v", err), http.StatusBadRequest, w)
+		return
+	}
+	if _, oidcErr := h.authenticateClient(clientCredentialsFromRequest(r)); oidcErr != nil {
+		logrus.Debug("[OIDC provider] error authenticating client: " + oidcErr.ToString())
+		oidcerror.WriteError(oidcerror.InvalidClient, "client authentication failed", http.StatusUnauthorized, w)
+		return
+	}
+	token := r.Form.Get("token")
+	if token == "" {
+		oidcerror.WriteError(oidcerror.InvalidRequest, "missing token", http.StatusBadRequest, w)
+		return
+	}
+
+	response, err := h.introspect(token)
+	if err != nil {
+		logrus.Errorf("[OIDC provider] failed to introspect token: %v", err)
+		oidcerror.WriteError(oidcerror.ServerError, "failed to introspect token", http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		oidcerror.WriteError(oidcerror.ServerError, "failed to encode introspection response", http.StatusInternalServerError, w)
+	}
+}
+
+// introspect returns whether the token is active. A token is active if it was signed by the OIDC provider, has not expired, its user is
+// enabled, and the Rancher token it was issued for, if any, is still present, enabled and not expired.
+func (h *tokenHandler) introspect(tokenString string) (IntrospectionResponse, error) {
+	claims := jwt.MapClaims{}
+	if _, err := parseToken(tokenString, &claims, h.jwks); err != nil {
+		logrus.Debugf("[OIDC provider] introspected token is not valid: %v", err)
+		return IntrospectionResponse{}, nil
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return IntrospectionResponse{}, nil
+	}
+	if rancherTokenHash, ok := claims["rancher_token_hash"].(string); ok {
+		rancherToken, err := h.getRancherTokenByHash(sub, rancherTokenHash)
+		if err != nil {
+			return IntrospectionResponse{}, fmt.Errorf("failed to get Rancher token: %w", err)
+		}
+		if rancherToken == nil || verifyRancherToken(rancherToken) != nil {
+			return IntrospectionResponse{}, nil
+		}
+	}
+	user, oidcErr := h.getEnabledUser(sub)
+	if oidcErr != nil {
+		if oidcErr.Error == oidcerror.AccessDenied {
+			return IntrospectionResponse{}, nil
+		}
+		return IntrospectionResponse{}, fmt.Errorf("%s", oidcErr.ErrorDescription)
+	}
+
+	return newIntrospectionResponse(claims, user), nil
+}
+
+func newIntrospectionResponse(claims jwt.MapClaims, user *v3.User) IntrospectionResponse {
+	response := IntrospectionResponse{
+		Active:   true,
+		Username: user.Username,
+	}
+	response.Sub, _ = claims.GetSubject()
+	response.Iss, _ = claims.GetIssuer()
+	if aud, err := claims.GetAudience(); err == nil && len(aud) > 0 {
+		response.Aud = aud
+		response.ClientID = aud[0]
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		response.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		response.Iat = iat.Unix()
+	}
+	if scopes, ok := claims["scope"].([]interface{}); ok {
+		var scope []string
+		for _, s := range scopes {
+			if str, ok := s.(string); ok {
+				scope = append(scope, str)
+			}
+		}
+		response.Scope = strings.Join(scope, " ")
+	}
+	// refresh tokens are not issued with an issuer
+	if response.Iss != "" {
+		response.TokenType = bearerTokenType
+	}
+
+	return response
+}
@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/rancher/rancher/pkg/oidc/mocks"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	fakeIntrospectClientID     = "client-id"
+	fakeIntrospectClientSecret = "client-secret"
+	fakeIntrospectSigningKey   = "key"
+	fakeIntrospectTokenName    = "token-name"
+	fakeIntrospectUserID       = "user-id"
+	fakeIntrospectUsername     = "username"
+)
+
+type introspectMockParams struct {
+	tokenCache       *fake.MockNonNamespacedCacheInterface[*v3.Token]
+	tokenClient      *fake.MockNonNamespacedClientInterface[*v3.Token, *v3.TokenList]
+	secretCache      *fake.MockCacheInterface[*v1.Secret]
+	oidcClientCache  *fake.MockNonNamespacedCacheInterface[*v3.OIDCClient]
+	oidcClient       *fake.MockNonNamespacedClientInterface[*v3.OIDCClient, *v3.OIDCClientList]
+	userLister       *fake.MockNonNamespacedCacheInterface[*v3.User]
+	signingKeyGetter *mocks.MocksigningKeyGetter
+}
+
+func newIntrospectMockParams(ctrl *gomock.Controller) introspectMockParams {
+	return introspectMockParams{
+		tokenCache:       fake.NewMockNonNamespacedCacheInterface[*v3.Token](ctrl),
+		tokenClient:      fake.NewMockNonNamespacedClientInterface[*v3.Token, *v3.TokenList](ctrl),
+		secretCache:      fake.NewMockCacheInterface[*v1.Secret](ctrl),
+		oidcClientCache:  fake.NewMockNonNamespacedCacheInterface[*v3.OIDCClient](ctrl),
+		oidcClient:       fake.NewMockNonNamespacedClientInterface[*v3.OIDCClient, *v3.OIDCClientList](ctrl),
+		userLister:       fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl),
+		signingKeyGetter: mocks.NewMocksigningKeyGetter(ctrl),
+	}
+}
+
+func (m introspectMockParams) handler() *tokenHandler {
+	return newTokenHandler(m.tokenCache, m.userLister, nil, nil, m.signingKeyGetter, m.oidcClientCache, m.oidcClient, m.secretCache, m.tokenClient)
+}
+
+// expectClientAuthentication sets up the mocks for a client authenticating with fakeIntrospectClientSecret.
+func (m introspectMockParams) expectClientAuthentication() {
+	oidcClient := &v3.OIDCClient{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "client-name",
+		},
+		Status: v3.OIDCClientStatus{
+			ClientID: fakeIntrospectClientID,
+		},
+	}
+	m.oidcClientCache.EXPECT().GetByIndex(oidcClientByIDIndex, fakeIntrospectClientID).Return([]*v3.OIDCClient{oidcClient}, nil)
+	m.secretCache.EXPECT().Get(secretsNamespace, fakeIntrospectClientID).Return(&v1.Secret{
+		Data: map[string][]byte{
+			"client-secret-1": []byte(fakeIntrospectClientSecret),
+		},
+	}, nil)
+	m.oidcClient.EXPECT().Patch("client-name", types.JSONPatchType, gomock.Any()).Return(oidcClient, nil)
+}
+
+func newClientRequest(token string, clientSecret string) *http.Request {
+	data := url.Values{}
+	data.Set("token", token)
+	req, _ := http.NewRequest(http.MethodPost, "https://rancher.com", bytes.NewBufferString(data.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeIntrospectClientID+":"+clientSecret))))
+
+	return req
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = fakeIntrospectSigningKey
+	tokenString, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	return tokenString
+}
+
+func TestIntrospectEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	accessClaims := jwt.MapClaims{
+		"aud":                []string{fakeIntrospectClientID},
+		"exp":                now.Add(time.Hour).Unix(),
+		"iss":                settings.ServerURL.Get() + "/oidc",
+		"iat":                now.Unix(),
+		"sub":                fakeIntrospectUserID,
+		"scope":              []string{"openid", "profile"},
+		"rancher_token_hash": hashRancherToken(fakeIntrospectTokenName),
+	}
+	expiredClaims := jwt.MapClaims{}
+	for k, v := range accessClaims {
+		expiredClaims[k] = v
+	}
+	expiredClaims["exp"] = now.Add(-time.Hour).Unix()
+
+	fakeToken := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fakeIntrospectTokenName,
+		},
+		UserID:  fakeIntrospectUserID,
+		Enabled: ptr.To(true),
+	}
+	fakeDisabledToken := fakeToken.DeepCopy()
+	fakeDisabledToken.Enabled = ptr.To(false)
+	fakeUser := &v3.User{
+		Username: fakeIntrospectUsername,
+		Enabled:  ptr.To(true),
+	}
+	userSelector := labels.SelectorFromSet(map[string]string{tokens.UserIDLabel: fakeIntrospectUserID})
+
+	tests := map[string]struct {
+		req        func() *http.Request
+		mockSetup  func(introspectMockParams)
+		wantStatus int
+		wantBody   string
+	}{
+		"active access token": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, accessClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return([]*v3.Token{fakeToken}, nil)
+				m.userLister.EXPECT().Get(fakeIntrospectUserID).Return(fakeUser, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody: fmt.Sprintf(`{"active":true,"scope":"openid profile","client_id":%q,"username":%q,"token_type":"Bearer","exp":%d,"iat":%d,"sub":%q,"aud":[%q],"iss":%q}`,
+				fakeIntrospectClientID, fakeIntrospectUsername, now.Add(time.Hour).Unix(), now.Unix(), fakeIntrospectUserID, fakeIntrospectClientID, settings.ServerURL.Get()+"/oidc"),
+		},
+		"inactive when the Rancher token is disabled": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, accessClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return([]*v3.Token{fakeDisabledToken}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"active":false}`,
+		},
+		"inactive when the Rancher token is no longer present": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, accessClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return(nil, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"active":false}`,
+		},
+		"inactive when the token has expired": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, expiredClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"active":false}`,
+		},
+		"inactive when the token is not signed by the provider": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, otherKey, accessClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"active":false}`,
+		},
+		"fails for an invalid client_secret": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, accessClaims), "invalid")
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex(oidcClientByIDIndex, fakeIntrospectClientID).Return([]*v3.OIDCClient{{}}, nil)
+				m.secretCache.EXPECT().Get(secretsNamespace, fakeIntrospectClientID).Return(&v1.Secret{
+					Data: map[string][]byte{
+						"client-secret-1": []byte(fakeIntrospectClientSecret),
+					},
+				}, nil)
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":"invalid_client","error_description":"client authentication failed"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := newIntrospectMockParams(ctrl)
+			test.mockSetup(m)
+			rec := httptest.NewRecorder()
+
+			m.handler().introspectEndpoint(rec, test.req())
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.JSONEq(t, test.wantBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestNewIntrospectionResponseRefreshToken(t *testing.T) {
+	// refresh tokens are issued without an issuer
+	claims := map[string]interface{}{
+		"aud":   []interface{}{"client-id"},
+		"sub":   "user-id",
+		"scope": []interface{}{"openid", "offline_access"},
+		"exp":   float64(100),
+		"iat":   float64(50),
+	}
+
+	response := newIntrospectionResponse(claims, &v3.User{Username: "username"})
+
+	assert.Equal(t, IntrospectionResponse{
+		Active:   true,
+		Scope:    "openid offline_access",
+		ClientID: "client-id",
+		Username: "username",
+		Exp:      100,
+		Iat:      50,
+		Sub:      "user-id",
+		Aud:      []string{"client-id"},
+	}, response)
+}
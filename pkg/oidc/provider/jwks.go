@@ -1,18 +1,20 @@
 package provider
 
 import (
-	"crypto/rand"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"math/big"
 	"net/http"
 	"strings"
+	"time"
 
 	oidcerror "github.com/rancher/rancher/pkg/oidc/provider/error"
+	"github.com/rancher/rancher/pkg/oidc/signingkey"
+	"github.com/rancher/rancher/pkg/settings"
 	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
@@ -21,18 +23,20 @@
 )
 
 const (
-	keyBits            = 3072
-	keySecretNamespace = "cattle-system"
-	keySecretName      = "oidc-signing-key"
+	keySecretNamespace = signingkey.SecretNamespace
+	keySecretName      = signingkey.SecretName
 )
 
 // JWK represents a JSON Web Key
 type JWK struct {
-	Kty string `json:"kty"` // Key Type (e.g., RSA)
-	Use string `json:"use"` // Key Usage (e.g., sig)
-	Kid string `json:"kid"` // Key ID
-	N   string `json:"n"`   // Modulus
-	E   string `json:"e"`   // Exponent
+	Kty string `json:"kty"`           // Key Type (e.g., RSA or EC)
+	Use string `json:"use"`           // Key Usage (e.g., sig)
+	Kid string `json:"kid"`           // Key ID
+	N   string `json:"n,omitempty"`   // Modulus
+	E   string `json:"e,omitempty"`   // Exponent
+	Crv string `json:"crv,omitempty"` // Curve
+	X   string `json:"x,omitempty"`   // X coordinate
+	Y   string `json:"y,omitempty"`   // Y coordinate
 }
 
 // JWKS represents a JSON Web Key Set
@@ -54,32 +58,18 @@ func newJWKSHandler(secretCache corecontrollers.SecretCache, secretClient coreco
 
 	if errors.IsNotFound(err) {
 		logrus.Infof("[OIDC provider] creating a new signing key")
-		// generate a default RSA private key
-		privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+		privateKeyPEM, publicKeyPEM, err := signingkey.Generate(settings.OIDCSigningKeyAlgorithm.Get())
 		if err != nil {
 			return nil, err
 		}
 
-		// Encode private key to PEM
-		privateKeyPEM := pem.EncodeToMemory(&pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-		})
-
-		// Encode public key to PEM
-		publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal public key: %w", err)
-		}
-		publicKeyPEM := pem.EncodeToMemory(&pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: publicKeyDER,
-		})
-
 		secret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      keySecretName,
 				Namespace: keySecretNamespace,
+				Annotations: map[string]string{
+					signingkey.CreatedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+				},
 			},
 			Data: map[string][]byte{
 				"key.pem": privateKeyPEM,
@@ -118,6 +108,8 @@ func newJWKSHandler(secretCache corecontrollers.SecretCache, secretClient coreco
 //
 // It will sign jwt tokens with key2.pem, but jwks will return key1.pub and key2.pub in order to avoid disruptions when doing a key rotation from key1 to key2.
 // Only one private key (.pem) can be in this secret. Note that the private and public keys must have the same name (kid) with different suffix (.pem and .pub).
+// RSA and ECDSA P-256 keys are supported. Keys are rotated automatically when the oidc-signing-key-rotation-interval setting is set, in which case
+// the public key of the previous key is published until the oidc-signing-key-overlap window has passed.
 func (h *jwksHandler) jwksEndpoint(w http.ResponseWriter, r *http.Request) {
 	s, err := h.secretCache.Get(keySecretNamespace, keySecretName)
 	if err != nil {
@@ -131,24 +123,18 @@ func (h *jwksHandler) jwksEndpoint(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		pubKey, err := getPublicKeyFromSecretData(value)
+		pubKey, err := signingkey.ParsePublicKey(value)
 		if err != nil {
 			logrus.Errorf("[OIDC provider] failed to extract public key from secret data %v", err)
 			oidcerror.WriteError(oidcerror.ServerError, "failed to extract public key from secret data", http.StatusInternalServerError, w)
 			return
 		}
-		if pubKey.N.BitLen() < 2048 {
+		if signingkey.IsTooWeak(pubKey) {
 			logrus.Warnf("[OIDC provider] ignoring key because the size is less than 2048 bits")
 			continue
 		}
 
-		keys = append(keys, JWK{
-			Kty: "RSA",
-			Use: "sig",
-			Kid: strings.TrimSuffix(name, ".pub"),
-			N:   base64.RawURLEncoding.EncodeToString(pubKey.N.Bytes()),
-			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pubKey.E)).Bytes()),
-		})
+		keys = append(keys, newJWK(strings.TrimSuffix(name, signingkey.PublicKeySuffix), pubKey))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -158,57 +144,55 @@ func (h *jwksHandler) jwksEndpoint(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSigningKey returns the key used for signing jwt tokens, and it's key id (kid)
-func (h *jwksHandler) GetSigningKey() (*rsa.PrivateKey, string, error) {
+func (h *jwksHandler) GetSigningKey() (crypto.Signer, string, error) {
 	s, err := h.secretCache.Get(keySecretNamespace, keySecretName)
 	if err != nil {
 		return nil, "", err
 	}
 	for name, value := range s.Data {
-		if strings.HasSuffix(name, ".pem") {
-			return getPrivateKeyFromSecretData(name, value)
+		if strings.HasSuffix(name, signingkey.PrivateKeySuffix) {
+			key, err := signingkey.ParsePrivateKey(value)
+			if err != nil {
+				return nil, "", err
+			}
+			return key, strings.TrimSuffix(name, signingkey.PrivateKeySuffix), nil
 		}
 	}
 	return nil, "", fmt.Errorf("signing key not found")
 }
 
 // GetPublicKey returns the public key specified by the kid
-func (h *jwksHandler) GetPublicKey(kid string) (*rsa.PublicKey, error) {
+func (h *jwksHandler) GetPublicKey(kid string) (crypto.PublicKey, error) {
 	s, err := h.secretCache.Get(keySecretNamespace, keySecretName)
 	if err != nil {
 		return nil, err
 	}
 	for name, value := range s.Data {
-		if name == kid+".pub" {
-			return getPublicKeyFromSecretData(value)
+		if name == kid+signingkey.PublicKeySuffix {
+			return signingkey.ParsePublicKey(value)
 		}
 	}
 	return nil, fmt.Errorf("public key not found")
 }
 
-func getPrivateKeyFromSecretData(name string, privateKeyPEM []byte) (*rsa.PrivateKey, string, error) {
-	block, _ := pem.Decode(privateKeyPEM)
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
-		return nil, "", fmt.Errorf("failed to decode PEM block")
+// newJWK returns the JSON Web Key for an RSA or ECDSA public key.
+func newJWK(kid string, publicKey crypto.PublicKey) JWK {
+	jwk := JWK{
+		Use: "sig",
+		Kid: kid,
 	}
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse RSA private key: %w", err)
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
 	}
-	return privateKey, strings.TrimSuffix(name, ".pem"), nil
-}
 
-func getPublicKeyFromSecretData(publicKeyPEM []byte) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode(publicKeyPEM)
-	if block == nil || block.Type != "PUBLIC KEY" {
-		return nil, fmt.Errorf("failed to decode PEM block containing public key")
-	}
-	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
-	}
-	publicKey, ok := pubInterface.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("not an RSA public key")
-	}
-	return publicKey, nil
+	return jwk
 }
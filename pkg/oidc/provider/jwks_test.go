@@ -1,7 +1,7 @@
 package provider
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -161,7 +161,7 @@ func TestGetSigningKey(t *testing.T) {
 	tests := map[string]struct {
 		secretCache func() corecontrollers.SecretCache
 		expectedKid string
-		expectedKey *rsa.PrivateKey
+		expectedKey crypto.Signer
 		expectedErr string
 	}{
 		"get signing key": {
@@ -229,7 +229,7 @@ func TestGetPublicKey(t *testing.T) {
 	tests := map[string]struct {
 		secretCache func() corecontrollers.SecretCache
 		kid         string
-		expectedKey *rsa.PublicKey
+		expectedKey crypto.PublicKey
 		expectedErr string
 	}{
 		"get signing key": {
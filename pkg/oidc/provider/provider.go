@@ -122,4 +122,6 @@ func (p *Provider) RegisterOIDCProviderHandles(mux *mux.Router) {
 	mux.HandleFunc("/oidc/authorize", p.middleware(p.authHandler.authEndpoint))
 	mux.HandleFunc("/oidc/token", p.middleware(p.tokenHandler.tokenEndpoint))
 	mux.HandleFunc("/oidc/userinfo", p.middleware(p.userInfoHandler.userInfoEndpoint))
+	mux.HandleFunc("/oidc/introspect", p.middleware(p.tokenHandler.introspectEndpoint)).Methods(http.MethodPost)
+	mux.HandleFunc("/oidc/revoke", p.middleware(p.tokenHandler.revokeEndpoint)).Methods(http.MethodPost)
 }
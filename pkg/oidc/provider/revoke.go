@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+	oidcerror "github.com/rancher/rancher/pkg/oidc/provider/error"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// revokeEndpoint handles the revocation endpoint of the OIDC provider as described in RFC 7009. Revoking an access_token or refresh_token
+// disables the Rancher token it was issued for, which invalidates every token issued for that Rancher token.
+func (h *tokenHandler) revokeEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oidcerror.WriteError(oidcerror.InvalidRequest, fmt.Sprintf("error parsing parameters from request %v", err), http.StatusBadRequest, w)
+		return
+	}
+	oidcClient, oidcErr := h.authenticateClient(clientCredentialsFromRequest(r))
+	if oidcErr != nil {
+		logrus.Debug("[OIDC provider] error authenticating client: " + oidcErr.ToString())
+		oidcerror.WriteError(oidcerror.InvalidClient, "client authentication failed", http.StatusUnauthorized, w)
+		return
+	}
+	token := r.Form.Get("token")
+	if token == "" {
+		oidcerror.WriteError(oidcerror.InvalidRequest, "missing token", http.StatusBadRequest, w)
+		return
+	}
+
+	claims := jwt.MapClaims{}
+	// expired tokens can still be revoked to disable the Rancher token they were issued for
+	_, err := parseToken(token, &claims, h.jwks)
+	if err != nil && !errors.Is(err, jwt.ErrTokenExpired) {
+		// invalid tokens don't cause an error response
+		logrus.Debugf("[OIDC provider] revoked token is not valid: %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil || !slices.Contains(aud, oidcClient.Status.ClientID) {
+		oidcerror.WriteError(oidcerror.UnauthorizedClient, "token was not issued to this client", http.StatusBadRequest, w)
+		return
+	}
+	rancherTokenHash, ok := claims["rancher_token_hash"].(string)
+	if !ok {
+		oidcerror.WriteError(oidcerror.UnsupportedTokenType, "token is not issued for a Rancher token and can't be revoked", http.StatusBadRequest, w)
+		return
+	}
+	sub, _ := claims.GetSubject()
+	rancherToken, err := h.getRancherTokenByHash(sub, rancherTokenHash)
+	if err != nil {
+		logrus.Errorf("[OIDC provider] failed to get Rancher token: %v", err)
+		oidcerror.WriteError(oidcerror.ServerError, "failed to get Rancher token", http.StatusInternalServerError, w)
+		return
+	}
+	if rancherToken == nil || !rancherToken.GetIsEnabled() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.disableRancherToken(rancherToken.Name); err != nil {
+		logrus.Errorf("[OIDC provider] failed to disable Rancher token %s: %v", rancherToken.Name, err)
+		oidcerror.WriteError(oidcerror.ServerError, "failed to revoke token", http.StatusInternalServerError, w)
+		return
+	}
+	logrus.Infof("[OIDC provider] Rancher token %s disabled by OIDC client %s", rancherToken.Name, oidcClient.Name)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *tokenHandler) disableRancherToken(name string) error {
+	patch, err := json.Marshal([]jsonPatch{{
+		Op:    "add",
+		Path:  "/enabled",
+		Value: false,
+	}})
+	if err != nil {
+		return err
+	}
+	_, err = h.tokenClient.Patch(name, types.JSONPatchType, patch)
+
+	return err
+}
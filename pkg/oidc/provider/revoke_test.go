@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func TestRevokeEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	refreshClaims := jwt.MapClaims{
+		"aud":                []string{fakeIntrospectClientID},
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"sub":                fakeIntrospectUserID,
+		"scope":              []string{"openid", "offline_access"},
+		"rancher_token_hash": hashRancherToken(fakeIntrospectTokenName),
+	}
+	expiredClaims := jwt.MapClaims{}
+	for k, v := range refreshClaims {
+		expiredClaims[k] = v
+	}
+	expiredClaims["exp"] = now.Add(-time.Hour).Unix()
+	otherClientClaims := jwt.MapClaims{}
+	for k, v := range refreshClaims {
+		otherClientClaims[k] = v
+	}
+	otherClientClaims["aud"] = []string{"other-client-id"}
+	serviceClaims := jwt.MapClaims{
+		"aud":   []string{fakeIntrospectClientID},
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"sub":   fakeIntrospectUserID,
+		"scope": []string{"openid"},
+	}
+
+	fakeToken := &v3.Token{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fakeIntrospectTokenName,
+		},
+		UserID:  fakeIntrospectUserID,
+		Enabled: ptr.To(true),
+	}
+	userSelector := labels.SelectorFromSet(map[string]string{tokens.UserIDLabel: fakeIntrospectUserID})
+	disablePatch := []byte(`[{"op":"add","path":"/enabled","value":false}]`)
+
+	tests := map[string]struct {
+		req        func() *http.Request
+		mockSetup  func(introspectMockParams)
+		wantStatus int
+		wantBody   string
+	}{
+		"revoking a refresh token disables the Rancher token": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, refreshClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return([]*v3.Token{fakeToken}, nil)
+				m.tokenClient.EXPECT().Patch(fakeIntrospectTokenName, types.JSONPatchType, disablePatch).Return(fakeToken, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		"revoking an expired token disables the Rancher token": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, expiredClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return([]*v3.Token{fakeToken}, nil)
+				m.tokenClient.EXPECT().Patch(fakeIntrospectTokenName, types.JSONPatchType, disablePatch).Return(fakeToken, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		"revoking a token whose Rancher token is no longer present succeeds": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, refreshClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+				m.tokenCache.EXPECT().List(userSelector).Return(nil, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		"revoking an invalid token succeeds": {
+			req: func() *http.Request {
+				return newClientRequest("invalid", fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+			},
+			wantStatus: http.StatusOK,
+		},
+		"fails for a token issued to another client": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, otherClientClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"unauthorized_client","error_description":"token was not issued to this client"}`,
+		},
+		"fails for a token not issued for a Rancher token": {
+			req: func() *http.Request {
+				return newClientRequest(signTestToken(t, privateKey, serviceClaims), fakeIntrospectClientSecret)
+			},
+			mockSetup: func(m introspectMockParams) {
+				m.expectClientAuthentication()
+				m.signingKeyGetter.EXPECT().GetPublicKey(fakeIntrospectSigningKey).Return(&privateKey.PublicKey, nil)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"unsupported_token_type","error_description":"token is not issued for a Rancher token and can't be revoked"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := newIntrospectMockParams(ctrl)
+			test.mockSetup(m)
+			rec := httptest.NewRecorder()
+
+			m.handler().revokeEndpoint(rec, test.req())
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantBody != "" {
+				assert.JSONEq(t, test.wantBody, strings.TrimSpace(rec.Body.String()))
+			} else {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
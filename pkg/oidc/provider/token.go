@@ -1,7 +1,7 @@
 package provider
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -18,6 +18,7 @@
 	wrangmgmtv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	oidcerror "github.com/rancher/rancher/pkg/oidc/provider/error"
 	"github.com/rancher/rancher/pkg/oidc/provider/session"
+	"github.com/rancher/rancher/pkg/oidc/signingkey"
 	"github.com/rancher/rancher/pkg/settings"
 	corev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
@@ -29,7 +30,15 @@
 	"k8s.io/client-go/tools/cache"
 )
 
-const bearerTokenType = "Bearer"
+const (
+	bearerTokenType = "Bearer"
+	// notApplicableTokenType is the token_type of exchanged tokens that can't be used as a bearer token.
+	notApplicableTokenType = "N_A"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	idTokenType            = "urn:ietf:params:oauth:token-type:id_token"
+)
 
 type sessionGetterRemover interface {
 	Get(code string) (*session.Session, error)
@@ -37,8 +46,8 @@ type sessionGetterRemover interface {
 }
 
 type signingKeyGetter interface {
-	GetSigningKey() (*rsa.PrivateKey, string, error)
-	GetPublicKey(kid string) (*rsa.PublicKey, error)
+	GetSigningKey() (crypto.Signer, string, error)
+	GetPublicKey(kid string) (crypto.PublicKey, error)
 }
 
 type jsonPatch struct {
@@ -64,15 +73,17 @@ type tokenHandler struct {
 // TokenResponse represents a successful response returned by the token endpoint
 type TokenResponse struct {
 	// IDToken is the oidc token generated.
-	IDToken string `json:"id_token"`
+	IDToken string `json:"id_token,omitempty"`
 	// AccessToken is the access token generated.
 	AccessToken string `json:"access_token"`
 	// AccessToken is the refresh token generated.
 	RefreshToken string `json:"refresh_token,omitempty"`
 	// ExpiresIn indicates when id_token and access_token expire.
 	ExpiresIn time.Duration `json:"expires_in"`
-	// TokenType is the OAuth 2.0 Token Type value. The value must be Bearer.
+	// TokenType is the OAuth 2.0 Token Type value. The value must be Bearer, or N_A for an id_token issued by a token exchange.
 	TokenType string `json:"token_type"`
+	// IssuedTokenType is the type of the token issued by a token exchange.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 // RefreshTokenClaims represent claims in the refresh_token
@@ -117,35 +128,31 @@ func (h *tokenHandler) tokenEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var tokenResponse TokenResponse
+	var oidcErr *oidcerror.Error
 	switch r.Form.Get("grant_type") {
 	case "authorization_code":
-		tokenResponse, oidcErr := h.createTokenFromCode(r)
-		if oidcErr != nil {
-			logrus.Debug("[OIDC provider] error creating token response: " + oidcErr.ToString())
-			oidcErr.Write(http.StatusBadRequest, w)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(tokenResponse)
-		if err != nil {
-			oidcerror.WriteError(oidcerror.ServerError, "failed to encode token response", http.StatusInternalServerError, w)
-			return
-		}
+		tokenResponse, oidcErr = h.createTokenFromCode(r)
 	case "refresh_token":
-		tokenResponse, oidcErr := h.createRefreshToken(r)
-		if oidcErr != nil {
-			logrus.Debug("[OIDC provider] error creating refresh token response: " + oidcErr.ToString())
-			oidcErr.Write(http.StatusBadRequest, w)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(tokenResponse)
-		if err != nil {
-			oidcerror.WriteError(oidcerror.ServerError, "failed to encode refresh token response", http.StatusInternalServerError, w)
-			return
-		}
+		tokenResponse, oidcErr = h.createRefreshToken(r)
+	case "client_credentials":
+		tokenResponse, oidcErr = h.createTokenFromClientCredentials(r)
+	case tokenExchangeGrantType:
+		tokenResponse, oidcErr = h.exchangeToken(r)
 	default:
-		http.Error(w, "grant_type not supported", http.StatusInternalServerError)
+		oidcerror.WriteError(oidcerror.UnsupportedGrantType, "grant_type not supported", http.StatusBadRequest, w)
+		return
+	}
+	if oidcErr != nil {
+		logrus.Debug("[OIDC provider] error creating token response: " + oidcErr.ToString())
+		oidcErr.Write(http.StatusBadRequest, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(tokenResponse)
+	if err != nil {
+		oidcerror.WriteError(oidcerror.ServerError, "failed to encode token response", http.StatusInternalServerError, w)
 		return
 	}
 }
@@ -161,36 +168,13 @@ func (h *tokenHandler) createTokenFromCode(r *http.Request) (TokenResponse, *oid
 		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, "error retrieving session :"+err.Error())
 	}
 
-	// verify clientID and secret. They can be set in the Authorization header or as a form param as specified in the OIDC spec.
-	var clientID, _ string
-	clientID, clientSecret, ok := r.BasicAuth()
-	if !ok {
-		clientID = r.FormValue("client_id")
-		clientSecret = r.FormValue("client_secret")
-	}
+	clientID, clientSecret := clientCredentialsFromRequest(r)
 	if clientID != session.ClientID {
 		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "invalid client_id")
 	}
-	oidcClient, err := h.getOIDCClientByClientID(clientID)
-	if err != nil {
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, "failed to get OIDC client")
-	}
-	secret, err := h.secretCache.Get(secretsNamespace, clientID)
-	if err != nil {
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, "failed to get client secret")
-	}
-	clientSecretFound := false
-	for key, cs := range secret.Data {
-		if clientSecret == string(cs) {
-			clientSecretFound = true
-			if err := h.updateClientSecretUsedTimeStamp(oidcClient, key); err != nil {
-				logrus.Errorf("[OIDC provider] failed to update client secret's used timestamp: %v", err)
-			}
-			break
-		}
-	}
-	if !clientSecretFound {
-		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "invalid client_secret")
+	oidcClient, oidcErr := h.authenticateClient(clientID, clientSecret)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
 	}
 
 	// PKCE verification
@@ -221,22 +205,7 @@ func (h *tokenHandler) createTokenFromCode(r *http.Request) (TokenResponse, *oid
 func (h *tokenHandler) createRefreshToken(r *http.Request) (TokenResponse, *oidcerror.Error) {
 	refreshToken := r.Form.Get("refresh_token")
 	// verify refresh_token signature
-	token, err := jwt.ParseWithClaims(refreshToken, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Ensure correct signing method
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		kid, ok := token.Header["kid"].(string)
-		if !ok {
-			return nil, fmt.Errorf("can't find kid")
-		}
-		pubKey, err := h.jwks.GetPublicKey(kid)
-		if err != nil {
-			return nil, err
-		}
-
-		return pubKey, nil
-	})
+	token, err := parseToken(refreshToken, &RefreshTokenClaims{}, h.jwks)
 	if err != nil {
 		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to parse refresh token: %v", err))
 	}
@@ -246,21 +215,10 @@ func (h *tokenHandler) createRefreshToken(r *http.Request) (TokenResponse, *oidc
 	}
 
 	// get rancher Token associated with this refresh_token
-	tokenList, err := h.tokenCache.List(labels.SelectorFromSet(map[string]string{
-		tokens.UserIDLabel: claims.Subject,
-	}))
+	rancherToken, err := h.getRancherTokenByHash(claims.Subject, claims.RancherTokenHash)
 	if err != nil {
 		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to add OIDC Client ID to Rancher token: %v", err))
 	}
-	var rancherToken *v3.Token
-	for _, token := range tokenList {
-		hash := sha256.Sum256([]byte(token.Name))
-		rancherTokenHash := hex.EncodeToString(hash[:])
-		if rancherTokenHash == claims.RancherTokenHash {
-			rancherToken = token
-			break
-		}
-	}
 	if rancherToken == nil {
 		return TokenResponse{}, oidcerror.New(oidcerror.AccessDenied, "Rancher token no longer present.")
 	}
@@ -277,34 +235,252 @@ func (h *tokenHandler) createRefreshToken(r *http.Request) (TokenResponse, *oidc
 	return h.createTokenResponse(rancherToken, oidcClient, "", claims.Scope)
 }
 
+// createTokenFromClientCredentials issues an id_token and access_token for the service principal of the authenticated client.
+// No refresh_token is issued, clients request a new token with their credentials instead.
+func (h *tokenHandler) createTokenFromClientCredentials(r *http.Request) (TokenResponse, *oidcerror.Error) {
+	oidcClient, oidcErr := h.authenticateClient(clientCredentialsFromRequest(r))
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	if oidcClient.Spec.ServicePrincipal == "" {
+		return TokenResponse{}, oidcerror.New(oidcerror.UnauthorizedClient, "client has no service principal")
+	}
+	if oidcClient.Status.ServicePrincipal != oidcClient.Spec.ServicePrincipal {
+		return TokenResponse{}, oidcerror.New(oidcerror.UnauthorizedClient, "service principal of the client is not authorized")
+	}
+	scopes, oidcErr := scopesFromRequest(r)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	user, oidcErr := h.getEnabledUser(oidcClient.Spec.ServicePrincipal)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	expiresIn := time.Duration(oidcClient.Spec.TokenExpirationSeconds) * time.Second
+	idClaims, accessClaims, oidcErr := h.newUserClaims(oidcClient.Spec.ServicePrincipal, user, "", oidcClient.Status.ClientID, expiresIn, "", scopes)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	key, kid, err := h.jwks.GetSigningKey()
+	if err != nil {
+		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to get signing key: %v", err))
+	}
+	idTokenString, oidcErr := signToken(idClaims, key, kid, "id token")
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	accessTokenString, oidcErr := signToken(accessClaims, key, kid, "access token")
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	return TokenResponse{
+		IDToken:     idTokenString,
+		AccessToken: accessTokenString,
+		TokenType:   bearerTokenType,
+		ExpiresIn:   expiresIn,
+	}, nil
+}
+
+// exchangeToken implements the RFC 8693 token exchange. A Rancher token is exchanged for an id_token or access_token restricted to the
+// requested audience, which must be the client ID of a registered OIDC client. The issued token never outlives the Rancher token.
+func (h *tokenHandler) exchangeToken(r *http.Request) (TokenResponse, *oidcerror.Error) {
+	oidcClient, oidcErr := h.authenticateClient(clientCredentialsFromRequest(r))
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	if r.Form.Get("subject_token") == "" {
+		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "missing subject_token")
+	}
+	if r.Form.Get("subject_token_type") != accessTokenType {
+		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "unsupported subject_token_type")
+	}
+	requestedTokenType := r.Form.Get("requested_token_type")
+	if requestedTokenType == "" {
+		requestedTokenType = accessTokenType
+	}
+	if requestedTokenType != accessTokenType && requestedTokenType != idTokenType {
+		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "unsupported requested_token_type")
+	}
+	scopes, oidcErr := scopesFromRequest(r)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	audience := oidcClient
+	switch audiences := r.Form["audience"]; len(audiences) {
+	case 0:
+	case 1:
+		if audiences[0] != oidcClient.Status.ClientID {
+			var err error
+			audience, err = h.getOIDCClientByClientID(audiences[0])
+			if err != nil {
+				return TokenResponse{}, oidcerror.New(oidcerror.InvalidTarget, "unknown audience")
+			}
+		}
+	default:
+		return TokenResponse{}, oidcerror.New(oidcerror.InvalidTarget, "only one audience can be requested")
+	}
+
+	tokenName, tokenKey := tokens.SplitTokenParts(r.Form.Get("subject_token"))
+	rancherToken, err := h.tokenCache.Get(tokenName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "invalid subject_token")
+		}
+		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, "failed to get Rancher token: "+err.Error())
+	}
+	if _, err := tokens.VerifyToken(rancherToken, tokenName, tokenKey); err != nil {
+		return TokenResponse{}, oidcerror.New(oidcerror.InvalidRequest, "invalid subject_token")
+	}
+	if oidcErr := verifyRancherToken(rancherToken); oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	user, oidcErr := h.getEnabledUser(rancherToken.UserID)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	expiresIn := time.Duration(audience.Spec.TokenExpirationSeconds) * time.Second
+	if rancherToken.TTLMillis != 0 {
+		expiresAt := rancherToken.CreationTimestamp.Add(time.Duration(rancherToken.TTLMillis) * time.Millisecond)
+		expiresIn = min(expiresIn, expiresAt.Sub(h.now()).Truncate(time.Second))
+	}
+	idClaims, accessClaims, oidcErr := h.newUserClaims(rancherToken.UserID, user, rancherToken.AuthProvider, audience.Status.ClientID, expiresIn, "", scopes)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	accessClaims["rancher_token_hash"] = hashRancherToken(rancherToken.Name)
+
+	key, kid, err := h.jwks.GetSigningKey()
+	if err != nil {
+		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to get signing key: %v", err))
+	}
+	resp := TokenResponse{
+		IssuedTokenType: requestedTokenType,
+		TokenType:       bearerTokenType,
+		ExpiresIn:       expiresIn,
+	}
+	if requestedTokenType == idTokenType {
+		// the id_token is returned in the access_token field, as required by RFC 8693, but it can't be used as a bearer token.
+		resp.AccessToken, oidcErr = signToken(idClaims, key, kid, "id token")
+		resp.TokenType = notApplicableTokenType
+	} else {
+		resp.AccessToken, oidcErr = signToken(accessClaims, key, kid, "access token")
+	}
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+
+	return resp, nil
+}
+
 // createTokenResponse creates an id_token, access_token and refresh_token for a valid Rancher token
 func (h *tokenHandler) createTokenResponse(rancherToken *v3.Token, oidcClient *v3.OIDCClient, nonce string, scopes []string) (TokenResponse, *oidcerror.Error) {
-	// verify Rancher token and user are valid
+	if oidcErr := verifyRancherToken(rancherToken); oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	user, oidcErr := h.getEnabledUser(rancherToken.UserID)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	expiresIn := time.Duration(oidcClient.Spec.TokenExpirationSeconds) * time.Second
+	idClaims, accessClaims, oidcErr := h.newUserClaims(rancherToken.UserID, user, rancherToken.AuthProvider, oidcClient.Status.ClientID, expiresIn, nonce, scopes)
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	// link the access_token to the Rancher token so it can be introspected and revoked
+	accessClaims["rancher_token_hash"] = hashRancherToken(rancherToken.Name)
+
+	key, kid, err := h.jwks.GetSigningKey()
+	if err != nil {
+		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to get signing key: %v", err))
+	}
+	idTokenString, oidcErr := signToken(idClaims, key, kid, "id token")
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	accessTokenString, oidcErr := signToken(accessClaims, key, kid, "access token")
+	if oidcErr != nil {
+		return TokenResponse{}, oidcErr
+	}
+	resp := TokenResponse{
+		IDToken:     idTokenString,
+		AccessToken: accessTokenString,
+		TokenType:   bearerTokenType,
+	}
+
+	// create refresh_token
+	if slices.Contains(scopes, "offline_access") {
+		refreshClaims := jwt.MapClaims{
+			"aud":                []string{oidcClient.Status.ClientID},
+			"exp":                h.now().Add(time.Duration(oidcClient.Spec.RefreshTokenExpirationSeconds) * time.Second).Unix(),
+			"iat":                h.now().Unix(),
+			"sub":                rancherToken.UserID,
+			"rancher_token_hash": hashRancherToken(rancherToken.Name),
+			"scope":              scopes,
+		}
+		if rancherToken.AuthProvider != "" {
+			refreshClaims["auth_provider"] = rancherToken.AuthProvider
+		}
+		refreshTokenString, oidcErr := signToken(refreshClaims, key, kid, "refresh token")
+		if oidcErr != nil {
+			return TokenResponse{}, oidcErr
+		}
+		resp.RefreshToken = refreshTokenString
+
+		if err := h.addOIDCClientIDToRancherToken(oidcClient.Name, rancherToken); err != nil {
+			return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to add OIDC Client ID to Rancher token: %v", err))
+		}
+	}
+
+	resp.ExpiresIn = expiresIn
+
+	return resp, nil
+}
+
+// verifyRancherToken checks that the Rancher token and its auth provider are enabled and the token has not expired.
+func verifyRancherToken(rancherToken *v3.Token) *oidcerror.Error {
 	if tokens.IsExpired(*rancherToken) {
-		return TokenResponse{}, oidcerror.New(oidcerror.AccessDenied, "Rancher token has expired")
+		return oidcerror.New(oidcerror.AccessDenied, "Rancher token has expired")
 	}
 	if rancherToken.Enabled != nil && !*rancherToken.Enabled {
-		return TokenResponse{}, oidcerror.New(oidcerror.AccessDenied, "Rancher token is disabled")
+		return oidcerror.New(oidcerror.AccessDenied, "Rancher token is disabled")
 	}
 	if rancherToken.AuthProvider != "" {
 		disabled, err := providers.IsDisabledProvider(rancherToken.AuthProvider)
 		if err != nil {
-			return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't check if auth provider is disabled: %v", err))
+			return oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't check if auth provider is disabled: %v", err))
 		}
 		if disabled {
-			return TokenResponse{}, oidcerror.New(oidcerror.AccessDenied, "auth provider is disabled")
+			return oidcerror.New(oidcerror.AccessDenied, "auth provider is disabled")
 		}
 	}
-	user, err := h.userLister.Get(rancherToken.UserID)
+
+	return nil
+}
+
+// getEnabledUser returns the user with the given ID if it is enabled.
+func (h *tokenHandler) getEnabledUser(userID string) (*v3.User, *oidcerror.Error) {
+	user, err := h.userLister.Get(userID)
 	if err != nil {
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't get user: %v", err))
+		return nil, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't get user: %v", err))
 	}
 	if user.Enabled != nil && !*user.Enabled {
-		return TokenResponse{}, oidcerror.New(oidcerror.AccessDenied, "user is disabled")
+		return nil, oidcerror.New(oidcerror.AccessDenied, "user is disabled")
 	}
-	attribs, err := h.userAttributeLister.Get(rancherToken.UserID)
+
+	return user, nil
+}
+
+// newUserClaims returns the claims of the id_token and access_token issued for a user to the given audience.
+func (h *tokenHandler) newUserClaims(userID string, user *v3.User, authProvider string, audience string, expiresIn time.Duration, nonce string, scopes []string) (jwt.MapClaims, jwt.MapClaims, *oidcerror.Error) {
+	attribs, err := h.userAttributeLister.Get(userID)
 	if err != nil && !apierrors.IsNotFound(err) {
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't get user attributes: %v", err))
+		return nil, nil, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("can't get user attributes: %v", err))
 	}
 	var groups []string
 	if attribs != nil {
@@ -316,17 +492,12 @@ func (h *tokenHandler) createTokenResponse(rancherToken *v3.Token, oidcClient *v
 		}
 	}
 
-	key, kid, err := h.jwks.GetSigningKey()
-	if err != nil {
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to get signing key: %v", err))
-	}
-	// create id_token
 	idClaims := jwt.MapClaims{
-		"aud": []string{oidcClient.Status.ClientID},
-		"exp": h.now().Add(time.Duration(oidcClient.Spec.TokenExpirationSeconds) * time.Second).Unix(),
+		"aud": []string{audience},
+		"exp": h.now().Add(expiresIn).Unix(),
 		"iss": settings.ServerURL.Get() + "/oidc",
 		"iat": h.now().Unix(),
-		"sub": rancherToken.UserID,
+		"sub": userID,
 	}
 	if slices.Contains(scopes, "profile") {
 		idClaims["name"] = user.DisplayName
@@ -337,75 +508,87 @@ func (h *tokenHandler) createTokenResponse(rancherToken *v3.Token, oidcClient *v
 	if groups != nil {
 		idClaims["groups"] = groups
 	}
-	if rancherToken.AuthProvider != "" {
-		idClaims["auth_provider"] = rancherToken.AuthProvider
-	}
-	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
-	idToken.Header["kid"] = kid
-	idTokenString, err := idToken.SignedString(key)
-	if err != nil {
-		logrus.Errorf("[OIDC provider] failed to sign id token %v", err)
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to sign id token: %v", err))
+	if authProvider != "" {
+		idClaims["auth_provider"] = authProvider
 	}
 
-	// create access_token
 	accessClaims := jwt.MapClaims{
-		"aud":   []string{oidcClient.Status.ClientID},
-		"exp":   h.now().Add(time.Duration(oidcClient.Spec.TokenExpirationSeconds) * time.Second).Unix(),
+		"aud":   []string{audience},
+		"exp":   h.now().Add(expiresIn).Unix(),
 		"iss":   settings.ServerURL.Get() + "/oidc",
 		"iat":   h.now().Unix(),
-		"sub":   rancherToken.UserID,
+		"sub":   userID,
 		"scope": scopes,
 	}
-	if rancherToken.AuthProvider != "" {
-		accessClaims["auth_provider"] = rancherToken.AuthProvider
+	if authProvider != "" {
+		accessClaims["auth_provider"] = authProvider
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims)
-	accessToken.Header["kid"] = kid
 
-	accessTokenString, err := accessToken.SignedString(key)
+	return idClaims, accessClaims, nil
+}
+
+// signToken signs the claims with the given key. tokenName is used in error messages.
+func signToken(claims jwt.MapClaims, key crypto.Signer, kid string, tokenName string) (string, *oidcerror.Error) {
+	alg, err := signingkey.Algorithm(key.Public())
 	if err != nil {
-		logrus.Errorf("[OIDC provider] failed to sign access token %v", err)
-		return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to sign access token: %v", err))
+		return "", oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to sign %s: %v", tokenName, err))
 	}
-	resp := TokenResponse{
-		IDToken:     idTokenString,
-		AccessToken: accessTokenString,
-		TokenType:   bearerTokenType,
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	token.Header["kid"] = kid
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		logrus.Errorf("[OIDC provider] failed to sign %s %v", tokenName, err)
+		return "", oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to sign %s: %v", tokenName, err))
 	}
 
-	// create refresh_token
-	if slices.Contains(scopes, "offline_access") {
-		hash := sha256.Sum256([]byte(rancherToken.Name))
-		rancherTokenHash := hex.EncodeToString(hash[:])
-		refreshClaims := jwt.MapClaims{
-			"aud":                []string{oidcClient.Status.ClientID},
-			"exp":                h.now().Add(time.Duration(oidcClient.Spec.RefreshTokenExpirationSeconds) * time.Second).Unix(),
-			"iat":                h.now().Unix(),
-			"sub":                rancherToken.UserID,
-			"rancher_token_hash": rancherTokenHash,
-			"scope":              scopes,
-		}
-		if rancherToken.AuthProvider != "" {
-			refreshClaims["auth_provider"] = rancherToken.AuthProvider
-		}
-		refreshToken := jwt.NewWithClaims(jwt.SigningMethodRS256, refreshClaims)
-		refreshToken.Header["kid"] = kid
-		refreshTokenString, err := refreshToken.SignedString(key)
-		if err != nil {
-			logrus.Errorf("[OIDC provider] failed to sign refresh token %v", err)
-			return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to sign refresh token: %v", err))
-		}
-		resp.RefreshToken = refreshTokenString
+	return tokenString, nil
+}
 
-		if err := h.addOIDCClientIDToRancherToken(oidcClient.Name, rancherToken); err != nil {
-			return TokenResponse{}, oidcerror.New(oidcerror.ServerError, fmt.Sprintf("failed to add OIDC Client ID to Rancher token: %v", err))
+// clientCredentialsFromRequest returns the client_id and client_secret. They can be set in the Authorization header or as a form param as
+// specified in the OIDC spec.
+func clientCredentialsFromRequest(r *http.Request) (string, string) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.FormValue("client_id")
+		clientSecret = r.FormValue("client_secret")
+	}
+
+	return clientID, clientSecret
+}
+
+// authenticateClient verifies the client secret and returns the OIDC client.
+func (h *tokenHandler) authenticateClient(clientID, clientSecret string) (*v3.OIDCClient, *oidcerror.Error) {
+	oidcClient, err := h.getOIDCClientByClientID(clientID)
+	if err != nil {
+		return nil, oidcerror.New(oidcerror.ServerError, "failed to get OIDC client")
+	}
+	secret, err := h.secretCache.Get(secretsNamespace, clientID)
+	if err != nil {
+		return nil, oidcerror.New(oidcerror.ServerError, "failed to get client secret")
+	}
+	for key, cs := range secret.Data {
+		if clientSecret != "" && clientSecret == string(cs) {
+			if err := h.updateClientSecretUsedTimeStamp(oidcClient, key); err != nil {
+				logrus.Errorf("[OIDC provider] failed to update client secret's used timestamp: %v", err)
+			}
+			return oidcClient, nil
 		}
 	}
 
-	resp.ExpiresIn = time.Duration(oidcClient.Spec.TokenExpirationSeconds) * time.Second
+	return nil, oidcerror.New(oidcerror.InvalidRequest, "invalid client_secret")
+}
 
-	return resp, nil
+// scopesFromRequest returns the scopes requested with the scope param. offline_access can't be requested since grants using this
+// don't issue refresh tokens.
+func scopesFromRequest(r *http.Request) ([]string, *oidcerror.Error) {
+	scopes := strings.Fields(r.Form.Get("scope"))
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) || scope == "offline_access" {
+			return nil, oidcerror.New(oidcerror.InvalidScope, fmt.Sprintf("invalid scope: %s", scope))
+		}
+	}
+
+	return scopes, nil
 }
 
 func (h *tokenHandler) updateClientSecretUsedTimeStamp(oidcClient *v3.OIDCClient, clientSecretID string) interface{} {
@@ -461,6 +644,41 @@ func (h *tokenHandler) addOIDCClientIDToRancherToken(oidcClientName string, ranc
 	return err
 }
 
+// parseToken parses a token issued by the OIDC provider and verifies its signature.
+func parseToken(tokenString string, claims jwt.Claims, jwks signingKeyGetter) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("can't find kid")
+		}
+
+		return jwks.GetPublicKey(kid)
+	}, jwt.WithValidMethods(signingkey.Algorithms))
+}
+
+// getRancherTokenByHash returns the Rancher token of the user whose name matches the hash, or nil if there is no such token.
+func (h *tokenHandler) getRancherTokenByHash(userID string, rancherTokenHash string) (*v3.Token, error) {
+	tokenList, err := h.tokenCache.List(labels.SelectorFromSet(map[string]string{
+		tokens.UserIDLabel: userID,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokenList {
+		if hashRancherToken(token.Name) == rancherTokenHash {
+			return token, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// hashRancherToken returns the hash of the Rancher token name included in the tokens issued for it.
+func hashRancherToken(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:])
+}
+
 func (h *tokenHandler) getOIDCClientByClientID(clientID string) (*v3.OIDCClient, error) {
 	oidcClients, err := h.oidcClientCache.GetByIndex(oidcClientByIDIndex, clientID)
 	if err != nil {
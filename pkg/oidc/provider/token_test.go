@@ -66,6 +66,10 @@ type mockParams struct {
 		fakeClientSecretID       = "client-secret-1"
 		fakeTokenLifespan        = 600
 		fakeRefreshTokenLifespan = 3600
+		fakeServiceUserID        = "service-user-id"
+		fakeAudienceClientID     = "audience-client-id"
+		fakeAudienceLifespan     = 60
+		fakeTokenKey             = "token-key"
 	)
 
 	fakeScopes := []interface{}{"openid", "profile"}
@@ -99,6 +103,23 @@ type mockParams struct {
 			ClientID: fakeClientID,
 		},
 	}
+	fakeServiceOIDCClient := fakeOIDCClient.DeepCopy()
+	fakeServiceOIDCClient.Spec.ServicePrincipal = fakeServiceUserID
+	fakeServiceOIDCClient.Status.ServicePrincipal = fakeServiceUserID
+	fakeUnauthorizedServiceOIDCClient := fakeServiceOIDCClient.DeepCopy()
+	fakeUnauthorizedServiceOIDCClient.Status.ServicePrincipal = ""
+	fakeAudienceOIDCClient := &v3.OIDCClient{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "audience-client-name",
+		},
+		Spec: v3.OIDCClientSpec{
+			TokenExpirationSeconds:        fakeAudienceLifespan,
+			RefreshTokenExpirationSeconds: fakeRefreshTokenLifespan,
+		},
+		Status: v3.OIDCClientStatus{
+			ClientID: fakeAudienceClientID,
+		},
+	}
 
 	fakeToken := &v3.Token{
 		ObjectMeta: metav1.ObjectMeta{
@@ -112,7 +133,7 @@ type mockParams struct {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fakeTokenName,
 			CreationTimestamp: metav1.Time{
-				time.Unix(10, 0),
+				Time: time.Unix(10, 0),
 			},
 		},
 		UserID:       fakeUserID,
@@ -120,6 +141,8 @@ type mockParams struct {
 		AuthProvider: fakeAuthProvider,
 		TTLMillis:    1,
 	}
+	fakeTokenWithKey := fakeToken.DeepCopy()
+	fakeTokenWithKey.Token = fakeTokenKey
 	fakeUser := &v3.User{
 		DisplayName: fakeUsername,
 		Enabled:     ptr.To(true),
@@ -221,13 +244,14 @@ type mockParams struct {
 				"groups":        []interface{}{fakeGroup},
 			},
 			wantAccessTokenClaims: &jwt.MapClaims{
-				"aud":           []interface{}{fakeClientID},
-				"exp":           float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
-				"iss":           settings.ServerURL.Get() + "/oidc",
-				"iat":           float64(fakeTime().Unix()),
-				"sub":           fakeUserID,
-				"auth_provider": fakeAuthProvider,
-				"scope":         fakeScopes,
+				"aud":                []interface{}{fakeClientID},
+				"exp":                float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
+				"iss":                settings.ServerURL.Get() + "/oidc",
+				"iat":                float64(fakeTime().Unix()),
+				"sub":                fakeUserID,
+				"auth_provider":      fakeAuthProvider,
+				"rancher_token_hash": rancherTokenHash,
+				"scope":              fakeScopes,
 			},
 		},
 		"authorization_code fails for an invalid code": {
@@ -385,13 +409,14 @@ type mockParams struct {
 				"groups":        []interface{}{fakeGroup},
 			},
 			wantAccessTokenClaims: &jwt.MapClaims{
-				"aud":           []interface{}{fakeClientID},
-				"exp":           float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
-				"iss":           settings.ServerURL.Get() + "/oidc",
-				"iat":           float64(fakeTime().Unix()),
-				"sub":           fakeUserID,
-				"auth_provider": fakeAuthProvider,
-				"scope":         fakeScopesOfflineAccess,
+				"aud":                []interface{}{fakeClientID},
+				"exp":                float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
+				"iss":                settings.ServerURL.Get() + "/oidc",
+				"iat":                float64(fakeTime().Unix()),
+				"sub":                fakeUserID,
+				"auth_provider":      fakeAuthProvider,
+				"rancher_token_hash": rancherTokenHash,
+				"scope":              fakeScopesOfflineAccess,
 			},
 			wantRefreshTokenClaims: &jwt.MapClaims{
 				"aud":                []interface{}{fakeClientID},
@@ -436,13 +461,14 @@ type mockParams struct {
 				"groups":        []interface{}{fakeGroup},
 			},
 			wantAccessTokenClaims: &jwt.MapClaims{
-				"aud":           []interface{}{fakeClientID},
-				"exp":           float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
-				"iss":           settings.ServerURL.Get() + "/oidc",
-				"iat":           float64(fakeTime().Unix()),
-				"sub":           fakeUserID,
-				"auth_provider": fakeAuthProvider,
-				"scope":         fakeScopesOfflineAccess,
+				"aud":                []interface{}{fakeClientID},
+				"exp":                float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
+				"iss":                settings.ServerURL.Get() + "/oidc",
+				"iat":                float64(fakeTime().Unix()),
+				"sub":                fakeUserID,
+				"auth_provider":      fakeAuthProvider,
+				"rancher_token_hash": rancherTokenHash,
+				"scope":              fakeScopesOfflineAccess,
 			},
 			wantRefreshTokenClaims: &jwt.MapClaims{
 				"aud":                []interface{}{fakeClientID},
@@ -559,6 +585,198 @@ type mockParams struct {
 			},
 			wantError: `{"error":"server_error","error_description":"failed to parse refresh token: token has invalid claims: token is expired"}`,
 		},
+		"client_credentials returns an id_token and access_token for the service principal": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "client_credentials")
+				data.Set("scope", "openid profile")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeServiceOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeServiceOIDCClient, nil)
+				m.userLister.EXPECT().Get(fakeServiceUserID).Return(fakeUser, nil)
+				m.useAttributeLister.EXPECT().Get(fakeServiceUserID).Return(nil, errors.NewNotFound(schema.GroupResource{}, fakeServiceUserID))
+				m.signingKeyGetter.EXPECT().GetSigningKey().Return(privateKey, fakeSigningKey, nil)
+			},
+			wantIdTokenClaims: &jwt.MapClaims{
+				"aud":  []interface{}{fakeClientID},
+				"exp":  float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
+				"iss":  settings.ServerURL.Get() + "/oidc",
+				"iat":  float64(fakeTime().Unix()),
+				"name": fakeUsername,
+				"sub":  fakeServiceUserID,
+			},
+			wantAccessTokenClaims: &jwt.MapClaims{
+				"aud":   []interface{}{fakeClientID},
+				"exp":   float64(fakeTime().Add(fakeTokenLifespan * time.Second).Unix()),
+				"iss":   settings.ServerURL.Get() + "/oidc",
+				"iat":   float64(fakeTime().Unix()),
+				"sub":   fakeServiceUserID,
+				"scope": fakeScopes,
+			},
+		},
+		"client_credentials fails for a client without a service principal": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "client_credentials")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeOIDCClient, nil)
+			},
+			wantError: `{"error":"unauthorized_client","error_description":"client has no service principal"}`,
+		},
+		"client_credentials fails for a client whose service principal is not authorized": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "client_credentials")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeUnauthorizedServiceOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeUnauthorizedServiceOIDCClient, nil)
+			},
+			wantError: `{"error":"unauthorized_client","error_description":"service principal of the client is not authorized"}`,
+		},
+		"client_credentials fails for an invalid client_secret": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "client_credentials")
+				data.Set("client_id", fakeClientID)
+				data.Set("client_secret", "invalid")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeServiceOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+			},
+			wantError: `{"error":"invalid_request","error_description":"invalid client_secret"}`,
+		},
+		"client_credentials fails when offline_access is requested": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "client_credentials")
+				data.Set("scope", "openid offline_access")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeServiceOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeServiceOIDCClient, nil)
+			},
+			wantError: `{"error":"invalid_scope","error_description":"invalid scope: offline_access"}`,
+		},
+		"token exchange returns an access_token for the requested audience": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+				data.Set("subject_token", fakeTokenName+":"+fakeTokenKey)
+				data.Set("subject_token_type", "urn:ietf:params:oauth:token-type:access_token")
+				data.Set("audience", fakeAudienceClientID)
+				data.Set("scope", "openid profile")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeOIDCClient, nil)
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeAudienceClientID).Return([]*v3.OIDCClient{fakeAudienceOIDCClient}, nil)
+				m.tokenCache.EXPECT().Get(fakeTokenName).Return(fakeTokenWithKey, nil)
+				m.userLister.EXPECT().Get(fakeUserID).Return(fakeUser, nil)
+				m.useAttributeLister.EXPECT().Get(fakeUserID).Return(fakeUserAttributes, nil)
+				m.signingKeyGetter.EXPECT().GetSigningKey().Return(privateKey, fakeSigningKey, nil)
+			},
+			wantAccessTokenClaims: &jwt.MapClaims{
+				"aud":                []interface{}{fakeAudienceClientID},
+				"exp":                float64(fakeTime().Add(fakeAudienceLifespan * time.Second).Unix()),
+				"iss":                settings.ServerURL.Get() + "/oidc",
+				"iat":                float64(fakeTime().Unix()),
+				"sub":                fakeUserID,
+				"auth_provider":      fakeAuthProvider,
+				"rancher_token_hash": rancherTokenHash,
+				"scope":              fakeScopes,
+			},
+		},
+		"token exchange fails for an invalid subject_token": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+				data.Set("subject_token", fakeTokenName+":invalid")
+				data.Set("subject_token_type", "urn:ietf:params:oauth:token-type:access_token")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeOIDCClient, nil)
+				m.tokenCache.EXPECT().Get(fakeTokenName).Return(fakeTokenWithKey, nil)
+			},
+			wantError: `{"error":"invalid_request","error_description":"invalid subject_token"}`,
+		},
+		"token exchange fails for an unknown audience": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+				data.Set("subject_token", fakeTokenName+":"+fakeTokenKey)
+				data.Set("subject_token_type", "urn:ietf:params:oauth:token-type:access_token")
+				data.Set("audience", "unknown")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fakeClientID+":"+fakeClientSecret))))
+
+				return req
+			},
+			mockSetup: func(m mockParams) {
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", fakeClientID).Return([]*v3.OIDCClient{fakeOIDCClient}, nil)
+				m.secretCache.EXPECT().Get("cattle-oidc-client-secrets", fakeClientID).Return(fakeClientk8sSecret, nil)
+				m.oidcClient.EXPECT().Patch(fakeClientName, types.JSONPatchType, clientSecretIDPatch).Return(fakeOIDCClient, nil)
+				m.oidcClientCache.EXPECT().GetByIndex("oidc.management.cattle.io/oidcclient-by-id", "unknown").Return(nil, nil)
+			},
+			wantError: `{"error":"invalid_target","error_description":"unknown audience"}`,
+		},
+		"unsupported grant_type": {
+			req: func() *http.Request {
+				data := url.Values{}
+				data.Set("grant_type", "password")
+				req, _ := http.NewRequest("POST", "https://rancher.com", bytes.NewBufferString(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+				return req
+			},
+			wantError: `{"error":"unsupported_grant_type","error_description":"grant_type not supported"}`,
+		},
 	}
 
 	// register auth provider
@@ -40,22 +40,7 @@ func (h *userInfoHandler) userInfoEndpoint(w http.ResponseWriter, r *http.Reques
 	accessToken, err := getTokenFromHeader(r)
 	claims := jwt.MapClaims{}
 	// verify access_token signature
-	_, err = jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
-		// Ensure correct signing method
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		kid, ok := token.Header["kid"].(string)
-		if !ok {
-			return nil, fmt.Errorf("can't find kid")
-		}
-		pubKey, err := h.jwks.GetPublicKey(kid)
-		if err != nil {
-			return nil, err
-		}
-
-		return pubKey, nil
-	})
+	_, err = parseToken(accessToken, &claims, h.jwks)
 	if err != nil {
 		oidcerror.WriteError(oidcerror.InvalidRequest, fmt.Sprintf("invalid access_token: %v", err), http.StatusBadRequest, w)
 		return
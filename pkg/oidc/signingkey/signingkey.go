@@ -0,0 +1,164 @@
+// Package signingkey generates and parses the keys the Rancher OIDC provider uses to sign tokens.
+//
+// Keys are stored in the oidc-signing-key secret in the cattle-system namespace. The private key used for signing is
+// stored as <kid>.pem and the public keys published in the JWKS endpoint as <kid>.pub. RSA (RS256) and ECDSA P-256
+// (ES256) keys are supported.
+package signingkey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+const (
+	// SecretNamespace is the namespace of the secret holding the signing keys.
+	SecretNamespace = "cattle-system"
+	// SecretName is the name of the secret holding the signing keys.
+	SecretName = "oidc-signing-key"
+
+	// PrivateKeySuffix is the suffix of the secret entry holding the private key used for signing.
+	PrivateKeySuffix = ".pem"
+	// PublicKeySuffix is the suffix of the secret entries holding the public keys published in the JWKS endpoint.
+	PublicKeySuffix = ".pub"
+
+	// CreatedAtAnnotation records when the current signing key was created, in RFC3339 format.
+	CreatedAtAnnotation = "oidc.management.cattle.io/signing-key-created-at"
+	// RetiredKeysAnnotation is a JSON object mapping the kid of every key that is no longer used for signing to when
+	// it was retired, in RFC3339 format. The public keys are removed once the overlap window has passed.
+	RetiredKeysAnnotation = "oidc.management.cattle.io/retired-signing-keys"
+
+	// RS256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	RS256 = "RS256"
+	// ES256 is ECDSA using P-256 and SHA-256.
+	ES256 = "ES256"
+
+	rsaKeyBits = 3072
+	// minRSAKeyBits is the minimum size of RSA keys accepted for verifying tokens.
+	minRSAKeyBits = 2048
+)
+
+// Algorithms are the supported signing algorithms.
+var Algorithms = []string{RS256, ES256}
+
+// Generate returns a new PEM encoded private and public key pair for the algorithm.
+func Generate(algorithm string) ([]byte, []byte, error) {
+	var privateKey crypto.Signer
+	var privateKeyPEM []byte
+	switch algorithm {
+	case RS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey = key
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+	case ES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal private key: %w", err)
+		}
+		privateKey = key
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		})
+	default:
+		return nil, nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyDER,
+	})
+
+	return privateKeyPEM, publicKeyPEM, nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS1 RSA, SEC1 EC or PKCS8 private key.
+func ParsePrivateKey(privateKeyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if _, err := Algorithm(signer.Public()); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+// ParsePublicKey parses a PEM encoded PKIX public key.
+func ParsePublicKey(publicKeyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("failed to decode PEM block containing public key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	if _, err := Algorithm(publicKey); err != nil {
+		return nil, err
+	}
+
+	return publicKey, nil
+}
+
+// Algorithm returns the signing algorithm used with the public key.
+func Algorithm(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return RS256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+		}
+		return ES256, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// IsTooWeak returns true if the public key should not be used to verify tokens.
+func IsTooWeak(publicKey crypto.PublicKey) bool {
+	key, ok := publicKey.(*rsa.PublicKey)
+	return ok && key.N.BitLen() < minRSAKeyBits
+}
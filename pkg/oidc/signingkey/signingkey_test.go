@@ -0,0 +1,33 @@
+package signingkey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			privateKeyPEM, publicKeyPEM, err := Generate(algorithm)
+			require.NoError(t, err)
+
+			privateKey, err := ParsePrivateKey(privateKeyPEM)
+			require.NoError(t, err)
+			publicKey, err := ParsePublicKey(publicKeyPEM)
+			require.NoError(t, err)
+			assert.Equal(t, privateKey.Public(), publicKey)
+
+			got, err := Algorithm(publicKey)
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, got)
+			assert.False(t, IsTooWeak(publicKey))
+		})
+	}
+}
+
+func TestGenerateUnsupportedAlgorithm(t *testing.T) {
+	_, _, err := Generate("HS256")
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@
 	"strings"
 	"time"
 
+	"github.com/rancher/rancher/pkg/encryptedstore"
 	"github.com/rancher/rancher/pkg/scc"
 
 	"github.com/Masterminds/semver/v3"
@@ -48,10 +49,12 @@
 	"github.com/rancher/rancher/pkg/serviceaccounttoken"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/tls"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/ui"
 	"github.com/rancher/rancher/pkg/websocket"
 	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/remotedialer"
 	aggregation2 "github.com/rancher/steve/pkg/aggregation"
 	steveauth "github.com/rancher/steve/pkg/auth"
 	steveserver "github.com/rancher/steve/pkg/server"
@@ -98,9 +101,14 @@ type Options struct {
 	AuditLogMaxbackup              int
 	AuditLogLevel                  int
 	AuditLogEnabled                bool
+	AuditLogSpoolDir               string
+	AuditLogSpoolMaxsize           int
+	AuditLogHashChain              bool
+	AuditLogCheckpointInterval     int
 	Features                       string
 	ClusterRegistry                string
 	AggregationRegistrationTimeout time.Duration
+	EncryptionProviderConfig       string
 }
 
 type Rancher struct {
@@ -135,6 +143,12 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 		return nil, err
 	}
 
+	if opts.EncryptionProviderConfig != "" {
+		if err := encryptedstore.Configure(ctx, opts.EncryptionProviderConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// Run the encryption migration before any controllers run otherwise the fields will be dropped
 	if err := migrateEncryptionConfig(ctx, restConfig); err != nil {
 		return nil, err
@@ -282,7 +296,9 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 	}
 
 	clusterProxy, err := proxy.NewProxyMiddleware(wranglerContext.K8s.AuthorizationV1(),
-		wranglerContext.TunnelServer.Dialer,
+		func(clusterID string) remotedialer.Dialer {
+			return tunnelserver.Dialer(wranglerContext.TunnelServer, clusterID)
+		},
 		wranglerContext.Mgmt.Cluster().Cache(),
 		localClusterEnabled(opts),
 		steve,
@@ -308,9 +324,23 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 		}
 		defer out.Close()
 
+		chainOpts := audit.ChainOptions{
+			Enabled:            opts.AuditLogHashChain,
+			CheckpointInterval: uint64(opts.AuditLogCheckpointInterval),
+		}
+		if chainOpts.Enabled {
+			chainOpts.Signer, chainOpts.KeyID, err = audit.CheckpointSignerFromSecret(wranglerContext.Core.Secret())
+			if err != nil {
+				return nil, fmt.Errorf("failed to load audit log signing key: %w", err)
+			}
+		}
+
 		auditLogWriter, err = audit.NewWriter(out, audit.WriterOptions{
 			DefaultPolicyLevel:     auditlogv1.Level(opts.AuditLogLevel),
 			DisableDefaultPolicies: !opts.AuditLogEnabled,
+			SpoolDir:               opts.AuditLogSpoolDir,
+			SpoolMaxSize:           int64(opts.AuditLogSpoolMaxsize) * 1024 * 1024,
+			Chain:                  chainOpts,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create audit log writer: %w", err)
@@ -311,9 +311,14 @@ func IsAdminGlobalRole(rtName string, grLister normanv3.GlobalRoleLister) (bool,
 		return false, err
 	}
 
+	return IsAdminGlobalRoleObject(gr), nil
+}
+
+// IsAdminGlobalRoleObject detects whether the given GlobalRole has admin permissions or not.
+func IsAdminGlobalRoleObject(gr *v3.GlobalRole) bool {
 	// global role is builtin admin role
 	if gr.Builtin && gr.Name == GlobalAdmin {
-		return true, nil
+		return true
 	}
 
 	var hasResourceRule, hasNonResourceRule bool
@@ -329,11 +334,7 @@ func IsAdminGlobalRole(rtName string, grLister normanv3.GlobalRoleLister) (bool,
 	}
 
 	// global role has an admin resource rule, and admin nonResourceURLs rule
-	if hasResourceRule && hasNonResourceRule {
-		return true, nil
-	}
-
-	return false, nil
+	return hasResourceRule && hasNonResourceRule
 }
 
 // CreateOrUpdateResource creates or updates the given non-namespaced resource
@@ -378,6 +378,9 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 		MustImport(&Version, v3.SearchPrincipalsInput{}).
 		MustImport(&Version, v3.ChangePasswordInput{}).
 		MustImport(&Version, v3.SetPasswordInput{}).
+		MustImport(&Version, v3.MFACodeInput{}).
+		MustImport(&Version, v3.MFAEnrollOutput{}).
+		MustImport(&Version, v3.MFARecoveryCodesOutput{}).
 		MustImportAndCustomize(&Version, v3.User{}, func(schema *types.Schema) {
 			schema.ResourceActions = map[string]types.Action{
 				"setpassword": {
@@ -385,12 +388,32 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 					Output: "user",
 				},
 				"refreshauthprovideraccess": {},
+				"resetmfa": {
+					Output: "user",
+				},
+				"unlock": {
+					Output: "user",
+				},
 			}
 			schema.CollectionActions = map[string]types.Action{
 				"changepassword": {
 					Input: "changePasswordInput",
 				},
 				"refreshauthprovideraccess": {},
+				"enrollmfa": {
+					Output: "mfaEnrollOutput",
+				},
+				"confirmmfa": {
+					Input:  "mfaCodeInput",
+					Output: "mfaRecoveryCodesOutput",
+				},
+				"regeneratemfarecoverycodes": {
+					Input:  "mfaCodeInput",
+					Output: "mfaRecoveryCodesOutput",
+				},
+				"disablemfa": {
+					Input: "mfaCodeInput",
+				},
 			}
 		}).
 		MustImportAndCustomize(&Version, v3.AuthConfig{}, func(schema *types.Schema) {
@@ -0,0 +1,48 @@
+package settings
+
+import (
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxHistory is the number of revisions kept in the history of each setting.
+const MaxHistory = 10
+
+// AppendRevision returns history with a revision recording the change of a setting from oldValue to newValue by user
+// appended to it. The oldest revisions are dropped to keep at most MaxHistory of them.
+// The history is returned unchanged if the value did not change.
+func AppendRevision(history []v32.SettingRevision, oldValue, newValue, user string, now time.Time) []v32.SettingRevision {
+	if oldValue == newValue {
+		return history
+	}
+
+	var revision int64 = 1
+	if len(history) > 0 {
+		revision = history[len(history)-1].Revision + 1
+	}
+
+	result := make([]v32.SettingRevision, 0, min(len(history)+1, MaxHistory))
+	if len(history) >= MaxHistory {
+		history = history[len(history)-MaxHistory+1:]
+	}
+	result = append(result, history...)
+	return append(result, v32.SettingRevision{
+		Revision: revision,
+		OldValue: oldValue,
+		NewValue: newValue,
+		User:     user,
+		Time:     metav1.NewTime(now),
+	})
+}
+
+// FindRevision returns the revision with the given sequence number from history.
+func FindRevision(history []v32.SettingRevision, revision int64) (v32.SettingRevision, bool) {
+	for _, r := range history {
+		if r.Revision == revision {
+			return r, true
+		}
+	}
+	return v32.SettingRevision{}, false
+}
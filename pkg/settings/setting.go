@@ -23,6 +23,9 @@
 	DefaultMaxUIPluginFileSizeInBytes = 30 * 1024 * 1024 // 30MB
 	AgentTLSModeStrict                = "strict"
 	AgentTLSModeSystemStore           = "system-store"
+	MFARequiredNone                   = "none"
+	MFARequiredAdmins                 = "admins"
+	MFARequiredAll                    = "all"
 )
 
 var (
@@ -57,32 +60,32 @@
 	}
 
 	AgentImage          = NewSetting("agent-image", "rancher/rancher-agent:head")
-	AgentRolloutTimeout = NewSetting("agent-rollout-timeout", "300s")
+	AgentRolloutTimeout = NewSetting("agent-rollout-timeout", "300s").WithDurationRange(time.Second, 0)
 	// AgentTLSMode is translated to the environment variable STRICT_VERIFY when rendering the cluster/node agent manifests and should not be specified as a default agent setting as it has no direct effect on the agent itself.
-	AgentTLSMode                        = NewSetting("agent-tls-mode", AgentTLSModeStrict).WithDefaultOnUpgrade(AgentTLSModeSystemStore)
+	AgentTLSMode                        = NewSetting("agent-tls-mode", AgentTLSModeStrict).WithDefaultOnUpgrade(AgentTLSModeSystemStore).WithEnum(AgentTLSModeStrict, AgentTLSModeSystemStore)
 	AuthImage                           = NewSetting("auth-image", v32.ToolsSystemImages.AuthSystemImages.KubeAPIAuth)
-	AuthorizationCacheTTLSeconds        = NewSetting("authorization-cache-ttl-seconds", "10")
-	AuthorizationDenyCacheTTLSeconds    = NewSetting("authorization-deny-cache-ttl-seconds", "10")
-	AzureGroupCacheSize                 = NewSetting("azure-group-cache-size", "10000")
+	AuthorizationCacheTTLSeconds        = NewSetting("authorization-cache-ttl-seconds", "10").WithMin(0)
+	AuthorizationDenyCacheTTLSeconds    = NewSetting("authorization-deny-cache-ttl-seconds", "10").WithMin(0)
+	AzureGroupCacheSize                 = NewSetting("azure-group-cache-size", "10000").WithMin(0)
 	CACerts                             = NewSetting("cacerts", "")
 	CLIURLDarwin                        = NewSetting("cli-url-darwin", "https://releases.rancher.com/cli/v1.0.0-alpha8/rancher-darwin-amd64-v1.0.0-alpha8.tar.gz")
 	CLIURLLinux                         = NewSetting("cli-url-linux", "https://releases.rancher.com/cli/v1.0.0-alpha8/rancher-linux-amd64-v1.0.0-alpha8.tar.gz")
 	CLIURLWindows                       = NewSetting("cli-url-windows", "https://releases.rancher.com/cli/v1.0.0-alpha8/rancher-windows-386-v1.0.0-alpha8.zip")
-	ClusterControllerStartCount         = NewSetting("cluster-controller-start-count", "50")
+	ClusterControllerStartCount         = NewSetting("cluster-controller-start-count", "50").WithMin(1)
 	EngineInstallURL                    = NewSetting("engine-install-url", "https://releases.rancher.com/install-docker/28.1.sh")
 	EngineISOURL                        = NewSetting("engine-iso-url", "https://releases.rancher.com/os/latest/rancheros-vmware.iso")
 	EngineNewestVersion                 = NewSetting("engine-newest-version", "v17.12.0")
 	EngineSupportedRange                = NewSetting("engine-supported-range", "~v1.11.2 || ~v1.12.0 || ~v1.13.0 || ~v17.03.0 || ~v17.06.0 || ~v17.09.0 || ~v18.06.0 || ~v18.09.0 || ~v19.03.0 || ~v20.10.0 || ~v23.0.0 || ~v24.0.0 || ~v25.0.0 || ~v26.0.0 || ~v26.1.0|| ~v27.0.0|| ~v27.1.0|| ~v27.2.0|| ~v27.3.0|| ~v27.4.0|| ~v27.5.0|| ~v28.0.0|| ~v28.1.0")
-	FirstLogin                          = NewSetting("first-login", "true")
-	GlobalRegistryEnabled               = NewSetting("global-registry-enabled", "false")
+	FirstLogin                          = NewSetting("first-login", "true").WithType(TypeBool)
+	GlobalRegistryEnabled               = NewSetting("global-registry-enabled", "false").WithType(TypeBool)
 	GithubProxyAPIURL                   = NewSetting("github-proxy-api-url", "https://api.github.com")
 	HelmVersion                         = NewSetting("helm-version", "dev")
-	HelmMaxHistory                      = NewSetting("helm-max-history", "10")
+	HelmMaxHistory                      = NewSetting("helm-max-history", "10").WithMin(0)
 	IngressIPDomain                     = NewSetting("ingress-ip-domain", "sslip.io")
 	InstallUUID                         = NewSetting("install-uuid", "")
 	InternalServerURL                   = NewSetting("internal-server-url", "")
 	InternalCACerts                     = NewSetting("internal-cacerts", "")
-	JailerTimeout                       = NewSetting("jailer-timeout", "60")
+	JailerTimeout                       = NewSetting("jailer-timeout", "60").WithMin(1)
 	KubernetesVersion                   = NewSetting("k8s-version", "")
 	KubernetesVersionToServiceOptions   = NewSetting("k8s-version-to-service-options", "")
 	KubernetesVersionToSystemImages     = NewSetting("k8s-version-to-images", "")
@@ -91,7 +94,10 @@
 	KDMBranch                           = NewSetting("kdm-branch", "dev-v2.12")
 	MachineVersion                      = NewSetting("machine-version", "dev")
 	Namespace                           = NewSetting("namespace", os.Getenv("CATTLE_NAMESPACE"))
-	PasswordMinLength                   = NewSetting("password-min-length", "12")
+	PasswordMinLength                   = NewSetting("password-min-length", "12").WithRange(2, 256)
+	PasswordMinCharacterClasses         = NewSetting("password-min-character-classes", "0").WithRange(0, 4)
+	PasswordHistorySize                 = NewSetting("password-history-size", "0").WithRange(0, 24)
+	PasswordMaxAge                      = NewSetting("password-max-age", "0s").WithDurationRange(0, 0)
 	PeerServices                        = NewSetting("peer-service", os.Getenv("CATTLE_PEER_SERVICE"))
 	RkeMetadataConfig                   = NewSetting("rke-metadata-config", getMetadataConfig())
 	ServerImage                         = NewSetting("server-image", "rancher/rancher")
@@ -108,32 +114,33 @@
 	WinsAgentUpgradeImage               = NewSetting("wins-agent-upgrade-image", "")
 	SystemNamespaces                    = NewSetting("system-namespaces", strings.Join(systemNamespaces, ","))
 	SystemUpgradeControllerChartVersion = NewSetting("system-upgrade-controller-chart-version", "")
-	TLSMinVersion                       = NewSetting("tls-min-version", "1.2")
+	TLSMinVersion                       = NewSetting("tls-min-version", "1.2").WithEnum("1.0", "1.1", "1.2", "1.3")
 	TLSCiphers                          = NewSetting("tls-ciphers", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305")
 	WhitelistDomain                     = NewSetting("whitelist-domain", "forums.rancher.com")
 	WhitelistEnvironmentVars            = NewSetting("whitelist-envvars", "HTTP_PROXY,HTTPS_PROXY,NO_PROXY")
 	AuthUserInfoResyncCron              = NewSetting("auth-user-info-resync-cron", "0 0 * * *")
-	APIUIVersion                        = NewSetting("api-ui-version", "1.1.11")              // Please update the CATTLE_API_UI_VERSION in package/Dockerfile when updating the version here.
-	RotateCertsIfExpiringInDays         = NewSetting("rotate-certs-if-expiring-in-days", "7") // 7 days
-	ClusterTemplateEnforcement          = NewSetting("cluster-template-enforcement", "false")
+	APIUIVersion                        = NewSetting("api-ui-version", "1.1.11")                         // Please update the CATTLE_API_UI_VERSION in package/Dockerfile when updating the version here.
+	RotateCertsIfExpiringInDays         = NewSetting("rotate-certs-if-expiring-in-days", "7").WithMin(1) // 7 days
+	ClusterTemplateEnforcement          = NewSetting("cluster-template-enforcement", "false").WithType(TypeBool)
 	InitialDockerRootDir                = NewSetting("initial-docker-root-dir", "/var/lib/docker")
-	SystemCatalog                       = NewSetting("system-catalog", "external") // Options are 'external' or 'bundled'
+	SystemCatalog                       = NewSetting("system-catalog", "external").WithEnum("external", "bundled") // Options are 'external' or 'bundled'
 	ChartDefaultBranch                  = NewSetting("chart-default-branch", "dev-v2.12")
-	SystemManagedChartsOperationTimeout = NewSetting("system-managed-charts-operation-timeout", "300s")
+	SystemManagedChartsOperationTimeout = NewSetting("system-managed-charts-operation-timeout", "300s").WithDurationRange(time.Second, 0)
 	FleetDefaultWorkspaceName           = NewSetting("fleet-default-workspace-name", fleetconst.ClustersDefaultNamespace) // fleetWorkspaceName to assign to clusters with none
 	ShellImage                          = NewSetting("shell-image", buildconfig.DefaultShellVersion)
 	IgnoreNodeName                      = NewSetting("ignore-node-name", "") // nodes to ignore when syncing v1.node to v3.node
 	NoDefaultAdmin                      = NewSetting("no-default-admin", "")
-	AKSUpstreamRefresh                  = NewSetting("aks-refresh", "300")
+	AKSUpstreamRefresh                  = NewSetting("aks-refresh", "300").WithMin(1)
 	EKSUpstreamRefreshCron              = NewSetting("eks-refresh-cron", "*/5 * * * *") // EKSUpstreamRefreshCron is deprecated and will be replaced by EKSUpstreamRefresh
-	EKSUpstreamRefresh                  = NewSetting("eks-refresh", "300")
-	GKEUpstreamRefresh                  = NewSetting("gke-refresh", "300")
-	HideLocalCluster                    = NewSetting("hide-local-cluster", "false")
+	EKSUpstreamRefresh                  = NewSetting("eks-refresh", "300").WithMin(1)
+	GKEUpstreamRefresh                  = NewSetting("gke-refresh", "300").WithMin(1)
+	HideLocalCluster                    = NewSetting("hide-local-cluster", "false").WithType(TypeBool)
 	MachineProvisionImage               = NewSetting("machine-provision-image", "rancher/machine:v0.15.0-rancher131")
-	SystemFeatureChartRefreshSeconds    = NewSetting("system-feature-chart-refresh-seconds", "21600")
+	EtcdSnapshotVerificationImage       = NewSetting("etcd-snapshot-verification-image", "rancher/mirrored-coreos-etcd:v3.5.21")
+	SystemFeatureChartRefreshSeconds    = NewSetting("system-feature-chart-refresh-seconds", "21600").WithMin(1)
 	ClusterAgentDefaultAffinity         = NewSetting("cluster-agent-default-affinity", ClusterAgentAffinity)
 	FleetAgentDefaultAffinity           = NewSetting("fleet-agent-default-affinity", FleetAgentAffinity)
-	MaxUIPluginFileByteSize             = NewSetting("max-ui-plugin-file-byte-size", strconv.Itoa(DefaultMaxUIPluginFileSizeInBytes)) // Max file size in bytes for ui plugins
+	MaxUIPluginFileByteSize             = NewSetting("max-ui-plugin-file-byte-size", strconv.Itoa(DefaultMaxUIPluginFileSizeInBytes)).WithMin(1) // Max file size in bytes for ui plugins
 
 	ClusterAgentDefaultPriorityClass       = NewSetting("cluster-agent-default-priority-class", ClusterAgentPriorityClass)
 	ClusterAgentDefaultPodDisruptionBudget = NewSetting("cluster-agent-default-pod-disruption-budget", ClusterAgentPodDisruptionBudget)
@@ -144,17 +151,51 @@
 	// AuthTokenMaxTTLMinutes is the max allowable time to live for tokens. Excluding those created for UI sessions which is controlled by AuthUserSessionTTLMinutes.
 	AuthTokenMaxTTLMinutes = NewSetting("auth-token-max-ttl-minutes", "129600") // 90 days
 
+	// TrustedProxyCIDRs is a comma separated list of the CIDRs of the proxies in front of Rancher. The source address of
+	// a request is only taken from the X-Forwarded-For header when the request comes from one of these proxies.
+	TrustedProxyCIDRs = NewSetting("trusted-proxy-cidrs", "").WithPattern(`^[0-9a-fA-F:./]+(\s*,\s*[0-9a-fA-F:./]+)*$`)
+
 	// AuthUserInfoMaxAgeSeconds represents the maximum age of a users auth tokens before an auth provider group membership sync will be performed.
-	AuthUserInfoMaxAgeSeconds = NewSetting("auth-user-info-max-age-seconds", "3600") // 1 hour
+	AuthUserInfoMaxAgeSeconds = NewSetting("auth-user-info-max-age-seconds", "3600").WithMin(0) // 1 hour
 
 	// AuthUserSessionTTLMinutes represents the time to live for tokens used for login sessions in minutes.
-	AuthUserSessionTTLMinutes = NewSetting("auth-user-session-ttl-minutes", "960") // 16 hours
+	AuthUserSessionTTLMinutes = NewSetting("auth-user-session-ttl-minutes", "960").WithMin(0) // 16 hours
 
 	// AuthUserSessionIdleTTLMinutes represents the time to live without user activity for tokens controlling a login session, in minutes.
 	// By default, the value for auth-user-session-idle-ttl-minutes should be set
 	// to the same value as auth-user-session-ttl-minutes (for backward compatibility reasons),
 	// and it must never be greater than this value.
-	AuthUserSessionIdleTTLMinutes = NewSetting("auth-user-session-idle-ttl-minutes", "960") // 16 hours
+	AuthUserSessionIdleTTLMinutes = NewSetting("auth-user-session-idle-ttl-minutes", "960").WithMin(1) // 16 hours
+
+	// LocalAuthMFARequired controls which local users must log in with a second factor.
+	// Valid values are "none", "admins" (users bound to a global role with admin permissions, directly or through one
+	// of their groups) and "all".
+	// Users enrolled in multi-factor authentication always need a second factor. Users who must but are not enrolled
+	// can't log in, so requiring a second factor is refused while a local admin is not enrolled.
+	// Admins locked out anyway, e.g. after losing both their authenticator and their recovery codes, regain access by
+	// setting the CATTLE_LOCAL_AUTH_MFA_REQUIRED environment variable of Rancher to "none", or by setting the value of
+	// the setting to "none" with kubectl on the local cluster.
+	LocalAuthMFARequired = NewSetting("local-auth-mfa-required", MFARequiredNone).WithEnum(MFARequiredNone, MFARequiredAdmins, MFARequiredAll)
+
+	// LocalAuthFailureWindow is the window in which failed logins of local users are counted.
+	LocalAuthFailureWindow = NewSetting("local-auth-failure-window", "15m").WithDurationRange(time.Minute, 0)
+
+	// LocalAuthUserLockoutThreshold is the number of failed logins of a local user from any source within
+	// LocalAuthFailureWindow after which the user is locked out for LocalAuthLockoutDuration. It is disabled by default
+	// since anyone can then lock out any user, including the admin, by failing to log in with their username.
+	LocalAuthUserLockoutThreshold = NewSetting("local-auth-user-lockout-threshold", "0").WithMin(0)
+
+	// LocalAuthSourceLockoutThreshold is the number of failed logins from a single source address within
+	// LocalAuthFailureWindow after which the address is locked out for LocalAuthLockoutDuration. A zero value disables
+	// the lockout of source addresses.
+	LocalAuthSourceLockoutThreshold = NewSetting("local-auth-source-lockout-threshold", "50").WithMin(0)
+
+	// LocalAuthLockoutDuration is how long users and source addresses are locked out after too many failed logins.
+	LocalAuthLockoutDuration = NewSetting("local-auth-lockout-duration", "15m").WithDurationRange(time.Minute, 0)
+
+	// LocalAuthMaxFailureDelay caps the delay enforced after failed logins, which doubles with each failure starting at
+	// one second. A zero value disables the delays.
+	LocalAuthMaxFailureDelay = NewSetting("local-auth-max-failure-delay", "30s").WithDurationRange(0, 5*time.Minute)
 
 	// ChartDefaultURL represents the default URL for the system charts repo. It should only be set for test or
 	// debug purposes.
@@ -173,7 +214,7 @@
 
 	// UserRetentionDryRun determines if the user retention process should actually disable and delete users.
 	// Valid values are "true" and "false". An empty string means "false".
-	UserRetentionDryRun = NewSetting("user-retention-dry-run", "false")
+	UserRetentionDryRun = NewSetting("user-retention-dry-run", "false").WithType(TypeBool)
 
 	// UserLastLoginDefault is used if UserAttribute.LastLogin is not set.
 	// The value should be a date and time truncated to a second and formatted according to RFC3339 e.g. "2023-03-01T00:00:00Z".
@@ -184,6 +225,35 @@
 	// The value should be a valid cron expression e.g. "0 * * * *" (every hour)
 	UserRetentionCron = NewSetting("user-retention-cron", "")
 
+	// OIDCSigningKeyRotationInterval is how often the key used by the OIDC provider to sign tokens is rotated.
+	// The value should be expressed in valid time.Duration units e.g. "720h". See https://pkg.go.dev/time#ParseDuration
+	// An empty string or a zero value means the key is not rotated automatically.
+	OIDCSigningKeyRotationInterval = NewSetting("oidc-signing-key-rotation-interval", "")
+
+	// OIDCSigningKeyOverlap is how long the public key of a rotated OIDC provider signing key is still published in the JWKS endpoint.
+	// It should be greater than the longest refresh token expiration of any OIDCClient, otherwise refresh tokens signed with the old key can no longer be used.
+	// The value should be expressed in valid time.Duration units e.g. "720h". See https://pkg.go.dev/time#ParseDuration
+	OIDCSigningKeyOverlap = NewSetting("oidc-signing-key-overlap", "720h").WithDurationRange(0, 0)
+
+	// OIDCSigningKeyAlgorithm is the algorithm of the keys created by the OIDC provider to sign tokens. Valid values are "RS256" and "ES256".
+	// Changing the algorithm rotates the signing key.
+	OIDCSigningKeyAlgorithm = NewSetting("oidc-signing-key-algorithm", "RS256").WithEnum("RS256", "ES256")
+
+	// TokenUsageDormancyPeriod is how long an ext token must be unused before its next use is reported as an anomaly.
+	// The value should be expressed in valid time.Duration units e.g. "720h". See https://pkg.go.dev/time#ParseDuration
+	// An empty string or a zero value disables the check.
+	TokenUsageDormancyPeriod = NewSetting("token-usage-dormancy-period", "720h").WithDurationRange(0, 0)
+
+	// CloudCredentialValidationInterval is how often cloud credentials are validated against their provider, in addition
+	// to when their data changes.
+	// The value should be expressed in valid time.Duration units e.g. "6h". See https://pkg.go.dev/time#ParseDuration
+	// An empty string or a zero value disables the periodic validation.
+	CloudCredentialValidationInterval = NewSetting("cloud-credential-validation-interval", "6h").WithDurationRange(0, 0)
+
+	// AccessRequestMaxDuration is the longest duration access can be requested for with an AccessRequest.
+	// The value should be expressed in valid time.Duration units e.g. "8h". See https://pkg.go.dev/time#ParseDuration
+	AccessRequestMaxDuration = NewSetting("access-request-max-duration", "24h").WithDurationRange(time.Minute, 0)
+
 	// ConfigMapName name of the configmap that stores rancher configuration information.
 	// Deprecated: to be removed in 2.8.0
 	ConfigMapName = NewSetting("config-map-name", "rancher-config")
@@ -214,7 +284,7 @@
 
 	// KubeconfigGenerateToken determines whether the UI will return a generate token with kubeconfigs.
 	// If set to false the kubeconfig will contain a command to login to Rancher.
-	KubeconfigGenerateToken = NewSetting("kubeconfig-generate-token", "true")
+	KubeconfigGenerateToken = NewSetting("kubeconfig-generate-token", "true").WithType(TypeBool)
 
 	// PartnerChartDefaultBranch represents the default branch for the partner charts repo.
 	PartnerChartDefaultBranch = NewSetting("partner-chart-default-branch", "main")
@@ -241,7 +311,7 @@
 
 	// S3BucketCheckTimeout is the timeout for checking if an s3 bucket for etcd backups exists,
 	// in the go duration string format.
-	S3BucketCheckTimeout = NewSetting("s3-bucket-check-timeout", "30s")
+	S3BucketCheckTimeout = NewSetting("s3-bucket-check-timeout", "30s").WithDurationRange(time.Second, 0)
 
 	// SystemDefaultRegistry is the default container registry used for images.
 	// The environmental variable "CATTLE_BASE_REGISTRY" controls the default value of this setting.
@@ -249,11 +319,11 @@
 
 	// K3sBasedUpgraderUninstallConcurrency defines the maximum number of clusters
 	// for which Rancher can simultaneously uninstall the legacy K3s-based upgrade app.
-	K3sBasedUpgraderUninstallConcurrency = NewSetting("k3s-based-upgrader-uninstall-concurrency", "5")
+	K3sBasedUpgraderUninstallConcurrency = NewSetting("k3s-based-upgrader-uninstall-concurrency", "5").WithMin(1)
 
 	// SystemAgentUpgraderInstallConcurrency defines the maximum number of clusters
 	// for which Rancher can simultaneously install or upgrade the resources needed for upgrading system-agent.
-	SystemAgentUpgraderInstallConcurrency = NewSetting("system-agent-upgrader-install-concurrency", "5")
+	SystemAgentUpgraderInstallConcurrency = NewSetting("system-agent-upgrader-install-concurrency", "5").WithMin(1)
 
 	// UIBanners holds configuration to display a custom fixed banner in the header, footer, or both
 	UIBanners = NewSetting("ui-banners", "{}")
@@ -264,7 +334,7 @@
 
 	// UICommunityLinks displays community links in the UI.
 	// Deprecated in favour of UICustomLinks.
-	UICommunityLinks = NewSetting("ui-community-links", "true")
+	UICommunityLinks = NewSetting("ui-community-links", "true").WithType(TypeBool)
 
 	// UICustomLinks Key(display text), value(url) for user customisable links to display in homepage and support pages.
 	UICustomLinks = NewSetting("ui-custom-links", "")
@@ -279,7 +349,7 @@
 	UIDashboardHarvesterLegacyPlugin = NewSetting("ui-dashboard-harvester-legacy-plugin", "https://releases.rancher.com/harvester-ui/plugin/harvester-1.0.3-head/harvester-1.0.3-head.umd.min.js")
 
 	// UIDefaultLanding the default page users land on after login.
-	UIDefaultLanding = NewSetting("ui-default-landing", "vue")
+	UIDefaultLanding = NewSetting("ui-default-landing", "vue").WithEnum("vue", "ember")
 
 	// UIFavicon custom favicon.
 	UIFavicon = NewSetting("ui-favicon", "")
@@ -302,7 +372,7 @@
 
 	// UIOfflinePreferred controls whether UI assets are served locally by the server container ('true') or from the remote URL defined in the ui-index and ui-dashboard-index settings ('false).
 	// The `dynamic` option will use remote assets for `-head` builds, otherwise the local assets for production builds.
-	UIOfflinePreferred = NewSetting("ui-offline-preferred", "dynamic")
+	UIOfflinePreferred = NewSetting("ui-offline-preferred", "dynamic").WithEnum("dynamic", "true", "false")
 
 	// UIPath path within Rancher Manager where the old ember UI files are found.
 	UIPath = NewSetting("ui-path", "/usr/share/rancher/ui")
@@ -336,7 +406,7 @@
 	UIBannerLoginConsent = NewSetting("ui-banner-login-consent", "")
 
 	// UIPreferred Ensure that the new Dashboard is the default UI.
-	UIPreferred = NewSetting("ui-preferred", "vue")
+	UIPreferred = NewSetting("ui-preferred", "vue").WithEnum("vue", "ember")
 
 	// SkipHostedClusterChartInstallation controls whether the hosted cluster chart is installed on the server. Defaults to false.
 	// This setting is for development purposes only.
@@ -358,7 +428,7 @@
 
 	// UnprivilegedJailUser controls whether jailed commands execute under a separate (unprivileged/non-root) user
 	// account. Setting it to false is only recommended for testing and development environments.
-	UnprivilegedJailUser = NewSetting("unprivileged-jail-user", "true")
+	UnprivilegedJailUser = NewSetting("unprivileged-jail-user", "true").WithType(TypeBool)
 
 	// ImportedClusterVersionManagement enables the version management feature on imported RKE2/K3s cluster,
 	// and the local cluster if it is an RKE2/K3s cluster.
@@ -367,10 +437,30 @@
 	// changing this flag will trigger a redeployment of the cluster agent during the next reconciliation
 	// (by default every 5 minutes, or as soon as the cluster is edited, whichever comes first).
 	// Valid values: ture, false
-	ImportedClusterVersionManagement = NewSetting("imported-cluster-version-management", "true")
-
-	SQLCacheGCInterval  = NewSetting("sql-cache-gc-interval", "15m")
-	SQLCacheGCKeepCount = NewSetting("sql-cache-gc-keep-count", "1000")
+	ImportedClusterVersionManagement = NewSetting("imported-cluster-version-management", "true").WithType(TypeBool)
+
+	SQLCacheGCInterval  = NewSetting("sql-cache-gc-interval", "15m").WithDurationRange(time.Second, 0)
+	SQLCacheGCKeepCount = NewSetting("sql-cache-gc-keep-count", "1000").WithMin(0)
+
+	// The following settings limit the requests proxied to each downstream cluster through /k8s/clusters/<cluster>.
+	// Requests are classified as watches (including exec, attach and port-forward), mutating requests and reads, and
+	// each class has its own concurrency limit so that a flood of one class can't starve the others.
+	// Requests over a limit are rejected with 429 Too Many Requests. A zero value disables the respective limit.
+
+	// ClusterProxyClusterQPS is the number of reads and mutating requests per second allowed to each cluster.
+	ClusterProxyClusterQPS = NewSetting("cluster-proxy-cluster-qps", "0").WithMin(0)
+	// ClusterProxyUserQPS is the number of reads and mutating requests per second allowed to each user for each cluster.
+	ClusterProxyUserQPS = NewSetting("cluster-proxy-user-qps", "0").WithMin(0)
+	// ClusterProxyClusterMaxInflightReads is the number of concurrent reads allowed to each cluster.
+	ClusterProxyClusterMaxInflightReads = NewSetting("cluster-proxy-cluster-max-inflight-reads", "0").WithMin(0)
+	// ClusterProxyClusterMaxInflightMutating is the number of concurrent mutating requests allowed to each cluster.
+	ClusterProxyClusterMaxInflightMutating = NewSetting("cluster-proxy-cluster-max-inflight-mutating", "0").WithMin(0)
+	// ClusterProxyClusterMaxWatches is the number of concurrent watches allowed to each cluster.
+	ClusterProxyClusterMaxWatches = NewSetting("cluster-proxy-cluster-max-watches", "0").WithMin(0)
+	// ClusterProxyUserMaxInflight is the number of concurrent reads and mutating requests allowed to each user for each cluster.
+	ClusterProxyUserMaxInflight = NewSetting("cluster-proxy-user-max-inflight", "0").WithMin(0)
+	// ClusterProxyUserMaxWatches is the number of concurrent watches allowed to each user for each cluster.
+	ClusterProxyUserMaxWatches = NewSetting("cluster-proxy-user-max-watches", "0").WithMin(0)
 )
 
 // FullShellImage returns the full private registry name of the rancher shell image.
@@ -378,6 +468,11 @@ func FullShellImage() string {
 	return PrefixPrivateRegistry(ShellImage.Get())
 }
 
+// FullEtcdSnapshotVerificationImage returns the full private registry name of the etcd image verifying snapshots.
+func FullEtcdSnapshotVerificationImage() string {
+	return PrefixPrivateRegistry(EtcdSnapshotVerificationImage.Get())
+}
+
 // PrefixPrivateRegistry prefixes the given image name with the stored private registry path.
 func PrefixPrivateRegistry(image string) string {
 	private := SystemDefaultRegistry.Get()
@@ -438,10 +533,18 @@ type Setting struct {
 	// on upgraded setups but use a new value for fresh installations for backward compatibility.
 	DefaultOnUpgrade string
 	ReadOnly         bool
+	// Type is the declared type of the value. Values written through Set, SetIfUnset or the API are validated
+	// against it and against any constraints added with WithRange, WithDurationRange, WithEnum or WithPattern.
+	Type Type
+
+	constraints *constraints
 }
 
 // SetIfUnset will store the given value of the setting if it was not already stored.
 func (s Setting) SetIfUnset(value string) error {
+	if err := s.Validate(value); err != nil {
+		return err
+	}
 	if provider == nil {
 		return s.Set(value)
 	}
@@ -450,6 +553,9 @@ func (s Setting) SetIfUnset(value string) error {
 
 // Set will store the given value for the setting
 func (s Setting) Set(value string) error {
+	if err := s.Validate(value); err != nil {
+		return err
+	}
 	if provider == nil {
 		s, ok := settings[s.Name]
 		if ok {
@@ -472,11 +578,14 @@ func (s Setting) Get() string {
 }
 
 // GetDuration will return the currently stored value of the setting as a time.Duration.
-// If the stored value is not a duration then the default value will be returned as a duration.
-// If the default value is not a duration then the function will return 0
+// If the stored value is not a duration or violates the setting's constraints then the default value will be returned
+// as a duration. If the default value is not a duration then the function will return 0
 func (s Setting) GetDuration() time.Duration {
 	v := s.Get()
 	dur, err := time.ParseDuration(v)
+	if err == nil {
+		err = s.Validate(v)
+	}
 	if err == nil {
 		return dur
 	}
@@ -489,11 +598,14 @@ func (s Setting) GetDuration() time.Duration {
 }
 
 // GetInt will return the currently stored value of the setting as an integer.
-// If the stored value is not an integer then the default value will be returned as an integer.
-// If the default value is not an integer then the function will return 0
+// If the stored value is not an integer or violates the setting's constraints then the default value will be returned
+// as an integer. If the default value is not an integer then the function will return 0
 func (s Setting) GetInt() int {
 	v := s.Get()
 	i, err := strconv.Atoi(v)
+	if err == nil {
+		err = s.Validate(v)
+	}
 	if err == nil {
 		return i
 	}
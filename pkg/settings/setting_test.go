@@ -6,6 +6,7 @@
 	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -186,3 +187,111 @@ func TestGetRancherVersion(t *testing.T) {
 		assert.Equal(t, value, result)
 	}
 }
+
+func TestDefaultsAreValid(t *testing.T) {
+	for name, s := range settings {
+		assert.NoError(t, s.Validate(s.Default), "default of setting %s", name)
+		if s.DefaultOnUpgrade != "" {
+			assert.NoError(t, s.Validate(s.DefaultOnUpgrade), "default on upgrade of setting %s", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	intSetting := Setting{Name: "int"}.WithRange(1, 10)
+	boolSetting := Setting{Name: "bool"}.WithType(TypeBool)
+	durationSetting := Setting{Name: "duration"}.WithDurationRange(time.Second, time.Hour)
+	enumSetting := Setting{Name: "enum"}.WithEnum("a", "b")
+	patternSetting := Setting{Name: "pattern"}.WithPattern(`^[a-z]+$`)
+	t.Cleanup(func() {
+		for _, name := range []string{"int", "bool", "duration", "enum", "pattern"} {
+			delete(settings, name)
+		}
+	})
+
+	tests := []struct {
+		setting Setting
+		value   string
+		wantErr bool
+	}{
+		{setting: intSetting, value: ""},
+		{setting: intSetting, value: "1"},
+		{setting: intSetting, value: "10"},
+		{setting: intSetting, value: "0", wantErr: true},
+		{setting: intSetting, value: "11", wantErr: true},
+		{setting: intSetting, value: "one", wantErr: true},
+		{setting: boolSetting, value: "true"},
+		{setting: boolSetting, value: "false"},
+		{setting: boolSetting, value: "yes", wantErr: true},
+		{setting: durationSetting, value: "1s"},
+		{setting: durationSetting, value: "30m"},
+		{setting: durationSetting, value: "500ms", wantErr: true},
+		{setting: durationSetting, value: "2h", wantErr: true},
+		{setting: durationSetting, value: "10", wantErr: true},
+		{setting: enumSetting, value: "a"},
+		{setting: enumSetting, value: "c", wantErr: true},
+		{setting: patternSetting, value: "abc"},
+		{setting: patternSetting, value: "ABC", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := tt.setting.Validate(tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, "setting %s value %q", tt.setting.Name, tt.value)
+		} else {
+			assert.NoError(t, err, "setting %s value %q", tt.setting.Name, tt.value)
+		}
+	}
+}
+
+func TestSetRejectsInvalidValues(t *testing.T) {
+	s := NewSetting("test-set-rejects", "5").WithRange(1, 10)
+	t.Cleanup(func() { delete(settings, s.Name) })
+
+	assert.Error(t, s.Set("20"))
+	assert.Equal(t, "5", s.Get())
+	assert.NoError(t, s.Set("7"))
+	assert.Equal(t, 7, s.GetInt())
+}
+
+func TestSetValidator(t *testing.T) {
+	s := NewSetting("test-set-validator", "a")
+	SetValidator(s.Name, func(value string) error {
+		if value == "b" {
+			return fmt.Errorf("b is not allowed")
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		delete(settings, s.Name)
+		delete(validators, s.Name)
+	})
+
+	assert.Error(t, Validate(s.Name, "b"))
+	assert.NoError(t, Validate(s.Name, "c"))
+}
+
+func TestAppendRevision(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+
+	history := AppendRevision(nil, "a", "b", "admin", now)
+	assert.Len(t, history, 1)
+	assert.Equal(t, int64(1), history[0].Revision)
+	assert.Equal(t, "admin", history[0].User)
+
+	history = AppendRevision(history, "b", "b", "admin", now)
+	assert.Len(t, history, 1, "unchanged values are not recorded")
+
+	for i := 0; i < MaxHistory+5; i++ {
+		history = AppendRevision(history, fmt.Sprint(i), fmt.Sprint(i+1), "", now)
+	}
+	assert.Len(t, history, MaxHistory)
+	assert.Equal(t, int64(MaxHistory+6), history[len(history)-1].Revision)
+	assert.Equal(t, int64(7), history[0].Revision)
+
+	r, ok := FindRevision(history, 10)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), r.Revision)
+	_, ok = FindRevision(history, 1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,192 @@
+package settings
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the declared type of a setting's value.
+type Type string
+
+const (
+	// TypeString is the type of settings whose value is an arbitrary string. This is the default.
+	TypeString Type = "string"
+	// TypeInt is the type of settings whose value is a base 10 integer.
+	TypeInt Type = "int"
+	// TypeBool is the type of settings whose value is either "true" or "false".
+	TypeBool Type = "bool"
+	// TypeDuration is the type of settings whose value is a duration as parsed by time.ParseDuration.
+	TypeDuration Type = "duration"
+)
+
+// constraints holds the restrictions a value must satisfy to be stored for a setting.
+// It is kept behind a pointer so that Setting stays comparable.
+type constraints struct {
+	min, max       *int64
+	minDur, maxDur *time.Duration
+	enum           []string
+	pattern        *regexp.Regexp
+}
+
+// GetType returns the declared type of the setting, defaulting to TypeString.
+func (s Setting) GetType() Type {
+	if s.Type == "" {
+		return TypeString
+	}
+	return s.Type
+}
+
+// WithType takes a setting and returns a new setting with the given type.
+func (s Setting) WithType(t Type) Setting {
+	s.Type = t
+	settings[s.Name] = s
+	return s
+}
+
+// WithRange takes a setting and returns a new int setting which only accepts values between min and max inclusive.
+func (s Setting) WithRange(min, max int64) Setting {
+	c := s.copyConstraints()
+	c.min, c.max = &min, &max
+	s.Type = TypeInt
+	s.constraints = c
+	settings[s.Name] = s
+	return s
+}
+
+// WithMin takes a setting and returns a new int setting which only accepts values greater than or equal to min.
+func (s Setting) WithMin(min int64) Setting {
+	c := s.copyConstraints()
+	c.min = &min
+	s.Type = TypeInt
+	s.constraints = c
+	settings[s.Name] = s
+	return s
+}
+
+// WithDurationRange takes a setting and returns a new duration setting which only accepts durations between min and
+// max inclusive. A zero max leaves the duration unbounded from above.
+func (s Setting) WithDurationRange(min, max time.Duration) Setting {
+	c := s.copyConstraints()
+	c.minDur = &min
+	if max > 0 {
+		c.maxDur = &max
+	}
+	s.Type = TypeDuration
+	s.constraints = c
+	settings[s.Name] = s
+	return s
+}
+
+// WithEnum takes a setting and returns a new setting which only accepts one of the given values.
+func (s Setting) WithEnum(values ...string) Setting {
+	c := s.copyConstraints()
+	c.enum = values
+	s.constraints = c
+	settings[s.Name] = s
+	return s
+}
+
+// WithPattern takes a setting and returns a new setting whose values must match the given regular expression.
+func (s Setting) WithPattern(pattern string) Setting {
+	c := s.copyConstraints()
+	c.pattern = regexp.MustCompile(pattern)
+	s.constraints = c
+	settings[s.Name] = s
+	return s
+}
+
+func (s Setting) copyConstraints() *constraints {
+	if s.constraints == nil {
+		return &constraints{}
+	}
+	c := *s.constraints
+	return &c
+}
+
+// Validate checks that the given value satisfies the declared type and constraints of the setting.
+// An empty value is always valid as it means the default value is used.
+func (s Setting) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch s.GetType() {
+	case TypeInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("setting %s: value %q is not an integer", s.Name, value)
+		}
+		if c := s.constraints; c != nil {
+			if c.min != nil && i < *c.min {
+				return fmt.Errorf("setting %s: value %d is less than the minimum %d", s.Name, i, *c.min)
+			}
+			if c.max != nil && i > *c.max {
+				return fmt.Errorf("setting %s: value %d is greater than the maximum %d", s.Name, i, *c.max)
+			}
+		}
+	case TypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("setting %s: value %q must be either true or false", s.Name, value)
+		}
+	case TypeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("setting %s: value %q is not a duration", s.Name, value)
+		}
+		if c := s.constraints; c != nil {
+			if c.minDur != nil && d < *c.minDur {
+				return fmt.Errorf("setting %s: value %s is less than the minimum %s", s.Name, d, *c.minDur)
+			}
+			if c.maxDur != nil && d > *c.maxDur {
+				return fmt.Errorf("setting %s: value %s is greater than the maximum %s", s.Name, d, *c.maxDur)
+			}
+		}
+	}
+
+	if c := s.constraints; c != nil {
+		if len(c.enum) > 0 && !slices.Contains(c.enum, value) {
+			return fmt.Errorf("setting %s: value %q must be one of [%s]", s.Name, value, strings.Join(c.enum, ", "))
+		}
+		if c.pattern != nil && !c.pattern.MatchString(value) {
+			return fmt.Errorf("setting %s: value %q does not match %s", s.Name, value, c.pattern)
+		}
+	}
+
+	return nil
+}
+
+// validators are the additional checks of settings which depend on state only known at runtime, like the users of
+// Rancher.
+var validators = map[string]func(value string) error{}
+
+// SetValidator sets an additional check the values of the setting with the given name must pass to be stored. It is
+// meant for checks which depend on state only known at runtime and must be set before the API is served.
+func SetValidator(name string, validator func(value string) error) {
+	validators[name] = validator
+}
+
+// GetSetting returns the registered setting with the given name.
+func GetSetting(name string) (Setting, bool) {
+	s, ok := settings[name]
+	return s, ok
+}
+
+// Validate checks the given value against the declared type and constraints of the setting with the given name, and
+// against the validator set for it, if any. Unknown settings are not validated.
+func Validate(name, value string) error {
+	s, ok := settings[name]
+	if !ok {
+		return nil
+	}
+	if err := s.Validate(value); err != nil {
+		return err
+	}
+	if validator := validators[name]; validator != nil {
+		return validator(value)
+	}
+	return nil
+}
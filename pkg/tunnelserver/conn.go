@@ -0,0 +1,85 @@
+package tunnelserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/remotedialer"
+)
+
+// Dialer returns the dialer of the tunnel session with the given client key, which counts the connections proxied
+// through the session as requests in flight.
+func Dialer(server *remotedialer.Server, clientKey string) remotedialer.Dialer {
+	dialer := server.Dialer(clientKey)
+	if !prometheusMetrics {
+		return dialer
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		inFlight := sessionRequestsInFlight.WithLabelValues(clusterName(clientKey), clientKey)
+		inFlight.Inc()
+		return &inFlightConn{Conn: conn, inFlight: inFlight}, nil
+	}
+}
+
+// inFlightConn is a connection proxied through a tunnel session, which is no longer in flight once closed.
+type inFlightConn struct {
+	net.Conn
+	inFlight prometheus.Gauge
+	once     sync.Once
+}
+
+func (c *inFlightConn) Close() error {
+	c.once.Do(c.inFlight.Dec)
+	return c.Conn.Close()
+}
+
+// countSessionBytes wraps the connection of the tunnel session with the given client key, so the bytes it transmits
+// and receives are counted. The buffered reader is wrapped as well, since the websocket reads through it.
+func countSessionBytes(clientKey string, conn net.Conn, brw *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter) {
+	if !prometheusMetrics {
+		return conn, brw
+	}
+	received := sessionReceiveBytes.WithLabelValues(clusterName(clientKey), clientKey)
+	transmitted := sessionTransmitBytes.WithLabelValues(clusterName(clientKey), clientKey)
+	if brw != nil {
+		brw = bufio.NewReadWriter(bufio.NewReaderSize(&countingReader{Reader: brw.Reader, counter: received}, brw.Reader.Size()), brw.Writer)
+	}
+	return &countingConn{Conn: conn, received: received, transmitted: transmitted}, brw
+}
+
+type countingReader struct {
+	io.Reader
+	counter prometheus.Counter
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.counter.Add(float64(n))
+	return n, err
+}
+
+type countingConn struct {
+	net.Conn
+	received    prometheus.Counter
+	transmitted prometheus.Counter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.received.Add(float64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.transmitted.Add(float64(n))
+	return n, err
+}
@@ -0,0 +1,96 @@
+package tunnelserver
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	prometheusMetrics = false
+
+	sessionsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_sessions",
+			Help:      "Number of agent tunnel sessions held by this Rancher replica",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	sessionConnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_session_connects_total",
+			Help:      "Number of agent tunnel sessions opened to this Rancher replica",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	sessionConnectedTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_session_connected_timestamp_seconds",
+			Help:      "Unix time the agent tunnel session held by this Rancher replica was opened",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	sessionTransmitBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_session_transmit_bytes_total",
+			Help:      "Bytes sent to the agent through its tunnel session held by this Rancher replica",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	sessionReceiveBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_session_receive_bytes_total",
+			Help:      "Bytes received from the agent through its tunnel session held by this Rancher replica",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	sessionRequestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_session_requests_in_flight",
+			Help:      "Number of connections currently proxied through the agent tunnel session held by this Rancher replica",
+		},
+		[]string{"cluster", "clientkey"},
+	)
+	pingLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "tunnel_server",
+			Name:      "agent_ping_latency_seconds",
+			Help:      "Round-trip time of the last ping to the cluster agent through its tunnel session",
+		},
+		[]string{"cluster"},
+	)
+)
+
+// RegisterMetrics registers the tunnel session metrics.
+func RegisterMetrics() {
+	prometheusMetrics = true
+	prometheus.MustRegister(sessionsActive, sessionConnects, sessionConnectedTime, sessionTransmitBytes,
+		sessionReceiveBytes, sessionRequestsInFlight, pingLatency)
+}
+
+func sessionConnectedMetrics(cluster, clientKey string) {
+	if prometheusMetrics {
+		sessionsActive.WithLabelValues(cluster, clientKey).Inc()
+		sessionConnects.WithLabelValues(cluster, clientKey).Inc()
+		sessionConnectedTime.WithLabelValues(cluster, clientKey).SetToCurrentTime()
+	}
+}
+
+func sessionDisconnectedMetrics(cluster, clientKey string) {
+	if prometheusMetrics {
+		sessionsActive.WithLabelValues(cluster, clientKey).Dec()
+	}
+}
+
+// ObservePingLatency records the round-trip time of a ping to the agent of the given cluster.
+func ObservePingLatency(cluster string, latency time.Duration) {
+	if prometheusMetrics {
+		pingLatency.WithLabelValues(cluster).Set(latency.Seconds())
+	}
+}
@@ -0,0 +1,476 @@
+package tunnelserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/remotedialer"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// FlapWindow is the window in which reconnects are counted to detect flapping sessions.
+	FlapWindow = 10 * time.Minute
+	// FlapThreshold is the number of reconnects within FlapWindow at which a session is considered flapping.
+	FlapThreshold = 3
+	// SessionRetention is how long closed sessions are kept in the status of their cluster.
+	SessionRetention = 24 * time.Hour
+
+	// steveAggregationPrefix prefixes the client keys of the sessions opened by the cluster agent for steve aggregation.
+	steveAggregationPrefix = "stv-cluster-"
+
+	sessionEventsBuffer = 1000
+	// resyncInterval is the interval at which the sessions whose events were dropped or failed to be recorded are
+	// recorded again from the sessions held by this replica.
+	resyncInterval = time.Minute
+	// shutdownTimeout bounds recording the sessions closed by the shutdown of this replica.
+	shutdownTimeout = 10 * time.Second
+)
+
+type sessionEventType int
+
+const (
+	sessionConnected sessionEventType = iota
+	sessionDisconnected
+	// sessionResync sets the session to its state on this replica without counting a reconnect.
+	sessionResync
+)
+
+type sessionEvent struct {
+	eventType     sessionEventType
+	clientKey     string
+	remoteAddress string
+	time          time.Time
+	// active is whether the session is held by this replica, for resync events.
+	active bool
+	// shutdown is whether the session was closed by the shutdown of this replica, for disconnect events.
+	shutdown bool
+}
+
+// activeSession is a session held by this replica.
+type activeSession struct {
+	remoteAddress string
+	connectedAt   time.Time
+}
+
+type clientKeyHolder struct {
+	key string
+}
+
+type clientKeyHolderKey struct{}
+
+// SessionTracker records the tunnel sessions opened by agents to this Rancher replica in the status of their clusters,
+// sets the AgentConnectionStable condition of clusters with flapping sessions and exports session metrics.
+type SessionTracker struct {
+	clusters managementcontrollers.ClusterClient
+	peerID   func() string
+	now      func() time.Time
+	events   chan sessionEvent
+
+	lock sync.Mutex
+	// active are the sessions held by this replica by client key.
+	active map[string]*activeSession
+	// dirty are the client keys of sessions whose events were dropped or failed to be recorded.
+	dirty map[string]bool
+	// shuttingDown is set once this replica shuts down, sessions closed afterward are recorded by shutdown.
+	shuttingDown bool
+}
+
+// NewSessionTracker creates a SessionTracker which records sessions until ctx is done.
+// The peerID function returns the ID of this replica.
+func NewSessionTracker(ctx context.Context, clusters managementcontrollers.ClusterClient, peerID func() string) *SessionTracker {
+	t := &SessionTracker{
+		clusters: clusters,
+		peerID:   peerID,
+		now:      time.Now,
+		events:   make(chan sessionEvent, sessionEventsBuffer),
+		active:   map[string]*activeSession{},
+		dirty:    map[string]bool{},
+	}
+	go t.run(ctx)
+	return t
+}
+
+// Authorize wraps a tunnel authorizer so that the client key of authorized sessions is known to Handler.
+func (t *SessionTracker) Authorize(authorizer remotedialer.Authorizer) remotedialer.Authorizer {
+	return func(req *http.Request) (string, bool, error) {
+		clientKey, authed, err := authorizer(req)
+		if holder, ok := req.Context().Value(clientKeyHolderKey{}).(*clientKeyHolder); ok && authed && err == nil {
+			holder.key = clientKey
+		}
+		return clientKey, authed, err
+	}
+}
+
+// Handler wraps the tunnel server handler to track the sessions it serves. A session starts when the connection is
+// upgraded to a websocket and ends when the handler returns.
+func (t *SessionTracker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		holder := &clientKeyHolder{}
+		req = req.WithContext(context.WithValue(req.Context(), clientKeyHolderKey{}, holder))
+		var session *activeSession
+		hijacker := &hijackResponseWriter{
+			ResponseWriter: rw,
+			onHijack: func(conn net.Conn, brw *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter) {
+				if holder.key == "" {
+					return conn, brw
+				}
+				session = t.connected(holder.key, req)
+				return countSessionBytes(holder.key, conn, brw)
+			},
+		}
+
+		next.ServeHTTP(hijacker, req)
+		if session == nil {
+			return
+		}
+		t.disconnected(holder.key, session)
+	})
+}
+
+// connected is called once a session is established.
+func (t *SessionTracker) connected(clientKey string, req *http.Request) *activeSession {
+	event := sessionEvent{
+		eventType:     sessionConnected,
+		clientKey:     clientKey,
+		remoteAddress: remoteAddress(req),
+		time:          t.now(),
+	}
+	session := &activeSession{remoteAddress: event.remoteAddress, connectedAt: event.time}
+	t.lock.Lock()
+	t.active[clientKey] = session
+	t.lock.Unlock()
+	t.enqueue(event)
+	sessionConnectedMetrics(clusterName(clientKey), clientKey)
+	return session
+}
+
+// disconnected is called once a session is closed. Sessions closed after this replica started shutting down were
+// already recorded as closed by the shutdown.
+func (t *SessionTracker) disconnected(clientKey string, session *activeSession) {
+	t.lock.Lock()
+	// The agent may have opened a new session before the old one was closed.
+	if t.active[clientKey] == session {
+		delete(t.active, clientKey)
+	}
+	shuttingDown := t.shuttingDown
+	t.lock.Unlock()
+	if !shuttingDown {
+		t.enqueue(sessionEvent{
+			eventType: sessionDisconnected,
+			clientKey: clientKey,
+			time:      t.now(),
+		})
+	}
+	sessionDisconnectedMetrics(clusterName(clientKey), clientKey)
+}
+
+// enqueue queues the event to be recorded. If too many events are pending, the event is dropped and the session is
+// resynced later instead, so it isn't left active.
+func (t *SessionTracker) enqueue(event sessionEvent) {
+	select {
+	case t.events <- event:
+	default:
+		logrus.Warnf("[tunnel-sessions] Dropping session event for %s, too many pending events", event.clientKey)
+		t.markDirty(event.clientKey)
+	}
+}
+
+func (t *SessionTracker) markDirty(clientKey string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.dirty[clientKey] = true
+}
+
+func (t *SessionTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			t.shutdown()
+			return
+		case <-ticker.C:
+			t.resync()
+		case event := <-t.events:
+			if err := t.record(event); err != nil {
+				logrus.Errorf("[tunnel-sessions] Failed to record session %s: %v", event.clientKey, err)
+				t.markDirty(event.clientKey)
+			}
+		}
+	}
+}
+
+// resync records the sessions whose events were dropped or failed to be recorded with their state on this replica.
+func (t *SessionTracker) resync() {
+	t.lock.Lock()
+	var events []sessionEvent
+	for clientKey := range t.dirty {
+		event := sessionEvent{
+			eventType: sessionResync,
+			clientKey: clientKey,
+		}
+		if session, ok := t.active[clientKey]; ok {
+			event.remoteAddress = session.remoteAddress
+			event.time = session.connectedAt
+			event.active = true
+		}
+		events = append(events, event)
+	}
+	t.dirty = map[string]bool{}
+	t.lock.Unlock()
+
+	for _, event := range events {
+		if !event.active {
+			event.time = t.now()
+		}
+		if err := t.record(event); err != nil {
+			logrus.Errorf("[tunnel-sessions] Failed to resync session %s: %v", event.clientKey, err)
+			t.markDirty(event.clientKey)
+		}
+	}
+}
+
+// shutdown records the sessions held by this replica as closed by its shutdown, so the reconnects of their agents to
+// other replicas, e.g. during a rolling upgrade of Rancher, are not taken for flapping.
+func (t *SessionTracker) shutdown() {
+	t.lock.Lock()
+	t.shuttingDown = true
+	var clientKeys []string
+	for clientKey := range t.active {
+		clientKeys = append(clientKeys, clientKey)
+	}
+	t.lock.Unlock()
+
+	deadline := t.now().Add(shutdownTimeout)
+	for _, clientKey := range clientKeys {
+		if t.now().After(deadline) {
+			logrus.Warnf("[tunnel-sessions] Timed out recording the sessions closed by shutdown")
+			return
+		}
+		if err := t.record(sessionEvent{
+			eventType: sessionDisconnected,
+			clientKey: clientKey,
+			time:      t.now(),
+			shutdown:  true,
+		}); err != nil {
+			logrus.Errorf("[tunnel-sessions] Failed to record session %s: %v", clientKey, err)
+		}
+	}
+}
+
+// record updates the status of the cluster of the session with the event.
+func (t *SessionTracker) record(event sessionEvent) error {
+	name := clusterName(event.clientKey)
+	for i := 0; i < 3; i++ {
+		cluster, err := t.clusters.Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			// Not a cluster agent session, e.g. an aggregated API service.
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		cluster = cluster.DeepCopy()
+		if !applySessionEvent(cluster, event, t.peerID()) {
+			return nil
+		}
+		_, err = t.clusters.Update(cluster)
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("unable to update cluster %s", name)
+}
+
+// applySessionEvent updates the agent sessions and the AgentConnectionStable condition in the status of the cluster. It
+// returns whether the cluster was changed.
+func applySessionEvent(cluster *v3.Cluster, event sessionEvent, peer string) bool {
+	index := -1
+	for i, session := range cluster.Status.AgentSessions {
+		if session.ClientKey == event.clientKey {
+			index = i
+			break
+		}
+	}
+
+	switch event.eventType {
+	case sessionConnected:
+		session := v3.ClusterAgentSession{
+			ClientKey:             event.clientKey,
+			RecentReconnectsSince: metav1.NewTime(event.time),
+		}
+		if index >= 0 {
+			session = cluster.Status.AgentSessions[index]
+			session.Reconnects++
+			// Reconnects after a replica shut down are expected, they are not counted towards flapping.
+			if !session.ClosedByReplicaShutdown {
+				if event.time.Sub(session.RecentReconnectsSince.Time) > FlapWindow {
+					session.RecentReconnects = 0
+					session.RecentAddressChanges = 0
+					session.RecentReconnectsSince = metav1.NewTime(event.time)
+				}
+				session.RecentReconnects++
+				if session.RemoteAddress != event.remoteAddress {
+					session.RecentAddressChanges++
+				}
+			}
+		}
+		session.Peer = peer
+		session.RemoteAddress = event.remoteAddress
+		session.ConnectedAt = metav1.NewTime(event.time)
+		session.DisconnectedAt = nil
+		session.ClosedByReplicaShutdown = false
+
+		if index >= 0 {
+			cluster.Status.AgentSessions[index] = session
+		} else {
+			cluster.Status.AgentSessions = append(cluster.Status.AgentSessions, session)
+		}
+		if session.RecentReconnects >= FlapThreshold {
+			v3.ClusterConditionAgentConnectionStable.False(cluster)
+			v3.ClusterConditionAgentConnectionStable.Reason(cluster, "Flapping")
+			v3.ClusterConditionAgentConnectionStable.Message(cluster, flappingMessage(session))
+		}
+		return true
+	case sessionDisconnected:
+		if index < 0 {
+			return false
+		}
+		session := &cluster.Status.AgentSessions[index]
+		// Only the replica holding the session closes it, the agent may already be connected to another replica.
+		if session.Peer != peer || session.DisconnectedAt != nil || session.ConnectedAt.After(event.time) {
+			return false
+		}
+		disconnectedAt := metav1.NewTime(event.time)
+		session.DisconnectedAt = &disconnectedAt
+		session.ClosedByReplicaShutdown = event.shutdown
+		return true
+	case sessionResync:
+		if !event.active {
+			return applySessionEvent(cluster, sessionEvent{
+				eventType: sessionDisconnected,
+				clientKey: event.clientKey,
+				time:      event.time,
+			}, peer)
+		}
+		if index < 0 {
+			cluster.Status.AgentSessions = append(cluster.Status.AgentSessions, v3.ClusterAgentSession{
+				ClientKey:             event.clientKey,
+				RecentReconnectsSince: metav1.NewTime(event.time),
+			})
+			index = len(cluster.Status.AgentSessions) - 1
+		}
+		session := &cluster.Status.AgentSessions[index]
+		// The agent may have connected to another replica since.
+		if session.ConnectedAt.After(event.time) {
+			return false
+		}
+		if session.Peer == peer && session.RemoteAddress == event.remoteAddress &&
+			session.ConnectedAt.Equal(&metav1.Time{Time: event.time}) && session.DisconnectedAt == nil {
+			return false
+		}
+		session.Peer = peer
+		session.RemoteAddress = event.remoteAddress
+		session.ConnectedAt = metav1.NewTime(event.time)
+		session.DisconnectedAt = nil
+		session.ClosedByReplicaShutdown = false
+		return true
+	}
+	return false
+}
+
+// ResolveSessions drops the agent sessions of the cluster closed for longer than SessionRetention and marks the
+// AgentConnectionStable condition True once none of its sessions flapped within FlapWindow. It returns whether the
+// cluster was changed.
+func ResolveSessions(cluster *v3.Cluster, now time.Time) bool {
+	changed := false
+	sessions := cluster.Status.AgentSessions[:0:0]
+	flapping := false
+	for _, session := range cluster.Status.AgentSessions {
+		if session.DisconnectedAt != nil && now.Sub(session.DisconnectedAt.Time) > SessionRetention {
+			changed = true
+			continue
+		}
+		if session.RecentReconnects >= FlapThreshold && now.Sub(session.RecentReconnectsSince.Time) <= FlapWindow {
+			flapping = true
+		}
+		sessions = append(sessions, session)
+	}
+	if changed {
+		cluster.Status.AgentSessions = sessions
+	}
+
+	if !flapping && len(cluster.Status.AgentSessions) > 0 && !v3.ClusterConditionAgentConnectionStable.IsTrue(cluster) {
+		v3.ClusterConditionAgentConnectionStable.True(cluster)
+		v3.ClusterConditionAgentConnectionStable.Reason(cluster, "")
+		v3.ClusterConditionAgentConnectionStable.Message(cluster, "")
+		changed = true
+	}
+	return changed
+}
+
+func flappingMessage(session v3.ClusterAgentSession) string {
+	cause := "the agent kept its address, this usually points at the network between the agent and Rancher"
+	if session.RecentAddressChanges > 0 {
+		cause = fmt.Sprintf("%d reconnects came from a new address, this usually means the agent is restarting", session.RecentAddressChanges)
+	}
+	return fmt.Sprintf("agent session %s reconnected %d times since %s: %s", session.ClientKey, session.RecentReconnects,
+		session.RecentReconnectsSince.UTC().Format(time.RFC3339), cause)
+}
+
+// clusterName returns the name of the cluster of the agent which opened the session with the given client key.
+func clusterName(clientKey string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(clientKey, steveAggregationPrefix), ":")
+	return name
+}
+
+// remoteAddress returns the address the agent connected from. X-Forwarded-For is only honored from trusted proxies.
+func remoteAddress(req *http.Request) string {
+	return scope.SourceIP(req)
+}
+
+// PeerID returns the ID of this replica in the tunnel server, or its hostname when not running in clustered mode.
+func PeerID(server *remotedialer.Server) string {
+	if server != nil && server.PeerID != "" {
+		return server.PeerID
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// hijackResponseWriter records whether the connection was hijacked for the websocket upgrade, and lets onHijack wrap
+// the hijacked connection.
+type hijackResponseWriter struct {
+	http.ResponseWriter
+	hijacked bool
+	onHijack func(conn net.Conn, brw *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter)
+}
+
+func (h *hijackResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		h.hijacked = true
+		if h.onHijack != nil {
+			conn, rw = h.onHijack(conn, rw)
+		}
+	}
+	return conn, rw, err
+}
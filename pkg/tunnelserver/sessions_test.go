@@ -0,0 +1,249 @@
+package tunnelserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func connect(cluster *v3.Cluster, address string, at time.Time, peer string) bool {
+	return applySessionEvent(cluster, sessionEvent{
+		eventType:     sessionConnected,
+		clientKey:     "stv-cluster-c-1",
+		remoteAddress: address,
+		time:          at,
+	}, peer)
+}
+
+func disconnect(cluster *v3.Cluster, at time.Time, peer string) bool {
+	return applySessionEvent(cluster, sessionEvent{
+		eventType: sessionDisconnected,
+		clientKey: "stv-cluster-c-1",
+		time:      at,
+	}, peer)
+}
+
+func TestApplySessionEvent(t *testing.T) {
+	cluster := &v3.Cluster{}
+
+	require.True(t, connect(cluster, "10.0.0.1", testTime, "peer-a"))
+	require.Len(t, cluster.Status.AgentSessions, 1)
+	session := cluster.Status.AgentSessions[0]
+	assert.Equal(t, "peer-a", session.Peer)
+	assert.Equal(t, "10.0.0.1", session.RemoteAddress)
+	assert.Equal(t, int64(0), session.Reconnects)
+	assert.Nil(t, session.DisconnectedAt)
+
+	// The agent already reconnected to another replica, the old replica must not close the new session.
+	require.True(t, connect(cluster, "10.0.0.1", testTime.Add(time.Minute), "peer-b"))
+	assert.False(t, disconnect(cluster, testTime.Add(time.Minute), "peer-a"))
+	assert.Nil(t, cluster.Status.AgentSessions[0].DisconnectedAt)
+
+	require.True(t, disconnect(cluster, testTime.Add(2*time.Minute), "peer-b"))
+	require.NotNil(t, cluster.Status.AgentSessions[0].DisconnectedAt)
+	assert.False(t, disconnect(cluster, testTime.Add(3*time.Minute), "peer-b"), "session is already closed")
+
+	session = cluster.Status.AgentSessions[0]
+	assert.Equal(t, "peer-b", session.Peer)
+	assert.Equal(t, int64(1), session.Reconnects)
+	assert.Equal(t, int64(1), session.RecentReconnects)
+	assert.Empty(t, v3.ClusterConditionAgentConnectionStable.GetStatus(cluster))
+}
+
+func TestApplySessionEventFlapping(t *testing.T) {
+	tests := map[string]struct {
+		addresses   []string
+		wantMessage string
+	}{
+		"network": {
+			addresses:   []string{"10.0.0.1", "10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			wantMessage: "the network",
+		},
+		"agent restarts": {
+			addresses:   []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+			wantMessage: "3 reconnects came from a new address",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cluster := &v3.Cluster{}
+			for i, address := range tt.addresses {
+				require.True(t, connect(cluster, address, testTime.Add(time.Duration(i)*time.Minute), "peer-a"))
+			}
+			assert.True(t, v3.ClusterConditionAgentConnectionStable.IsFalse(cluster))
+			assert.Equal(t, "Flapping", v3.ClusterConditionAgentConnectionStable.GetReason(cluster))
+			assert.Contains(t, v3.ClusterConditionAgentConnectionStable.GetMessage(cluster), tt.wantMessage)
+		})
+	}
+}
+
+func TestApplySessionEventWindow(t *testing.T) {
+	cluster := &v3.Cluster{}
+	connect(cluster, "10.0.0.1", testTime, "peer-a")
+	connect(cluster, "10.0.0.1", testTime.Add(time.Minute), "peer-a")
+	connect(cluster, "10.0.0.1", testTime.Add(time.Minute+FlapWindow+time.Second), "peer-a")
+	connect(cluster, "10.0.0.1", testTime.Add(time.Minute+FlapWindow+2*time.Second), "peer-a")
+
+	session := cluster.Status.AgentSessions[0]
+	assert.Equal(t, int64(3), session.Reconnects)
+	assert.Equal(t, int64(2), session.RecentReconnects)
+	assert.Equal(t, testTime.Add(time.Minute+FlapWindow+time.Second), session.RecentReconnectsSince.Time)
+	assert.Empty(t, v3.ClusterConditionAgentConnectionStable.GetStatus(cluster))
+}
+
+func TestResolveSessions(t *testing.T) {
+	cluster := &v3.Cluster{}
+	for i := 0; i < 4; i++ {
+		connect(cluster, "10.0.0.1", testTime.Add(time.Duration(i)*time.Minute), "peer-a")
+	}
+	closed := metav1.NewTime(testTime)
+	cluster.Status.AgentSessions = append(cluster.Status.AgentSessions, v3.ClusterAgentSession{
+		ClientKey:      "stv-cluster-c-1:other",
+		DisconnectedAt: &closed,
+	})
+	require.True(t, v3.ClusterConditionAgentConnectionStable.IsFalse(cluster))
+
+	assert.False(t, ResolveSessions(cluster, testTime.Add(5*time.Minute)), "the session is still flapping")
+	assert.True(t, v3.ClusterConditionAgentConnectionStable.IsFalse(cluster))
+
+	assert.True(t, ResolveSessions(cluster, testTime.Add(FlapWindow+time.Second)))
+	assert.True(t, v3.ClusterConditionAgentConnectionStable.IsTrue(cluster))
+	assert.Empty(t, v3.ClusterConditionAgentConnectionStable.GetReason(cluster))
+	assert.Len(t, cluster.Status.AgentSessions, 2)
+
+	assert.True(t, ResolveSessions(cluster, testTime.Add(SessionRetention+time.Second)))
+	require.Len(t, cluster.Status.AgentSessions, 1)
+	assert.Equal(t, "stv-cluster-c-1", cluster.Status.AgentSessions[0].ClientKey)
+	assert.False(t, ResolveSessions(cluster, testTime.Add(SessionRetention+time.Second)))
+}
+
+func TestClusterName(t *testing.T) {
+	assert.Equal(t, "c-1", clusterName("stv-cluster-c-1"))
+	assert.Equal(t, "c-1", clusterName("c-1"))
+	assert.Equal(t, "c-1", clusterName("c-1:m-1"))
+}
+
+func TestApplySessionEventReplicaShutdown(t *testing.T) {
+	cluster := &v3.Cluster{}
+	connect(cluster, "10.0.0.1", testTime, "peer-a")
+	// A rolling upgrade of Rancher moves the session across replicas.
+	for i, peer := range []string{"peer-b", "peer-c", "peer-d"} {
+		previous := cluster.Status.AgentSessions[0].Peer
+		at := testTime.Add(time.Duration(i+1) * time.Minute)
+		require.True(t, applySessionEvent(cluster, sessionEvent{
+			eventType: sessionDisconnected,
+			clientKey: "stv-cluster-c-1",
+			time:      at,
+			shutdown:  true,
+		}, previous))
+		assert.True(t, cluster.Status.AgentSessions[0].ClosedByReplicaShutdown)
+		require.True(t, connect(cluster, "10.0.0.1", at.Add(time.Second), peer))
+	}
+
+	session := cluster.Status.AgentSessions[0]
+	assert.Equal(t, int64(3), session.Reconnects)
+	assert.Equal(t, int64(0), session.RecentReconnects)
+	assert.False(t, session.ClosedByReplicaShutdown)
+	assert.Empty(t, v3.ClusterConditionAgentConnectionStable.GetStatus(cluster))
+}
+
+func TestApplySessionEventResync(t *testing.T) {
+	cluster := &v3.Cluster{}
+	resync := func(active bool, at time.Time, peer string) bool {
+		return applySessionEvent(cluster, sessionEvent{
+			eventType:     sessionResync,
+			clientKey:     "stv-cluster-c-1",
+			remoteAddress: "10.0.0.1",
+			time:          at,
+			active:        active,
+		}, peer)
+	}
+
+	// The connect event was dropped.
+	require.True(t, resync(true, testTime, "peer-a"))
+	require.Len(t, cluster.Status.AgentSessions, 1)
+	assert.Equal(t, "peer-a", cluster.Status.AgentSessions[0].Peer)
+	assert.Equal(t, int64(0), cluster.Status.AgentSessions[0].Reconnects)
+	assert.False(t, resync(true, testTime, "peer-a"), "the session is up to date")
+
+	// The disconnect event was dropped.
+	require.True(t, resync(false, testTime.Add(time.Minute), "peer-a"))
+	assert.NotNil(t, cluster.Status.AgentSessions[0].DisconnectedAt)
+
+	// The agent connected to another replica since.
+	connect(cluster, "10.0.0.1", testTime.Add(2*time.Minute), "peer-b")
+	assert.False(t, resync(true, testTime, "peer-a"))
+	assert.False(t, resync(false, testTime.Add(3*time.Minute), "peer-a"))
+	assert.Nil(t, cluster.Status.AgentSessions[0].DisconnectedAt)
+}
+
+func TestSessionTrackerDroppedEvents(t *testing.T) {
+	tracker := &SessionTracker{
+		now:    func() time.Time { return testTime },
+		events: make(chan sessionEvent),
+		active: map[string]*activeSession{},
+		dirty:  map[string]bool{},
+	}
+	req := httptest.NewRequest("GET", "/v3/connect", nil)
+	first := tracker.connected("c-1", req)
+	second := tracker.connected("c-1", req)
+	assert.True(t, tracker.dirty["c-1"], "a dropped event must be resynced")
+
+	// Closing the old session keeps the new one active.
+	tracker.disconnected("c-1", first)
+	assert.Same(t, second, tracker.active["c-1"])
+	tracker.disconnected("c-1", second)
+	assert.Empty(t, tracker.active)
+}
+
+func TestRemoteAddress(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v3/connect", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	assert.Equal(t, "10.0.0.1", remoteAddress(req))
+
+	req.Header.Set("X-Forwarded-For", "192.168.0.1, 10.0.0.2")
+	assert.Equal(t, "10.0.0.1", remoteAddress(req), "X-Forwarded-For of untrusted clients is ignored")
+
+	require.NoError(t, settings.TrustedProxyCIDRs.Set("10.0.0.0/8"))
+	t.Cleanup(func() { settings.TrustedProxyCIDRs.Set("") })
+	assert.Equal(t, "192.168.0.1", remoteAddress(req))
+}
+
+func TestSessionMetrics(t *testing.T) {
+	prometheusMetrics = true
+	t.Cleanup(func() { prometheusMetrics = false })
+
+	server, client := net.Pipe()
+	defer client.Close()
+	conn, brw := countSessionBytes("c-1", server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)))
+	go func() {
+		client.Write([]byte("ping"))
+		io.ReadFull(client, make([]byte, 5))
+	}()
+	_, err := io.ReadFull(brw, make([]byte, 4))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), testutil.ToFloat64(sessionReceiveBytes.WithLabelValues("c-1", "c-1")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(sessionTransmitBytes.WithLabelValues("c-1", "c-1")))
+
+	inFlight := sessionRequestsInFlight.WithLabelValues("c-1", "c-1")
+	proxied := &inFlightConn{Conn: conn, inFlight: inFlight}
+	inFlight.Inc()
+	assert.Equal(t, float64(1), testutil.ToFloat64(inFlight))
+	proxied.Close()
+	proxied.Close()
+	assert.Equal(t, float64(0), testutil.ToFloat64(inFlight))
+}
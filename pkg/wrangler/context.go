@@ -129,6 +129,7 @@ type Context struct {
 	MultiClusterManager MultiClusterManager
 	TunnelServer        *remotedialer.Server
 	TunnelAuthorizer    *tunnelserver.Authorizers
+	TunnelSessions      *tunnelserver.SessionTracker
 	PeerManager         peermanager.PeerManager
 	Provisioning        provisioningv1.Interface
 	RBAC                rbacv1.Interface
@@ -417,7 +418,11 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 	}
 
 	tunnelAuth := &tunnelserver.Authorizers{}
-	tunnelServer := remotedialer.New(tunnelAuth.Authorize, tunnelserver.ErrorWriter)
+	var tunnelServer *remotedialer.Server
+	tunnelSessions := tunnelserver.NewSessionTracker(ctx, mgmt.Management().V3().Cluster(), func() string {
+		return tunnelserver.PeerID(tunnelServer)
+	})
+	tunnelServer = remotedialer.New(tunnelSessions.Authorize(tunnelAuth.Authorize), tunnelserver.ErrorWriter)
 	peerManager, err := tunnelserver.NewPeerManager(ctx, core.Core().V1().Endpoints(), tunnelServer)
 	if err != nil {
 		return nil, err
@@ -464,6 +469,7 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 		HelmOperations:          helmop,
 		SystemChartsManager:     systemCharts,
 		TunnelAuthorizer:        tunnelAuth,
+		TunnelSessions:          tunnelSessions,
 		TunnelServer:            tunnelServer,
 		Plan:                    plan.Upgrade().V1(),
 		SCC:                     scc.Scc().V1(),